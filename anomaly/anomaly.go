@@ -0,0 +1,158 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// BulkDumpPattern - pattern name of bulk credential dump events
+const BulkDumpPattern = "bulk credential dump"
+
+// Detector - flags commits with much more leaks than usual for repo,
+// such commits are usually config dumps or database exports
+type Detector struct {
+	MinLeaks int     // commit with less leaks is never flagged
+	Sigma    float64 // how many standard deviations above mean of repo is anomaly
+	Window   int     // how many last commits with leaks of repo are the baseline
+	// StateFile - counts of repos survive restart, they are kept in memory if empty
+	StateFile string
+
+	mutex sync.Mutex
+	repos map[string]*repoStats
+	dirty bool
+}
+
+type repoStats struct {
+	Commits []string        `json:"commits"` // order of commits to forget the oldest
+	Counts  map[string]int  `json:"counts"`
+	Flagged map[string]bool `json:"flagged"`
+}
+
+// Load - counts of repos from state file, nothing is known if there is no state
+func (d *Detector) Load() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.repos = map[string]*repoStats{}
+	if d.StateFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(d.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &d.repos)
+}
+
+// Save - write counts changed since the last save through temporary file, so it is never half written
+func (d *Detector) Save() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.StateFile == "" || !d.dirty {
+		return nil
+	}
+	data, err := json.Marshal(d.repos)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(d.StateFile), filepath.Base(d.StateFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), d.StateFile); err != nil {
+		return err
+	}
+	d.dirty = false
+	return nil
+}
+
+// Observe - count leak to its commit, bulk dump event is returned once when commit becomes anomalous
+func (d *Detector) Observe(leak hungryfox.Leak) (*hungryfox.Leak, bool) {
+	if leak.CommitHash == "" || leak.Kind != "" {
+		return nil, false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.repos == nil {
+		d.repos = map[string]*repoStats{}
+	}
+	d.dirty = true
+	stats, ok := d.repos[leak.RepoURL]
+	if !ok {
+		stats = &repoStats{Counts: map[string]int{}, Flagged: map[string]bool{}}
+		d.repos[leak.RepoURL] = stats
+	}
+	if _, ok := stats.Counts[leak.CommitHash]; !ok {
+		stats.Commits = append(stats.Commits, leak.CommitHash)
+		if len(stats.Commits) > d.Window {
+			oldest := stats.Commits[0]
+			stats.Commits = stats.Commits[1:]
+			delete(stats.Counts, oldest)
+			delete(stats.Flagged, oldest)
+		}
+	}
+	stats.Counts[leak.CommitHash]++
+	count := stats.Counts[leak.CommitHash]
+	if count < d.MinLeaks || stats.Flagged[leak.CommitHash] {
+		return nil, false
+	}
+	mean, stddev := stats.baseline(leak.CommitHash)
+	if float64(count) <= mean+d.Sigma*stddev {
+		return nil, false
+	}
+	stats.Flagged[leak.CommitHash] = true
+	return &hungryfox.Leak{
+		PatternName: BulkDumpPattern,
+		// counts are not in leak string, so fingerprint of event is the same on rescan
+		Regexp:       fmt.Sprintf("%d leaks in one commit, %.1f per commit with leaks is usual for repo", count, mean),
+		RepoPath:     leak.RepoPath,
+		LeakString:   fmt.Sprintf("too many leaks in commit %s", leak.CommitHash),
+		RepoURL:      leak.RepoURL,
+		CommitHash:   leak.CommitHash,
+		TimeStamp:    leak.TimeStamp,
+		CommitAuthor: leak.CommitAuthor,
+		CommitEmail:  leak.CommitEmail,
+		Severity:     hungryfox.SeverityCritical,
+		Source:       leak.Source,
+		Kind:         hungryfox.LeakKindBulkDump,
+	}, true
+}
+
+// baseline - mean and standard deviation of leaks per commit except the given one
+func (s *repoStats) baseline(except string) (float64, float64) {
+	n, sum := 0, 0
+	for commit, count := range s.Counts {
+		if commit != except {
+			n++
+			sum += count
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	mean := float64(sum) / float64(n)
+	variance := 0.0
+	for commit, count := range s.Counts {
+		if commit != except {
+			variance += (float64(count) - mean) * (float64(count) - mean)
+		}
+	}
+	return mean, math.Sqrt(variance / float64(n))
+}
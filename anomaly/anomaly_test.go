@@ -0,0 +1,81 @@
+package anomaly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDetector(t *testing.T) {
+	observe := func(d *Detector, commit string, count int) []*hungryfox.Leak {
+		events := []*hungryfox.Leak{}
+		for i := 0; i < count; i++ {
+			if event, ok := d.Observe(hungryfox.Leak{RepoURL: "https://github.com/a/b", CommitHash: commit, Line: i}); ok {
+				events = append(events, event)
+			}
+		}
+		return events
+	}
+	Convey("usual commits are not flagged", t, func() {
+		d := &Detector{MinLeaks: 5, Sigma: 3, Window: 100}
+		for i := 0; i < 10; i++ {
+			So(observe(d, fmt.Sprintf("c%d", i), 1+i%3), ShouldBeEmpty)
+		}
+		Convey("spike is flagged once", func() {
+			events := observe(d, "dump", 50)
+			So(events, ShouldHaveLength, 1)
+			So(events[0].Kind, ShouldEqual, hungryfox.LeakKindBulkDump)
+			So(events[0].Severity, ShouldEqual, hungryfox.SeverityCritical)
+			So(events[0].CommitHash, ShouldEqual, "dump")
+		})
+	})
+	Convey("repo with many leaks per commit is not flagged", t, func() {
+		d := &Detector{MinLeaks: 5, Sigma: 3, Window: 100}
+		for i := 0; i < 10; i++ {
+			observe(d, fmt.Sprintf("c%d", i), 40+i)
+		}
+		So(observe(d, "next", 45), ShouldBeEmpty)
+	})
+	Convey("commits below min_leaks are not flagged", t, func() {
+		d := &Detector{MinLeaks: 20, Sigma: 3, Window: 100}
+		observe(d, "c1", 1)
+		So(observe(d, "c2", 19), ShouldBeEmpty)
+	})
+	Convey("old commits are forgotten", t, func() {
+		d := &Detector{MinLeaks: 1, Sigma: 3, Window: 2}
+		for i := 0; i < 5; i++ {
+			observe(d, fmt.Sprintf("c%d", i), 1)
+		}
+		So(d.repos["https://github.com/a/b"].Counts, ShouldHaveLength, 2)
+	})
+	Convey("counts survive restart with state file", t, func() {
+		dir, err := ioutil.TempDir("", "anomaly")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		stateFile := filepath.Join(dir, "anomaly.json")
+		d := &Detector{MinLeaks: 5, Sigma: 3, Window: 100, StateFile: stateFile}
+		So(d.Load(), ShouldBeNil)
+		for i := 0; i < 10; i++ {
+			observe(d, fmt.Sprintf("c%d", i), 1+i%3)
+		}
+		So(d.Save(), ShouldBeNil)
+
+		restarted := &Detector{MinLeaks: 5, Sigma: 3, Window: 100, StateFile: stateFile}
+		So(restarted.Load(), ShouldBeNil)
+		So(restarted.repos["https://github.com/a/b"].Counts, ShouldHaveLength, 10)
+		So(observe(restarted, "dump", 50), ShouldHaveLength, 1)
+		So(restarted.Save(), ShouldBeNil)
+
+		Convey("flagged commit is not flagged again", func() {
+			again := &Detector{MinLeaks: 5, Sigma: 3, Window: 100, StateFile: stateFile}
+			So(again.Load(), ShouldBeNil)
+			So(observe(again, "dump", 1), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+const adminReposPath = "/api/admin/repos"
+
+type adminRequest struct {
+	Action string `json:"action"` // add, remove, pause, resume, scan or rescan
+	Repo   string `json:"repo"`   // url of repo, absolute path for local repo to add
+}
+
+// handleAdminRepos - changes of scan list made by admin api on GET, change of scan list on POST
+func (s *Server) handleAdminRepos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	// admin api changes what is scanned and removes state of repos, so it is never open
+	token, err := s.Tokens.Require(r, tokens.ScopeAdmin)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if s.Admin == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("instance doesn't scan repos"))
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, s.Admin.AdminRepos())
+		return
+	}
+	req := adminRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("can't parse request: %v", err))
+		return
+	}
+	if req.Repo == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("repo is required"))
+		return
+	}
+	if !token.AllowRepo(req.Repo) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("repo %s is not allowed for token", req.Repo))
+		return
+	}
+	switch req.Action {
+	case "add":
+		err = s.Admin.AddRepo(req.Repo)
+	case "remove":
+		err = s.Admin.RemoveRepo(req.Repo)
+	case "pause", "resume":
+		err = s.Admin.PauseRepo(req.Repo, req.Action == "pause")
+	case "scan":
+		err = s.Admin.TriggerScan(req.Repo)
+	case "rescan":
+		err = s.Admin.RescanRepo(req.Repo)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown action '%s', one of add, remove, pause, resume, scan or rescan", req.Action))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.Log.Info().Str("service", "api").Str("action", req.Action).Str("repo", req.Repo).Str("token", token.Name).Msg("scan list changed by admin api")
+	writeJSON(w, http.StatusOK, s.Admin.AdminRepos())
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeAdmin struct {
+	repos     hungryfox.AdminRepos
+	scanned   []string
+	rescanned []string
+}
+
+func (f *fakeAdmin) AddRepo(url string) error {
+	f.repos.Added = append(f.repos.Added, url)
+	return nil
+}
+
+func (f *fakeAdmin) RemoveRepo(url string) error {
+	if url == "https://github.com/backend/missing" {
+		return fmt.Errorf("repo %s not found", url)
+	}
+	f.repos.Removed = append(f.repos.Removed, url)
+	return nil
+}
+
+func (f *fakeAdmin) PauseRepo(url string, paused bool) error {
+	if paused {
+		f.repos.Paused = append(f.repos.Paused, url)
+	} else {
+		f.repos.Paused = nil
+	}
+	return nil
+}
+
+func (f *fakeAdmin) TriggerScan(urls ...string) error {
+	f.scanned = append(f.scanned, urls...)
+	return nil
+}
+
+func (f *fakeAdmin) RescanRepo(url string) error {
+	f.rescanned = append(f.rescanned, url)
+	return nil
+}
+
+func (f *fakeAdmin) AdminRepos() hungryfox.AdminRepos {
+	return f.repos
+}
+
+func TestAdminRepos(t *testing.T) {
+	admin := &fakeAdmin{}
+	s := &Server{
+		Admin: admin,
+		Tokens: tokens.Tokens{
+			{Name: "ops", Secret: "o", Scopes: []string{tokens.ScopeAdmin}},
+			{Name: "backend", Secret: "b", Scopes: []string{tokens.ScopeAdmin}, Repos: []string{"backend/*"}},
+			{Name: "reader", Secret: "r", Scopes: []string{tokens.ScopeLeaks}},
+		},
+	}
+	post := func(token, action, repo string) (int, hungryfox.AdminRepos) {
+		body := fmt.Sprintf(`{"action": %q, "repo": %q}`, action, repo)
+		r := httptest.NewRequest(http.MethodPost, adminReposPath, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.handleAdminRepos(w, r)
+		result := hungryfox.AdminRepos{}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result
+	}
+
+	Convey("repos are added, paused, scanned and removed", t, func() {
+		code, repos := post("o", "add", "https://github.com/frontend/app")
+		So(code, ShouldEqual, http.StatusOK)
+		So(repos.Added, ShouldResemble, []string{"https://github.com/frontend/app"})
+		code, repos = post("o", "pause", "https://github.com/frontend/app")
+		So(repos.Paused, ShouldResemble, []string{"https://github.com/frontend/app"})
+		code, repos = post("o", "resume", "https://github.com/frontend/app")
+		So(repos.Paused, ShouldBeEmpty)
+		post("o", "scan", "https://github.com/frontend/app")
+		So(admin.scanned, ShouldResemble, []string{"https://github.com/frontend/app"})
+		code, _ = post("o", "rescan", "https://github.com/frontend/app")
+		So(code, ShouldEqual, http.StatusOK)
+		So(admin.rescanned, ShouldResemble, []string{"https://github.com/frontend/app"})
+		code, repos = post("o", "remove", "https://github.com/frontend/app")
+		So(repos.Removed, ShouldResemble, []string{"https://github.com/frontend/app"})
+	})
+
+	Convey("errors of scan manager and unknown actions are bad requests", t, func() {
+		code, _ := post("o", "remove", "https://github.com/backend/missing")
+		So(code, ShouldEqual, http.StatusBadRequest)
+		code, _ = post("o", "delete", "https://github.com/frontend/app")
+		So(code, ShouldEqual, http.StatusBadRequest)
+	})
+
+	Convey("admin scope and repos of token are required", t, func() {
+		code, _ := post("r", "scan", "https://github.com/backend/api")
+		So(code, ShouldEqual, http.StatusUnauthorized)
+		code, _ = post("b", "scan", "https://github.com/frontend/app")
+		So(code, ShouldEqual, http.StatusForbidden)
+		code, _ = post("b", "scan", "https://github.com/backend/api")
+		So(code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("request without token is unauthorized", t, func() {
+		w := httptest.NewRecorder()
+		s.handleAdminRepos(w, httptest.NewRequest(http.MethodGet, adminReposPath, nil))
+		So(w.Code, ShouldEqual, http.StatusUnauthorized)
+	})
+
+	Convey("admin api is not served without admin tokens", t, func() {
+		_, ok := (&Server{Admin: admin}).routes()[adminReposPath]
+		So(ok, ShouldBeFalse)
+		_, ok = s.routes()[adminReposPath]
+		So(ok, ShouldBeTrue)
+		w := httptest.NewRecorder()
+		(&Server{Admin: admin}).handleAdminRepos(w, httptest.NewRequest(http.MethodGet, adminReposPath, nil))
+		So(w.Code, ShouldEqual, http.StatusUnauthorized)
+	})
+
+	Convey("instance without scan manager answers 404", t, func() {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, adminReposPath, nil)
+		r.Header.Set("Authorization", "Bearer o")
+		(&Server{Tokens: s.Tokens}).handleAdminRepos(w, r)
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+}
@@ -1,25 +1,124 @@
 package api
 
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/buildinfo"
+	"github.com/AlexAkulov/hungryfox/clock"
+	"github.com/AlexAkulov/hungryfox/health"
+	"github.com/AlexAkulov/hungryfox/receipt"
+	"github.com/AlexAkulov/hungryfox/senders/spool"
+	"github.com/AlexAkulov/hungryfox/tokens"
+
+	"github.com/rs/zerolog"
+)
+
+// Server - HTTP API for querying leaks
 type Server struct {
+	Listen string
+	Leaks  hungryfox.ILeakStore
+	Repos  hungryfox.IRepoStore
+	// Statuses - history of leak statuses, every leak is open if nil
+	Statuses hungryfox.IStatusStore
+	Tokens   tokens.Tokens
+	UI       bool
+	// Ingest - leaks received from edge instances are sent here, ingest is disabled if nil
+	Ingest chan<- *hungryfox.Leak
+	// Queue - scan order of repos, it is empty if nil
+	Queue hungryfox.IScanQueue
+	// Costs - time spent by families of rules, it is empty if nil
+	Costs hungryfox.IRuleCosts
+	// Admin - runtime management of scan list, admin api answers 404 if nil
+	Admin hungryfox.IRepoAdmin
+	// Readiness - services of instance for /readyz, instance is ready if nil
+	Readiness *health.Readiness
+	// Signer - receipt_key_file which signs manifests of scans, manifests are not available if nil
+	Signer *receipt.Signer
+	// Version - build, rules and latest release of instance, unknown if nil
+	Version func() buildinfo.Info
+	Clock   clock.Clock // system clock if nil
+	Log     zerolog.Logger
 
+	server       *http.Server
+	ingestMutex  sync.Mutex
+	envelopes    map[string]time.Time // ids of received envelopes and when they were received
+	fingerprints map[string]bool      // fingerprints of known leaks of all sources
+	csrfOnce     sync.Once
+	csrfKey      []byte // key of csrf tokens of ui forms
 }
 
+// Start - start listen
 func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.Listen)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	for pattern, handler := range s.routes() {
+		mux.HandleFunc(pattern, handler)
+	}
+	s.server = &http.Server{Handler: mux}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.Log.Error().Str("error", err.Error()).Str("service", "api").Msg("serve failed")
+		}
+	}()
 	return nil
 }
 
-func AddFilter() error {
-	return nil
+func (s *Server) routes() map[string]http.HandlerFunc {
+	routes := map[string]http.HandlerFunc{
+		"/api/leaks":    s.handleLeaks,
+		statusPath:      s.handleLeakStatus,
+		statusUndoPath:  s.handleStatusUndo,
+		statusBulkPath:  s.handleStatusBulk,
+		reposPath:       s.handleRepos,
+		queuePath:       s.handleQueue,
+		manifestPath:    s.handleManifest,
+		versionPath:     s.handleVersion,
+		ruleCostsPath:   s.handleRuleCosts,
+		healthzPath:     s.handleHealthz,
+		readyzPath:      s.handleReadyz,
+		"/api/badge":    s.handleBadge,
+		"/openapi.json": s.handleOpenAPI,
+	}
+	if s.Tokens.Configured(tokens.ScopeAdmin) {
+		routes[adminReposPath] = s.handleAdminRepos
+	}
+	if s.UI {
+		routes[hungryfox.UILeakPath] = s.handleUILeak
+		routes[uiSearchPath] = s.handleUISearch
+		routes[uiDashboardPath] = s.handleUIDashboard
+	}
+	if s.Ingest != nil {
+		routes[spool.IngestPath] = s.handleIngest
+	}
+	return routes
 }
 
-func AddPattern() error {
-	return nil
+func (s *Server) now() time.Time {
+	return clock.Or(s.Clock).Now()
 }
 
-func MarkLeakAsResolved() error {
-	return nil
+// Stop - stop listen
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
 }
 
-func Status() {
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
 
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
 }
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+// badgeColors - shields.io color names to svg colors
+var badgeColors = map[string]string{
+	"red":         "#e05d44",
+	"brightgreen": "#4c1",
+	"lightgrey":   "#9f9f9f",
+}
+
+type badge struct {
+	Repo        string     `json:"repo"`
+	LastScan    *time.Time `json:"last_scan,omitempty"`
+	ScanSuccess bool       `json:"scan_success"`
+	Unhealthy   bool       `json:"unhealthy,omitempty"`
+	OpenLeaks   int        `json:"open_leaks"`
+}
+
+// shieldsBadge - https://shields.io/endpoint format
+type shieldsBadge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+func findRepo(repos []hungryfox.Repo, name string) *hungryfox.Repo {
+	name = strings.TrimSuffix(strings.Trim(name, "/"), ".git")
+	for i := range repos {
+		location := repos[i].Location
+		for _, candidate := range []string{location.URL, location.RepoPath, strings.TrimSuffix(location.CloneURL, ".git")} {
+			if candidate != "" && strings.EqualFold(candidate, name) {
+				return &repos[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (b *badge) message(now time.Time) (string, string) {
+	switch {
+	case b.Unhealthy:
+		return "unhealthy", "lightgrey"
+	case b.LastScan == nil:
+		return "not scanned", "lightgrey"
+	case b.OpenLeaks > 0:
+		return fmt.Sprintf("%d leaks", b.OpenLeaks), "red"
+	case !b.ScanSuccess:
+		return "scan failed", "lightgrey"
+	}
+	return fmt.Sprintf("clean, scanned %s ago", humanDuration(now.Sub(*b.LastScan))), "brightgreen"
+}
+
+func humanDuration(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// badgeSVG - flat badge, text width is estimated as 7px per char
+func badgeSVG(label, message, color string) string {
+	labelWidth, messageWidth := len(label)*7+10, len(message)*7+10
+	width := labelWidth + messageWidth
+	label, message = html.EscapeString(label), html.EscapeString(message)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`+
+		`<rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%d" y="14">%s</text><text x="%d" y="14">%s</text></g></svg>`,
+		width, label, message,
+		labelWidth, labelWidth, messageWidth, badgeColors[color],
+		labelWidth/2, label, labelWidth+messageWidth/2, message)
+}
+
+func (s *Server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	token, err := s.Tokens.Authorize(r, tokens.ScopeBadge)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	name := r.URL.Query().Get("repo")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("repo is required"))
+		return
+	}
+	repos, err := s.Repos.GetRepos()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	repo := findRepo(repos, name)
+	if repo == nil || !token.AllowRepo(repo.Location.RepoPath, repo.Location.URL) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("repo %s not found", name))
+		return
+	}
+	leaks, err := s.Leaks.GetLeaks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	history, err := s.statusHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	result := badge{Repo: repo.Location.URL, ScanSuccess: repo.Scan.Success, Unhealthy: repo.Scan.Unhealthy}
+	if !repo.Scan.EndTime.IsZero() {
+		result.LastScan = &repo.Scan.EndTime
+	}
+	now := s.now()
+	for _, leak := range leaks {
+		if !leak.Purged && leak.RepoURL == repo.Location.URL && hungryfox.IsOpen(hungryfox.StatusAsOf(history[leak.Fingerprint()], now)) {
+			result.OpenLeaks++
+		}
+	}
+	message, color := result.message(now)
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	switch r.URL.Query().Get("format") {
+	case "json":
+		writeJSON(w, http.StatusOK, result)
+	case "shields":
+		writeJSON(w, http.StatusOK, shieldsBadge{SchemaVersion: 1, Label: "hungryfox", Message: message, Color: color})
+	case "", "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprint(w, badgeSVG("hungryfox", message, color))
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("bad format '%s'", r.URL.Query().Get("format")))
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeRepoStore []hungryfox.Repo
+
+func (f fakeRepoStore) GetRepos() ([]hungryfox.Repo, error) {
+	return f, nil
+}
+
+func TestBadge(t *testing.T) {
+	scanned := time.Now().Add(-2 * time.Hour)
+	s := &Server{
+		Repos: fakeRepoStore{
+			{Location: hungryfox.RepoLocation{URL: "https://github.com/a/leaky", RepoPath: "a/leaky"}, Scan: hungryfox.ScanStatus{EndTime: scanned, Success: true}},
+			{Location: hungryfox.RepoLocation{URL: "https://github.com/a/clean", RepoPath: "a/clean"}, Scan: hungryfox.ScanStatus{EndTime: scanned, Success: true}},
+			{Location: hungryfox.RepoLocation{URL: "https://github.com/a/new", RepoPath: "a/new"}},
+		},
+		Leaks: fakeLeakStore{
+			{RepoURL: "https://github.com/a/leaky"},
+			{RepoURL: "https://github.com/a/leaky"},
+		},
+	}
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.handleBadge(w, httptest.NewRequest(http.MethodGet, "/api/badge?"+query, nil))
+		return w
+	}
+
+	Convey("json badge by repo path", t, func() {
+		w := get("repo=a/leaky&format=json")
+		So(w.Code, ShouldEqual, http.StatusOK)
+		result := badge{}
+		So(json.Unmarshal(w.Body.Bytes(), &result), ShouldBeNil)
+		So(result.OpenLeaks, ShouldEqual, 2)
+		So(result.LastScan, ShouldNotBeNil)
+	})
+	Convey("shields badge messages", t, func() {
+		result := shieldsBadge{}
+		json.Unmarshal(get("repo=https://github.com/a/clean&format=shields").Body.Bytes(), &result)
+		So(result.Message, ShouldEqual, "clean, scanned 2h ago")
+		So(result.Color, ShouldEqual, "brightgreen")
+		json.Unmarshal(get("repo=a/new&format=shields").Body.Bytes(), &result)
+		So(result.Message, ShouldEqual, "not scanned")
+	})
+	Convey("svg badge", t, func() {
+		w := get("repo=a/leaky")
+		So(w.Header().Get("Content-Type"), ShouldEqual, "image/svg+xml")
+		So(strings.Contains(w.Body.String(), "2 leaks"), ShouldBeTrue)
+	})
+	Convey("unknown repo", t, func() {
+		So(get("repo=a/nope").Code, ShouldEqual, http.StatusNotFound)
+	})
+	Convey("badge token", t, func() {
+		s.Tokens = tokens.Tokens{{Name: "readme", Secret: "r", Scopes: []string{tokens.ScopeBadge}}}
+		defer func() { s.Tokens = nil }()
+		So(get("repo=a/leaky&token=r").Code, ShouldEqual, http.StatusOK)
+		So(get("repo=a/leaky").Code, ShouldEqual, http.StatusUnauthorized)
+	})
+}
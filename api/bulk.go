@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+const statusBulkPath = "/api/leaks/status/bulk"
+
+type bulkRequest struct {
+	Status  string `json:"status"`
+	Comment string `json:"comment"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+// BulkStatusEvents - status events for all leaks matching filter of /api/leaks query,
+// leaks which already have the status are skipped, comment is the mandatory reason of change
+func BulkStatusEvents(leaks []hungryfox.Leak, history map[string][]hungryfox.StatusEvent, filter url.Values, status, comment, actor string, now time.Time) ([]hungryfox.StatusEvent, error) {
+	if !hungryfox.ValidStatus(status) {
+		return nil, fmt.Errorf("unknown status '%s'", status)
+	}
+	if comment == "" {
+		return nil, fmt.Errorf("comment is required")
+	}
+	q, err := parseLeaksQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	if !q.AsOf.IsZero() {
+		return nil, fmt.Errorf("as_of can't be used for status changes")
+	}
+	if len(q.Filters) == 0 && q.Since.IsZero() && q.Until.IsZero() && q.Search.Empty() {
+		return nil, fmt.Errorf("filter is required")
+	}
+	q.history = history
+	q.now = now
+	result := []hungryfox.StatusEvent{}
+	seen := map[string]bool{}
+	for _, leak := range leaks {
+		if leak.Purged {
+			continue
+		}
+		fields := q.leakFields(leak)
+		fingerprint := leak.Fingerprint()
+		if seen[fingerprint] || fields["status"] == status || !q.match(leak, fields) {
+			continue
+		}
+		seen[fingerprint] = true
+		event := hungryfox.StatusEvent{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Time:        now,
+			Actor:       actor,
+			Comment:     comment,
+		}
+		event.ID = event.EventID()
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+// handleStatusBulk - change status of all leaks matching filter of query string
+func (s *Server) handleStatusBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if s.Statuses == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("status_file is not configured"))
+		return
+	}
+	token, err := s.Tokens.Authorize(r, tokens.ScopeTriage)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	req := bulkRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("can't parse request: %v", err))
+		return
+	}
+	leaks, err := s.Leaks.GetLeaks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	allowed := leaks[:0:0]
+	for _, leak := range leaks {
+		if token.AllowRepo(leak.RepoPath, leak.RepoURL) {
+			allowed = append(allowed, leak)
+		}
+	}
+	history, err := s.statusHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	actor := ""
+	if token != nil {
+		actor = token.Name
+	}
+	events, err := BulkStatusEvents(allowed, history, r.URL.Query(), req.Status, req.Comment, actor, s.now().UTC())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !req.DryRun {
+		for _, event := range events {
+			if err := s.Statuses.AddEvent(event); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		s.Log.Info().Str("status", req.Status).Str("actor", actor).Int("leaks", len(events)).Msg("leak statuses changed in bulk")
+	}
+	fingerprints := make([]string, len(events))
+	for i, event := range events {
+		fingerprints[i] = event.Fingerprint
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"dry_run":      req.DryRun,
+		"changed":      len(events),
+		"fingerprints": fingerprints,
+	})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+	"github.com/rs/zerolog"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatusBulk(t *testing.T) {
+	ts := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	leaks := fakeLeakStore{
+		{RepoURL: "https://github.com/a/b", PatternName: "aws", FilePath: "test/keys", TimeStamp: ts},
+		{RepoURL: "https://github.com/a/b", PatternName: "aws", FilePath: "main.go", TimeStamp: ts},
+		{RepoURL: "https://github.com/a/b", PatternName: "slack", FilePath: "test/hook", TimeStamp: ts},
+		{RepoURL: "https://github.com/c/d", PatternName: "aws", FilePath: "test/keys", TimeStamp: ts},
+	}
+	statuses := &fakeStatusStore{}
+	s := &Server{
+		Leaks:    leaks,
+		Statuses: statuses,
+		Tokens: tokens.Tokens{
+			{Name: "alice", Secret: "t", Scopes: []string{tokens.ScopeTriage}, Repos: []string{"github.com/a/*"}},
+		},
+		Log: zerolog.Nop(),
+	}
+	bulk := func(query string, req bulkRequest) (*httptest.ResponseRecorder, map[string]interface{}) {
+		data, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, statusBulkPath+"?"+query, bytes.NewReader(data))
+		r.Header.Set("Authorization", "Bearer t")
+		w := httptest.NewRecorder()
+		s.handleStatusBulk(w, r)
+		result := map[string]interface{}{}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w, result
+	}
+	Convey("reason and filter are required", t, func() {
+		w, _ := bulk("rule=aws", bulkRequest{Status: hungryfox.StatusIgnored})
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		w, _ = bulk("", bulkRequest{Status: hungryfox.StatusIgnored, Comment: "fixtures"})
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		w, _ = bulk("rule=aws", bulkRequest{Status: "done", Comment: "fixtures"})
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+	})
+	Convey("dry run doesn't change statuses", t, func() {
+		w, result := bulk("rule=aws", bulkRequest{Status: hungryfox.StatusIgnored, Comment: "fixtures", DryRun: true})
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(result["changed"], ShouldEqual, 2)
+		So(statuses.events, ShouldBeEmpty)
+	})
+	Convey("matching leaks of allowed repos are changed once", t, func() {
+		w, result := bulk("rule=aws&status=open", bulkRequest{Status: hungryfox.StatusIgnored, Comment: "fixtures"})
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(result["changed"], ShouldEqual, 2)
+		So(statuses.events, ShouldHaveLength, 2)
+		So(statuses.events[0].Actor, ShouldEqual, "alice")
+		So(statuses.events[0].Comment, ShouldEqual, "fixtures")
+		_, result = bulk("rule=aws", bulkRequest{Status: hungryfox.StatusIgnored, Comment: "fixtures"})
+		So(result["changed"], ShouldEqual, 0)
+	})
+}
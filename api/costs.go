@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+const ruleCostsPath = "/api/rules/costs"
+
+// handleRuleCosts - time spent by families of rules on repo groups since start, for cost attribution.
+// Tokens limited to repos can't see it
+func (s *Server) handleRuleCosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	token, err := s.Tokens.Authorize(r, tokens.ScopeLeaks)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if token != nil && len(token.Repos) > 0 {
+		// costs of all repo groups are visible only with token of every repo
+		writeError(w, http.StatusForbidden, fmt.Errorf("token '%s' is limited to repos", token.Name))
+		return
+	}
+	items := []hungryfox.RuleCost{}
+	if s.Costs != nil {
+		items = s.Costs.RuleCosts()
+	}
+	total := 0.0
+	for _, cost := range items {
+		total += cost.Seconds
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items":   items,
+		"seconds": total,
+	})
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+// uiDashboardPath - overview of repos, recent leaks and rule hits
+const uiDashboardPath = "/ui/"
+
+// uiRecentLimit - number of recent leaks on dashboard
+const uiRecentLimit = 20
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>HungryFox</title>
+  <style>
+    body { font-family: sans-serif; margin: 2em; color: #111111; }
+    th { text-align: left; }
+    td, th { padding-right: 2em; vertical-align: top; }
+    code { white-space: pre-wrap; word-break: break-all; }
+    .bad { color: #e05d44; }
+  </style>
+</head>
+<body>
+  <h1>HungryFox</h1>
+  <p>{{ .OpenLeaks }} open of {{ .TotalLeaks }} leaks in {{ len .Repos }} repos. <a href="{{ .SearchURL }}">Search</a></p>
+  <h2>Repos</h2>
+  <table>
+    <tr><th>Repo</th><th>Scan</th><th>Open leaks</th></tr>
+  {{ range .Repos }}
+    <tr><td>{{ .URL }}</td><td{{ if .Failed }} class="bad"{{ end }}>{{ .Scan }}</td><td>{{ .OpenLeaks }}</td></tr>
+  {{ end }}
+  </table>
+  <h2>Recent leaks</h2>
+  <table>
+  {{ range .Recent }}
+    <tr>
+      <td><a href="{{ .URL }}">{{ .Leak.PatternName }}</a></td>
+      <td>{{ .Leak.RepoURL }}<br>{{ .Leak.FilePath }}{{ if .Leak.Line }}:{{ .Leak.Line }}{{ end }}</td>
+      <td><code>{{ .Context }}</code></td>
+    </tr>
+  {{ end }}
+  </table>
+  <h2>Rules</h2>
+  <table>
+    <tr><th>Rule</th><th>Leaks</th><th>Open</th></tr>
+  {{ range .Rules }}
+    <tr><td>{{ .Name }}</td><td>{{ .Leaks }}</td><td>{{ .OpenLeaks }}</td></tr>
+  {{ end }}
+  </table>
+</body>
+</html>
+`))
+
+type dashboardRepo struct {
+	URL       string
+	Scan      string
+	Failed    bool
+	OpenLeaks int
+}
+
+type dashboardRule struct {
+	Name      string
+	Leaks     int
+	OpenLeaks int
+}
+
+type dashboardPage struct {
+	SearchURL  string
+	TotalLeaks int
+	OpenLeaks  int
+	Repos      []*dashboardRepo
+	Recent     []searchPageItem
+	Rules      []*dashboardRule
+}
+
+// handleUIDashboard - scan state of repos, recent leaks and hits of rules allowed for token, secrets are masked
+func (s *Server) handleUIDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != uiDashboardPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	token, err := s.Tokens.Require(r, tokens.ScopeLeaks)
+	if err != nil {
+		uiUnauthorized(w, err)
+		return
+	}
+	page := dashboardPage{SearchURL: uiSearchPath}
+	now := s.now()
+
+	var repos []hungryfox.Repo
+	if s.Repos != nil {
+		if repos, err = s.Repos.GetRepos(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	byURL := map[string]*dashboardRepo{}
+	for _, repo := range repos {
+		if !token.AllowRepo(repo.Location.RepoPath, repo.Location.URL) {
+			continue
+		}
+		item := &dashboardRepo{URL: repo.Location.URL}
+		switch scan := repo.Scan; {
+		case scan.Unhealthy:
+			item.Scan, item.Failed = "unhealthy", true
+		case scan.StartTime.After(scan.EndTime):
+			item.Scan = fmt.Sprintf("scanning for %s", humanDuration(now.Sub(scan.StartTime)))
+		case scan.EndTime.IsZero():
+			item.Scan = "not scanned"
+		case !scan.Success:
+			item.Scan, item.Failed = fmt.Sprintf("failed %s ago: %s", humanDuration(now.Sub(scan.EndTime)), scan.Error), true
+		default:
+			item.Scan = fmt.Sprintf("scanned %s ago", humanDuration(now.Sub(scan.EndTime)))
+		}
+		byURL[item.URL] = item
+		page.Repos = append(page.Repos, item)
+	}
+	sort.Slice(page.Repos, func(i, j int) bool { return page.Repos[i].URL < page.Repos[j].URL })
+
+	leaks, err := s.Leaks.GetLeaks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	history, err := s.statusHistory()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	allowed := leaks[:0:0]
+	rules := map[string]*dashboardRule{}
+	for _, leak := range leaks {
+		if leak.Purged || !token.AllowRepo(leak.RepoPath, leak.RepoURL) {
+			continue
+		}
+		allowed = append(allowed, leak)
+		rule := rules[leak.PatternName]
+		if rule == nil {
+			rule = &dashboardRule{Name: leak.PatternName}
+			rules[leak.PatternName] = rule
+			page.Rules = append(page.Rules, rule)
+		}
+		rule.Leaks++
+		page.TotalLeaks++
+		if !hungryfox.IsOpen(hungryfox.StatusAsOf(history[leak.Fingerprint()], now)) {
+			continue
+		}
+		rule.OpenLeaks++
+		page.OpenLeaks++
+		if repo := byURL[leak.RepoURL]; repo != nil {
+			repo.OpenLeaks++
+		}
+	}
+	sort.Slice(page.Rules, func(i, j int) bool {
+		if page.Rules[i].Leaks != page.Rules[j].Leaks {
+			return page.Rules[i].Leaks > page.Rules[j].Leaks
+		}
+		return page.Rules[i].Name < page.Rules[j].Name
+	})
+	sort.SliceStable(allowed, func(i, j int) bool {
+		if !allowed[i].FoundAt.Equal(allowed[j].FoundAt) {
+			return allowed[i].FoundAt.After(allowed[j].FoundAt)
+		}
+		return allowed[i].TimeStamp.After(allowed[j].TimeStamp)
+	})
+	for i := 0; i < len(allowed) && i < uiRecentLimit; i++ {
+		leak := allowed[i]
+		page.Recent = append(page.Recent, searchPageItem{Leak: leak, URL: hungryfox.UILeakPath + leak.Fingerprint(), Context: strings.TrimSpace(leak.Redacted())})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		s.Log.Error().Str("error", err.Error()).Str("service", "api").Msg("can't render dashboard")
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+)
+
+// handleHealthz - process is alive while it serves requests
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz - config is loaded, state store is open and senders are started
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ready, pending := true, []string{}
+	if s.Readiness != nil {
+		ready, pending = s.Readiness.Status()
+	}
+	if !ready {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "not ready", "pending": pending})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "pending": pending})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox/health"
+	"github.com/AlexAkulov/hungryfox/tokens"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHealth(t *testing.T) {
+	readiness := &health.Readiness{}
+	readiness.Expect("state", "router")
+	s := &Server{
+		Tokens:    tokens.Tokens{{Name: "all", Secret: "a", Scopes: []string{tokens.ScopeLeaks}}},
+		Readiness: readiness,
+	}
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		s.routes()[path](w, httptest.NewRequest(http.MethodGet, path, nil))
+		result := map[string]interface{}{}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result
+	}
+
+	Convey("liveness doesn't need token and services", t, func() {
+		code, result := get(healthzPath)
+		So(code, ShouldEqual, http.StatusOK)
+		So(result["status"], ShouldEqual, "ok")
+	})
+
+	Convey("not ready until all services are started", t, func() {
+		code, result := get(readyzPath)
+		So(code, ShouldEqual, http.StatusServiceUnavailable)
+		So(result["pending"], ShouldResemble, []interface{}{"router", "state"})
+
+		readiness.Ready("state")
+		code, result = get(readyzPath)
+		So(code, ShouldEqual, http.StatusServiceUnavailable)
+		So(result["pending"], ShouldResemble, []interface{}{"router"})
+	})
+
+	Convey("ready when all services are started", t, func() {
+		readiness.Ready("router")
+		code, result := get(readyzPath)
+		So(code, ShouldEqual, http.StatusOK)
+		So(result["status"], ShouldEqual, "ok")
+	})
+
+	Convey("not ready on shutdown but still alive", t, func() {
+		readiness.Stopping()
+		code, _ := get(readyzPath)
+		So(code, ShouldEqual, http.StatusServiceUnavailable)
+		code, _ = get(healthzPath)
+		So(code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("ready without readiness", t, func() {
+		s.Readiness = nil
+		code, _ := get(readyzPath)
+		So(code, ShouldEqual, http.StatusOK)
+	})
+}
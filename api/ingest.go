@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+	"github.com/AlexAkulov/hungryfox/senders/spool"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+// maxEnvelopeSize - envelopes are written per leak, so they are small
+const maxEnvelopeSize = 10 << 20
+
+const (
+	// envelopeTTL - ids of envelopes are kept for conflicts of repeated delivery, leaks of older ones are
+	// still deduplicated by fingerprints
+	envelopeTTL = 7 * 24 * time.Hour
+	// maxEnvelopes - the oldest ids are dropped over it
+	maxEnvelopes = 100000
+)
+
+type ingestResult struct {
+	Accepted   int `json:"accepted"`
+	Duplicates int `json:"duplicates"`
+}
+
+// handleIngest - receive envelope of edge instance, leaks already known from any site are skipped
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if len(s.Tokens) == 0 {
+		// leaks of ingest are routed to senders, open api would let anyone send notifications
+		writeError(w, http.StatusForbidden, fmt.Errorf("ingest requires api tokens"))
+		return
+	}
+	if _, err := s.Tokens.Authorize(r, tokens.ScopeIngest); err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxEnvelopeSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	envelope, err := spool.ParseEnvelope(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	leaks, err := s.ingest(envelope)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if leaks == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("envelope %s was received before", envelope.ID))
+		return
+	}
+	for i := range leaks {
+		s.Ingest <- &leaks[i]
+	}
+	s.Log.Debug().Str("source", envelope.Source).Str("envelope", envelope.ID).Int("leaks", len(leaks)).Msg("ingested")
+	writeJSON(w, http.StatusOK, ingestResult{Accepted: len(leaks), Duplicates: len(envelope.Leaks) - len(leaks)})
+}
+
+// ingest - new leaks of envelope, nil if envelope itself was received before
+func (s *Server) ingest(envelope *spool.Envelope) ([]hungryfox.Leak, error) {
+	s.ingestMutex.Lock()
+	defer s.ingestMutex.Unlock()
+	if s.fingerprints == nil {
+		// leaks of previous runs are in the store, envelopes received before restart are detected by them
+		stored, err := s.Leaks.GetLeaks()
+		if err != nil {
+			return nil, err
+		}
+		s.envelopes = map[string]time.Time{}
+		s.fingerprints = map[string]bool{}
+		for _, leak := range stored {
+			s.fingerprints[leak.Fingerprint()] = true
+		}
+	}
+	if _, ok := s.envelopes[envelope.ID]; ok {
+		return nil, nil
+	}
+	now := clock.Or(s.Clock).Now()
+	if len(s.envelopes) >= maxEnvelopes {
+		s.expireEnvelopes(now)
+	}
+	s.envelopes[envelope.ID] = now
+	result := []hungryfox.Leak{}
+	for _, leak := range envelope.Leaks {
+		fingerprint := leak.Fingerprint()
+		if s.fingerprints[fingerprint] {
+			continue
+		}
+		s.fingerprints[fingerprint] = true
+		if leak.Source == "" {
+			leak.Source = envelope.Source
+		}
+		result = append(result, leak)
+	}
+	return result, nil
+}
+
+// expireEnvelopes - drop ids older than envelopeTTL, the oldest half if all of them are newer
+func (s *Server) expireEnvelopes(now time.Time) {
+	for id, received := range s.envelopes {
+		if now.Sub(received) > envelopeTTL {
+			delete(s.envelopes, id)
+		}
+	}
+	if len(s.envelopes) < maxEnvelopes {
+		return
+	}
+	times := make([]time.Time, 0, len(s.envelopes))
+	for _, received := range s.envelopes {
+		times = append(times, received)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	median := times[len(times)/2]
+	for id, received := range s.envelopes {
+		if received.Before(median) {
+			delete(s.envelopes, id)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/senders/spool"
+	"github.com/AlexAkulov/hungryfox/tokens"
+	"github.com/rs/zerolog"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIngest(t *testing.T) {
+	stored := hungryfox.Leak{RepoURL: "https://github.com/a/b", PatternName: "aws", LeakString: "old"}
+	fresh := hungryfox.Leak{RepoURL: "https://github.com/a/b", PatternName: "aws", LeakString: "new"}
+	ingested := make(chan *hungryfox.Leak, 10)
+	s := &Server{
+		Leaks:  fakeLeakStore{stored},
+		Ingest: ingested,
+		Tokens: tokens.Tokens{
+			{Name: "edge", Secret: "e", Scopes: []string{tokens.ScopeIngest}},
+			{Name: "reader", Secret: "r", Scopes: []string{tokens.ScopeLeaks}},
+		},
+		Log: zerolog.Nop(),
+	}
+	post := func(token string, envelope interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(envelope)
+		req := httptest.NewRequest(http.MethodPost, spool.IngestPath, bytes.NewReader(data))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.handleIngest(w, req)
+		return w
+	}
+	envelope := spool.Envelope{Version: spool.EnvelopeVersion, ID: "edge-1-1", Source: "edge-1", Leaks: []hungryfox.Leak{stored, fresh}}
+
+	Convey("ingest scope is required", t, func() {
+		So(post("r", envelope).Code, ShouldEqual, http.StatusUnauthorized)
+	})
+	Convey("bad envelope is rejected", t, func() {
+		So(post("e", map[string]interface{}{"version": 99, "id": "x"}).Code, ShouldEqual, http.StatusBadRequest)
+	})
+	Convey("new leaks are passed with source", t, func() {
+		w := post("e", envelope)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldContainSubstring, `"accepted":1,"duplicates":1`)
+		So(len(ingested), ShouldEqual, 1)
+		leak := <-ingested
+		So(leak.LeakString, ShouldEqual, "new")
+		So(leak.Source, ShouldEqual, "edge-1")
+	})
+	Convey("repeated envelope is conflict", t, func() {
+		So(post("e", envelope).Code, ShouldEqual, http.StatusConflict)
+	})
+	Convey("same leak from another site is skipped", t, func() {
+		other := spool.Envelope{Version: spool.EnvelopeVersion, ID: "edge-2-1", Source: "edge-2", Leaks: []hungryfox.Leak{fresh}}
+		So(post("e", other).Body.String(), ShouldContainSubstring, `"accepted":0`)
+		So(len(ingested), ShouldEqual, 0)
+	})
+	Convey("ingest is closed without tokens", t, func() {
+		open := &Server{Leaks: fakeLeakStore{}, Ingest: ingested, Log: zerolog.Nop()}
+		w := httptest.NewRecorder()
+		open.handleIngest(w, httptest.NewRequest(http.MethodPost, spool.IngestPath, bytes.NewReader(nil)))
+		So(w.Code, ShouldEqual, http.StatusForbidden)
+	})
+}
+
+func TestExpireEnvelopes(t *testing.T) {
+	Convey("ids of old envelopes are dropped", t, func() {
+		now := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+		s := &Server{envelopes: map[string]time.Time{
+			"old":   now.Add(-envelopeTTL - time.Hour),
+			"fresh": now.Add(-time.Hour),
+		}}
+		s.expireEnvelopes(now)
+		So(s.envelopes, ShouldContainKey, "fresh")
+		So(s.envelopes, ShouldNotContainKey, "old")
+
+		Convey("the oldest half is dropped if all are fresh", func() {
+			s.envelopes = map[string]time.Time{}
+			for i := 0; i < maxEnvelopes; i++ {
+				s.envelopes[fmt.Sprint(i)] = now.Add(time.Duration(i) * time.Millisecond)
+			}
+			s.expireEnvelopes(now)
+			So(len(s.envelopes), ShouldEqual, maxEnvelopes/2)
+			So(s.envelopes, ShouldContainKey, fmt.Sprint(maxEnvelopes-1))
+		})
+	})
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/search"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// filterFields - query parameter to json field of leak
+var filterFields = map[string][]string{
+	"repo":        {"repo_url", "repo_path"},
+	"rule":        {"pattern_name"},
+	"severity":    {"severity"},
+	"status":      {"status"},
+	"author":      {"author", "email", "committer_email"},
+	"commit":      {"commit"},
+	"file":        {"filepath"},
+	"fingerprint": {"fingerprint"},
+	"source":      {"source"},
+	"language":    {"language"},
+	"kind":        {"kind"},
+	"verified":    {"verified"},
+}
+
+type leaksQuery struct {
+	Filters map[string][]string
+	Since   time.Time
+	Until   time.Time
+	AsOf    time.Time // leaks found before and their statuses at this time
+	Search  search.Query
+	Fields  []string
+	Limit   int
+	Cursor  string
+	Desc    bool
+
+	history map[string][]hungryfox.StatusEvent // status events of leak fingerprints
+	now     time.Time                          // statuses are taken at this time if as_of is not set
+}
+
+type leaksPage struct {
+	Items      []map[string]interface{} `json:"items"`
+	Total      int                      `json:"total"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+type leakRecord struct {
+	key    string
+	fields map[string]interface{}
+}
+
+func parseLeaksQuery(values url.Values) (*leaksQuery, error) {
+	q := &leaksQuery{
+		Filters: map[string][]string{},
+		Limit:   defaultLimit,
+		Desc:    true,
+	}
+	for param := range filterFields {
+		for _, value := range values[param] {
+			for _, v := range strings.Split(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					q.Filters[param] = append(q.Filters[param], v)
+				}
+			}
+		}
+	}
+	var err error
+	if v := values.Get("since"); v != "" {
+		if q.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("bad since: %v", err)
+		}
+	}
+	if v := values.Get("until"); v != "" {
+		if q.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("bad until: %v", err)
+		}
+	}
+	if v := values.Get("as_of"); v != "" {
+		if q.AsOf, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("bad as_of: %v", err)
+		}
+	}
+	q.Search = search.Parse(values.Get("q"))
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 {
+			return nil, fmt.Errorf("bad limit '%s'", v)
+		}
+		if q.Limit > maxLimit {
+			q.Limit = maxLimit
+		}
+	}
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Desc = false
+	default:
+		return nil, fmt.Errorf("bad order '%s'", values.Get("order"))
+	}
+	if v := values.Get("cursor"); v != "" {
+		cursor, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("bad cursor")
+		}
+		q.Cursor = string(cursor)
+	}
+	if v := values.Get("fields"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				q.Fields = append(q.Fields, field)
+			}
+		}
+	}
+	return q, nil
+}
+
+// leakKey - key for stable ordering of leaks, it is the cursor of pages, so it has fingerprint instead of
+// leak string which would put secrets in urls and logs
+func leakKey(leak hungryfox.Leak) string {
+	return leak.TimeStamp.UTC().Format("2006-01-02T15:04:05.000000000Z") + "\x00" + leak.Fingerprint()
+}
+
+func (q *leaksQuery) leakFields(leak hungryfox.Leak) map[string]interface{} {
+	fields := map[string]interface{}{}
+	data, _ := json.Marshal(leak)
+	json.Unmarshal(data, &fields)
+	fingerprint := leak.Fingerprint()
+	fields["fingerprint"] = fingerprint
+	fields["secret_fingerprint"] = leak.SecretFingerprint()
+	at := q.AsOf
+	if at.IsZero() {
+		at = q.now
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	fields["status"] = hungryfox.StatusAsOf(q.history[fingerprint], at)
+	return fields
+}
+
+func (q *leaksQuery) match(leak hungryfox.Leak, fields map[string]interface{}) bool {
+	if !q.Since.IsZero() && leak.TimeStamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !leak.TimeStamp.Before(q.Until) {
+		return false
+	}
+	if !q.AsOf.IsZero() {
+		foundAt := leak.FoundAt
+		if foundAt.IsZero() {
+			// leaks found before found_at was added
+			foundAt = leak.TimeStamp
+		}
+		if foundAt.After(q.AsOf) {
+			return false
+		}
+	}
+	if !q.Search.Match(leak) {
+		return false
+	}
+	for param, values := range q.Filters {
+		found := false
+		for _, field := range filterFields[param] {
+			fieldValue := fmt.Sprint(fields[field])
+			for _, v := range values {
+				if strings.EqualFold(fieldValue, v) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *leaksQuery) selectFields(fields map[string]interface{}) map[string]interface{} {
+	if len(q.Fields) == 0 {
+		return fields
+	}
+	result := map[string]interface{}{}
+	for _, field := range q.Fields {
+		if v, ok := fields[field]; ok {
+			result[field] = v
+		}
+	}
+	return result
+}
+
+// MatchLeaks - not purged leaks matching filter of /api/leaks query and time of their statuses, as_of or now
+func MatchLeaks(leaks []hungryfox.Leak, history map[string][]hungryfox.StatusEvent, filter url.Values, now time.Time) ([]hungryfox.Leak, time.Time, error) {
+	q, err := parseLeaksQuery(filter)
+	if err != nil {
+		return nil, now, err
+	}
+	q.history = history
+	q.now = now
+	result := []hungryfox.Leak{}
+	for _, leak := range leaks {
+		if !leak.Purged && q.match(leak, q.leakFields(leak)) {
+			result = append(result, leak)
+		}
+	}
+	if !q.AsOf.IsZero() {
+		return result, q.AsOf, nil
+	}
+	return result, now, nil
+}
+
+func (q *leaksQuery) apply(leaks []hungryfox.Leak) leaksPage {
+	records := []leakRecord{}
+	for _, leak := range leaks {
+		fields := q.leakFields(leak)
+		if !q.match(leak, fields) {
+			continue
+		}
+		records = append(records, leakRecord{key: leakKey(leak), fields: fields})
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if q.Desc {
+			return records[i].key > records[j].key
+		}
+		return records[i].key < records[j].key
+	})
+	page := leaksPage{Items: []map[string]interface{}{}, Total: len(records)}
+	start := 0
+	if q.Cursor != "" {
+		start = sort.Search(len(records), func(i int) bool {
+			if q.Desc {
+				return records[i].key < q.Cursor
+			}
+			return records[i].key > q.Cursor
+		})
+	}
+	for i := start; i < len(records) && len(page.Items) < q.Limit; i++ {
+		page.Items = append(page.Items, q.selectFields(records[i].fields))
+		if len(page.Items) == q.Limit && i+1 < len(records) {
+			page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(records[i].key))
+		}
+	}
+	return page
+}
+
+func (s *Server) handleLeaks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	token, err := s.Tokens.Authorize(r, tokens.ScopeLeaks)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	q, err := parseLeaksQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	leaks, err := s.Leaks.GetLeaks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if q.history, err = s.statusHistory(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	q.now = s.now()
+	allowed := leaks[:0:0]
+	for _, leak := range leaks {
+		if !leak.Purged && token.AllowRepo(leak.RepoPath, leak.RepoURL) {
+			allowed = append(allowed, leak)
+		}
+	}
+	writeJSON(w, http.StatusOK, q.apply(allowed))
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/receipt"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+const manifestPath = "/api/repos/manifest"
+
+// handleManifest - manifest of the last scan of repo by daemon signed with receipt_key_file, it has scanned refs
+// and fingerprints of all leaks found in repo so far
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	token, err := s.Tokens.Authorize(r, tokens.ScopeLeaks)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if s.Signer == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("receipt_key_file is not configured"))
+		return
+	}
+	name := r.URL.Query().Get("repo")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("repo is required"))
+		return
+	}
+	repos, err := s.Repos.GetRepos()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	repo := findRepo(repos, name)
+	if repo == nil || !token.AllowRepo(repo.Location.RepoPath, repo.Location.URL) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("repo %s not found", name))
+		return
+	}
+	if repo.Scan.EndTime.IsZero() {
+		writeError(w, http.StatusNotFound, fmt.Errorf("repo %s was not scanned yet", name))
+		return
+	}
+	leaks, err := s.Leaks.GetLeaks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	m := manifest(*repo, leaks)
+	if err := s.Signer.SignManifest(&m); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// manifest - refs and times of the last scan of repo, leaks removed by retention have no repo and are not included
+func manifest(repo hungryfox.Repo, leaks []hungryfox.Leak) receipt.Manifest {
+	m := receipt.Manifest{
+		Version:    receipt.ManifestVersion,
+		Repo:       repo.Location.URL,
+		Refs:       repo.State.Refs,
+		StartedAt:  repo.Scan.StartTime,
+		FinishedAt: repo.Scan.EndTime,
+		Leaks:      []string{},
+	}
+	if m.Refs == nil {
+		m.Refs = []string{}
+	}
+	seen := map[string]bool{}
+	for _, leak := range leaks {
+		fingerprint := leak.Fingerprint()
+		if leak.RepoURL != repo.Location.URL || seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+		m.Leaks = append(m.Leaks, fingerprint)
+	}
+	sort.Strings(m.Leaks)
+	return m
+}
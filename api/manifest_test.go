@@ -0,0 +1,85 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/receipt"
+	"github.com/AlexAkulov/hungryfox/tokens"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(privateKey)
+	keyFile := filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	signer, err := receipt.LoadSigner(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scanned := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	leak := hungryfox.Leak{RepoURL: "https://github.com/backend/api", PatternName: "aws", LeakString: "AKIA"}
+	s := &Server{
+		Repos: fakeRepoStore{
+			{Location: hungryfox.RepoLocation{URL: "https://github.com/backend/api", RepoPath: "backend/api"}, State: hungryfox.RepoState{Refs: []string{"abc"}}, Scan: hungryfox.ScanStatus{StartTime: scanned, EndTime: scanned.Add(time.Minute), Success: true}},
+			{Location: hungryfox.RepoLocation{URL: "https://github.com/frontend/app", RepoPath: "frontend/app"}},
+		},
+		Leaks: fakeLeakStore{leak, leak, {RepoURL: "https://github.com/frontend/app", PatternName: "slack"}},
+		Tokens: tokens.Tokens{
+			{Name: "all", Secret: "a", Scopes: []string{tokens.ScopeLeaks}},
+			{Name: "frontend", Secret: "f", Scopes: []string{tokens.ScopeLeaks}, Repos: []string{"frontend/*"}},
+		},
+		Signer: signer,
+	}
+	get := func(token, query string) (int, receipt.Manifest) {
+		r := httptest.NewRequest(http.MethodGet, manifestPath+"?"+query, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.handleManifest(w, r)
+		m := receipt.Manifest{}
+		json.Unmarshal(w.Body.Bytes(), &m)
+		return w.Code, m
+	}
+
+	Convey("manifest of the last scan is signed", t, func() {
+		code, m := get("a", "repo=backend/api")
+		So(code, ShouldEqual, http.StatusOK)
+		So(m.Repo, ShouldEqual, "https://github.com/backend/api")
+		So(m.Refs, ShouldResemble, []string{"abc"})
+		So(m.FinishedAt, ShouldResemble, scanned.Add(time.Minute))
+		So(m.Leaks, ShouldResemble, []string{leak.Fingerprint()})
+		So(receipt.VerifyManifest(m, publicKey), ShouldBeNil)
+	})
+	Convey("repo must be scanned and allowed by token", t, func() {
+		code, _ := get("a", "repo=frontend/app")
+		So(code, ShouldEqual, http.StatusNotFound)
+		code, _ = get("f", "repo=backend/api")
+		So(code, ShouldEqual, http.StatusNotFound)
+		code, _ = get("a", "")
+		So(code, ShouldEqual, http.StatusBadRequest)
+	})
+	Convey("manifests are not available without receipt key", t, func() {
+		s.Signer = nil
+		code, _ := get("a", "repo=backend/api")
+		So(code, ShouldEqual, http.StatusNotFound)
+	})
+}
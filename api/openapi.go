@@ -0,0 +1,583 @@
+package api
+
+import (
+	"net/http"
+)
+
+// openAPISpec - OpenAPI 3 description of the API, must be updated with routes
+const openAPISpec = `{
+  "openapi": "3.0.0",
+  "info": {
+    "title": "HungryFox API",
+    "version": "1.0.0"
+  },
+  "paths": {
+    "/api/leaks": {
+      "get": {
+        "summary": "Query found leaks",
+        "description": "Only leaks of repos allowed for token are returned",
+        "security": [{"token": []}],
+        "parameters": [
+          {"name": "repo", "in": "query", "schema": {"type": "string"}, "description": "repo url or path, comma separated"},
+          {"name": "rule", "in": "query", "schema": {"type": "string"}, "description": "pattern name, comma separated"},
+          {"name": "severity", "in": "query", "schema": {"type": "string"}},
+          {"name": "status", "in": "query", "schema": {"type": "string"}},
+          {"name": "author", "in": "query", "schema": {"type": "string"}, "description": "commit author name, email or committer email"},
+          {"name": "commit", "in": "query", "schema": {"type": "string"}},
+          {"name": "file", "in": "query", "schema": {"type": "string"}},
+          {"name": "fingerprint", "in": "query", "schema": {"type": "string"}, "description": "stable id of leak, comma separated"},
+          {"name": "source", "in": "query", "schema": {"type": "string"}, "description": "edge instance, comma separated"},
+          {"name": "language", "in": "query", "schema": {"type": "string"}, "description": "language of file, comma separated"},
+          {"name": "kind", "in": "query", "schema": {"type": "string"}, "description": "bulk_dump for commits with anomalous number of leaks"},
+          {"name": "verified", "in": "query", "schema": {"type": "string", "enum": ["verified", "unverified"]}},
+          {"name": "q", "in": "query", "schema": {"type": "string"}, "description": "full text search over metadata and context of leak, words and quoted phrases, secrets are not searchable"},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "until", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "as_of", "in": "query", "schema": {"type": "string", "format": "date-time"}, "description": "leaks found before and their statuses at this time"},
+          {"name": "fields", "in": "query", "schema": {"type": "string"}, "description": "comma separated list of returned fields"},
+          {"name": "order", "in": "query", "schema": {"type": "string", "enum": ["asc", "desc"], "default": "desc"}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 1000, "default": 100}},
+          {"name": "cursor", "in": "query", "schema": {"type": "string"}, "description": "next_cursor from previous page"}
+        ],
+        "responses": {
+          "200": {
+            "description": "Page of leaks",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LeaksPage"}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/leaks/status": {
+      "get": {
+        "summary": "Status history of leak",
+        "security": [{"token": []}],
+        "parameters": [
+          {"name": "fingerprint", "in": "query", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Current status and history",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LeakStatus"}}}
+          },
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Change status of leak",
+        "description": "Token needs triage scope, token name is saved as actor",
+        "security": [{"token": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "properties": {
+              "fingerprint": {"type": "string"},
+              "status": {"type": "string", "enum": ["open", "acknowledged", "resolved", "rotated", "ignored", "false_positive"]},
+              "comment": {"type": "string"}
+            }
+          }}}
+        },
+        "responses": {
+          "200": {
+            "description": "Current status and history",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LeakStatus"}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/leaks/status/undo": {
+      "post": {
+        "summary": "Undo status changes",
+        "description": "Appends undo events for status changes selected by id or by actor since time, token needs triage scope",
+        "security": [{"token": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "properties": {
+              "id": {"type": "string", "description": "id of event from status history"},
+              "actor": {"type": "string", "description": "undo all changes of actor"},
+              "since": {"type": "string", "format": "date-time"},
+              "comment": {"type": "string"},
+              "dry_run": {"type": "boolean", "description": "only return events which would be undone"}
+            }
+          }}}
+        },
+        "responses": {
+          "200": {
+            "description": "Undo events",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "dry_run": {"type": "boolean"},
+                "undone": {"type": "array", "items": {"$ref": "#/components/schemas/StatusEvent"}}
+              }
+            }}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/leaks/status/bulk": {
+      "post": {
+        "summary": "Change status of all matching leaks",
+        "description": "Query parameters are filters of /api/leaks, at least one filter is required, leaks which already have the status are skipped. Token needs triage scope",
+        "security": [{"token": []}],
+        "parameters": [
+          {"name": "repo", "in": "query", "schema": {"type": "string"}},
+          {"name": "rule", "in": "query", "schema": {"type": "string"}},
+          {"name": "severity", "in": "query", "schema": {"type": "string"}},
+          {"name": "status", "in": "query", "schema": {"type": "string"}},
+          {"name": "file", "in": "query", "schema": {"type": "string"}},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "until", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "q", "in": "query", "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "required": ["status", "comment"],
+            "properties": {
+              "status": {"type": "string", "enum": ["open", "acknowledged", "resolved", "rotated", "ignored", "false_positive"]},
+              "comment": {"type": "string", "description": "reason of change"},
+              "dry_run": {"type": "boolean", "description": "only count leaks which would be changed"}
+            }
+          }}}
+        },
+        "responses": {
+          "200": {
+            "description": "Changed leaks",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "dry_run": {"type": "boolean"},
+                "changed": {"type": "integer"},
+                "fingerprints": {"type": "array", "items": {"type": "string"}}
+              }
+            }}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/repos": {
+      "get": {
+        "summary": "Scan status of repos",
+        "description": "Repos allowed by token with their last scan and number of leaks",
+        "security": [{"token": []}],
+        "parameters": [
+          {"name": "repo", "in": "query", "schema": {"type": "string"}, "description": "repo url or path, all repos if empty"}
+        ],
+        "responses": {
+          "200": {
+            "description": "Repos sorted by url",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "items": {"type": "array", "items": {"$ref": "#/components/schemas/RepoStatus"}},
+                "total": {"type": "integer"}
+              }
+            }}}
+          },
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/repos/queue": {
+      "get": {
+        "summary": "Scan queue",
+        "description": "Repos in order of scan: never scanned first, then due repos by hours since last scan weighted by found leaks, then the rest by time of last scan. Empty if instance doesn't scan",
+        "security": [{"token": []}],
+        "responses": {
+          "200": {
+            "description": "Repos in order of scan",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "items": {"type": "array", "items": {"$ref": "#/components/schemas/QueuedRepo"}},
+                "total": {"type": "integer"}
+              }
+            }}}
+          },
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/repos/manifest": {
+      "get": {
+        "summary": "Signed manifest of the last scan of repo",
+        "description": "Scanned refs, times of the last scan and fingerprints of leaks found in repo so far, signed with receipt_key_file like hungryfox scan -manifest. 404 if receipt_key_file is not configured or repo was not scanned",
+        "security": [{"token": []}],
+        "parameters": [
+          {"name": "repo", "in": "query", "required": true, "schema": {"type": "string"}, "description": "repo url or path"}
+        ],
+        "responses": {
+          "200": {
+            "description": "Manifest, verify it with hungryfox verify-receipts -manifest",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Manifest"}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/admin/repos": {
+      "get": {
+        "summary": "Changes of scan list made by admin api",
+        "description": "Token needs admin scope. 404 if instance doesn't scan",
+        "security": [{"token": []}],
+        "responses": {
+          "200": {
+            "description": "Added, removed and paused repos",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AdminRepos"}}}
+          },
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Change scan list at runtime",
+        "description": "Token needs admin scope and the repo must be allowed for it. Changes are kept in api.admin_file",
+        "security": [{"token": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "properties": {
+              "action": {"type": "string", "enum": ["add", "remove", "pause", "resume", "scan"]},
+              "repo": {"type": "string", "description": "url of repo, https url or absolute local path to add"}
+            }
+          }}}
+        },
+        "responses": {
+          "200": {
+            "description": "Added, removed and paused repos",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AdminRepos"}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/rules/costs": {
+      "get": {
+        "summary": "Time spent by rules",
+        "description": "Time spent by families of rules on diffs of repo groups since start for cost attribution, the most expensive first. Empty if instance doesn't scan, tokens limited to repos are forbidden",
+        "security": [{"token": []}],
+        "responses": {
+          "200": {
+            "description": "Costs of rules",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "items": {"type": "array", "items": {"$ref": "#/components/schemas/RuleCost"}},
+                "seconds": {"type": "number", "description": "total time of all rules"}
+              }
+            }}}
+          },
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/version": {
+      "get": {
+        "summary": "Version of instance",
+        "description": "Build info, hash of loaded rules and the latest release from update_check.url if it is enabled",
+        "security": [{"token": []}],
+        "responses": {
+          "200": {
+            "description": "Version",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Version"}}}
+          },
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/badge": {
+      "get": {
+        "summary": "Scan coverage badge of repo",
+        "description": "Token needs badge or leaks scope",
+        "security": [{"token": []}],
+        "parameters": [
+          {"name": "repo", "in": "query", "required": true, "schema": {"type": "string"}, "description": "repo url or path"},
+          {"name": "format", "in": "query", "schema": {"type": "string", "enum": ["svg", "json", "shields"], "default": "svg"}, "description": "shields is https://shields.io/endpoint format"},
+          {"name": "token", "in": "query", "schema": {"type": "string"}, "description": "api token for embedding where headers can't be set"}
+        ],
+        "responses": {
+          "200": {
+            "description": "SVG image or badge data",
+            "content": {
+              "image/svg+xml": {},
+              "application/json": {"schema": {"$ref": "#/components/schemas/Badge"}}
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/api/ingest": {
+      "post": {
+        "summary": "Receive envelope of edge instance",
+        "description": "Only in central role, leaks already received from any source are skipped",
+        "security": [{"token": []}],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Envelope"}}}
+        },
+        "responses": {
+          "200": {
+            "description": "Envelope is accepted",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/IngestResult"}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/healthz": {
+      "get": {
+        "summary": "Liveness probe",
+        "description": "Ok while the process serves requests",
+        "responses": {"200": {"description": "Alive"}}
+      }
+    },
+    "/readyz": {
+      "get": {
+        "summary": "Readiness probe",
+        "description": "Ok when config is loaded, state store is open and senders are started. Not ready again on shutdown",
+        "responses": {
+          "200": {"description": "Ready"},
+          "503": {
+            "description": "Not ready",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "status": {"type": "string"},
+                "pending": {"type": "array", "items": {"type": "string"}}
+              }
+            }}}
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "responses": {"200": {"description": "OpenAPI document"}}
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "token": {"type": "http", "scheme": "bearer", "description": "api token with leaks or ingest scope, required if api.tokens are configured"}
+    },
+    "responses": {
+      "Error": {
+        "description": "Error",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      }
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "properties": {"error": {"type": "string"}}
+      },
+      "Leak": {
+        "type": "object",
+        "properties": {
+          "pattern_name": {"type": "string"},
+          "pattern": {"type": "string"},
+          "filepath": {"type": "string"},
+          "repo_path": {"type": "string"},
+          "leak": {"type": "string"},
+          "secret": {"type": "string", "description": "value of secret in leak, set by secret_group of pattern and entropy detectors"},
+          "repo_url": {"type": "string"},
+          "commit": {"type": "string"},
+          "ts": {"type": "string", "format": "date-time"},
+          "found_at": {"type": "string", "format": "date-time"},
+          "line": {"type": "integer"},
+          "author": {"type": "string"},
+          "email": {"type": "string"},
+          "committer_email": {"type": "string", "description": "empty if it is the same as email"},
+          "status": {"type": "string", "enum": ["open", "acknowledged", "resolved", "rotated", "ignored", "false_positive"]},
+          "severity": {"type": "string", "enum": ["critical", "high", "medium", "low"]},
+          "source": {"type": "string", "description": "edge instance which found leak"},
+          "language": {"type": "string", "description": "detected by extension or shebang"},
+          "kind": {"type": "string", "description": "empty for leaks of patterns, bulk_dump for commits with anomalous number of leaks"},
+          "verified": {"type": "string", "enum": ["verified", "unverified"], "description": "credential is live or not, empty if it was not checked"},
+          "signed": {"type": "string", "enum": ["gpg", "ssh", "x509", "unsigned"], "description": "signature of commit, empty if commit is unknown"},
+          "signing_key": {"type": "string", "description": "key id of gpg signature or sha256 fingerprint of ssh key"},
+          "context": {"type": "string", "description": "lines around leak line, empty if context_lines is disabled"},
+          "fingerprint": {"type": "string"},
+          "secret_fingerprint": {"type": "string", "description": "same for secret reappearing in file of repo"},
+          "receipt": {
+            "type": "object",
+            "description": "ed25519 signature of leak by receipt_key_file",
+            "properties": {"key_id": {"type": "string"}, "signature": {"type": "string"}}
+          }
+        }
+      },
+      "Badge": {
+        "type": "object",
+        "properties": {
+          "repo": {"type": "string"},
+          "last_scan": {"type": "string", "format": "date-time"},
+          "scan_success": {"type": "boolean"},
+          "unhealthy": {"type": "boolean", "description": "repo can't be read by go-git nor by external git"},
+          "open_leaks": {"type": "integer"}
+        }
+      },
+      "RepoStatus": {
+        "type": "object",
+        "properties": {
+          "repo": {"type": "string"},
+          "repo_path": {"type": "string"},
+          "refs": {"type": "array", "items": {"type": "string"}, "description": "scanned refs of repo"},
+          "scan_started_at": {"type": "string", "format": "date-time"},
+          "last_scan": {"type": "string", "format": "date-time"},
+          "scan_success": {"type": "boolean"},
+          "scan_error": {"type": "string"},
+          "unhealthy": {"type": "boolean"},
+          "removed_at": {"type": "string", "format": "date-time", "description": "repo is gone from inspected paths"},
+          "leaks": {"type": "integer"},
+          "open_leaks": {"type": "integer"}
+        }
+      },
+      "Manifest": {
+        "type": "object",
+        "properties": {
+          "version": {"type": "integer"},
+          "repo": {"type": "string"},
+          "refs": {"type": "array", "items": {"type": "string"}, "description": "scanned refs with their hashes"},
+          "started_at": {"type": "string", "format": "date-time"},
+          "finished_at": {"type": "string", "format": "date-time"},
+          "leaks": {"type": "array", "items": {"type": "string"}, "description": "fingerprints of found leaks"},
+          "receipt": {
+            "type": "object",
+            "description": "ed25519 signature of manifest by receipt_key_file",
+            "properties": {"key_id": {"type": "string"}, "signature": {"type": "string"}}
+          }
+        }
+      },
+      "QueuedRepo": {
+        "type": "object",
+        "properties": {
+          "repo": {"type": "string"},
+          "due": {"type": "boolean", "description": "scan_interval or schedule passed since last scan or repo was never scanned"},
+          "priority": {"type": "number", "description": "hours since last scan weighted by found leaks, 0 if repo is not due"},
+          "leaks": {"type": "integer"},
+          "last_scan": {"type": "string", "format": "date-time"},
+          "next_scan": {"type": "string", "format": "date-time", "description": "by scan_interval or schedule of repo"},
+          "paused": {"type": "boolean", "description": "paused by admin api, it is never due"},
+          "scanning": {"type": "boolean", "description": "repo is being scanned"}
+        }
+      },
+      "AdminRepos": {
+        "type": "object",
+        "properties": {
+          "added": {"type": "array", "items": {"type": "string"}, "description": "repos which are not in config"},
+          "removed": {"type": "array", "items": {"type": "string"}, "description": "repos of config which are not scanned"},
+          "paused": {"type": "array", "items": {"type": "string"}, "description": "repos which are kept but not scanned"}
+        }
+      },
+      "RuleCost": {
+        "type": "object",
+        "properties": {
+          "family": {"type": "string", "description": "family of patterns, builtin for default patterns, entropy for entropy detectors"},
+          "repo_group": {"type": "string", "description": "GitHub organization or user, GitLab group"},
+          "seconds": {"type": "number"},
+          "diffs": {"type": "integer"},
+          "matches": {"type": "integer", "description": "leaks found before filters"}
+        }
+      },
+      "Version": {
+        "type": "object",
+        "properties": {
+          "version": {"type": "string"},
+          "go_version": {"type": "string"},
+          "build_date": {"type": "string"},
+          "rules": {"type": "string", "description": "hash of loaded patterns, filters and entropy detectors, empty if instance doesn't scan"},
+          "latest": {
+            "type": "object",
+            "properties": {
+              "version": {"type": "string"},
+              "url": {"type": "string"},
+              "checked_at": {"type": "string", "format": "date-time"}
+            }
+          },
+          "outdated": {"type": "boolean", "description": "latest release is newer than version"}
+        }
+      },
+      "StatusEvent": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "fingerprint": {"type": "string"},
+          "status": {"type": "string", "enum": ["open", "acknowledged", "resolved", "rotated", "ignored", "false_positive", "undo"]},
+          "time": {"type": "string", "format": "date-time"},
+          "actor": {"type": "string"},
+          "comment": {"type": "string"},
+          "undoes": {"type": "string", "description": "id of event reverted by undo event"}
+        }
+      },
+      "LeakStatus": {
+        "type": "object",
+        "properties": {
+          "fingerprint": {"type": "string"},
+          "status": {"type": "string"},
+          "history": {"type": "array", "items": {"$ref": "#/components/schemas/StatusEvent"}}
+        }
+      },
+      "Envelope": {
+        "type": "object",
+        "properties": {
+          "version": {"type": "integer"},
+          "id": {"type": "string"},
+          "source": {"type": "string"},
+          "created_at": {"type": "string", "format": "date-time"},
+          "leaks": {"type": "array", "items": {"$ref": "#/components/schemas/Leak"}}
+        }
+      },
+      "IngestResult": {
+        "type": "object",
+        "properties": {
+          "accepted": {"type": "integer"},
+          "duplicates": {"type": "integer"}
+        }
+      },
+      "LeaksPage": {
+        "type": "object",
+        "properties": {
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/Leak"}},
+          "total": {"type": "integer"},
+          "next_cursor": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(openAPISpec))
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	Convey("spec is valid json and documents every route", t, func() {
+		spec := struct {
+			Paths map[string]interface{} `json:"paths"`
+		}{}
+		So(json.Unmarshal([]byte(openAPISpec), &spec), ShouldBeNil)
+		s := &Server{Ingest: make(chan *hungryfox.Leak)}
+		for route := range s.routes() {
+			So(spec.Paths, ShouldContainKey, route)
+		}
+	})
+}
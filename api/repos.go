@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+const (
+	reposPath = "/api/repos"
+	queuePath = "/api/repos/queue"
+)
+
+type repoStatus struct {
+	Repo          string     `json:"repo"`
+	Path          string     `json:"repo_path,omitempty"`
+	Refs          []string   `json:"refs,omitempty"`
+	ScanStartedAt *time.Time `json:"scan_started_at,omitempty"`
+	LastScan      *time.Time `json:"last_scan,omitempty"`
+	ScanSuccess   bool       `json:"scan_success"`
+	ScanError     string     `json:"scan_error,omitempty"`
+	Unhealthy     bool       `json:"unhealthy,omitempty"`
+	RemovedAt     *time.Time `json:"removed_at,omitempty"`
+	Leaks         int        `json:"leaks"`
+	OpenLeaks     int        `json:"open_leaks"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// handleRepos - scan status and number of leaks of every repo allowed by token, or of one repo by ?repo=
+func (s *Server) handleRepos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	token, err := s.Tokens.Authorize(r, tokens.ScopeLeaks)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	repos, err := s.Repos.GetRepos()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if name := r.URL.Query().Get("repo"); name != "" {
+		repo := findRepo(repos, name)
+		if repo == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("repo %s not found", name))
+			return
+		}
+		repos = []hungryfox.Repo{*repo}
+	}
+	leaks, err := s.Leaks.GetLeaks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	history, err := s.statusHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	now := s.now()
+	byURL := map[string]*repoStatus{}
+	items := []*repoStatus{}
+	for _, repo := range repos {
+		if !token.AllowRepo(repo.Location.RepoPath, repo.Location.URL) {
+			continue
+		}
+		item := &repoStatus{
+			Repo:          repo.Location.URL,
+			Path:          repo.Location.RepoPath,
+			Refs:          repo.State.Refs,
+			ScanStartedAt: optionalTime(repo.Scan.StartTime),
+			LastScan:      optionalTime(repo.Scan.EndTime),
+			ScanSuccess:   repo.Scan.Success,
+			ScanError:     repo.Scan.Error,
+			Unhealthy:     repo.Scan.Unhealthy,
+			RemovedAt:     optionalTime(repo.State.RemovedAt),
+		}
+		byURL[item.Repo] = item
+		items = append(items, item)
+	}
+	if len(items) == 0 && r.URL.Query().Get("repo") != "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("repo %s not found", r.URL.Query().Get("repo")))
+		return
+	}
+	for _, leak := range leaks {
+		item := byURL[leak.RepoURL]
+		if item == nil || leak.Purged {
+			continue
+		}
+		item.Leaks++
+		if hungryfox.IsOpen(hungryfox.StatusAsOf(history[leak.Fingerprint()], now)) {
+			item.OpenLeaks++
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Repo < items[j].Repo })
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+// handleQueue - repos allowed by token in order of scan
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	token, err := s.Tokens.Authorize(r, tokens.ScopeLeaks)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	items := []hungryfox.QueuedRepo{}
+	if s.Queue != nil {
+		for _, q := range s.Queue.ScanQueue() {
+			if token.AllowRepo(q.URL) {
+				items = append(items, q)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"total": len(items),
+	})
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRepos(t *testing.T) {
+	scanned := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	leaky := hungryfox.Leak{RepoURL: "https://github.com/backend/api", PatternName: "aws"}
+	resolved := hungryfox.Leak{RepoURL: "https://github.com/backend/api", PatternName: "slack"}
+	s := &Server{
+		Repos: fakeRepoStore{
+			{Location: hungryfox.RepoLocation{URL: "https://github.com/backend/api", RepoPath: "backend/api"}, Scan: hungryfox.ScanStatus{EndTime: scanned, Success: true}},
+			{Location: hungryfox.RepoLocation{URL: "https://github.com/frontend/app", RepoPath: "frontend/app"}, Scan: hungryfox.ScanStatus{Error: "auth failed"}},
+		},
+		Leaks: fakeLeakStore{leaky, resolved},
+		Statuses: &fakeStatusStore{events: []hungryfox.StatusEvent{
+			{Fingerprint: resolved.Fingerprint(), Status: hungryfox.StatusResolved, Time: scanned},
+		}},
+		Tokens: tokens.Tokens{
+			{Name: "all", Secret: "a", Scopes: []string{tokens.ScopeLeaks}},
+			{Name: "backend", Secret: "b", Scopes: []string{tokens.ScopeLeaks}, Repos: []string{"backend/*"}},
+		},
+	}
+	get := func(token, query string) (int, []repoStatus) {
+		r := httptest.NewRequest(http.MethodGet, reposPath+"?"+query, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.handleRepos(w, r)
+		result := struct{ Items []repoStatus }{}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result.Items
+	}
+
+	Convey("all repos with scan status and leaks", t, func() {
+		code, items := get("a", "")
+		So(code, ShouldEqual, http.StatusOK)
+		So(items, ShouldHaveLength, 2)
+		So(items[0].Leaks, ShouldEqual, 2)
+		So(items[0].OpenLeaks, ShouldEqual, 1)
+		So(items[1].ScanError, ShouldEqual, "auth failed")
+		So(items[1].LastScan, ShouldBeNil)
+	})
+	Convey("repos are limited by token", t, func() {
+		_, items := get("b", "")
+		So(items, ShouldHaveLength, 1)
+		code, _ := get("b", "repo=frontend/app")
+		So(code, ShouldEqual, http.StatusNotFound)
+	})
+}
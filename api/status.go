@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/findings"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+const (
+	statusPath     = "/api/leaks/status"
+	statusUndoPath = "/api/leaks/status/undo"
+)
+
+type statusRequest struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      string `json:"status"`
+	Comment     string `json:"comment"`
+}
+
+type undoRequest struct {
+	ID      string    `json:"id"`
+	Actor   string    `json:"actor"`
+	Since   time.Time `json:"since"`
+	Comment string    `json:"comment"`
+	DryRun  bool      `json:"dry_run"`
+}
+
+func (s *Server) statusHistory() (map[string][]hungryfox.StatusEvent, error) {
+	if s.Statuses == nil {
+		return map[string][]hungryfox.StatusEvent{}, nil
+	}
+	events, err := s.Statuses.GetEvents()
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		events[i].ID = events[i].EventID()
+	}
+	return findings.StatusHistory(events), nil
+}
+
+// findLeak - leak by fingerprint if token allows its repo
+func (s *Server) findLeak(fingerprint string, token *tokens.Token) (*hungryfox.Leak, error) {
+	leaks, err := s.Leaks.GetLeaks()
+	if err != nil {
+		return nil, err
+	}
+	for i := range leaks {
+		if !leaks[i].Purged && leaks[i].Fingerprint() == fingerprint && token.AllowRepo(leaks[i].RepoPath, leaks[i].RepoURL) {
+			return &leaks[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// handleLeakStatus - GET returns status history of leak, POST changes its status
+func (s *Server) handleLeakStatus(w http.ResponseWriter, r *http.Request) {
+	scope := tokens.ScopeLeaks
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		scope = tokens.ScopeTriage
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if s.Statuses == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("status_file is not configured"))
+		return
+	}
+	token, err := s.Tokens.Authorize(r, scope)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	req := statusRequest{Fingerprint: r.URL.Query().Get("fingerprint")}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("can't parse request: %v", err))
+			return
+		}
+		if !hungryfox.ValidStatus(req.Status) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown status '%s'", req.Status))
+			return
+		}
+	}
+	leak, err := s.findLeak(req.Fingerprint, token)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if leak == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("leak '%s' not found", req.Fingerprint))
+		return
+	}
+	if r.Method == http.MethodPost {
+		if err := s.addStatus(req.Fingerprint, req.Status, req.Comment, token); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	history, err := s.statusHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	events := history[req.Fingerprint]
+	if events == nil {
+		events = []hungryfox.StatusEvent{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"fingerprint": req.Fingerprint,
+		"status":      hungryfox.StatusAsOf(events, s.now()),
+		"history":     events,
+	})
+}
+
+// addStatus - append status change of leak made by token
+func (s *Server) addStatus(fingerprint, status, comment string, token *tokens.Token) error {
+	event := hungryfox.StatusEvent{
+		Fingerprint: fingerprint,
+		Status:      status,
+		Time:        s.now().UTC(),
+		Comment:     comment,
+	}
+	if token != nil {
+		event.Actor = token.Name
+	}
+	event.ID = event.EventID()
+	if err := s.Statuses.AddEvent(event); err != nil {
+		return err
+	}
+	s.Log.Info().Str("fingerprint", event.Fingerprint).Str("status", event.Status).Str("actor", event.Actor).Msg("leak status changed")
+	return nil
+}
+
+// handleStatusUndo - revert status changes selected by event id or by actor since time,
+// reverted events stay in history
+func (s *Server) handleStatusUndo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if s.Statuses == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("status_file is not configured"))
+		return
+	}
+	token, err := s.Tokens.Authorize(r, tokens.ScopeTriage)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	req := undoRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("can't parse request: %v", err))
+		return
+	}
+	if req.ID == "" && req.Actor == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("id or actor is required"))
+		return
+	}
+	events, err := s.Statuses.GetEvents()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	leaks, err := s.Leaks.GetLeaks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	allowed := map[string]bool{}
+	for _, leak := range leaks {
+		if token.AllowRepo(leak.RepoPath, leak.RepoURL) {
+			allowed[leak.Fingerprint()] = true
+		}
+	}
+	visible := []hungryfox.StatusEvent{}
+	for _, e := range events {
+		if allowed[e.Fingerprint] {
+			visible = append(visible, e)
+		}
+	}
+	actor := ""
+	if token != nil {
+		actor = token.Name
+	}
+	filter := findings.UndoFilter{ID: req.ID, Actor: req.Actor, Since: req.Since}
+	undo := findings.UndoEvents(visible, filter, s.now().UTC(), actor, req.Comment)
+	if req.ID != "" && len(undo) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("event '%s' not found or already undone", req.ID))
+		return
+	}
+	for i := range undo {
+		undo[i].ID = undo[i].EventID()
+	}
+	if !req.DryRun {
+		for i := range undo {
+			if err := s.Statuses.AddEvent(undo[i]); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		s.Log.Info().Str("actor", actor).Int("events", len(undo)).Msg("leak status changes undone")
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"dry_run": req.DryRun,
+		"undone":  undo,
+	})
+}
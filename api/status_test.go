@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+	"github.com/rs/zerolog"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeStatusStore struct {
+	events []hungryfox.StatusEvent
+}
+
+func (f *fakeStatusStore) GetEvents() ([]hungryfox.StatusEvent, error) {
+	return f.events, nil
+}
+
+func (f *fakeStatusStore) AddEvent(event hungryfox.StatusEvent) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+func TestLeakStatus(t *testing.T) {
+	leak := hungryfox.Leak{RepoURL: "https://github.com/a/b", PatternName: "aws", TimeStamp: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+	statuses := &fakeStatusStore{}
+	s := &Server{
+		Leaks:    fakeLeakStore{leak},
+		Statuses: statuses,
+		Tokens: tokens.Tokens{
+			{Name: "alice", Secret: "t", Scopes: []string{tokens.ScopeTriage, tokens.ScopeLeaks}},
+			{Name: "reader", Secret: "r", Scopes: []string{tokens.ScopeLeaks}},
+		},
+		Log: zerolog.Nop(),
+	}
+	post := func(token string, req statusRequest) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, statusPath, bytes.NewReader(data))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.handleLeakStatus(w, r)
+		return w
+	}
+	Convey("triage scope is required to change status", t, func() {
+		So(post("r", statusRequest{Fingerprint: leak.Fingerprint(), Status: hungryfox.StatusResolved}).Code, ShouldEqual, http.StatusUnauthorized)
+		So(post("t", statusRequest{Fingerprint: leak.Fingerprint(), Status: "done"}).Code, ShouldEqual, http.StatusBadRequest)
+		So(post("t", statusRequest{Fingerprint: "unknown", Status: hungryfox.StatusResolved}).Code, ShouldEqual, http.StatusNotFound)
+	})
+	Convey("status is saved with actor", t, func() {
+		w := post("t", statusRequest{Fingerprint: leak.Fingerprint(), Status: hungryfox.StatusResolved, Comment: "rotated"})
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(statuses.events, ShouldHaveLength, 1)
+		So(statuses.events[0].Actor, ShouldEqual, "alice")
+		So(w.Body.String(), ShouldContainSubstring, `"status":"resolved"`)
+	})
+	Convey("leaks as of past date", t, func() {
+		statuses.events = []hungryfox.StatusEvent{
+			{Fingerprint: leak.Fingerprint(), Status: hungryfox.StatusResolved, Time: time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)},
+		}
+		query := func(asOf string) leaksPage {
+			q, err := parseLeaksQuery(url.Values{"as_of": {asOf}})
+			So(err, ShouldBeNil)
+			q.history, err = s.statusHistory()
+			So(err, ShouldBeNil)
+			return q.apply(fakeLeakStore{leak})
+		}
+		So(query("2017-12-01T00:00:00Z").Total, ShouldEqual, 0)
+		So(query("2018-03-01T00:00:00Z").Items[0]["status"], ShouldEqual, hungryfox.StatusOpen)
+		So(query("2018-07-01T00:00:00Z").Items[0]["status"], ShouldEqual, hungryfox.StatusResolved)
+	})
+	Convey("status changes are undone and stay in history", t, func() {
+		statuses.events = nil
+		So(post("t", statusRequest{Fingerprint: leak.Fingerprint(), Status: hungryfox.StatusIgnored}).Code, ShouldEqual, http.StatusOK)
+		id := statuses.events[0].ID
+		So(id, ShouldNotBeEmpty)
+		undo := func(req undoRequest) *httptest.ResponseRecorder {
+			data, _ := json.Marshal(req)
+			r := httptest.NewRequest(http.MethodPost, statusUndoPath, bytes.NewReader(data))
+			r.Header.Set("Authorization", "Bearer t")
+			w := httptest.NewRecorder()
+			s.handleStatusUndo(w, r)
+			return w
+		}
+		So(undo(undoRequest{}).Code, ShouldEqual, http.StatusBadRequest)
+		So(undo(undoRequest{Actor: "alice", DryRun: true}).Body.String(), ShouldContainSubstring, `"undoes":"`+id+`"`)
+		So(statuses.events, ShouldHaveLength, 1)
+		So(undo(undoRequest{ID: id, Comment: "wrong leak"}).Code, ShouldEqual, http.StatusOK)
+		So(statuses.events, ShouldHaveLength, 2)
+		So(statuses.events[1].Status, ShouldEqual, hungryfox.StatusUndo)
+		So(hungryfox.StatusAsOf(statuses.events, time.Now()), ShouldEqual, hungryfox.StatusOpen)
+		So(undo(undoRequest{ID: id}).Code, ShouldEqual, http.StatusNotFound)
+	})
+}
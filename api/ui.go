@@ -0,0 +1,257 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/search"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+var leakPageTemplate = template.Must(template.New("leak").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{ .PatternName }} in {{ .RepoPath }} - HungryFox</title>
+  <style>
+    body { font-family: sans-serif; margin: 2em; color: #111111; }
+    th { text-align: left; padding-right: 2em; vertical-align: top; }
+    pre { background-color: #f9f9f9; padding: 1em; white-space: pre-wrap; word-break: break-all; }
+  </style>
+</head>
+<body>
+  <h1>{{ .PatternName }}</h1>
+  <pre>{{ .LeakString }}</pre>
+  {{ if .Context }}<h2>Context</h2>
+  <pre>{{ .Context }}</pre>{{ end }}
+  <table>
+    <tr><th>Repo</th><td><a href="{{ .RepoURL }}">{{ .RepoURL }}</a></td></tr>
+    <tr><th>File</th><td><a href="{{ .RepoURL }}/blob/{{ .CommitHash }}/{{ .FilePath }}">{{ .FilePath }}</a>{{ if .Line }}:{{ .Line }}{{ end }}</td></tr>
+    <tr><th>Commit</th><td>{{ .CommitHash }}</td></tr>
+    <tr><th>Author</th><td>{{ .CommitAuthor }} &lt;{{ .CommitEmail }}&gt;</td></tr>
+    <tr><th>Time</th><td>{{ .TimeStamp.Format "15:04:05 02.01.2006" }}</td></tr>
+    <tr><th>Pattern</th><td><code>{{ .Regexp }}</code></td></tr>
+    <tr><th>Fingerprint</th><td><code>{{ .Fingerprint }}</code></td></tr>
+    <tr><th>Status</th><td>{{ .Status }}</td></tr>
+  </table>
+  {{ if .History }}
+  <h2>History</h2>
+  <table>
+  {{ range .History }}
+    <tr><td>{{ .Time.Format "15:04:05 02.01.2006" }}</td><td>{{ .Status }}</td><td>{{ .Actor }}</td><td>{{ .Comment }}</td></tr>
+  {{ end }}
+  </table>
+  {{ end }}
+  {{ if .Statuses }}
+  <form method="post">
+    <input type="hidden" name="csrf" value="{{ .CSRF }}">
+    <select name="status">{{ range .Statuses }}<option value="{{ . }}"{{ if eq . $.Status }} selected{{ end }}>{{ . }}</option>{{ end }}</select>
+    <input type="text" name="comment" placeholder="comment">
+    <input type="submit" value="Change status">
+  </form>
+  {{ end }}
+</body>
+</html>
+`))
+
+// uiUnauthorized - browsers ask for basic auth credentials, password is the token
+func uiUnauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="hungryfox"`)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// leakPage - leak with its status, statuses can be changed on the page only if status_file is configured
+type leakPage struct {
+	*hungryfox.Leak
+	Status   string
+	History  []hungryfox.StatusEvent
+	Statuses []string
+	CSRF     string // token of status form
+}
+
+// handleUILeak - page of leak found by fingerprint, status of leak is changed by post of its form
+func (s *Server) handleUILeak(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	scope := tokens.ScopeLeaks
+	if r.Method == http.MethodPost {
+		scope = tokens.ScopeTriage
+	}
+	token, err := s.Tokens.Require(r, scope)
+	if err != nil {
+		uiUnauthorized(w, err)
+		return
+	}
+	fingerprint := strings.TrimPrefix(r.URL.Path, hungryfox.UILeakPath)
+	leak, err := s.findLeak(fingerprint, token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if leak == nil {
+		http.Error(w, fmt.Sprintf("leak %s not found", fingerprint), http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodPost {
+		if s.Statuses == nil {
+			http.Error(w, "status_file is not configured", http.StatusNotFound)
+			return
+		}
+		// browsers send basic auth credentials with forms of other sites too
+		if !sameOrigin(r) || !hmac.Equal([]byte(r.PostFormValue("csrf")), []byte(s.csrfToken(token))) {
+			http.Error(w, "form of leak page is required", http.StatusForbidden)
+			return
+		}
+		status := r.PostFormValue("status")
+		if !hungryfox.ValidStatus(status) {
+			http.Error(w, fmt.Sprintf("unknown status %q", status), http.StatusBadRequest)
+			return
+		}
+		if err := s.addStatus(fingerprint, status, r.PostFormValue("comment"), token); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// token of query is not kept in location, browser history and logs
+		http.Redirect(w, r, hungryfox.UILeakPath+fingerprint, http.StatusSeeOther)
+		return
+	}
+	history, err := s.statusHistory()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	events := history[fingerprint]
+	page := leakPage{
+		Leak:    leak,
+		Status:  hungryfox.StatusAsOf(events, s.now()),
+		History: hungryfox.EffectiveEvents(events, s.now()),
+	}
+	if s.Statuses != nil {
+		page.Statuses = hungryfox.Statuses
+		page.CSRF = s.csrfToken(token)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := leakPageTemplate.Execute(w, page); err != nil {
+		s.Log.Error().Str("error", err.Error()).Str("service", "api").Msg("can't render leak page")
+	}
+}
+
+// csrfToken - token of status form bound to api token of browser session, other sites can't know it
+// and it doesn't survive restart
+func (s *Server) csrfToken(token *tokens.Token) string {
+	s.csrfOnce.Do(func() {
+		s.csrfKey = make([]byte, 32)
+		rand.Read(s.csrfKey)
+	})
+	mac := hmac.New(sha256.New, s.csrfKey)
+	mac.Write([]byte(token.Current()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sameOrigin - Origin or Referer of request is the host of api, requests without both are refused
+func sameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	u, err := url.Parse(source)
+	return err == nil && source != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// uiSearchPath - page of full text search over leaks
+const uiSearchPath = "/ui/search"
+
+// uiSearchLimit - max number of leaks on search page
+const uiSearchLimit = 100
+
+var searchPageTemplate = template.Must(template.New("search").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Search - HungryFox</title>
+  <style>
+    body { font-family: sans-serif; margin: 2em; color: #111111; }
+    input[type=text] { width: 40em; }
+    td { padding-right: 2em; vertical-align: top; }
+    code { white-space: pre-wrap; word-break: break-all; }
+  </style>
+</head>
+<body>
+  <form method="get">
+    <input type="text" name="q" value="{{ .Query }}" placeholder="service-foo &quot;db password&quot;">
+    <input type="submit" value="Search">
+  </form>
+  {{ if .Query }}<p>{{ .Total }} leaks found{{ if gt .Total (len .Items) }}, first {{ len .Items }} are shown{{ end }}</p>{{ end }}
+  <table>
+  {{ range .Items }}
+    <tr>
+      <td><a href="{{ .URL }}">{{ .Leak.PatternName }}</a></td>
+      <td>{{ .Leak.RepoURL }}<br>{{ .Leak.FilePath }}{{ if .Leak.Line }}:{{ .Leak.Line }}{{ end }}</td>
+      <td><code>{{ .Context }}</code></td>
+    </tr>
+  {{ end }}
+  </table>
+</body>
+</html>
+`))
+
+type searchPageItem struct {
+	Leak    hungryfox.Leak
+	URL     string
+	Context string // leak string with masked secret
+}
+
+type searchPage struct {
+	Query string
+	Total int
+	Items []searchPageItem
+}
+
+// handleUISearch - full text search over leaks allowed for token, secrets are masked
+func (s *Server) handleUISearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	token, err := s.Tokens.Require(r, tokens.ScopeLeaks)
+	if err != nil {
+		uiUnauthorized(w, err)
+		return
+	}
+	page := searchPage{
+		Query: r.URL.Query().Get("q"),
+		Items: []searchPageItem{},
+	}
+	q := search.Parse(page.Query)
+	if !q.Empty() {
+		leaks, err := s.Leaks.GetLeaks()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, leak := range leaks {
+			if leak.Purged || !token.AllowRepo(leak.RepoPath, leak.RepoURL) || !q.Match(leak) {
+				continue
+			}
+			page.Total++
+			if len(page.Items) >= uiSearchLimit {
+				continue
+			}
+			page.Items = append(page.Items, searchPageItem{Leak: leak, URL: hungryfox.UILeakPath + leak.Fingerprint(), Context: strings.TrimSpace(leak.Redacted())})
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := searchPageTemplate.Execute(w, page); err != nil {
+		s.Log.Error().Str("error", err.Error()).Str("service", "api").Msg("can't render search page")
+	}
+}
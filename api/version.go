@@ -0,0 +1,28 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/AlexAkulov/hungryfox/buildinfo"
+	"github.com/AlexAkulov/hungryfox/tokens"
+)
+
+const versionPath = "/api/version"
+
+// handleVersion - version of binary and rules, fleet tooling finds outdated instances with it
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if _, err := s.Tokens.Authorize(r, tokens.ScopeLeaks); err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	info := buildinfo.Info{Version: "unknown"}
+	if s.Version != nil {
+		info = s.Version()
+	}
+	writeJSON(w, http.StatusOK, info)
+}
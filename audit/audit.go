@@ -0,0 +1,84 @@
+// Package audit - events of scans, rule reloads and notifications as json lines in file or syslog, the stream is
+// separate from leaks and logs, so there is evidence that every repo was scanned and who was notified
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+
+	"github.com/rs/zerolog"
+)
+
+// output - destination of json lines
+type output interface {
+	write(line []byte) error
+	close() error
+}
+
+// Log - audit log, it is safe for concurrent use
+type Log struct {
+	Clock clock.Clock // system clock if nil
+	Log   zerolog.Logger
+
+	mutex sync.Mutex
+	out   output
+}
+
+// NewFile - log which appends events to file, the file is opened for every event so it can be rotated by logrotate
+func NewFile(path string) (*Log, error) {
+	out := &file{path: path}
+	f, err := out.open()
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &Log{out: out}, nil
+}
+
+// Record - write event, errors are logged because scan or notification is not stopped by audit
+func (l *Log) Record(event hungryfox.AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = clock.Or(l.Clock).Now().UTC()
+	}
+	line, _ := json.Marshal(event)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := l.out.write(line); err != nil {
+		l.Log.Error().Str("service", "audit").Str("error", err.Error()).Str("action", event.Action).Msg("can't write audit event")
+	}
+}
+
+// Close - close connection to syslog
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.out.close()
+}
+
+type file struct {
+	path string
+}
+
+func (f *file) open() (*os.File, error) {
+	return os.OpenFile(f.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+}
+
+func (f *file) write(line []byte) error {
+	out, err := f.open()
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(append(line, '\n')); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (f *file) close() error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	Convey("events are appended as json lines", t, func() {
+		path := filepath.Join(dir, "audit.log")
+		l, err := NewFile(path)
+		So(err, ShouldBeNil)
+		l.Clock = clock.NewFake(now, 0)
+		l.Record(hungryfox.AuditEvent{Action: hungryfox.AuditScanStart, Result: hungryfox.AuditOK, Repo: "https://github.com/a/b"})
+		l.Record(hungryfox.AuditEvent{Time: now.Add(time.Minute), Action: hungryfox.AuditScanFinish, Result: hungryfox.AuditOK, Repo: "https://github.com/a/b", ToRefs: []string{"c1"}, Commits: 1})
+		So(l.Close(), ShouldBeNil)
+
+		data, err := ioutil.ReadFile(path)
+		So(err, ShouldBeNil)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		So(lines, ShouldHaveLength, 2)
+		So(lines[0], ShouldEqual, `{"time":"2018-07-01T00:00:00Z","action":"scan_start","result":"ok","repo":"https://github.com/a/b"}`)
+		event := hungryfox.AuditEvent{}
+		So(json.Unmarshal([]byte(lines[1]), &event), ShouldBeNil)
+		So(event.Time, ShouldEqual, now.Add(time.Minute))
+		So(event.ToRefs, ShouldResemble, []string{"c1"})
+
+		Convey("rotated file is created again", func() {
+			So(os.Rename(path, path+".1"), ShouldBeNil)
+			l.Record(hungryfox.AuditEvent{Action: hungryfox.AuditRulesReload, Result: hungryfox.AuditOK})
+			_, err := os.Stat(path)
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("file in missing dir is an error", t, func() {
+		_, err := NewFile(filepath.Join(dir, "missing", "audit.log"))
+		So(err, ShouldNotBeNil)
+	})
+}
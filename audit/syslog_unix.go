@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package audit
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+)
+
+// NewSyslog - log which sends events to syslog at address like udp://host:514, local syslog if address is empty
+func NewSyslog(address, tag string) (*Log, error) {
+	network, host := "", ""
+	if address != "" {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, err
+		}
+		network, host = u.Scheme, u.Host
+	}
+	w, err := syslog.Dial(network, host, syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to syslog: %v", err)
+	}
+	return &Log{out: &syslogOutput{w: w}}, nil
+}
+
+type syslogOutput struct {
+	w *syslog.Writer
+}
+
+// write - syslog.Writer reconnects if connection was lost
+func (s *syslogOutput) write(line []byte) error {
+	return s.w.Info(string(line))
+}
+
+func (s *syslogOutput) close() error {
+	return s.w.Close()
+}
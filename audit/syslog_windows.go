@@ -0,0 +1,8 @@
+package audit
+
+import "fmt"
+
+// NewSyslog - there is no syslog on windows
+func NewSyslog(address, tag string) (*Log, error) {
+	return nil, fmt.Errorf("syslog is not supported on windows, use file output")
+}
@@ -0,0 +1,90 @@
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// Version - version of baseline file format
+const Version = 1
+
+// Entry - known leak, fields besides fingerprint are for people reviewing the file
+type Entry struct {
+	Fingerprint string `json:"fingerprint"`
+	RepoURL     string `json:"repo_url"`
+	FilePath    string `json:"filepath"`
+	CommitHash  string `json:"commit"`
+	PatternName string `json:"pattern_name"`
+}
+
+// File - leaks which were known when baseline was created, they are not reported again
+type File struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Leaks     []Entry   `json:"leaks"`
+}
+
+// New - baseline of leaks, duplicates are removed and entries are sorted for stable diffs
+func New(leaks []hungryfox.Leak) *File {
+	seen := map[string]bool{}
+	result := &File{Version: Version, CreatedAt: time.Now().UTC(), Leaks: []Entry{}}
+	for _, leak := range leaks {
+		fingerprint := leak.Fingerprint()
+		if seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+		result.Leaks = append(result.Leaks, Entry{
+			Fingerprint: fingerprint,
+			RepoURL:     leak.RepoURL,
+			FilePath:    leak.FilePath,
+			CommitHash:  leak.CommitHash,
+			PatternName: leak.PatternName,
+		})
+	}
+	sort.Slice(result.Leaks, func(i, j int) bool {
+		return result.Leaks[i].Fingerprint < result.Leaks[j].Fingerprint
+	})
+	return result
+}
+
+// Write - write baseline as indented json
+func (f *File) Write(fileName string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append(data, '\n'), 0644)
+}
+
+// Load - fingerprints of baseline file, empty if file name is empty or file doesn't exist yet
+func Load(fileName string) (map[string]bool, error) {
+	result := map[string]bool{}
+	if fileName == "" {
+		return result, nil
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read baseline: %v", err)
+	}
+	f := File{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("can't parse baseline '%s': %v", fileName, err)
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("unsupported baseline version %d", f.Version)
+	}
+	for _, entry := range f.Leaks {
+		result[entry.Fingerprint] = true
+	}
+	return result, nil
+}
@@ -0,0 +1,43 @@
+package baseline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBaseline(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "baseline")
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "baseline.json")
+	leak := hungryfox.Leak{RepoURL: "https://github.com/a/b", PatternName: "aws", LeakString: "AKIA"}
+
+	Convey("written baseline is loaded", t, func() {
+		b := New([]hungryfox.Leak{leak, leak})
+		So(b.Leaks, ShouldHaveLength, 1)
+		So(b.Write(fileName), ShouldBeNil)
+		known, err := Load(fileName)
+		So(err, ShouldBeNil)
+		So(known, ShouldResemble, map[string]bool{leak.Fingerprint(): true})
+	})
+	Convey("empty file name is empty baseline", t, func() {
+		known, err := Load("")
+		So(err, ShouldBeNil)
+		So(known, ShouldBeEmpty)
+	})
+	Convey("missing file is empty baseline", t, func() {
+		known, err := Load(filepath.Join(dir, "missing.json"))
+		So(err, ShouldBeNil)
+		So(known, ShouldBeEmpty)
+	})
+	Convey("unknown version is error", t, func() {
+		So(ioutil.WriteFile(fileName, []byte(`{"version": 2}`), 0644), ShouldBeNil)
+		_, err := Load(fileName)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,149 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+type Client struct {
+	URL     string
+	Token   string
+	WorkDir string
+	SSH     bool // use ssh clone urls
+	// HTTPClient - client for api requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	client     *http.Client
+}
+
+type link struct {
+	Href string `json:"href"`
+	Name string `json:"name"`
+}
+
+type repository struct {
+	Slug    string `json:"slug"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Links struct {
+		Clone []link `json:"clone"`
+		Self  []link `json:"self"`
+	} `json:"links"`
+}
+
+type project struct {
+	Key string `json:"key"`
+}
+
+type page struct {
+	Values        json.RawMessage `json:"values"`
+	IsLastPage    bool            `json:"isLastPage"`
+	NextPageStart int             `json:"nextPageStart"`
+}
+
+func (c *Client) connect() {
+	if c.client == nil {
+		c.client = c.HTTPClient
+	}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+}
+
+// FetchProjects - get keys of all projects visible with token
+func (c *Client) FetchProjects() ([]string, error) {
+	c.connect()
+	var keys []string
+	err := c.fetchAll("/projects", func(values json.RawMessage) error {
+		projects := []project{}
+		if err := json.Unmarshal(values, &projects); err != nil {
+			return err
+		}
+		for _, p := range projects {
+			keys = append(keys, p.Key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// FetchProjectRepos - get all repositories of project
+func (c *Client) FetchProjectRepos(projectKey string) ([]hungryfox.RepoLocation, error) {
+	c.connect()
+	var repoList []hungryfox.RepoLocation
+	err := c.fetchAll(fmt.Sprintf("/projects/%s/repos", url.PathEscape(projectKey)), func(values json.RawMessage) error {
+		repos := []repository{}
+		if err := json.Unmarshal(values, &repos); err != nil {
+			return err
+		}
+		repoList = append(repoList, c.convertRepoList(repos)...)
+		return nil
+	})
+	return repoList, err
+}
+
+func (c *Client) fetchAll(apiPath string, add func(json.RawMessage) error) error {
+	start := 0
+	for {
+		p := page{}
+		if err := c.get(fmt.Sprintf("%s?limit=100&start=%d", apiPath, start), &p); err != nil {
+			return err
+		}
+		if err := add(p.Values); err != nil {
+			return err
+		}
+		if p.IsLastPage {
+			return nil
+		}
+		start = p.NextPageStart
+	}
+}
+
+func (c *Client) get(apiPath string, result interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(c.URL, "/")+"/rest/api/1.0"+apiPath, nil)
+	if err != nil {
+		return err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s for %s", resp.Status, apiPath)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (c *Client) convertRepoList(list []repository) (hfRepoList []hungryfox.RepoLocation) {
+	for _, repo := range list {
+		location := hungryfox.RepoLocation{
+			DataPath: c.WorkDir,
+			RepoPath: fmt.Sprintf("%s/%s", strings.ToLower(repo.Project.Key), repo.Slug),
+		}
+		for _, l := range repo.Links.Clone {
+			switch {
+			case c.SSH && l.Name == "ssh":
+				location.CloneURL = l.Href
+			case !c.SSH && (l.Name == "http" || l.Name == "https"):
+				location.CloneURL = l.Href
+			}
+		}
+		if len(repo.Links.Self) > 0 {
+			location.URL = strings.TrimSuffix(repo.Links.Self[0].Href, "/browse")
+		}
+		if location.CloneURL == "" {
+			continue
+		}
+		hfRepoList = append(hfRepoList, location)
+	}
+	return
+}
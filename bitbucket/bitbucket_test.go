@@ -0,0 +1,90 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFetchRepos(t *testing.T) {
+	repo := func(project, slug string, archived bool, clone ...link) map[string]interface{} {
+		return map[string]interface{}{
+			"slug":     slug,
+			"archived": archived,
+			"project":  map[string]string{"key": project},
+			"links": map[string][]link{
+				"clone": clone,
+				"self":  {{Href: "https://bitbucket.example.com/projects/" + project + "/repos/" + slug + "/browse"}},
+			},
+		}
+	}
+	clone := func(slug string) []link {
+		return []link{
+			{Name: "http", Href: "https://bitbucket.example.com/scm/ops/" + slug + ".git"},
+			{Name: "ssh", Href: "ssh://git@bitbucket.example.com:7999/ops/" + slug + ".git"},
+		}
+	}
+	pages := map[string]map[string]interface{}{
+		"/rest/api/1.0/projects": {
+			"0": map[string]interface{}{"values": []project{{Key: "OPS"}}, "isLastPage": false, "nextPageStart": 1},
+			"1": map[string]interface{}{"values": []project{{Key: "WEB"}}, "isLastPage": true},
+		},
+		"/rest/api/1.0/projects/OPS/repos": {
+			"0": map[string]interface{}{"values": []interface{}{repo("OPS", "deploy", false, clone("deploy")...)}, "isLastPage": false, "nextPageStart": 100},
+			"100": map[string]interface{}{"values": []interface{}{
+				repo("OPS", "legacy", true, clone("legacy")...),
+				repo("OPS", "broken", false),
+			}, "isLastPage": true},
+		},
+	}
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+"?start="+r.URL.Query().Get("start"))
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		result, ok := pages[r.URL.Path][r.URL.Query().Get("start")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	Convey("projects of all pages", t, func() {
+		requests = requests[:0]
+		c := &Client{URL: server.URL, Token: "secret"}
+		keys, err := c.FetchProjects()
+		So(err, ShouldBeNil)
+		So(keys, ShouldResemble, []string{"OPS", "WEB"})
+		So(requests, ShouldResemble, []string{"/rest/api/1.0/projects?start=0", "/rest/api/1.0/projects?start=1"})
+	})
+	Convey("repos of all pages, archived are kept and repos without clone url are skipped", t, func() {
+		c := &Client{URL: server.URL + "/", Token: "secret", WorkDir: "/var/hungryfox/bitbucket"}
+		repos, err := c.FetchProjectRepos("OPS")
+		So(err, ShouldBeNil)
+		So(repos, ShouldResemble, []hungryfox.RepoLocation{
+			{URL: "https://bitbucket.example.com/projects/OPS/repos/deploy", CloneURL: "https://bitbucket.example.com/scm/ops/deploy.git", DataPath: "/var/hungryfox/bitbucket", RepoPath: "ops/deploy"},
+			{URL: "https://bitbucket.example.com/projects/OPS/repos/legacy", CloneURL: "https://bitbucket.example.com/scm/ops/legacy.git", DataPath: "/var/hungryfox/bitbucket", RepoPath: "ops/legacy"},
+		})
+	})
+	Convey("ssh clone urls", t, func() {
+		c := &Client{URL: server.URL, Token: "secret", SSH: true}
+		repos, err := c.FetchProjectRepos("OPS")
+		So(err, ShouldBeNil)
+		So(repos[0].CloneURL, ShouldEqual, "ssh://git@bitbucket.example.com:7999/ops/deploy.git")
+	})
+	Convey("error status is returned", t, func() {
+		c := &Client{URL: server.URL, Token: "bad"}
+		_, err := c.FetchProjectRepos("OPS")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "401")
+	})
+}
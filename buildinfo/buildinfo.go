@@ -0,0 +1,154 @@
+// Package buildinfo - version of binary and rules of instance and check of newer releases
+package buildinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox/clock"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/tomb.v2"
+)
+
+// Info - build and rules of instance
+type Info struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	BuildDate string `json:"build_date"`
+	// Rules - hash of loaded patterns, filters and entropy detectors, empty if instance doesn't search
+	Rules string `json:"rules,omitempty"`
+	// Latest - result of update check, nil if check is disabled or not done yet
+	Latest *Release `json:"latest,omitempty"`
+	// Outdated - latest release is newer than version
+	Outdated bool `json:"outdated"`
+}
+
+// Release - version published on release url
+type Release struct {
+	Version   string    `json:"version"`
+	URL       string    `json:"url,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Newer - latest version is greater than current, numbers of versions are compared one by one:
+// 1.10.0-3 is newer than 1.9.2-15, unknown versions are never outdated
+func Newer(latest, current string) bool {
+	l, c := versionNumbers(latest), versionNumbers(current)
+	if len(l) == 0 || len(c) == 0 {
+		return false
+	}
+	for i := 0; i < len(l) && i < len(c); i++ {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return len(l) > len(c)
+}
+
+func versionNumbers(version string) []int {
+	fields := strings.FieldsFunc(version, func(r rune) bool { return r < '0' || r > '9' })
+	result := make([]int, 0, len(fields))
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+// Fetch - latest release from url, it is json {"version": "1.2.0", "url": "..."} or plain text version
+func Fetch(client *http.Client, url string) (Release, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return Release{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Release{}, fmt.Errorf("release url answered %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return Release{}, err
+	}
+	release := Release{}
+	if text := strings.TrimSpace(string(body)); !strings.HasPrefix(text, "{") {
+		release.Version = text
+	} else if err := json.Unmarshal(body, &release); err != nil {
+		return Release{}, fmt.Errorf("can't parse release: %v", err)
+	}
+	if len(versionNumbers(release.Version)) == 0 {
+		return Release{}, fmt.Errorf("bad version '%s' of release", release.Version)
+	}
+	return release, nil
+}
+
+// Checker - check release url periodically and warn if newer version is released
+type Checker struct {
+	URL      string
+	Interval time.Duration
+	Current  string
+	Client   *http.Client
+	Clock    clock.Clock // system clock if nil
+	Log      zerolog.Logger
+
+	mutex  sync.RWMutex
+	latest *Release
+	tomb   tomb.Tomb
+}
+
+// Check - fetch latest release once
+func (c *Checker) Check() (Release, error) {
+	release, err := Fetch(c.Client, c.URL)
+	if err != nil {
+		return release, err
+	}
+	release.CheckedAt = clock.Or(c.Clock).Now()
+	c.mutex.Lock()
+	c.latest = &release
+	c.mutex.Unlock()
+	if Newer(release.Version, c.Current) {
+		c.Log.Warn().Str("service", "update check").Str("version", c.Current).Str("latest", release.Version).Msg("newer version is released")
+	}
+	return release, nil
+}
+
+// Latest - result of the last successful check, nil if there was none
+func (c *Checker) Latest() *Release {
+	if c == nil {
+		return nil
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.latest
+}
+
+func (c *Checker) Start() error {
+	c.tomb.Go(func() error {
+		for {
+			if _, err := c.Check(); err != nil {
+				c.Log.Warn().Str("service", "update check").Str("error", err.Error()).Msg("can't check latest version")
+			}
+			select {
+			case <-c.tomb.Dying():
+				return nil
+			case <-time.After(c.Interval):
+			}
+		}
+	})
+	return nil
+}
+
+func (c *Checker) Stop() error {
+	c.tomb.Kill(nil)
+	return c.tomb.Wait()
+}
@@ -0,0 +1,78 @@
+package buildinfo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox/clock"
+
+	"github.com/rs/zerolog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewer(t *testing.T) {
+	Convey("versions are compared by numbers", t, func() {
+		So(Newer("1.10.0-3", "1.9.2-15"), ShouldBeTrue)
+		So(Newer("1.2.1", "1.2.0-7"), ShouldBeTrue)
+		So(Newer("1.2.0-8", "1.2.0-7"), ShouldBeTrue)
+		So(Newer("v1.2.0", "1.2.0"), ShouldBeFalse)
+		So(Newer("1.2.0", "1.2.0-7"), ShouldBeFalse)
+		So(Newer("1.1.9", "1.2.0"), ShouldBeFalse)
+	})
+	Convey("unknown version is never outdated", t, func() {
+		So(Newer("1.2.0", "unknown"), ShouldBeFalse)
+		So(Newer("", "1.2.0"), ShouldBeFalse)
+	})
+}
+
+func TestChecker(t *testing.T) {
+	body := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	now := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	checker := &Checker{
+		URL:     server.URL,
+		Current: "1.2.0-7",
+		Client:  server.Client(),
+		Clock:   clock.NewFake(now, 0),
+		Log:     zerolog.Nop(),
+	}
+
+	Convey("nothing is known before check", t, func() {
+		So(checker.Latest(), ShouldBeNil)
+		So((*Checker)(nil).Latest(), ShouldBeNil)
+	})
+
+	Convey("json release", t, func() {
+		body = `{"version": "1.3.0-1", "url": "https://releases.example.com/hungryfox/1.3.0"}`
+		release, err := checker.Check()
+		So(err, ShouldBeNil)
+		So(release, ShouldResemble, Release{Version: "1.3.0-1", URL: "https://releases.example.com/hungryfox/1.3.0", CheckedAt: now})
+		So(checker.Latest(), ShouldResemble, &release)
+	})
+
+	Convey("plain text release", t, func() {
+		body = "1.2.0-7\n"
+		release, err := checker.Check()
+		So(err, ShouldBeNil)
+		So(release.Version, ShouldEqual, "1.2.0-7")
+	})
+
+	Convey("failed check keeps the last result", t, func() {
+		body = ""
+		_, err := checker.Check()
+		So(err, ShouldNotBeNil)
+		body = "<html>"
+		_, err = checker.Check()
+		So(err, ShouldNotBeNil)
+		So(checker.Latest().Version, ShouldEqual, "1.2.0-7")
+	})
+}
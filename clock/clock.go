@@ -0,0 +1,65 @@
+// Package clock - source of current time for scanner, router, senders and api,
+// ids of envelopes and scans are derived from it, so with Fake clock runs are reproducible
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock - current time
+type Clock interface {
+	Now() time.Time
+}
+
+// Real - system clock
+type Real struct{}
+
+// Now - current system time
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Or - c or system clock if c is nil
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real{}
+	}
+	return c
+}
+
+// Fake - deterministic clock, every call of Now moves it by Step so times and ids are unique
+type Fake struct {
+	Step time.Duration
+
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewFake - fake clock starting at start
+func NewFake(start time.Time, step time.Duration) *Fake {
+	return &Fake{now: start.UTC(), Step: step}
+}
+
+// Now - current fake time, then clock moves by Step
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	now := f.now
+	f.now = f.now.Add(f.Step)
+	return now
+}
+
+// Advance - move clock forward
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	f.now = f.now.Add(d)
+	f.mutex.Unlock()
+}
+
+// Set - move clock to time
+func (f *Fake) Set(t time.Time) {
+	f.mutex.Lock()
+	f.now = t.UTC()
+	f.mutex.Unlock()
+}
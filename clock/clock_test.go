@@ -0,0 +1,25 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	Convey("fake clock moves by step on every call", t, func() {
+		c := NewFake(start, time.Millisecond)
+		So(c.Now(), ShouldResemble, start)
+		So(c.Now(), ShouldResemble, start.Add(time.Millisecond))
+		c.Advance(time.Hour)
+		So(c.Now(), ShouldResemble, start.Add(time.Hour+2*time.Millisecond))
+		c.Set(start)
+		So(c.Now(), ShouldResemble, start)
+	})
+	Convey("system clock is used by default", t, func() {
+		So(Or(nil), ShouldHaveSameTypeAs, Real{})
+		So(Or(NewFake(start, 0)).Now(), ShouldResemble, start)
+	})
+}
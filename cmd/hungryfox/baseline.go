@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/AlexAkulov/hungryfox/baseline"
+)
+
+func baselineCommand(args []string) int {
+	flags := flag.NewFlagSet("baseline", flag.ContinueOnError)
+	leaksFile := flags.String("leaks", "", "leaks file or json output of scan, leaks_file of config by default")
+	output := flags.String("output", "", "baseline file, baseline_file of config by default")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	conf, _, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *leaksFile == "" {
+		*leaksFile = conf.Common.LeaksFile
+	}
+	if *output == "" {
+		*output = conf.Common.BaselineFile
+	}
+	if *leaksFile == "" || *output == "" {
+		fmt.Fprintln(os.Stderr, "-leaks and -output are required")
+		return 2
+	}
+	leaks, err := readSampleLeaks(*leaksFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", *leaksFile, err)
+		return 1
+	}
+	b := baseline.New(leaks)
+	if err := b.Write(*output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%d leaks written to %s\n", len(b.Leaks), *output)
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/hercules"
+)
+
+const (
+	exitLeaksFound = 1
+	exitError      = 2
+)
+
+func ciCommand(args []string) int {
+	flags := flag.NewFlagSet("ci", flag.ContinueOnError)
+	base := flags.String("base", os.Getenv("HUNGRYFOX_BASE"), "base revision, all history of head is scanned if empty")
+	head := flags.String("head", "HEAD", "head revision")
+	repoPath := flags.String("repo", ".", "path to repository")
+	format := flags.String("format", "text", "output format: text or json")
+	patternsPath := flags.String("patterns", "", "glob of patterns files, overrides patterns_path from config")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	for _, rev := range []string{*base, *head} {
+		if strings.HasPrefix(rev, "-") {
+			fmt.Fprintf(os.Stderr, "bad revision '%s'\n", rev)
+			return exitError
+		}
+	}
+	conf, logger, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if *patternsPath != "" {
+		conf.Common.PatternsPath = *patternsPath
+	}
+	revRange := *head
+	if *base != "" {
+		revRange = fmt.Sprintf("%s..%s", *base, *head)
+	}
+	fullPath, err := filepath.Abs(*repoPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	leaks, _, err := collectLeaks(conf, logger, func(diffChannel chan<- *hungryfox.Diff) error {
+		r := &repo.Repo{
+			DiffChannel: diffChannel,
+			DataPath:    fullPath,
+			URL:         fullPath,
+			WindowSize:  conf.Common.DiffWindowKB * 1024,
+			MaxDiffSize: int64(conf.Common.MaxDiffMB) * 1024 * 1024,
+			FileFilter:  conf.Files.Filter,
+		}
+		return r.ScanRevs(revRange)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't scan %s: %v\n", revRange, err)
+		return exitError
+	}
+	if err := printLeaks(os.Stdout, leaks, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if len(leaks) > 0 {
+		fmt.Fprintf(os.Stderr, "found %d leaks in %s\n", len(leaks), revRange)
+		return exitLeaksFound
+	}
+	return 0
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/searcher"
+
+	"github.com/rs/zerolog"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) int
+}
+
+var commands = map[string]command{
+	"baseline": {
+		usage: "write current leaks to baseline file, they are not reported anymore",
+		run:   baselineCommand,
+	},
+	"ci": {
+		usage: "scan commit range of current checkout and fail if leaks found",
+		run:   ciCommand,
+	},
+	"config": {
+		usage: "config check validates config with line numbers of problems, -smtp connects to smtp server",
+		run:   configCommand,
+	},
+	"import-rules": {
+		usage: "convert gitleaks toml or trufflehog detectors to patterns file",
+		run:   importRulesCommand,
+	},
+	"forward": {
+		usage: "ship spooled leaks to central instance",
+		run:   forwardCommand,
+	},
+	"report": {
+		usage: "html or csv summary of leaks by repo, rule and author, secrets are not included",
+		run:   reportCommand,
+	},
+	"rescan": {
+		usage: "forget scanned refs of repos while hungryfox is stopped, so all their history is scanned again",
+		run:   rescanCommand,
+	},
+	"route-test": {
+		usage: "show senders and recipients which would receive sample leak",
+		run:   routeTestCommand,
+	},
+	"scan": {
+		usage: "scan local repository once and print leaks",
+		run:   scanCommand,
+	},
+	"search": {
+		usage: "full text search over found leaks, secrets are masked",
+		run:   searchCommand,
+	},
+	"test-patterns": {
+		usage: "show which patterns match sample file with captured groups and time of regexps",
+		run:   testPatternsCommand,
+	},
+	"triage-bulk": {
+		usage: "change status of all leaks matching filter with reason",
+		run:   triageBulkCommand,
+	},
+	"triage-undo": {
+		usage: "revert status changes of leaks by event id or by actor, history is kept",
+		run:   triageUndoCommand,
+	},
+	"version": {
+		usage: "print version of binary and rules, -check compares it with the latest release",
+		run:   versionCommand,
+	},
+	"verify-receipts": {
+		usage: "check signatures of leaks and scan manifest",
+		run:   verifyReceiptsCommand,
+	},
+	"watch": {
+		usage: "report leaks in files of working tree as they are saved",
+		run:   watchCommand,
+	},
+	"pre-receive": {
+		usage: "scan pushed commits from git pre-receive hook and reject push with leaks",
+		run:   preReceiveCommand,
+	},
+}
+
+func runCommand(name string, args []string) int {
+	c, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n", name)
+		printCommands(os.Stderr)
+		return 2
+	}
+	return c.run(args)
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Commands:")
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-14s %s\n", name, commands[name].usage)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] [command [command flags]]\n", os.Args[0])
+	flag.PrintDefaults()
+	printCommands(os.Stderr)
+}
+
+func isFlagSet(name string) (result bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			result = true
+		}
+	})
+	return
+}
+
+// loadCommandConfig - load config and logger to stderr for one-shot commands,
+// defaults are used if config is not set and config.yml doesn't exist
+func loadCommandConfig() (*config.Config, zerolog.Logger, error) {
+	var conf *config.Config
+	var err error
+	if _, statErr := os.Stat(*configFlag); os.IsNotExist(statErr) && !isFlagSet("config") {
+		conf, err = config.ParseConfig(nil)
+	} else {
+		conf, err = config.LoadConfig(*configFlag)
+	}
+	if err != nil {
+		return nil, zerolog.Nop(), fmt.Errorf("failed to open config %s: %v", *configFlag, err)
+	}
+	logLevel := "warn"
+	if conf.Common.LogLevel == "debug" {
+		logLevel = "debug"
+	}
+	logger, err := newLogger(logLevel, os.Stderr)
+	if err != nil {
+		return nil, zerolog.Nop(), err
+	}
+	// secrets of vault are read when they are used
+	if _, err := newVaultClient(conf, logger); err != nil {
+		return nil, zerolog.Nop(), fmt.Errorf("can't configure vault: %v", err)
+	}
+	return conf, logger, nil
+}
+
+// collectLeaks - inspect all diffs from produce and return found leaks with time spent by rules
+func collectLeaks(conf *config.Config, logger zerolog.Logger, produce func(chan<- *hungryfox.Diff) error) ([]hungryfox.Leak, []hungryfox.RuleCost, error) {
+	leakSearcher := &searcher.Searcher{Log: logger}
+	if err := leakSearcher.Configure(conf); err != nil {
+		return nil, nil, err
+	}
+	diffChannel := make(chan *hungryfox.Diff, 100)
+	errChannel := make(chan error, 1)
+	go func() {
+		errChannel <- produce(diffChannel)
+		close(diffChannel)
+	}()
+	result := []hungryfox.Leak{}
+	for diff := range diffChannel {
+		leaks, _ := leakSearcher.Inspect(*diff)
+		result = append(result, leaks...)
+	}
+	err := <-errChannel
+	return result, leakSearcher.RuleCosts(), err
+}
+
+// printCosts - time spent by families of rules, the most expensive first
+func printCosts(w io.Writer, costs []hungryfox.RuleCost) {
+	families := map[string]*hungryfox.RuleCost{}
+	total := 0.0
+	for _, cost := range costs {
+		family := families[cost.Family]
+		if family == nil {
+			family = &hungryfox.RuleCost{Family: cost.Family}
+			families[cost.Family] = family
+		}
+		family.Seconds += cost.Seconds
+		family.Diffs += cost.Diffs
+		family.Matches += cost.Matches
+		total += cost.Seconds
+	}
+	result := make([]*hungryfox.RuleCost, 0, len(families))
+	for _, family := range families {
+		result = append(result, family)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Seconds != result[j].Seconds {
+			return result[i].Seconds > result[j].Seconds
+		}
+		return result[i].Family < result[j].Family
+	})
+	fmt.Fprintf(w, "%-30s %10s %6s %8s %8s\n", "family", "seconds", "share", "diffs", "matches")
+	for _, family := range result {
+		share := 0.0
+		if total > 0 {
+			share = family.Seconds / total * 100
+		}
+		fmt.Fprintf(w, "%-30s %10.3f %5.1f%% %8d %8d\n", family.Family, family.Seconds, share, family.Diffs, family.Matches)
+	}
+}
+
+func printLeaks(w io.Writer, leaks []hungryfox.Leak, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(leaks)
+	case "text":
+		// text goes to terminals and CI logs, so secrets are masked there
+		for _, leak := range leaks {
+			fmt.Fprintf(w, "%s:%d %s %s: %s\n", leak.FilePath, leak.Line, shortHash(leak.CommitHash), leak.PatternName, strings.TrimSpace(leak.Redacted()))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format '%s'", format)
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 10 {
+		return hash[:10]
+	}
+	return hash
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/searcher"
+
+	"github.com/rs/zerolog"
+)
+
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: hungryfox config check [flags] [config.yml]")
+		return 2
+	}
+	flags := flag.NewFlagSet("config check", flag.ContinueOnError)
+	smtp := flags.Bool("smtp", false, "connect to smtp server of enabled smtp")
+	timeout := flags.Duration("timeout", 5*time.Second, "timeout of smtp connection")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: hungryfox config check [flags] [config.yml]")
+		return 2
+	}
+	fileName := *configFlag
+	if flags.NArg() == 1 {
+		fileName = flags.Arg(0)
+	}
+	configYaml, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	lines := strings.Split(string(configYaml), "\n")
+	conf, problems := config.Check(configYaml)
+	if conf != nil {
+		// patterns and filters files, secret groups and severities are checked by searcher as on start,
+		// it stops on the first error and repeats errors of regexps, so it runs only for config without other problems
+		if len(problems) == 0 {
+			if err := (&searcher.Searcher{Log: zerolog.Nop()}).Configure(conf); err != nil {
+				problems = append(problems, config.Problem{Message: err.Error()})
+			}
+		}
+		if *smtp && conf.SMTP.Enable {
+			if err := config.CheckSMTP(conf, *timeout); err != nil {
+				problems = append(problems, config.Problem{Line: config.LineOf(configYaml, "smtp.host"), Option: "smtp.host", Message: err.Error()})
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[j].Line == 0 && problems[i].Line != 0 || problems[i].Line != 0 && problems[i].Line < problems[j].Line
+	})
+	for _, p := range problems {
+		if p.Line == 0 {
+			fmt.Printf("%s: %s\n", fileName, p)
+			continue
+		}
+		fmt.Printf("%s:%d: %s\n", fileName, p.Line, p)
+		if p.Line <= len(lines) {
+			fmt.Printf("%6d | %s\n", p.Line, lines[p.Line-1])
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "found %d problems in %s\n", len(problems), fileName)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s is ok\n", fileName)
+	return 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox/proxy"
+	"github.com/AlexAkulov/hungryfox/senders/spool"
+)
+
+func forwardCommand(args []string) int {
+	flags := flag.NewFlagSet("forward", flag.ContinueOnError)
+	url := flags.String("url", "", "central instance, forward.url of config by default")
+	dir := flags.String("dir", "", "spool dir, spool.dir of config by default")
+	watch := flags.Bool("watch", false, "keep running and forward every forward.interval")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	conf, logger, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *url == "" {
+		*url = conf.Forward.URL
+	}
+	if *dir == "" {
+		*dir = conf.Spool.Dir
+	}
+	if *url == "" || *dir == "" {
+		fmt.Fprintln(os.Stderr, "-url and -dir are required")
+		return 2
+	}
+	token := ""
+	if conf.Forward.IsSet() {
+		if token, err = conf.Forward.GetToken(); err != nil {
+			fmt.Fprintf(os.Stderr, "can't get forward token: %v\n", err)
+			return 1
+		}
+	}
+	p, err := proxy.New(conf.Proxy.URL, conf.Proxy.Hosts, conf.Proxy.NoProxy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	client := p.Client()
+	client.Timeout = time.Minute
+	forwarder := &spool.Forwarder{
+		Dir:    *dir,
+		URL:    *url,
+		Token:  token,
+		Client: client,
+		Log:    logger,
+	}
+	if conf.Forward.TokenVault != "" {
+		forwarder.TokenSource = conf.Forward.GetToken
+	}
+	if !*watch {
+		sent, err := forwarder.Forward()
+		fmt.Fprintf(os.Stderr, "forwarded %d envelopes\n", sent)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGINT, syscall.SIGTERM)
+	for {
+		sent, err := forwarder.Forward()
+		if err != nil {
+			logger.Warn().Str("error", err.Error()).Int("sent", sent).Msg("central instance is unavailable, retry later")
+		} else if sent > 0 {
+			logger.Info().Int("sent", sent).Msg("forwarded")
+		}
+		select {
+		case <-signalChannel:
+			return 0
+		case <-time.After(conf.Forward.Interval):
+		}
+	}
+}
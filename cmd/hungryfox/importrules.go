@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/AlexAkulov/hungryfox/ruleimport"
+
+	"gopkg.in/yaml.v2"
+)
+
+func importRulesCommand(args []string) int {
+	flags := flag.NewFlagSet("import-rules", flag.ContinueOnError)
+	format := flags.String("format", "", "gitleaks or trufflehog, detected by file if empty")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: hungryfox import-rules [-format gitleaks|trufflehog] rules.toml > patterns.yml")
+		return exitError
+	}
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if *format == "" {
+		if *format = ruleimport.Detect(flags.Arg(0), data); *format == "" {
+			fmt.Fprintf(os.Stderr, "%s: unknown format, set -format\n", flags.Arg(0))
+			return exitError
+		}
+	}
+	patterns, warnings, err := ruleimport.Convert(*format, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flags.Arg(0), err)
+		return exitError
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	out, err := yaml.Marshal(patterns)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	os.Stdout.Write(out)
+	fmt.Fprintf(os.Stderr, "%d patterns imported\n", len(patterns))
+	return 0
+}
@@ -3,34 +3,169 @@ package main
 import (
 	"flag"
 	"fmt"
-	"net/http"
-	_ "net/http/pprof"
+	"io"
 	"os"
-	"os/signal"
 	"runtime"
 	"syscall"
 	"time"
 
 	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/api"
+	"github.com/AlexAkulov/hungryfox/audit"
+	"github.com/AlexAkulov/hungryfox/buildinfo"
+	"github.com/AlexAkulov/hungryfox/clock"
 	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/debug"
+	"github.com/AlexAkulov/hungryfox/findings"
+	"github.com/AlexAkulov/hungryfox/hashonly"
+	"github.com/AlexAkulov/hungryfox/health"
 	"github.com/AlexAkulov/hungryfox/helpers"
+	"github.com/AlexAkulov/hungryfox/queue"
+	"github.com/AlexAkulov/hungryfox/receipt"
 	"github.com/AlexAkulov/hungryfox/router"
 	"github.com/AlexAkulov/hungryfox/scanmanager"
 	"github.com/AlexAkulov/hungryfox/searcher"
+	"github.com/AlexAkulov/hungryfox/state/dbstate"
 	"github.com/AlexAkulov/hungryfox/state/filestate"
+	"github.com/AlexAkulov/hungryfox/tokens"
+	"github.com/AlexAkulov/hungryfox/webhook"
 
 	"github.com/rs/zerolog"
 )
 
 var (
 	version         = "unknown"
+	goVersion       = "" // runtime version if not set by ldflags
+	buildDate       = "unknown"
 	skipScan        = flag.Bool("skip-scan", false, "Update state for all repo")
 	configFlag      = flag.String("config", "config.yml", "config file location")
-	pprofFlag       = flag.Bool("pprof", false, "Enable listen pprof on :6060")
+	pprofFlag       = flag.Bool("pprof", false, "Enable debug listener with pprof on :6060 if debug.listen is not set")
 	printConfigFlag = flag.Bool("default-config", false, "Print default config to stdout and exit")
 )
 
+func newLogger(logLevel string, out io.Writer) (zerolog.Logger, error) {
+	var lvl zerolog.Level
+	switch logLevel {
+	case "debug":
+		lvl = zerolog.DebugLevel
+	case "info":
+		lvl = zerolog.InfoLevel
+	case "warn":
+		lvl = zerolog.WarnLevel
+	case "error":
+		lvl = zerolog.ErrorLevel
+	default:
+		return zerolog.Nop(), fmt.Errorf("Unknown log_level '%s'", logLevel)
+	}
+	// logger := zerolog.New(os.Stdout).Level(lvl).With().Timestamp().Logger()
+	return zerolog.New(out).Level(lvl).With().Timestamp().Logger().Output(zerolog.ConsoleWriter{Out: out}), nil
+}
+
+// stateService - state manager with its lifecycle
+type stateService interface {
+	hungryfox.IStateManager
+	Start() error
+	Stop() error
+}
+
+// startedState - already started database is not started again
+type startedState struct {
+	*dbstate.StateManager
+}
+
+func (startedState) Start() error { return nil }
+
+func newStateManager(conf *config.Config, stateDB *dbstate.StateManager) stateService {
+	if stateDB != nil {
+		return startedState{stateDB}
+	}
+	return &filestate.StateManager{Location: conf.Common.StateFile}
+}
+
+// startDebug - start debug listener if it is enabled, nil if it is not
+func startDebug(conf *config.Config, logger zerolog.Logger, queues map[string]debug.Queue, scan func() *hungryfox.Repo, costs hungryfox.IRuleCosts) *debug.Server {
+	listen := conf.Debug.Listen
+	if listen == "" && *pprofFlag {
+		listen = ":6060"
+	}
+	if listen == "" {
+		return nil
+	}
+	debugServer := &debug.Server{Listen: listen, Queues: queues, Scan: scan, Costs: costs, Log: logger}
+	if err := debugServer.Start(); err != nil {
+		logger.Error().Str("service", "debug").Str("error", err.Error()).Msg("fail")
+		return nil
+	}
+	logger.Warn().Str("service", "debug").Str("listen", listen).Msg("pprof and runtime stats are served without auth")
+	return debugServer
+}
+
+// newAuditLog - audit log of config, nil if it is disabled
+func newAuditLog(conf *config.Config, clk clock.Clock, logger zerolog.Logger) (*audit.Log, error) {
+	if !conf.Audit.Enable {
+		return nil, nil
+	}
+	var auditLog *audit.Log
+	var err error
+	if conf.Audit.Output == "syslog" {
+		auditLog, err = audit.NewSyslog(conf.Audit.SyslogAddress, conf.Audit.Tag)
+	} else {
+		auditLog, err = audit.NewFile(conf.Audit.File)
+	}
+	if err != nil {
+		return nil, err
+	}
+	auditLog.Clock = clk
+	auditLog.Log = logger
+	return auditLog, nil
+}
+
+func queueOptions(conf *config.Config, logger zerolog.Logger, capacity int, hasher *hashonly.Hasher) queue.Options {
+	return queue.Options{
+		Capacity: capacity,
+		Policy:   conf.Queues.Policy,
+		SpillDir: conf.Queues.SpillDir,
+		Hasher:   hasher,
+		Log:      logger,
+	}
+}
+
+// stopQueues - stop queues after their producers, consumers take the rest of items
+func stopQueues(logger zerolog.Logger, queues ...interface{ Stop() error }) {
+	for _, q := range queues {
+		if err := q.Stop(); err != nil {
+			logger.Error().Str("error", err.Error()).Str("service", "queue").Msg("can't stop")
+		}
+	}
+}
+
+// shutdown - stop services in order, false if they were not stopped within timeout and queued diffs and leaks are dropped
+func shutdown(timeout time.Duration, logger zerolog.Logger, queues map[string]debug.Queue, stop func()) bool {
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+	if timeout <= 0 {
+		<-done
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		event := logger.Error().Str("timeout", helpers.PrettyDuration(timeout))
+		for name, q := range queues {
+			queueStats := q()
+			event = event.Int64(name, int64(queueStats.Length)+queueStats.Pending)
+		}
+		event.Msg("shutdown timeout, queued diffs and leaks are dropped")
+		return false
+	}
+}
+
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
 	if *printConfigFlag {
@@ -38,36 +173,110 @@ func main() {
 		os.Exit(0)
 	}
 
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Arg(0), flag.Args()[1:]))
+	}
+
 	conf, err := config.LoadConfig(*configFlag)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to open config %s: %v\n", "config.yml", err)
+		fmt.Fprintf(os.Stderr, "failed to open config %s: %v\n", *configFlag, err)
 		os.Exit(1)
 	}
 
-	var lvl zerolog.Level
-	switch conf.Common.LogLevel {
-	case "debug":
-		lvl = zerolog.DebugLevel
-	case "info":
-		lvl = zerolog.InfoLevel
-	case "warn":
-		lvl = zerolog.WarnLevel
-	case "error":
-		lvl = zerolog.ErrorLevel
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown log_level '%s'", conf.Common.LogLevel)
+	logger, err := newLogger(conf.Common.LogLevel, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	// logger := zerolog.New(os.Stdout).Level(lvl).With().Timestamp().Logger()
-	logger := zerolog.New(os.Stdout).Level(lvl).With().Timestamp().Logger().Output(zerolog.ConsoleWriter{Out: os.Stdout})
 
-	diffChannel := make(chan *hungryfox.Diff, 100)
-	leakChannel := make(chan *hungryfox.Leak, 1)
+	// settings are reloaded by paramchange of windows service as by SIGHUP
+	if err := startService(conf.Common.ShutdownTimeout, conf.Common.Role == config.RoleAll); err != nil {
+		logger.Error().Str("service", "windows service").Str("error", err.Error()).Msg("fail")
+		os.Exit(1)
+	}
 
-	if *skipScan {
-		stateManager := &filestate.StateManager{
-			Location: conf.Common.StateFile,
+	// secrets of senders and credentials are read before services which use them are started
+	vaultClient, err := newVaultClient(conf, logger)
+	if err == nil && vaultClient != nil {
+		if err = vaultClient.Prefetch(conf.VaultRefs()); err == nil {
+			err = vaultClient.Start()
+		}
+	}
+	if err != nil {
+		logger.Error().Str("service", "vault").Str("error", err.Error()).Msg("fail")
+		os.Exit(1)
+	}
+	if vaultClient != nil {
+		defer vaultClient.Stop()
+	}
+
+	var hasher *hashonly.Hasher
+	if conf.Common.HashOnly {
+		if hasher, err = hashonly.Load(conf.Common.HashKeyFile); err != nil {
+			logger.Error().Str("service", "queue").Str("error", err.Error()).Msg("fail")
+			os.Exit(1)
+		}
+	}
+	diffQueue, err := queue.NewDiffs(queueOptions(conf, logger, conf.Queues.Diffs, hasher))
+	if err != nil {
+		logger.Error().Str("service", "queue").Str("error", err.Error()).Msg("fail")
+		os.Exit(1)
+	}
+	leakQueue, err := queue.NewLeaks(queueOptions(conf, logger, conf.Queues.Leaks, hasher))
+	if err != nil {
+		logger.Error().Str("service", "queue").Str("error", err.Error()).Msg("fail")
+		os.Exit(1)
+	}
+	diffQueue.Start()
+	leakQueue.Start()
+	queues := map[string]debug.Queue{
+		"diffs": diffQueue.Stats,
+		"leaks": leakQueue.Stats,
+	}
+
+	var clk clock.Clock = clock.Real{}
+	if !conf.Common.FakeClock.IsZero() {
+		clk = clock.NewFake(conf.Common.FakeClock, time.Millisecond)
+		logger.Warn().Str("fake_clock", conf.Common.FakeClockString).Msg("deterministic clock is used")
+	}
+	auditLog, err := newAuditLog(conf, clk, logger)
+	if err != nil {
+		logger.Error().Str("service", "audit").Str("error", err.Error()).Msg("fail")
+		os.Exit(1)
+	}
+	if auditLog != nil {
+		defer auditLog.Close()
+	}
+
+	// config is loaded at this point, services are ready when they are started
+	readiness := &health.Readiness{}
+	if conf.Common.StateDB != "" || conf.Common.Role == config.RoleAll {
+		readiness.Expect("state")
+	}
+	if conf.Common.Role != config.RoleAPI {
+		readiness.Expect("router")
+	}
+	if conf.Common.Role == config.RoleAll {
+		readiness.Expect("searcher", "scanner")
+	}
+
+	var stateDB *dbstate.StateManager
+	if conf.Common.StateDB != "" {
+		// database is opened before router, it keeps fingerprints of sent leaks
+		stateDB = &dbstate.StateManager{
+			Location: conf.Common.StateDB,
+			Import:   conf.Common.StateFile,
+			Log:      logger,
 		}
+		if err := stateDB.Start(); err != nil {
+			logger.Error().Str("service", "state manager").Str("error", err.Error()).Msg("fail")
+			os.Exit(1)
+		}
+		readiness.Ready("state")
+	}
+
+	if *skipScan {
+		stateManager := newStateManager(conf, stateDB)
 		if err := stateManager.Start(); err != nil {
 			logger.Error().Str("service", "state manager").Str("error", err.Error()).Msg("fail")
 			os.Exit(1)
@@ -76,9 +285,10 @@ func main() {
 
 		logger.Debug().Str("service", "scan manager").Msg("start")
 		scanManager := &scanmanager.ScanManager{
-			DiffChannel:  diffChannel,
+			DiffChannel:  diffQueue.In,
 			Log:          logger,
 			StateManager: stateManager,
+			Clock:        clk,
 		}
 		scanManager.SetConfig(conf)
 		scanManager.DryRun()
@@ -86,19 +296,37 @@ func main() {
 		os.Exit(0)
 	}
 
-	logger.Debug().Str("service", "leaks router").Msg("start")
-	leakRouter := &router.LeaksRouter{
-		LeakChannel: leakChannel,
-		Config:      conf,
-		Log:         logger,
+	if conf.Common.Role != config.RoleAll && conf.API.Listen == "" {
+		logger.Error().Str("role", conf.Common.Role).Msg("api.listen is required")
+		os.Exit(1)
 	}
-	if err := leakRouter.Start(); err != nil {
-		logger.Error().Str("service", "leaks router").Str("error", err.Error()).Msg("fail")
+	apiTokens, err := tokens.New(conf.API.Tokens)
+	if err != nil {
+		logger.Error().Str("error", err.Error()).Msg("can't load api tokens")
 		os.Exit(1)
 	}
-	logger.Debug().Str("service", "leaks router").Msg("strated")
-
-	logger.Debug().Str("service", "leaks searcher").Msg("start")
+	var leakRouter *router.LeaksRouter
+	if conf.Common.Role != config.RoleAPI {
+		logger.Debug().Str("service", "leaks router").Msg("start")
+		leakRouter = &router.LeaksRouter{
+			LeakChannel: leakQueue.Out,
+			Config:      conf,
+			Clock:       clk,
+			Log:         logger,
+		}
+		if stateDB != nil {
+			leakRouter.Fingerprints = stateDB
+		}
+		if auditLog != nil {
+			leakRouter.Audit = auditLog
+		}
+		if err := leakRouter.Start(); err != nil {
+			logger.Error().Str("service", "leaks router").Str("error", err.Error()).Msg("fail")
+			os.Exit(1)
+		}
+		logger.Debug().Str("service", "leaks router").Msg("strated")
+		readiness.Ready("router")
+	}
 
 	numCPUs := runtime.NumCPU() - 1
 	if numCPUs < 1 {
@@ -107,96 +335,250 @@ func main() {
 	if conf.Common.Workers > 0 {
 		numCPUs = conf.Common.Workers
 	}
-	leakSearcher := &searcher.Searcher{
-		Workers:     numCPUs,
-		DiffChannel: diffChannel,
-		LeakChannel: leakChannel,
-		Log:         logger,
+	var leakSearcher *searcher.Searcher
+	var scanManager *scanmanager.ScanManager
+	if conf.Common.Role == config.RoleAll {
+		// created before api which shows version of rules and scan queue, they are started after it
+		leakSearcher = &searcher.Searcher{
+			Workers:     numCPUs,
+			DiffChannel: diffQueue.Out,
+			LeakChannel: leakQueue.In,
+			Log:         logger,
+		}
+		scanManager = &scanmanager.ScanManager{
+			DiffChannel: diffQueue.In,
+			Leaks:       &findings.FileStore{LeaksFile: conf.Common.LeaksFile},
+			Log:         logger,
+			Clock:       clk,
+		}
+		scanManager.RulesVersion = leakSearcher.RulesVersion
+		scanManager.Observer = leakRouter
+		if auditLog != nil {
+			leakSearcher.Audit = auditLog
+			scanManager.Audit = auditLog
+		}
+	}
+
+	updateChecker, err := newUpdateChecker(conf)
+	if err != nil {
+		logger.Error().Str("service", "update check").Str("error", err.Error()).Msg("fail")
+		os.Exit(1)
+	}
+	if updateChecker != nil {
+		updateChecker.Clock = clk
+		updateChecker.Log = logger
+		updateChecker.Start()
+		defer updateChecker.Stop()
+	}
+
+	var apiServer *api.Server
+	if conf.API.Listen != "" {
+		logger.Debug().Str("service", "api").Msg("start")
+		apiServer = &api.Server{
+			Listen:    conf.API.Listen,
+			Leaks:     &findings.FileStore{LeaksFile: conf.Common.LeaksFile},
+			Repos:     &filestate.Reader{Location: conf.Common.StateFile},
+			Tokens:    apiTokens,
+			UI:        conf.API.UI,
+			Readiness: readiness,
+			Clock:     clk,
+			Log:       logger,
+		}
+		if stateDB != nil {
+			apiServer.Repos = stateDB
+		}
+		if conf.Common.StatusFile != "" {
+			apiServer.Statuses = &findings.StatusLog{StatusFile: conf.Common.StatusFile}
+		}
+		if conf.Common.ReceiptKeyFile != "" {
+			if apiServer.Signer, err = receipt.LoadSigner(conf.Common.ReceiptKeyFile); err != nil {
+				logger.Error().Str("service", "api").Str("error", err.Error()).Msg("can't load receipt key")
+				os.Exit(1)
+			}
+		}
+		if conf.Common.Role == config.RoleCentral {
+			apiServer.Ingest = leakQueue.In
+		}
+		if scanManager != nil {
+			apiServer.Queue = scanManager
+			apiServer.Admin = scanManager
+			apiServer.Costs = leakSearcher
+		}
+		apiServer.Version = func() buildinfo.Info {
+			rules := ""
+			if leakSearcher != nil {
+				rules = leakSearcher.RulesVersion()
+			}
+			return buildInfo(rules, updateChecker.Latest())
+		}
+		if err := apiServer.Start(); err != nil {
+			logger.Error().Str("service", "api").Str("error", err.Error()).Msg("fail")
+			os.Exit(1)
+		}
+		logger.Debug().Str("service", "api").Str("listen", conf.API.Listen).Msg("started")
+	}
+
+	if conf.Common.Role != config.RoleAll {
+		debugServer := startDebug(conf, logger, queues, nil, nil)
+		logger.Info().Str("version", version).Str("role", conf.Common.Role).Msg("started")
+		signalChannel := make(chan os.Signal, 1)
+		notifySignals(signalChannel, syscall.SIGINT, syscall.SIGTERM)
+		s := <-signalChannel
+		logger.Info().Str("signal", s.String()).Msg("received signal")
+		readiness.Stopping()
+		drained := shutdown(conf.Common.ShutdownTimeout, logger, queues, func() {
+			if err := apiServer.Stop(); err != nil {
+				logger.Error().Str("error", err.Error()).Str("service", "api").Msg("can't stop")
+			}
+			if debugServer != nil {
+				debugServer.Stop()
+			}
+			stopQueues(logger, diffQueue, leakQueue)
+			if leakRouter != nil {
+				if err := leakRouter.Stop(); err != nil {
+					logger.Error().Str("error", err.Error()).Str("service", "leaks router").Msg("can't stop")
+				}
+			}
+		})
+		if stateDB != nil {
+			if err := stateDB.Stop(); err != nil {
+				logger.Error().Str("error", err.Error()).Str("service", "state manager").Msg("can't stop")
+			}
+		}
+		logger.Info().Str("version", version).Msg("stopped")
+		if !drained {
+			serviceStopped(1)
+			os.Exit(1)
+		}
+		serviceStopped(0)
+		return
 	}
+
+	logger.Debug().Str("service", "leaks searcher").Msg("start")
 	if err := leakSearcher.Start(conf); err != nil {
 		logger.Error().Str("service", "leaks searcher").Str("error", err.Error()).Msg("fail")
 		os.Exit(1)
 	}
 	logger.Debug().Str("service", "leaks searcher").Int("workers", numCPUs).Msg("started")
+	readiness.Ready("searcher")
 
 	logger.Debug().Str("service", "state manager").Msg("start")
-	stateManager := &filestate.StateManager{
-		Location: conf.Common.StateFile,
-	}
+	stateManager := newStateManager(conf, stateDB)
 	if err := stateManager.Start(); err != nil {
 		logger.Error().Str("service", "state manager").Str("error", err.Error()).Msg("fail")
 		os.Exit(1)
 	}
 	logger.Debug().Str("service", "state manager").Msg("started")
+	readiness.Ready("state")
 
 	logger.Debug().Str("service", "scan manager").Msg("start")
-	scanManager := &scanmanager.ScanManager{
-		DiffChannel:  diffChannel,
-		Log:          logger,
-		StateManager: stateManager,
-	}
+	scanManager.StateManager = stateManager
 	if err := scanManager.Start(conf); err != nil {
 		logger.Error().Str("service", "scan manager").Str("error", err.Error()).Msg("fail")
 		os.Exit(1)
 	}
 	logger.Debug().Str("service", "scan manager").Msg("started")
+	readiness.Ready("scanner")
+
+	var webhookServer *webhook.Server
+	if conf.Webhook.Listen != "" {
+		logger.Debug().Str("service", "webhook").Msg("start")
+		webhookServer = &webhook.Server{
+			Listen:  conf.Webhook.Listen,
+			Secret:  conf.Webhook.Secret,
+			Scanner: scanManager,
+			Tokens:  apiTokens,
+			Repos:   &filestate.Reader{Location: conf.Common.StateFile},
+			Log:     logger,
+		}
+		if stateDB != nil {
+			// state_file is only imported into state_db once, repos scanned later are in the database
+			webhookServer.Repos = stateDB
+		}
+		if err := webhookServer.Start(); err != nil {
+			logger.Error().Str("service", "webhook").Str("error", err.Error()).Msg("fail")
+			os.Exit(1)
+		}
+		logger.Debug().Str("service", "webhook").Str("listen", conf.Webhook.Listen).Msg("started")
+	}
 
 	statusTicker := time.NewTicker(time.Second * 10)
 	defer statusTicker.Stop()
 	go func() {
 		for range statusTicker.C {
-			r := scanManager.Status()
-			if r != nil {
+			for _, r := range scanManager.Scans() {
 				l := leakSearcher.Status(r.Location.URL)
 				logger.Info().Int("leaks", l.LeaksFound).Int("leaks_filtred", l.LeaksFiltred).Str("duration", helpers.PrettyDuration(time.Since(r.Scan.StartTime))).Str("repo", r.Location.URL).Msg("scan")
-				continue
 			}
 		}
 	}()
-	if *pprofFlag {
-		go func() {
-			if err := http.ListenAndServe(":6060", nil); err != nil {
-				logger.Error().Str("error", err.Error()).Msg("can't start pprof")
-			}
-		}()
-	}
+	debugServer := startDebug(conf, logger, queues, scanManager.Status, leakSearcher)
 
-	logger.Info().Str("version", version).Msg("started")
+	logger.Info().Str("version", version).Str("rules", leakSearcher.RulesVersion()).Msg("started")
 
 	signalChannel := make(chan os.Signal, 1)
-	signal.Notify(signalChannel, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	notifySignals(signalChannel, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	for {
 		s := <-signalChannel
 		logger.Info().Str("signal", s.String()).Msg("received signal")
 		if s != syscall.SIGHUP {
+			readiness.Stopping()
 			break
 		}
 
-		newConf, err := config.LoadConfig("config.yml")
+		newConf, err := config.LoadConfig(*configFlag)
 		if err != nil {
 			logger.Error().Str("error", err.Error()).Msg("can't update config")
 			continue
 		}
-		leakSearcher.Update(newConf)
+		if err := leakSearcher.Update(newConf); err != nil {
+			logger.Error().Str("error", err.Error()).Str("service", "leaks searcher").Msg("can't update patterns and filtres")
+			continue
+		}
 		scanManager.SetConfig(newConf)
 		logger.Info().Msg("settings reloaded")
 	}
 
-	if err := scanManager.Stop(); err != nil {
-		logger.Error().Str("error", err.Error()).Str("service", "scan manager").Msg("can't stop")
-	}
-	logger.Debug().Str("service", "scan manager").Msg("stopped")
+	// scan checkpoints are saved by state manager even if queues were not drained in time
+	drained := shutdown(conf.Common.ShutdownTimeout, logger, queues, func() {
+		if webhookServer != nil {
+			if err := webhookServer.Stop(); err != nil {
+				logger.Error().Str("error", err.Error()).Str("service", "webhook").Msg("can't stop")
+			}
+			logger.Debug().Str("service", "webhook").Msg("stopped")
+		}
 
-	if err := leakSearcher.Stop(); err != nil {
-		logger.Error().Str("error", err.Error()).Str("service", "leak searcher").Msg("can't stop")
-	}
-	logger.Debug().Str("service", "leak searcher").Msg("stopped")
+		if debugServer != nil {
+			debugServer.Stop()
+		}
 
-	if err := leakRouter.Stop(); err != nil {
-		logger.Error().Str("error", err.Error()).Str("service", "leaks router").Msg("can't stop")
-	}
-	logger.Debug().Str("service", "leaks router").Msg("stopped")
+		if err := scanManager.Stop(); err != nil {
+			logger.Error().Str("error", err.Error()).Str("service", "scan manager").Msg("can't stop")
+		}
+		logger.Debug().Str("service", "scan manager").Msg("stopped")
+
+		stopQueues(logger, diffQueue)
+
+		if err := leakSearcher.Stop(); err != nil {
+			logger.Error().Str("error", err.Error()).Str("service", "leak searcher").Msg("can't stop")
+		}
+		logger.Debug().Str("service", "leak searcher").Msg("stopped")
+
+		if apiServer != nil {
+			if err := apiServer.Stop(); err != nil {
+				logger.Error().Str("error", err.Error()).Str("service", "api").Msg("can't stop")
+			}
+			logger.Debug().Str("service", "api").Msg("stopped")
+		}
+
+		stopQueues(logger, leakQueue)
+
+		if err := leakRouter.Stop(); err != nil {
+			logger.Error().Str("error", err.Error()).Str("service", "leaks router").Msg("can't stop")
+		}
+		logger.Debug().Str("service", "leaks router").Msg("stopped")
+	})
 
 	logger.Debug().Str("service", "state manager").Msg("stop")
 	if err := stateManager.Stop(); err != nil {
@@ -204,4 +586,9 @@ func main() {
 	}
 
 	logger.Info().Str("version", version).Msg("stopped")
+	if !drained {
+		serviceStopped(1)
+		os.Exit(1)
+	}
+	serviceStopped(0)
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/executor"
+	"github.com/AlexAkulov/hungryfox/hercules"
+)
+
+// hookEnv - variables which git sets for hooks, objects of the push are only visible with them
+var hookEnv = []string{
+	"GIT_DIR",
+	"GIT_OBJECT_DIRECTORY",
+	"GIT_ALTERNATE_OBJECT_DIRECTORIES",
+	"GIT_QUARANTINE_PATH",
+}
+
+type refUpdate struct {
+	OldRev string
+	NewRev string
+	Ref    string
+}
+
+func isZeroRev(rev string) bool {
+	return strings.Trim(rev, "0") == ""
+}
+
+func readRefUpdates(r io.Reader) ([]refUpdate, error) {
+	updates := []refUpdate{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("bad ref update line '%s'", scanner.Text())
+		}
+		updates = append(updates, refUpdate{OldRev: fields[0], NewRev: fields[1], Ref: fields[2]})
+	}
+	return updates, scanner.Err()
+}
+
+func preReceiveCommand(args []string) int {
+	conf, logger, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	updates, err := readRefUpdates(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	revArgs := []string{}
+	for _, update := range updates {
+		if isZeroRev(update.NewRev) {
+			// ref is deleted
+			continue
+		}
+		revArgs = append(revArgs, update.NewRev)
+	}
+	if len(revArgs) == 0 {
+		return 0
+	}
+	// only commits which are not reachable from existing refs
+	revArgs = append(revArgs, "--not", "--all")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	leaks, _, err := collectLeaks(conf, logger, func(diffChannel chan<- *hungryfox.Diff) error {
+		r := &repo.Repo{
+			DiffChannel: diffChannel,
+			DataPath:    wd,
+			Executor:    &executor.Executor{Env: hookEnv},
+			WindowSize:  conf.Common.DiffWindowKB * 1024,
+			MaxDiffSize: int64(conf.Common.MaxDiffMB) * 1024 * 1024,
+			FileFilter:  conf.Files.Filter,
+		}
+		return r.ScanRevs(revArgs...)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hungryfox: can't scan pushed commits: %v\n", err)
+		return 1
+	}
+	if len(leaks) == 0 {
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "hungryfox: push rejected, found %d possible leaks of secrets:\n", len(leaks))
+	for _, leak := range leaks {
+		fmt.Fprintf(os.Stderr, "  %s %s:%d (%s)\n", shortHash(leak.CommitHash), leak.FilePath, leak.Line, leak.PatternName)
+	}
+	fmt.Fprintln(os.Stderr, "hungryfox: remove secrets from these commits and push again")
+	return 1
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/AlexAkulov/hungryfox/receipt"
+)
+
+func verifyReceiptsCommand(args []string) int {
+	flags := flag.NewFlagSet("verify-receipts", flag.ContinueOnError)
+	keyFile := flags.String("key", "", "ed25519 public or private key in PEM, receipt_key_file of config by default")
+	leaksFile := flags.String("leaks", "", "leaks file or json output of scan, leaks_file of config by default")
+	manifestFile := flags.String("manifest", "", "manifest of scan, leaks are not checked if set without -leaks")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	conf, _, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if *keyFile == "" {
+		*keyFile = conf.Common.ReceiptKeyFile
+	}
+	if *leaksFile == "" && *manifestFile == "" {
+		*leaksFile = conf.Common.LeaksFile
+	}
+	if *keyFile == "" || (*leaksFile == "" && *manifestFile == "") {
+		fmt.Fprintln(os.Stderr, "-key and -leaks or -manifest are required")
+		return exitError
+	}
+	key, err := receipt.LoadPublicKey(*keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	failed := 0
+	if *manifestFile != "" {
+		data, err := ioutil.ReadFile(*manifestFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		m := receipt.Manifest{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			fmt.Fprintf(os.Stderr, "can't parse %s: %v\n", *manifestFile, err)
+			return exitError
+		}
+		if err := receipt.VerifyManifest(m, key); err != nil {
+			fmt.Printf("manifest %s: %v\n", *manifestFile, err)
+			failed++
+		}
+	}
+	if *leaksFile != "" {
+		leaks, err := readSampleLeaks(*leaksFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "can't read %s: %v\n", *leaksFile, err)
+			return exitError
+		}
+		for _, leak := range leaks {
+			if err := receipt.VerifyLeak(leak, key); err != nil {
+				fmt.Printf("%s %s:%d %s: %v\n", leak.Fingerprint(), leak.FilePath, leak.Line, shortHash(leak.CommitHash), err)
+				failed++
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%d leaks checked\n", len(leaks))
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d receipts are not valid\n", failed)
+		return exitLeaksFound
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/api"
+	"github.com/AlexAkulov/hungryfox/findings"
+	"github.com/AlexAkulov/hungryfox/report"
+)
+
+func reportCommand(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	leaksFile := flags.String("leaks", "", "leaks file, leaks_file of config by default")
+	statusFile := flags.String("status", "", "status file, status_file of config by default, every leak is open without it")
+	format := flags.String("format", "html", "output format: html or csv")
+	output := flags.String("o", "", "output file, stdout if empty")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *format != "html" && *format != "csv" {
+		fmt.Fprintf(os.Stderr, "unknown format '%s'\n", *format)
+		return 2
+	}
+	filter := url.Values{}
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "only one filter in query string format of /api/leaks is allowed, e.g. 'repo=backend/api&as_of=2018-07-01T00:00:00Z'")
+		return 2
+	}
+	if flags.NArg() == 1 {
+		var err error
+		if filter, err = url.ParseQuery(flags.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "can't parse filter: %v\n", err)
+			return 2
+		}
+	}
+	conf, _, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *leaksFile == "" {
+		*leaksFile = conf.Common.LeaksFile
+	}
+	if *statusFile == "" {
+		*statusFile = conf.Common.StatusFile
+	}
+	if *leaksFile == "" {
+		fmt.Fprintln(os.Stderr, "-leaks is required")
+		return 2
+	}
+	leaks, err := readSampleLeaks(*leaksFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", *leaksFile, err)
+		return 1
+	}
+	history := map[string][]hungryfox.StatusEvent{}
+	if *statusFile != "" {
+		events, err := (&findings.StatusLog{StatusFile: *statusFile}).GetEvents()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "can't read %s: %v\n", *statusFile, err)
+			return 1
+		}
+		history = findings.StatusHistory(events)
+	}
+	leaks, at, err := api.MatchLeaks(leaks, history, filter, time.Now().UTC())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	result := report.Build(leaks, history, at)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "csv" {
+		err = result.WriteCSV(w)
+	} else {
+		err = result.WriteHTML(w)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
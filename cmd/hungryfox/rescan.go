@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/state/dbstate"
+)
+
+func rescanCommand(args []string) int {
+	flags := flag.NewFlagSet("rescan", flag.ContinueOnError)
+	all := flags.Bool("all", false, "forget scanned refs of all repos")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 && !*all {
+		fmt.Fprintln(os.Stderr, "url or path of repo or -all is required, use rescan action of admin api while hungryfox is running")
+		return 2
+	}
+	conf, logger, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var stateDB *dbstate.StateManager
+	if conf.Common.StateDB != "" {
+		stateDB = &dbstate.StateManager{Location: conf.Common.StateDB, Import: conf.Common.StateFile, Log: logger}
+		if err := stateDB.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "can't open state, is hungryfox running? %v\n", err)
+			return 2
+		}
+	}
+	state := newStateManager(conf, stateDB)
+	if err := state.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "can't open state: %v\n", err)
+		return 2
+	}
+	found := map[string]bool{}
+	for _, r := range state.List() {
+		arg := rescanArg(r, flags.Args())
+		if arg == "" && !*all {
+			continue
+		}
+		found[arg] = true
+		r.State.Refs = []string{}
+		r.State.Blobs = nil
+		state.Save(r)
+		fmt.Println(r.Location.URL)
+	}
+	if err := state.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "can't save state: %v\n", err)
+		return 2
+	}
+	result := 0
+	for _, arg := range flags.Args() {
+		if !found[arg] {
+			fmt.Fprintf(os.Stderr, "repo %s not found in state\n", arg)
+			result = 1
+		}
+	}
+	return result
+}
+
+// rescanArg - argument of rescan which matches url or path of repo with or without .git, empty if none
+func rescanArg(r hungryfox.Repo, args []string) string {
+	trim := func(s string) string {
+		return strings.TrimSuffix(strings.TrimSuffix(s, "/"), ".git")
+	}
+	for _, arg := range args {
+		if a := trim(arg); a == trim(r.Location.URL) || a == trim(r.Location.RepoPath) {
+			return arg
+		}
+	}
+	return ""
+}
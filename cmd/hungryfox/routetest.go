@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/router"
+	"github.com/AlexAkulov/hungryfox/searcher"
+)
+
+// readSampleLeaks - read one leak, list of leaks or json lines of leaks file
+func readSampleLeaks(fileName string) ([]hungryfox.Leak, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	leaks := []hungryfox.Leak{}
+	if bytes.HasPrefix(data, []byte("[")) {
+		err = json.Unmarshal(data, &leaks)
+		return leaks, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		leak := hungryfox.Leak{}
+		if err := decoder.Decode(&leak); err != nil {
+			return nil, err
+		}
+		leaks = append(leaks, leak)
+	}
+	return leaks, nil
+}
+
+func routeTestCommand(args []string) int {
+	flags := flag.NewFlagSet("route-test", flag.ContinueOnError)
+	leakFile := flags.String("leak", "", "json file with leak or list of leaks")
+	verify := flags.Bool("verify", false, "check credentials by apis of verify, routes use severity of sample leaks without it")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *leakFile == "" {
+		fmt.Fprintln(os.Stderr, "-leak is required")
+		return 2
+	}
+	conf, logger, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	leaks, err := readSampleLeaks(*leakFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", *leakFile, err)
+		return 1
+	}
+	leakSearcher := &searcher.Searcher{Log: logger}
+	if err := leakSearcher.Configure(conf); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	leakRouter := &router.LeaksRouter{Config: conf, Log: logger}
+	if err := leakRouter.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if conf.Verify.Enable && !*verify {
+		fmt.Fprintln(os.Stderr, "verification is skipped, use -verify to check credentials")
+	}
+	for _, leak := range leaks {
+		fmt.Printf("%s %s/%s:%d\n", leak.PatternName, leak.RepoURL, leak.FilePath, leak.Line)
+		if leakSearcher.IsFiltered(leak) {
+			fmt.Println("  filtered")
+			continue
+		}
+		explanation, err := leakRouter.Explain(leak, *verify)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if explanation.Verified != "" {
+			fmt.Printf("  %s, severity %s\n", explanation.Verified, explanation.Severity)
+		}
+		if explanation.Duplicate {
+			fmt.Println("  duplicate, skipped by dedup")
+			continue
+		}
+		for _, destination := range explanation.Destinations {
+			notes := ""
+			if destination.Stripped {
+				notes += " (secret stripped)"
+			}
+			if destination.Skipped != "" {
+				notes += fmt.Sprintf(" (skipped: %s)", destination.Skipped)
+			}
+			fmt.Printf("  -> %s%s: %s\n", destination.Sender, notes, strings.Join(destination.Recipients, ", "))
+		}
+	}
+	return 0
+}
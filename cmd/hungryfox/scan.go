@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/helpers"
+	"github.com/AlexAkulov/hungryfox/hercules"
+	"github.com/AlexAkulov/hungryfox/receipt"
+
+	"gopkg.in/yaml.v2"
+)
+
+func readRefsFile(fileName string) ([]string, error) {
+	refs := []string{}
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return refs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return refs, yaml.Unmarshal(data, &refs)
+}
+
+func writeRefsFile(fileName string, refs []string) error {
+	data, err := yaml.Marshal(refs)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// writeManifest - signed record of scanned refs and found leaks
+func writeManifest(fileName string, signer *receipt.Signer, repoPath string, refs []string, startedAt time.Time, leaks []hungryfox.Leak) error {
+	m := receipt.Manifest{
+		Version:    receipt.ManifestVersion,
+		Repo:       repoPath,
+		Refs:       refs,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now().UTC(),
+		Leaks:      []string{},
+	}
+	for _, leak := range leaks {
+		m.Leaks = append(m.Leaks, leak.Fingerprint())
+	}
+	if err := signer.SignManifest(&m); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+func scanCommand(args []string) int {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	output := flags.String("output", "", "write leaks to file instead of stdout")
+	format := flags.String("format", "text", "output format: text or json")
+	stateFile := flags.String("state", "", "file with scanned refs, only new commits are scanned if it exists")
+	historyLimit := flags.String("history", "", "don't scan commits older than duration, e.g. 1y")
+	patternsPath := flags.String("patterns", "", "glob of patterns files, overrides patterns_path from config")
+	manifestFile := flags.String("manifest", "", "write manifest of scan signed with receipt_key_file")
+	costs := flags.Bool("costs", false, "print time spent by families of rules to stderr")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: hungryfox scan [flags] /path/to/repo")
+		return exitError
+	}
+	conf, logger, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if *patternsPath != "" {
+		conf.Common.PatternsPath = *patternsPath
+	}
+	repoPath, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	var pastLimit time.Time
+	if *historyLimit != "" {
+		d, err := helpers.ParseDuration(*historyLimit)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		pastLimit = time.Now().Add(-d)
+	}
+	var signer *receipt.Signer
+	if conf.Common.ReceiptKeyFile != "" {
+		if signer, err = receipt.LoadSigner(conf.Common.ReceiptKeyFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+	if *manifestFile != "" && signer == nil {
+		fmt.Fprintln(os.Stderr, "receipt_key_file is required for -manifest")
+		return exitError
+	}
+	refs := []string{}
+	if *stateFile != "" {
+		if refs, err = readRefsFile(*stateFile); err != nil {
+			fmt.Fprintf(os.Stderr, "can't read state: %v\n", err)
+			return exitError
+		}
+	}
+
+	var newRefs []string
+	startedAt := time.Now().UTC()
+	leaks, ruleCosts, err := collectLeaks(conf, logger, func(diffChannel chan<- *hungryfox.Diff) error {
+		r := &repo.Repo{
+			DiffChannel:      diffChannel,
+			HistoryPastLimit: pastLimit,
+			MaxCommits:       conf.Common.MaxCommits,
+			DataPath:         repoPath,
+			URL:              repoPath,
+			FullScanPaths:    conf.Common.FullScanPaths,
+			FileFilter:       conf.Files.Filter,
+			WindowSize:       conf.Common.DiffWindowKB * 1024,
+			MaxDiffSize:      int64(conf.Common.MaxDiffMB) * 1024 * 1024,
+		}
+		r.SetRefs(refs)
+		if err := r.Open(); err != nil {
+			return err
+		}
+		defer r.Close()
+		if err := r.Scan(); err != nil {
+			return err
+		}
+		newRefs = r.GetRefs()
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't scan %s: %v\n", repoPath, err)
+		return exitError
+	}
+	if *costs {
+		printCosts(os.Stderr, ruleCosts)
+	}
+
+	if signer != nil {
+		for i := range leaks {
+			leaks[i].FoundAt = startedAt
+			if err := signer.SignLeak(&leaks[i]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitError
+			}
+		}
+	}
+	if *manifestFile != "" {
+		if err := writeManifest(*manifestFile, signer, repoPath, newRefs, startedAt, leaks); err != nil {
+			fmt.Fprintf(os.Stderr, "can't write manifest: %v\n", err)
+			return exitError
+		}
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		defer out.Close()
+	}
+	if err := printLeaks(out, leaks, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if *stateFile != "" {
+		if err := writeRefsFile(*stateFile, newRefs); err != nil {
+			fmt.Fprintf(os.Stderr, "can't save state: %v\n", err)
+			return exitError
+		}
+	}
+	fmt.Fprintf(os.Stderr, "found %d leaks in %s\n", len(leaks), repoPath)
+	if len(leaks) > 0 {
+		return exitLeaksFound
+	}
+	return 0
+}
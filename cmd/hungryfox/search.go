@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/search"
+)
+
+func searchCommand(args []string) int {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	leaksFile := flags.String("leaks", "", "leaks file or json output of scan, leaks_file of config by default")
+	format := flags.String("format", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	q := search.Parse(strings.Join(flags.Args(), " "))
+	if q.Empty() {
+		fmt.Fprintln(os.Stderr, "query is required")
+		return 2
+	}
+	conf, _, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *leaksFile == "" {
+		*leaksFile = conf.Common.LeaksFile
+	}
+	if *leaksFile == "" {
+		fmt.Fprintln(os.Stderr, "-leaks is required")
+		return 2
+	}
+	leaks, err := readSampleLeaks(*leaksFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", *leaksFile, err)
+		return 1
+	}
+	found := []hungryfox.Leak{}
+	for _, leak := range leaks {
+		if leak.Purged || !q.Match(leak) {
+			continue
+		}
+		leak.LeakString = leak.Redacted()
+		leak.Context = leak.RedactedContext()
+		leak.Secret = ""
+		found = append(found, leak)
+	}
+	if err := printLeaks(os.Stdout, found, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(found) == 0 {
+		return 1
+	}
+	return 0
+}
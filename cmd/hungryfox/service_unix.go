@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"time"
+)
+
+// startService - hungryfox is run by systemd or supervisor as regular process
+func startService(waitHint time.Duration, reload bool) error {
+	return nil
+}
+
+func notifySignals(c chan os.Signal, sig ...os.Signal) {
+	signal.Notify(c, sig...)
+}
+
+func serviceStopped(code int) {}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+const serviceName = "hungryfox"
+
+var (
+	// serviceSignals - stop and paramchange requests of service control manager as signals
+	serviceSignals = make(chan os.Signal, 1)
+	serviceExit    = make(chan uint32)
+	serviceDone    = make(chan struct{})
+	isService      bool
+)
+
+type serviceHandler struct {
+	waitHint time.Duration
+	reload   bool
+}
+
+// Execute - service is running until hungryfox stops, paramchange reloads settings like SIGHUP
+func (h *serviceHandler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	accepts := svc.AcceptStop | svc.AcceptShutdown
+	if h.reload {
+		accepts |= svc.AcceptParamChange
+	}
+	s <- svc.Status{State: svc.StartPending}
+	s <- svc.Status{State: svc.Running, Accepts: accepts}
+	for {
+		select {
+		case code := <-serviceExit:
+			return false, code
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending, WaitHint: uint32(h.waitHint / time.Millisecond)}
+				select {
+				case serviceSignals <- syscall.SIGTERM:
+				default:
+				}
+			case svc.ParamChange:
+				select {
+				case serviceSignals <- syscall.SIGHUP:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// startService - report to service control manager if hungryfox is started as windows service,
+// waitHint is time which stop may take
+func startService(waitHint time.Duration, reload bool) error {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return err
+	}
+	if interactive {
+		return nil
+	}
+	isService = true
+	go func() {
+		svc.Run(serviceName, &serviceHandler{waitHint: waitHint, reload: reload})
+		close(serviceDone)
+	}()
+	return nil
+}
+
+// notifySignals - signals and requests of service control manager are sent to c
+func notifySignals(c chan os.Signal, sig ...os.Signal) {
+	signal.Notify(c, sig...)
+	if !isService {
+		return
+	}
+	go func() {
+		for s := range serviceSignals {
+			c <- s
+		}
+	}()
+}
+
+// serviceStopped - report exit code to service control manager, the process may be killed after it
+func serviceStopped(code int) {
+	if !isService {
+		return
+	}
+	select {
+	case serviceExit <- uint32(code):
+	case <-serviceDone:
+		return
+	}
+	select {
+	case <-serviceDone:
+	case <-time.After(5 * time.Second):
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox/language"
+	"github.com/AlexAkulov/hungryfox/searcher"
+)
+
+func testPatternsCommand(args []string) int {
+	flags := flag.NewFlagSet("test-patterns", flag.ContinueOnError)
+	patternsFile := flags.String("patterns", "", "patterns file")
+	filePath := flags.String("file", "", "path of sample for file regexps and language, name of input by default")
+	format := flags.String("format", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *patternsFile == "" || flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: hungryfox test-patterns -patterns patterns.yml [-file path] [sample file, stdin by default]")
+		return exitError
+	}
+	var data []byte
+	var err error
+	if flags.NArg() == 1 {
+		data, err = ioutil.ReadFile(flags.Arg(0))
+		if *filePath == "" {
+			*filePath = flags.Arg(0)
+		}
+	} else {
+		data, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	content := string(data)
+	lang := language.Detect(*filePath, content)
+	results, warnings, err := searcher.CheckPatterns(*patternsFile, *filePath, content, lang)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	matched := 0
+	for _, result := range results {
+		if len(result.Matches) > 0 {
+			matched++
+		}
+	}
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+	case "text":
+		for _, result := range results {
+			if result.Skipped != "" {
+				fmt.Printf("%s: skipped by %s\n", result.Name, result.Skipped)
+				continue
+			}
+			fmt.Printf("%s: %d matches in %s\n", result.Name, len(result.Matches), result.Duration)
+			for _, m := range result.Matches {
+				fmt.Printf("  %d: %s\n", m.Line, strings.TrimSpace(m.Text))
+				groups := []string{}
+				for name := range m.Groups {
+					groups = append(groups, name)
+				}
+				sort.Strings(groups)
+				for _, name := range groups {
+					fmt.Printf("     %s = %q\n", name, m.Groups[name])
+				}
+				if m.Secret != "" {
+					fmt.Printf("     secret = %q\n", m.Secret)
+				}
+			}
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format '%s'\n", *format)
+		return exitError
+	}
+	if matched == 0 {
+		return exitLeaksFound
+	}
+	return 0
+}
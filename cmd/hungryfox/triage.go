@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox/api"
+	"github.com/AlexAkulov/hungryfox/findings"
+)
+
+func triageUndoCommand(args []string) int {
+	flags := flag.NewFlagSet("triage-undo", flag.ContinueOnError)
+	statusFile := flags.String("status", "", "status history file, status_file of config by default")
+	id := flags.String("id", "", "undo single event by its id")
+	actor := flags.String("actor", "", "undo all status changes made by actor")
+	since := flags.String("since", "", "undo only changes made since time in RFC3339")
+	comment := flags.String("comment", "", "comment saved in undo events")
+	dryRun := flags.Bool("dry-run", false, "only print changes which would be undone")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *id == "" && *actor == "" {
+		fmt.Fprintln(os.Stderr, "-id or -actor is required")
+		return 2
+	}
+	filter := findings.UndoFilter{ID: *id, Actor: *actor}
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "can't parse -since: %v\n", err)
+			return 2
+		}
+		filter.Since = t
+	}
+	conf, _, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *statusFile == "" {
+		*statusFile = conf.Common.StatusFile
+	}
+	if *statusFile == "" {
+		fmt.Fprintln(os.Stderr, "-status is required")
+		return 2
+	}
+	statuses := &findings.StatusLog{StatusFile: *statusFile}
+	events, err := statuses.GetEvents()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", *statusFile, err)
+		return 2
+	}
+	undo := findings.UndoEvents(events, filter, time.Now().UTC(), os.Getenv("USER"), *comment)
+	for _, e := range undo {
+		if !*dryRun {
+			if err := statuses.AddEvent(e); err != nil {
+				fmt.Fprintf(os.Stderr, "can't write %s: %v\n", *statusFile, err)
+				return 2
+			}
+		}
+		fmt.Printf("%s %s\n", e.Fingerprint, e.Undoes)
+	}
+	if len(undo) == 0 {
+		fmt.Fprintln(os.Stderr, "nothing to undo")
+		return 1
+	}
+	return 0
+}
+
+func triageBulkCommand(args []string) int {
+	flags := flag.NewFlagSet("triage-bulk", flag.ContinueOnError)
+	leaksFile := flags.String("leaks", "", "leaks file, leaks_file of config by default")
+	statusFile := flags.String("status", "", "status history file, status_file of config by default")
+	set := flags.String("set", "", "new status of leaks: open, acknowledged, resolved, rotated, ignored or false_positive")
+	reason := flags.String("reason", "", "reason of change saved as comment, required")
+	dryRun := flags.Bool("dry-run", false, "only print leaks which would be changed")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "filter in query string format of /api/leaks is required, e.g. 'repo=backend/api&rule=aws'")
+		return 2
+	}
+	filter, err := url.ParseQuery(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't parse filter: %v\n", err)
+		return 2
+	}
+	conf, _, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *leaksFile == "" {
+		*leaksFile = conf.Common.LeaksFile
+	}
+	if *statusFile == "" {
+		*statusFile = conf.Common.StatusFile
+	}
+	if *leaksFile == "" || *statusFile == "" {
+		fmt.Fprintln(os.Stderr, "-leaks and -status are required")
+		return 2
+	}
+	leaks, err := readSampleLeaks(*leaksFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", *leaksFile, err)
+		return 2
+	}
+	statuses := &findings.StatusLog{StatusFile: *statusFile}
+	history, err := statuses.GetEvents()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", *statusFile, err)
+		return 2
+	}
+	events, err := api.BulkStatusEvents(leaks, findings.StatusHistory(history), filter, *set, *reason, os.Getenv("USER"), time.Now().UTC())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, e := range events {
+		if !*dryRun {
+			if err := statuses.AddEvent(e); err != nil {
+				fmt.Fprintf(os.Stderr, "can't write %s: %v\n", *statusFile, err)
+				return 2
+			}
+		}
+		fmt.Println(e.Fingerprint)
+	}
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "%d leaks would be changed to %s\n", len(events), *set)
+	} else {
+		fmt.Fprintf(os.Stderr, "%d leaks changed to %s\n", len(events), *set)
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/senders/webhook"
+	"github.com/AlexAkulov/hungryfox/vault"
+
+	"github.com/rs/zerolog"
+)
+
+// newVaultClient - client of vault of config which reads token_vault and password_vault references, nil if vault is disabled
+func newVaultClient(conf *config.Config, logger zerolog.Logger) (*vault.Client, error) {
+	if conf.Vault.Address == "" {
+		return nil, nil
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if conf.Vault.IsSet() {
+		var err error
+		if token, err = conf.Vault.GetToken(); err != nil {
+			return nil, err
+		}
+	}
+	tlsConfig, err := webhook.TLSConfig("", "", conf.Vault.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	client := &vault.Client{
+		Address:   conf.Vault.Address,
+		Token:     token,
+		Namespace: conf.Vault.Namespace,
+		Refresh:   conf.Vault.Refresh,
+		HTTP: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+		Log: logger,
+	}
+	config.VaultReader = client.Get
+	return client, nil
+}
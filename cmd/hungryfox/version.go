@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox/buildinfo"
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/proxy"
+	"github.com/AlexAkulov/hungryfox/searcher"
+)
+
+// buildInfo - version of binary with version of rules and result of the last update check
+func buildInfo(rules string, latest *buildinfo.Release) buildinfo.Info {
+	info := buildinfo.Info{
+		Version:   version,
+		GoVersion: goVersion,
+		BuildDate: buildDate,
+		Rules:     rules,
+		Latest:    latest,
+	}
+	if info.GoVersion == "" {
+		info.GoVersion = runtime.Version()
+	}
+	if latest != nil {
+		info.Outdated = buildinfo.Newer(latest.Version, version)
+	}
+	return info
+}
+
+// newUpdateChecker - checker of update_check.url, nil if it is disabled
+func newUpdateChecker(conf *config.Config) (*buildinfo.Checker, error) {
+	if conf.UpdateCheck.URL == "" {
+		return nil, nil
+	}
+	p, err := proxy.New(conf.Proxy.URL, conf.Proxy.Hosts, conf.Proxy.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+	client := p.Client()
+	client.Timeout = time.Minute
+	return &buildinfo.Checker{
+		URL:      conf.UpdateCheck.URL,
+		Interval: conf.UpdateCheck.Interval,
+		Current:  version,
+		Client:   client,
+	}, nil
+}
+
+func versionCommand(args []string) int {
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	check := flags.Bool("check", false, "check update_check.url of config for newer version, exit code is 1 if it is released")
+	format := flags.String("format", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	conf, logger, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	leakSearcher := &searcher.Searcher{Log: logger}
+	if err := leakSearcher.Configure(conf); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var latest *buildinfo.Release
+	if *check {
+		checker, err := newUpdateChecker(conf)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if checker == nil {
+			fmt.Fprintln(os.Stderr, "update_check.url is not set")
+			return 2
+		}
+		checker.Log = logger
+		release, err := checker.Check()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "can't check latest version: %v\n", err)
+			return 1
+		}
+		latest = &release
+	}
+	info := buildInfo(leakSearcher.RulesVersion(), latest)
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(info)
+	case "text":
+		fmt.Printf("version:    %s\ngo version: %s\nbuild date: %s\nrules:      %s\n", info.Version, info.GoVersion, info.BuildDate, info.Rules)
+		if info.Latest != nil {
+			fmt.Printf("latest:     %s\n", info.Latest.Version)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format '%s'\n", *format)
+		return 2
+	}
+	if info.Outdated {
+		return 1
+	}
+	return 0
+}
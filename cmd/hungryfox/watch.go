@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/searcher"
+	"github.com/AlexAkulov/hungryfox/watch"
+)
+
+// watchDiff - whole file of working tree as diff, binary files are skipped
+func watchDiff(root, rel string) (*hungryfox.Diff, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+	return &hungryfox.Diff{
+		RepoURL:   root,
+		RepoPath:  root,
+		FilePath:  rel,
+		LineBegin: 1,
+		Content:   string(data),
+		TimeStamp: time.Now().UTC(),
+	}, nil
+}
+
+func watchCommand(args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := flags.Duration("interval", 500*time.Millisecond, "how often working tree is checked for changes")
+	debounce := flags.Duration("debounce", time.Second, "files are inspected when nothing has changed for this time")
+	exclude := flags.String("exclude", "node_modules,vendor", "comma separated globs of skipped files and directories")
+	maxSize := flags.Int64("max-size", 1024*1024, "bigger files are skipped, bytes")
+	initial := flags.Bool("initial", true, "report leaks in existing files on start")
+	patternsPath := flags.String("patterns", "", "glob of patterns files, overrides patterns_path from config")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: hungryfox watch [flags] [/path/to/dir]")
+		return exitError
+	}
+	conf, logger, err := loadCommandConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if *patternsPath != "" {
+		conf.Common.PatternsPath = *patternsPath
+	}
+	root, err := filepath.Abs(".")
+	if flags.NArg() == 1 {
+		root, err = filepath.Abs(flags.Arg(0))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	leakSearcher := &searcher.Searcher{Log: logger}
+	if err := leakSearcher.Configure(conf); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	watcher := &watch.Watcher{Root: root, MaxSize: *maxSize}
+	for _, pattern := range strings.Split(*exclude, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			watcher.Exclude = append(watcher.Exclude, pattern)
+		}
+	}
+
+	// reported - fingerprints of leaks by file, a leak is printed once until it is removed from file
+	reported := map[string]map[string]bool{}
+	inspect := func(changed, removed []string) {
+		for _, rel := range removed {
+			delete(reported, rel)
+		}
+		for _, rel := range changed {
+			diff, err := watchDiff(root, rel)
+			if err != nil {
+				// file was removed or replaced after poll
+				logger.Debug().Str("file", rel).Str("error", err.Error()).Msg("can't read file")
+				continue
+			}
+			if diff == nil || conf.Files.Filter.Skip(rel, diff.Content) {
+				continue
+			}
+			leaks, _ := leakSearcher.Inspect(*diff)
+			found := map[string]bool{}
+			for _, leak := range leaks {
+				fingerprint := leak.Fingerprint()
+				found[fingerprint] = true
+				if !reported[rel][fingerprint] {
+					fmt.Fprintf(os.Stdout, "%s:%d %s: %s\n", leak.FilePath, leak.Line, leak.PatternName, strings.TrimSpace(leak.LeakString))
+				}
+			}
+			if len(found) == 0 && len(reported[rel]) > 0 {
+				fmt.Fprintf(os.Stdout, "%s: no leaks anymore\n", rel)
+			}
+			reported[rel] = found
+		}
+	}
+
+	changed, _ := watcher.Poll()
+	if *initial {
+		inspect(changed, nil)
+	}
+	fmt.Fprintf(os.Stderr, "watching %s, press Ctrl+C to stop\n", root)
+
+	stop := make(chan struct{})
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signalChannel
+		close(stop)
+	}()
+	watcher.Run(*interval, *debounce, stop, inspect)
+	return 0
+}
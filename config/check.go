@@ -0,0 +1,416 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Problem - misconfiguration found by Check, Line is zero if it is unknown
+type Problem struct {
+	Line    int
+	Option  string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Option == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("%s: %s", p.Option, p.Message)
+}
+
+var (
+	yamlLineRe  = regexp.MustCompile(`line (\d+): `)
+	optionRe    = regexp.MustCompile(`[a-z_]+(\.[a-z_]+)*`)
+	inspectURLs = map[string]bool{"path": true, "github": true, "gitlab": true, "bitbucket": true, "gitea": true, "forgejo": true}
+)
+
+// Check - parse config and validate every section without connecting anywhere: unknown options,
+// regexps of patterns and filters, files and directories, urls of repos and endpoints.
+// Config is nil if it can't be parsed at all
+func Check(configYaml []byte) (*Config, []Problem) {
+	c := &checker{lines: strings.Split(string(configYaml), "\n")}
+
+	// unknown options are silently ignored by ParseConfig, typos are found by strict unmarshal
+	// errors of environment variables are reported by ParseConfig
+	strict := defaultConfig()
+	if expanded, err := expandEnv(configYaml); err == nil {
+		if err := yaml.UnmarshalStrict(expanded, &strict); err != nil {
+			if typeErr, ok := err.(*yaml.TypeError); ok {
+				for _, message := range typeErr.Errors {
+					c.addYamlError(message)
+				}
+			}
+		}
+	}
+	conf, err := ParseConfig(configYaml)
+	if err != nil {
+		c.addParseError(err)
+		return nil, c.problems
+	}
+
+	c.checkRegexps("patterns", conf.Patterns)
+	c.checkRegexps("filters", conf.Filters)
+	for i, e := range conf.Entropy {
+		c.checkRegexp(fmt.Sprintf("entropy[%d].file", i), e.File)
+	}
+
+	c.checkGlob("common.patterns_path", conf.Common.PatternsPath)
+	c.checkGlob("common.filters_path", conf.Common.FiltresPath)
+	for option, path := range map[string]string{
+		"common.receipt_key_file": conf.Common.ReceiptKeyFile,
+		"common.hash_key_file":    conf.Common.HashKeyFile,
+		"smtp.template_file":      conf.SMTP.TemplateFile,
+		"forward.token_file":      conf.Forward.TokenFile,
+		"vault.token_file":        conf.Vault.TokenFile,
+		"vault.ca_file":           conf.Vault.CAFile,
+	} {
+		c.checkFile(option, path)
+	}
+	for option, path := range map[string]string{
+		"common.baseline_file": conf.Common.BaselineFile,
+		"common.state_file":    conf.Common.StateFile,
+		"anomaly.state_file":   conf.Anomaly.StateFile,
+		"common.state_db":      conf.Common.StateDB,
+		"common.leaks_file":    conf.Common.LeaksFile,
+		"common.status_file":   conf.Common.StatusFile,
+		"json_lines.path":      conf.JSONLines.Path,
+		"spool.dir":            conf.Spool.Dir,
+		"queues.spill_dir":     conf.Queues.SpillDir,
+		"api.admin_file":       conf.API.AdminFile,
+	} {
+		if path != "" {
+			c.checkDir(option, filepath.Dir(path))
+		}
+	}
+
+	for i, inspect := range conf.Inspect {
+		option := fmt.Sprintf("inspect[%d]", i)
+		if !inspectURLs[inspect.Type] {
+			c.add(option+".type", "unknown type '%s', one of path, github, gitlab, bitbucket, gitea or forgejo", inspect.Type)
+		}
+		c.checkURL(option+".url", inspect.URL, "http", "https")
+		c.checkURL(option+".proxy", inspect.Proxy, "http", "https", "socks5")
+		if inspect.Type == "path" {
+			for j, path := range inspect.Paths {
+				if !strings.HasPrefix(path, "!") {
+					c.checkGlob(fmt.Sprintf("%s.paths[%d]", option, j), path)
+				}
+			}
+		} else if inspect.WorkDir == "" {
+			c.add(option+".work_dir", "is required for %s", inspect.Type)
+		}
+		for j, repo := range inspect.Repos {
+			if parts := strings.Split(repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.ContainsAny(repo, " :") {
+				c.add(fmt.Sprintf("%s.repos[%d]", option, j), "'%s' is not owner/name", repo)
+			}
+		}
+		if inspect.SSH != nil {
+			c.checkFile(option+".ssh.key_file", inspect.SSH.KeyFile)
+			for j, path := range inspect.SSH.KnownHosts {
+				c.checkFile(fmt.Sprintf("%s.ssh.known_hosts[%d]", option, j), path)
+			}
+		}
+	}
+	for i, credential := range conf.Credentials {
+		c.checkFile(fmt.Sprintf("credentials[%d].token_file", i), credential.TokenFile)
+	}
+	for i, token := range conf.API.Tokens {
+		c.checkFile(fmt.Sprintf("api.tokens[%d].token_file", i), token.TokenFile)
+	}
+	for i, hook := range conf.FindingWebhooks {
+		option := fmt.Sprintf("finding_webhooks[%d]", i)
+		c.checkURL(option+".url", hook.URL, "http", "https")
+		c.checkFile(option+".cert_file", hook.CertFile)
+		c.checkFile(option+".key_file", hook.KeyFile)
+		c.checkFile(option+".ca_file", hook.CAFile)
+	}
+	for i, sender := range conf.ExecSenders {
+		if len(sender.Command) > 0 {
+			if _, err := exec.LookPath(sender.Command[0]); err != nil {
+				c.add(fmt.Sprintf("exec_senders[%d].command", i), "%v", err)
+			}
+		}
+	}
+	c.checkURL("api.public_url", conf.API.PublicURL, "http", "https")
+	c.checkURL("forward.url", conf.Forward.URL, "http", "https")
+	if conf.Archive.Enable {
+		c.checkURL("archive.endpoint", conf.Archive.Endpoint, "http", "https")
+	}
+	c.checkURL("update_check.url", conf.UpdateCheck.URL, "http", "https")
+	c.checkURL("proxy.url", conf.Proxy.URL, "http", "https", "socks5")
+	c.checkURL("vault.address", conf.Vault.Address, "http", "https")
+	for option, address := range map[string]string{
+		"api.listen":     conf.API.Listen,
+		"webhook.listen": conf.Webhook.Listen,
+		"debug.listen":   conf.Debug.Listen,
+	} {
+		if _, _, err := net.SplitHostPort(address); address != "" && err != nil {
+			c.add(option, "bad address '%s': %v", address, err)
+		}
+	}
+	if conf.SMTP.Enable {
+		if conf.SMTP.Host == "" || conf.SMTP.Port == 0 {
+			c.add("smtp.host", "host and port are required")
+		}
+		if conf.SMTP.From == "" {
+			c.add("smtp.mail_from", "is required")
+		}
+		addresses := map[string][]string{
+			"smtp.mail_from": {conf.SMTP.From},
+			"smtp.recipient": strings.Split(conf.SMTP.Recipient, ","),
+			"smtp.cc":        conf.SMTP.CC,
+		}
+		for i, route := range conf.SMTP.Routes {
+			addresses[fmt.Sprintf("smtp.routes[%d].to", i)] = route.To
+			addresses[fmt.Sprintf("smtp.routes[%d].cc", i)] = route.CC
+		}
+		for option, list := range addresses {
+			for _, address := range list {
+				if address = strings.TrimSpace(address); address == "" {
+					continue
+				}
+				if _, err := mail.ParseAddress(address); err != nil {
+					c.add(option, "bad address '%s': %v", address, err)
+				}
+			}
+		}
+		if conf.SMTP.Delay != "" {
+			if _, err := time.ParseDuration(conf.SMTP.Delay); err != nil {
+				c.add("smtp.delay", "%v", err)
+			}
+		}
+		if conf.SMTP.OAuth2 != nil {
+			c.checkURL("smtp.oauth2.token_url", conf.SMTP.OAuth2.TokenURL, "https")
+		}
+	}
+	return conf, c.problems
+}
+
+// CheckSMTP - connect to smtp server of config, it is optional as server may be reachable only from production
+func CheckSMTP(conf *Config, timeout time.Duration) error {
+	address := net.JoinHostPort(conf.SMTP.Host, strconv.Itoa(conf.SMTP.Port))
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return fmt.Errorf("smtp server %s is unreachable: %v", address, err)
+	}
+	return conn.Close()
+}
+
+// LineOf - number of line of option like "smtp.host" or "patterns[2].content" in config, zero if it is not found
+func LineOf(configYaml []byte, option string) int {
+	return lineOf(strings.Split(string(configYaml), "\n"), option)
+}
+
+type checker struct {
+	lines    []string
+	problems []Problem
+}
+
+func (c *checker) add(option, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{
+		Line:    lineOf(c.lines, option),
+		Option:  option,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) addYamlError(message string) {
+	p := Problem{Message: message}
+	if m := yamlLineRe.FindStringSubmatch(message); m != nil {
+		p.Line, _ = strconv.Atoi(m[1])
+		p.Message = strings.Replace(message, m[0], "", 1)
+	}
+	c.problems = append(c.problems, p)
+}
+
+// addParseError - error of ParseConfig, its line is found by yaml line or option mentioned in message
+func (c *checker) addParseError(err error) {
+	if yamlLineRe.MatchString(err.Error()) {
+		c.addYamlError(err.Error())
+		return
+	}
+	p := Problem{Message: err.Error()}
+	for _, option := range optionRe.FindAllString(err.Error(), -1) {
+		if !strings.ContainsAny(option, "._") {
+			continue
+		}
+		if p.Line = lineOf(c.lines, option); p.Line == 0 {
+			p.Line = lineOf(c.lines, "common."+option)
+		}
+		if p.Line != 0 {
+			break
+		}
+	}
+	c.problems = append(c.problems, p)
+}
+
+func (c *checker) checkRegexp(option, expr string) {
+	if expr == "" || expr == "*" {
+		return
+	}
+	if _, err := regexp.Compile(expr); err != nil {
+		c.add(option, "%v", err)
+	}
+}
+
+func (c *checker) checkRegexps(section string, patterns []Pattern) {
+	for i, p := range patterns {
+		c.checkRegexp(fmt.Sprintf("%s[%d].file", section, i), p.File)
+		c.checkRegexp(fmt.Sprintf("%s[%d].content", section, i), p.Content)
+	}
+}
+
+func (c *checker) checkFile(option, path string) {
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		c.add(option, "%v", err)
+	} else if info.IsDir() {
+		c.add(option, "%s is a directory", path)
+	}
+}
+
+func (c *checker) checkDir(option, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		c.add(option, "directory %v", err)
+	} else if !info.IsDir() {
+		c.add(option, "%s is not a directory", path)
+	}
+}
+
+func (c *checker) checkGlob(option, pattern string) {
+	if pattern == "" {
+		return
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		c.add(option, "bad glob '%s': %v", pattern, err)
+	} else if len(matches) == 0 {
+		c.add(option, "'%s' matches nothing", pattern)
+	}
+}
+
+func (c *checker) checkURL(option, rawURL string, schemes ...string) {
+	if rawURL == "" {
+		return
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		c.add(option, "%v", err)
+		return
+	}
+	if u.Scheme == "" || u.Host == "" {
+		c.add(option, "'%s' is not absolute url with scheme and host", rawURL)
+		return
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return
+		}
+	}
+	c.add(option, "scheme of '%s' is not %s", rawURL, strings.Join(schemes, " or "))
+}
+
+// lineColumn - column of the first char and text of yaml line, column is -1 for empty lines and comments
+func lineColumn(line string) (int, string) {
+	text := strings.TrimLeft(line, " ")
+	if text == "" || strings.HasPrefix(text, "#") {
+		return -1, ""
+	}
+	return len(line) - len(text), text
+}
+
+// blockEnd - line after block of key at column col which starts at from
+func blockEnd(lines []string, from, end, col int) int {
+	for i := from; i < end; i++ {
+		c, text := lineColumn(lines[i])
+		if c < 0 {
+			continue
+		}
+		if c < col || (c == col && !strings.HasPrefix(text, "-")) {
+			return i
+		}
+	}
+	return end
+}
+
+// lineOf - number of line of option like "smtp.host" or "patterns[2].content" in yaml,
+// line of the closest found parent if option is not set and zero if nothing is found
+func lineOf(lines []string, option string) int {
+	start, end, parent, line := 0, len(lines), -1, 0
+	for _, segment := range strings.Split(option, ".") {
+		key, index := segment, -1
+		if i := strings.Index(segment, "["); i >= 0 && strings.HasSuffix(segment, "]") {
+			key = segment[:i]
+			n, err := strconv.Atoi(segment[i+1 : len(segment)-1])
+			if err != nil {
+				return line
+			}
+			index = n
+		}
+		if key != "" {
+			found := false
+			for i := start; i < end; i++ {
+				col, text := lineColumn(lines[i])
+				if col < 0 {
+					continue
+				}
+				if strings.HasPrefix(text, "- ") {
+					item := strings.TrimLeft(text[1:], " ")
+					col, text = col+len(text)-len(item), item
+				}
+				if col > parent && strings.HasPrefix(text, key+":") {
+					line, start, end, parent, found = i+1, i+1, blockEnd(lines, i+1, end, col), col, true
+					break
+				}
+			}
+			if !found {
+				return line
+			}
+		}
+		if index >= 0 {
+			listCol, n, found := -1, 0, false
+			for i := start; i < end; i++ {
+				col, text := lineColumn(lines[i])
+				if col < parent || !strings.HasPrefix(text, "-") || (listCol >= 0 && col != listCol) {
+					continue
+				}
+				listCol = col
+				if n == index {
+					line, start, end, parent, found = i+1, i, itemEnd(lines, i+1, end, col), col, true
+					break
+				}
+				n++
+			}
+			if !found {
+				return line
+			}
+		}
+	}
+	return line
+}
+
+// itemEnd - line after list item starting with dash at column col
+func itemEnd(lines []string, from, end, col int) int {
+	for i := from; i < end; i++ {
+		if c, _ := lineColumn(lines[i]); c >= 0 && c <= col {
+			return i
+		}
+	}
+	return end
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const checkYaml = `common:
+  log_level: info
+  leaks_file: /nonexistent/leaks.json
+smtp:
+  enable: true
+  hots: mail.example.com
+  port: 25
+  mail_from: fox@example.com
+patterns:
+  - name: ok
+    content: secret
+  - name: broken
+    file: \.go$
+    content: (unclosed
+inspect:
+  - type: github
+    work_dir: WORKDIR
+    repos:
+      - backend/api
+      - https://github.com/frontend/app
+  - type: gitlub
+    url: gitlab.example.com
+    work_dir: WORKDIR
+`
+
+func TestLineOf(t *testing.T) {
+	lines := strings.Split(checkYaml, "\n")
+	Convey("options are found by path", t, func() {
+		So(lineOf(lines, "smtp"), ShouldEqual, 4)
+		So(lineOf(lines, "smtp.port"), ShouldEqual, 7)
+		So(lineOf(lines, "patterns[1]"), ShouldEqual, 12)
+		So(lineOf(lines, "patterns[1].content"), ShouldEqual, 14)
+		So(lineOf(lines, "inspect[0].repos[1]"), ShouldEqual, 20)
+		So(lineOf(lines, "inspect[1].type"), ShouldEqual, 21)
+	})
+
+	Convey("the closest parent is found for missing option", t, func() {
+		So(lineOf(lines, "smtp.host"), ShouldEqual, 4)
+		So(lineOf(lines, "inspect[5].url"), ShouldEqual, 15)
+		So(lineOf(lines, "api.listen"), ShouldEqual, 0)
+	})
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	workDir := filepath.Join(dir, "work")
+
+	Convey("every problem is reported with its line", t, func() {
+		conf, problems := Check([]byte(strings.Replace(checkYaml, "WORKDIR", workDir, -1)))
+		So(conf, ShouldNotBeNil)
+		lines := map[int]string{}
+		for _, p := range problems {
+			lines[p.Line] = p.String()
+		}
+		So(lines, ShouldHaveLength, 7)
+		So(lines[6], ShouldContainSubstring, "field hots not found")
+		So(lines[3], ShouldStartWith, "common.leaks_file: directory")
+		So(lines[4], ShouldEqual, "smtp.host: host and port are required")
+		So(lines[14], ShouldStartWith, "patterns[1].content: error parsing regexp")
+		So(lines[20], ShouldEqual, "inspect[0].repos[1]: 'https://github.com/frontend/app' is not owner/name")
+		So(lines[21], ShouldStartWith, "inspect[1].type: unknown type 'gitlub'")
+		So(lines[22], ShouldStartWith, "inspect[1].url: 'gitlab.example.com' is not absolute url")
+	})
+
+	Convey("errors of parsing have lines too", t, func() {
+		conf, problems := Check([]byte("common:\n  role: all\njson_lines:\n  enable: true\n"))
+		So(conf, ShouldBeNil)
+		So(problems, ShouldResemble, []Problem{{Line: 3, Message: "json_lines.path is required"}})
+
+		_, problems = Check([]byte("common:\n  log_level: [\n"))
+		So(problems, ShouldHaveLength, 1)
+		So(problems[0].Line, ShouldEqual, 2)
+	})
+
+	Convey("default config has no problems", t, func() {
+		_, problems := Check(nil)
+		So(problems, ShouldBeEmpty)
+	})
+}
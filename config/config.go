@@ -3,13 +3,31 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/cron"
+	"github.com/AlexAkulov/hungryfox/filetype"
 	"github.com/AlexAkulov/hungryfox/helpers"
+	"github.com/AlexAkulov/hungryfox/vault"
 
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	// RoleAll - scan repositories and serve api
+	RoleAll = "all"
+	// RoleAPI - serve api from shared leaks store only, don't scan
+	RoleAPI = "api"
+	// RoleCentral - receive leaks of edge instances and send notifications, don't scan
+	RoleCentral = "central"
+)
+
 type SMTP struct {
 	Enable       bool   `yaml:"enable"`
 	From         string `yaml:"mail_from"`
@@ -19,16 +37,446 @@ type SMTP struct {
 	Username     string `yaml:"username"`
 	Password     string `yaml:"password"`
 	Recipient    string `yaml:"recipient"`
-	SentToAuthor bool   `yaml:"sent_to_autor"`
+	SentToAuthor bool   `yaml:"sent_to_author"`
 	Delay        string `yaml:"delay"`
+	TemplateFile string `yaml:"template_file"`
+	MinSeverity  string `yaml:"min_severity"` // leaks with lower severity are not sent, all if empty
+	Redact       bool   `yaml:"redact"`       // secrets extracted by patterns are masked in messages
+	External     bool   `yaml:"external"`     // mailboxes are outside the perimeter, secrets are always stripped by router
+	// PasswordVault - path#field of password in vault instead of password
+	PasswordVault string `yaml:"password_vault"`
+	// CC - copies of messages to recipient
+	CC []string `yaml:"cc"`
+	// Routes - the first matching route chooses recipients instead of recipient and cc
+	Routes []EmailRoute `yaml:"routes"`
+	// AuthorDomains - with sent_to_author only authors with email in these domains are notified, required with sent_to_author
+	AuthorDomains []string `yaml:"author_domains"`
+	// SentToAutor - misspelled sent_to_author of old configs
+	SentToAutor bool `yaml:"sent_to_autor"`
+	// TLSMode - starttls, implicit (usually port 465) or none, tls only switches verification of certificate
+	TLSMode string `yaml:"tls_mode"`
+	// Auth - plain, login or xoauth2
+	Auth   string      `yaml:"auth"`
+	OAuth2 *SMTPOAuth2 `yaml:"oauth2"`
+	// MaxPerMinute - limit of messages per minute of relay, unlimited if zero
+	MaxPerMinute int `yaml:"max_per_minute"`
+}
+
+// SMTPOAuth2 - access token of xoauth2, refresh token grant if refresh_token is set and client credentials grant otherwise
+type SMTPOAuth2 struct {
+	TokenURL     string   `yaml:"token_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RefreshToken string   `yaml:"refresh_token"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// EmailRoute - leaks matching every non-empty condition are sent to its recipients
+type EmailRoute struct {
+	Name        string   `yaml:"name"`
+	MinSeverity string   `yaml:"min_severity"`
+	Repos       []string `yaml:"repos"`    // glob patterns of repo path or host/path
+	Patterns    []string `yaml:"patterns"` // glob patterns of pattern name
+	To          []string `yaml:"to"`
+	CC          []string `yaml:"cc"`
+	// Digest - cron of digest, leaks of route are collected and sent in one message at every time of it instead of after delay
+	Digest         string         `yaml:"digest"`
+	DigestSchedule *cron.Schedule `yaml:"-"`
 }
 
 type Config struct {
 	Common   *Common   `yaml:"common"`
 	Inspect  []Inspect `yaml:"inspect"`
 	Patterns []Pattern `yaml:"patterns"`
-	Filters  []Pattern `yaml:"filters"`
-	SMTP     *SMTP     `yaml:"smtp"`
+	// DefaultPatterns - built-in curated patterns
+	DefaultPatterns *DefaultPatterns `yaml:"default_patterns"`
+	// Entropy - detectors of high entropy strings, disabled if empty
+	Entropy []Entropy `yaml:"entropy"`
+	Filters []Pattern `yaml:"filters"`
+	// Allowlist - leaks in test fixtures, vendored code and so on are suppressed before router
+	Allowlist []Allowlist `yaml:"allowlist"`
+	// Escalate - raise severity of leaks by their authors
+	Escalate []Escalation `yaml:"escalate"`
+	SMTP     *SMTP        `yaml:"smtp"`
+	API      *API         `yaml:"api"`
+	Webhook  *Webhook     `yaml:"webhook"`
+	// Credentials - https tokens per host for clone, fetch and discovery api
+	Credentials []Credential `yaml:"credentials"`
+	Proxy       *Proxy       `yaml:"proxy"`
+	Spool       *Spool       `yaml:"spool"`
+	JSONLines   *JSONLines   `yaml:"json_lines"`
+	Forward     *Forward     `yaml:"forward"`
+	Anomaly     *Anomaly     `yaml:"anomaly"`
+	Verify      *Verify      `yaml:"verify"`
+	Retention   *Retention   `yaml:"retention"`
+	Debug       *Debug       `yaml:"debug"`
+	UpdateCheck *UpdateCheck `yaml:"update_check"`
+	// FindingWebhooks - endpoints which receive every leak as json
+	FindingWebhooks []FindingWebhook `yaml:"finding_webhooks"`
+	Identity        *Identity        `yaml:"identity"`
+	Vault           *Vault           `yaml:"vault"`
+	RateLimit       *RateLimit       `yaml:"rate_limit"`
+	// Schedules - scan intervals and cron schedules of repo groups instead of scan_interval
+	Schedules []Schedule `yaml:"schedules"`
+	Queues    *Queues    `yaml:"queues"`
+	// Histories - history_limit and max_commits of repo groups instead of common ones
+	Histories []History `yaml:"history"`
+	// ExecSenders - commands which receive every leak as json on stdin
+	ExecSenders []ExecSender `yaml:"exec_senders"`
+	// Archive - batches of leaks in S3-compatible bucket for long-term archiving
+	Archive *Archive `yaml:"archive"`
+	// Files - files which are scanned by extension, name or MIME type, all files if empty
+	Files *Files `yaml:"files"`
+	// Summary - scheduled summary of scans and leaks by email and Slack
+	Summary *Summary `yaml:"summary"`
+	// Audit - machine-readable log of scans, rule reloads and notifications
+	Audit *Audit `yaml:"audit"`
+}
+
+// Vault - HashiCorp Vault which holds secrets of token_vault and password_vault references
+type Vault struct {
+	Address     string `yaml:"address"` // disabled if empty
+	Namespace   string `yaml:"namespace"`
+	CAFile      string `yaml:"ca_file"` // CA of vault server certificate, system roots if empty
+	TokenSource `yaml:",inline"`
+	// RefreshString - secrets without lease like kv are read again after it, so rotated secrets are picked up
+	RefreshString string        `yaml:"refresh"`
+	Refresh       time.Duration `yaml:"-"`
+}
+
+// VaultReader - reads path#field references of token_vault and password_vault, set on start when vault is configured
+var VaultReader func(ref string) (string, error)
+
+// FindingWebhook - endpoint which receives leaks by POST, with client certificate for mutual TLS
+type FindingWebhook struct {
+	Name     string            `yaml:"name"`
+	URL      string            `yaml:"url"`
+	CertFile string            `yaml:"cert_file"` // client certificate, mutual TLS is disabled if empty
+	KeyFile  string            `yaml:"key_file"`
+	CAFile   string            `yaml:"ca_file"` // CA of server certificate, system roots if empty
+	Headers  map[string]string `yaml:"headers"`
+	// MinSeverity - leaks with lower severity are not sent, all if empty
+	MinSeverity string `yaml:"min_severity"`
+	// External - endpoint is outside the perimeter, secrets are always stripped by router
+	External bool `yaml:"external"`
+	// Retries - attempts after failed delivery with doubling delay, 3 if empty, negative disables retries
+	Retries int `yaml:"retries"`
+}
+
+// ExecSender - command run for every leak, e.g. script which opens ticket or rotates key
+type ExecSender struct {
+	Name          string        `yaml:"name"`
+	Command       []string      `yaml:"command"` // executable and arguments, it is not run by shell
+	TimeoutString string        `yaml:"timeout"` // command is killed after it, 30s if empty
+	Timeout       time.Duration `yaml:"-"`
+	// MinSeverity - leaks with lower severity are not sent, all if empty
+	MinSeverity string `yaml:"min_severity"`
+	// External - command sends leaks outside the perimeter, secrets are always stripped by router
+	External bool `yaml:"external"`
+}
+
+// Identity - deployment identity in headers of finding webhooks, receivers can check it against client certificate and source address
+type Identity struct {
+	Instance  string   `yaml:"instance"`   // hostname if empty
+	EgressIPs []string `yaml:"egress_ips"` // static egress addresses of deployment
+}
+
+// UpdateCheck - opt-in check of internal release url for newer versions
+type UpdateCheck struct {
+	URL            string        `yaml:"url"` // disabled if empty
+	IntervalString string        `yaml:"interval"`
+	Interval       time.Duration `yaml:"-"`
+}
+
+// Debug - pprof and runtime stats for diagnosing production instances, it has no auth, bind it to localhost
+type Debug struct {
+	Listen string `yaml:"listen"` // disabled if empty
+}
+
+// Retention - periods after which leaks are purged from leaks_file, fingerprints are kept forever
+type Retention struct {
+	Enable              bool          `yaml:"enable"`
+	DryRun              bool          `yaml:"dry_run"` // only log what would be purged
+	IntervalString      string        `yaml:"interval"`
+	ContentAfterString  string        `yaml:"content_after"`  // leak strings are removed, never if empty
+	ResolvedAfterString string        `yaml:"resolved_after"` // resolved and ignored leaks are removed, never if empty
+	Interval            time.Duration `yaml:"-"`
+	ContentAfter        time.Duration `yaml:"-"`
+	ResolvedAfter       time.Duration `yaml:"-"`
+}
+
+// Verify - check whether found credentials are live by calling their apis
+type Verify struct {
+	Enable    bool     `yaml:"enable"`
+	Verifiers []string `yaml:"verifiers"` // aws, github, gitlab, slack or stripe, all if empty
+	Rate      int      `yaml:"rate"`      // requests per minute, leaks over rate are not checked
+	// VerifiedSeverity - severity of verified leaks, so they can be routed by min_severity
+	VerifiedSeverity string `yaml:"verified_severity"`
+}
+
+// RateLimit - notifications of email, finding webhooks and exec senders with own limit over limits are replaced by summaries,
+// leaks file, spool and json_lines still receive every leak
+type RateLimit struct {
+	Enable    bool `yaml:"enable"`
+	PerMinute int  `yaml:"per_minute"` // notifications of all senders, unlimited if zero
+	Burst     int  `yaml:"burst"`      // notifications at once after quiet period, per_minute if zero
+	// Senders - limits of email, webhook:<name> and exec:<name> senders, exec senders are not limited without it
+	Senders               map[string]SenderRateLimit `yaml:"senders"`
+	SummaryIntervalString string                     `yaml:"summary_interval"` // how often leaks over limits are summarized
+	SummaryInterval       time.Duration              `yaml:"-"`
+}
+
+// Queues - queues of diffs between scanner and searcher and of leaks between searcher and router
+type Queues struct {
+	Diffs    int    `yaml:"diffs"`     // capacity
+	Leaks    int    `yaml:"leaks"`     // capacity
+	Policy   string `yaml:"policy"`    // block or spill, what is done with diffs and leaks when queue is full
+	SpillDir string `yaml:"spill_dir"` // spilled diffs and leaks, they are read after restart if they were not read before stop
+}
+
+// SenderRateLimit - notifications per minute of sender
+type SenderRateLimit struct {
+	PerMinute int `yaml:"per_minute"`
+	Burst     int `yaml:"burst"`
+}
+
+// Anomaly - commits with much more leaks than usual for repo are reported as bulk credential dumps
+type Anomaly struct {
+	Enable   bool    `yaml:"enable"`
+	MinLeaks int     `yaml:"min_leaks"` // commit with less leaks is never reported
+	Sigma    float64 `yaml:"sigma"`     // standard deviations above mean leaks per commit of repo
+	Window   int     `yaml:"window"`    // last commits with leaks of repo which are baseline
+	// StateFile - leaks per commit of repos survive restart, they are kept in memory if empty
+	StateFile string `yaml:"state_file"`
+}
+
+// Spool - leaks are stored in dir for forward command, it is for instances without access to central one
+type Spool struct {
+	Enable bool   `yaml:"enable"`
+	Dir    string `yaml:"dir"`
+	Source string `yaml:"source"` // name of instance in envelopes, hostname if empty
+	// MinSeverity - leaks with lower severity are not spooled, all if empty
+	MinSeverity string `yaml:"min_severity"`
+	// External - central instance is outside the perimeter, secrets are always stripped by router
+	External bool `yaml:"external"`
+}
+
+// JSONLines - every leak as canonical json line for log shippers, rotated by size and age
+type JSONLines struct {
+	Enable       bool          `yaml:"enable"`
+	Path         string        `yaml:"path"`
+	MaxSizeMB    int           `yaml:"max_size_mb"` // not rotated by size if zero
+	MaxAgeString string        `yaml:"max_age"`     // not rotated by age if empty
+	MaxAge       time.Duration `yaml:"-"`
+	MaxFiles     int           `yaml:"max_files"` // rotated files which are kept, all if zero
+	Gzip         bool          `yaml:"gzip"`
+	MinSeverity  string        `yaml:"min_severity"`
+	External     bool          `yaml:"external"`
+}
+
+// Archive - leaks are uploaded as gzipped json lines to S3-compatible bucket every interval
+type Archive struct {
+	Enable    bool   `yaml:"enable"`
+	Endpoint  string `yaml:"endpoint"` // https://s3.amazonaws.com, minio or ceph url, path-style urls are used
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"`     // of object keys, batches are named <prefix>YYYY/MM/DD/<time>-<instance>.jsonl.gz
+	AccessKey string `yaml:"access_key"` // AWS_ACCESS_KEY_ID if empty
+	SecretKey string `yaml:"secret_key"` // AWS_SECRET_ACCESS_KEY if empty
+	// IntervalString - how often batch is uploaded, leaks of the last batch are uploaded on shutdown
+	IntervalString  string        `yaml:"interval"`
+	Interval        time.Duration `yaml:"-"`
+	RetentionString string        `yaml:"retention"` // older batches are removed, kept forever if empty
+	Retention       time.Duration `yaml:"-"`
+	// PendingDir - batches are written here before upload and kept until they are uploaded
+	PendingDir   string `yaml:"pending_dir"`
+	MaxPendingMB int    `yaml:"max_pending_mb"` // the oldest pending batches are dropped over it, unlimited if zero
+	MinSeverity  string `yaml:"min_severity"`
+	External     bool   `yaml:"external"`
+}
+
+// Files - rules of filetype: extensions like .svg, globs of file name like yarn.lock or MIME types like image/*
+type Files struct {
+	Include []string         `yaml:"include"` // only matching files are scanned if not empty
+	Exclude []string         `yaml:"exclude"` // matching files are never scanned
+	Filter  *filetype.Filter `yaml:"-"`
+}
+
+// Summary - numbers of scans, new and open leaks of period sent by schedule, secrets are never included
+type Summary struct {
+	Enable          bool     `yaml:"enable"`
+	Schedule        string   `yaml:"schedule"`          // cron in local time of the end of period
+	EmailTo         []string `yaml:"email_to"`          // recipients of html summary, smtp is required
+	SlackWebhookURL string   `yaml:"slack_webhook_url"` // incoming webhook of Slack channel
+	Top             int      `yaml:"top"`               // rows of top patterns, repos and authors
+	// StateFile - activity of current period survives restart, it is kept in memory if empty
+	StateFile    string         `yaml:"state_file"`
+	CronSchedule *cron.Schedule `yaml:"-"`
+}
+
+// Audit - json events of scans, rule reloads and notifications in file or syslog, they are evidence for compliance
+type Audit struct {
+	Enable bool   `yaml:"enable"`
+	Output string `yaml:"output"` // file or syslog
+	File   string `yaml:"file"`   // events are appended as json lines
+	// SyslogAddress - like udp://host:514 or tcp://host:514, local syslog if empty
+	SyslogAddress string `yaml:"syslog_address"`
+	Tag           string `yaml:"tag"` // syslog tag
+}
+
+// Forward - central instance which receives spooled leaks
+type Forward struct {
+	URL            string `yaml:"url"`
+	TokenSource    `yaml:",inline"`
+	IntervalString string        `yaml:"interval"` // retry interval of forward -watch
+	Interval       time.Duration `yaml:"-"`
+}
+
+// Proxy - proxy for git over https, discovery api and http senders
+type Proxy struct {
+	URL     string            `yaml:"url"`
+	NoProxy []string          `yaml:"no_proxy"`
+	Hosts   map[string]string `yaml:"hosts"`
+}
+
+// Credential - the first one matching host and repos is used
+type Credential struct {
+	Host        string   `yaml:"host"`
+	Repos       []string `yaml:"repos"`
+	Username    string   `yaml:"username"`
+	TokenSource `yaml:",inline"`
+}
+
+// TokenSource - token is set in config, environment variable, secret file or vault
+type TokenSource struct {
+	Token      string `yaml:"token"`
+	TokenEnv   string `yaml:"token_env"`
+	TokenFile  string `yaml:"token_file"`
+	TokenVault string `yaml:"token_vault"` // path#field of secret in vault
+}
+
+// IsSet - one of token sources is configured
+func (c *TokenSource) IsSet() bool {
+	return c.Token != "" || c.TokenEnv != "" || c.TokenFile != "" || c.TokenVault != ""
+}
+
+// getVaultSecret - secret of path#field reference from vault
+func getVaultSecret(ref string) (string, error) {
+	if VaultReader == nil {
+		return "", fmt.Errorf("vault is not configured")
+	}
+	return VaultReader(ref)
+}
+
+// GetToken - token from config, environment variable, secret file or vault
+func (c *TokenSource) GetToken() (string, error) {
+	switch {
+	case c.Token != "":
+		return c.Token, nil
+	case c.TokenVault != "":
+		return getVaultSecret(c.TokenVault)
+	case c.TokenEnv != "":
+		token := os.Getenv(c.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s is empty", c.TokenEnv)
+		}
+		return token, nil
+	case c.TokenFile != "":
+		data, err := ioutil.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", fmt.Errorf("token, token_env, token_file or token_vault is required")
+}
+
+// GetPassword - password from config or vault
+func (s *SMTP) GetPassword() (string, error) {
+	if s.PasswordVault != "" {
+		return getVaultSecret(s.PasswordVault)
+	}
+	return s.Password, nil
+}
+
+// RetentionExcluded - stores which keep secrets of leaks longer than retention.content_after, retention
+// rewrites only leaks_file, external stores receive leaks without secrets
+func (c *Config) RetentionExcluded() []string {
+	if !c.Retention.Enable || c.Retention.ContentAfter == 0 {
+		return nil
+	}
+	result := []string{}
+	if c.JSONLines.Enable && !c.JSONLines.External && (c.JSONLines.MaxAge == 0 || c.JSONLines.MaxFiles == 0) {
+		result = append(result, "json_lines: rotated files are kept, set max_age and max_files")
+	}
+	if c.Archive.Enable && !c.Archive.External && (c.Archive.Retention == 0 || c.Archive.Retention > c.Retention.ContentAfter) {
+		result = append(result, "archive: batches are kept longer, set archive.retention")
+	}
+	if c.Spool.Enable && !c.Spool.External {
+		result = append(result, "spool: envelopes are kept until they are forwarded")
+	}
+	if c.Queues.Policy == "spill" && !c.Common.HashOnly {
+		result = append(result, "queues: spilled leaks are kept until they are routed")
+	}
+	return result
+}
+
+// VaultRefs - references of token_vault and password_vault in config, they are read on start and again on use
+func (c *Config) VaultRefs() []string {
+	refs := []string{}
+	add := func(ref string) {
+		if ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	add(c.SMTP.PasswordVault)
+	add(c.Forward.TokenVault)
+	for _, credential := range c.Credentials {
+		add(credential.TokenVault)
+	}
+	for _, token := range c.API.Tokens {
+		add(token.TokenVault)
+	}
+	return refs
+}
+
+type Webhook struct {
+	Listen string `yaml:"listen"`
+	Secret string `yaml:"secret"`
+}
+
+type API struct {
+	Listen string `yaml:"listen"`
+	// UI - serve leak pages, notifications link to them
+	UI bool `yaml:"ui"`
+	// PublicURL - external address of api for links in notifications
+	PublicURL string `yaml:"public_url"`
+	// Tokens - scoped tokens for api and webhook scan trigger, api is open if empty
+	Tokens []APIToken `yaml:"tokens"`
+	// AdminFile - repos added, removed and paused by admin api, changes are lost on restart if empty
+	AdminFile string `yaml:"admin_file"`
+	// AdminWorkDir - repos added by url are cloned here, only local paths can be added if empty
+	AdminWorkDir string `yaml:"admin_work_dir"`
+}
+
+// APIToken - token of team or tenant limited by scopes and repos
+type APIToken struct {
+	Name        string   `yaml:"name"`
+	Scopes      []string `yaml:"scopes"`
+	Repos       []string `yaml:"repos"`
+	TokenSource `yaml:",inline"`
+}
+
+// hasScope - one of tokens has scope, tokens can't be used here as it imports config
+func (a *API) hasScope(scope string) bool {
+	for _, t := range a.Tokens {
+		for _, s := range t.Scopes {
+			if s == scope {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 type Inspect struct {
@@ -42,57 +490,716 @@ type Inspect struct {
 	Users      []string `yaml:"users"`
 	Repos      []string `yaml:"repos"`
 	Orgs       []string `yaml:"orgs"`
+	Groups     []string `yaml:"groups"`
+	Projects   []string `yaml:"projects"`
+	Include    []string `yaml:"include"`
+	Exclude    []string `yaml:"exclude"`
+	SSH        *SSH     `yaml:"ssh"`
+	Proxy      string   `yaml:"proxy"`
+	// ObjectFormat - sha1 (default) or sha256, sha256 repos are handled by external git
+	ObjectFormat string `yaml:"object_format"`
+	// Fetch - local repos of path are fetched from remote with prune before every scan
+	Fetch  bool   `yaml:"fetch"`
+	Remote string `yaml:"remote"` // remote which is fetched, origin if empty
+	// FetchTimeoutString - fetch of repos of inspect is cancelled after it, common fetch_timeout if empty
+	FetchTimeoutString string        `yaml:"fetch_timeout"`
+	FetchTimeout       time.Duration `yaml:"-"`
+}
+
+// SSH - key authentication for clone and fetch, repos are cloned by ssh url if set
+type SSH struct {
+	User                  string   `yaml:"user"`
+	KeyFile               string   `yaml:"key_file"`
+	Passphrase            string   `yaml:"passphrase"`
+	KnownHosts            []string `yaml:"known_hosts"`
+	InsecureIgnoreHostKey bool     `yaml:"insecure_ignore_host_key"`
 }
 
 type Common struct {
-	StateFile              string `yaml:"state_file"`
-	HistoryPastLimitString string `yaml:"history_limit"`
-	LogLevel               string `yaml:"log_level"`
-	LeaksFile              string `yaml:"leaks_file"`
-	ScanIntervalString     string `yaml:"scan_interval"`
-	PatternsPath           string `yaml:"patterns_path"`
-	FiltresPath            string `yaml:"filters_path"`
-	Workers                int    `yaml:"workers"`
+	StateFile              string        `yaml:"state_file"`
+	StateDB                string        `yaml:"state_db"` // embedded database instead of state_file, state_file is imported on first start
+	HistoryPastLimitString string        `yaml:"history_limit"`
+	LogLevel               string        `yaml:"log_level"`
+	LeaksFile              string        `yaml:"leaks_file"`
+	StatusFile             string        `yaml:"status_file"` // history of leak statuses, statuses are disabled if empty
+	ScanIntervalString     string        `yaml:"scan_interval"`
+	ScanJitterString       string        `yaml:"scan_jitter"`        // scans of repos are spread over this time after their due time
+	DiscoveryString        string        `yaml:"discovery_interval"` // how often globs of paths and repos of hosting apis are expanded again
+	PatternsPath           string        `yaml:"patterns_path"`
+	FiltresPath            string        `yaml:"filters_path"`
+	PatternsReloadString   string        `yaml:"patterns_reload_interval"` // how often patterns_path and filters_path are checked for changes, 0 disables
+	Workers                int           `yaml:"workers"`
+	Role                   string        `yaml:"role"`
+	RemovedRepos           *RemovedRepos `yaml:"removed_repos"`
+	FullScanPaths          []string      `yaml:"full_scan_paths"`
+	DiffWindowKB           int           `yaml:"diff_window_kb"`   // added chunks and files are inspected in windows of this size
+	MaxDiffMB              int           `yaml:"max_diff_mb"`      // the rest of bigger added chunk or file is not inspected, unlimited if zero
+	ScanWorkers            int           `yaml:"scan_workers"`     // repos scanned in parallel
+	MaxCommits             int           `yaml:"max_commits"`      // new commits of repo which are scanned, older ones are inspected as one snapshot, unlimited if zero
+	FetchPerHost           int           `yaml:"fetch_per_host"`   // clones and fetches from one host at once, unlimited if zero
+	BaselineFile           string        `yaml:"baseline_file"`    // leaks of baseline are not reported, see hungryfox baseline
+	Dedup                  bool          `yaml:"dedup"`            // leaks of leaks_file are not sent again, reappeared secrets are only written to leaks_file
+	ReceiptKeyFile         string        `yaml:"receipt_key_file"` // ed25519 private key in PEM, found leaks are signed with it if set
+	FakeClockString        string        `yaml:"fake_clock"`       // RFC3339 start of deterministic clock for tests and replays, system clock if empty
+	HashOnly               bool          `yaml:"hash_only"`        // leaks keep only HMAC fingerprints, rule and location, content never leaves searcher
+	HashKeyFile            string        `yaml:"hash_key_file"`    // HMAC key of hash_only
+	ShutdownString         string        `yaml:"shutdown_timeout"` // how long queued diffs and leaks are drained on stop, 0 waits for all of them
+	FakeClock              time.Time
 	HistoryPastLimit       time.Time
 	ScanInterval           time.Duration
+	ScanJitter             time.Duration
+	DiscoveryInterval      time.Duration
+	PatternsReload         time.Duration
+	ShutdownTimeout        time.Duration
+	// FetchLimits - fetch_per_host of hosts
+	FetchLimits map[string]int `yaml:"fetch_limits"`
+
+	// ContextLines - lines before and after leak line which are kept in leak, disabled if zero
+	ContextLines int `yaml:"context_lines"`
+	// RedactContext - secret is masked in context of leak, the whole leak line if pattern doesn't declare secret group
+	RedactContext bool `yaml:"redact_context"`
+	// BlobCacheSize - hashes of inspected blobs kept in state per repo, files with these blobs are not inspected again, disabled if zero
+	BlobCacheSize int `yaml:"blob_cache_size"`
+	// FetchTimeoutString - fetch of repo is cancelled after it
+	FetchTimeoutString string        `yaml:"fetch_timeout"`
+	FetchTimeout       time.Duration `yaml:"-"`
+}
+
+// Schedule - scan interval or cron of repos, the first matching schedule is used and scan_interval if none matches
+type Schedule struct {
+	Repos          []string       `yaml:"repos"` // glob patterns of repo path or host/path
+	IntervalString string         `yaml:"interval"`
+	Cron           string         `yaml:"cron"` // minute hour day month weekday in local time or @hourly, @daily, @weekly, @monthly
+	Interval       time.Duration  `yaml:"-"`
+	CronSchedule   *cron.Schedule `yaml:"-"`
+}
+
+// History - how deep new commits of repos are scanned, the first matching history is used and common
+// history_limit and max_commits if none matches
+type History struct {
+	Repos                  []string  `yaml:"repos"`         // glob patterns of repo path or host/path
+	HistoryPastLimitString string    `yaml:"history_limit"` // common history_limit if empty
+	MaxCommits             int       `yaml:"max_commits"`   // common max_commits if zero
+	HistoryPastLimit       time.Time `yaml:"-"`
+}
+
+// RemovedRepos - what to do with repos which disappeared from config or discovery
+type RemovedRepos struct {
+	ArchiveState            bool          `yaml:"archive_state"`
+	DeleteMirrorAfterString string        `yaml:"delete_mirror_after"`
+	DeleteMirrorAfter       time.Duration `yaml:"-"`
+}
+
+// DefaultPatterns - built-in patterns are enabled by default, some of them can be disabled by name
+type DefaultPatterns struct {
+	Enable  bool     `yaml:"enable"`
+	Disable []string `yaml:"disable"`
+}
+
+// Entropy - strings of charset longer than min_length with Shannon entropy above threshold are leaks
+type Entropy struct {
+	Name      string  `yaml:"name"`
+	File      string  `yaml:"file"`
+	Charset   string  `yaml:"charset"` // base64, hex or chars of custom charset
+	MinLength int     `yaml:"min_length"`
+	Threshold float64 `yaml:"threshold"` // bits per char
+	Severity  string  `yaml:"severity"`
+}
+
+// Allowlist - rule suppresses leaks matching all of its non-empty lists
+type Allowlist struct {
+	Name     string   `yaml:"name"`
+	Files    []string `yaml:"files"`    // glob patterns of file path, "**" matches any depth
+	Repos    []string `yaml:"repos"`    // glob patterns of repo path or host/path
+	Patterns []string `yaml:"patterns"` // glob patterns of pattern name
+	Authors  []string `yaml:"authors"`  // glob patterns of author or committer email, case insensitive
+}
+
+// Escalation - leaks committed by matching authors get higher severity, e.g. commits of contractors
+type Escalation struct {
+	Name     string   `yaml:"name"`
+	Authors  []string `yaml:"authors"` // glob patterns of author or committer email, case insensitive
+	Severity string   `yaml:"severity"`
 }
 
 type Pattern struct {
 	Name    string `yaml:"name"`
-	File    string `yaml:"file"`
-	Content string `yaml:"content"`
+	File    string `yaml:"file,omitempty"`
+	Content string `yaml:"content,omitempty"`
+	// Keywords - pattern is checked only for diffs containing one of them, case insensitive
+	Keywords []string `yaml:"keywords,omitempty"`
+	Severity string   `yaml:"severity,omitempty"` // critical, high, medium or low, medium if empty
+	// Languages - pattern is checked only for files of these languages, all files if empty
+	Languages []string `yaml:"languages,omitempty"`
+	// SecretGroup - number or name of capture group of content regexp holding the secret itself
+	SecretGroup string `yaml:"secret_group,omitempty"`
+	// Family - patterns are grouped by family in rule costs, name of pattern if empty
+	Family string `yaml:"family,omitempty"`
 }
 
 func defaultConfig() *Config {
 	return &Config{
+		Common: &Common{
+			LogLevel:             "info",
+			ScanIntervalString:   "30m",
+			DiscoveryString:      "30m",
+			PatternsReloadString: "30s",
+			ShutdownString:       "30s",
+			FetchTimeoutString:   "10m",
+			DiffWindowKB:         256,
+			ScanWorkers:          1,
+			Role:                 RoleAll,
+			RemovedRepos: &RemovedRepos{
+				ArchiveState: true,
+			},
+			FullScanPaths: []string{"id_rsa", "id_dsa", "*.pem", "*.key", ".env", "credentials.*"},
+			Dedup:         true,
+		},
 		SMTP: &SMTP{
-			Delay: "5m",
+			Delay:   "5m",
+			TLSMode: "starttls",
+			Auth:    "plain",
 		},
+		API:       &API{},
+		Webhook:   &Webhook{},
+		Proxy:     &Proxy{},
+		Spool:     &Spool{},
+		JSONLines: &JSONLines{MaxSizeMB: 100, MaxFiles: 10, Gzip: true},
+		Archive:   &Archive{Endpoint: "https://s3.amazonaws.com", Region: "us-east-1", IntervalString: "1h", MaxPendingMB: 1024},
+		Forward:   &Forward{IntervalString: "1m"},
+		Anomaly:   &Anomaly{MinLeaks: 20, Sigma: 3, Window: 100},
+		Verify:    &Verify{Rate: 30, VerifiedSeverity: "critical"},
+		Retention: &Retention{IntervalString: "24h"},
+		Debug:     &Debug{},
+
+		UpdateCheck: &UpdateCheck{IntervalString: "24h"},
+		Identity:    &Identity{},
+		Vault:       &Vault{RefreshString: "5m"},
+		RateLimit:   &RateLimit{SummaryIntervalString: "10m"},
+		Queues:      &Queues{Diffs: 100, Leaks: 1, Policy: "block"},
+		Files:       &Files{},
+		Summary:     &Summary{Schedule: "0 9 * * 1", Top: 10},
+		Audit:       &Audit{Output: "file", Tag: "hungryfox"},
+
+		DefaultPatterns: &DefaultPatterns{Enable: true},
+	}
+}
+
+// fillDefaults - restore sections which were set to null in yaml
+func fillDefaults(config *Config) {
+	defaults := defaultConfig()
+	if config.Common == nil {
+		config.Common = defaults.Common
+	}
+	if config.Common.RemovedRepos == nil {
+		config.Common.RemovedRepos = defaults.Common.RemovedRepos
+	}
+	if config.SMTP == nil {
+		config.SMTP = defaults.SMTP
+	}
+	if config.API == nil {
+		config.API = defaults.API
+	}
+	if config.Webhook == nil {
+		config.Webhook = defaults.Webhook
+	}
+	if config.Proxy == nil {
+		config.Proxy = defaults.Proxy
+	}
+	if config.DefaultPatterns == nil {
+		config.DefaultPatterns = defaults.DefaultPatterns
+	}
+	if config.Spool == nil {
+		config.Spool = defaults.Spool
+	}
+	if config.JSONLines == nil {
+		config.JSONLines = defaults.JSONLines
+	}
+	if config.Archive == nil {
+		config.Archive = defaults.Archive
+	}
+	if config.Forward == nil {
+		config.Forward = defaults.Forward
+	}
+	if config.Anomaly == nil {
+		config.Anomaly = defaults.Anomaly
+	}
+	if config.Verify == nil {
+		config.Verify = defaults.Verify
+	}
+	if config.Retention == nil {
+		config.Retention = defaults.Retention
+	}
+	if config.Debug == nil {
+		config.Debug = defaults.Debug
+	}
+	if config.UpdateCheck == nil {
+		config.UpdateCheck = defaults.UpdateCheck
+	}
+	if config.Identity == nil {
+		config.Identity = defaults.Identity
+	}
+	if config.Vault == nil {
+		config.Vault = defaults.Vault
+	}
+	if config.RateLimit == nil {
+		config.RateLimit = defaults.RateLimit
+	}
+	if config.Queues == nil {
+		config.Queues = defaults.Queues
+	}
+	if config.Files == nil {
+		config.Files = defaults.Files
+	}
+	if config.Summary == nil {
+		config.Summary = defaults.Summary
+	}
+	if config.Audit == nil {
+		config.Audit = defaults.Audit
 	}
 }
 
 func LoadConfig(configLocation string) (*Config, error) {
-	config := defaultConfig()
 	configYaml, err := ioutil.ReadFile(configLocation)
 	if err != nil {
 		return nil, fmt.Errorf("can't read with: %v", err)
 	}
+	return ParseConfig(configYaml)
+}
+
+// ParseConfig - parse yaml config over defaults, environment variables are expanded first
+func ParseConfig(configYaml []byte) (*Config, error) {
+	configYaml, err := expandEnv(configYaml)
+	if err != nil {
+		return nil, err
+	}
+	config := defaultConfig()
 	err = yaml.Unmarshal(configYaml, &config)
 	if err != nil {
 		return nil, fmt.Errorf("can't parse with: %v", err)
 	}
+	if config == nil {
+		config = defaultConfig()
+	}
+	fillDefaults(config)
+	switch config.Common.Role {
+	case RoleAll, RoleAPI, RoleCentral:
+	default:
+		return nil, fmt.Errorf("unknown role '%s'", config.Common.Role)
+	}
 	pastLimit, err := helpers.ParseDuration(config.Common.HistoryPastLimitString)
 	if err != nil {
 		return nil, err
 	}
-	config.Common.HistoryPastLimit = time.Now().Add(-pastLimit)
+	now := time.Now()
+	if config.Common.FakeClockString != "" {
+		if config.Common.FakeClock, err = time.Parse(time.RFC3339, config.Common.FakeClockString); err != nil {
+			return nil, fmt.Errorf("bad fake_clock: %v", err)
+		}
+		now = config.Common.FakeClock
+	}
+	config.Common.HistoryPastLimit = now.Add(-pastLimit)
+	if config.Common.RemovedRepos.DeleteMirrorAfter, err = helpers.ParseDuration(config.Common.RemovedRepos.DeleteMirrorAfterString); err != nil {
+		return nil, err
+	}
 	config.Common.ScanInterval, err = helpers.ParseDuration(config.Common.ScanIntervalString)
 	if err != nil {
 		return nil, err
 	}
-	if config.Common.ScanInterval < time.Second {
+	if config.Common.ScanInterval < time.Second && config.Common.Role == RoleAll {
 		return nil, fmt.Errorf("scan_interval so small")
 	}
+	if config.Common.PatternsReload, err = helpers.ParseDuration(config.Common.PatternsReloadString); err != nil {
+		return nil, err
+	}
+	if config.Common.ScanJitter, err = helpers.ParseDuration(config.Common.ScanJitterString); err != nil {
+		return nil, err
+	}
+	if config.Common.DiscoveryInterval, err = helpers.ParseDuration(config.Common.DiscoveryString); err != nil {
+		return nil, err
+	}
+	if config.Common.DiscoveryInterval < time.Minute && config.Common.Role == RoleAll {
+		return nil, fmt.Errorf("discovery_interval so small")
+	}
+	if config.Common.ShutdownTimeout, err = helpers.ParseDuration(config.Common.ShutdownString); err != nil {
+		return nil, err
+	}
+	for i := range config.Schedules {
+		schedule := &config.Schedules[i]
+		if len(schedule.Repos) == 0 {
+			return nil, fmt.Errorf("schedule %d: repos are required", i+1)
+		}
+		if (schedule.IntervalString == "") == (schedule.Cron == "") {
+			return nil, fmt.Errorf("schedule %d: one of interval or cron is required", i+1)
+		}
+		if schedule.Cron != "" {
+			if schedule.CronSchedule, err = cron.Parse(schedule.Cron); err != nil {
+				return nil, fmt.Errorf("schedule %d: %v", i+1, err)
+			}
+			if schedule.CronSchedule.Next(now).IsZero() {
+				return nil, fmt.Errorf("schedule %d: cron '%s' never matches", i+1, schedule.Cron)
+			}
+			continue
+		}
+		if schedule.Interval, err = helpers.ParseDuration(schedule.IntervalString); err != nil {
+			return nil, fmt.Errorf("schedule %d: %v", i+1, err)
+		}
+		if schedule.Interval < time.Minute {
+			return nil, fmt.Errorf("schedule %d: interval so small", i+1)
+		}
+	}
+	if config.Common.MaxCommits < 0 {
+		return nil, fmt.Errorf("max_commits can't be negative")
+	}
+	for i := range config.Histories {
+		history := &config.Histories[i]
+		if len(history.Repos) == 0 {
+			return nil, fmt.Errorf("history %d: repos are required", i+1)
+		}
+		if history.MaxCommits < 0 {
+			return nil, fmt.Errorf("history %d: max_commits can't be negative", i+1)
+		}
+		history.HistoryPastLimit = config.Common.HistoryPastLimit
+		if history.HistoryPastLimitString == "" {
+			continue
+		}
+		limit, err := helpers.ParseDuration(history.HistoryPastLimitString)
+		if err != nil {
+			return nil, fmt.Errorf("history %d: %v", i+1, err)
+		}
+		history.HistoryPastLimit = now.Add(-limit)
+	}
+	if config.Common.FetchTimeout, err = helpers.ParseDuration(config.Common.FetchTimeoutString); err != nil {
+		return nil, fmt.Errorf("fetch_timeout: %v", err)
+	}
+	if config.Common.FetchTimeout <= 0 {
+		return nil, fmt.Errorf("fetch_timeout must be positive")
+	}
+	for i := range config.Inspect {
+		inspect := &config.Inspect[i]
+		switch inspect.ObjectFormat {
+		case "", "sha1", "sha256":
+		default:
+			return nil, fmt.Errorf("unknown object_format '%s' of inspect %s", inspect.ObjectFormat, inspect.Type)
+		}
+		if (inspect.Fetch || inspect.Remote != "") && inspect.Type != "path" {
+			return nil, fmt.Errorf("fetch and remote of inspect %s: only repos of path are not fetched by default", inspect.Type)
+		}
+		inspect.FetchTimeout = config.Common.FetchTimeout
+		if inspect.FetchTimeoutString == "" {
+			continue
+		}
+		if inspect.FetchTimeout, err = helpers.ParseDuration(inspect.FetchTimeoutString); err != nil {
+			return nil, fmt.Errorf("fetch_timeout of inspect %s: %v", inspect.Type, err)
+		}
+		if inspect.FetchTimeout <= 0 {
+			return nil, fmt.Errorf("fetch_timeout of inspect %s must be positive", inspect.Type)
+		}
+	}
+	if config.Forward.Interval, err = helpers.ParseDuration(config.Forward.IntervalString); err != nil {
+		return nil, err
+	}
+	if config.Forward.Interval < time.Second {
+		return nil, fmt.Errorf("forward.interval so small")
+	}
+	if config.SMTP.SentToAutor {
+		config.SMTP.SentToAuthor = true
+	}
+	switch config.SMTP.TLSMode {
+	case "starttls", "implicit", "none":
+	default:
+		return nil, fmt.Errorf("unknown smtp.tls_mode '%s'", config.SMTP.TLSMode)
+	}
+	switch config.SMTP.Auth {
+	case "plain", "login":
+	case "xoauth2":
+		if config.SMTP.OAuth2 == nil || config.SMTP.OAuth2.TokenURL == "" || config.SMTP.OAuth2.ClientID == "" {
+			return nil, fmt.Errorf("smtp.oauth2 with token_url and client_id is required for xoauth2")
+		}
+		if config.SMTP.TLSMode == "none" {
+			return nil, fmt.Errorf("xoauth2 can't be used without tls")
+		}
+	default:
+		return nil, fmt.Errorf("unknown smtp.auth '%s'", config.SMTP.Auth)
+	}
+	if config.SMTP.MaxPerMinute < 0 {
+		return nil, fmt.Errorf("smtp.max_per_minute can't be negative")
+	}
+	if config.SMTP.SentToAuthor && len(config.SMTP.AuthorDomains) == 0 {
+		return nil, fmt.Errorf("smtp.author_domains is required with sent_to_author, so leaks are not mailed outside the company")
+	}
+	for i := range config.SMTP.Routes {
+		route := &config.SMTP.Routes[i]
+		if len(route.To) == 0 {
+			return nil, fmt.Errorf("smtp route %d '%s': to is required", i+1, route.Name)
+		}
+		if route.MinSeverity != "" && hungryfox.SeverityLevel(route.MinSeverity) == 0 {
+			return nil, fmt.Errorf("smtp route %d '%s': unknown min_severity '%s'", i+1, route.Name, route.MinSeverity)
+		}
+		if route.Digest == "" {
+			continue
+		}
+		if route.DigestSchedule, err = cron.Parse(route.Digest); err != nil {
+			return nil, fmt.Errorf("smtp route %d '%s': digest: %v", i+1, route.Name, err)
+		}
+		if route.DigestSchedule.Next(now).IsZero() {
+			return nil, fmt.Errorf("smtp route %d '%s': digest '%s' never matches", i+1, route.Name, route.Digest)
+		}
+	}
+	for option, severity := range map[string]string{
+		"smtp.min_severity":        config.SMTP.MinSeverity,
+		"spool.min_severity":       config.Spool.MinSeverity,
+		"json_lines.min_severity":  config.JSONLines.MinSeverity,
+		"archive.min_severity":     config.Archive.MinSeverity,
+		"verify.verified_severity": config.Verify.VerifiedSeverity,
+	} {
+		if severity != "" && hungryfox.SeverityLevel(severity) == 0 {
+			return nil, fmt.Errorf("unknown %s '%s'", option, severity)
+		}
+	}
+	for _, d := range []struct {
+		value  string
+		result *time.Duration
+	}{
+		{config.Retention.IntervalString, &config.Retention.Interval},
+		{config.Retention.ContentAfterString, &config.Retention.ContentAfter},
+		{config.Retention.ResolvedAfterString, &config.Retention.ResolvedAfter},
+		{config.UpdateCheck.IntervalString, &config.UpdateCheck.Interval},
+		{config.JSONLines.MaxAgeString, &config.JSONLines.MaxAge},
+		{config.RateLimit.SummaryIntervalString, &config.RateLimit.SummaryInterval},
+		{config.Archive.IntervalString, &config.Archive.Interval},
+		{config.Archive.RetentionString, &config.Archive.Retention},
+	} {
+		if *d.result, err = helpers.ParseDuration(d.value); err != nil {
+			return nil, err
+		}
+	}
+	if config.Retention.Enable && config.Retention.Interval < time.Minute {
+		return nil, fmt.Errorf("retention.interval so small")
+	}
+	if config.UpdateCheck.URL != "" && config.UpdateCheck.Interval < time.Minute {
+		return nil, fmt.Errorf("update_check.interval so small")
+	}
+	if config.Archive.Enable {
+		if config.Archive.Bucket == "" || config.Archive.Endpoint == "" {
+			return nil, fmt.Errorf("archive: endpoint and bucket are required")
+		}
+		if config.Archive.PendingDir == "" {
+			return nil, fmt.Errorf("archive.pending_dir is required")
+		}
+		if config.Archive.MaxPendingMB < 0 {
+			return nil, fmt.Errorf("archive.max_pending_mb can't be negative")
+		}
+		if config.Archive.Interval < time.Minute {
+			return nil, fmt.Errorf("archive.interval so small")
+		}
+		if config.Archive.Retention != 0 && config.Archive.Retention < config.Archive.Interval {
+			return nil, fmt.Errorf("archive.retention is less than interval")
+		}
+	}
+	if config.Summary.Enable {
+		if config.Summary.CronSchedule, err = cron.Parse(config.Summary.Schedule); err != nil {
+			return nil, fmt.Errorf("summary.schedule: %v", err)
+		}
+		if len(config.Summary.EmailTo) == 0 && config.Summary.SlackWebhookURL == "" {
+			return nil, fmt.Errorf("summary: email_to or slack_webhook_url is required")
+		}
+		if len(config.Summary.EmailTo) > 0 && !config.SMTP.Enable {
+			return nil, fmt.Errorf("summary.email_to requires smtp")
+		}
+		if config.Summary.Top < 1 {
+			return nil, fmt.Errorf("summary.top must be at least 1")
+		}
+	}
+	if config.Audit.Enable {
+		switch config.Audit.Output {
+		case "file":
+			if config.Audit.File == "" {
+				return nil, fmt.Errorf("audit.file is required")
+			}
+		case "syslog":
+			if address := config.Audit.SyslogAddress; address != "" {
+				u, err := url.Parse(address)
+				if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+					return nil, fmt.Errorf("audit.syslog_address must be like udp://host:514 or tcp://host:514")
+				}
+			}
+		default:
+			return nil, fmt.Errorf("audit.output must be file or syslog")
+		}
+	}
+	if config.RateLimit.Enable {
+		if config.RateLimit.SummaryInterval < time.Minute {
+			return nil, fmt.Errorf("rate_limit.summary_interval so small")
+		}
+		if config.RateLimit.PerMinute < 0 || config.RateLimit.Burst < 0 {
+			return nil, fmt.Errorf("rate_limit: per_minute and burst can't be negative")
+		}
+		for name, limit := range config.RateLimit.Senders {
+			if name != "email" && !strings.HasPrefix(name, "webhook:") && !strings.HasPrefix(name, "exec:") {
+				return nil, fmt.Errorf("rate_limit.senders: unknown sender '%s', email, webhook:<name> or exec:<name>", name)
+			}
+			if limit.PerMinute <= 0 || limit.Burst < 0 {
+				return nil, fmt.Errorf("rate_limit.senders.%s: per_minute is required and burst can't be negative", name)
+			}
+		}
+	}
+	if config.Common.DiffWindowKB < 4 {
+		return nil, fmt.Errorf("diff_window_kb must be at least 4")
+	}
+	if config.Common.MaxDiffMB < 0 {
+		return nil, fmt.Errorf("max_diff_mb can't be negative")
+	}
+	if config.Common.ContextLines < 0 {
+		return nil, fmt.Errorf("context_lines can't be negative")
+	}
+	if config.Common.BlobCacheSize < 0 {
+		return nil, fmt.Errorf("blob_cache_size can't be negative")
+	}
+	if config.Common.ScanWorkers < 1 {
+		return nil, fmt.Errorf("scan_workers must be at least 1")
+	}
+	if config.Common.FetchPerHost < 0 {
+		return nil, fmt.Errorf("fetch_per_host can't be negative")
+	}
+	if config.Files.Filter, err = filetype.New(config.Files.Include, config.Files.Exclude); err != nil {
+		return nil, fmt.Errorf("files.%v", err)
+	}
+	fetchLimits := map[string]int{}
+	for host, limit := range config.Common.FetchLimits {
+		if limit < 1 {
+			return nil, fmt.Errorf("fetch_limits of %s must be at least 1", host)
+		}
+		fetchLimits[strings.ToLower(host)] = limit
+	}
+	config.Common.FetchLimits = fetchLimits
+	if config.Queues.Diffs < 1 || config.Queues.Leaks < 1 {
+		return nil, fmt.Errorf("queues: capacity of diffs and leaks must be at least 1")
+	}
+	switch config.Queues.Policy {
+	case "block":
+	case "spill":
+		if config.Queues.SpillDir == "" {
+			return nil, fmt.Errorf("queues.spill_dir is required for spill policy")
+		}
+	default:
+		return nil, fmt.Errorf("unknown queues.policy '%s', block or spill", config.Queues.Policy)
+	}
+	if config.JSONLines.Enable && config.JSONLines.Path == "" {
+		return nil, fmt.Errorf("json_lines.path is required")
+	}
+	if config.JSONLines.MaxSizeMB < 0 || config.JSONLines.MaxFiles < 0 {
+		return nil, fmt.Errorf("json_lines: max_size_mb and max_files can't be negative")
+	}
+	hookNames := map[string]bool{}
+	for i := range config.FindingWebhooks {
+		hook := &config.FindingWebhooks[i]
+		if hook.Retries == 0 {
+			hook.Retries = 3
+		} else if hook.Retries < 0 {
+			hook.Retries = 0
+		}
+		if hook.Name == "" || hook.URL == "" {
+			return nil, fmt.Errorf("name and url of finding_webhooks are required")
+		}
+		if hookNames[hook.Name] {
+			return nil, fmt.Errorf("duplicate finding webhook '%s'", hook.Name)
+		}
+		hookNames[hook.Name] = true
+		if (hook.CertFile == "") != (hook.KeyFile == "") {
+			return nil, fmt.Errorf("cert_file and key_file of finding webhook '%s' are required together", hook.Name)
+		}
+		if hook.MinSeverity != "" && hungryfox.SeverityLevel(hook.MinSeverity) == 0 {
+			return nil, fmt.Errorf("unknown min_severity '%s' of finding webhook '%s'", hook.MinSeverity, hook.Name)
+		}
+	}
+	execNames := map[string]bool{}
+	for i := range config.ExecSenders {
+		sender := &config.ExecSenders[i]
+		if sender.Name == "" || len(sender.Command) == 0 {
+			return nil, fmt.Errorf("name and command of exec_senders are required")
+		}
+		if execNames[sender.Name] {
+			return nil, fmt.Errorf("duplicate exec sender '%s'", sender.Name)
+		}
+		execNames[sender.Name] = true
+		if sender.TimeoutString != "" {
+			if sender.Timeout, err = helpers.ParseDuration(sender.TimeoutString); err != nil {
+				return nil, fmt.Errorf("exec sender '%s': %v", sender.Name, err)
+			}
+		}
+		if sender.MinSeverity != "" && hungryfox.SeverityLevel(sender.MinSeverity) == 0 {
+			return nil, fmt.Errorf("unknown min_severity '%s' of exec sender '%s'", sender.MinSeverity, sender.Name)
+		}
+	}
+	for _, ip := range config.Identity.EgressIPs {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("bad identity.egress_ips address '%s'", ip)
+		}
+	}
+	if config.Verify.Rate < 1 {
+		return nil, fmt.Errorf("verify.rate must be positive")
+	}
+	if config.Anomaly.MinLeaks < 1 || config.Anomaly.Sigma < 0 || config.Anomaly.Window < 1 {
+		return nil, fmt.Errorf("anomaly: min_leaks and window must be positive, sigma can't be negative")
+	}
+	if config.Common.HashOnly && config.Common.HashKeyFile == "" {
+		return nil, fmt.Errorf("hash_key_file is required for hash_only")
+	}
+	if config.Common.HashOnly && config.Verify.Enable {
+		return nil, fmt.Errorf("verify can't be used with hash_only, it sends secrets to their providers")
+	}
+	if config.Spool.Enable && config.Spool.Dir == "" {
+		return nil, fmt.Errorf("spool.dir is required")
+	}
+	if config.API.UI && config.API.PublicURL == "" {
+		return nil, fmt.Errorf("api.public_url is required for ui")
+	}
+	if config.Common.Role == RoleCentral && !config.API.hasScope("ingest") {
+		return nil, fmt.Errorf("role central receives leaks of edge instances, a token with ingest scope is required for it")
+	}
+	if config.API.UI && !config.API.hasScope("leaks") {
+		return nil, fmt.Errorf("api.ui shows leaks, a token with leaks scope is required for it")
+	}
+	// paths of files in git are separated by slash, so filters written with backslash on windows match too
+	for i := range config.Common.FullScanPaths {
+		config.Common.FullScanPaths[i] = filepath.ToSlash(config.Common.FullScanPaths[i])
+	}
+	for i, a := range config.Allowlist {
+		if len(a.Files) == 0 && len(a.Repos) == 0 && len(a.Patterns) == 0 && len(a.Authors) == 0 {
+			return nil, fmt.Errorf("allowlist rule %d '%s': files, repos, patterns or authors is required", i+1, a.Name)
+		}
+		for j := range a.Files {
+			a.Files[j] = filepath.ToSlash(a.Files[j])
+		}
+	}
+	for i, e := range config.Escalate {
+		if len(e.Authors) == 0 {
+			return nil, fmt.Errorf("escalate rule %d '%s': authors is required", i+1, e.Name)
+		}
+		if hungryfox.SeverityLevel(e.Severity) == 0 {
+			return nil, fmt.Errorf("escalate rule %d '%s': unknown severity '%s'", i+1, e.Name, e.Severity)
+		}
+	}
+	for _, c := range config.Credentials {
+		if c.Host == "" {
+			return nil, fmt.Errorf("credentials: host is required")
+		}
+	}
+	if config.Vault.TokenVault != "" {
+		return nil, fmt.Errorf("vault.token_vault can't be used, token of vault is set by token, token_env, token_file or VAULT_TOKEN")
+	}
+	if config.Vault.Refresh, err = helpers.ParseDuration(config.Vault.RefreshString); err != nil {
+		return nil, fmt.Errorf("vault.refresh: %v", err)
+	}
+	for _, ref := range config.VaultRefs() {
+		if config.Vault.Address == "" {
+			return nil, fmt.Errorf("vault.address is required for token_vault and password_vault")
+		}
+		if _, _, err := vault.ParseRef(ref); err != nil {
+			return nil, err
+		}
+	}
 	return config, nil
 }
 
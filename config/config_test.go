@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetentionExcluded(t *testing.T) {
+	Convey("stores with secrets which retention doesn't purge", t, func() {
+		conf, err := ParseConfig([]byte(`retention:
+  enable: true
+  content_after: 90d
+json_lines:
+  enable: true
+  path: /tmp/leaks.jsonl
+archive:
+  enable: true
+  bucket: leaks
+  pending_dir: /tmp/archive
+  retention: 30d
+`))
+		So(err, ShouldBeNil)
+		So(conf.RetentionExcluded(), ShouldResemble, []string{"json_lines: rotated files are kept, set max_age and max_files"})
+
+		Convey("nothing is excluded without content_after", func() {
+			conf.Retention.ContentAfter = 0
+			So(conf.RetentionExcluded(), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// envRe - ${VAR} or ${VAR:-default}, $${VAR} is escaped
+var envRe = regexp.MustCompile(`\$(\$?)\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv - replace ${VAR} with environment variable and ${VAR:-default} with default if variable is unset or empty,
+// $${VAR} is kept as ${VAR}. Unset variable without default is an error, so secrets are never silently empty.
+// Comments are not expanded and values are inserted as yaml scalars, so a value with quotes, colons or # can't change
+// the structure of config: in quoted strings it is escaped, a plain scalar is quoted if the value is not read back as is
+func expandEnv(configYaml []byte) ([]byte, error) {
+	lines := strings.Split(string(configYaml), "\n")
+	for i, line := range lines {
+		expanded, err := expandLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		lines[i] = expanded
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// expandLine - expand variables of one line before its comment
+func expandLine(line string) (string, error) {
+	if !strings.Contains(line, "${") {
+		return line, nil
+	}
+	s := scanLine(line)
+	result, pos := "", 0
+	for _, loc := range envRe.FindAllStringIndex(line[:s.comment], -1) {
+		if loc[0] < pos {
+			continue
+		}
+		state := s.at(loc[0])
+		if state.quote != 0 {
+			value, err := expandMatch(line[loc[0]:loc[1]])
+			if err != nil {
+				return "", err
+			}
+			if state.quote == '"' {
+				quoted := strconv.Quote(value)
+				value = quoted[1 : len(quoted)-1]
+			} else if strings.ContainsAny(value, "\r\n") {
+				return "", fmt.Errorf("value of %s has new line and can't be in single quotes, use double quotes", envRe.FindStringSubmatch(line[loc[0]:loc[1]])[2])
+			} else {
+				value = strings.Replace(value, "'", "''", -1)
+			}
+			result += line[pos:loc[0]] + value
+			pos = loc[1]
+			continue
+		}
+		// the whole plain scalar is expanded, it is quoted if it is not read back as the same string
+		start, end := state.start, s.plainEnd(loc[0], state.flow)
+		if start < pos {
+			start = pos
+		}
+		value, err := expandAll(line[start:end])
+		if err != nil {
+			return "", err
+		}
+		trimmed := strings.TrimRight(value, " \t")
+		if !plainSafe(trimmed, state.flow) {
+			value = strconv.Quote(trimmed) + value[len(trimmed):]
+		}
+		result += line[pos:start] + value
+		pos = end
+	}
+	return result + line[pos:], nil
+}
+
+// expandAll - expand every variable of s
+func expandAll(s string) (string, error) {
+	var err error
+	result := envRe.ReplaceAllStringFunc(s, func(match string) string {
+		value, e := expandMatch(match)
+		if e != nil && err == nil {
+			err = e
+		}
+		return value
+	})
+	return result, err
+}
+
+// expandMatch - value of one ${VAR}, ${VAR:-default} or $${VAR}
+func expandMatch(match string) (string, error) {
+	m := envRe.FindStringSubmatch(match)
+	if m[1] != "" {
+		return match[1:], nil
+	}
+	value, ok := os.LookupEnv(m[2])
+	if value == "" && m[3] != "" {
+		return m[4], nil
+	}
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", m[2])
+	}
+	return value, nil
+}
+
+// plainSafe - value is read back from plain scalar as the same string or number
+func plainSafe(value string, flow bool) bool {
+	if value == "" {
+		return true
+	}
+	if strings.TrimSpace(value) != value || (flow && strings.ContainsAny(value, ",[]{}")) {
+		return false
+	}
+	parsed := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte("v: "+value), &parsed); err != nil || len(parsed) != 1 {
+		return false
+	}
+	switch v := parsed["v"].(type) {
+	case string, int, int64, uint64, float64, bool:
+		return fmt.Sprint(v) == value
+	}
+	return false
+}
+
+// lineScan - quoting of every byte of line before its comment
+type lineScan struct {
+	line    string
+	states  []scalarState
+	comment int // start of comment or length of line
+}
+
+// scalarState - byte is in quoted string if quote is set, otherwise in plain scalar starting at start
+type scalarState struct {
+	quote byte
+	start int
+	flow  bool // inside [] or {}
+}
+
+func scanLine(line string) *lineScan {
+	s := &lineScan{line: line, states: make([]scalarState, len(line)), comment: len(line)}
+	vars := map[int]int{}
+	for _, loc := range envRe.FindAllStringIndex(line, -1) {
+		vars[loc[0]] = loc[1]
+	}
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if end, ok := vars[i]; ok {
+			// braces of variable are not flow collection
+			for ; i < end; i++ {
+				s.states[i] = scalarState{quote: quote, start: start, flow: depth > 0}
+			}
+			i--
+			continue
+		}
+		switch {
+		case quote == '"' && c == '\\':
+			s.states[i] = scalarState{quote: quote}
+			if i+1 < len(line) {
+				i++
+				s.states[i] = scalarState{quote: quote}
+			}
+			continue
+		case quote == '\'' && c == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			s.states[i], s.states[i+1] = scalarState{quote: quote}, scalarState{quote: quote}
+			i++
+			continue
+		case quote != 0 && c == quote:
+			s.states[i] = scalarState{quote: quote}
+			quote = 0
+			continue
+		case quote != 0:
+			s.states[i] = scalarState{quote: quote}
+			continue
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			s.comment = i
+			s.states = s.states[:i]
+			return s
+		case (c == '"' || c == '\'') && strings.TrimSpace(line[start:i]) == "":
+			quote = c
+			s.states[i] = scalarState{quote: quote}
+			continue
+		case c == '[' || c == '{':
+			depth++
+			start = i + 1
+		case (c == ']' || c == '}') && depth > 0:
+			depth--
+			start = i + 1
+		case c == ',' && depth > 0:
+			start = i + 1
+		case c == ':' && (i+1 == len(line) || line[i+1] == ' ' || line[i+1] == '\t'):
+			start = i + 1
+		case c == '-' && (i+1 == len(line) || line[i+1] == ' ') && strings.TrimSpace(line[start:i]) == "":
+			start = i + 1
+		}
+		s.states[i] = scalarState{start: start, flow: depth > 0}
+	}
+	return s
+}
+
+// at - state of byte i, the start of plain scalar skips leading spaces
+func (s *lineScan) at(i int) scalarState {
+	state := s.states[i]
+	if state.quote == 0 {
+		for state.start < i && (s.line[state.start] == ' ' || s.line[state.start] == '\t') {
+			state.start++
+		}
+	}
+	return state
+}
+
+// plainEnd - end of plain scalar containing byte i
+func (s *lineScan) plainEnd(i int, flow bool) int {
+	for j := i; j < s.comment; j++ {
+		c := s.line[j]
+		if envInside(s.line, j) {
+			continue
+		}
+		if flow && (c == ',' || c == ']' || c == '}') {
+			return j
+		}
+		if c == ':' && (j+1 == len(s.line) || s.line[j+1] == ' ') {
+			return j
+		}
+	}
+	return s.comment
+}
+
+// envInside - byte i is inside of ${...}
+func envInside(line string, i int) bool {
+	for _, loc := range envRe.FindAllStringIndex(line, -1) {
+		if loc[0] < i && i < loc[1] {
+			return true
+		}
+	}
+	return false
+}
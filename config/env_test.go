@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("HUNGRYFOX_TEST_PASSWORD", "qwerty")
+	os.Setenv("HUNGRYFOX_TEST_EMPTY", "")
+	defer os.Unsetenv("HUNGRYFOX_TEST_PASSWORD")
+	defer os.Unsetenv("HUNGRYFOX_TEST_EMPTY")
+
+	Convey("variables are replaced anywhere", t, func() {
+		result, err := expandEnv([]byte("smtp:\n  password: ${HUNGRYFOX_TEST_PASSWORD}\n  host: mail-${HUNGRYFOX_TEST_EMPTY}.example.com\n"))
+		So(err, ShouldBeNil)
+		So(string(result), ShouldEqual, "smtp:\n  password: qwerty\n  host: mail-.example.com\n")
+	})
+
+	Convey("default is used for unset and empty variables", t, func() {
+		result, err := expandEnv([]byte("a: ${HUNGRYFOX_TEST_UNSET:-25}\nb: ${HUNGRYFOX_TEST_EMPTY:-x}\nc: ${HUNGRYFOX_TEST_PASSWORD:-x}\nd: ${HUNGRYFOX_TEST_UNSET:-}"))
+		So(err, ShouldBeNil)
+		So(string(result), ShouldEqual, "a: 25\nb: x\nc: qwerty\nd: ")
+	})
+
+	Convey("escaped and other dollars are kept", t, func() {
+		result, err := expandEnv([]byte(`content: $${HUNGRYFOX_TEST_PASSWORD} ^secret$ ${1}`))
+		So(err, ShouldBeNil)
+		So(string(result), ShouldEqual, `content: ${HUNGRYFOX_TEST_PASSWORD} ^secret$ ${1}`)
+	})
+
+	Convey("comments are not expanded", t, func() {
+		result, err := expandEnv([]byte("# token: ${HUNGRYFOX_TEST_UNSET}\na: ${HUNGRYFOX_TEST_PASSWORD} # ${HUNGRYFOX_TEST_UNSET}\nb: \"x # ${HUNGRYFOX_TEST_PASSWORD}\""))
+		So(err, ShouldBeNil)
+		So(string(result), ShouldEqual, "# token: ${HUNGRYFOX_TEST_UNSET}\na: qwerty # ${HUNGRYFOX_TEST_UNSET}\nb: \"x # qwerty\"")
+	})
+
+	Convey("values are inserted as yaml scalars", t, func() {
+		os.Setenv("HUNGRYFOX_TEST_PASSWORD", "a: b # 'c' \"d\"\ne")
+		defer os.Setenv("HUNGRYFOX_TEST_PASSWORD", "qwerty")
+		configYaml := []byte(`smtp:
+  password: ${HUNGRYFOX_TEST_PASSWORD}
+  username: x-${HUNGRYFOX_TEST_PASSWORD}
+  host: "${HUNGRYFOX_TEST_PASSWORD}"
+  from: '${HUNGRYFOX_TEST_EMPTY:-'x'}'
+  port: ${HUNGRYFOX_TEST_UNSET:-2525}
+exec:
+  - name: test
+    command: [echo, ${HUNGRYFOX_TEST_PASSWORD}, ${HUNGRYFOX_TEST_EMPTY:-x}]
+`)
+		result, err := expandEnv(configYaml)
+		So(err, ShouldBeNil)
+		So(strings.Count(string(result), "\n"), ShouldEqual, strings.Count(string(configYaml), "\n"))
+		conf := struct {
+			SMTP struct {
+				Password string
+				Username string
+				Host     string
+				From     string
+				Port     int
+			}
+			Exec []struct {
+				Command []string
+			}
+		}{}
+		So(yaml.Unmarshal(result, &conf), ShouldBeNil)
+		value := "a: b # 'c' \"d\"\ne"
+		So(conf.SMTP.Password, ShouldEqual, value)
+		So(conf.SMTP.Username, ShouldEqual, "x-"+value)
+		So(conf.SMTP.Host, ShouldEqual, value)
+		So(conf.SMTP.From, ShouldEqual, "'x'")
+		So(conf.SMTP.Port, ShouldEqual, 2525)
+		So(conf.Exec, ShouldHaveLength, 1)
+		So(conf.Exec[0].Command, ShouldResemble, []string{"echo", value, "x"})
+	})
+
+	Convey("new line can't be in single quotes", t, func() {
+		os.Setenv("HUNGRYFOX_TEST_PASSWORD", "a\nb")
+		defer os.Setenv("HUNGRYFOX_TEST_PASSWORD", "qwerty")
+		_, err := expandEnv([]byte("a: '${HUNGRYFOX_TEST_PASSWORD}'"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "line 1: value of HUNGRYFOX_TEST_PASSWORD has new line and can't be in single quotes, use double quotes")
+	})
+
+	Convey("unset variable without default is an error with line", t, func() {
+		_, err := ParseConfig([]byte("smtp:\n  password: ${HUNGRYFOX_TEST_UNSET}\n"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "line 2: environment variable HUNGRYFOX_TEST_UNSET is not set")
+	})
+
+	Convey("config is parsed after expansion", t, func() {
+		conf, err := ParseConfig([]byte("smtp:\n  password: ${HUNGRYFOX_TEST_PASSWORD}\n  port: ${HUNGRYFOX_TEST_UNSET:-2525}\n"))
+		So(err, ShouldBeNil)
+		So(conf.SMTP.Password, ShouldEqual, "qwerty")
+		So(conf.SMTP.Port, ShouldEqual, 2525)
+	})
+}
@@ -0,0 +1,22 @@
+//go:build go1.18
+// +build go1.18
+
+package config
+
+import "testing"
+
+func FuzzParseConfig(f *testing.F) {
+	f.Add([]byte("common:\n  log_level: debug\n  scan_interval: 1m\n"))
+	f.Add([]byte("common:\nsmtp:\napi:\nwebhook:\n"))
+	f.Add([]byte("common: {removed_repos: null, history_limit: 1y2d}\n"))
+	f.Add([]byte("inspect: [{type: path, paths: ['/tmp/*']}]\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		conf, err := ParseConfig(data)
+		if err != nil {
+			return
+		}
+		if conf.Common == nil || conf.SMTP == nil || conf.API == nil || conf.Webhook == nil || conf.Common.RemovedRepos == nil {
+			t.Fatal("config section is nil")
+		}
+	})
+}
@@ -0,0 +1,150 @@
+// Package cron - standard five field cron expressions of scan schedules
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shortcuts - predefined schedules
+var shortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// field - bounds of field of expression
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // 0 and 7 are sunday
+}
+
+// Schedule - parsed expression, allowed values of every field
+type Schedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	// domAny and dowAny - day matches by the other field only, otherwise any of them matches as in vixie cron
+	domAny, dowAny bool
+}
+
+// Parse - schedule of "minute hour day month weekday" with *, lists, ranges and steps or @hourly, @daily, @weekly, @monthly
+func Parse(expression string) (*Schedule, error) {
+	spec := strings.TrimSpace(expression)
+	if s, ok := shortcuts[spec]; ok {
+		spec = s
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron '%s' must have 5 fields: minute hour day month weekday", expression)
+	}
+	values := make([]map[int]bool, len(fields))
+	for i, part := range parts {
+		v, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron '%s': %v", expression, err)
+		}
+		values[i] = v
+	}
+	if values[4][7] {
+		values[4][0] = true
+	}
+	return &Schedule{
+		minute: values[0],
+		hour:   values[1],
+		dom:    values[2],
+		month:  values[3],
+		dow:    values[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+func parseField(part string, f field) (map[int]bool, error) {
+	result := map[int]bool{}
+	for _, item := range strings.Split(part, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("bad step of %s '%s'", f.name, item)
+			}
+			item = item[:i]
+		}
+		from, to := f.min, f.max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err1, err2 error
+			from, err1 = strconv.Atoi(bounds[0])
+			to, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || from > to {
+				return nil, fmt.Errorf("bad range of %s '%s'", f.name, item)
+			}
+		default:
+			value, err := strconv.Atoi(item)
+			if err != nil {
+				return nil, fmt.Errorf("bad %s '%s'", f.name, item)
+			}
+			from, to = value, value
+			if step > 1 {
+				to = f.max
+			}
+		}
+		if from < f.min || to > f.max {
+			return nil, fmt.Errorf("%s '%s' is out of %d-%d", f.name, item, f.min, f.max)
+		}
+		for v := from; v <= to; v += step {
+			result[v] = true
+		}
+	}
+	return result, nil
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// Next - the first time of schedule after t in location of t
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// every combination repeats within years, 5 years cover february 29
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !s.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
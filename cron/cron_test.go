@@ -0,0 +1,36 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNext(t *testing.T) {
+	start := time.Date(2018, 7, 1, 10, 30, 15, 0, time.UTC) // sunday
+	Convey("next time of schedule", t, func() {
+		for expression, expected := range map[string]time.Time{
+			"*/10 * * * *":     time.Date(2018, 7, 1, 10, 40, 0, 0, time.UTC),
+			"0 * * * *":        time.Date(2018, 7, 1, 11, 0, 0, 0, time.UTC),
+			"@daily":           time.Date(2018, 7, 2, 0, 0, 0, 0, time.UTC),
+			"0 3 * * 6":        time.Date(2018, 7, 7, 3, 0, 0, 0, time.UTC),
+			"0 3 * * 1-5":      time.Date(2018, 7, 2, 3, 0, 0, 0, time.UTC),
+			"15 2 1 * *":       time.Date(2018, 8, 1, 2, 15, 0, 0, time.UTC),
+			"0 0 29 2 *":       time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC),
+			"30 10 * * 7":      time.Date(2018, 7, 8, 10, 30, 0, 0, time.UTC),
+			"0 12 15 * 1":      time.Date(2018, 7, 2, 12, 0, 0, 0, time.UTC),
+			"0,45 10,11 * * *": time.Date(2018, 7, 1, 10, 45, 0, 0, time.UTC),
+		} {
+			s, err := Parse(expression)
+			So(err, ShouldBeNil)
+			So(s.Next(start), ShouldResemble, expected)
+		}
+	})
+	Convey("bad expressions", t, func() {
+		for _, expression := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@often"} {
+			_, err := Parse(expression)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
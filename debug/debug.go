@@ -0,0 +1,144 @@
+// Package debug - opt-in listener with pprof profiles and runtime stats of queues and goroutines
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/queue"
+
+	"github.com/rs/zerolog"
+)
+
+// StatsPath - runtime stats in json
+const StatsPath = "/debug/stats"
+
+// Queue - depth and backpressure of queue between services
+type Queue func() queue.Stats
+
+// Server - serves /debug/pprof/ and /debug/stats
+type Server struct {
+	Listen string
+	Queues map[string]Queue
+	// Scan - repo which is being scanned, nil if there is none or scanner is not running
+	Scan func() *hungryfox.Repo
+	// Costs - time spent by families of rules, not shown if nil
+	Costs hungryfox.IRuleCosts
+	Log   zerolog.Logger
+
+	server *http.Server
+}
+
+type memoryStats struct {
+	Alloc        uint64     `json:"alloc"`
+	HeapInuse    uint64     `json:"heap_inuse"`
+	HeapObjects  uint64     `json:"heap_objects"`
+	Sys          uint64     `json:"sys"`
+	NumGC        uint32     `json:"num_gc"`
+	PauseTotalNs uint64     `json:"pause_total_ns"`
+	LastGC       *time.Time `json:"last_gc,omitempty"`
+}
+
+type scanStats struct {
+	Repo      string    `json:"repo"`
+	StartedAt time.Time `json:"started_at"`
+	Progress  int       `json:"progress,omitempty"` // scanned commits in permille, empty if unknown
+}
+
+type stats struct {
+	Goroutines int           `json:"goroutines"`
+	Memory     memoryStats   `json:"memory"`
+	Queues     []queue.Stats `json:"queues"`
+	Scan       *scanStats    `json:"scan,omitempty"`
+	// RuleCosts - time spent by families of rules on repo groups
+	RuleCosts []hungryfox.RuleCost `json:"rule_costs,omitempty"`
+}
+
+// Start - start listen
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.Listen)
+	if err != nil {
+		return err
+	}
+	s.server = &http.Server{Handler: s.handler()}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.Log.Error().Str("error", err.Error()).Str("service", "debug").Msg("serve failed")
+		}
+	}()
+	return nil
+}
+
+// Stop - stop listen
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	// named profiles like heap and goroutine are served by index
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc(StatsPath, s.handleStats)
+	return mux
+}
+
+func (s *Server) stats() stats {
+	m := runtime.MemStats{}
+	runtime.ReadMemStats(&m)
+	result := stats{
+		Goroutines: runtime.NumGoroutine(),
+		Memory: memoryStats{
+			Alloc:        m.Alloc,
+			HeapInuse:    m.HeapInuse,
+			HeapObjects:  m.HeapObjects,
+			Sys:          m.Sys,
+			NumGC:        m.NumGC,
+			PauseTotalNs: m.PauseTotalNs,
+		},
+		Queues: []queue.Stats{},
+	}
+	if m.LastGC > 0 {
+		lastGC := time.Unix(0, int64(m.LastGC)).UTC()
+		result.Memory.LastGC = &lastGC
+	}
+	for name, q := range s.Queues {
+		queueStats := q()
+		queueStats.Name = name
+		result.Queues = append(result.Queues, queueStats)
+	}
+	sort.Slice(result.Queues, func(i, j int) bool { return result.Queues[i].Name < result.Queues[j].Name })
+	if s.Scan != nil {
+		if r := s.Scan(); r != nil {
+			result.Scan = &scanStats{Repo: r.Location.URL, StartedAt: r.Scan.StartTime}
+			if r.Repo != nil {
+				if progress := r.Repo.GetProgress(); progress >= 0 {
+					result.Scan.Progress = progress
+				}
+			}
+		}
+	}
+	if s.Costs != nil {
+		result.RuleCosts = s.Costs.RuleCosts()
+	}
+	return result
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(s.stats())
+}
@@ -0,0 +1,44 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/queue"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServer(t *testing.T) {
+	started := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	s := &Server{
+		Queues: map[string]Queue{"diffs": func() queue.Stats { return queue.Stats{Length: 1, Capacity: 10, Blocks: 2} }},
+		Scan: func() *hungryfox.Repo {
+			return &hungryfox.Repo{Location: hungryfox.RepoLocation{URL: "https://github.com/a/b"}, Scan: hungryfox.ScanStatus{StartTime: started}}
+		},
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	Convey("stats of queues and current scan", t, func() {
+		w := get(StatsPath)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		result := stats{}
+		So(json.Unmarshal(w.Body.Bytes(), &result), ShouldBeNil)
+		So(result.Goroutines, ShouldBeGreaterThan, 0)
+		So(result.Memory.Sys, ShouldBeGreaterThan, 0)
+		So(result.Queues, ShouldResemble, []queue.Stats{{Name: "diffs", Length: 1, Capacity: 10, Blocks: 2}})
+		So(result.Scan.Repo, ShouldEqual, "https://github.com/a/b")
+		So(result.Scan.StartedAt, ShouldResemble, started)
+	})
+	Convey("pprof profiles", t, func() {
+		So(get("/debug/pprof/").Code, ShouldEqual, http.StatusOK)
+		So(get("/debug/pprof/goroutine?debug=1").Body.String(), ShouldContainSubstring, "goroutine profile")
+	})
+}
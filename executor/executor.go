@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout     = 10 * time.Minute
+	defaultMaxOutput   = 512 * 1024 * 1024
+	defaultCPUTime     = 30 * time.Minute
+	defaultMaxMemory   = 8 * 1024 * 1024 * 1024
+	defaultMaxFileSize = 32 * 1024 * 1024 * 1024
+)
+
+// ErrOutputLimit - command wrote more than MaxOutput bytes
+var ErrOutputLimit = errors.New("output limit exceeded")
+
+// Executor - runs external commands without shell, with timeout, output limit and clean environment
+type Executor struct {
+	Timeout   time.Duration
+	MaxOutput int
+	// CPUTime, MaxMemory and MaxFileSize - rlimits of command on unix, threads of git count in CPUTime together,
+	// MaxMemory limits data segment and anonymous mappings, so mapped pack files don't count
+	CPUTime     time.Duration
+	MaxMemory   int64
+	MaxFileSize int64
+	// Env - names of variables which are passed from the current environment in addition to PATH
+	Env []string
+}
+
+// Default - executor with default limits
+var Default = &Executor{}
+
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.limit {
+		return 0, ErrOutputLimit
+	}
+	return b.buf.Write(p)
+}
+
+func (e *Executor) environment() []string {
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + os.TempDir(),
+		"LC_ALL=C",
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_NOSYSTEM=1",
+	}
+	for _, name := range append(platformEnv, e.Env...) {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// Run - run command in dir and return its stdout
+func (e *Executor) Run(dir string, name string, args ...string) ([]byte, error) {
+	for _, arg := range append([]string{name}, args...) {
+		if strings.ContainsRune(arg, 0) {
+			return nil, fmt.Errorf("bad argument %q", arg)
+		}
+	}
+	timeout, maxOutput := e.Timeout, e.MaxOutput
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if maxOutput <= 0 {
+		maxOutput = defaultMaxOutput
+	}
+	cmd := command(e.limits(), name, args...)
+	cmd.Dir = dir
+	cmd.Env = e.environment()
+	stdout := &limitedBuffer{limit: maxOutput}
+	stderr := &limitedBuffer{limit: 64 * 1024}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	setProcAttr(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return stdout.buf.Bytes(), fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.buf.String()))
+		}
+		return stdout.buf.Bytes(), nil
+	case <-timer.C:
+		kill(cmd)
+		<-done
+		return nil, fmt.Errorf("%s %s: timeout after %s", name, strings.Join(args, " "), timeout)
+	}
+}
+
+type limits struct {
+	cpuTime  time.Duration
+	memory   int64
+	fileSize int64
+}
+
+// limits - rlimits of command with defaults
+func (e *Executor) limits() limits {
+	l := limits{cpuTime: e.CPUTime, memory: e.MaxMemory, fileSize: e.MaxFileSize}
+	if l.cpuTime <= 0 {
+		l.cpuTime = defaultCPUTime
+	}
+	if l.memory <= 0 {
+		l.memory = defaultMaxMemory
+	}
+	if l.fileSize <= 0 {
+		l.fileSize = defaultMaxFileSize
+	}
+	return l
+}
+
+// Git - run git command in dir
+func (e *Executor) Git(dir string, args ...string) ([]byte, error) {
+	return e.Run(dir, "git", args...)
+}
@@ -0,0 +1,63 @@
+//go:build !windows
+// +build !windows
+
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRun(t *testing.T) {
+	Convey("returns stdout", t, func() {
+		out, err := Default.Run("", "echo", "$HOME;", "`id`")
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, "$HOME; `id`\n")
+	})
+	Convey("kills command on timeout", t, func() {
+		e := &Executor{Timeout: 100 * time.Millisecond}
+		start := time.Now()
+		_, err := e.Run("", "sleep", "10")
+		So(err, ShouldNotBeNil)
+		So(time.Since(start), ShouldBeLessThan, 5*time.Second)
+	})
+	Convey("limits output", t, func() {
+		e := &Executor{MaxOutput: 4}
+		_, err := e.Run("", "echo", "too long output")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestLimits(t *testing.T) {
+	Convey("rlimits are set for command", t, func() {
+		e := &Executor{CPUTime: 90 * time.Second, MaxMemory: 1024 * 1024 * 1024, MaxFileSize: 1024 * 1024}
+		for limit, expected := range map[string]string{"-t": "90\n", "-d": "1048576\n", "-f": "2048\n"} {
+			out, err := e.Run("", "sh", "-c", "ulimit "+limit)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, expected)
+		}
+	})
+	Convey("command is killed over cpu time", t, func() {
+		e := &Executor{CPUTime: time.Second, Timeout: 20 * time.Second}
+		start := time.Now()
+		_, err := e.Run("", "sh", "-c", "while :; do :; done")
+		So(err, ShouldNotBeNil)
+		So(time.Since(start), ShouldBeLessThan, 10*time.Second)
+	})
+	Convey("file can't grow over max file size", t, func() {
+		dir, _ := ioutil.TempDir("", "executor")
+		defer os.RemoveAll(dir)
+		e := &Executor{MaxFileSize: 4096}
+		_, err := e.Run(dir, "dd", "if=/dev/zero", "of=big", "bs=1024", "count=64")
+		So(err, ShouldNotBeNil)
+	})
+	Convey("arguments are not parsed by shell", t, func() {
+		out, err := Default.Run("", "printf", "%s|", "a b", "$(id)", "'")
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, "a b|$(id)|'|")
+	})
+}
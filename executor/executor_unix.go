@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// platformEnv - variables which are always passed from the current environment
+var platformEnv []string
+
+// limitScript - sh sets rlimits and replaces itself with the command, a limit above the hard limit of
+// hungryfox is left as it is, command and its arguments are positional parameters and are never parsed by sh
+const limitScript = `ulimit -t %d 2>/dev/null; ulimit -d %d 2>/dev/null; ulimit -f %d 2>/dev/null; exec "$@"`
+
+// command - command with rlimits, ulimit takes cpu in seconds, memory in kilobytes and file size in 512 byte blocks
+func command(l limits, name string, args ...string) *exec.Cmd {
+	cpu := int64(l.cpuTime.Seconds())
+	if cpu < 1 {
+		cpu = 1
+	}
+	script := fmt.Sprintf(limitScript, cpu, l.memory/1024, l.fileSize/512)
+	return exec.Command("/bin/sh", append([]string{"-c", script, "sh", name}, args...)...)
+}
+
+func setProcAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// kill - kill whole process group so children of the command don't hang
+func kill(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,20 @@
+package executor
+
+import "os/exec"
+
+// platformEnv - git for windows can't resolve hosts and create temp files without them
+var platformEnv = []string{"SYSTEMROOT", "TEMP", "TMP"}
+
+// command - windows has no rlimits, command runs with timeout and output limit only
+func command(l limits, name string, args ...string) *exec.Cmd {
+	return exec.Command(name, args...)
+}
+
+func setProcAttr(cmd *exec.Cmd) {}
+
+func kill(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Kill()
+}
@@ -0,0 +1,173 @@
+// Package filetype - include and exclude files from scanning by extension, name or MIME type,
+// so generated assets like minified scripts, source maps and lock files don't reach searcher
+package filetype
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// sniffSize - bytes of content which are used to detect MIME type of file with unknown extension
+const sniffSize = 512
+
+// mimeTypes - MIME types of extensions, the table is built in so rules match the same files on every host
+// unlike system MIME tables, types of other extensions are sniffed from content
+var mimeTypes = map[string]string{
+	".7z":    "application/x-7z-compressed",
+	".avif":  "image/avif",
+	".bmp":   "image/bmp",
+	".bz2":   "application/x-bzip2",
+	".css":   "text/css",
+	".csv":   "text/csv",
+	".eot":   "application/vnd.ms-fontobject",
+	".gif":   "image/gif",
+	".gz":    "application/gzip",
+	".htm":   "text/html",
+	".html":  "text/html",
+	".ico":   "image/x-icon",
+	".jar":   "application/java-archive",
+	".jpeg":  "image/jpeg",
+	".jpg":   "image/jpeg",
+	".js":    "text/javascript",
+	".json":  "application/json",
+	".md":    "text/markdown",
+	".mjs":   "text/javascript",
+	".mp3":   "audio/mpeg",
+	".mp4":   "video/mp4",
+	".ogg":   "audio/ogg",
+	".otf":   "font/otf",
+	".pdf":   "application/pdf",
+	".png":   "image/png",
+	".rar":   "application/vnd.rar",
+	".svg":   "image/svg+xml",
+	".tar":   "application/x-tar",
+	".tif":   "image/tiff",
+	".tiff":  "image/tiff",
+	".ttf":   "font/ttf",
+	".txt":   "text/plain",
+	".wasm":  "application/wasm",
+	".wav":   "audio/wav",
+	".webm":  "video/webm",
+	".webp":  "image/webp",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".xml":   "text/xml",
+	".zip":   "application/zip",
+}
+
+// Filter - only files matching include rules are scanned (all files if there are none), files matching exclude rules are never scanned.
+// Rule with slash is MIME type like image/* or application/json, rule starting with dot is extension like .svg or .min.js,
+// other rules are globs of file name like yarn.lock or *.pb.go. Rules are case insensitive
+type Filter struct {
+	include []string
+	exclude []string
+	hasMIME bool // some rules are MIME types
+}
+
+// New - filter of rules, nil if there are no rules
+func New(include, exclude []string) (*Filter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	f := &Filter{}
+	var err error
+	if f.include, err = compile(include); err != nil {
+		return nil, fmt.Errorf("include: %v", err)
+	}
+	if f.exclude, err = compile(exclude); err != nil {
+		return nil, fmt.Errorf("exclude: %v", err)
+	}
+	for _, rule := range append(f.include, f.exclude...) {
+		if strings.Contains(rule, "/") {
+			f.hasMIME = true
+		}
+	}
+	return f, nil
+}
+
+func compile(rules []string) ([]string, error) {
+	result := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		switch {
+		case rule == "":
+			return nil, fmt.Errorf("empty rule")
+		case strings.Count(rule, "/") > 1 || strings.HasPrefix(rule, "/") || strings.HasSuffix(rule, "/"):
+			return nil, fmt.Errorf("'%s' is not MIME type", rule)
+		}
+		if _, err := path.Match(rule, ""); err != nil {
+			return nil, fmt.Errorf("'%s': %v", rule, err)
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+// NeedsContent - decision about file depends on its content: there are MIME rules and its extension is not in the table,
+// other files are decided by Skip with empty head before their content is read
+func (f *Filter) NeedsContent(filePath string) bool {
+	if f == nil || !f.hasMIME {
+		return false
+	}
+	return mimeTypes[strings.ToLower(path.Ext(filePath))] == ""
+}
+
+// Skip - file must not be scanned, head is the beginning of its content which is sniffed if extension has no MIME type
+func (f *Filter) Skip(filePath, head string) bool {
+	if f == nil {
+		return false
+	}
+	file := &file{path: strings.ToLower(filePath), head: head}
+	if len(f.include) > 0 && !file.matchAny(f.include) {
+		return true
+	}
+	return file.matchAny(f.exclude)
+}
+
+type file struct {
+	path     string
+	head     string
+	mimeType *string // detected on first use
+}
+
+func (f *file) matchAny(rules []string) bool {
+	for _, rule := range rules {
+		if f.match(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *file) match(rule string) bool {
+	name := path.Base(f.path)
+	switch {
+	case strings.HasPrefix(rule, "."):
+		return strings.HasSuffix(name, rule)
+	case strings.Contains(rule, "/"):
+		ok, _ := path.Match(rule, f.mime())
+		return ok
+	}
+	ok, _ := path.Match(rule, name)
+	return ok
+}
+
+// mime - MIME type of file extension or of sniffed content without parameters, empty if both are unknown
+func (f *file) mime() string {
+	if f.mimeType != nil {
+		return *f.mimeType
+	}
+	t := mimeTypes[path.Ext(f.path)]
+	if t == "" && f.head != "" {
+		head := f.head
+		if len(head) > sniffSize {
+			head = head[:sniffSize]
+		}
+		t = http.DetectContentType([]byte(head))
+	}
+	t = strings.ToLower(strings.TrimSpace(strings.SplitN(t, ";", 2)[0]))
+	f.mimeType = &t
+	return t
+}
@@ -0,0 +1,59 @@
+package filetype
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilter(t *testing.T) {
+	Convey("no rules mean no filter", t, func() {
+		f, err := New(nil, nil)
+		So(err, ShouldBeNil)
+		So(f, ShouldBeNil)
+		So(f.Skip("logo.svg", "<svg>"), ShouldBeFalse)
+	})
+	Convey("excluded extensions, names and MIME types are skipped", t, func() {
+		f, err := New(nil, []string{".svg", ".MIN.js", "yarn.lock", "*.pb.go", "image/*"})
+		So(err, ShouldBeNil)
+		So(f.Skip("static/Logo.SVG", ""), ShouldBeTrue)
+		So(f.Skip("static/app.min.js", ""), ShouldBeTrue)
+		So(f.Skip("static/app.js", ""), ShouldBeFalse)
+		So(f.Skip("web/yarn.lock", ""), ShouldBeTrue)
+		So(f.Skip("api/api.pb.go", ""), ShouldBeTrue)
+		So(f.Skip("photo.png", ""), ShouldBeTrue)
+		So(f.Skip("config.yml", "password: 123"), ShouldBeFalse)
+	})
+	Convey("only included files are scanned", t, func() {
+		f, err := New([]string{".go", "text/*"}, []string{"*_test.go"})
+		So(err, ShouldBeNil)
+		So(f.Skip("main.go", ""), ShouldBeFalse)
+		So(f.Skip("main_test.go", ""), ShouldBeTrue)
+		So(f.Skip("index.html", ""), ShouldBeFalse)
+		So(f.Skip("app.json", `{"token": "123"}`), ShouldBeTrue)
+		Convey("content is sniffed if extension is unknown", func() {
+			So(f.Skip("Dockerfile", "FROM alpine\nENV TOKEN=123\n"), ShouldBeFalse)
+			So(f.Skip("blob", "\x89PNG\r\n\x1a\n"), ShouldBeTrue)
+			So(f.Skip("blob", ""), ShouldBeTrue)
+		})
+	})
+	Convey("content is needed only for MIME rules and unknown extensions", t, func() {
+		f, err := New(nil, []string{".map", "image/*"})
+		So(err, ShouldBeNil)
+		So(f.NeedsContent("app.js.map"), ShouldBeTrue)
+		So(f.NeedsContent("logo.PNG"), ShouldBeFalse)
+		So(f.NeedsContent("Dockerfile"), ShouldBeTrue)
+		So(f.Skip("favicon.ico", ""), ShouldBeTrue)
+		f, err = New(nil, []string{".map"})
+		So(err, ShouldBeNil)
+		So(f.NeedsContent("Dockerfile"), ShouldBeFalse)
+	})
+	Convey("bad rules", t, func() {
+		_, err := New([]string{""}, nil)
+		So(err, ShouldNotBeNil)
+		_, err = New(nil, []string{"a/b/c"})
+		So(err, ShouldNotBeNil)
+		_, err = New(nil, []string{"[.js"})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,87 @@
+package findings
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// FileStore - read-only access to leaks file written by file sender
+type FileStore struct {
+	LeaksFile string
+
+	mutex    sync.Mutex
+	leaks    []hungryfox.Leak
+	position position
+}
+
+// position - how much of file was read, file can be replaced by retention
+type position struct {
+	offset int64
+	file   os.FileInfo
+}
+
+// GetLeaks - get all leaks, file is read incrementally since last call
+func (s *FileStore) GetLeaks() ([]hungryfox.Leak, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err := readLines(s.LeaksFile, &s.position, func() { s.leaks = nil }, func(line []byte) error {
+		leak := hungryfox.Leak{}
+		if err := json.Unmarshal(line, &leak); err != nil {
+			return err
+		}
+		s.leaks = append(s.leaks, leak)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]hungryfox.Leak, len(s.leaks))
+	copy(result, s.leaks)
+	return result, nil
+}
+
+// readLines - pass lines of file written after position to parse,
+// reset is called if file was truncated or replaced and lines read before must be dropped
+func readLines(fileName string, pos *position, reset func(), parse func([]byte) error) error {
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < pos.offset || (pos.file != nil && !os.SameFile(pos.file, info)) {
+		reset()
+		pos.offset = 0
+	}
+	pos.file = info
+	if _, err := f.Seek(pos.offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// incomplete line will be read next time
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		pos.offset += int64(len(line))
+		if err := parse(line); err != nil {
+			return fmt.Errorf("can't parse %s at offset %d: %v", fileName, pos.offset, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package findings
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// StatusLog - history of leak statuses in json lines file, events are only appended
+type StatusLog struct {
+	StatusFile string
+
+	mutex    sync.Mutex
+	events   []hungryfox.StatusEvent
+	history  map[string][]hungryfox.StatusEvent // events by fingerprint, in order of file
+	position position
+}
+
+// GetEvents - all events sorted by time, file is read incrementally since last call
+func (s *StatusLog) GetEvents() ([]hungryfox.StatusEvent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+	result := make([]hungryfox.StatusEvent, len(s.events))
+	copy(result, s.events)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+// History - events of leak sorted by time, only new lines of file are read, so it is cheap for every routed leak
+func (s *StatusLog) History(fingerprint string) ([]hungryfox.StatusEvent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+	events := s.history[fingerprint]
+	result := make([]hungryfox.StatusEvent, len(events))
+	copy(result, events)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+// AddEvent - append event to file
+func (s *StatusLog) AddEvent(event hungryfox.StatusEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	event.ID = event.EventID()
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.StatusFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *StatusLog) read() error {
+	if s.history == nil {
+		s.history = map[string][]hungryfox.StatusEvent{}
+	}
+	reset := func() {
+		s.events = nil
+		s.history = map[string][]hungryfox.StatusEvent{}
+	}
+	return readLines(s.StatusFile, &s.position, reset, func(line []byte) error {
+		event := hungryfox.StatusEvent{}
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		event.ID = event.EventID()
+		s.events = append(s.events, event)
+		s.history[event.Fingerprint] = append(s.history[event.Fingerprint], event)
+		return nil
+	})
+}
+
+// StatusHistory - events of every leak fingerprint sorted by time
+func StatusHistory(events []hungryfox.StatusEvent) map[string][]hungryfox.StatusEvent {
+	result := map[string][]hungryfox.StatusEvent{}
+	for _, e := range events {
+		result[e.Fingerprint] = append(result[e.Fingerprint], e)
+	}
+	return result
+}
+
+// UndoFilter - which events should be undone, empty fields match everything
+type UndoFilter struct {
+	ID    string
+	Actor string
+	Since time.Time
+}
+
+// Match - true if event is selected by filter
+func (f UndoFilter) Match(e hungryfox.StatusEvent) bool {
+	if f.ID != "" && e.EventID() != f.ID {
+		return false
+	}
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	return !e.Time.Before(f.Since)
+}
+
+// UndoEvents - undo events for every status change selected by filter which is in effect at time,
+// already undone events and undo events themselves are never selected
+func UndoEvents(events []hungryfox.StatusEvent, filter UndoFilter, at time.Time, actor, comment string) []hungryfox.StatusEvent {
+	result := []hungryfox.StatusEvent{}
+	for _, leakEvents := range sortedHistory(events) {
+		for _, e := range hungryfox.EffectiveEvents(leakEvents, at) {
+			if !filter.Match(e) {
+				continue
+			}
+			result = append(result, hungryfox.StatusEvent{
+				Fingerprint: e.Fingerprint,
+				Status:      hungryfox.StatusUndo,
+				Time:        at,
+				Actor:       actor,
+				Comment:     comment,
+				Undoes:      e.EventID(),
+			})
+		}
+	}
+	return result
+}
+
+// sortedHistory - StatusHistory ordered by fingerprint to keep undo events deterministic
+func sortedHistory(events []hungryfox.StatusEvent) [][]hungryfox.StatusEvent {
+	history := StatusHistory(events)
+	fingerprints := make([]string, 0, len(history))
+	for fingerprint := range history {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+	result := make([][]hungryfox.StatusEvent, len(fingerprints))
+	for i, fingerprint := range fingerprints {
+		result[i] = history[fingerprint]
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package gitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+const pageSize = 50
+
+// Client - Gitea and Forgejo API client
+type Client struct {
+	URL     string
+	Token   string
+	WorkDir string
+	SSH     bool // use ssh clone urls
+	// HTTPClient - client for api requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	client     *http.Client
+}
+
+type repository struct {
+	HTMLURL  string `json:"html_url"`
+	CloneURL string `json:"clone_url"`
+	SSHURL   string `json:"ssh_url"`
+	FullName string `json:"full_name"`
+	Empty    bool   `json:"empty"` // repository without commits can't be cloned
+}
+
+type organization struct {
+	UserName string `json:"username"`
+}
+
+func (c *Client) connect() {
+	if c.client == nil {
+		c.client = c.HTTPClient
+	}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+}
+
+// FetchOrgs - get names of all organizations visible with token
+func (c *Client) FetchOrgs() ([]string, error) {
+	c.connect()
+	var orgs []string
+	for page := 1; ; page++ {
+		list := []organization{}
+		if err := c.get(fmt.Sprintf("/orgs?page=%d&limit=%d", page, pageSize), &list); err != nil {
+			return orgs, err
+		}
+		for _, org := range list {
+			orgs = append(orgs, org.UserName)
+		}
+		if len(list) < pageSize {
+			return orgs, nil
+		}
+	}
+}
+
+// FetchOrgRepos - get all repositories of organization
+func (c *Client) FetchOrgRepos(orgName string) ([]hungryfox.RepoLocation, error) {
+	return c.fetchRepos(fmt.Sprintf("/orgs/%s/repos", url.PathEscape(orgName)))
+}
+
+// FetchUserRepos - get all repositories of user
+func (c *Client) FetchUserRepos(userName string) ([]hungryfox.RepoLocation, error) {
+	return c.fetchRepos(fmt.Sprintf("/users/%s/repos", url.PathEscape(userName)))
+}
+
+func (c *Client) fetchRepos(apiPath string) ([]hungryfox.RepoLocation, error) {
+	c.connect()
+	var repoList []hungryfox.RepoLocation
+	for page := 1; ; page++ {
+		list := []repository{}
+		if err := c.get(fmt.Sprintf("%s?page=%d&limit=%d", apiPath, page, pageSize), &list); err != nil {
+			return repoList, err
+		}
+		repoList = append(repoList, c.convertRepoList(list)...)
+		if len(list) < pageSize {
+			return repoList, nil
+		}
+	}
+}
+
+func (c *Client) get(apiPath string, result interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(c.URL, "/")+"/api/v1"+apiPath, nil)
+	if err != nil {
+		return err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s for %s", resp.Status, apiPath)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (c *Client) convertRepoList(list []repository) (hfRepoList []hungryfox.RepoLocation) {
+	for _, repo := range list {
+		if repo.Empty {
+			continue
+		}
+		cloneURL := repo.CloneURL
+		if c.SSH {
+			cloneURL = repo.SSHURL
+		}
+		hfRepoList = append(hfRepoList, hungryfox.RepoLocation{
+			URL:      repo.HTMLURL,
+			CloneURL: cloneURL,
+			DataPath: c.WorkDir,
+			RepoPath: repo.FullName,
+		})
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package gitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFetchRepos(t *testing.T) {
+	full := []repository{}
+	for i := 0; i < pageSize; i++ {
+		name := fmt.Sprintf("infra/repo%d", i)
+		full = append(full, repository{HTMLURL: "https://gitea.example.com/" + name, CloneURL: "https://gitea.example.com/" + name + ".git", SSHURL: "git@gitea.example.com:" + name + ".git", FullName: name})
+	}
+	orgs := []organization{}
+	for i := 0; i < pageSize; i++ {
+		orgs = append(orgs, organization{UserName: fmt.Sprintf("org%d", i)})
+	}
+	pages := map[string]map[string]interface{}{
+		"/api/v1/orgs": {"1": orgs, "2": []organization{{UserName: "infra"}}},
+		"/api/v1/orgs/infra/repos": {
+			"1": full,
+			"2": []map[string]interface{}{
+				{"html_url": "https://gitea.example.com/infra/old", "clone_url": "https://gitea.example.com/infra/old.git", "full_name": "infra/old", "archived": true},
+				{"html_url": "https://gitea.example.com/infra/new", "clone_url": "https://gitea.example.com/infra/new.git", "full_name": "infra/new", "empty": true},
+			},
+		},
+		"/api/v1/users/alice/repos": {"1": []repository{}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		result, ok := pages[r.URL.Path][r.URL.Query().Get("page")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+	c := &Client{URL: server.URL + "/", Token: "secret", WorkDir: "/var/hungryfox/gitea"}
+
+	Convey("organizations of all pages", t, func() {
+		names, err := c.FetchOrgs()
+		So(err, ShouldBeNil)
+		So(names, ShouldHaveLength, pageSize+1)
+		So(names[pageSize], ShouldEqual, "infra")
+	})
+	Convey("repos of all pages, archived are kept and empty are skipped", t, func() {
+		repos, err := c.FetchOrgRepos("infra")
+		So(err, ShouldBeNil)
+		So(repos, ShouldHaveLength, pageSize+1)
+		So(repos[0].URL, ShouldEqual, "https://gitea.example.com/infra/repo0")
+		So(repos[0].CloneURL, ShouldEqual, "https://gitea.example.com/infra/repo0.git")
+		So(repos[0].DataPath, ShouldEqual, "/var/hungryfox/gitea")
+		So(repos[0].RepoPath, ShouldEqual, "infra/repo0")
+		So(repos[pageSize].RepoPath, ShouldEqual, "infra/old")
+	})
+	Convey("user without repos", t, func() {
+		repos, err := c.FetchUserRepos("alice")
+		So(err, ShouldBeNil)
+		So(repos, ShouldBeEmpty)
+	})
+	Convey("ssh clone urls", t, func() {
+		sshClient := &Client{URL: server.URL, Token: "secret", SSH: true}
+		repos, err := sshClient.FetchOrgRepos("infra")
+		So(err, ShouldBeNil)
+		So(repos[0].CloneURL, ShouldEqual, "git@gitea.example.com:infra/repo0.git")
+	})
+	Convey("error status is returned", t, func() {
+		badClient := &Client{URL: server.URL, Token: "bad"}
+		_, err := badClient.FetchOrgRepos("infra")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "401")
+	})
+}
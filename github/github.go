@@ -13,7 +13,10 @@ import (
 type Client struct {
 	Token   string
 	WorkDir string
-	client  *github.Client
+	SSH     bool // use ssh clone urls
+	// HTTPClient - base client for api requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	client     *github.Client
 }
 
 func (c *Client) connect() {
@@ -67,9 +70,13 @@ func (c *Client) FetchUserRepos(userName string) ([]hungryfox.RepoLocation, erro
 
 func (c *Client) convertRepoList(list []*github.Repository) (hfRepoList []hungryfox.RepoLocation) {
 	for _, repo := range list {
+		cloneURL := repo.GetCloneURL()
+		if c.SSH {
+			cloneURL = repo.GetSSHURL()
+		}
 		hfRepoList = append(hfRepoList, hungryfox.RepoLocation{
 			URL:      *repo.HTMLURL,
-			CloneURL: *repo.CloneURL,
+			CloneURL: cloneURL,
 			DataPath: c.WorkDir,
 			RepoPath: *repo.FullName,
 		})
@@ -79,10 +86,14 @@ func (c *Client) convertRepoList(list []*github.Repository) (hfRepoList []hungry
 
 func (c *Client) getTokenClient() *http.Client {
 	if c.Token == "" {
-		return nil
+		return c.HTTPClient
+	}
+	ctx := context.Background()
+	if c.HTTPClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, c.HTTPClient)
 	}
 	return oauth2.NewClient(
-		context.Background(),
+		ctx,
 		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: c.Token}),
 	)
 }
@@ -0,0 +1,138 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+type Client struct {
+	URL     string
+	Token   string
+	WorkDir string
+	SSH     bool // use ssh clone urls
+	// HTTPClient - client for api requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+	client     *http.Client
+}
+
+type project struct {
+	WebURL            string `json:"web_url"`
+	HTTPURLToRepo     string `json:"http_url_to_repo"`
+	SSHURLToRepo      string `json:"ssh_url_to_repo"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	EmptyRepo         bool   `json:"empty_repo"` // project without commits can't be cloned
+}
+
+type group struct {
+	ID       int    `json:"id"`
+	FullPath string `json:"full_path"`
+}
+
+func (c *Client) connect() {
+	if c.client == nil {
+		c.client = c.HTTPClient
+	}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+}
+
+// FetchGroupRepos - get all projects of group and its subgroups
+func (c *Client) FetchGroupRepos(groupName string) ([]hungryfox.RepoLocation, error) {
+	c.connect()
+	var repoList []hungryfox.RepoLocation
+	groups := []string{groupName}
+	visited := map[string]struct{}{}
+	for len(groups) > 0 {
+		groupID := groups[0]
+		groups = groups[1:]
+		if _, ok := visited[groupID]; ok {
+			continue
+		}
+		visited[groupID] = struct{}{}
+
+		projects := []project{}
+		if err := c.fetchAll(fmt.Sprintf("/groups/%s/projects", url.PathEscape(groupID)), func() interface{} {
+			page := []project{}
+			return &page
+		}, func(page interface{}) {
+			projects = append(projects, *page.(*[]project)...)
+		}); err != nil {
+			return repoList, err
+		}
+		repoList = append(repoList, c.convertRepoList(projects)...)
+
+		if err := c.fetchAll(fmt.Sprintf("/groups/%s/subgroups", url.PathEscape(groupID)), func() interface{} {
+			page := []group{}
+			return &page
+		}, func(page interface{}) {
+			for _, g := range *page.(*[]group) {
+				groups = append(groups, strconv.Itoa(g.ID))
+			}
+		}); err != nil {
+			return repoList, err
+		}
+	}
+	return repoList, nil
+}
+
+func (c *Client) fetchAll(apiPath string, newPage func() interface{}, add func(interface{})) error {
+	page := "1"
+	for page != "" {
+		result := newPage()
+		nextPage, err := c.get(fmt.Sprintf("%s?per_page=100&page=%s", apiPath, page), result)
+		if err != nil {
+			return err
+		}
+		add(result)
+		page = nextPage
+	}
+	return nil
+}
+
+func (c *Client) get(apiPath string, result interface{}) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(c.URL, "/")+"/api/v4"+apiPath, nil)
+	if err != nil {
+		return "", err
+	}
+	if c.Token != "" {
+		req.Header.Set("Private-Token", c.Token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s for %s", resp.Status, apiPath)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", err
+	}
+	return resp.Header.Get("X-Next-Page"), nil
+}
+
+func (c *Client) convertRepoList(list []project) (hfRepoList []hungryfox.RepoLocation) {
+	for _, repo := range list {
+		if repo.EmptyRepo {
+			continue
+		}
+		cloneURL := repo.HTTPURLToRepo
+		if c.SSH {
+			cloneURL = repo.SSHURLToRepo
+		}
+		hfRepoList = append(hfRepoList, hungryfox.RepoLocation{
+			URL:      repo.WebURL,
+			CloneURL: cloneURL,
+			DataPath: c.WorkDir,
+			RepoPath: repo.PathWithNamespace,
+		})
+	}
+	return
+}
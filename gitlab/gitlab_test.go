@@ -0,0 +1,79 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFetchGroupRepos(t *testing.T) {
+	pages := map[string]map[string]interface{}{
+		"/api/v4/groups/backend/projects": {
+			"1": []project{{WebURL: "https://gitlab.example.com/backend/api", HTTPURLToRepo: "https://gitlab.example.com/backend/api.git", SSHURLToRepo: "git@gitlab.example.com:backend/api.git", PathWithNamespace: "backend/api"}},
+			"2": []project{
+				{WebURL: "https://gitlab.example.com/backend/old", HTTPURLToRepo: "https://gitlab.example.com/backend/old.git", PathWithNamespace: "backend/old"},
+				{WebURL: "https://gitlab.example.com/backend/new", HTTPURLToRepo: "https://gitlab.example.com/backend/new.git", PathWithNamespace: "backend/new", EmptyRepo: true},
+			},
+		},
+		"/api/v4/groups/backend/subgroups": {"1": []group{{ID: 7, FullPath: "backend/team"}}},
+		"/api/v4/groups/7/projects":        {"1": []project{{WebURL: "https://gitlab.example.com/backend/team/web", HTTPURLToRepo: "https://gitlab.example.com/backend/team/web.git", PathWithNamespace: "backend/team/web"}}},
+		"/api/v4/groups/7/subgroups":       {"1": []group{}},
+	}
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+"?page="+r.URL.Query().Get("page"))
+		if r.Header.Get("Private-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		result, ok := pages[r.URL.Path][page]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		n, _ := strconv.Atoi(page)
+		if next := strconv.Itoa(n + 1); pages[r.URL.Path][next] != nil {
+			w.Header().Set("X-Next-Page", next)
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	Convey("projects of all pages of group and its subgroups, empty projects are skipped", t, func() {
+		requests = requests[:0]
+		c := &Client{URL: server.URL + "/", Token: "secret", WorkDir: "/var/hungryfox/gitlab"}
+		repos, err := c.FetchGroupRepos("backend")
+		So(err, ShouldBeNil)
+		So(repos, ShouldResemble, []hungryfox.RepoLocation{
+			{URL: "https://gitlab.example.com/backend/api", CloneURL: "https://gitlab.example.com/backend/api.git", DataPath: "/var/hungryfox/gitlab", RepoPath: "backend/api"},
+			{URL: "https://gitlab.example.com/backend/old", CloneURL: "https://gitlab.example.com/backend/old.git", DataPath: "/var/hungryfox/gitlab", RepoPath: "backend/old"},
+			{URL: "https://gitlab.example.com/backend/team/web", CloneURL: "https://gitlab.example.com/backend/team/web.git", DataPath: "/var/hungryfox/gitlab", RepoPath: "backend/team/web"},
+		})
+		So(requests, ShouldResemble, []string{
+			"/api/v4/groups/backend/projects?page=1",
+			"/api/v4/groups/backend/projects?page=2",
+			"/api/v4/groups/backend/subgroups?page=1",
+			"/api/v4/groups/7/projects?page=1",
+			"/api/v4/groups/7/subgroups?page=1",
+		})
+	})
+	Convey("ssh clone urls", t, func() {
+		c := &Client{URL: server.URL, Token: "secret", SSH: true}
+		repos, err := c.FetchGroupRepos("backend")
+		So(err, ShouldBeNil)
+		So(repos[0].CloneURL, ShouldEqual, "git@gitlab.example.com:backend/api.git")
+	})
+	Convey("error status is returned", t, func() {
+		c := &Client{URL: server.URL, Token: "bad"}
+		_, err := c.FetchGroupRepos("backend")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "401")
+	})
+}
@@ -0,0 +1,69 @@
+// Package hashonly - leaks without content for deployments where hungryfox must never store or send secrets,
+// fingerprints are HMAC so short secrets can't be brute-forced by their hashes
+package hashonly
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// minKeySize - shorter keys are rejected
+const minKeySize = 16
+
+// Hasher - replaces content of leaks by HMAC fingerprints
+type Hasher struct {
+	key []byte
+}
+
+// New - hasher with key
+func New(key []byte) (*Hasher, error) {
+	if len(key) < minKeySize {
+		return nil, fmt.Errorf("hash key must be at least %d bytes", minKeySize)
+	}
+	return &Hasher{key: key}, nil
+}
+
+// Load - hasher with key from file, surrounding whitespace is trimmed
+func Load(keyFile string) (*Hasher, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	h, err := New(bytes.TrimSpace(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", keyFile, err)
+	}
+	return h, nil
+}
+
+// Hash - leak with HMAC fingerprints instead of leak string, context and secret, hashed leaks are returned as is
+func (h *Hasher) Hash(leak hungryfox.Leak) hungryfox.Leak {
+	if leak.Hashed {
+		return leak
+	}
+	secret := leak.Secret
+	if secret == "" {
+		secret = strings.TrimSpace(leak.LeakString)
+	}
+	leak.StoredFingerprint = h.sum(leak.RepoURL, leak.CommitHash, leak.FilePath, strconv.Itoa(leak.Line), leak.PatternName, leak.LeakString)
+	leak.StoredSecretFingerprint = h.sum(leak.RepoURL, leak.FilePath, secret)
+	leak.LeakString = ""
+	leak.Context = ""
+	leak.Secret = ""
+	leak.Hashed = true
+	return leak
+}
+
+func (h *Hasher) sum(values ...string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(strings.Join(values, "\x00")))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
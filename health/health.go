@@ -0,0 +1,57 @@
+// Package health - readiness of services for liveness and readiness probes
+package health
+
+import (
+	"sort"
+	"sync"
+)
+
+// Readiness - services which must be started before instance is ready
+type Readiness struct {
+	mutex    sync.RWMutex
+	services map[string]bool
+	stopping bool
+}
+
+// Expect - services which are not ready yet
+func (r *Readiness) Expect(names ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.services == nil {
+		r.services = map[string]bool{}
+	}
+	for _, name := range names {
+		r.services[name] = false
+	}
+}
+
+// Ready - service is started
+func (r *Readiness) Ready(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.services == nil {
+		r.services = map[string]bool{}
+	}
+	r.services[name] = true
+}
+
+// Stopping - instance is shutting down and is not ready anymore
+func (r *Readiness) Stopping() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopping = true
+}
+
+// Status - instance is ready if all services are ready and it is not stopping, not ready services are listed
+func (r *Readiness) Status() (bool, []string) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	pending := []string{}
+	for name, ready := range r.services {
+		if !ready {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return len(pending) == 0 && !r.stopping, pending
+}
@@ -0,0 +1,24 @@
+//go:build go1.18
+// +build go1.18
+
+package helpers
+
+import "testing"
+
+func FuzzParseDuration(f *testing.F) {
+	f.Add("1y2d3h4m5s")
+	f.Add("")
+	f.Add("99999999999999999999y")
+	f.Fuzz(func(t *testing.T, s string) {
+		ParseDuration(s)
+	})
+}
+
+func FuzzMatchGlob(f *testing.F) {
+	f.Add("**/testdata/**", "a/testdata/b")
+	f.Add("*.pem", "key.pem")
+	f.Add("[a-", "x")
+	f.Fuzz(func(t *testing.T, pattern, name string) {
+		MatchGlob(pattern, name)
+	})
+}
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	globCache      = map[string]*regexp.Regexp{}
+	globCacheMutex sync.Mutex
+)
+
+// MatchGlob - match name with shell-like pattern, "**" matches any number of path segments
+func MatchGlob(pattern, name string) bool {
+	globCacheMutex.Lock()
+	re, ok := globCache[pattern]
+	if !ok {
+		re = regexp.MustCompile(globToRegexp(pattern))
+		globCache[pattern] = re
+	}
+	globCacheMutex.Unlock()
+	return re.MatchString(name)
+}
+
+func globToRegexp(pattern string) string {
+	var result strings.Builder
+	result.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					result.WriteString("(.*/)?")
+					continue
+				}
+				result.WriteString(".*")
+				continue
+			}
+			result.WriteString("[^/]*")
+		case '?':
+			result.WriteString("[^/]")
+		default:
+			result.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	result.WriteString("$")
+	return result.String()
+}
+
+// MatchRepo - any of repo paths or urls matches one of glob patterns of repo path or host/path
+func MatchRepo(patterns []string, repos ...string) bool {
+	for _, repo := range repos {
+		for _, name := range RepoNames(repo) {
+			for _, pattern := range patterns {
+				if MatchGlob(pattern, name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// RepoNames - repo path itself or path and host/path of repo url
+func RepoNames(repo string) []string {
+	if repo == "" {
+		return nil
+	}
+	if strings.HasPrefix(repo, "git@") {
+		// scp-like ssh url git@host:path
+		repo = "ssh://" + strings.Replace(strings.TrimPrefix(repo, "git@"), ":", "/", 1)
+	}
+	u, err := url.Parse(repo)
+	if err != nil || u.Host == "" {
+		return []string{strings.Trim(repo, "/")}
+	}
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	return []string{repoPath, u.Host + "/" + repoPath}
+}
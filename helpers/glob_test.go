@@ -0,0 +1,25 @@
+package helpers
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatchGlob(t *testing.T) {
+	Convey("single star does not cross directories", t, func() {
+		So(MatchGlob("group/*", "group/repo"), ShouldBeTrue)
+		So(MatchGlob("group/*", "group/sub/repo"), ShouldBeFalse)
+	})
+	Convey("double star matches any depth", t, func() {
+		So(MatchGlob("group/**", "group/sub/repo"), ShouldBeTrue)
+		So(MatchGlob("**/testdata/**", "pkg/a/testdata/file.txt"), ShouldBeTrue)
+		So(MatchGlob("**/testdata/**", "testdata/file.txt"), ShouldBeTrue)
+		So(MatchGlob("vendor/**", "src/vendor/file.go"), ShouldBeFalse)
+	})
+	Convey("special chars are escaped", t, func() {
+		So(MatchGlob("*.pem", "key.pem"), ShouldBeTrue)
+		So(MatchGlob("*.pem", "keyXpem"), ShouldBeFalse)
+		So(MatchGlob("file?.txt", "file1.txt"), ShouldBeTrue)
+	})
+}
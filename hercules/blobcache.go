@@ -0,0 +1,56 @@
+package repo
+
+// BlobCache - keys of blobs whose content was inspected at their path, the same file in other commits and branches
+// is not inspected again. The oldest keys are forgotten over size
+type BlobCache struct {
+	ring   []string // keys in order of adding, next is the oldest when ring is full
+	next   int
+	full   bool
+	hashes map[string]struct{}
+}
+
+// NewBlobCache - cache with keys of previous scans, the oldest first
+func NewBlobCache(size int, keys []string) *BlobCache {
+	if size <= 0 {
+		return nil
+	}
+	c := &BlobCache{ring: make([]string, size), hashes: map[string]struct{}{}}
+	for _, key := range keys {
+		c.Seen(key)
+	}
+	return c
+}
+
+// Seen - key was inspected before, otherwise it is added to cache in place of the oldest key. Nothing is cached by nil cache
+func (c *BlobCache) Seen(key string) bool {
+	if c == nil {
+		return false
+	}
+	if _, ok := c.hashes[key]; ok {
+		return true
+	}
+	if c.full {
+		delete(c.hashes, c.ring[c.next])
+	}
+	c.hashes[key] = struct{}{}
+	c.ring[c.next] = key
+	c.next = (c.next + 1) % len(c.ring)
+	c.full = c.full || c.next == 0
+	return false
+}
+
+// Hashes - cached keys for state, the oldest first
+func (c *BlobCache) Hashes() []string {
+	if c == nil {
+		return nil
+	}
+	if !c.full {
+		return append([]string{}, c.ring[:c.next]...)
+	}
+	return append(append([]string{}, c.ring[c.next:]...), c.ring[:c.next]...)
+}
+
+// blobKey - key of blob at path, the same blob at another path can be filtered differently
+func blobKey(filePath, hash string) string {
+	return hash + " " + filePath
+}
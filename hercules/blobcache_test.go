@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBlobCache(t *testing.T) {
+	Convey("keys are seen after the first time", t, func() {
+		c := NewBlobCache(2, []string{"a"})
+		So(c.Seen("a"), ShouldBeTrue)
+		So(c.Seen("b"), ShouldBeFalse)
+		So(c.Seen("b"), ShouldBeTrue)
+		So(c.Hashes(), ShouldResemble, []string{"a", "b"})
+	})
+	Convey("the oldest keys are forgotten over size", t, func() {
+		c := NewBlobCache(2, []string{"a", "b", "c"})
+		So(c.Hashes(), ShouldResemble, []string{"b", "c"})
+		So(c.Seen("a"), ShouldBeFalse)
+		So(c.Hashes(), ShouldResemble, []string{"c", "a"})
+	})
+	Convey("the ring keeps order after many evictions", t, func() {
+		c := NewBlobCache(3, nil)
+		for _, key := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+			So(c.Seen(key), ShouldBeFalse)
+		}
+		So(c.Seen("e"), ShouldBeTrue)
+		So(c.Seen("d"), ShouldBeFalse)
+		So(c.Hashes(), ShouldResemble, []string{"f", "g", "d"})
+	})
+	Convey("nil cache caches nothing", t, func() {
+		var c *BlobCache
+		So(NewBlobCache(0, []string{"a"}), ShouldBeNil)
+		So(c.Seen("a"), ShouldBeFalse)
+		So(c.Seen("a"), ShouldBeFalse)
+		So(c.Hashes(), ShouldBeNil)
+	})
+}
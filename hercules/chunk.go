@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+const (
+	blobChunkSize    = 256 * 1024
+	blobChunkOverlap = 1024
+	// sniffSize - beginning of content which is given to FileFilter
+	sniffSize = 512
+)
+
+// readChunks - stream r in chunks cut on line boundaries, line is the number of the first line in chunk.
+// A line longer than size is cut and its last overlap bytes are repeated in the next chunk
+// so a match on the cut is not lost, repeated is the number of such bytes at the beginning of chunk
+func readChunks(r io.Reader, size, overlap int, send func(content string, line, repeated int)) error {
+	buf := make([]byte, size)
+	filled, line, repeated := 0, 1, 0
+	for {
+		n, err := io.ReadFull(r, buf[filled:])
+		filled += n
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if filled > 0 {
+				send(string(buf[:filled]), line, repeated)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cut := bytes.LastIndexByte(buf[:filled], '\n')
+		if cut < 0 {
+			send(string(buf[:filled]), line, repeated)
+			filled = copy(buf, buf[filled-overlap:filled])
+			repeated = overlap
+			continue
+		}
+		send(string(buf[:cut]), line, repeated)
+		repeated = 0
+		line += bytes.Count(buf[:cut+1], []byte{'\n'})
+		filled = copy(buf, buf[cut+1:filled])
+	}
+}
+
+// windowSize - size of diffs sent to searcher, bigger chunks and files are split on line boundaries
+func (r *Repo) windowSize() int {
+	if r.WindowSize > 0 {
+		return r.WindowSize
+	}
+	return blobChunkSize
+}
+
+// skipByName - file is skipped by FileFilter before its content is read, files which need sniffing are
+// decided by sendWindows
+func (r *Repo) skipByName(filePath string) bool {
+	return r.FileFilter != nil && !r.FileFilter.NeedsContent(filePath) && r.FileFilter.Skip(filePath, "")
+}
+
+// sendWindows - stream content to searcher in windows of diff d, content over MaxDiffSize is not inspected,
+// content of files skipped by FileFilter is not read further than its beginning
+func (r *Repo) sendWindows(d hungryfox.Diff, content io.Reader) error {
+	if r.skipByName(d.FilePath) {
+		return nil
+	}
+	if r.FileFilter.NeedsContent(d.FilePath) {
+		buffered := bufio.NewReaderSize(content, sniffSize)
+		head, _ := buffered.Peek(sniffSize)
+		if r.FileFilter.Skip(d.FilePath, string(head)) {
+			return nil
+		}
+		content = buffered
+	}
+	limited := content
+	if r.MaxDiffSize > 0 {
+		limited = io.LimitReader(content, r.MaxDiffSize)
+	}
+	err := readChunks(limited, r.windowSize(), blobChunkOverlap, func(window string, line, repeated int) {
+		diff := d
+		diff.Content, diff.Overlap = window, repeated
+		diff.FileStart = d.FileStart && line == 1
+		if d.LineBegin > 0 {
+			diff.LineBegin = d.LineBegin + line - 1
+		}
+		r.DiffChannel <- &diff
+	})
+	if err != nil {
+		return err
+	}
+	if r.MaxDiffSize > 0 {
+		if n, _ := content.Read(make([]byte, 1)); n > 0 {
+			r.Truncated++
+		}
+	}
+	return nil
+}
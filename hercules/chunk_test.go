@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/filetype"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type chunk struct {
+	content  string
+	line     int
+	repeated int
+}
+
+func collectChunks(content string, size, overlap int) []chunk {
+	var result []chunk
+	readChunks(strings.NewReader(content), size, overlap, func(content string, line, repeated int) {
+		result = append(result, chunk{content, line, repeated})
+	})
+	return result
+}
+
+func TestReadChunks(t *testing.T) {
+	Convey("small blob is one chunk", t, func() {
+		So(collectChunks("a\nb\n", 16, 2), ShouldResemble, []chunk{{"a\nb\n", 1, 0}})
+	})
+	Convey("chunks are cut on line boundaries", t, func() {
+		So(collectChunks("aaa\nbbb\nccc\nddd", 10, 2), ShouldResemble, []chunk{
+			{"aaa\nbbb", 1, 0},
+			{"ccc\nddd", 3, 0},
+		})
+	})
+	Convey("long line overlaps next chunk and repeated bytes are counted", t, func() {
+		So(collectChunks("0123456789abc\nz", 8, 3), ShouldResemble, []chunk{
+			{"01234567", 1, 0},
+			{"56789abc", 1, 3},
+			{"abc\nz", 1, 3},
+		})
+	})
+	Convey("empty blob", t, func() {
+		So(collectChunks("", 8, 3), ShouldBeEmpty)
+	})
+}
+
+func TestSendWindows(t *testing.T) {
+	Convey("added chunk is sent in windows", t, func() {
+		diffChannel := make(chan *hungryfox.Diff, 100)
+		r := &Repo{DiffChannel: diffChannel, WindowSize: 2048}
+		content := strings.Repeat("password: 123456789\n", 500)
+		So(r.sendWindows(hungryfox.Diff{FilePath: "dump.sql", FileStart: true}, strings.NewReader(content)), ShouldBeNil)
+		close(diffChannel)
+		total, starts := 0, 0
+		for d := range diffChannel {
+			So(len(d.Content), ShouldBeLessThanOrEqualTo, 2048)
+			So(d.FilePath, ShouldEqual, "dump.sql")
+			So(d.LineBegin, ShouldEqual, 0)
+			total += strings.Count(d.Content, "password")
+			if d.FileStart {
+				starts++
+			}
+		}
+		So(total, ShouldEqual, 500)
+		So(starts, ShouldEqual, 1)
+		So(r.Truncated, ShouldEqual, 0)
+	})
+	Convey("content over max diff size is not sent", t, func() {
+		diffChannel := make(chan *hungryfox.Diff, 100)
+		r := &Repo{DiffChannel: diffChannel, WindowSize: 2048, MaxDiffSize: 4096}
+		content := strings.Repeat("password: 123456789\n", 500)
+		So(r.sendWindows(hungryfox.Diff{LineBegin: 1}, strings.NewReader(content)), ShouldBeNil)
+		close(diffChannel)
+		sent := 0
+		for d := range diffChannel {
+			sent += len(d.Content)
+		}
+		So(sent, ShouldBeLessThanOrEqualTo, 4096)
+		So(r.Truncated, ShouldEqual, 1)
+	})
+	Convey("files skipped by filter are not sent", t, func() {
+		filter, err := filetype.New(nil, []string{".map", "image/*"})
+		So(err, ShouldBeNil)
+		diffChannel := make(chan *hungryfox.Diff, 100)
+		r := &Repo{DiffChannel: diffChannel, WindowSize: 2048, MaxDiffSize: 4096, FileFilter: filter}
+		content := strings.Repeat("password: 123456789\n", 500)
+		So(r.sendWindows(hungryfox.Diff{FilePath: "app.js.map"}, strings.NewReader(content)), ShouldBeNil)
+		So(r.sendWindows(hungryfox.Diff{FilePath: "blob"}, strings.NewReader("\x89PNG\r\n\x1a\n"+content)), ShouldBeNil)
+		So(diffChannel, ShouldHaveLength, 0)
+		So(r.Truncated, ShouldEqual, 0)
+		So(r.sendWindows(hungryfox.Diff{FilePath: "app.js"}, strings.NewReader(content)), ShouldBeNil)
+		close(diffChannel)
+		sent := 0
+		for d := range diffChannel {
+			sent += len(d.Content)
+		}
+		So(sent, ShouldBeGreaterThan, 2048)
+		So(sent, ShouldBeLessThanOrEqualTo, 4096)
+		So(r.Truncated, ShouldEqual, 1)
+
+		Convey("content of files skipped by name is not read", func() {
+			So(r.skipByName("logo.png"), ShouldBeTrue)
+			So(r.skipByName("blob"), ShouldBeFalse)
+			So(r.sendWindows(hungryfox.Diff{FilePath: "logo.png"}, failingReader{}), ShouldBeNil)
+		})
+	})
+}
+
+// failingReader - content which must not be read
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	panic("content is read")
+}
@@ -0,0 +1,177 @@
+package repo
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+)
+
+// fallbackBatchSize - commits per external git log call when go-git can't read repo
+var fallbackBatchSize = 100
+
+// UnsupportedError - go-git can't read repo and external git can't be used instead
+type UnsupportedError struct {
+	Reason string
+}
+
+func (e *UnsupportedError) Error() string {
+	return "unsupported repo: " + e.Reason
+}
+
+var remoteObjectFormatRe = regexp.MustCompile(`object-format=(\w+)`)
+
+// unsupportedErrors - go-git errors about repo format, not about network or missing repo
+var unsupportedErrors = []error{
+	packfile.ErrUnsupportedVersion,
+	packfile.ErrBadSignature,
+	packfile.ErrInvalidObject,
+	packfile.ErrZLib,
+	packfile.ErrInvalidDelta,
+	packfile.ErrDeltaCmd,
+	idxfile.ErrUnsupportedVersion,
+	idxfile.ErrMalformedIdxFile,
+}
+
+// unsupportedReason - why go-git can't read repo at path, empty if it can or err is of other kind
+func unsupportedReason(repoPath string, err error) string {
+	if format := objectFormat(repoPath); format != "sha1" {
+		return "object format " + format
+	}
+	if err == nil {
+		return ""
+	}
+	// go-git fails to parse refs advertisement of sha256 remote
+	if m := remoteObjectFormatRe.FindStringSubmatch(err.Error()); m != nil && m[1] != "sha1" {
+		return "remote object format " + m[1]
+	}
+	for _, e := range unsupportedErrors {
+		if strings.Contains(err.Error(), e.Error()) {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// objectFormat - extensions.objectFormat from config of repo or bare repo
+func objectFormat(repoPath string) string {
+	for _, configPath := range []string{filepath.Join(repoPath, ".git", "config"), filepath.Join(repoPath, "config")} {
+		f, err := os.Open(configPath)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+			if !strings.HasPrefix(line, "objectformat") {
+				continue
+			}
+			if i := strings.Index(line, "="); i > 0 {
+				return strings.TrimSpace(line[i+1:])
+			}
+		}
+		return "sha1"
+	}
+	return "sha1"
+}
+
+// useExternalGit - switch repo to external git plumbing, it's impossible with go-git auth
+func (r *Repo) useExternalGit(reason string) error {
+	r.repository = nil
+	if (r.AllowUpdate || r.Fetch) && r.Auth != nil {
+		return &UnsupportedError{Reason: reason + ", external git can't use configured auth"}
+	}
+	if _, err := r.executor().Git(r.fullRepoPath(), "--version"); err != nil {
+		return &UnsupportedError{Reason: reason + ", external git is not available"}
+	}
+	r.FallbackReason = reason
+	return nil
+}
+
+// isSHA1 - object format of repo is sha1 or unknown, go-git supports only sha1
+func (r *Repo) isSHA1() bool {
+	return r.ObjectFormat == "" || r.ObjectFormat == "sha1"
+}
+
+func (r *Repo) cloneWithGit() error {
+	_, err := r.executor().Git(r.fullRepoPath(), "clone", "--mirror", "--quiet", r.CloneURL, ".")
+	return err
+}
+
+func (r *Repo) fetchWithGit() error {
+	e := *r.executor()
+	if r.FetchTimeout > 0 {
+		e.Timeout = r.FetchTimeout
+	}
+	_, err := e.Git(r.fullRepoPath(), "fetch", "--force", "--prune", "--quiet", r.remoteName())
+	return err
+}
+
+// revListWithGit - hashes of commits of all refs, newest first, whole list if maxCount is zero
+func (r *Repo) revListWithGit(maxCount int) ([]string, error) {
+	args := []string{"rev-list", "--all", "--remotes", "--date-order"}
+	if maxCount > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(maxCount))
+	}
+	out, err := r.executor().Git(r.fullRepoPath(), args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+func (r *Repo) getRefsWithGit() []string {
+	out, err := r.executor().Git(r.fullRepoPath(), "for-each-ref", "--format=%(objectname) %(refname)")
+	if err != nil {
+		return nil
+	}
+	refs := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.HasPrefix(fields[1], "refs/keep-around/") {
+			continue
+		}
+		refs = append(refs, fields[0])
+	}
+	return refs
+}
+
+// scanWithGit - scan new commits with external git log, history limit and max commits are applied
+// without snapshot of the tree at the limit
+func (r *Repo) scanWithGit() error {
+	hashes, err := r.getNewCommits()
+	if err != nil {
+		return err
+	}
+	if r.MaxCommits > 0 && len(hashes) > r.MaxCommits {
+		hashes = hashes[:r.MaxCommits]
+	}
+	r.commitsTotal = len(hashes)
+	// oldest batches go first, so commits scanned before a failure are saved as PartialRefs
+	for end := len(hashes); end > 0; end -= fallbackBatchSize {
+		if r.stopped() {
+			return ErrInterrupted
+		}
+		start := end - fallbackBatchSize
+		if start < 0 {
+			start = 0
+		}
+		args := []string{"--no-walk=unsorted"}
+		if !r.HistoryPastLimit.IsZero() {
+			args = append(args, "--since="+r.HistoryPastLimit.Format("2006-01-02T15:04:05Z07:00"))
+		}
+		if err := r.ScanRevs(append(args, hashes[start:end]...)...); err != nil {
+			return err
+		}
+		r.commitsScanned += end - start
+		r.partialRef = hashes[start]
+	}
+	r.partialRef = ""
+	return nil
+}
@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/executor"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func gitCommand(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=AA", "-c", "user.email=aa@example.com"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("git %v: %v %s", args, err, out)
+	}
+}
+
+func TestFallbackToExternalGit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-fallback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitCommand(t, dir, "init", "-q", "--object-format=sha256", "repo")
+	repoDir := filepath.Join(dir, "repo")
+	if err := ioutil.WriteFile(filepath.Join(repoDir, "a.txt"), []byte("one\npassword = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommand(t, repoDir, "add", "a.txt")
+	gitCommand(t, repoDir, "commit", "-q", "-m", "init")
+
+	Convey("sha256 repo is scanned with external git", t, func() {
+		So(unsupportedReason(repoDir, nil), ShouldEqual, "object format sha256")
+		diffs := make(chan *hungryfox.Diff, 10)
+		r := &Repo{DiffChannel: diffs, DataPath: dir, RepoPath: "repo"}
+		So(r.Open(), ShouldBeNil)
+		So(r.FallbackReason, ShouldNotBeEmpty)
+		So(r.Scan(), ShouldBeNil)
+		So(len(diffs), ShouldEqual, 1)
+		d := <-diffs
+		So(d.FilePath, ShouldEqual, "a.txt")
+		So(d.LineBegin, ShouldEqual, 1)
+		So(len(d.CommitHash), ShouldEqual, 64)
+
+		refs := r.GetRefs()
+		So(refs, ShouldNotBeEmpty)
+		So(refs[0], ShouldEqual, d.CommitHash)
+
+		r.SetRefs(refs)
+		So(r.Scan(), ShouldBeNil)
+		So(len(diffs), ShouldEqual, 0)
+	})
+	Convey("scan is interrupted by stop", t, func() {
+		stop := make(chan struct{})
+		close(stop)
+		diffs := make(chan *hungryfox.Diff, 10)
+		r := &Repo{DiffChannel: diffs, DataPath: dir, RepoPath: "repo", Stop: stop}
+		So(r.Open(), ShouldBeNil)
+		So(r.Scan(), ShouldEqual, ErrInterrupted)
+		So(len(diffs), ShouldEqual, 0)
+	})
+	Convey("sha256 remote is cloned with external git", t, func() {
+		for _, format := range []string{"", "sha256"} {
+			diffs := make(chan *hungryfox.Diff, 10)
+			r := &Repo{DiffChannel: diffs, DataPath: dir, RepoPath: "mirror-" + format, CloneURL: "file://" + repoDir, AllowUpdate: true, ObjectFormat: format}
+			So(r.Open(), ShouldBeNil)
+			So(r.FallbackReason, ShouldContainSubstring, "sha256")
+			So(r.Scan(), ShouldBeNil)
+			So(len(diffs), ShouldEqual, 1)
+			So(r.Open(), ShouldBeNil)
+		}
+	})
+	Convey("sha1 repo is not a fallback case", t, func() {
+		So(unsupportedReason(dir, nil), ShouldEqual, "")
+		So(unsupportedReason(dir, os.ErrNotExist), ShouldEqual, "")
+	})
+	Convey("commits scanned before failure are saved as partial refs", t, func() {
+		batchSize := fallbackBatchSize
+		fallbackBatchSize = 1
+		defer func() { fallbackBatchSize = batchSize }()
+		big := strings.Repeat("password = 1\n", 1000)
+		if err := ioutil.WriteFile(filepath.Join(repoDir, "b.txt"), []byte(big), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gitCommand(t, repoDir, "add", "b.txt")
+		gitCommand(t, repoDir, "commit", "-q", "-m", "big")
+
+		diffs := make(chan *hungryfox.Diff, 10)
+		r := &Repo{DiffChannel: diffs, DataPath: dir, RepoPath: "repo", Executor: &executor.Executor{MaxOutput: 4096}}
+		So(r.Open(), ShouldBeNil)
+		So(r.Scan(), ShouldNotBeNil)
+		So(len(diffs), ShouldEqual, 1)
+		d := <-diffs
+		So(d.FilePath, ShouldEqual, "a.txt")
+		So(r.PartialRefs(), ShouldResemble, []string{d.CommitHash})
+
+		r.Executor = nil
+		r.SetRefs(r.PartialRefs())
+		So(r.Scan(), ShouldBeNil)
+		So(len(diffs), ShouldEqual, 1)
+		So((<-diffs).FilePath, ShouldEqual, "b.txt")
+		So(r.PartialRefs(), ShouldBeNil)
+	})
+}
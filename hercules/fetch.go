@@ -0,0 +1,159 @@
+package repo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// defaultFetchTimeout - fetch timeout if FetchTimeout is zero, the same as timeout of external git
+const defaultFetchTimeout = 10 * time.Minute
+
+func (r *Repo) remoteName() string {
+	if r.Remote == "" {
+		return git.DefaultRemoteName
+	}
+	return r.Remote
+}
+
+// fetch - fetch remote with prune, so refs of deleted branches don't keep scanned commits,
+// external git is used if go-git can't read repo
+func (r *Repo) fetch() error {
+	if r.FallbackReason != "" {
+		return r.fetchWithGit()
+	}
+	timeout := r.FetchTimeout
+	if timeout <= 0 {
+		timeout = defaultFetchTimeout
+	}
+	if err := r.looseRefs(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := r.repository.FetchContext(ctx, &git.FetchOptions{RemoteName: r.remoteName(), Auth: r.Auth, Force: true})
+	if err == nil || err == git.NoErrAlreadyUpToDate {
+		return r.pruneRefs(ctx, timeout)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("fetch of %s: timeout after %s", r.remoteName(), timeout)
+	}
+	reason := unsupportedReason(r.fullRepoPath(), err)
+	if reason == "" {
+		return err
+	}
+	if err := r.useExternalGit(reason); err != nil {
+		return err
+	}
+	return r.fetchWithGit()
+}
+
+// pruneRefs - remove refs of fetch refspecs of remote which the remote doesn't have anymore like git fetch --prune
+func (r *Repo) pruneRefs(ctx context.Context, timeout time.Duration) error {
+	remote, err := r.repository.Remote(r.remoteName())
+	if err != nil {
+		return err
+	}
+	// go-git can't cancel listing of remote refs, the result is dropped after timeout
+	type result struct {
+		refs []*plumbing.Reference
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		refs, err := remote.List(&git.ListOptions{Auth: r.Auth})
+		done <- result{refs, err}
+	}()
+	var advertised []*plumbing.Reference
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return fmt.Errorf("can't list refs of %s: %v", r.remoteName(), res.err)
+		}
+		advertised = res.refs
+	case <-ctx.Done():
+		return fmt.Errorf("fetch of %s: timeout after %s", r.remoteName(), timeout)
+	}
+	specs := remote.Config().Fetch
+	keep := map[plumbing.ReferenceName]bool{}
+	for _, ref := range advertised {
+		for _, spec := range specs {
+			if spec.Match(ref.Name()) {
+				keep[spec.Dst(ref.Name())] = true
+			}
+		}
+	}
+	local, err := r.repository.References()
+	if err != nil {
+		return err
+	}
+	stale := []plumbing.ReferenceName{}
+	local.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && !keep[ref.Name()] && isDestination(specs, ref.Name()) {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+	for _, name := range stale {
+		if err := r.repository.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// looseRefs - write packed refs which fetch updates as loose ones, go-git can't update refs of
+// packed-refs which git gc of local repos leaves
+func (r *Repo) looseRefs() error {
+	remote, err := r.repository.Remote(r.remoteName())
+	if err != nil {
+		return err
+	}
+	specs := remote.Config().Fetch
+	for _, dir := range []string{filepath.Join(r.fullRepoPath(), ".git"), r.fullRepoPath()} {
+		f, err := os.Open(filepath.Join(dir, "packed-refs"))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			// <hash> <ref>, peeled tags start with ^
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "^") {
+				continue
+			}
+			name := plumbing.ReferenceName(fields[1])
+			if !isDestination(specs, name) {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(fields[1]))); err == nil {
+				continue
+			}
+			if err := r.repository.Storer.SetReference(plumbing.NewHashReference(name, plumbing.NewHash(fields[0]))); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+	return nil
+}
+
+// isDestination - ref is written by one of refspecs
+func isDestination(specs []config.RefSpec, name plumbing.ReferenceName) bool {
+	for _, spec := range specs {
+		parts := strings.SplitN(strings.TrimPrefix(string(spec), "+"), ":", 2)
+		if len(parts) == 2 && config.RefSpec(parts[1]+":"+parts[0]).Match(name) {
+			return true
+		}
+	}
+	return false
+}
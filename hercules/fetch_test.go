@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	upstream := filepath.Join(dir, "upstream")
+	gitCommand(t, dir, "init", "-q", "upstream")
+	if err := ioutil.WriteFile(filepath.Join(upstream, "a.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommand(t, upstream, "add", "a.txt")
+	gitCommand(t, upstream, "commit", "-q", "-m", "init")
+	gitCommand(t, upstream, "branch", "dev")
+	gitCommand(t, dir, "clone", "-q", upstream, "local")
+
+	// upstream moves on after the local clone
+	if err := ioutil.WriteFile(filepath.Join(upstream, "b.txt"), []byte("password = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommand(t, upstream, "add", "b.txt")
+	gitCommand(t, upstream, "commit", "-q", "-m", "secret")
+	gitCommand(t, upstream, "branch", "-q", "-D", "dev")
+
+	Convey("local repo is not fetched by default", t, func() {
+		r := &Repo{DiffChannel: make(chan *hungryfox.Diff, 10), DataPath: dir, RepoPath: "local"}
+		So(r.Open(), ShouldBeNil)
+		defer r.Close()
+		_, err := r.repository.Reference(plumbing.ReferenceName("refs/remotes/origin/dev"), false)
+		So(err, ShouldBeNil)
+	})
+	Convey("local repo is fetched with prune", t, func() {
+		diffs := make(chan *hungryfox.Diff, 10)
+		r := &Repo{DiffChannel: diffs, DataPath: dir, RepoPath: "local", Fetch: true}
+		So(r.Open(), ShouldBeNil)
+		defer r.Close()
+		_, err := r.repository.Reference(plumbing.ReferenceName("refs/remotes/origin/dev"), false)
+		So(err, ShouldEqual, plumbing.ErrReferenceNotFound)
+		So(r.Scan(), ShouldBeNil)
+		files := []string{}
+		for len(diffs) > 0 {
+			files = append(files, (<-diffs).FilePath)
+		}
+		So(files, ShouldContain, "b.txt")
+	})
+	Convey("stale clone is scanned if remote is missing", t, func() {
+		r := &Repo{DataPath: dir, RepoPath: "local", Fetch: true, Remote: "backup"}
+		So(r.Open(), ShouldBeNil)
+		defer r.Close()
+		So(r.FetchError, ShouldNotBeEmpty)
+	})
+}
@@ -0,0 +1,29 @@
+//go:build go1.18
+// +build go1.18
+
+package repo
+
+import (
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+func FuzzParseCommitPatch(f *testing.F) {
+	f.Add("abc\x1fA\x1fa@b\x1f1\ndiff --git a/x b/x\n+++ b/x\n@@ -1 +1,2 @@\n+secret\n")
+	f.Add("abc\x1fA\x1fa@b\x1f1\n+++ /dev/null\n@@ garbage\n+line\n\\ No newline at end of file\n")
+	f.Add("\x1f\x1f\x1f\n@@ -0,0 +99999999999999999999 @@\n+x\n")
+	f.Fuzz(func(t *testing.T, raw string) {
+		diffChannel := make(chan *hungryfox.Diff, 1)
+		done := make(chan struct{})
+		go func() {
+			for range diffChannel {
+			}
+			close(done)
+		}()
+		r := &Repo{DiffChannel: diffChannel}
+		r.parseCommitPatch(raw, nil)
+		close(diffChannel)
+		<-done
+	})
+}
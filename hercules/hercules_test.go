@@ -1 +1,99 @@
-package repo
\ No newline at end of file
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaxCommits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-depth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitCommand(t, dir, "init", "-q", "repo")
+	repoDir := filepath.Join(dir, "repo")
+	for i := 1; i <= 5; i++ {
+		name := fmt.Sprintf("%d.txt", i)
+		if err := ioutil.WriteFile(filepath.Join(repoDir, name), []byte(fmt.Sprintf("token = %d\n", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gitCommand(t, repoDir, "add", name)
+		gitCommand(t, repoDir, "commit", "-q", "-m", name)
+	}
+
+	scan := func(fallback bool) map[string]string {
+		diffs := make(chan *hungryfox.Diff, 100)
+		r := &Repo{DiffChannel: diffs, DataPath: dir, RepoPath: "repo", MaxCommits: 2}
+		So(r.Open(), ShouldBeNil)
+		if fallback {
+			r.FallbackReason = "test"
+		}
+		So(r.Scan(), ShouldBeNil)
+		close(diffs)
+		authors := map[string]string{}
+		for d := range diffs {
+			authors[d.FilePath] = d.Author
+		}
+		return authors
+	}
+
+	Convey("older commits are inspected as one snapshot", t, func() {
+		So(scan(false), ShouldResemble, map[string]string{
+			"5.txt": "AA",
+			"4.txt": "AA",
+			"3.txt": "unknown",
+			"2.txt": "unknown",
+			"1.txt": "unknown",
+		})
+	})
+	Convey("external git scans only max commits", t, func() {
+		So(scan(true), ShouldResemble, map[string]string{"5.txt": "AA", "4.txt": "AA"})
+	})
+}
+
+func TestAddedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-added")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitCommand(t, dir, "init", "-q", "repo")
+	repoDir := filepath.Join(dir, "repo")
+	content := ""
+	for i := 0; i < 50; i++ {
+		content += fmt.Sprintf("token = %d\n", i)
+	}
+	for _, name := range []string{"init.txt", "added.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gitCommand(t, repoDir, "add", name)
+		gitCommand(t, repoDir, "commit", "-q", "-m", name)
+	}
+
+	Convey("added files are streamed from blobs in windows", t, func() {
+		diffs := make(chan *hungryfox.Diff, 100)
+		r := &Repo{DiffChannel: diffs, DataPath: dir, RepoPath: "repo", WindowSize: 64}
+		So(r.Open(), ShouldBeNil)
+		So(r.Scan(), ShouldBeNil)
+		close(diffs)
+		windows, received := map[string]int{}, map[string]string{}
+		for d := range diffs {
+			windows[d.FilePath]++
+			received[d.FilePath] += d.Content + "\n"
+		}
+		for _, name := range []string{"init.txt", "added.txt"} {
+			So(windows[name], ShouldBeGreaterThan, 1)
+			So(strings.TrimSpace(received[name]), ShouldEqual, strings.TrimSpace(content))
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+const commitSeparator = "\x00"
+
+// ScanRevs - find added lines in commits selected by rev-list arguments with external git,
+// it works with quarantined objects in git hooks and with repos which go-git can't open
+func (r *Repo) ScanRevs(revArgs ...string) error {
+	args := []string{
+		"-c", "core.quotePath=false",
+		"log", "--no-color", "--no-ext-diff", "--find-renames", "--unified=0", "--full-index",
+		"--format=format:%x00%H%x1f%an%x1f%ae%x1f%at%x1f%ce",
+		"-p",
+	}
+	args = append(args, revArgs...)
+	out, err := r.executor().Git(r.fullRepoPath(), args...)
+	if err != nil {
+		return err
+	}
+	// signatures are unknown if they can't be read, leaks are still found
+	signatures := r.gitSignatures(revArgs...)
+	for _, commit := range strings.Split(string(out), commitSeparator) {
+		if commit == "" {
+			continue
+		}
+		r.parseCommitPatch(commit, signatures)
+	}
+	return nil
+}
+
+type commitInfo struct {
+	hash      string
+	author    string
+	email     string
+	when      time.Time
+	committer string // email of committer
+	signature signature
+}
+
+func (r *Repo) parseCommitPatch(raw string, signatures map[string]signature) {
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	if !scanner.Scan() {
+		return
+	}
+	header := strings.Split(scanner.Text(), "\x1f")
+	if len(header) != 4 && len(header) != 5 {
+		return
+	}
+	ts, _ := strconv.ParseInt(header[3], 10, 64)
+	commit := commitInfo{hash: header[0], author: header[1], email: header[2], when: time.Unix(ts, 0)}
+	if len(header) == 5 {
+		commit.committer = header[4]
+	}
+	commit.signature = signatures[commit.hash]
+
+	var (
+		filePath  string
+		lineBegin int
+		nextLine  int
+		content   bytes.Buffer
+		hunkSize  int64  // added bytes of hunk, the rest of hunk over MaxDiffSize is skipped
+		blob      string // new blob of file, its lines are skipped if it was inspected before at the same path
+		oldLeft   int    // lines of hunk not read yet by the counts of its header, they are never taken for file headers
+		newLeft   int
+	)
+	flush := func() {
+		if content.Len() > 0 && filePath != "" {
+			r.sendChunk(commit, filePath, lineBegin, content.String())
+		}
+		content.Reset()
+	}
+	add := func(line string) {
+		size := int64(len(line))
+		nextLine++
+		if r.MaxDiffSize > 0 && hunkSize+size > r.MaxDiffSize {
+			if hunkSize <= r.MaxDiffSize {
+				r.Truncated++
+			}
+			hunkSize += size
+			return
+		}
+		hunkSize += size
+		if content.Len() == 0 {
+			lineBegin = nextLine - 1
+		}
+		content.WriteString(line[1:])
+		content.WriteString("\n")
+		// added lines are sent in windows, so a huge added file doesn't stay in memory as a whole
+		if content.Len() >= r.windowSize() {
+			flush()
+		}
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || !strings.ContainsAny(line[:1], "+- \\") {
+			oldLeft, newLeft = 0, 0
+		}
+		// an added line "++ x" looks like "+++ x" header, so headers are matched only out of hunks
+		inHunk := oldLeft > 0 || newLeft > 0
+		switch {
+		case inHunk && strings.HasPrefix(line, "+"):
+			newLeft--
+			add(line)
+		case inHunk && strings.HasPrefix(line, "-"):
+			oldLeft--
+		case inHunk && strings.HasPrefix(line, " "):
+			oldLeft--
+			newLeft--
+			flush()
+		case strings.HasPrefix(line, "\\"):
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			filePath, hunkSize, blob = "", 0, ""
+		case strings.HasPrefix(line, "index "):
+			// index <old blob>..<new blob> <mode>
+			if fields := strings.Fields(line); len(fields) > 1 {
+				if blobs := strings.SplitN(fields[1], "..", 2); len(blobs) == 2 {
+					blob = blobs[1]
+				}
+			}
+		case strings.HasPrefix(line, "+++ "):
+			filePath = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			if filePath == "/dev/null" || (strings.Trim(blob, "0") != "" && r.seenBlob(filePath, blob)) {
+				filePath = ""
+			}
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "Binary files "):
+		case strings.HasPrefix(line, "@@ "):
+			flush()
+			nextLine, oldLeft, newLeft = parseHunk(line)
+			hunkSize = 0
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+// parseHunk - get first line of new file and counts of old and new lines from "@@ -1,2 +3,4 @@",
+// a count is 1 if it is omitted
+func parseHunk(hunk string) (start, oldLines, newLines int) {
+	fields := strings.Fields(hunk)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0
+	}
+	count := func(field string) (int, int) {
+		parts := strings.SplitN(field[1:], ",", 2)
+		first, _ := strconv.Atoi(parts[0])
+		if len(parts) == 1 {
+			return first, 1
+		}
+		n, _ := strconv.Atoi(parts[1])
+		return first, n
+	}
+	_, oldLines = count(fields[1])
+	start, newLines = count(fields[2])
+	return start, oldLines, newLines
+}
+
+func (r *Repo) sendChunk(commit commitInfo, filePath string, lineBegin int, content string) {
+	if r.FileFilter.Skip(filePath, content) {
+		return
+	}
+	r.DiffChannel <- &hungryfox.Diff{
+		CommitHash:     commit.hash,
+		RepoURL:        r.URL,
+		RepoPath:       r.RepoPath,
+		FilePath:       filePath,
+		LineBegin:      lineBegin,
+		Content:        content,
+		Author:         commit.author,
+		AuthorEmail:    commit.email,
+		CommitterEmail: commit.committer,
+		TimeStamp:      commit.when,
+		Signed:         commit.signature.signed,
+		SigningKey:     commit.signature.key,
+	}
+}
@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseCommitPatch(t *testing.T) {
+	Convey("added chunks with line numbers", t, func() {
+		diffChannel := make(chan *hungryfox.Diff, 10)
+		r := &Repo{DiffChannel: diffChannel, URL: "https://example.com/repo"}
+		signatures := map[string]signature{"abc": {signed: hungryfox.SignatureSSH, key: "SHA256:key"}}
+		patch := "abc\x1fAA\x1faa@example.com\x1f1530000000\x1fci@example.com\n" +
+			"diff --git a/config.yml b/config.yml\n" +
+			"--- a/config.yml\n" +
+			"+++ b/config.yml\n" +
+			"@@ -3,0 +4,2 @@ head\n" +
+			"+password: 123\n" +
+			"+token: 456\n" +
+			"@@ -10 +12 @@\n" +
+			"-old\n" +
+			"+new\n" +
+			"diff --git a/removed.txt b/removed.txt\n" +
+			"--- a/removed.txt\n" +
+			"+++ /dev/null\n" +
+			"@@ -1 +0,0 @@\n" +
+			"-gone\n"
+		r.parseCommitPatch(patch, signatures)
+		close(diffChannel)
+		diffs := []hungryfox.Diff{}
+		for d := range diffChannel {
+			diffs = append(diffs, *d)
+		}
+		So(len(diffs), ShouldEqual, 2)
+		So(diffs[0].FilePath, ShouldEqual, "config.yml")
+		So(diffs[0].LineBegin, ShouldEqual, 4)
+		So(diffs[0].Content, ShouldEqual, "password: 123\ntoken: 456\n")
+		So(diffs[0].CommitHash, ShouldEqual, "abc")
+		So(diffs[0].AuthorEmail, ShouldEqual, "aa@example.com")
+		So(diffs[0].CommitterEmail, ShouldEqual, "ci@example.com")
+		So(diffs[0].Signed, ShouldEqual, hungryfox.SignatureSSH)
+		So(diffs[0].SigningKey, ShouldEqual, "SHA256:key")
+		So(diffs[1].LineBegin, ShouldEqual, 12)
+		So(diffs[1].Content, ShouldEqual, "new\n")
+	})
+	Convey("added lines starting with ++ and removed lines starting with -- are not headers", t, func() {
+		diffChannel := make(chan *hungryfox.Diff, 10)
+		r := &Repo{DiffChannel: diffChannel}
+		patch := "abc\x1fAA\x1faa@example.com\x1f1530000000\n" +
+			"diff --git a/notes.md b/notes.md\n" +
+			"--- a/notes.md\n" +
+			"+++ b/notes.md\n" +
+			"@@ -1,2 +1,2 @@\n" +
+			"--- old password = 1\n" +
+			"-x\n" +
+			"+++ password = 2\n" +
+			"+y\n" +
+			"\\ No newline at end of file\n"
+		r.parseCommitPatch(patch, nil)
+		close(diffChannel)
+		diffs := []hungryfox.Diff{}
+		for d := range diffChannel {
+			diffs = append(diffs, *d)
+		}
+		So(len(diffs), ShouldEqual, 1)
+		So(diffs[0].FilePath, ShouldEqual, "notes.md")
+		So(diffs[0].LineBegin, ShouldEqual, 1)
+		So(diffs[0].Content, ShouldEqual, "++ password = 2\ny\n")
+	})
+	Convey("big hunks are sent in windows and cut by max diff size", t, func() {
+		diffChannel := make(chan *hungryfox.Diff, 10)
+		r := &Repo{DiffChannel: diffChannel, WindowSize: 12, MaxDiffSize: 30}
+		patch := "abc\x1fAA\x1faa@example.com\x1f1530000000\n" +
+			"diff --git a/big.txt b/big.txt\n" +
+			"--- /dev/null\n" +
+			"+++ b/big.txt\n" +
+			"@@ -0,0 +1,5 @@\n" +
+			"+line one\n" +
+			"+line two\n" +
+			"+line three\n" +
+			"+line four\n" +
+			"+line five\n"
+		r.parseCommitPatch(patch, nil)
+		close(diffChannel)
+		diffs := []hungryfox.Diff{}
+		for d := range diffChannel {
+			diffs = append(diffs, *d)
+		}
+		So(len(diffs), ShouldEqual, 2)
+		So(diffs[0].LineBegin, ShouldEqual, 1)
+		So(diffs[0].Content, ShouldEqual, "line one\nline two\n")
+		So(diffs[1].LineBegin, ShouldEqual, 3)
+		So(diffs[1].Content, ShouldEqual, "line three\n")
+		So(r.Truncated, ShouldEqual, 1)
+	})
+	Convey("files with blobs inspected before are skipped", t, func() {
+		diffChannel := make(chan *hungryfox.Diff, 10)
+		r := &Repo{DiffChannel: diffChannel, BlobCache: NewBlobCache(10, []string{"2222222222222222222222222222222222222222 copy.yml"})}
+		patch := "abc\x1fAA\x1faa@example.com\x1f1530000000\n" +
+			"diff --git a/copy.yml b/copy.yml\n" +
+			"new file mode 100644\n" +
+			"index 0000000000000000000000000000000000000000..2222222222222222222222222222222222222222\n" +
+			"--- /dev/null\n" +
+			"+++ b/copy.yml\n" +
+			"@@ -0,0 +1 @@\n" +
+			"+password: 123\n" +
+			"diff --git a/new.yml b/new.yml\n" +
+			"index 1111111111111111111111111111111111111111..3333333333333333333333333333333333333333 100644\n" +
+			"--- a/new.yml\n" +
+			"+++ b/new.yml\n" +
+			"@@ -1 +1 @@\n" +
+			"-token: 1\n" +
+			"+token: 2\n" +
+			"diff --git a/other.yml b/other.yml\n" +
+			"new file mode 100644\n" +
+			"index 0000000000000000000000000000000000000000..2222222222222222222222222222222222222222\n" +
+			"--- /dev/null\n" +
+			"+++ b/other.yml\n" +
+			"@@ -0,0 +1 @@\n" +
+			"+password: 123\n"
+		r.parseCommitPatch(patch, nil)
+		close(diffChannel)
+		diffs := []hungryfox.Diff{}
+		for d := range diffChannel {
+			diffs = append(diffs, *d)
+		}
+		So(len(diffs), ShouldEqual, 2)
+		So(diffs[0].FilePath, ShouldEqual, "new.yml")
+		So(diffs[1].FilePath, ShouldEqual, "other.yml")
+		So(r.SkippedBlobs, ShouldEqual, 1)
+		So(r.BlobCache.Hashes(), ShouldResemble, []string{
+			"2222222222222222222222222222222222222222 copy.yml",
+			"3333333333333333333333333333333333333333 new.yml",
+			"2222222222222222222222222222222222222222 other.yml",
+		})
+	})
+}
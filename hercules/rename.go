@@ -0,0 +1,210 @@
+package repo
+
+import (
+	"bufio"
+	"hash/fnv"
+	"io"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+const (
+	// renameLimit - moved and edited files are looked for only if deleted and added files of commit are not more than this
+	renameLimit = 100
+	// renameScore - percent of the same lines in deleted and added files to pair them, the same as default of git
+	renameScore = 50
+	// renameMaxSize - bigger files are paired only with the same blob
+	renameMaxSize = 4 * 1024 * 1024
+)
+
+// commitChanges - changes of commit with renamed files, so only changed lines of moved file are added
+func commitChanges(parent, commit *object.Commit) (object.Changes, error) {
+	fromTree, err := parent.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+	return detectRenames(changes), nil
+}
+
+// detectRenames - pair deleted and added files with the same blob or with at least renameScore percent of
+// the same lines like git diff -M does, a pair is a modification of file from old path to new one
+func detectRenames(changes object.Changes) object.Changes {
+	var deleted, added []int
+	for i, c := range changes {
+		action, err := c.Action()
+		if err != nil {
+			continue
+		}
+		switch action {
+		case merkletrie.Delete:
+			deleted = append(deleted, i)
+		case merkletrie.Insert:
+			added = append(added, i)
+		}
+	}
+	if len(deleted) == 0 || len(added) == 0 {
+		return changes
+	}
+	sources := map[int]int{}  // index of deleted change by index of added one
+	used := map[int]bool{}    // deleted changes which are paired
+	renamed := map[int]bool{} // added changes which are paired
+
+	byHash := map[string][]int{}
+	for _, d := range deleted {
+		hash := changes[d].From.TreeEntry.Hash.String()
+		byHash[hash] = append(byHash[hash], d)
+	}
+	for _, a := range added {
+		hash := changes[a].To.TreeEntry.Hash.String()
+		if candidates := byHash[hash]; len(candidates) > 0 {
+			sources[a], used[candidates[0]], renamed[a] = candidates[0], true, true
+			byHash[hash] = candidates[1:]
+		}
+	}
+
+	deleted = notPaired(deleted, used)
+	added = notPaired(added, renamed)
+	if len(deleted) > 0 && len(added) > 0 && len(deleted) <= renameLimit && len(added) <= renameLimit {
+		for _, p := range similarPairs(changes, deleted, added) {
+			if renamed[p.to] || used[p.from] {
+				continue
+			}
+			sources[p.to], used[p.from], renamed[p.to] = p.from, true, true
+		}
+	}
+
+	result := make(object.Changes, 0, len(changes)-len(used))
+	for i, c := range changes {
+		if used[i] {
+			continue
+		}
+		if d, ok := sources[i]; ok {
+			c = &object.Change{From: changes[d].From, To: c.To}
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func notPaired(list []int, paired map[int]bool) []int {
+	result := []int{}
+	for _, i := range list {
+		if !paired[i] {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+type renamePair struct {
+	from, to int
+	score    int
+}
+
+// similarPairs - pairs of deleted and added files scoring at least renameScore, the best first
+func similarPairs(changes object.Changes, deleted, added []int) []renamePair {
+	from := map[int]lineSet{}
+	for _, d := range deleted {
+		if f, _, err := changes[d].Files(); err == nil && f != nil {
+			if s, ok := readLineSet(f); ok {
+				from[d] = s
+			}
+		}
+	}
+	if len(from) == 0 {
+		return nil
+	}
+	result := []renamePair{}
+	for _, a := range added {
+		_, f, err := changes[a].Files()
+		if err != nil || f == nil {
+			continue
+		}
+		to, ok := readLineSet(f)
+		if !ok {
+			continue
+		}
+		for d, s := range from {
+			if score := s.score(to); score >= renameScore {
+				result = append(result, renamePair{from: d, to: a, score: score})
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].score != result[j].score {
+			return result[i].score > result[j].score
+		}
+		if result[i].to != result[j].to {
+			return result[i].to < result[j].to
+		}
+		return result[i].from < result[j].from
+	})
+	return result
+}
+
+// lineSet - bytes of file by hash of line
+type lineSet struct {
+	size  int64
+	lines map[uint64]int64
+}
+
+func readLineSet(f *object.File) (lineSet, bool) {
+	if f.Size > renameMaxSize {
+		return lineSet{}, false
+	}
+	reader, err := f.Reader()
+	if err != nil {
+		return lineSet{}, false
+	}
+	defer reader.Close()
+	s := lineSet{size: f.Size, lines: map[uint64]int64{}}
+	buf := bufio.NewReader(reader)
+	for {
+		line, err := buf.ReadBytes('\n')
+		if len(line) > 0 {
+			h := fnv.New64a()
+			h.Write(line)
+			s.lines[h.Sum64()] += int64(len(line))
+		}
+		if err == io.EOF {
+			return s, true
+		}
+		if err != nil {
+			return lineSet{}, false
+		}
+	}
+}
+
+// score - percent of bytes of the bigger file which are the same lines in both files
+func (s lineSet) score(other lineSet) int {
+	bigger := s.size
+	if other.size > bigger {
+		bigger = other.size
+	}
+	if bigger == 0 {
+		return 100
+	}
+	smaller := s.size + other.size - bigger
+	if smaller*100 < bigger*renameScore {
+		return 0
+	}
+	var same int64
+	for h, size := range s.lines {
+		if otherSize := other.lines[h]; otherSize < size {
+			same += otherSize
+		} else {
+			same += size
+		}
+	}
+	return int(same * 100 / bigger)
+}
@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRenames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-rename")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitCommand(t, dir, "init", "-q", "repo")
+	repoDir := filepath.Join(dir, "repo")
+	lines := []string{"password = 1"}
+	for i := 0; i < 20; i++ {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	write := func(name string, lines []string) {
+		if err := ioutil.WriteFile(filepath.Join(repoDir, name), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", lines)
+	gitCommand(t, repoDir, "add", "a.txt")
+	gitCommand(t, repoDir, "commit", "-q", "-m", "init")
+	gitCommand(t, repoDir, "mv", "a.txt", "b.txt")
+	gitCommand(t, repoDir, "commit", "-q", "-m", "move")
+	gitCommand(t, repoDir, "mv", "b.txt", "c.txt")
+	write("c.txt", append(lines, "token = 2"))
+	gitCommand(t, repoDir, "add", "c.txt")
+	gitCommand(t, repoDir, "commit", "-q", "-m", "move and edit")
+
+	scan := func(fallback bool) []*hungryfox.Diff {
+		diffs := make(chan *hungryfox.Diff, 100)
+		r := &Repo{DiffChannel: diffs, DataPath: dir, RepoPath: "repo"}
+		So(r.Open(), ShouldBeNil)
+		if fallback {
+			r.FallbackReason = "test"
+		}
+		So(r.Scan(), ShouldBeNil)
+		close(diffs)
+		result := []*hungryfox.Diff{}
+		for d := range diffs {
+			result = append(result, d)
+		}
+		return result
+	}
+
+	Convey("moved files are not scanned again", t, func() {
+		for _, fallback := range []bool{false, true} {
+			diffs := scan(fallback)
+			So(diffs, ShouldHaveLength, 2)
+			paths := map[string]string{}
+			for _, d := range diffs {
+				paths[d.FilePath] = d.Content
+			}
+			So(paths["a.txt"], ShouldStartWith, "password = 1\n")
+			So(paths["c.txt"], ShouldEqual, "token = 2\n")
+		}
+	})
+}
@@ -1,22 +1,29 @@
 package repo
 
 import (
-	"fmt"
+	"errors"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
 	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/executor"
+	"github.com/AlexAkulov/hungryfox/filetype"
 
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/diff"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
 )
 
+// ErrInterrupted - scan was stopped by Stop before all new commits were scanned
+var ErrInterrupted = errors.New("scan interrupted")
+
 type Repo struct {
 	DiffChannel      chan<- *hungryfox.Diff
 	HistoryPastLimit time.Time
@@ -25,15 +32,47 @@ type Repo struct {
 	CloneURL         string
 	URL              string
 	AllowUpdate      bool
-	repository       *git.Repository
-	scannedHash      map[string]struct{}
-	commitsTotal     int
-	commitsScanned   int
+	FullScanPaths    []string
+	Executor         *executor.Executor
+	Auth             transport.AuthMethod
+	// ObjectFormat - sha1 or sha256, sha256 repos are cloned and scanned with external git
+	ObjectFormat string
+	// FallbackReason - why go-git can't read repo, external git is used if it is set
+	FallbackReason string
+	// Stop - no more commits are scanned after it is closed, diffs of the current commit are still sent
+	Stop <-chan struct{}
+	// WindowSize - added chunks and files are sent to searcher in pieces of this size, 256KB if zero
+	WindowSize int
+	// MaxDiffSize - bytes of added chunk or file which are inspected, the rest is skipped, unlimited if zero
+	MaxDiffSize int64
+	// MaxCommits - new commits which are scanned one by one, the tree of the next one is inspected as a snapshot, unlimited if zero
+	MaxCommits int
+	// Fetch - repo which is not updated by AllowUpdate is fetched from Remote before scan
+	Fetch bool
+	// Remote - remote which is fetched, origin if empty
+	Remote string
+	// FetchTimeout - fetch is cancelled after it, 10 minutes if zero
+	FetchTimeout time.Duration
+	// FetchError - why Fetch of repo failed, its stale clone is scanned if it is set
+	FetchError string
+	// FileFilter - files which are not scanned by extension, name or MIME type, all files are scanned if nil
+	FileFilter *filetype.Filter
+	// BlobCache - blobs inspected by previous commits and scans are skipped, disabled if nil
+	BlobCache *BlobCache
+	// SkippedBlobs - number of files of the last scan which were skipped by BlobCache
+	SkippedBlobs int
+	// Truncated - number of chunks and files of the last scan which were bigger than MaxDiffSize
+	Truncated      int
+	repository     *git.Repository
+	scannedHash    map[string]struct{}
+	commitsTotal   int
+	commitsScanned int
+	partialRef     string
 }
 
 func (r *Repo) GetProgress() int {
 	if r.commitsTotal > 0 {
-		return (r.commitsScanned / r.commitsTotal) * 1000
+		return r.commitsScanned * 1000 / r.commitsTotal
 	}
 	return -1
 }
@@ -51,15 +90,34 @@ func (r *Repo) SetRefs(refs []string) {
 	}
 }
 
+// PartialRefs - refs of commits which the last scan inspected before it failed, older commits are not scanned again after them
+func (r *Repo) PartialRefs() []string {
+	if r.partialRef == "" {
+		return nil
+	}
+	return []string{r.partialRef}
+}
+
 func (r *Repo) GetRefs() (refsMap []string) {
 	refsMap = []string{}
-	if err := r.open(); err != nil {
+	if r.FallbackReason != "" {
+		refsMap = r.getRefsWithGit()
+	} else if err := r.open(); err != nil {
+		return
+	} else if refsMap, err = r.getRefsWithGoGit(); err != nil {
 		return
 	}
+	lastCommit := r.getLastCommit()
+	if lastCommit != "" {
+		refsMap = append(refsMap, lastCommit)
+	}
+	return
+}
 
+func (r *Repo) getRefsWithGoGit() (refsMap []string, err error) {
 	refs, err := r.repository.References()
 	if err != nil {
-		return
+		return nil, err
 	}
 	refs.ForEach(func(ref *plumbing.Reference) error {
 		if ref.Hash().IsZero() {
@@ -71,10 +129,6 @@ func (r *Repo) GetRefs() (refsMap []string) {
 		refsMap = append(refsMap, ref.Hash().String())
 		return nil
 	})
-	lastCommit := r.getLastCommit()
-	if lastCommit != "" {
-		refsMap = append(refsMap, lastCommit)
-	}
 	return
 }
 
@@ -83,48 +137,54 @@ func (r *Repo) isChecked(commitHash string) bool {
 	return ok
 }
 
-func (r *Repo) getLastCommit() string {
-	oldWD, err := os.Getwd()
-	if err != nil {
-		return ""
+func (r *Repo) executor() *executor.Executor {
+	if r.Executor != nil {
+		return r.Executor
 	}
-	if err := os.Chdir(r.fullRepoPath()); err != nil {
-		return ""
+	return executor.Default
+}
+
+// listCommits - hashes of commits in date order by external git which stops at max count, go-git decodes
+// every reachable commit to order them, so it is used only where git can't run
+func (r *Repo) listCommits(maxCount int) ([]string, error) {
+	hashes, err := r.revListWithGit(maxCount)
+	if err == nil || r.FallbackReason != "" {
+		return hashes, err
 	}
-	// --topo-order???
-	out, err := exec.Command("git", "rev-list", "--all", "--remotes", "--date-order", "--max-count=1").Output()
-	os.Chdir(oldWD)
-	if err != nil {
+	return r.revList(maxCount)
+}
+
+// getLastCommit - the newest commit of repo, it is kept in refs so the next scan stops at it
+func (r *Repo) getLastCommit() string {
+	hashes, err := r.listCommits(1)
+	if err != nil || len(hashes) == 0 {
 		return ""
 	}
-	commits := strings.Split(string(out), "\n")
-	if len(commits) > 0 {
-		return commits[0]
-	}
-	return ""
+	return hashes[0]
 }
 
-func (r *Repo) getRevList() (result []*object.Commit, err error) {
-	oldWD, err := os.Getwd()
+// getNewCommits - hashes of commits from newest to the first scanned one
+func (r *Repo) getNewCommits() ([]string, error) {
+	hashes, err := r.listCommits(0)
 	if err != nil {
-		return nil, fmt.Errorf("error on get working dir: %v", err)
+		return nil, err
 	}
-	if err := os.Chdir(r.fullRepoPath()); err != nil {
-		return nil, fmt.Errorf("error on change dir to %s: %v", r.fullRepoPath(), err)
+	result := []string{}
+	for _, commitHash := range hashes {
+		if r.isChecked(commitHash) {
+			break
+		}
+		result = append(result, commitHash)
 	}
-	// --topo-order???
-	out, err := exec.Command("git", "rev-list", "--all", "--remotes", "--date-order").Output()
-	os.Chdir(oldWD)
+	return result, nil
+}
+
+func (r *Repo) getRevList() (result []*object.Commit, err error) {
+	hashList, err := r.getNewCommits()
 	if err != nil {
 		return nil, err
 	}
-
-	hashList := strings.Split(string(out), "\n")
 	for _, commitHash := range hashList {
-		commitHash = strings.TrimSpace(commitHash)
-		if r.isChecked(commitHash) {
-			break
-		}
 		commit, err := r.repository.CommitObject(plumbing.NewHash(commitHash))
 		if err != nil {
 			continue
@@ -148,15 +208,27 @@ func (r *Repo) open() error {
 	return err
 }
 
+// Commits - number of commits scanned by the last scan, the snapshot at history limit counts as one
+func (r *Repo) Commits() int {
+	return r.commitsScanned
+}
+
 // Scan - rt
 func (r *Repo) Scan() error {
+	r.Truncated, r.SkippedBlobs, r.commitsScanned, r.partialRef = 0, 0, 0, ""
+	if r.FallbackReason != "" {
+		return r.scanWithGit()
+	}
 	commits, err := r.getRevList()
 	if err != nil {
 		return err
 	}
 	for i, commit := range commits {
+		if r.stopped() {
+			return ErrInterrupted
+		}
 		r.commitsScanned = i + 1
-		if commit.Committer.When.Before(r.HistoryPastLimit) {
+		if commit.Committer.When.Before(r.HistoryPastLimit) || (r.MaxCommits > 0 && i >= r.MaxCommits) {
 			r.getAllChanges(commit, false)
 			break
 		}
@@ -165,52 +237,45 @@ func (r *Repo) Scan() error {
 	return nil
 }
 
+// stopped - Stop is closed
+func (r *Repo) stopped() bool {
+	select {
+	case <-r.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// getAllChanges - every file of commit tree as added, files are streamed from their blobs
 func (r *Repo) getAllChanges(commit *object.Commit, initCommit bool) error {
 	tree, err := commit.Tree()
 	if err != nil {
 		return err
 	}
-	changes, err := object.DiffTree(nil, tree)
-	if err != nil {
-		return err
-	}
-	patch, err := changes.Patch()
-	if err != nil {
-		return err
+	sig := signature{}
+	if initCommit {
+		sig = r.commitSignature(commit)
 	}
-	for _, p := range patch.FilePatches() {
-		_, f := p.Files()
-		if f == nil || p.IsBinary() {
-			continue
+	return tree.Files().ForEach(func(f *object.File) error {
+		if r.skipByName(f.Name) {
+			return nil
 		}
-		for _, chunk := range p.Chunks() {
-			if chunk.Type() != diff.Add {
-				continue
-			}
+		if binary, err := f.IsBinary(); err != nil || binary || r.seenBlob(f.Name, f.Hash.String()) {
+			return nil
+		}
+		d := r.commitDiff(commit, sig, f.Name)
+		if !initCommit {
 			// TODO: Use blame for this
-			author := "unknown"
-			authorEmail := "unknown"
-
-			if initCommit {
-				author = commit.Author.Name
-				authorEmail = commit.Author.Email
-			}
-			r.DiffChannel <- &hungryfox.Diff{
-				CommitHash:  commit.Hash.String(),
-				RepoURL:     r.URL,
-				RepoPath:    r.RepoPath,
-				FilePath:    f.Path(),
-				LineBegin:   0, // TODO: await https://github.com/src-d/go-git/issues/806
-				Content:     chunk.Content(),
-				Author:      author,
-				AuthorEmail: authorEmail,
-				TimeStamp:   commit.Author.When,
-			}
+			d.Author, d.AuthorEmail, d.CommitterEmail = "unknown", "unknown", ""
 		}
-	}
-	return nil
+		d.LineBegin = 0 // TODO: await https://github.com/src-d/go-git/issues/806
+		return r.sendBlob(d, f.Hash)
+	})
 }
 
+// getCommitChanges - added lines of commit, added files and full scan paths are streamed from their blobs,
+// only modified files are diffed
 func (r *Repo) getCommitChanges(commit *object.Commit) error {
 	if commit == nil {
 		return nil
@@ -219,67 +284,200 @@ func (r *Repo) getCommitChanges(commit *object.Commit) error {
 	if err != nil {
 		return r.getAllChanges(commit, true)
 	}
-	patch, err := parrentCommit.Patch(commit)
+	changes, err := commitChanges(parrentCommit, commit)
 	if err != nil {
 		return err
 	}
-	for _, p := range patch.FilePatches() {
-		_, f := p.Files()
-		if f == nil || p.IsBinary() {
+	sig := r.commitSignature(commit)
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return err
+		}
+		if action == merkletrie.Delete {
+			continue
+		}
+		_, to, err := change.Files()
+		if err != nil {
+			return err
+		}
+		if r.skipByName(to.Name) {
+			// path rules are checked before blobs are read and diffed
+			continue
+		}
+		if binary, err := to.IsBinary(); err != nil || binary || r.seenBlob(to.Name, to.Hash.String()) {
 			continue
 		}
-		for _, chunk := range p.Chunks() {
-			if chunk.Type() != diff.Add {
-				continue
+		d := r.commitDiff(commit, sig, to.Name)
+		if r.isFullScanPath(to.Name) {
+			d.LineBegin = 1
+			if err := r.sendBlob(d, to.Hash); err != nil {
+				return err
 			}
-			r.DiffChannel <- &hungryfox.Diff{
-				CommitHash:  commit.Hash.String(),
-				RepoURL:     r.URL,
-				RepoPath:    r.RepoPath,
-				FilePath:    f.Path(),
-				LineBegin:   0, // TODO: await https://github.com/src-d/go-git/issues/806
-				Content:     chunk.Content(),
-				Author:      commit.Author.Name,
-				AuthorEmail: commit.Author.Email,
-				TimeStamp:   commit.Author.When,
+			continue
+		}
+		if action == merkletrie.Insert {
+			// TODO: await https://github.com/src-d/go-git/issues/806
+			if err := r.sendBlob(d, to.Hash); err != nil {
+				return err
+			}
+			continue
+		}
+		patch, err := change.Patch()
+		if err != nil {
+			return err
+		}
+		for _, p := range patch.FilePatches() {
+			for _, chunk := range p.Chunks() {
+				if chunk.Type() != diff.Add {
+					continue
+				}
+				if err := r.sendWindows(d, strings.NewReader(chunk.Content())); err != nil {
+					return err
+				}
 			}
 		}
 	}
 	return nil
 }
 
-func (r *Repo) fullRepoPath() string {
-	return filepath.Join(r.DataPath, r.RepoPath)
+// commitDiff - diff of file changed by commit without content, line of diff is unknown
+func (r *Repo) commitDiff(commit *object.Commit, sig signature, filePath string) hungryfox.Diff {
+	return hungryfox.Diff{
+		CommitHash:     commit.Hash.String(),
+		RepoURL:        r.URL,
+		RepoPath:       r.RepoPath,
+		FilePath:       filePath,
+		Author:         commit.Author.Name,
+		AuthorEmail:    commit.Author.Email,
+		CommitterEmail: commit.Committer.Email,
+		TimeStamp:      commit.Author.When,
+		Signed:         sig.signed,
+		SigningKey:     sig.key,
+	}
 }
 
-func (r *Repo) Open() error {
-	if !r.AllowUpdate {
-		return r.open()
+// seenBlob - content of blob was inspected before at the same path, lines of blob which are not added by the commit
+// were inspected with older blobs of file, so all of its content is known
+func (r *Repo) seenBlob(filePath, hash string) bool {
+	if r.BlobCache.Seen(blobKey(filePath, hash)) {
+		r.SkippedBlobs++
+		return true
 	}
-	if _, err := os.Stat(r.fullRepoPath()); os.IsNotExist(err) {
-		if err := os.MkdirAll(r.fullRepoPath(), 0755); err != nil {
-			return err
+	return false
+}
+
+// isFullScanPath - file must be scanned entirely instead of added chunks only
+func (r *Repo) isFullScanPath(filePath string) bool {
+	fileName := path.Base(filePath)
+	for _, pattern := range r.FullScanPaths {
+		if ok, _ := path.Match(pattern, fileName); ok {
+			return true
 		}
-		cloneOptions := &git.CloneOptions{
-			URL:        r.CloneURL,
-			NoCheckout: true,
+		if ok, _ := path.Match(pattern, filePath); ok {
+			return true
 		}
-		repository, err := git.PlainClone(r.fullRepoPath(), false, cloneOptions)
+	}
+	return false
+}
 
-		if err != nil {
+// sendBlob - stream the whole blob as content of diff d
+func (r *Repo) sendBlob(d hungryfox.Diff, hash plumbing.Hash) error {
+	blob, err := r.repository.BlobObject(hash)
+	if err != nil {
+		return err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	d.FileStart = true
+	// big data files are streamed so memory is bounded by window size and queue capacity
+	return r.sendWindows(d, reader)
+}
+
+// fullRepoPath - path of repo on disk, DataPath and RepoPath may be separated by slashes on every OS.
+// DataPath which is only a volume like C: is the root of the drive, not its current directory
+func (r *Repo) fullRepoPath() string {
+	dataPath := filepath.FromSlash(r.DataPath)
+	if dataPath != "" && dataPath == filepath.VolumeName(dataPath) {
+		dataPath += string(filepath.Separator)
+	}
+	return filepath.Join(dataPath, filepath.FromSlash(r.RepoPath))
+}
+
+// Open - open repo, clone or fetch it if update is allowed. External git is used if go-git can't read repo
+func (r *Repo) Open() error {
+	if !r.AllowUpdate {
+		if err := r.openOrFallback(); err != nil || !r.Fetch {
 			return err
 		}
-		r.repository = repository
+		// local clone is intact, so an unreachable remote doesn't fail its scan
+		if err := r.fetch(); err != nil {
+			r.FetchError = err.Error()
+		}
 		return nil
 	}
+	if _, err := os.Stat(r.fullRepoPath()); os.IsNotExist(err) {
+		return r.clone()
+	}
 
-	if err := r.open(); err != nil {
+	if err := r.openOrFallback(); err != nil {
 		return err
 	}
+	return r.fetch()
+}
 
-	if err := r.repository.Fetch(&git.FetchOptions{Force: true}); err != nil && err != git.NoErrAlreadyUpToDate {
+func (r *Repo) clone() error {
+	if err := os.MkdirAll(r.fullRepoPath(), 0755); err != nil {
 		return err
 	}
+	if !r.isSHA1() {
+		return r.cloneWithFallback("object format " + r.ObjectFormat)
+	}
+	cloneOptions := &git.CloneOptions{
+		URL:        r.CloneURL,
+		Auth:       r.Auth,
+		NoCheckout: true,
+	}
+	repository, err := git.PlainClone(r.fullRepoPath(), false, cloneOptions)
+	if err == nil {
+		r.repository = repository
+		return nil
+	}
+	// object format is read from config of partial clone, it can't be opened next time
+	reason := unsupportedReason(r.fullRepoPath(), err)
+	os.RemoveAll(r.fullRepoPath())
+	if reason == "" {
+		return err
+	}
+	if err := os.MkdirAll(r.fullRepoPath(), 0755); err != nil {
+		return err
+	}
+	return r.cloneWithFallback(reason)
+}
 
+func (r *Repo) cloneWithFallback(reason string) error {
+	if err := r.useExternalGit(reason); err != nil {
+		os.RemoveAll(r.fullRepoPath())
+		return err
+	}
+	if err := r.cloneWithGit(); err != nil {
+		os.RemoveAll(r.fullRepoPath())
+		return err
+	}
 	return nil
 }
+
+func (r *Repo) openOrFallback() error {
+	if !r.isSHA1() && r.FallbackReason == "" {
+		return r.useExternalGit("object format " + r.ObjectFormat)
+	}
+	err := r.open()
+	reason := unsupportedReason(r.fullRepoPath(), err)
+	if reason == "" {
+		return err
+	}
+	return r.useExternalGit(reason)
+}
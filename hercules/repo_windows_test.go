@@ -0,0 +1,15 @@
+package repo
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFullRepoPath(t *testing.T) {
+	Convey("repo path on windows", t, func() {
+		So((&Repo{DataPath: "C:/repos", RepoPath: "backend/api.git"}).fullRepoPath(), ShouldEqual, `C:\repos\backend\api.git`)
+		So((&Repo{DataPath: "C:", RepoPath: "backend/api.git"}).fullRepoPath(), ShouldEqual, `C:\backend\api.git`)
+		So((&Repo{DataPath: `\\server\share`, RepoPath: "api.git"}).fullRepoPath(), ShouldEqual, `\\server\share\api.git`)
+	})
+}
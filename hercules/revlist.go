@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"container/heap"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// revList - hashes of commits reachable from all refs in order of git rev-list --all --date-order: no parent
+// before all of its children, otherwise newest first by committer time. Whole list if maxCount is zero
+func (r *Repo) revList(maxCount int) ([]string, error) {
+	tips, err := r.refCommits()
+	if err != nil {
+		return nil, err
+	}
+	commits := map[plumbing.Hash]*object.Commit{}
+	children := map[plumbing.Hash]int{}
+	stack := tips
+	for len(stack) > 0 {
+		commit := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := commits[commit.Hash]; ok {
+			continue
+		}
+		commits[commit.Hash] = commit
+		for _, parentHash := range commit.ParentHashes {
+			children[parentHash]++
+			if _, ok := commits[parentHash]; ok {
+				continue
+			}
+			parent, err := r.repository.CommitObject(parentHash)
+			if err != nil {
+				// parents of shallow clone are missing
+				continue
+			}
+			stack = append(stack, parent)
+		}
+	}
+
+	ready := &commitHeap{}
+	for hash, commit := range commits {
+		if children[hash] == 0 {
+			heap.Push(ready, commit)
+		}
+	}
+	result := []string{}
+	for ready.Len() > 0 && (maxCount <= 0 || len(result) < maxCount) {
+		commit := heap.Pop(ready).(*object.Commit)
+		result = append(result, commit.Hash.String())
+		for _, parentHash := range commit.ParentHashes {
+			parent, ok := commits[parentHash]
+			if !ok {
+				continue
+			}
+			if children[parentHash]--; children[parentHash] == 0 {
+				heap.Push(ready, parent)
+			}
+		}
+	}
+	return result, nil
+}
+
+// refCommits - commits of HEAD and all refs, annotated tags are peeled, tags of trees and blobs are skipped
+func (r *Repo) refCommits() ([]*object.Commit, error) {
+	refs, err := r.repository.References()
+	if err != nil {
+		return nil, err
+	}
+	result := []*object.Commit{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.SymbolicReference {
+			resolved, err := r.repository.Reference(ref.Name(), true)
+			if err != nil {
+				// HEAD of empty repo points to unborn branch
+				return nil
+			}
+			ref = resolved
+		}
+		if ref.Hash().IsZero() {
+			return nil
+		}
+		if commit, err := r.repository.CommitObject(ref.Hash()); err == nil {
+			result = append(result, commit)
+			return nil
+		}
+		if tag, err := r.repository.TagObject(ref.Hash()); err == nil {
+			if commit, err := tag.Commit(); err == nil {
+				result = append(result, commit)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// commitHeap - the newest commit by committer time on top, hash decides between commits of the same time
+type commitHeap []*object.Commit
+
+func (h commitHeap) Len() int { return len(h) }
+func (h commitHeap) Less(i, j int) bool {
+	if !h[i].Committer.When.Equal(h[j].Committer.When) {
+		return h[i].Committer.When.After(h[j].Committer.When)
+	}
+	return h[i].Hash.String() < h[j].Hash.String()
+}
+func (h commitHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *commitHeap) Push(x interface{}) { *h = append(*h, x.(*object.Commit)) }
+func (h *commitHeap) Pop() interface{} {
+	old := *h
+	commit := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return commit
+}
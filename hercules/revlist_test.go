@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRevList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-revlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitCommand(t, dir, "init", "-q", "repo")
+	repoDir := filepath.Join(dir, "repo")
+	// commit with committer date, the last one is older than its parent like after clock skew
+	commit := func(name, date string) string {
+		if err := ioutil.WriteFile(filepath.Join(repoDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gitCommand(t, repoDir, "add", name)
+		cmd := exec.Command("git", "-c", "user.name=AA", "-c", "user.email=aa@example.com", "commit", "-q", "-m", name)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE="+date, "GIT_AUTHOR_DATE="+date)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("git commit: %v %s", err, out)
+		}
+		out, _ := exec.Command("git", "-C", repoDir, "rev-parse", "HEAD").Output()
+		return strings.TrimSpace(string(out))
+	}
+	c1 := commit("1.txt", "2018-07-01T10:00:00Z")
+	gitCommand(t, repoDir, "checkout", "-q", "-b", "feature")
+	f1 := commit("f.txt", "2018-07-03T10:00:00Z")
+	gitCommand(t, repoDir, "-c", "user.name=AA", "-c", "user.email=aa@example.com", "tag", "-a", "-m", "release", "v1")
+	gitCommand(t, repoDir, "checkout", "-q", "-")
+	gitCommand(t, repoDir, "branch", "-q", "-D", "feature")
+	c2 := commit("2.txt", "2018-07-02T10:00:00Z")
+	c3 := commit("3.txt", "2018-07-04T10:00:00Z")
+	c4 := commit("4.txt", "2018-06-01T10:00:00Z")
+
+	r := &Repo{DataPath: dir, RepoPath: "repo"}
+	if err := r.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("commits are listed in order of git rev-list --date-order", t, func() {
+		hashes, err := r.revList(0)
+		So(err, ShouldBeNil)
+		So(hashes, ShouldResemble, []string{f1, c4, c3, c2, c1})
+		external, err := r.revListWithGit(0)
+		So(err, ShouldBeNil)
+		So(hashes, ShouldResemble, external)
+	})
+
+	Convey("new commits are listed till the first scanned one", t, func() {
+		r.SetRefs([]string{c3})
+		newCommits, err := r.getNewCommits()
+		So(err, ShouldBeNil)
+		So(newCommits, ShouldResemble, []string{f1, c4})
+		So(r.getLastCommit(), ShouldEqual, f1)
+	})
+
+	Convey("commits are listed by go-git without git", t, func() {
+		path := os.Getenv("PATH")
+		os.Setenv("PATH", dir)
+		defer os.Setenv("PATH", path)
+		hashes, err := r.listCommits(0)
+		So(err, ShouldBeNil)
+		So(hashes, ShouldResemble, []string{f1, c4, c3, c2, c1})
+	})
+}
@@ -0,0 +1,188 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// signature - how commit is signed, signed is empty if commit can't be read
+type signature struct {
+	signed string
+	key    string
+}
+
+// commitSignature - signature of commit from its raw object, go-git parses only pgp signatures
+func (r *Repo) commitSignature(commit *object.Commit) signature {
+	obj, err := r.repository.Storer.EncodedObject(plumbing.CommitObject, commit.Hash)
+	if err != nil {
+		return signature{}
+	}
+	reader, err := obj.Reader()
+	if err != nil {
+		return signature{}
+	}
+	defer reader.Close()
+	return parseSignature(signatureHeader(bufio.NewScanner(reader)))
+}
+
+// gitSignatures - signatures of commits selected by rev-list arguments by hash, read with external git
+func (r *Repo) gitSignatures(revArgs ...string) map[string]signature {
+	args := append([]string{"log", "--no-color", "--no-patch", "--format=raw"}, revArgs...)
+	out, err := r.executor().Git(r.fullRepoPath(), args...)
+	if err != nil {
+		return nil
+	}
+	return parseRawLog(out)
+}
+
+// parseRawLog - signatures of commits by hash from git log --format=raw
+func parseRawLog(out []byte) map[string]signature {
+	result := map[string]signature{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		// lines of message are indented, so only headers start with commit
+		line := scanner.Text()
+		if line == "" || line[0] == ' ' {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == "commit" {
+			result[fields[1]] = parseSignature(signatureHeader(scanner))
+		}
+	}
+	return result
+}
+
+// signatureHeader - gpgsig header of raw commit, it ends with the first empty line after headers
+func signatureHeader(scanner *bufio.Scanner) string {
+	lines := []string{}
+	inHeader := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		if inHeader && strings.HasPrefix(line, " ") {
+			lines = append(lines, line[1:])
+			continue
+		}
+		inHeader = false
+		// sha256 repos may keep signatures of both object formats, the first one is enough
+		if len(lines) == 0 && (strings.HasPrefix(line, "gpgsig ") || strings.HasPrefix(line, "gpgsig-sha256 ")) {
+			inHeader = true
+			lines = append(lines, line[strings.Index(line, " ")+1:])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseSignature - type and key of armored signature of commit, key is empty if it can't be read
+func parseSignature(armored string) signature {
+	armored = strings.TrimSpace(armored)
+	switch {
+	case armored == "":
+		return signature{signed: hungryfox.Unsigned}
+	case strings.HasPrefix(armored, "-----BEGIN PGP SIGNATURE-----"):
+		return signature{signed: hungryfox.SignatureGPG, key: gpgKeyID(armored)}
+	case strings.HasPrefix(armored, "-----BEGIN SSH SIGNATURE-----"):
+		return signature{signed: hungryfox.SignatureSSH, key: sshKeyFingerprint(armored)}
+	}
+	return signature{signed: hungryfox.SignatureX509}
+}
+
+// gpgKeyID - issuer key id of signature packet in hex like gpg --keyid-format long shows it,
+// subpackets are read directly because packet of x/crypto doesn't support ed25519 keys
+func gpgKeyID(armored string) string {
+	block, err := armor.Decode(strings.NewReader(armored))
+	if err != nil {
+		return ""
+	}
+	p, err := packet.NewOpaqueReader(block.Body).Next()
+	if err != nil || p.Tag != 2 {
+		return ""
+	}
+	body := p.Contents
+	// version 4: version, type, public key and hash algorithms, then hashed and unhashed subpackets
+	if len(body) < 6 || body[0] != 4 {
+		return ""
+	}
+	body = body[4:]
+	for i := 0; i < 2 && len(body) >= 2; i++ {
+		size := int(binary.BigEndian.Uint16(body))
+		if len(body) < 2+size {
+			return ""
+		}
+		if id := issuerKeyID(body[2 : 2+size]); id != "" {
+			return id
+		}
+		body = body[2+size:]
+	}
+	return ""
+}
+
+// issuerKeyID - key id of issuer or issuer fingerprint subpacket, RFC 4880 5.2.3.1
+func issuerKeyID(subpackets []byte) string {
+	for len(subpackets) > 0 {
+		var size int
+		switch first := int(subpackets[0]); {
+		case first < 192:
+			size, subpackets = first, subpackets[1:]
+		case first < 255 && len(subpackets) >= 2:
+			size, subpackets = (first-192)<<8+int(subpackets[1])+192, subpackets[2:]
+		case first == 255 && len(subpackets) >= 5:
+			size, subpackets = int(binary.BigEndian.Uint32(subpackets[1:5])), subpackets[5:]
+		default:
+			return ""
+		}
+		if size == 0 || len(subpackets) < size {
+			return ""
+		}
+		data := subpackets[1:size]
+		switch subpackets[0] & 0x7f {
+		case 16: // issuer
+			if len(data) == 8 {
+				return fmt.Sprintf("%X", data)
+			}
+		case 33: // issuer fingerprint, key id is its last 8 bytes for v4 keys
+			if len(data) == 21 {
+				return fmt.Sprintf("%X", data[13:])
+			}
+		}
+		subpackets = subpackets[size:]
+	}
+	return ""
+}
+
+// sshKeyFingerprint - sha256 fingerprint of public key of ssh signature like ssh-keygen -l shows it
+func sshKeyFingerprint(armored string) string {
+	block, _ := pem.Decode([]byte(armored))
+	if block == nil || !bytes.HasPrefix(block.Bytes, []byte("SSHSIG")) {
+		return ""
+	}
+	// magic, version and public key as ssh string
+	data := block.Bytes[6:]
+	if len(data) < 8 {
+		return ""
+	}
+	size := binary.BigEndian.Uint32(data[4:8])
+	if uint32(len(data)-8) < size {
+		return ""
+	}
+	key, err := ssh.ParsePublicKey(data[8 : 8+size])
+	if err != nil {
+		return ""
+	}
+	return ssh.FingerprintSHA256(key)
+}
@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/ssh"
+)
+
+const testGPGSignature = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABYIAB0WIQTvSkDaJPOZijwRZ5vd4tXX7UHVVgUCatBI9gAKCRDd4tXX7UHV
+VlhAAP4hCvNFDJJgl6bYjsEYLwPxwp6niW/GDeYWcER1o22pZgD+KgtmOGor2Kvv
+tOALy+DMpCty6bFL0I2FkymNrlpBPAs=
+=L37l
+-----END PGP SIGNATURE-----`
+
+func TestParseSignature(t *testing.T) {
+	Convey("key id of ed25519 gpg signature", t, func() {
+		So(parseSignature(testGPGSignature), ShouldResemble, signature{signed: hungryfox.SignatureGPG, key: "DDE2D5D7ED41D556"})
+	})
+	Convey("commit without signature", t, func() {
+		So(parseSignature(""), ShouldResemble, signature{signed: hungryfox.Unsigned})
+	})
+	Convey("commits mentioned in messages keep their signatures", t, func() {
+		out := "commit abc\ntree 1\ngpgsig -----BEGIN SSH SIGNATURE-----\n -----END SSH SIGNATURE-----\n\n    fix\n\n" +
+			"commit def\ntree 2\n\n    revert\n\n    commit abc\n"
+		signatures := parseRawLog([]byte(out))
+		So(signatures, ShouldHaveLength, 2)
+		So(signatures["abc"].signed, ShouldEqual, hungryfox.SignatureSSH)
+		So(signatures["def"].signed, ShouldEqual, hungryfox.Unsigned)
+	})
+	Convey("broken signatures are signed with unknown key", t, func() {
+		So(parseSignature("-----BEGIN PGP SIGNATURE-----\n\nbroken\n-----END PGP SIGNATURE-----"), ShouldResemble, signature{signed: hungryfox.SignatureGPG})
+		So(parseSignature("-----BEGIN SSH SIGNATURE-----\nbroken\n-----END SSH SIGNATURE-----"), ShouldResemble, signature{signed: hungryfox.SignatureSSH})
+		So(parseSignature("-----BEGIN SIGNED MESSAGE-----\nMIAGCSqGSIb3DQEHAqCAMIACAQExDTALBglghkgBZQMEAgE=\n-----END SIGNED MESSAGE-----"), ShouldResemble, signature{signed: hungryfox.SignatureX509})
+	})
+}
+
+func TestCommitSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-signature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", keyFile).CombinedOutput(); err != nil {
+		t.Skipf("ssh-keygen: %v %s", err, out)
+	}
+	publicKey, err := ioutil.ReadFile(keyFile + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitCommand(t, dir, "init", "-q", "repo")
+	repoDir := filepath.Join(dir, "repo")
+	for _, name := range []string{"signed.txt", "unsigned.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(repoDir, name), []byte("password = 1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gitCommand(t, repoDir, "add", name)
+		if name == "signed.txt" {
+			gitCommand(t, repoDir, "-c", "gpg.format=ssh", "-c", "user.signingkey="+keyFile, "commit", "-q", "-S", "-m", name)
+		} else {
+			gitCommand(t, repoDir, "commit", "-q", "-m", name)
+		}
+	}
+
+	scan := func(fallback bool) map[string]hungryfox.Diff {
+		diffs := make(chan *hungryfox.Diff, 100)
+		r := &Repo{DiffChannel: diffs, DataPath: dir, RepoPath: "repo"}
+		So(r.Open(), ShouldBeNil)
+		if fallback {
+			r.FallbackReason = "test"
+		}
+		So(r.Scan(), ShouldBeNil)
+		close(diffs)
+		result := map[string]hungryfox.Diff{}
+		for d := range diffs {
+			result[d.FilePath] = *d
+		}
+		return result
+	}
+
+	Convey("signature of commit is sent with its diffs", t, func() {
+		for _, fallback := range []bool{false, true} {
+			diffs := scan(fallback)
+			So(diffs["signed.txt"].Signed, ShouldEqual, hungryfox.SignatureSSH)
+			So(diffs["signed.txt"].SigningKey, ShouldEqual, ssh.FingerprintSHA256(key))
+			So(diffs["unsigned.txt"].Signed, ShouldEqual, hungryfox.Unsigned)
+			So(diffs["unsigned.txt"].SigningKey, ShouldBeEmpty)
+		}
+	})
+}
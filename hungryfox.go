@@ -1,21 +1,49 @@
 package hungryfox
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
 
 type Diff struct {
-	CommitHash  string
-	RepoURL     string
-	RepoPath    string
-	FilePath    string
-	LineBegin   int
-	Content     string
+	CommitHash string
+	RepoURL    string
+	RepoPath   string
+	FilePath   string
+	LineBegin  int
+	Content    string
+	// Overlap - bytes at the beginning of Content which repeat the end of the previous window of a cut line,
+	// matches ending there were inspected with that window
+	Overlap     int
 	AuthorEmail string
 	Author      string
-	TimeStamp   time.Time
+	// CommitterEmail - differs from AuthorEmail for rebased, cherry-picked and bot commits
+	CommitterEmail string
+	TimeStamp      time.Time
+	Language       string // detected by searcher if empty
+	// FileStart - Content begins at the first line of file, shebang is detected only then
+	FileStart bool
+	// Signed - SignatureGPG, SignatureSSH, SignatureX509 or Unsigned, empty if commit is unknown
+	Signed     string
+	SigningKey string // key id of gpg signature or sha256 fingerprint of ssh key
 }
 
 type RepoOptions struct {
-	AllowUpdate bool
+	AllowUpdate  bool
+	Auth         transport.AuthMethod
+	ObjectFormat string
+	// Fetch - local repo which is not updated by AllowUpdate is fetched from Remote before scan
+	Fetch  bool
+	Remote string
+	// FetchTimeout - fetch_timeout of inspect
+	FetchTimeout time.Duration
 }
 
 type RepoLocation struct {
@@ -26,21 +54,29 @@ type RepoLocation struct {
 }
 
 type RepoState struct {
-	Refs     []string
+	Refs      []string
+	RemovedAt time.Time
+	// Blobs - hashes and paths of inspected blobs, the oldest first, empty if blob cache is disabled
+	Blobs []string
+	// BlobsRules - version of rules which inspected Blobs, the cache is dropped when rules change
+	BlobsRules string
 }
 
 type ScanStatus struct {
 	StartTime time.Time
 	EndTime   time.Time
 	Success   bool
+	Error     string
+	// Unhealthy - repo can't be read neither by go-git nor by external git
+	Unhealthy bool
 }
 
 type Repo struct {
-	Options RepoOptions
+	Options  RepoOptions
 	Location RepoLocation
-	State RepoState
-	Scan ScanStatus
-	Repo IRepo
+	State    RepoState
+	Scan     ScanStatus
+	Repo     IRepo
 }
 
 type IMessageSender interface {
@@ -49,6 +85,11 @@ type IMessageSender interface {
 	Stop() error
 }
 
+// IRecipients - sender which can tell who receives leak
+type IRecipients interface {
+	Recipients(Leak) []string
+}
+
 type ILeakSearcher interface {
 	Start() error
 	SetConfig() error
@@ -68,6 +109,206 @@ type IRepo interface {
 type IStateManager interface {
 	Load(string) (RepoState, ScanStatus)
 	Save(Repo)
+	List() []Repo
+	Delete(string)
+}
+
+// IScanObserver - receives every finished scan which was not interrupted, commits is the number of scanned commits
+type IScanObserver interface {
+	ScanFinished(r Repo, commits int)
+}
+
+// AuditEvent - record of audit log, evidence of what was scanned with which rules and who was notified
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+	// Reason - why rules were reloaded or notification was skipped
+	Reason string `json:"reason,omitempty"`
+	Repo   string `json:"repo,omitempty"`
+	// FromRefs - refs scanned before, the whole history is scanned if empty
+	FromRefs []string `json:"from_refs,omitempty"`
+	// ToRefs - refs scanned to, empty if scan was not successful
+	ToRefs      []string `json:"to_refs,omitempty"`
+	Commits     int      `json:"commits,omitempty"`
+	Rules       string   `json:"rules,omitempty"` // version of rules
+	Sender      string   `json:"sender,omitempty"`
+	Recipients  []string `json:"recipients,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"` // leak of notification
+}
+
+// Actions and results of audit events
+const (
+	AuditScanStart    = "scan_start"
+	AuditScanFinish   = "scan_finish"
+	AuditRulesReload  = "rules_reload"
+	AuditNotification = "notification"
+
+	AuditOK          = "ok"
+	AuditQueued      = "queued"
+	AuditFailed      = "failed"
+	AuditInterrupted = "interrupted"
+	AuditSkipped     = "skipped"
+)
+
+// IAuditLog - append-only stream of audit events, time of event is set by log if it is zero
+type IAuditLog interface {
+	Record(AuditEvent)
+}
+
+// IFingerprintStore - fingerprints of sent leaks for dedup
+type IFingerprintStore interface {
+	GetFingerprints() (fingerprints map[string]bool, secretFingerprints map[string]bool, err error)
+	AddFingerprint(fingerprint, secretFingerprint string, foundAt time.Time) error
+}
+
+type ILeakStore interface {
+	GetLeaks() ([]Leak, error)
+}
+
+// IStatusStore - append-only history of leak statuses, status of leak is its last event
+type IStatusStore interface {
+	GetEvents() ([]StatusEvent, error)
+	AddEvent(StatusEvent) error
+}
+
+// Statuses of leaks, leak without events is open
+const (
+	StatusOpen     = "open"
+	StatusResolved = "resolved"
+	StatusIgnored  = "ignored"
+	// StatusAcknowledged - leak is still open but somebody works on it, it is not notified again
+	StatusAcknowledged  = "acknowledged"
+	StatusFalsePositive = "false_positive"
+	StatusRotated       = "rotated"
+)
+
+// Statuses - statuses which can be set by triage
+var Statuses = []string{StatusOpen, StatusAcknowledged, StatusResolved, StatusRotated, StatusIgnored, StatusFalsePositive}
+
+// ValidStatus - status can be set by triage
+func ValidStatus(status string) bool {
+	for _, s := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen - secret of leak with status is still valid, acknowledged leaks are open too
+func IsOpen(status string) bool {
+	return status == StatusOpen || status == StatusAcknowledged
+}
+
+// StatusUndo - event which reverts earlier event of the same leak, history itself is never rewritten
+const StatusUndo = "undo"
+
+// StatusEvent - change of leak status
+type StatusEvent struct {
+	ID          string    `json:"id,omitempty"`
+	Fingerprint string    `json:"fingerprint"`
+	Status      string    `json:"status"`
+	Time        time.Time `json:"time"`
+	Actor       string    `json:"actor,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+	Undoes      string    `json:"undoes,omitempty"`
+}
+
+// EventID - ID of event, events written before IDs were introduced get it from their content
+func (e StatusEvent) EventID() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	h := sha256.New()
+	for _, s := range []string{e.Fingerprint, e.Status, e.Time.UTC().Format(time.RFC3339Nano), e.Actor, e.Comment, e.Undoes} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// EffectiveEvents - status changes at time which are not undone, undo events are skipped
+func EffectiveEvents(events []StatusEvent, at time.Time) []StatusEvent {
+	undone := map[string]bool{}
+	for _, e := range events {
+		if e.Status == StatusUndo && !e.Time.After(at) {
+			undone[e.Undoes] = true
+		}
+	}
+	result := []StatusEvent{}
+	for _, e := range events {
+		if e.Time.After(at) {
+			break
+		}
+		if e.Status != StatusUndo && !undone[e.EventID()] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// StatusAsOf - status of leak at time by its events sorted by time
+func StatusAsOf(events []StatusEvent, at time.Time) string {
+	effective := EffectiveEvents(events, at)
+	if len(effective) == 0 {
+		return StatusOpen
+	}
+	return effective[len(effective)-1].Status
+}
+
+// QueuedRepo - place of repo in scan queue
+type QueuedRepo struct {
+	URL      string     `json:"repo"`
+	Due      bool       `json:"due"`                 // scan_interval or schedule passed since last scan or repo was never scanned
+	Priority float64    `json:"priority"`            // hours since last scan weighted by found leaks, 0 if repo is not due
+	Leaks    int        `json:"leaks"`               // leaks found in repo before
+	LastScan *time.Time `json:"last_scan,omitempty"` // empty if repo was never scanned
+	NextScan *time.Time `json:"next_scan,omitempty"` // by schedule of repo, empty if repo was never scanned
+	Paused   bool       `json:"paused,omitempty"`    // paused by admin api, it is never due
+	Scanning bool       `json:"scanning,omitempty"`  // repo is being scanned
+}
+
+// AdminRepos - changes of scan list made by admin api
+type AdminRepos struct {
+	Added   []string `json:"added"`   // urls or local paths of repos which are not in config
+	Removed []string `json:"removed"` // urls of repos of config which are not scanned
+	Paused  []string `json:"paused"`  // urls of repos which are kept but not scanned
+}
+
+// IRepoAdmin - runtime management of scan list
+type IRepoAdmin interface {
+	AddRepo(url string) error
+	RemoveRepo(url string) error
+	PauseRepo(url string, paused bool) error
+	TriggerScan(urls ...string) error
+	RescanRepo(url string) error
+	AdminRepos() AdminRepos
+}
+
+// IScanQueue - order in which repos will be scanned
+type IScanQueue interface {
+	ScanQueue() []QueuedRepo
+}
+
+// RuleCost - time spent by family of rules on diffs of repo group
+type RuleCost struct {
+	Family    string  `json:"family"`
+	RepoGroup string  `json:"repo_group"` // owner of repo: GitHub organization or user, GitLab group
+	Seconds   float64 `json:"seconds"`
+	Diffs     int     `json:"diffs"`
+	Matches   int     `json:"matches"` // leaks found by rules before filters
+}
+
+// IRuleCosts - time spent by rules since start
+type IRuleCosts interface {
+	RuleCosts() []RuleCost
+}
+
+// IRepoStore - read-only access to state of scanned repos
+type IRepoStore interface {
+	GetRepos() ([]Repo, error)
 }
 
 type Leak struct {
@@ -75,11 +316,190 @@ type Leak struct {
 	Regexp       string    `json:"pattern"`
 	FilePath     string    `json:"filepath"`
 	RepoPath     string    `json:"repo_path"`
-	LeakString   string    `json:"leak"`
+	LeakString   string    `json:"leak"` // context of secret, whole line
 	RepoURL      string    `json:"repo_url"`
 	CommitHash   string    `json:"commit"`
 	TimeStamp    time.Time `json:"ts"`
 	Line         int       `json:"line"`
 	CommitAuthor string    `json:"author"`
 	CommitEmail  string    `json:"email"`
+	// CommitterEmail - empty if it is the same as email of author
+	CommitterEmail string `json:"committer_email,omitempty"`
+	Severity       string `json:"severity,omitempty"`
+	Language       string `json:"language,omitempty"`
+	// Secret - value of secret in LeakString, empty if pattern doesn't declare secret group
+	Secret string `json:"secret,omitempty"`
+	// Source - edge instance which found leak, empty for own leaks
+	Source string `json:"source,omitempty"`
+	// Kind - empty for leaks found by patterns, LeakKindBulkDump for anomaly events, LeakKindRateLimited for summaries
+	Kind string `json:"kind,omitempty"`
+	// Verified - verified if credential is live, unverified if it is not, empty if it was not checked
+	Verified string `json:"verified,omitempty"`
+	// FoundAt - when leak was sent by router, retention periods are counted from it
+	FoundAt time.Time `json:"found_at"`
+	// Purged - leak was removed by retention, only its fingerprints are kept for dedup
+	Purged bool `json:"purged,omitempty"`
+	// Hashed - leak was found in hash_only mode, its content is replaced by HMAC fingerprints
+	Hashed bool `json:"hashed,omitempty"`
+	// StoredFingerprint and StoredSecretFingerprint are kept when content of leak is purged
+	StoredFingerprint       string `json:"stored_fingerprint,omitempty"`
+	StoredSecretFingerprint string `json:"stored_secret_fingerprint,omitempty"`
+	// Receipt - signature of leak by deployment key, empty if receipts are disabled
+	Receipt *Receipt `json:"receipt,omitempty"`
+	// Signed - signature of commit which introduced leak: gpg, ssh, x509 or unsigned, empty if commit is unknown
+	Signed string `json:"signed,omitempty"`
+	// SigningKey - key id of gpg signature or sha256 fingerprint of ssh key, empty if key can't be read
+	SigningKey string `json:"signing_key,omitempty"`
+	// Context - lines around leak line and the line itself, empty if context_lines is disabled
+	Context string `json:"context,omitempty"`
+}
+
+// Signatures of commits
+const (
+	SignatureGPG  = "gpg"
+	SignatureSSH  = "ssh"
+	SignatureX509 = "x509"
+	Unsigned      = "unsigned"
+)
+
+// Receipt - ed25519 signature of leak or scan manifest
+type Receipt struct {
+	KeyID     string `json:"key_id"`    // first bytes of sha256 of public key in hex
+	Signature string `json:"signature"` // base64 of signature of json without receipt
+}
+
+// LeakKindBulkDump - commit with anomalous number of leaks, it is usually config dump or database export
+const LeakKindBulkDump = "bulk_dump"
+
+// LeakKindRateLimited - summary of leaks which were not sent because of rate limit
+const LeakKindRateLimited = "rate_limited"
+
+// Severities of patterns, medium is used if pattern doesn't declare it
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+// SeverityLevel - order of severity from 1 for low to 4 for critical, 0 if unknown
+func SeverityLevel(severity string) int {
+	switch severity {
+	case SeverityLow:
+		return 1
+	case SeverityMedium:
+		return 2
+	case SeverityHigh:
+		return 3
+	case SeverityCritical:
+		return 4
+	}
+	return 0
+}
+
+// UILeakPath - path of leak page in web UI, fingerprint is appended
+const UILeakPath = "/ui/leaks/"
+
+// Fingerprint - stable id of leak, it doesn't change across restarts and rescans
+func (l Leak) Fingerprint() string {
+	if l.StoredFingerprint != "" {
+		return l.StoredFingerprint
+	}
+	h := sha256.Sum256([]byte(strings.Join([]string{
+		l.RepoURL,
+		l.CommitHash,
+		l.FilePath,
+		strconv.Itoa(l.Line),
+		l.PatternName,
+		l.LeakString,
+	}, "\x00")))
+	return hex.EncodeToString(h[:16])
+}
+
+// redactedMask - placeholder of masked secret
+const redactedMask = "*****"
+
+// Redacted - LeakString with masked secret, the whole match of pattern is masked if secret is unknown
+func (l Leak) Redacted() string {
+	return l.redact(l.LeakString)
+}
+
+// RedactedContext - Context with masked secret, the line of leak is masked like Redacted if secret is unknown
+func (l Leak) RedactedContext() string {
+	return l.redact(l.Context)
+}
+
+func (l Leak) redact(s string) string {
+	if l.Hashed {
+		return s
+	}
+	if l.Secret == "" {
+		if l.LeakString == "" {
+			return l.MaskPattern(s, redactedMask)
+		}
+		if s == l.LeakString {
+			return l.maskLeakString(redactedMask)
+		}
+		// the line of leak in context may differ from leak string by masks of other leaks
+		return l.MaskPattern(strings.Replace(s, l.LeakString, l.maskLeakString(redactedMask), -1), redactedMask)
+	}
+	mask := redactedMask
+	if len(l.Secret) > 8 {
+		// a few first chars help to find the secret in vault or rotate it
+		mask = l.Secret[:3] + mask
+	}
+	return strings.Replace(s, l.Secret, mask, -1)
+}
+
+// compiledPatterns - regexps of leaks by source, search redacts thousands of leaks of a few patterns
+var compiledPatterns sync.Map
+
+// MaskPattern - s with every match of pattern of leak replaced by mask, s as is if pattern doesn't match it,
+// the whole s if pattern can't be compiled
+func (l Leak) MaskPattern(s, mask string) string {
+	var re *regexp.Regexp
+	if cached, ok := compiledPatterns.Load(l.Regexp); ok {
+		re = cached.(*regexp.Regexp)
+	} else if compiled, err := regexp.Compile(l.Regexp); err == nil && l.Regexp != "" {
+		compiledPatterns.Store(l.Regexp, compiled)
+		re = compiled
+	}
+	if re == nil {
+		return mask
+	}
+	result, last := "", 0
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		result += s[last:loc[0]] + mask
+		last = loc[1]
+	}
+	return result + s[last:]
+}
+
+// maskLeakString - LeakString with masked matches of pattern, the whole string if pattern doesn't match it
+func (l Leak) maskLeakString(mask string) string {
+	if masked := l.MaskPattern(l.LeakString, mask); masked != l.LeakString {
+		return masked
+	}
+	return mask
+}
+
+// SecretFingerprint - id of secret in file of repo, it is the same when secret reappears in later commits
+func (l Leak) SecretFingerprint() string {
+	if l.StoredSecretFingerprint != "" {
+		return l.StoredSecretFingerprint
+	}
+	value := l.Secret
+	if value == "" {
+		value = strings.TrimSpace(l.LeakString)
+	}
+	secret := sha256.Sum256([]byte(value))
+	h := sha256.Sum256([]byte(strings.Join([]string{
+		l.RepoURL,
+		l.FilePath,
+		hex.EncodeToString(secret[:]),
+	}, "\x00")))
+	return hex.EncodeToString(h[:16])
 }
@@ -0,0 +1,112 @@
+package language
+
+import (
+	"path"
+	"strings"
+)
+
+// byExtension - languages of file extensions in lower case
+var byExtension = map[string]string{
+	".go":         "go",
+	".py":         "python",
+	".rb":         "ruby",
+	".js":         "javascript",
+	".jsx":        "javascript",
+	".mjs":        "javascript",
+	".ts":         "typescript",
+	".tsx":        "typescript",
+	".java":       "java",
+	".kt":         "kotlin",
+	".kts":        "kotlin",
+	".scala":      "scala",
+	".cs":         "csharp",
+	".c":          "c",
+	".h":          "c",
+	".cc":         "cpp",
+	".cpp":        "cpp",
+	".hpp":        "cpp",
+	".rs":         "rust",
+	".php":        "php",
+	".swift":      "swift",
+	".sh":         "shell",
+	".bash":       "shell",
+	".zsh":        "shell",
+	".ps1":        "powershell",
+	".sql":        "sql",
+	".tf":         "terraform",
+	".yml":        "yaml",
+	".yaml":       "yaml",
+	".json":       "json",
+	".xml":        "xml",
+	".toml":       "toml",
+	".ini":        "ini",
+	".properties": "properties",
+	".env":        "dotenv",
+	".pem":        "pem",
+	".key":        "pem",
+	".md":         "markdown",
+	".html":       "html",
+}
+
+// byName - languages of well-known file names
+var byName = map[string]string{
+	"Dockerfile":  "dockerfile",
+	"Makefile":    "make",
+	"Jenkinsfile": "groovy",
+	"Gemfile":     "ruby",
+	"Rakefile":    "ruby",
+	".env":        "dotenv",
+	"id_rsa":      "pem",
+	"id_dsa":      "pem",
+	"id_ecdsa":    "pem",
+	"id_ed25519":  "pem",
+}
+
+// byInterpreter - languages of shebang interpreters
+var byInterpreter = map[string]string{
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"ruby":    "ruby",
+	"node":    "javascript",
+	"perl":    "perl",
+	"php":     "php",
+}
+
+// Detect - language of file by its name, extension or shebang of content, empty if unknown
+func Detect(filePath, content string) string {
+	name := path.Base(filePath)
+	if lang, ok := byName[name]; ok {
+		return lang
+	}
+	switch {
+	case strings.HasPrefix(name, "Dockerfile."):
+		return "dockerfile"
+	case strings.HasPrefix(name, ".env."):
+		return "dotenv"
+	}
+	if lang, ok := byExtension[strings.ToLower(path.Ext(name))]; ok {
+		return lang
+	}
+	return detectShebang(content)
+}
+
+// detectShebang - interpreter of "#!/usr/bin/env python3" or "#!/bin/sh" in the first line of content
+func detectShebang(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line := strings.SplitN(content[2:], "\n", 2)[0]
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	return byInterpreter[interpreter]
+}
@@ -0,0 +1,24 @@
+package language
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDetect(t *testing.T) {
+	Convey("by name and extension", t, func() {
+		So(Detect("cmd/main.go", ""), ShouldEqual, "go")
+		So(Detect("app/Settings.PY", ""), ShouldEqual, "python")
+		So(Detect("deploy/Dockerfile", ""), ShouldEqual, "dockerfile")
+		So(Detect("deploy/Dockerfile.prod", ""), ShouldEqual, "dockerfile")
+		So(Detect(".env.local", ""), ShouldEqual, "dotenv")
+	})
+	Convey("by shebang", t, func() {
+		So(Detect("bin/deploy", "#!/usr/bin/env python3\nimport os\n"), ShouldEqual, "python")
+		So(Detect("bin/run", "#!/bin/bash -e\n"), ShouldEqual, "shell")
+		So(Detect("bin/run", "\n#!/bin/bash -e\n"), ShouldEqual, "")
+		So(Detect("bin/run", "#!/opt/unknown\n"), ShouldEqual, "")
+		So(Detect("README", "text"), ShouldEqual, "")
+	})
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox/helpers"
+)
+
+// Proxy - choose proxy for outgoing http requests by host, HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used if nothing is configured
+type Proxy struct {
+	Default *url.URL
+	Hosts   map[string]*url.URL
+	NoProxy []string
+}
+
+// New - parse proxy urls, supported schemes are http, https and socks5
+func New(defaultURL string, hosts map[string]string, noProxy []string) (*Proxy, error) {
+	p := &Proxy{
+		Hosts:   map[string]*url.URL{},
+		NoProxy: noProxy,
+	}
+	var err error
+	if defaultURL != "" {
+		if p.Default, err = parseURL(defaultURL); err != nil {
+			return nil, err
+		}
+	}
+	for host, proxyURL := range hosts {
+		if p.Hosts[strings.ToLower(host)], err = parseURL(proxyURL); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func parseURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad proxy url '%s': %v", proxyURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme in '%s'", proxyURL)
+	}
+	return u, nil
+}
+
+// Func - proxy for request, suitable for http.Transport
+func (p *Proxy) Func(req *http.Request) (*url.URL, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	for _, pattern := range p.NoProxy {
+		if helpers.MatchGlob(strings.ToLower(pattern), host) {
+			return nil, nil
+		}
+	}
+	if u, ok := p.Hosts[strings.ToLower(req.URL.Host)]; ok {
+		return u, nil
+	}
+	if u, ok := p.Hosts[host]; ok {
+		return u, nil
+	}
+	if p.Default != nil {
+		return p.Default, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// Client - http client with proxy
+func (p *Proxy) Client() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = p.Func
+	return &http.Client{Transport: transport}
+}
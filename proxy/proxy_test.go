@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func proxyFor(p *Proxy, rawURL string) string {
+	req, _ := http.NewRequest("GET", rawURL, nil)
+	u, err := p.Func(req)
+	So(err, ShouldBeNil)
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+func TestProxy(t *testing.T) {
+	Convey("routing by host", t, func() {
+		p, err := New("http://proxy:3128", map[string]string{
+			"github.com":          "socks5://127.0.0.1:1080",
+			"gitlab.example:8443": "http://gitlab-proxy:3128",
+		}, []string{"*.internal"})
+		So(err, ShouldBeNil)
+		So(proxyFor(p, "https://github.com/a/b.git"), ShouldEqual, "socks5://127.0.0.1:1080")
+		So(proxyFor(p, "https://gitlab.example:8443/api/v4"), ShouldEqual, "http://gitlab-proxy:3128")
+		So(proxyFor(p, "https://bitbucket.example/rest"), ShouldEqual, "http://proxy:3128")
+		So(proxyFor(p, "https://git.corp.internal/a.git"), ShouldEqual, "")
+	})
+	Convey("bad urls", t, func() {
+		_, err := New("ftp://proxy", nil, nil)
+		So(err, ShouldNotBeNil)
+		_, err = New("", map[string]string{"github.com": "%zz"}, nil)
+		So(err, ShouldNotBeNil)
+	})
+}
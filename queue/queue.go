@@ -0,0 +1,344 @@
+// Package queue - bounded queues of diffs and leaks between services with backpressure stats,
+// items which don't fit are waited for or spilled to disk
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/hashonly"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/tomb.v2"
+)
+
+const (
+	// PolicyBlock - producers wait until consumer takes items
+	PolicyBlock = "block"
+	// PolicySpill - items which don't fit are written to spill file and read back when there is free space
+	PolicySpill = "spill"
+)
+
+// Stats - depth and backpressure of queue
+type Stats struct {
+	Name     string `json:"name"`
+	Length   int    `json:"length"`
+	Capacity int    `json:"capacity"`
+	// Blocked - total time producers waited for free space
+	Blocked time.Duration `json:"blocked_ns,omitempty"`
+	Blocks  int64         `json:"blocks,omitempty"`
+	// Spilled - items written to spill file, Pending of them are not read back yet
+	Spilled int64 `json:"spilled,omitempty"`
+	Pending int64 `json:"pending,omitempty"`
+	// Dropped - items which could not be spilled
+	Dropped int64 `json:"dropped,omitempty"`
+}
+
+// Options - capacity and policy of queue
+type Options struct {
+	Capacity int
+	Policy   string // block or spill
+	SpillDir string // spill files are kept here, items spilled before restart are read first
+	// Hasher - leaks are hashed before they are spilled and diffs are never spilled, so secrets are not written to disk
+	Hasher *hashonly.Hasher
+	Log    zerolog.Logger
+}
+
+// counters - backpressure of queue, they are read by stats while pump is running
+type counters struct {
+	blocked int64
+	blocks  int64
+	spilled int64
+	pending int64
+	dropped int64
+}
+
+func (c *counters) stats(name string, length, capacity int) Stats {
+	return Stats{
+		Name:     name,
+		Length:   length,
+		Capacity: capacity,
+		Blocked:  time.Duration(atomic.LoadInt64(&c.blocked)),
+		Blocks:   atomic.LoadInt64(&c.blocks),
+		Spilled:  atomic.LoadInt64(&c.spilled),
+		Pending:  atomic.LoadInt64(&c.pending),
+		Dropped:  atomic.LoadInt64(&c.dropped),
+	}
+}
+
+// block - count time of send to full queue
+func (c *counters) block(send func()) {
+	start := time.Now()
+	send()
+	atomic.AddInt64(&c.blocked, int64(time.Since(start)))
+	atomic.AddInt64(&c.blocks, 1)
+}
+
+// spill - json lines of items which didn't fit into queue, the file is truncated when all of them are read
+type spill struct {
+	path   string
+	writer *os.File
+	file   *os.File
+	reader *bufio.Reader
+	count  *counters
+}
+
+func openSpill(path string, count *counters) (*spill, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	writer, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	s := &spill{path: path, writer: writer, file: file, reader: bufio.NewReader(file), count: count}
+	// items of previous run are pending
+	lines := bufio.NewScanner(file)
+	lines.Buffer(nil, 64*1024*1024)
+	for lines.Scan() {
+		atomic.AddInt64(&count.pending, 1)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		writer.Close()
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *spill) write(item interface{}) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if _, err := s.writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.count.spilled, 1)
+	atomic.AddInt64(&s.count.pending, 1)
+	return nil
+}
+
+// read - the oldest pending item, call it only if there are pending items
+func (s *spill) read(item interface{}) error {
+	line, err := s.reader.ReadBytes('\n')
+	if err != nil {
+		// broken file, the rest of it can't be read
+		atomic.AddInt64(&s.count.dropped, atomic.SwapInt64(&s.count.pending, 0))
+		if resetErr := s.reset(); resetErr != nil {
+			return resetErr
+		}
+		return err
+	}
+	if atomic.AddInt64(&s.count.pending, -1) == 0 {
+		if err := s.reset(); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(line, item)
+}
+
+// reset - truncate file when it has no pending items
+func (s *spill) reset() error {
+	if err := s.writer.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, 0); err != nil {
+		return err
+	}
+	s.reader.Reset(s.file)
+	return nil
+}
+
+// close - items which were read are removed from file, the rest is read after restart
+func (s *spill) close() error {
+	defer s.writer.Close()
+	defer s.file.Close()
+	if atomic.LoadInt64(&s.count.pending) == 0 {
+		return nil
+	}
+	rest, err := ioutil.ReadAll(s.reader)
+	if err != nil {
+		return err
+	}
+	tmpFile := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, rest, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, s.path)
+}
+
+// core - moves items from typed In to typed Out of Diffs and Leaks, items which don't fit wait
+// or are spilled, items wait behind spilled ones so the order is kept
+type core struct {
+	name    string
+	in      reflect.Value
+	out     reflect.Value
+	newItem func() interface{}                                      // empty item which is read from spill file
+	encode  func(item interface{}) interface{}                      // item as it is spilled
+	logItem func(e *zerolog.Event, item interface{}) *zerolog.Event // fields of item in logs
+
+	options Options
+	count   counters
+	spill   *spill
+	tomb    tomb.Tomb
+}
+
+func (q *core) init(name string, in, out interface{}, options Options) error {
+	q.name, q.in, q.out, q.options = name, reflect.ValueOf(in), reflect.ValueOf(out), options
+	if options.Policy != PolicySpill {
+		return nil
+	}
+	var err error
+	if q.spill, err = openSpill(filepath.Join(options.SpillDir, name+".jsonl"), &q.count); err != nil {
+		return fmt.Errorf("can't open spill file of %s: %v", name, err)
+	}
+	return nil
+}
+
+// Start - move items from In to Out
+func (q *core) Start() {
+	q.tomb.Go(q.pump)
+}
+
+// Stop - stop when producers are stopped, spilled items are kept for the next start
+func (q *core) Stop() error {
+	q.tomb.Kill(nil)
+	if err := q.tomb.Wait(); err != nil {
+		return err
+	}
+	if q.spill != nil {
+		return q.spill.close()
+	}
+	return nil
+}
+
+// Stats - depth and backpressure of queue
+func (q *core) Stats() Stats {
+	return q.count.stats(q.name, q.out.Len(), q.out.Cap())
+}
+
+func (q *core) pump() error {
+	var next reflect.Value // item read from spill file, it is sent before new ones
+	for {
+		if !next.IsValid() && atomic.LoadInt64(&q.count.pending) > 0 {
+			item := q.newItem()
+			if err := q.spill.read(item); err != nil {
+				q.options.Log.Error().Str("service", "queue").Str("error", err.Error()).Msgf("can't read spilled item of %s", q.name)
+			} else {
+				next = reflect.ValueOf(item)
+			}
+		}
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(q.tomb.Dying())},
+			{Dir: reflect.SelectRecv, Chan: q.in},
+		}
+		if next.IsValid() {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: q.out, Send: next})
+		}
+		switch chosen, item, _ := reflect.Select(cases); chosen {
+		case 0: // Stop
+			if next.IsValid() {
+				// consumer is stopped after queue, the item which was read from spill file is not lost
+				q.out.Send(next)
+			}
+			return nil
+		case 1:
+			q.push(item, next.IsValid())
+		case 2:
+			next = reflect.Value{}
+		}
+	}
+}
+
+// push - item goes to Out only if nothing waits in spill file, so it doesn't overtake spilled items
+func (q *core) push(item reflect.Value, spilled bool) {
+	if q.spill == nil || (!spilled && atomic.LoadInt64(&q.count.pending) == 0) {
+		if q.out.TrySend(item) {
+			return
+		}
+	}
+	if q.spill == nil {
+		q.count.block(func() { q.out.Send(item) })
+		return
+	}
+	value := item.Interface()
+	if q.encode != nil {
+		value = q.encode(value)
+	}
+	if err := q.spill.write(value); err != nil {
+		atomic.AddInt64(&q.count.dropped, 1)
+		q.logItem(q.options.Log.Error(), item.Interface()).Str("service", "queue").Str("error", err.Error()).Msgf("can't spill item of %s, it is dropped", q.name)
+	}
+}
+
+// Diffs - queue of diffs, scanners send to In and searcher reads Out
+type Diffs struct {
+	In  chan *hungryfox.Diff
+	Out chan *hungryfox.Diff
+	core
+}
+
+// NewDiffs - queue with capacity of Out, In has no buffer so producers wait while the queue is full,
+// diffs have content of files so they are not spilled with Hasher
+func NewDiffs(options Options) (*Diffs, error) {
+	q := &Diffs{
+		In:  make(chan *hungryfox.Diff),
+		Out: make(chan *hungryfox.Diff, options.Capacity),
+	}
+	if options.Hasher != nil {
+		options.Policy = PolicyBlock
+	}
+	q.newItem = func() interface{} { return &hungryfox.Diff{} }
+	q.logItem = func(e *zerolog.Event, item interface{}) *zerolog.Event {
+		diff := item.(*hungryfox.Diff)
+		return e.Str("repo_url", diff.RepoURL).Str("commit", diff.CommitHash)
+	}
+	if err := q.init("diffs", q.In, q.Out, options); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Leaks - queue of leaks, searcher and ingest api send to In and router reads Out
+type Leaks struct {
+	In  chan *hungryfox.Leak
+	Out chan *hungryfox.Leak
+	core
+}
+
+// NewLeaks - queue with capacity of Out, In has no buffer so producers wait while the queue is full
+func NewLeaks(options Options) (*Leaks, error) {
+	q := &Leaks{
+		In:  make(chan *hungryfox.Leak),
+		Out: make(chan *hungryfox.Leak, options.Capacity),
+	}
+	q.newItem = func() interface{} { return &hungryfox.Leak{} }
+	q.logItem = func(e *zerolog.Event, item interface{}) *zerolog.Event {
+		leak := item.(*hungryfox.Leak)
+		return e.Str("repo_url", leak.RepoURL).Str("fingerprint", leak.Fingerprint())
+	}
+	if options.Hasher != nil {
+		q.encode = func(item interface{}) interface{} {
+			leak := options.Hasher.Hash(*item.(*hungryfox.Leak))
+			return &leak
+		}
+	}
+	if err := q.init("leaks", q.In, q.Out, options); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
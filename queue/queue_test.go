@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/hashonly"
+	"github.com/rs/zerolog"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiffs(t *testing.T) {
+	Convey("producer waits while queue is full", t, func() {
+		q, err := NewDiffs(Options{Capacity: 1, Policy: PolicyBlock, Log: zerolog.Nop()})
+		So(err, ShouldBeNil)
+		q.Start()
+		q.In <- &hungryfox.Diff{FilePath: "a"}
+		sent := make(chan struct{})
+		go func() {
+			q.In <- &hungryfox.Diff{FilePath: "b"}
+			close(sent)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		So((<-q.Out).FilePath, ShouldEqual, "a")
+		<-sent
+		So((<-q.Out).FilePath, ShouldEqual, "b")
+		So(q.Stop(), ShouldBeNil)
+		stats := q.Stats()
+		So(stats.Blocks, ShouldEqual, 1)
+		So(stats.Blocked, ShouldBeGreaterThan, 0)
+		So(stats.Capacity, ShouldEqual, 1)
+	})
+
+	Convey("diffs which don't fit are spilled", t, func() {
+		dir, err := ioutil.TempDir("", "hungryfox-spill")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		q, err := NewDiffs(Options{Capacity: 1, Policy: PolicySpill, SpillDir: dir, Log: zerolog.Nop()})
+		So(err, ShouldBeNil)
+		q.Start()
+		for _, name := range []string{"a", "b", "c", "d"} {
+			q.In <- &hungryfox.Diff{FilePath: name, Content: "password: qwerty"}
+		}
+		for i := 0; i < 1000 && q.Stats().Spilled < 3; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		So(q.Stats().Spilled, ShouldEqual, 3)
+		// searcher reads diffs while queue is stopped, the diff taken from spill file is not lost
+		stopped := make(chan error)
+		go func() { stopped <- q.Stop() }()
+		received := []string{}
+		for done := false; !done; {
+			select {
+			case d := <-q.Out:
+				received = append(received, d.FilePath)
+			case err := <-stopped:
+				So(err, ShouldBeNil)
+				done = true
+			}
+		}
+		for len(q.Out) > 0 {
+			received = append(received, (<-q.Out).FilePath)
+		}
+		So(received[:2], ShouldResemble, []string{"a", "b"})
+		So(int64(len(received))+q.Stats().Pending, ShouldEqual, 4)
+
+		Convey("spilled diffs are read after restart", func() {
+			pending := q.Stats().Pending
+			q, err := NewDiffs(Options{Capacity: 1, Policy: PolicySpill, SpillDir: dir, Log: zerolog.Nop()})
+			So(err, ShouldBeNil)
+			So(q.Stats().Pending, ShouldEqual, pending)
+			q.Start()
+			for _, name := range []string{"a", "b", "c", "d"}[len(received):] {
+				d := <-q.Out
+				So(d.FilePath, ShouldEqual, name)
+				So(d.Content, ShouldEqual, "password: qwerty")
+			}
+			So(q.Stop(), ShouldBeNil)
+			So(q.Stats().Pending, ShouldEqual, 0)
+			info, err := os.Stat(filepath.Join(dir, "diffs.jsonl"))
+			So(err, ShouldBeNil)
+			So(info.Size(), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestLeaks(t *testing.T) {
+	Convey("leaks pass through queue in order", t, func() {
+		q, err := NewLeaks(Options{Capacity: 10, Policy: PolicyBlock, Log: zerolog.Nop()})
+		So(err, ShouldBeNil)
+		q.Start()
+		for _, name := range []string{"a", "b"} {
+			q.In <- &hungryfox.Leak{FilePath: name}
+		}
+		So(q.Stop(), ShouldBeNil)
+		So((<-q.Out).FilePath, ShouldEqual, "a")
+		So((<-q.Out).FilePath, ShouldEqual, "b")
+		So(q.Stats().Blocks, ShouldEqual, 0)
+	})
+
+	Convey("new leaks don't overtake spilled ones", t, func() {
+		dir, err := ioutil.TempDir("", "hungryfox-spill")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		q, err := NewLeaks(Options{Capacity: 1, Policy: PolicySpill, SpillDir: dir, Log: zerolog.Nop()})
+		So(err, ShouldBeNil)
+		q.Start()
+		for _, name := range []string{"a", "b", "c"} {
+			q.In <- &hungryfox.Leak{FilePath: name}
+		}
+		received := []string{(<-q.Out).FilePath}
+		for _, name := range []string{"d", "e"} {
+			q.In <- &hungryfox.Leak{FilePath: name}
+		}
+		for len(received) < 5 {
+			received = append(received, (<-q.Out).FilePath)
+		}
+		So(received, ShouldResemble, []string{"a", "b", "c", "d", "e"})
+		So(q.Stop(), ShouldBeNil)
+	})
+
+	Convey("secrets are not spilled in hash only mode", t, func() {
+		dir, err := ioutil.TempDir("", "hungryfox-spill")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		hasher, err := hashonly.New([]byte("0123456789abcdef0123456789abcdef"))
+		So(err, ShouldBeNil)
+		q, err := NewLeaks(Options{Capacity: 1, Policy: PolicySpill, SpillDir: dir, Hasher: hasher, Log: zerolog.Nop()})
+		So(err, ShouldBeNil)
+		q.Start()
+		for _, name := range []string{"a", "b", "c"} {
+			q.In <- &hungryfox.Leak{FilePath: name, LeakString: "password: qwerty"}
+		}
+		for i := 0; i < 1000 && q.Stats().Spilled < 2; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		So(q.Stats().Spilled, ShouldEqual, 2)
+		data, err := ioutil.ReadFile(filepath.Join(dir, "leaks.jsonl"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldNotContainSubstring, "qwerty")
+		So(string(data), ShouldContainSubstring, `"hashed":true`)
+		So((<-q.Out).LeakString, ShouldEqual, "password: qwerty")
+		So((<-q.Out).Hashed, ShouldBeTrue)
+		So(q.Stop(), ShouldBeNil)
+
+		Convey("diffs are not spilled", func() {
+			q, err := NewDiffs(Options{Capacity: 1, Policy: PolicySpill, SpillDir: dir, Hasher: hasher, Log: zerolog.Nop()})
+			So(err, ShouldBeNil)
+			So(q.spill, ShouldBeNil)
+			_, err = os.Stat(filepath.Join(dir, "diffs.jsonl"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
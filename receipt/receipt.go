@@ -0,0 +1,174 @@
+// Package receipt - signatures of found leaks and scan manifests by deployment key,
+// exported evidence can be verified as unaltered with public key
+package receipt
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// ManifestVersion - version of manifest format
+const ManifestVersion = 1
+
+// errors of verification
+var (
+	ErrNotSigned    = errors.New("receipt is missing")
+	ErrPurged       = errors.New("content is purged by retention")
+	ErrKeyMismatch  = errors.New("signed by another key")
+	ErrBadSignature = errors.New("signature doesn't match content")
+)
+
+// Manifest - what was scanned and which leaks were found
+type Manifest struct {
+	Version    int                `json:"version"`
+	Repo       string             `json:"repo"`
+	Refs       []string           `json:"refs"` // scanned refs with their hashes
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+	Leaks      []string           `json:"leaks"` // fingerprints of found leaks
+	Receipt    *hungryfox.Receipt `json:"receipt,omitempty"`
+}
+
+// Signer - signs with private key
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// LoadSigner - read PKCS8 PEM private key, e.g. from `openssl genpkey -algorithm ed25519`
+func LoadSigner(keyFile string) (*Signer, error) {
+	block, err := readPEM(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse private key %s: %v", keyFile, err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not ed25519", keyFile)
+	}
+	return &Signer{key: privateKey, keyID: KeyID(privateKey.Public().(ed25519.PublicKey))}, nil
+}
+
+// LoadPublicKey - read PKIX PEM public key or public part of private key
+func LoadPublicKey(keyFile string) (ed25519.PublicKey, error) {
+	block, err := readPEM(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "PRIVATE KEY" {
+		signer, err := LoadSigner(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return signer.key.Public().(ed25519.PublicKey), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse public key %s: %v", keyFile, err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not ed25519", keyFile)
+	}
+	return publicKey, nil
+}
+
+func readPEM(keyFile string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem data in %s", keyFile)
+	}
+	return block, nil
+}
+
+// KeyID - short id of key to find the key of receipt after key rotation
+func KeyID(key ed25519.PublicKey) string {
+	h := sha256.Sum256(key)
+	return hex.EncodeToString(h[:8])
+}
+
+// SignLeak - set receipt of leak, it covers every field of leak
+func (s *Signer) SignLeak(leak *hungryfox.Leak) error {
+	leak.Receipt = nil
+	payload, err := json.Marshal(leak)
+	if err != nil {
+		return err
+	}
+	leak.Receipt = s.sign(payload)
+	return nil
+}
+
+// SignManifest - set receipt of manifest
+func (s *Signer) SignManifest(m *Manifest) error {
+	m.Receipt = nil
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	m.Receipt = s.sign(payload)
+	return nil
+}
+
+func (s *Signer) sign(payload []byte) *hungryfox.Receipt {
+	return &hungryfox.Receipt{
+		KeyID:     s.keyID,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, payload)),
+	}
+}
+
+// VerifyLeak - leak is not changed since it was signed by key
+func VerifyLeak(leak hungryfox.Leak, key ed25519.PublicKey) error {
+	if leak.Purged || (leak.LeakString == "" && leak.StoredFingerprint != "" && !leak.Hashed) {
+		return ErrPurged
+	}
+	r := leak.Receipt
+	leak.Receipt = nil
+	payload, err := json.Marshal(leak)
+	if err != nil {
+		return err
+	}
+	return verify(payload, r, key)
+}
+
+// VerifyManifest - manifest is not changed since it was signed by key
+func VerifyManifest(m Manifest, key ed25519.PublicKey) error {
+	r := m.Receipt
+	m.Receipt = nil
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return verify(payload, r, key)
+}
+
+func verify(payload []byte, r *hungryfox.Receipt, key ed25519.PublicKey) error {
+	if r == nil {
+		return ErrNotSigned
+	}
+	if r.KeyID != KeyID(key) {
+		return ErrKeyMismatch
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil || !ed25519.Verify(key, payload, signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
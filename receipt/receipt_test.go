@@ -0,0 +1,86 @@
+package receipt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeKeys(dir string) (string, string) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	privateDER, _ := x509.MarshalPKCS8PrivateKey(privateKey)
+	publicDER, _ := x509.MarshalPKIXPublicKey(publicKey)
+	privateFile := filepath.Join(dir, "key.pem")
+	publicFile := filepath.Join(dir, "key.pub")
+	ioutil.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600)
+	ioutil.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644)
+	return privateFile, publicFile
+}
+
+func TestReceipt(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "receipt")
+	defer os.RemoveAll(dir)
+	privateFile, publicFile := writeKeys(dir)
+	os.Mkdir(filepath.Join(dir, "other"), 0755)
+	otherFile, _ := writeKeys(filepath.Join(dir, "other"))
+
+	signer, err := LoadSigner(privateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := LoadPublicKey(publicFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leak := hungryfox.Leak{
+		RepoURL:    "https://github.com/backend/api",
+		FilePath:   ".env",
+		LeakString: "password=123",
+		TimeStamp:  time.Date(2018, 7, 1, 0, 0, 0, 0, time.FixedZone("MSK", 3*3600)),
+		FoundAt:    time.Now(),
+	}
+
+	Convey("signed leak is verified after json round trip", t, func() {
+		So(signer.SignLeak(&leak), ShouldBeNil)
+		data, _ := json.Marshal(leak)
+		stored := hungryfox.Leak{}
+		So(json.Unmarshal(data, &stored), ShouldBeNil)
+		So(VerifyLeak(stored, publicKey), ShouldBeNil)
+	})
+	Convey("public key is taken from private key too", t, func() {
+		key, err := LoadPublicKey(privateFile)
+		So(err, ShouldBeNil)
+		So(VerifyLeak(leak, key), ShouldBeNil)
+	})
+	Convey("changed leak is not verified", t, func() {
+		changed := leak
+		changed.Severity = hungryfox.SeverityLow
+		So(VerifyLeak(changed, publicKey), ShouldEqual, ErrBadSignature)
+		changed = leak
+		changed.Receipt = nil
+		So(VerifyLeak(changed, publicKey), ShouldEqual, ErrNotSigned)
+	})
+	Convey("leak of another key", t, func() {
+		otherKey, err := LoadPublicKey(otherFile)
+		So(err, ShouldBeNil)
+		So(VerifyLeak(leak, otherKey), ShouldEqual, ErrKeyMismatch)
+	})
+	Convey("manifest", t, func() {
+		m := Manifest{Version: ManifestVersion, Repo: "/src/api", Leaks: []string{leak.Fingerprint()}}
+		So(signer.SignManifest(&m), ShouldBeNil)
+		So(VerifyManifest(m, publicKey), ShouldBeNil)
+		m.Leaks = nil
+		So(VerifyManifest(m, publicKey), ShouldEqual, ErrBadSignature)
+	})
+}
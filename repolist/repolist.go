@@ -1,17 +1,27 @@
 package repolist
 
 import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/AlexAkulov/hungryfox"
 )
 
 type RepoList struct {
-	list []hungryfox.Repo
+	list  []hungryfox.Repo
 	State hungryfox.IStateManager
+	// NextScan - time when scanned repo is due again, end of its last scan plus interval if nil
+	NextScan func(r hungryfox.Repo) time.Time
+	mutex    sync.RWMutex    // scan queue is read by api while scan manager changes the list
+	paused   map[string]bool // urls of repos paused by admin api, they are kept on Clear
 }
 
 func (l *RepoList) Clear() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
 	l.list = nil
 }
 
@@ -30,15 +40,53 @@ func (l *RepoList) addRepo(r hungryfox.Repo) {
 
 func (l *RepoList) AddRepo(r hungryfox.Repo) {
 	r.State, r.Scan = l.State.Load(r.Location.URL)
+	if !r.State.RemovedAt.IsZero() {
+		// repo came back after removal, the next removal starts a new grace period
+		r.State.RemovedAt = time.Time{}
+		l.State.Save(r)
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
 	l.addRepo(r)
 }
 
+// RemoveRepo - remove repo with url from list, false if there is no such repo
+func (l *RepoList) RemoveRepo(url string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i := range l.list {
+		if l.list[i].Location.URL == url {
+			l.list = append(l.list[:i], l.list[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetPaused - paused repo stays in list but is not scanned until it is resumed
+func (l *RepoList) SetPaused(url string, paused bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.paused == nil {
+		l.paused = map[string]bool{}
+	}
+	if paused {
+		l.paused[url] = true
+	} else {
+		delete(l.paused, url)
+	}
+}
+
 func (l *RepoList) UpdateRepo(r hungryfox.Repo) {
+	l.mutex.Lock()
 	l.addRepo(r)
+	l.mutex.Unlock()
 	l.State.Save(r)
 }
 
 func (l *RepoList) GetRepoByIndex(i int) *hungryfox.Repo {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
 	if i > len(l.list)-1 || i < 0 {
 		return nil
 	}
@@ -46,21 +94,112 @@ func (l *RepoList) GetRepoByIndex(i int) *hungryfox.Repo {
 	return &r
 }
 
-func (l *RepoList) GetRepoForScan() int {
-	rID := -1
-	lastScan := time.Now().UTC()
+// GetRepoForScan - index of the first not paused repo of scan queue, -1 if there is no such
+func (l *RepoList) GetRepoForScan(now time.Time, interval time.Duration, leaks map[string]int) int {
+	for _, q := range l.scanOrder(now, interval, leaks) {
+		if !q.Paused {
+			return q.index
+		}
+	}
+	return -1
+}
+
+// ScanQueue - repos in order of scan, see scanOrder
+func (l *RepoList) ScanQueue(now time.Time, interval time.Duration, leaks map[string]int) []hungryfox.QueuedRepo {
+	order := l.scanOrder(now, interval, leaks)
+	result := make([]hungryfox.QueuedRepo, len(order))
+	for i := range order {
+		result[i] = order[i].QueuedRepo
+	}
+	return result
+}
+
+type queuedRepo struct {
+	hungryfox.QueuedRepo
+	index int
+}
+
+// scanOrder - never scanned repos go first in order of config, then repos which are due by interval or NextScan
+// by hours since last scan weighted by number of leaks found in them before, so after downtime the riskiest
+// backlog is cleared first, then the rest by time of last scan, paused repos are never due and go last
+func (l *RepoList) scanOrder(now time.Time, interval time.Duration, leaks map[string]int) []queuedRepo {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	result := make([]queuedRepo, 0, len(l.list))
 	for i, r := range l.list {
+		q := queuedRepo{index: i}
+		q.URL = r.Location.URL
+		q.Leaks = leaks[r.Location.URL]
+		q.Paused = l.paused[r.Location.URL]
 		if r.Scan.StartTime.IsZero() {
-			return i
+			q.Due = true
+		} else {
+			lastScan := r.Scan.EndTime
+			q.LastScan = &lastScan
+			nextScan := lastScan.Add(interval)
+			if l.NextScan != nil {
+				nextScan = l.NextScan(r)
+			}
+			if !q.Paused {
+				q.NextScan = &nextScan
+			}
+			q.Due = now.After(nextScan)
+			if q.Due {
+				q.Priority = now.Sub(lastScan).Hours() * (1 + math.Log2(1+float64(q.Leaks)))
+			}
+		}
+		if q.Paused {
+			q.Due, q.Priority = false, 0
+		}
+		result = append(result, q)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Paused != b.Paused {
+			return b.Paused
+		}
+		if (a.LastScan == nil) != (b.LastScan == nil) {
+			return a.LastScan == nil
+		}
+		if a.Due != b.Due {
+			return a.Due
+		}
+		if a.Due && a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.LastScan != nil && b.LastScan != nil {
+			return a.LastScan.Before(*b.LastScan)
+		}
+		return false
+	})
+	return result
+}
+
+func normalizeURL(url string) string {
+	url = strings.ToLower(strings.TrimSuffix(url, "/"))
+	return strings.TrimSuffix(url, ".git")
+}
+
+// FindRepo - get index of repo with any of urls or clone urls, -1 if not found
+func (l *RepoList) FindRepo(urls ...string) int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	for _, url := range urls {
+		if url == "" {
+			continue
 		}
-		if r.Scan.EndTime.Before(lastScan) {
-			rID = i
-			lastScan = r.Scan.EndTime
+		url = normalizeURL(url)
+		for i, r := range l.list {
+			if normalizeURL(r.Location.URL) == url || normalizeURL(r.Location.CloneURL) == url {
+				return i
+			}
 		}
 	}
-	return rID
+	return -1
 }
 
 func (l *RepoList) GetTotalRepos() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
 	return len(l.list)
 }
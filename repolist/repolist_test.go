@@ -31,6 +31,13 @@ func (f FakeStateManager) Save(r hungryfox.Repo) {
 	f.data = append(f.data, r)
 }
 
+func (f FakeStateManager) List() []hungryfox.Repo {
+	return f.data
+}
+
+func (f FakeStateManager) Delete(url string) {
+}
+
 func TestGetRepoForScan(t *testing.T) {
 	Convey("addRepoToScan", t, func() {
 		now := time.Now().UTC()
@@ -71,7 +78,7 @@ func TestGetRepoForScan(t *testing.T) {
 
 		So(len(rl.list), ShouldEqual, 4)
 		for _, expectedID := range []int{2, 1, 3, 0} {
-			id := rl.GetRepoForScan()
+			id := rl.GetRepoForScan(now, 0, nil)
 			So(id, ShouldEqual, expectedID)
 			r := *rl.GetRepoByIndex(id)
 			So(r, ShouldResemble, testData[expectedID])
@@ -81,3 +88,37 @@ func TestGetRepoForScan(t *testing.T) {
 		}
 	})
 }
+
+func TestScanQueue(t *testing.T) {
+	Convey("stale repos with leaks are scanned first", t, func() {
+		now := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+		scanned := func(url string, ago time.Duration) hungryfox.Repo {
+			return hungryfox.Repo{
+				Location: hungryfox.RepoLocation{URL: url},
+				Scan:     hungryfox.ScanStatus{StartTime: now.Add(-ago), EndTime: now.Add(-ago)},
+			}
+		}
+		testData := []hungryfox.Repo{
+			scanned("fresh", 10*time.Minute),
+			scanned("stale clean", 48*time.Hour),
+			scanned("stale leaky", 24*time.Hour),
+			{Location: hungryfox.RepoLocation{URL: "new"}},
+			scanned("recent", 20*time.Minute),
+		}
+		rl := RepoList{State: FakeStateManager{data: testData}}
+		for _, r := range testData {
+			rl.AddRepo(r)
+		}
+		queue := rl.ScanQueue(now, 30*time.Minute, map[string]int{"stale leaky": 7, "fresh": 100})
+		urls := []string{}
+		for _, q := range queue {
+			urls = append(urls, q.URL)
+		}
+		So(urls, ShouldResemble, []string{"new", "stale leaky", "stale clean", "recent", "fresh"})
+		So(queue[1].Due, ShouldBeTrue)
+		So(queue[1].Priority, ShouldEqual, 24*4)
+		So(queue[3].Due, ShouldBeFalse)
+		So(queue[3].Priority, ShouldEqual, 0)
+		So(rl.GetRepoForScan(now, 30*time.Minute, map[string]int{"stale leaky": 7}), ShouldEqual, 3)
+	})
+}
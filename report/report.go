@@ -0,0 +1,203 @@
+// Package report - summary of leaks by repo, rule, author and commit signature for management and compliance, secrets are never included
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// Row - leaks of repo, rule or author by status, severities are counted for open leaks
+type Row struct {
+	Name     string
+	Leaks    int
+	Open     int
+	Resolved int
+	Ignored  int
+	Critical int
+	High     int
+	Medium   int
+	Low      int
+}
+
+func (r *Row) add(leak hungryfox.Leak, status string) {
+	r.Leaks++
+	switch status {
+	case hungryfox.StatusResolved, hungryfox.StatusRotated:
+		r.Resolved++
+		return
+	case hungryfox.StatusIgnored, hungryfox.StatusFalsePositive:
+		r.Ignored++
+		return
+	}
+	r.Open++
+	switch leak.Severity {
+	case hungryfox.SeverityCritical:
+		r.Critical++
+	case hungryfox.SeverityHigh:
+		r.High++
+	case hungryfox.SeverityLow:
+		r.Low++
+	default:
+		r.Medium++
+	}
+}
+
+// Report - leaks grouped by repo, rule, author and signature of commit
+type Report struct {
+	At      time.Time // statuses are taken at this time
+	Total   Row
+	Repos   []*Row
+	Rules   []*Row
+	Authors []*Row
+	// Signatures - gpg, ssh, x509, unsigned or unknown if commit was not read
+	Signatures []*Row
+	// Languages - language of file detected by searcher, unknown if it wasn't detected
+	Languages []*Row
+}
+
+// Build - report of leaks with statuses at time at, purged leaks are not counted
+func Build(leaks []hungryfox.Leak, history map[string][]hungryfox.StatusEvent, at time.Time) *Report {
+	result := &Report{At: at, Total: Row{Name: "total"}}
+	repos, rules, authors, signatures, languages := map[string]*Row{}, map[string]*Row{}, map[string]*Row{}, map[string]*Row{}, map[string]*Row{}
+	for _, leak := range leaks {
+		if leak.Purged {
+			continue
+		}
+		status := hungryfox.StatusAsOf(history[leak.Fingerprint()], at)
+		result.Total.add(leak, status)
+		group(repos, leak.RepoURL).add(leak, status)
+		group(rules, leak.PatternName).add(leak, status)
+		group(authors, author(leak)).add(leak, status)
+		group(signatures, leak.Signed).add(leak, status)
+		group(languages, leak.Language).add(leak, status)
+	}
+	result.Repos, result.Rules, result.Authors, result.Signatures = sorted(repos), sorted(rules), sorted(authors), sorted(signatures)
+	result.Languages = sorted(languages)
+	return result
+}
+
+func author(leak hungryfox.Leak) string {
+	switch {
+	case leak.CommitAuthor == "":
+		return leak.CommitEmail
+	case leak.CommitEmail == "":
+		return leak.CommitAuthor
+	}
+	return fmt.Sprintf("%s <%s>", leak.CommitAuthor, leak.CommitEmail)
+}
+
+func group(rows map[string]*Row, name string) *Row {
+	if name == "" {
+		name = "unknown"
+	}
+	row := rows[name]
+	if row == nil {
+		row = &Row{Name: name}
+		rows[name] = row
+	}
+	return row
+}
+
+// sorted - rows with more open leaks first
+func sorted(rows map[string]*Row) []*Row {
+	result := make([]*Row, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Open != result[j].Open {
+			return result[i].Open > result[j].Open
+		}
+		if result[i].Leaks != result[j].Leaks {
+			return result[i].Leaks > result[j].Leaks
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// csvHeader - columns of WriteCSV
+var csvHeader = []string{"group", "name", "leaks", "open", "resolved", "ignored", "open_critical", "open_high", "open_medium", "open_low"}
+
+// WriteCSV - one row per repo, rule, author, signature and language with group column, total is the first row
+func (r *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write(csvHeader)
+	write := func(groupName string, row *Row) {
+		record := []string{groupName, row.Name}
+		for _, n := range []int{row.Leaks, row.Open, row.Resolved, row.Ignored, row.Critical, row.High, row.Medium, row.Low} {
+			record = append(record, strconv.Itoa(n))
+		}
+		writer.Write(record)
+	}
+	write("total", &r.Total)
+	for _, g := range []struct {
+		name string
+		rows []*Row
+	}{{"repo", r.Repos}, {"rule", r.Rules}, {"author", r.Authors}, {"signature", r.Signatures}, {"language", r.Languages}} {
+		for _, row := range g.rows {
+			write(g.name, row)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type section struct {
+	Title string
+	Rows  []*Row
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"section": func(title string, rows []*Row) section { return section{title, rows} },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>HungryFox report {{ .At.Format "2006-01-02" }}</title>
+  <style>
+    body { font-family: sans-serif; margin: 2em; color: #111111; }
+    table { border-collapse: collapse; margin-bottom: 2em; }
+    td, th { padding: 0.2em 1em; border-bottom: 1px solid #dddddd; }
+    th { text-align: left; }
+    td.n { text-align: right; }
+    .bad { color: #e05d44; }
+  </style>
+</head>
+<body>
+  <h1>HungryFox report</h1>
+  <p>Statuses as of {{ .At.Format "2006-01-02 15:04 MST" }}. {{ .Total.Open }} open, {{ .Total.Resolved }} resolved and {{ .Total.Ignored }} ignored of {{ .Total.Leaks }} leaks in {{ len .Repos }} repos.</p>
+{{ define "rows" }}
+  <table>
+    <tr><th>{{ .Title }}</th><th>Leaks</th><th>Open</th><th>Resolved</th><th>Ignored</th><th>Critical</th><th>High</th><th>Medium</th><th>Low</th></tr>
+  {{ range .Rows }}
+    <tr><td>{{ .Name }}</td><td class="n">{{ .Leaks }}</td><td class="n">{{ .Open }}</td><td class="n">{{ .Resolved }}</td><td class="n">{{ .Ignored }}</td><td class="n{{ if .Critical }} bad{{ end }}">{{ .Critical }}</td><td class="n">{{ .High }}</td><td class="n">{{ .Medium }}</td><td class="n">{{ .Low }}</td></tr>
+  {{ end }}
+  </table>
+{{ end }}
+  <h2>Repos</h2>
+  {{ template "rows" (section "Repo" .Repos) }}
+  <h2>Rules</h2>
+  {{ template "rows" (section "Rule" .Rules) }}
+  <h2>Authors</h2>
+  {{ template "rows" (section "Author" .Authors) }}
+  <h2>Commit signatures</h2>
+  {{ template "rows" (section "Signature" .Signatures) }}
+  <h2>Languages</h2>
+  {{ template "rows" (section "Language" .Languages) }}
+  <p>Critical, high, medium and low are numbers of open leaks.</p>
+</body>
+</html>
+`))
+
+// WriteHTML - standalone html page of report
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,144 @@
+package retention
+
+import (
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+	"github.com/AlexAkulov/hungryfox/findings"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/tomb.v2"
+)
+
+// Policy - how long leaks are kept, zero period keeps forever
+type Policy struct {
+	ContentAfter  time.Duration // leak strings are removed this time after leak was found
+	ResolvedAfter time.Duration // resolved and ignored leaks are removed this time after status change
+}
+
+// Stats - result of policy
+type Stats struct {
+	ContentPurged int
+	LeaksPurged   int
+}
+
+// Apply - leaks with purged content, purged leaks are replaced by their fingerprints,
+// so they are not reported again
+func (p Policy) Apply(leaks []hungryfox.Leak, history map[string][]hungryfox.StatusEvent, now time.Time) ([]hungryfox.Leak, Stats) {
+	stats := Stats{}
+	result := make([]hungryfox.Leak, 0, len(leaks))
+	for _, leak := range leaks {
+		if leak.Purged {
+			result = append(result, leak)
+			continue
+		}
+		if p.ResolvedAfter > 0 {
+			if closedAt, ok := closedAt(history[leak.Fingerprint()], now); ok && now.Sub(closedAt) > p.ResolvedAfter {
+				result = append(result, hungryfox.Leak{
+					StoredFingerprint:       leak.Fingerprint(),
+					StoredSecretFingerprint: leak.SecretFingerprint(),
+					Purged:                  true,
+				})
+				stats.LeaksPurged++
+				continue
+			}
+		}
+		foundAt := leak.FoundAt
+		if foundAt.IsZero() {
+			// leaks found before found_at was added
+			foundAt = leak.TimeStamp
+		}
+		if p.ContentAfter > 0 && leak.LeakString != "" && now.Sub(foundAt) > p.ContentAfter {
+			leak.StoredFingerprint = leak.Fingerprint()
+			leak.StoredSecretFingerprint = leak.SecretFingerprint()
+			leak.LeakString = ""
+			leak.Context = ""
+			leak.Secret = ""
+			stats.ContentPurged++
+		}
+		result = append(result, leak)
+	}
+	return result, stats
+}
+
+// closedAt - time of the last status change if leak is closed now, acknowledged leaks are open
+func closedAt(events []hungryfox.StatusEvent, now time.Time) (time.Time, bool) {
+	events = hungryfox.EffectiveEvents(events, now)
+	if len(events) == 0 {
+		return time.Time{}, false
+	}
+	last := events[len(events)-1]
+	if hungryfox.IsOpen(last.Status) {
+		return time.Time{}, false
+	}
+	return last.Time, true
+}
+
+// Rewriter - leaks store which can be changed in place
+type Rewriter interface {
+	Rewrite(func([]hungryfox.Leak) []hungryfox.Leak) error
+}
+
+// Janitor - apply policy to leaks store periodically
+type Janitor struct {
+	Policy   Policy
+	Leaks    Rewriter
+	Statuses hungryfox.IStatusStore // every leak is open if nil
+	Interval time.Duration
+	DryRun   bool        // only log what would be purged
+	Clock    clock.Clock // system clock if nil
+	Log      zerolog.Logger
+
+	tomb tomb.Tomb
+}
+
+// Run - apply policy once
+func (j *Janitor) Run() (Stats, error) {
+	history := map[string][]hungryfox.StatusEvent{}
+	if j.Statuses != nil {
+		events, err := j.Statuses.GetEvents()
+		if err != nil {
+			return Stats{}, err
+		}
+		history = findings.StatusHistory(events)
+	}
+	stats := Stats{}
+	err := j.Leaks.Rewrite(func(leaks []hungryfox.Leak) []hungryfox.Leak {
+		var result []hungryfox.Leak
+		result, stats = j.Policy.Apply(leaks, history, clock.Or(j.Clock).Now())
+		if j.DryRun || stats == (Stats{}) {
+			return nil
+		}
+		return result
+	})
+	if err != nil {
+		return stats, err
+	}
+	j.Log.Info().Str("service", "retention").Bool("dry_run", j.DryRun).
+		Int("content_purged", stats.ContentPurged).
+		Int("leaks_purged", stats.LeaksPurged).
+		Msg("retention policy applied")
+	return stats, nil
+}
+
+func (j *Janitor) Start() error {
+	j.tomb.Go(func() error {
+		for {
+			if _, err := j.Run(); err != nil {
+				j.Log.Error().Str("service", "retention").Str("error", err.Error()).Msg("can't apply retention policy")
+			}
+			select {
+			case <-j.tomb.Dying():
+				return nil
+			case <-time.After(j.Interval):
+			}
+		}
+	})
+	return nil
+}
+
+func (j *Janitor) Stop() error {
+	j.tomb.Kill(nil)
+	return j.tomb.Wait()
+}
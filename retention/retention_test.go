@@ -0,0 +1,66 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/rs/zerolog"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeRewriter struct {
+	leaks []hungryfox.Leak
+}
+
+func (f *fakeRewriter) Rewrite(change func([]hungryfox.Leak) []hungryfox.Leak) error {
+	if result := change(f.leaks); result != nil {
+		f.leaks = result
+	}
+	return nil
+}
+
+type fakeStatuses []hungryfox.StatusEvent
+
+func (f fakeStatuses) GetEvents() ([]hungryfox.StatusEvent, error) { return f, nil }
+func (f fakeStatuses) AddEvent(hungryfox.StatusEvent) error        { return nil }
+
+func TestJanitor(t *testing.T) {
+	now := time.Now()
+	fresh := hungryfox.Leak{RepoURL: "r", LeakString: "fresh", FoundAt: now.Add(-time.Hour)}
+	old := hungryfox.Leak{RepoURL: "r", LeakString: "old", Context: "# db\nold", FoundAt: now.Add(-100 * 24 * time.Hour)}
+	resolved := hungryfox.Leak{RepoURL: "r", LeakString: "resolved", FoundAt: now.Add(-time.Hour)}
+	statuses := fakeStatuses{
+		{Fingerprint: resolved.Fingerprint(), Status: hungryfox.StatusResolved, Time: now.Add(-3 * 365 * 24 * time.Hour)},
+	}
+	policy := Policy{ContentAfter: 90 * 24 * time.Hour, ResolvedAfter: 2 * 365 * 24 * time.Hour}
+
+	Convey("dry run keeps leaks", t, func() {
+		store := &fakeRewriter{leaks: []hungryfox.Leak{fresh, old, resolved}}
+		j := &Janitor{Policy: policy, Leaks: store, Statuses: statuses, DryRun: true, Log: zerolog.Nop()}
+		stats, err := j.Run()
+		So(err, ShouldBeNil)
+		So(stats, ShouldResemble, Stats{ContentPurged: 1, LeaksPurged: 1})
+		So(store.leaks, ShouldResemble, []hungryfox.Leak{fresh, old, resolved})
+	})
+	Convey("purged leaks keep fingerprints", t, func() {
+		store := &fakeRewriter{leaks: []hungryfox.Leak{fresh, old, resolved}}
+		j := &Janitor{Policy: policy, Leaks: store, Statuses: statuses, Log: zerolog.Nop()}
+		_, err := j.Run()
+		So(err, ShouldBeNil)
+		So(store.leaks[0], ShouldResemble, fresh)
+		So(store.leaks[1].LeakString, ShouldBeEmpty)
+		So(store.leaks[1].Context, ShouldBeEmpty)
+		So(store.leaks[1].Fingerprint(), ShouldEqual, old.Fingerprint())
+		So(store.leaks[2].Purged, ShouldBeTrue)
+		So(store.leaks[2].RepoURL, ShouldBeEmpty)
+		So(store.leaks[2].SecretFingerprint(), ShouldEqual, resolved.SecretFingerprint())
+
+		Convey("second run changes nothing", func() {
+			stats, err := j.Run()
+			So(err, ShouldBeNil)
+			So(stats, ShouldResemble, Stats{})
+		})
+	})
+}
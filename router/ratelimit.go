@@ -0,0 +1,135 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// bucket - token bucket of notifications, it is refilled by perMinute and holds up to burst
+type bucket struct {
+	perMinute int
+	burst     int
+	tokens    float64
+	last      time.Time
+}
+
+func newBucket(perMinute, burst int) *bucket {
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &bucket{perMinute: perMinute, burst: burst, tokens: float64(burst)}
+}
+
+// refill - add tokens for time since last refill, true if there is a token
+func (b *bucket) refill(now time.Time) bool {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens += now.Sub(b.last).Minutes() * float64(b.perMinute)
+		if b.tokens > float64(b.burst) {
+			b.tokens = float64(b.burst)
+		}
+	}
+	b.last = now
+	return b.tokens >= 1
+}
+
+// overflow - leaks of sender which were not sent because of rate limit since the last summary
+type overflow struct {
+	count    int
+	severity string
+	repos    map[string]int
+	patterns map[string]int
+}
+
+func (o *overflow) add(leak hungryfox.Leak) {
+	o.count++
+	if o.severity == "" || hungryfox.SeverityLevel(leak.Severity) > hungryfox.SeverityLevel(o.severity) {
+		o.severity = leak.Severity
+	}
+	o.repos[leak.RepoURL]++
+	o.patterns[leak.PatternName]++
+}
+
+// rateLimiter - global and per sender limits of notifications, senders without limit are limited only globally
+type rateLimiter struct {
+	global    *bucket // nil if unlimited
+	senders   map[string]*bucket
+	overflows map[string]*overflow
+}
+
+// allow - take token of sender and global one, leak is kept for summary if any of them is exhausted
+func (l *rateLimiter) allow(sender string, leak hungryfox.Leak, now time.Time) bool {
+	allowed := true
+	if l.global != nil && !l.global.refill(now) {
+		allowed = false
+	}
+	if b := l.senders[sender]; b != nil && !b.refill(now) {
+		allowed = false
+	}
+	if allowed {
+		if l.global != nil {
+			l.global.tokens--
+		}
+		if b := l.senders[sender]; b != nil {
+			b.tokens--
+		}
+		return true
+	}
+	o := l.overflows[sender]
+	if o == nil {
+		o = &overflow{repos: map[string]int{}, patterns: map[string]int{}}
+		l.overflows[sender] = o
+	}
+	o.add(leak)
+	return false
+}
+
+// summaries - one leak per sender which describes leaks over limit, overflows are reset
+func (l *rateLimiter) summaries(now time.Time) map[string]hungryfox.Leak {
+	result := map[string]hungryfox.Leak{}
+	for sender, o := range l.overflows {
+		summary := hungryfox.Leak{
+			PatternName: "rate-limit",
+			LeakString:  fmt.Sprintf("%d leaks were not sent because of rate limit", o.count),
+			Regexp:      fmt.Sprintf("repos: %s; patterns: %s", topCounts(o.repos, 10), topCounts(o.patterns, 10)),
+			Severity:    o.severity,
+			Kind:        hungryfox.LeakKindRateLimited,
+			TimeStamp:   now,
+			FoundAt:     now,
+		}
+		if len(o.repos) == 1 {
+			for repoURL := range o.repos {
+				summary.RepoURL = repoURL
+			}
+		}
+		result[sender] = summary
+	}
+	l.overflows = map[string]*overflow{}
+	return result
+}
+
+// topCounts - "name: count" of the most frequent names
+func topCounts(counts map[string]int, limit int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	result := []string{}
+	for i, name := range names {
+		if i == limit {
+			result = append(result, fmt.Sprintf("and %d more", len(names)-limit))
+			break
+		}
+		result = append(result, fmt.Sprintf("%s: %d", name, counts[name]))
+	}
+	return strings.Join(result, ", ")
+}
@@ -2,12 +2,31 @@ package router
 
 import (
 	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/anomaly"
+	"github.com/AlexAkulov/hungryfox/clock"
 	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/findings"
+	"github.com/AlexAkulov/hungryfox/hashonly"
 	"github.com/AlexAkulov/hungryfox/helpers"
+	"github.com/AlexAkulov/hungryfox/proxy"
+	"github.com/AlexAkulov/hungryfox/receipt"
+	"github.com/AlexAkulov/hungryfox/retention"
+	"github.com/AlexAkulov/hungryfox/senders/archive"
+	"github.com/AlexAkulov/hungryfox/senders/command"
 	"github.com/AlexAkulov/hungryfox/senders/email"
 	"github.com/AlexAkulov/hungryfox/senders/file"
+	"github.com/AlexAkulov/hungryfox/senders/spool"
+	"github.com/AlexAkulov/hungryfox/senders/webhook"
+	"github.com/AlexAkulov/hungryfox/summary"
+	"github.com/AlexAkulov/hungryfox/verify"
 
 	"github.com/rs/zerolog"
 	"gopkg.in/tomb.v2"
@@ -16,62 +35,766 @@ import (
 type LeaksRouter struct {
 	LeakChannel <-chan *hungryfox.Leak
 	Config      *config.Config
-	Log         zerolog.Logger
+	Clock       clock.Clock // system clock if nil, it is passed to senders
+	// Fingerprints - persistent fingerprints of sent leaks, only leaks_file is used for dedup if nil
+	Fingerprints hungryfox.IFingerprintStore
+	// Audit - deliveries of notifications, nothing is recorded if nil
+	Audit hungryfox.IAuditLog
+	Log   zerolog.Logger
 
-	senders map[string]hungryfox.IMessageSender
-	tomb    tomb.Tomb
+	senders     map[string]hungryfox.IMessageSender
+	minSeverity map[string]string // senders receive only leaks with severity not lower
+	external    map[string]bool   // senders outside the perimeter receive leaks without secrets
+	anomaly     *anomaly.Detector
+	verifier    *verify.Checker
+	janitor     *retention.Janitor
+	summary     *summary.Reporter // nil if summary is disabled
+	signer      *receipt.Signer
+	hasher      *hashonly.Hasher // leaks of edge instances without hash_only are hashed too
+	limiter     *rateLimiter     // nil if rate limit is disabled
+	seen        map[string]bool  // fingerprints of sent leaks
+	seenSecrets map[string]bool  // secret fingerprints of sent leaks
+	tomb        tomb.Tomb
+	// routeMutex - leaks are routed by routing and verifying goroutines
+	routeMutex  sync.Mutex
+	verifyQueue chan *hungryfox.Leak // leaks waiting for verification, requests to apis don't block routing
+	statuses    *findings.StatusLog  // leaks with status set by triage are not notified, nil without status_file
 }
 
-func (r *LeaksRouter) Start() error {
+// Destination - sender and its recipients of leak
+type Destination struct {
+	Sender     string
+	Recipients []string
+	// Stripped - sender is external and receives leak without secret
+	Stripped bool
+	// Skipped - why sender doesn't receive leak, it is set only by Explain
+	Skipped string
+}
+
+// Explanation - what router would do with leak, nothing is sent
+type Explanation struct {
+	// Duplicate - leak was sent before and is skipped by dedup, it has no destinations
+	Duplicate bool
+	// Verified - result of verification, empty if leak was not checked
+	Verified     string
+	Severity     string
+	Destinations []Destination
+}
+
+// Init - create senders without starting them
+func (r *LeaksRouter) Init() error {
 	delay, err := helpers.ParseDuration(r.Config.SMTP.Delay)
 	if err != nil {
 		return fmt.Errorf("can't parse delay with: %v", err)
 	}
+	uiURL := ""
+	if r.Config.API.UI {
+		uiURL = r.Config.API.PublicURL
+	}
 	r.senders = map[string]hungryfox.IMessageSender{}
+	r.minSeverity = map[string]string{}
+	r.external = map[string]bool{}
+	var emailConfig *email.Config
 	if r.Config.SMTP.Enable {
+		password, err := r.Config.SMTP.GetPassword()
+		if err != nil {
+			return fmt.Errorf("can't get smtp password: %v", err)
+		}
+		var oauth2Token func() (string, error)
+		if oauth2 := r.Config.SMTP.OAuth2; oauth2 != nil {
+			p, err := proxy.New(r.Config.Proxy.URL, r.Config.Proxy.Hosts, r.Config.Proxy.NoProxy)
+			if err != nil {
+				return err
+			}
+			client := p.Client()
+			client.Timeout = 30 * time.Second
+			token := &email.OAuth2Token{
+				TokenURL:     oauth2.TokenURL,
+				ClientID:     oauth2.ClientID,
+				ClientSecret: oauth2.ClientSecret,
+				RefreshToken: oauth2.RefreshToken,
+				Scopes:       oauth2.Scopes,
+				Client:       client,
+			}
+			oauth2Token = token.Token
+		}
+		routes := []email.Route{}
+		for _, route := range r.Config.SMTP.Routes {
+			routes = append(routes, email.Route{
+				Name:        route.Name,
+				MinSeverity: route.MinSeverity,
+				Repos:       route.Repos,
+				Patterns:    route.Patterns,
+				To:          route.To,
+				CC:          route.CC,
+				Digest:      route.DigestSchedule,
+			})
+		}
+		emailConfig = &email.Config{
+			From:         r.Config.SMTP.From,
+			SMTPHost:     r.Config.SMTP.Host,
+			SMTPPort:     r.Config.SMTP.Port,
+			InsecureTLS:  !r.Config.SMTP.TLS,
+			Username:     r.Config.SMTP.Username,
+			Password:     password,
+			Delay:        delay,
+			TemplateFile: r.Config.SMTP.TemplateFile,
+			UIURL:        uiURL,
+			Redact:       r.Config.SMTP.Redact,
+			TLSMode:      r.Config.SMTP.TLSMode,
+			AuthMethod:   r.Config.SMTP.Auth,
+			OAuth2Token:  oauth2Token,
+			MaxPerMinute: r.Config.SMTP.MaxPerMinute,
+		}
+		if r.Config.SMTP.PasswordVault != "" {
+			emailConfig.PasswordSource = r.Config.SMTP.GetPassword
+		}
 		r.senders["email"] = &email.Sender{
-			AuditorEmail: r.Config.SMTP.Recipient,
-			Config: &email.Config{
-				From:        r.Config.SMTP.From,
-				SMTPHost:    r.Config.SMTP.Host,
-				SMTPPort:    r.Config.SMTP.Port,
-				InsecureTLS: !r.Config.SMTP.TLS,
-				Username:    r.Config.SMTP.Username,
-				Password:    r.Config.SMTP.Password,
-				Delay:       delay,
-			},
-			Log: r.Log,
+			AuditorEmail:  r.Config.SMTP.Recipient,
+			CC:            r.Config.SMTP.CC,
+			Routes:        routes,
+			SendToAuthor:  r.Config.SMTP.SentToAuthor,
+			AuthorDomains: r.Config.SMTP.AuthorDomains,
+			Config:        emailConfig,
+			Audit:         r.Audit,
+			Log:           r.Log,
+		}
+		r.minSeverity["email"] = r.Config.SMTP.MinSeverity
+		r.external["email"] = r.Config.SMTP.External
+	}
+	if r.Config.Spool.Enable {
+		r.senders["spool"] = &spool.Spool{
+			Dir:    r.Config.Spool.Dir,
+			Source: r.Config.Spool.Source,
+			Clock:  r.Clock,
+		}
+		r.minSeverity["spool"] = r.Config.Spool.MinSeverity
+		r.external["spool"] = r.Config.Spool.External
+	}
+	if r.Config.JSONLines.Enable {
+		r.senders["json_lines"] = &file.JSONLines{
+			Path:     r.Config.JSONLines.Path,
+			MaxSize:  int64(r.Config.JSONLines.MaxSizeMB) << 20,
+			MaxAge:   r.Config.JSONLines.MaxAge,
+			MaxFiles: r.Config.JSONLines.MaxFiles,
+			Gzip:     r.Config.JSONLines.Gzip,
+			Clock:    r.Clock,
+		}
+		r.minSeverity["json_lines"] = r.Config.JSONLines.MinSeverity
+		r.external["json_lines"] = r.Config.JSONLines.External
+	}
+	if r.Config.Archive.Enable {
+		sender, err := r.newArchive()
+		if err != nil {
+			return err
+		}
+		r.senders["archive"] = sender
+		r.minSeverity["archive"] = r.Config.Archive.MinSeverity
+		r.external["archive"] = r.Config.Archive.External
+	}
+	for _, sender := range r.Config.ExecSenders {
+		name := "exec:" + sender.Name
+		r.senders[name] = &command.Sender{
+			Name:    sender.Name,
+			Command: sender.Command,
+			Timeout: sender.Timeout,
+			Log:     r.Log,
+		}
+		r.minSeverity[name] = sender.MinSeverity
+		r.external[name] = sender.External
+	}
+	for _, hook := range r.Config.FindingWebhooks {
+		sender, err := r.newWebhook(hook, uiURL)
+		if err != nil {
+			return fmt.Errorf("finding webhook '%s': %v", hook.Name, err)
 		}
+		name := "webhook:" + hook.Name
+		r.senders[name] = sender
+		r.minSeverity[name] = hook.MinSeverity
+		r.external[name] = hook.External
 	}
-	r.senders["file"] = &file.File{
+	leaksFile := &file.File{
 		LeaksFile: r.Config.Common.LeaksFile,
 	}
+	r.senders["file"] = leaksFile
+	if r.Config.Common.StatusFile != "" {
+		r.statuses = &findings.StatusLog{StatusFile: r.Config.Common.StatusFile}
+	}
+	if r.Config.Retention.Enable {
+		r.janitor = &retention.Janitor{
+			Policy: retention.Policy{
+				ContentAfter:  r.Config.Retention.ContentAfter,
+				ResolvedAfter: r.Config.Retention.ResolvedAfter,
+			},
+			Leaks:    leaksFile,
+			Interval: r.Config.Retention.Interval,
+			DryRun:   r.Config.Retention.DryRun,
+			Clock:    r.Clock,
+			Log:      r.Log,
+		}
+		for _, store := range r.Config.RetentionExcluded() {
+			r.Log.Warn().Str("service", "retention").Str("store", store).Msg("store is not purged by retention")
+		}
+		if r.statuses != nil {
+			r.janitor.Statuses = r.statuses
+		}
+	}
+	if r.Config.Summary.Enable {
+		if r.summary, err = r.newSummary(emailConfig); err != nil {
+			return err
+		}
+	}
+	if r.Config.Verify.Enable {
+		p, err := proxy.New(r.Config.Proxy.URL, r.Config.Proxy.Hosts, r.Config.Proxy.NoProxy)
+		if err != nil {
+			return err
+		}
+		client := p.Client()
+		client.Timeout = 10 * time.Second
+		if r.verifier, err = verify.New(r.Config.Verify.Verifiers, client, r.Config.Verify.Rate); err != nil {
+			return err
+		}
+		r.verifier.Clock = r.Clock
+	}
+	if r.Config.Common.ReceiptKeyFile != "" {
+		if r.signer, err = receipt.LoadSigner(r.Config.Common.ReceiptKeyFile); err != nil {
+			return err
+		}
+	}
+	if r.Config.Common.HashOnly {
+		if r.hasher, err = hashonly.Load(r.Config.Common.HashKeyFile); err != nil {
+			return err
+		}
+	}
+	if r.Config.RateLimit.Enable {
+		r.limiter = &rateLimiter{senders: map[string]*bucket{}, overflows: map[string]*overflow{}}
+		if r.Config.RateLimit.PerMinute > 0 {
+			r.limiter.global = newBucket(r.Config.RateLimit.PerMinute, r.Config.RateLimit.Burst)
+		}
+		for name, limit := range r.Config.RateLimit.Senders {
+			r.limiter.senders[name] = newBucket(limit.PerMinute, limit.Burst)
+		}
+	}
+	if r.Config.Anomaly.Enable {
+		r.anomaly = &anomaly.Detector{
+			MinLeaks:  r.Config.Anomaly.MinLeaks,
+			Sigma:     r.Config.Anomaly.Sigma,
+			Window:    r.Config.Anomaly.Window,
+			StateFile: r.Config.Anomaly.StateFile,
+		}
+		if err := r.anomaly.Load(); err != nil {
+			return fmt.Errorf("can't read %s: %v", r.Config.Anomaly.StateFile, err)
+		}
+	}
+	return nil
+}
+
+// newSummary - reporter of scheduled summary, it is sent through smtp of email sender and Slack webhook with proxy
+func (r *LeaksRouter) newSummary(emailConfig *email.Config) (*summary.Reporter, error) {
+	conf := r.Config.Summary
+	reporter := &summary.Reporter{
+		Schedule:  conf.CronSchedule,
+		Top:       conf.Top,
+		StateFile: conf.StateFile,
+		Leaks:     &findings.FileStore{LeaksFile: r.Config.Common.LeaksFile},
+		Clock:     r.Clock,
+		Log:       r.Log,
+	}
+	if r.statuses != nil {
+		reporter.Statuses = r.statuses
+	}
+	if len(conf.EmailTo) > 0 {
+		reporter.Notifiers = append(reporter.Notifiers, &summary.Email{
+			Mailer: &email.Report{Config: emailConfig, To: conf.EmailTo},
+		})
+	}
+	if conf.SlackWebhookURL != "" {
+		p, err := proxy.New(r.Config.Proxy.URL, r.Config.Proxy.Hosts, r.Config.Proxy.NoProxy)
+		if err != nil {
+			return nil, err
+		}
+		client := p.Client()
+		client.Timeout = 30 * time.Second
+		reporter.Notifiers = append(reporter.Notifiers, &summary.Slack{URL: conf.SlackWebhookURL, Client: client})
+	}
+	return reporter, nil
+}
+
+// ScanFinished - count finished scan in activity of summary
+func (r *LeaksRouter) ScanFinished(repo hungryfox.Repo, commits int) {
+	if r.summary != nil {
+		r.summary.ScanFinished(repo, commits)
+	}
+	r.saveAnomaly()
+}
+
+// saveAnomaly - write counts of anomaly detection, so they survive restart
+func (r *LeaksRouter) saveAnomaly() {
+	if r.anomaly == nil {
+		return
+	}
+	if err := r.anomaly.Save(); err != nil {
+		r.Log.Error().Str("service", "router").Str("error", err.Error()).Msg("can't save anomaly state")
+	}
+}
+
+// newArchive - sender to bucket with proxy, keys are taken from environment like aws cli does if they are not set
+func (r *LeaksRouter) newArchive() (*archive.Archive, error) {
+	p, err := proxy.New(r.Config.Proxy.URL, r.Config.Proxy.Hosts, r.Config.Proxy.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+	client := p.Client()
+	client.Timeout = 5 * time.Minute
+	conf := r.Config.Archive
+	bucket := &archive.Bucket{
+		Endpoint:  conf.Endpoint,
+		Region:    conf.Region,
+		Name:      conf.Bucket,
+		AccessKey: conf.AccessKey,
+		SecretKey: conf.SecretKey,
+		Client:    client,
+	}
+	if bucket.AccessKey == "" {
+		bucket.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if bucket.SecretKey == "" {
+		bucket.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	return &archive.Archive{
+		Bucket:     bucket,
+		Prefix:     conf.Prefix,
+		Instance:   r.Config.Identity.Instance,
+		Interval:   conf.Interval,
+		Retention:  conf.Retention,
+		PendingDir: conf.PendingDir,
+		MaxPending: int64(conf.MaxPendingMB) * 1024 * 1024,
+		Clock:      r.Clock,
+		Log:        r.Log,
+	}, nil
+}
 
+// newWebhook - sender with proxy and client certificate of hook
+func (r *LeaksRouter) newWebhook(hook config.FindingWebhook, uiURL string) (*webhook.Sender, error) {
+	tlsConfig, err := webhook.TLSConfig(hook.CertFile, hook.KeyFile, hook.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	p, err := proxy.New(r.Config.Proxy.URL, r.Config.Proxy.Hosts, r.Config.Proxy.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+	client := p.Client()
+	client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+	client.Timeout = 30 * time.Second
+	return &webhook.Sender{
+		URL:     hook.URL,
+		Headers: hook.Headers,
+		Identity: webhook.Identity{
+			Instance:  r.Config.Identity.Instance,
+			EgressIPs: r.Config.Identity.EgressIPs,
+		},
+		Client:  client,
+		UIURL:   uiURL,
+		Retries: hook.Retries,
+		Log:     r.Log,
+	}, nil
+}
+
+func (r *LeaksRouter) Start() error {
+	if err := r.Init(); err != nil {
+		return err
+	}
+	if err := r.loadSeen(); err != nil {
+		return fmt.Errorf("can't read leaks file for dedup: %v", err)
+	}
 	for senderName, sender := range r.senders {
 		if err := sender.Start(); err != nil {
 			return err
 		}
 		r.Log.Debug().Str("service", senderName).Msg("strated")
 	}
+	if r.janitor != nil {
+		r.janitor.Start()
+	}
+	if r.summary != nil {
+		if err := r.summary.Start(); err != nil {
+			return err
+		}
+	}
 
 	r.tomb.Go(func() error {
+		var summaryTicker <-chan time.Time
+		if r.limiter != nil {
+			ticker := time.NewTicker(r.Config.RateLimit.SummaryInterval)
+			defer ticker.Stop()
+			summaryTicker = ticker.C
+		}
 		for {
 			select {
 			case <-r.tomb.Dying(): // Stop
+				r.drain()
 				return nil
+			case <-summaryTicker:
+				r.routeMutex.Lock()
+				r.sendSummaries()
+				r.routeMutex.Unlock()
 			case leak := <-r.LeakChannel:
-				for _, sender := range r.senders {
-					sender.Send(*leak)
-				}
+				r.handle(leak, false)
 			}
 		}
 	})
+	if r.verifier != nil {
+		r.verifyQueue = make(chan *hungryfox.Leak, verifyQueueSize)
+		r.tomb.Go(r.verifyLeaks)
+	}
 	return nil
 }
 
+// verifyQueueSize - leaks over it are routed unverified
+const verifyQueueSize = 100
+
+// drain - route leaks left in LeakChannel on stop, senders are stopped after it
+func (r *LeaksRouter) drain() {
+	for {
+		select {
+		case leak := <-r.LeakChannel:
+			r.handle(leak, true)
+		default:
+			return
+		}
+	}
+}
+
+// verifyLeaks - verify queued leaks and route them
+func (r *LeaksRouter) verifyLeaks() error {
+	for {
+		select {
+		case <-r.tomb.Dying():
+			return nil
+		case leak := <-r.verifyQueue:
+			r.verify(leak)
+			r.route(leak)
+		}
+	}
+}
+
+// drainVerifyQueue - route leaks left in verify queue unverified on stop, requests to apis would delay it
+func (r *LeaksRouter) drainVerifyQueue() {
+	for {
+		select {
+		case leak := <-r.verifyQueue:
+			r.route(leak)
+		default:
+			return
+		}
+	}
+}
+
+// handle - queue leak for verification or route it, wait verifies leak in place
+func (r *LeaksRouter) handle(leak *hungryfox.Leak, wait bool) {
+	if r.hasher != nil {
+		*leak = r.hasher.Hash(*leak)
+	}
+	if r.needsVerify(leak) {
+		if wait {
+			r.verify(leak)
+		} else {
+			select {
+			case r.verifyQueue <- leak:
+				return
+			default:
+				r.Log.Warn().Str("repo", leak.RepoURL).Str("pattern", leak.PatternName).Msg("verify queue is full, leak is routed unverified")
+			}
+		}
+	}
+	r.route(leak)
+}
+
+// route - send leak and its anomaly event
+func (r *LeaksRouter) route(leak *hungryfox.Leak) {
+	r.routeMutex.Lock()
+	defer r.routeMutex.Unlock()
+	if !r.send(*leak) || r.anomaly == nil {
+		return
+	}
+	if event, ok := r.anomaly.Observe(*leak); ok {
+		r.Log.Warn().Str("repo", event.RepoURL).Str("commit", event.CommitHash).Msg(event.PatternName)
+		r.send(*event)
+	}
+}
+
+// needsVerify - leak was not verified by edge instance and was not sent before
+func (r *LeaksRouter) needsVerify(leak *hungryfox.Leak) bool {
+	if r.verifier == nil || leak.Verified != "" {
+		return false
+	}
+	r.routeMutex.Lock()
+	defer r.routeMutex.Unlock()
+	return !r.seen[leak.Fingerprint()]
+}
+
+// verify - mark leak as verified or unverified, verified leaks get verified_severity
+func (r *LeaksRouter) verify(leak *hungryfox.Leak) {
+	result, err := r.verifier.Check(*leak)
+	if err != nil {
+		r.Log.Warn().Str("error", err.Error()).Str("repo", leak.RepoURL).Str("pattern", leak.PatternName).Msg("can't verify leak")
+		return
+	}
+	leak.Verified = result
+	if result == verify.Verified && r.Config.Verify.VerifiedSeverity != "" {
+		leak.Severity = r.Config.Verify.VerifiedSeverity
+	}
+}
+
+// loadSeen - leaks written to leaks file or fingerprints store before restart are not sent again
+func (r *LeaksRouter) loadSeen() error {
+	r.seen = map[string]bool{}
+	r.seenSecrets = map[string]bool{}
+	if !r.Config.Common.Dedup {
+		return nil
+	}
+	if r.Config.Common.LeaksFile != "" {
+		leaks, err := (&findings.FileStore{LeaksFile: r.Config.Common.LeaksFile}).GetLeaks()
+		if err != nil {
+			return err
+		}
+		for _, leak := range leaks {
+			r.seen[leak.Fingerprint()] = true
+			r.seenSecrets[leak.SecretFingerprint()] = true
+		}
+	}
+	if r.Fingerprints == nil {
+		return nil
+	}
+	fingerprints, secretFingerprints, err := r.Fingerprints.GetFingerprints()
+	if err != nil {
+		return err
+	}
+	for fingerprint := range fingerprints {
+		r.seen[fingerprint] = true
+	}
+	for secretFingerprint := range secretFingerprints {
+		r.seenSecrets[secretFingerprint] = true
+	}
+	return nil
+}
+
+// send - route leak to senders, false if the same leak was sent before
+func (r *LeaksRouter) send(leak hungryfox.Leak) bool {
+	if leak.FoundAt.IsZero() {
+		leak.FoundAt = clock.Or(r.Clock).Now().UTC()
+	}
+	fingerprint, secretFingerprint := leak.Fingerprint(), leak.SecretFingerprint()
+	if r.Config.Common.Dedup && r.seen[fingerprint] {
+		return false
+	}
+	secretSeen := r.Config.Common.Dedup && r.seenSecrets[secretFingerprint]
+	if r.signer != nil && leak.Receipt == nil {
+		// leaks forwarded by edge instances keep their receipts
+		if err := r.signer.SignLeak(&leak); err != nil {
+			r.Log.Error().Str("error", err.Error()).Str("repo", leak.RepoURL).Msg("can't sign leak")
+		}
+	}
+	triaged := r.triaged(leak)
+	delivered := true
+	for _, destination := range r.Route(leak) {
+		if reason := r.skipReason(leak, destination, secretSeen, triaged); reason != "" {
+			r.audit(leak, destination, reason, nil)
+			continue
+		}
+		var err error
+		if destination.Stripped {
+			err = r.senders[destination.Sender].Send(StripSecret(leak))
+		} else {
+			err = r.senders[destination.Sender].Send(leak)
+		}
+		if err != nil {
+			delivered = false
+		}
+		r.audit(leak, destination, "", err)
+	}
+	if r.Config.Common.Dedup && delivered {
+		r.markSeen(fingerprint, secretFingerprint, leak.FoundAt)
+	} else if r.Config.Common.Dedup {
+		r.Log.Warn().Str("repo", leak.RepoURL).Str("fingerprint", fingerprint).Msg("leak is not delivered to every sender, it will be sent again when found")
+	}
+	return true
+}
+
+// skipReason - why destination doesn't receive leak, empty if it does, rate limit of sender is taken
+func (r *LeaksRouter) skipReason(leak hungryfox.Leak, destination Destination, secretSeen, triaged bool) string {
+	switch {
+	case secretSeen && destination.Sender != "file":
+		// secret was reported before, new place is only recorded
+		return "secret was reported before"
+	case triaged && notification(destination.Sender):
+		// status of leak was set by triage, it is recorded without notifying people again
+		return "triaged"
+	case r.limiter != nil && r.rateLimited(destination.Sender) && !r.limiter.allow(destination.Sender, leak, clock.Or(r.Clock).Now()):
+		// leak is recorded by leaks file and summarized later
+		return "rate limit"
+	}
+	return ""
+}
+
+// Explain - decisions of router for leak as if it was found now: hash only, verification if verify is set, dedup
+// against leaks_file and previous explained leaks, triage statuses and rate limits, nothing is sent or saved
+func (r *LeaksRouter) Explain(leak hungryfox.Leak, verify bool) (Explanation, error) {
+	if r.seen == nil {
+		if err := r.loadSeen(); err != nil {
+			return Explanation{}, fmt.Errorf("can't read leaks file for dedup: %v", err)
+		}
+	}
+	if r.hasher != nil {
+		leak = r.hasher.Hash(leak)
+	}
+	if verify && r.needsVerify(&leak) {
+		r.verify(&leak)
+	}
+	result := Explanation{Verified: leak.Verified, Severity: leak.Severity}
+	fingerprint, secretFingerprint := leak.Fingerprint(), leak.SecretFingerprint()
+	if r.Config.Common.Dedup && r.seen[fingerprint] {
+		result.Duplicate = true
+		return result, nil
+	}
+	secretSeen := r.Config.Common.Dedup && r.seenSecrets[secretFingerprint]
+	triaged := r.triaged(leak)
+	for _, destination := range r.Route(leak) {
+		destination.Skipped = r.skipReason(leak, destination, secretSeen, triaged)
+		result.Destinations = append(result.Destinations, destination)
+	}
+	if r.Config.Common.Dedup {
+		r.seen[fingerprint] = true
+		r.seenSecrets[secretFingerprint] = true
+	}
+	return result, nil
+}
+
+// markSeen - leak is not sent again, it is recorded only after senders accept it
+func (r *LeaksRouter) markSeen(fingerprint, secretFingerprint string, foundAt time.Time) {
+	r.seen[fingerprint] = true
+	r.seenSecrets[secretFingerprint] = true
+	if r.Fingerprints == nil {
+		return
+	}
+	if err := r.Fingerprints.AddFingerprint(fingerprint, secretFingerprint, foundAt); err != nil {
+		r.Log.Error().Str("error", err.Error()).Str("fingerprint", fingerprint).Msg("can't save fingerprint")
+	}
+}
+
+// audit - record delivery of notification, skipped if reason is set, storages are not recorded
+func (r *LeaksRouter) audit(leak hungryfox.Leak, destination Destination, reason string, err error) {
+	if r.Audit == nil || !notification(destination.Sender) {
+		return
+	}
+	event := hungryfox.AuditEvent{
+		Time:        clock.Or(r.Clock).Now().UTC(),
+		Action:      hungryfox.AuditNotification,
+		Result:      hungryfox.AuditOK,
+		Repo:        leak.RepoURL,
+		Sender:      destination.Sender,
+		Recipients:  destination.Recipients,
+		Fingerprint: leak.Fingerprint(),
+	}
+	switch {
+	case reason != "":
+		event.Result, event.Reason = hungryfox.AuditSkipped, reason
+	case err != nil:
+		event.Result, event.Error = hungryfox.AuditFailed, err.Error()
+	case queued(destination.Sender):
+		event.Result = hungryfox.AuditQueued
+	}
+	r.Audit.Record(event)
+}
+
+// triaged - status of leak was changed from open by triage, e.g. it was acknowledged or marked as false positive
+func (r *LeaksRouter) triaged(leak hungryfox.Leak) bool {
+	if r.statuses == nil {
+		return false
+	}
+	history, err := r.statuses.History(leak.Fingerprint())
+	if err != nil {
+		r.Log.Error().Str("error", err.Error()).Str("service", "router").Msg("can't read statuses of leaks")
+		return false
+	}
+	return hungryfox.StatusAsOf(history, clock.Or(r.Clock).Now()) != hungryfox.StatusOpen
+}
+
+// queued - sender delivers leaks later in batches and records their delivery to audit log itself
+func queued(sender string) bool {
+	return sender == "email"
+}
+
+// notification - sender notifies people, storages receive every leak
+func notification(sender string) bool {
+	return sender == "email" || strings.HasPrefix(sender, "webhook:") || strings.HasPrefix(sender, "exec:")
+}
+
+// rateLimited - email and finding webhooks are rate limited, exec senders only with their own limit in rate_limit.senders,
+// so commands like key rotation get every leak by default
+func (r *LeaksRouter) rateLimited(sender string) bool {
+	if strings.HasPrefix(sender, "exec:") {
+		_, ok := r.Config.RateLimit.Senders[sender]
+		return ok
+	}
+	return notification(sender)
+}
+
+// sendSummaries - send leaks which were over rate limit as one summary per sender
+func (r *LeaksRouter) sendSummaries() {
+	for senderName, summary := range r.limiter.summaries(clock.Or(r.Clock).Now().UTC()) {
+		r.Log.Warn().Str("service", senderName).Str("summary", summary.LeakString).Msg("rate limit is exceeded")
+		err := r.senders[senderName].Send(summary)
+		r.audit(summary, Destination{Sender: senderName}, "", err)
+	}
+}
+
+// Route - get senders which must receive leak
+func (r *LeaksRouter) Route(leak hungryfox.Leak) []Destination {
+	result := []Destination{}
+	for senderName, sender := range r.senders {
+		if !severityAllowed(leak.Severity, r.minSeverity[senderName]) {
+			continue
+		}
+		destination := Destination{Sender: senderName, Stripped: r.external[senderName]}
+		if s, ok := sender.(hungryfox.IRecipients); ok {
+			destination.Recipients = s.Recipients(leak)
+		}
+		result = append(result, destination)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.Compare(result[i].Sender, result[j].Sender) < 0
+	})
+	return result
+}
+
+// severityAllowed - leak severity is not lower than minimal, leaks without severity are medium
+func severityAllowed(severity, minSeverity string) bool {
+	if minSeverity == "" {
+		return true
+	}
+	if severity == "" {
+		severity = hungryfox.SeverityMedium
+	}
+	return hungryfox.SeverityLevel(severity) >= hungryfox.SeverityLevel(minSeverity)
+}
+
+// Stop - route queued leaks, send summaries of rate limits and flush senders
 func (r *LeaksRouter) Stop() error {
 	r.tomb.Kill(nil)
 	r.tomb.Wait()
+	r.drainVerifyQueue()
+	if r.limiter != nil {
+		r.sendSummaries()
+	}
+	if r.janitor != nil {
+		r.janitor.Stop()
+	}
+	if r.summary != nil {
+		r.summary.Stop()
+	}
+	r.saveAnomaly()
 	for _, sender := range r.senders {
 		sender.Stop()
 	}
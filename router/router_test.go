@@ -0,0 +1,342 @@
+package router
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/findings"
+	"github.com/AlexAkulov/hungryfox/verify"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeSender struct {
+	sent []hungryfox.Leak
+	err  error
+}
+
+func (f *fakeSender) Start() error { return nil }
+func (f *fakeSender) Stop() error  { return nil }
+func (f *fakeSender) Send(leak hungryfox.Leak) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, leak)
+	return nil
+}
+
+func TestDedup(t *testing.T) {
+	Convey("duplicates are not sent", t, func() {
+		conf, _ := config.ParseConfig(nil)
+		file, email := &fakeSender{}, &fakeSender{}
+		r := &LeaksRouter{
+			Config:  conf,
+			senders: map[string]hungryfox.IMessageSender{"file": file, "email": email},
+		}
+		So(r.loadSeen(), ShouldBeNil)
+		leak := hungryfox.Leak{RepoURL: "https://github.com/a/b", FilePath: "config.yml", CommitHash: "c1", LeakString: "password: qwerty"}
+		So(r.send(leak), ShouldBeTrue)
+		So(r.send(leak), ShouldBeFalse)
+		So(file.sent, ShouldHaveLength, 1)
+		So(email.sent, ShouldHaveLength, 1)
+
+		Convey("reappeared secret is only written to file", func() {
+			leak.CommitHash, leak.Line = "c2", 10
+			So(leak.SecretFingerprint(), ShouldEqual, hungryfox.Leak{RepoURL: "https://github.com/a/b", FilePath: "config.yml", LeakString: " password: qwerty"}.SecretFingerprint())
+			So(r.send(leak), ShouldBeTrue)
+			So(file.sent, ShouldHaveLength, 2)
+			So(email.sent, ShouldHaveLength, 1)
+		})
+		Convey("leak is sent again if sender fails", func() {
+			leak.LeakString = "password: secret"
+			email.err = fmt.Errorf("smtp is down")
+			So(r.send(leak), ShouldBeTrue)
+			email.err = nil
+			So(r.send(leak), ShouldBeTrue)
+			So(r.send(leak), ShouldBeFalse)
+		})
+	})
+}
+
+func TestTriaged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-triaged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	Convey("triaged leaks are recorded without notification", t, func() {
+		conf, _ := config.ParseConfig(nil)
+		statuses := &findings.StatusLog{StatusFile: filepath.Join(dir, "statuses.json")}
+		file, email := &fakeSender{}, &fakeSender{}
+		r := &LeaksRouter{
+			Config:   conf,
+			senders:  map[string]hungryfox.IMessageSender{"file": file, "email": email},
+			statuses: statuses,
+		}
+		So(r.loadSeen(), ShouldBeNil)
+		acknowledged := hungryfox.Leak{RepoURL: "https://github.com/a/b", FilePath: "config.yml", LeakString: "password: qwerty"}
+		rotated := hungryfox.Leak{RepoURL: "https://github.com/a/b", FilePath: "config.yml", LeakString: "password: asdfgh"}
+		open := hungryfox.Leak{RepoURL: "https://github.com/a/b", FilePath: "config.yml", LeakString: "password: zxcvbn"}
+		So(statuses.AddEvent(hungryfox.StatusEvent{Fingerprint: acknowledged.Fingerprint(), Status: hungryfox.StatusAcknowledged, Time: time.Now().Add(-time.Hour)}), ShouldBeNil)
+		So(statuses.AddEvent(hungryfox.StatusEvent{Fingerprint: rotated.Fingerprint(), Status: hungryfox.StatusRotated, Time: time.Now().Add(-time.Hour)}), ShouldBeNil)
+		for _, leak := range []hungryfox.Leak{acknowledged, rotated, open} {
+			So(r.send(leak), ShouldBeTrue)
+		}
+		So(file.sent, ShouldHaveLength, 3)
+		So(email.sent, ShouldHaveLength, 1)
+		So(email.sent[0].LeakString, ShouldEqual, open.LeakString)
+	})
+}
+
+func TestDrain(t *testing.T) {
+	Convey("queued leaks are sent on stop", t, func() {
+		conf, _ := config.ParseConfig(nil)
+		file := &fakeSender{}
+		leaks := make(chan *hungryfox.Leak, 10)
+		r := &LeaksRouter{
+			LeakChannel: leaks,
+			Config:      conf,
+			senders:     map[string]hungryfox.IMessageSender{"file": file},
+		}
+		So(r.loadSeen(), ShouldBeNil)
+		for i := 0; i < 3; i++ {
+			leaks <- &hungryfox.Leak{RepoURL: "https://github.com/a/b", FilePath: fmt.Sprintf("config%d.yml", i), LeakString: "password: qwerty"}
+		}
+		r.drain()
+		So(len(leaks), ShouldEqual, 0)
+		So(file.sent, ShouldHaveLength, 3)
+	})
+}
+
+func TestVerifyQueue(t *testing.T) {
+	Convey("leaks with credentials are verified in background", t, func() {
+		conf, _ := config.ParseConfig(nil)
+		file := &fakeSender{}
+		r := &LeaksRouter{
+			Config:      conf,
+			senders:     map[string]hungryfox.IMessageSender{"file": file},
+			verifier:    &verify.Checker{Interval: time.Hour},
+			verifyQueue: make(chan *hungryfox.Leak, 1),
+		}
+		So(r.loadSeen(), ShouldBeNil)
+		r.handle(&hungryfox.Leak{FilePath: "a.yml", LeakString: "password: qwerty"}, false)
+		So(file.sent, ShouldHaveLength, 0)
+		So(r.verifyQueue, ShouldHaveLength, 1)
+
+		Convey("leak is routed if queue is full", func() {
+			r.handle(&hungryfox.Leak{FilePath: "b.yml", LeakString: "password: qwerty"}, false)
+			So(file.sent, ShouldHaveLength, 1)
+		})
+		Convey("leaks verified by edge instances are routed", func() {
+			r.handle(&hungryfox.Leak{FilePath: "b.yml", LeakString: "password: qwerty", Verified: verify.Verified}, false)
+			So(file.sent, ShouldHaveLength, 1)
+		})
+		Convey("queued leaks are routed on stop", func() {
+			r.drainVerifyQueue()
+			So(file.sent, ShouldHaveLength, 1)
+			So(file.sent[0].Verified, ShouldEqual, "")
+		})
+	})
+}
+
+func TestSeverityAllowed(t *testing.T) {
+	Convey("leaks without severity are medium", t, func() {
+		So(severityAllowed("", ""), ShouldBeTrue)
+		So(severityAllowed("", hungryfox.SeverityHigh), ShouldBeFalse)
+		So(severityAllowed(hungryfox.SeverityCritical, hungryfox.SeverityHigh), ShouldBeTrue)
+	})
+}
+
+func TestFakeClock(t *testing.T) {
+	Convey("found_at is taken from clock", t, func() {
+		conf, _ := config.ParseConfig(nil)
+		file := &fakeSender{}
+		start := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+		r := &LeaksRouter{
+			Config:  conf,
+			Clock:   clock.NewFake(start, time.Second),
+			senders: map[string]hungryfox.IMessageSender{"file": file},
+		}
+		So(r.loadSeen(), ShouldBeNil)
+		r.send(hungryfox.Leak{CommitHash: "c1"})
+		r.send(hungryfox.Leak{CommitHash: "c2"})
+		So(file.sent[0].FoundAt, ShouldResemble, start)
+		So(file.sent[1].FoundAt, ShouldResemble, start.Add(time.Second))
+	})
+}
+
+func TestStripSecret(t *testing.T) {
+	Convey("external senders receive leaks without secrets", t, func() {
+		conf, _ := config.ParseConfig(nil)
+		file, email := &fakeSender{}, &fakeSender{}
+		r := &LeaksRouter{
+			Config:   conf,
+			senders:  map[string]hungryfox.IMessageSender{"file": file, "email": email},
+			external: map[string]bool{"email": true},
+		}
+		So(r.loadSeen(), ShouldBeNil)
+		leak := hungryfox.Leak{RepoURL: "https://github.com/a/b", LeakString: "token = xoxb-123-456", Secret: "xoxb-123-456", Receipt: &hungryfox.Receipt{KeyID: "k"}}
+		So(r.send(leak), ShouldBeTrue)
+		So(file.sent[0].LeakString, ShouldEqual, "token = xoxb-123-456")
+		So(email.sent[0].LeakString, ShouldEqual, "token = [REDACTED]")
+		So(email.sent[0].Secret, ShouldBeEmpty)
+		So(email.sent[0].Receipt, ShouldBeNil)
+		So(email.sent[0].Fingerprint(), ShouldEqual, leak.Fingerprint())
+		So(email.sent[0].SecretFingerprint(), ShouldEqual, leak.SecretFingerprint())
+	})
+	Convey("secret is stripped in context", t, func() {
+		leak := hungryfox.Leak{LeakString: "token = xoxb-123", Secret: "xoxb-123", Context: "# slack\ntoken = xoxb-123\nold = xoxb-123"}
+		So(StripSecret(leak).Context, ShouldEqual, "# slack\ntoken = [REDACTED]\nold = [REDACTED]")
+	})
+	Convey("match of pattern is stripped if secret is unknown", t, func() {
+		leak := hungryfox.Leak{LeakString: "password: qwerty # prod", Regexp: `password:\s*\S+`, Context: "db:\npassword: qwerty # prod"}
+		So(StripSecret(leak).LeakString, ShouldEqual, "[REDACTED] # prod")
+		So(StripSecret(leak).Context, ShouldEqual, "db:\n[REDACTED] # prod")
+		leak.Context = "db:\npassword: qwerty # ***** of other leak"
+		So(StripSecret(leak).Context, ShouldEqual, "db:\n[REDACTED] # ***** of other leak")
+		leak.Regexp = "nomatch"
+		So(StripSecret(leak).LeakString, ShouldEqual, "[REDACTED]")
+	})
+}
+
+func TestExplain(t *testing.T) {
+	Convey("explanation follows dedup and rate limit without sending", t, func() {
+		conf, _ := config.ParseConfig([]byte("rate_limit:\n  enable: true\n  per_minute: 60\n  burst: 1\n"))
+		file, email := &fakeSender{}, &fakeSender{}
+		r := &LeaksRouter{Config: conf, Clock: clock.NewFake(time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC), 0)}
+		So(r.Init(), ShouldBeNil)
+		r.senders = map[string]hungryfox.IMessageSender{"file": file, "email": email}
+		leak := hungryfox.Leak{RepoURL: "a/b", FilePath: "1", LeakString: "password: qwerty"}
+		explanation, err := r.Explain(leak, false)
+		So(err, ShouldBeNil)
+		So(explanation.Duplicate, ShouldBeFalse)
+		So(explanation.Destinations, ShouldHaveLength, 2)
+		So(explanation.Destinations[0].Skipped, ShouldEqual, "")
+
+		explanation, _ = r.Explain(leak, false)
+		So(explanation.Duplicate, ShouldBeTrue)
+
+		explanation, _ = r.Explain(hungryfox.Leak{RepoURL: "a/b", FilePath: "2", LeakString: "password: secret"}, false)
+		So(explanation.Destinations[0].Sender, ShouldEqual, "email")
+		So(explanation.Destinations[0].Skipped, ShouldEqual, "rate limit")
+		So(explanation.Destinations[1].Skipped, ShouldEqual, "")
+		So(file.sent, ShouldBeEmpty)
+		So(email.sent, ShouldBeEmpty)
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	Convey("notifications over limit are summarized", t, func() {
+		conf, _ := config.ParseConfig([]byte("rate_limit:\n  enable: true\n  per_minute: 60\n  burst: 2\n"))
+		start := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+		fakeClock := clock.NewFake(start, 0)
+		file, email := &fakeSender{}, &fakeSender{}
+		r := &LeaksRouter{
+			Config:  conf,
+			Clock:   fakeClock,
+			senders: map[string]hungryfox.IMessageSender{"file": file, "email": email},
+		}
+		So(r.Init(), ShouldBeNil)
+		r.senders = map[string]hungryfox.IMessageSender{"file": file, "email": email}
+		So(r.loadSeen(), ShouldBeNil)
+		for i, repo := range []string{"a/b", "a/b", "a/b", "a/b", "c/d"} {
+			r.send(hungryfox.Leak{RepoURL: repo, PatternName: "aws", FilePath: fmt.Sprint(i), Severity: hungryfox.SeverityHigh})
+		}
+		So(file.sent, ShouldHaveLength, 5)
+		So(email.sent, ShouldHaveLength, 2)
+
+		Convey("tokens are refilled over time", func() {
+			fakeClock.Advance(time.Second)
+			r.send(hungryfox.Leak{RepoURL: "a/b", FilePath: "6"})
+			So(email.sent, ShouldHaveLength, 3)
+		})
+
+		Convey("summary is sent instead of leaks over limit", func() {
+			r.sendSummaries()
+			So(email.sent, ShouldHaveLength, 3)
+			summary := email.sent[2]
+			So(summary.Kind, ShouldEqual, hungryfox.LeakKindRateLimited)
+			So(summary.LeakString, ShouldEqual, "3 leaks were not sent because of rate limit")
+			So(summary.Regexp, ShouldEqual, "repos: a/b: 2, c/d: 1; patterns: aws: 3")
+			So(summary.Severity, ShouldEqual, hungryfox.SeverityHigh)
+			r.sendSummaries()
+			So(email.sent, ShouldHaveLength, 3)
+		})
+	})
+
+	Convey("exec senders are rate limited only with their own limit", t, func() {
+		conf, _ := config.ParseConfig([]byte("rate_limit:\n  enable: true\n  per_minute: 60\n  burst: 1\n  senders:\n    exec:limited:\n      per_minute: 60\n      burst: 1\n"))
+		rotate, limited := &fakeSender{}, &fakeSender{}
+		r := &LeaksRouter{
+			Config: conf,
+			Clock:  clock.NewFake(time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC), 0),
+		}
+		So(r.Init(), ShouldBeNil)
+		r.senders = map[string]hungryfox.IMessageSender{"file": &fakeSender{}, "exec:rotate": rotate, "exec:limited": limited}
+		So(r.loadSeen(), ShouldBeNil)
+		for i := 0; i < 3; i++ {
+			r.send(hungryfox.Leak{RepoURL: "a/b", PatternName: "aws", FilePath: fmt.Sprint(i)})
+		}
+		So(rotate.sent, ShouldHaveLength, 3)
+		So(limited.sent, ShouldHaveLength, 1)
+	})
+}
+
+type fakeAudit struct {
+	events []hungryfox.AuditEvent
+}
+
+func (f *fakeAudit) Record(event hungryfox.AuditEvent) {
+	f.events = append(f.events, event)
+}
+
+type failingSender struct {
+	fakeSender
+}
+
+func (f *failingSender) Send(leak hungryfox.Leak) error {
+	return fmt.Errorf("relay is down")
+}
+
+func TestAudit(t *testing.T) {
+	Convey("deliveries of notifications are recorded", t, func() {
+		conf, _ := config.ParseConfig(nil)
+		audit := &fakeAudit{}
+		r := &LeaksRouter{
+			Config:  conf,
+			Clock:   clock.NewFake(time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC), time.Second),
+			Audit:   audit,
+			senders: map[string]hungryfox.IMessageSender{"file": &fakeSender{}, "email": &fakeSender{}, "webhook:siem": &failingSender{}},
+		}
+		So(r.loadSeen(), ShouldBeNil)
+		leak := hungryfox.Leak{RepoURL: "https://github.com/a/b", FilePath: "config.yml", CommitHash: "c1", LeakString: "password: qwerty"}
+		So(r.send(leak), ShouldBeTrue)
+		So(audit.events, ShouldHaveLength, 2)
+		So(audit.events[0].Action, ShouldEqual, hungryfox.AuditNotification)
+		So(audit.events[0].Sender, ShouldEqual, "email")
+		So(audit.events[0].Result, ShouldEqual, hungryfox.AuditQueued)
+		So(audit.events[0].Fingerprint, ShouldEqual, leak.Fingerprint())
+		So(audit.events[0].Time.IsZero(), ShouldBeFalse)
+		So(audit.events[1].Sender, ShouldEqual, "webhook:siem")
+		So(audit.events[1].Result, ShouldEqual, hungryfox.AuditFailed)
+		So(audit.events[1].Error, ShouldEqual, "relay is down")
+
+		Convey("notification of secret reported before is skipped", func() {
+			// failed leak is not recorded as seen, it is delivered again
+			r.senders["webhook:siem"] = &fakeSender{}
+			So(r.send(leak), ShouldBeTrue)
+			leak.CommitHash = "c2"
+			So(r.send(leak), ShouldBeTrue)
+			So(audit.events, ShouldHaveLength, 6)
+			So(audit.events[4].Result, ShouldEqual, hungryfox.AuditSkipped)
+			So(audit.events[4].Reason, ShouldEqual, "secret was reported before")
+		})
+	})
+}
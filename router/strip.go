@@ -0,0 +1,52 @@
+package router
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// strippedMask - placeholder of secret in leaks for external senders
+const strippedMask = "[REDACTED]"
+
+// StripSecret - copy of leak which is safe to send outside the perimeter.
+// Secret extracted by pattern is replaced in leak string and context, otherwise every match of pattern
+// or the whole leak string if pattern doesn't match it. Other secrets of context lines are masked by searcher. Fingerprints are kept to link the copy to stored leak,
+// receipt is removed as it can't be verified anymore. Leaks of hash_only mode have nothing to strip.
+func StripSecret(leak hungryfox.Leak) hungryfox.Leak {
+	if leak.Hashed {
+		return leak
+	}
+	leak.StoredFingerprint = leak.Fingerprint()
+	leak.StoredSecretFingerprint = leak.SecretFingerprint()
+	switch {
+	case leak.Secret != "":
+		leak.LeakString = strings.Replace(leak.LeakString, leak.Secret, strippedMask, -1)
+		leak.Context = strings.Replace(leak.Context, leak.Secret, strippedMask, -1)
+	default:
+		stripped := stripMatch(leak.LeakString, leak.Regexp)
+		if leak.LeakString != "" {
+			// context contains leak string as one of its lines
+			leak.Context = strings.Replace(leak.Context, leak.LeakString, stripped, -1)
+		}
+		// the line of leak in context may differ from leak string by masks of other leaks found there
+		leak.Context = leak.MaskPattern(leak.Context, strippedMask)
+		leak.LeakString = stripped
+	}
+	leak.Secret = ""
+	leak.Receipt = nil
+	return leak
+}
+
+func stripMatch(leakString, pattern string) string {
+	re, err := regexp.Compile(pattern)
+	if err != nil || pattern == "" {
+		return strippedMask
+	}
+	loc := re.FindStringIndex(leakString)
+	if loc == nil || loc[0] == loc[1] {
+		return strippedMask
+	}
+	return leakString[:loc[0]] + strippedMask + leakString[loc[1]:]
+}
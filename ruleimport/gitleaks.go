@@ -0,0 +1,103 @@
+package ruleimport
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox/config"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Gitleaks - patterns of gitleaks TOML config, rules of v8 (id, regex, secretGroup, keywords, path)
+// and of v7 (description, regex, file, path) are supported. Warnings are about parts of config which can't be imported
+func Gitleaks(data []byte) ([]config.Pattern, []string, error) {
+	doc := map[string]interface{}{}
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, nil, err
+	}
+	warnings := []string{}
+	if extend, ok := doc["extend"].(map[string]interface{}); ok && len(extend) > 0 {
+		warnings = append(warnings, "extend is not supported, built-in rules of gitleaks are not imported, default_patterns of hungryfox cover most of them")
+	}
+	if _, ok := doc["allowlist"]; ok {
+		warnings = append(warnings, "global allowlist is not imported, use allowlist or filters of hungryfox")
+	}
+	rules, _ := doc["rules"].([]map[string]interface{})
+	if _, ok := doc["rules"]; ok && rules == nil {
+		return nil, nil, fmt.Errorf("rules must be array of tables")
+	}
+	patterns := []config.Pattern{}
+	for i, rule := range rules {
+		name := stringValue(rule, "id")
+		if name == "" {
+			name = stringValue(rule, "description")
+		}
+		if name == "" {
+			name = fmt.Sprintf("gitleaks rule %d", i+1)
+		}
+		regex := stringValue(rule, "regex")
+		if regex == "" {
+			warnings = append(warnings, fmt.Sprintf("rule '%s' has no regex, rules of file paths only are not imported", name))
+			continue
+		}
+		pattern := config.Pattern{
+			Name:     name,
+			Content:  regex,
+			Keywords: stringsValue(rule, "keywords"),
+			Family:   "gitleaks",
+		}
+		// v7 has regexp of file name and regexp of path, v8 has only path
+		if path := stringValue(rule, "path"); path != "" {
+			pattern.File = relativeToRepo(path)
+		} else if file := stringValue(rule, "file"); file != "" {
+			pattern.File = relativeToRepo(file)
+		}
+		if group, ok := rule["secretGroup"].(int64); ok && group > 0 {
+			pattern.SecretGroup = strconv.FormatInt(group, 10)
+		}
+		if _, ok := rule["entropy"]; ok {
+			warnings = append(warnings, fmt.Sprintf("entropy of rule '%s' is ignored, use entropy detectors of hungryfox", name))
+		}
+		if _, ok := rule["entropies"]; ok {
+			warnings = append(warnings, fmt.Sprintf("entropies of rule '%s' are ignored, use entropy detectors of hungryfox", name))
+		}
+		if rule["allowlist"] != nil || rule["allowlists"] != nil {
+			warnings = append(warnings, fmt.Sprintf("allowlist of rule '%s' is not imported, use allowlist or filters of hungryfox", name))
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, warnings, nil
+}
+
+// relativeToRepo - path regexps of gitleaks match paths relative to repo and file regexps of hungryfox match
+// repo url with path, so the start of path is the start of string or slash
+func relativeToRepo(re string) string {
+	flags := ""
+	for _, prefix := range []string{"(?i)", "(?s)", "(?m)"} {
+		if strings.HasPrefix(re, prefix) {
+			flags, re = flags+prefix, strings.TrimPrefix(re, prefix)
+		}
+	}
+	if strings.HasPrefix(re, "^") {
+		re = "(?:^|/)" + strings.TrimPrefix(re, "^")
+	}
+	return flags + re
+}
+
+func stringValue(table map[string]interface{}, key string) string {
+	s, _ := table[key].(string)
+	return s
+}
+
+func stringsValue(table map[string]interface{}, key string) []string {
+	list, _ := table[key].([]interface{})
+	result := []string{}
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
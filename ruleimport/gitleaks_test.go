@@ -0,0 +1,71 @@
+package ruleimport
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox/config"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGitleaks(t *testing.T) {
+	Convey("rules of v8", t, func() {
+		patterns, warnings, err := Gitleaks([]byte(`
+title = "custom"
+
+[extend]
+useDefault = true
+
+[[rules]]
+id = "internal-token"
+description = "Internal API token"
+regex = '''(?i)internal[_-]?token\s*=\s*['"]?([a-z0-9]{32})'''
+secretGroup = 1
+entropy = 3.5
+keywords = ["internal"]
+
+[[rules]]
+id = "env-password"
+regex = '''PASSWORD=(\S+)'''
+path = '''^config/.*\.env$'''
+[rules.allowlist]
+regexes = ['''PASSWORD=changeme''']
+
+[[rules]]
+id = "pkcs12-file"
+path = '''(?i)\.p12$'''
+`))
+		So(err, ShouldBeNil)
+		So(patterns, ShouldResemble, []config.Pattern{
+			{Name: "internal-token", Content: `(?i)internal[_-]?token\s*=\s*['"]?([a-z0-9]{32})`, SecretGroup: "1", Keywords: []string{"internal"}, Family: "gitleaks"},
+			{Name: "env-password", Content: `PASSWORD=(\S+)`, File: `(?:^|/)config/.*\.env$`, Keywords: []string{}, Family: "gitleaks"},
+		})
+		So(warnings, ShouldHaveLength, 4)
+		So(regexp.MustCompile(patterns[1].File).MatchString("https://github.com/a/b/config/prod.env"), ShouldBeTrue)
+		So(regexp.MustCompile(patterns[1].File).MatchString("https://github.com/a/b/app/config/prod.env"), ShouldBeTrue)
+	})
+	Convey("rules of v7 are named by description", t, func() {
+		patterns, warnings, err := Gitleaks([]byte(`
+[[rules]]
+description = "AWS Access Key"
+regex = '''(A3T[A-Z0-9]|AKIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA|ASIA)[A-Z0-9]{16}'''
+file = '''(?i)^.*\.(yml|json)$'''
+tags = ["key", "AWS"]
+`))
+		So(err, ShouldBeNil)
+		So(warnings, ShouldBeEmpty)
+		So(patterns, ShouldHaveLength, 1)
+		So(patterns[0].Name, ShouldEqual, "AWS Access Key")
+		So(patterns[0].File, ShouldEqual, `(?i)(?:^|/).*\.(yml|json)$`)
+	})
+	Convey("bad config", t, func() {
+		_, _, err := Gitleaks([]byte("rules = 1\n"))
+		So(err, ShouldNotBeNil)
+		_, _, err = Gitleaks([]byte("[[rules]\n"))
+		So(err, ShouldNotBeNil)
+		_, _, err = Gitleaks([]byte("[[rules]]\nid = \"a\"\nregex = \"unterminated\n"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "line 3")
+	})
+}
@@ -0,0 +1,51 @@
+// Package ruleimport - patterns of hungryfox from rule files of gitleaks and trufflehog,
+// so curated rulesets are reused without translating their regexps by hand
+package ruleimport
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox/config"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Formats of rule files
+const (
+	FormatGitleaks   = "gitleaks"
+	FormatTrufflehog = "trufflehog"
+)
+
+// Detect - format of rules file: gitleaks for toml files, trufflehog for yaml with detectors or object of regexps,
+// empty for patterns of hungryfox
+func Detect(file string, data []byte) string {
+	if strings.EqualFold(filepath.Ext(file), ".toml") {
+		return FormatGitleaks
+	}
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc) == 0 {
+		return ""
+	}
+	if _, ok := doc["detectors"]; ok {
+		return FormatTrufflehog
+	}
+	for _, value := range doc {
+		if _, ok := value.(string); !ok {
+			return ""
+		}
+	}
+	return FormatTrufflehog
+}
+
+// Convert - patterns of rules file of format with warnings about rules which are not imported entirely
+func Convert(format string, data []byte) ([]config.Pattern, []string, error) {
+	switch format {
+	case FormatGitleaks:
+		return Gitleaks(data)
+	case FormatTrufflehog:
+		return Trufflehog(data)
+	}
+	return nil, nil, fmt.Errorf("unknown format '%s'", format)
+}
@@ -0,0 +1,100 @@
+package ruleimport
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/AlexAkulov/hungryfox/config"
+
+	"gopkg.in/yaml.v2"
+)
+
+type trufflehogConfig struct {
+	Detectors []trufflehogDetector `yaml:"detectors"`
+}
+
+type trufflehogDetector struct {
+	Name     string            `yaml:"name"`
+	Keywords []string          `yaml:"keywords"`
+	Regex    map[string]string `yaml:"regex"`
+	Verify   []interface{}     `yaml:"verify"`
+}
+
+// Trufflehog - patterns of trufflehog custom detectors in yaml (v3) or of regexes.json with names and regexps (v2).
+// The first capture group of regexp is the secret like trufflehog reports it.
+// Warnings are about parts of config which can't be imported
+func Trufflehog(data []byte) ([]config.Pattern, []string, error) {
+	conf := trufflehogConfig{}
+	if err := yaml.Unmarshal(data, &conf); err == nil && len(conf.Detectors) > 0 {
+		return trufflehogDetectors(conf.Detectors)
+	}
+	regexes := map[string]string{}
+	if err := yaml.Unmarshal(data, &regexes); err != nil {
+		return nil, nil, fmt.Errorf("neither custom detectors nor regexes: %v", err)
+	}
+	names := make([]string, 0, len(regexes))
+	for name := range regexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	patterns := []config.Pattern{}
+	for _, name := range names {
+		pattern, err := trufflehogPattern(name, regexes[name], nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, []string{}, nil
+}
+
+func trufflehogDetectors(detectors []trufflehogDetector) ([]config.Pattern, []string, error) {
+	patterns := []config.Pattern{}
+	warnings := []string{}
+	for i, detector := range detectors {
+		if detector.Name == "" {
+			detector.Name = fmt.Sprintf("trufflehog detector %d", i+1)
+		}
+		if len(detector.Regex) == 0 {
+			warnings = append(warnings, fmt.Sprintf("detector '%s' has no regex", detector.Name))
+			continue
+		}
+		if len(detector.Regex) > 1 {
+			// trufflehog reports a match only when all regexps match, they are imported as separate patterns
+			warnings = append(warnings, fmt.Sprintf("regexps of detector '%s' are imported as separate patterns which match on their own", detector.Name))
+		}
+		if len(detector.Verify) > 0 {
+			warnings = append(warnings, fmt.Sprintf("verify endpoints of detector '%s' are not imported", detector.Name))
+		}
+		keys := make([]string, 0, len(detector.Regex))
+		for key := range detector.Regex {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := detector.Name
+			if len(keys) > 1 {
+				name += "." + key
+			}
+			pattern, err := trufflehogPattern(name, detector.Regex[key], detector.Keywords)
+			if err != nil {
+				return nil, nil, err
+			}
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, warnings, nil
+}
+
+func trufflehogPattern(name, regex string, keywords []string) (config.Pattern, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return config.Pattern{}, fmt.Errorf("can't compile regexp of '%s': %v", name, err)
+	}
+	pattern := config.Pattern{Name: name, Content: regex, Keywords: keywords, Family: "trufflehog"}
+	if re.NumSubexp() > 0 {
+		pattern.SecretGroup = "1"
+	}
+	return pattern, nil
+}
@@ -0,0 +1,56 @@
+package ruleimport
+
+import (
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox/config"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTrufflehog(t *testing.T) {
+	Convey("custom detectors of v3", t, func() {
+		patterns, warnings, err := Trufflehog([]byte(`
+detectors:
+  - name: HogTokenDetector
+    keywords: [hog]
+    regex:
+      hogID: '\b(HOG[0-9A-Z]{17})\b'
+      hogToken: '[^A-Za-z0-9+\/]{0,1}([A-Za-z0-9+\/]{40})[^A-Za-z0-9+\/]{0,1}'
+    verify:
+      - endpoint: http://localhost:8000/
+  - name: Plain
+    keywords: [plain]
+    regex:
+      token: 'plain_[a-z]{10}'
+`))
+		So(err, ShouldBeNil)
+		So(patterns, ShouldResemble, []config.Pattern{
+			{Name: "HogTokenDetector.hogID", Content: `\b(HOG[0-9A-Z]{17})\b`, Keywords: []string{"hog"}, SecretGroup: "1", Family: "trufflehog"},
+			{Name: "HogTokenDetector.hogToken", Content: `[^A-Za-z0-9+\/]{0,1}([A-Za-z0-9+\/]{40})[^A-Za-z0-9+\/]{0,1}`, Keywords: []string{"hog"}, SecretGroup: "1", Family: "trufflehog"},
+			{Name: "Plain", Content: "plain_[a-z]{10}", Keywords: []string{"plain"}, Family: "trufflehog"},
+		})
+		So(warnings, ShouldHaveLength, 2)
+	})
+	Convey("regexes of v2", t, func() {
+		patterns, _, err := Trufflehog([]byte(`{"Slack Token": "(xox[pboa]-[0-9]{12}-[0-9]{12}-[0-9]{12}-[a-z0-9]{32})", "AWS API Key": "AKIA[0-9A-Z]{16}"}`))
+		So(err, ShouldBeNil)
+		So(patterns, ShouldHaveLength, 2)
+		So(patterns[0].Name, ShouldEqual, "AWS API Key")
+		So(patterns[1].SecretGroup, ShouldEqual, "1")
+	})
+	Convey("regexps must compile", t, func() {
+		_, _, err := Trufflehog([]byte(`{"lookahead": "(?=a)b"}`))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestDetect(t *testing.T) {
+	Convey("format is detected by extension and content", t, func() {
+		So(Detect("gitleaks.TOML", nil), ShouldEqual, FormatGitleaks)
+		So(Detect("custom.yml", []byte("detectors:\n  - name: a\n")), ShouldEqual, FormatTrufflehog)
+		So(Detect("regexes.json", []byte(`{"a": "b"}`)), ShouldEqual, FormatTrufflehog)
+		So(Detect("patterns.yml", []byte("- name: a\n  content: b\n")), ShouldEqual, "")
+		So(Detect("empty.yml", nil), ShouldEqual, "")
+	})
+}
@@ -0,0 +1,240 @@
+package scanmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// loadAdminRepos - read changes of scan list made by admin api before restart
+func (sm *ScanManager) loadAdminRepos() error {
+	sm.adminMutex.Lock()
+	defer sm.adminMutex.Unlock()
+	sm.admin = hungryfox.AdminRepos{}
+	if sm.getConfig().API.AdminFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(sm.getConfig().API.AdminFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &sm.admin); err != nil {
+		return fmt.Errorf("can't parse %s: %v", sm.getConfig().API.AdminFile, err)
+	}
+	return nil
+}
+
+// saveAdminRepos - admin file is replaced at once, so it is never half written
+func (sm *ScanManager) saveAdminRepos() error {
+	if sm.getConfig().API.AdminFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(sm.admin, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile := sm.getConfig().API.AdminFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, sm.getConfig().API.AdminFile)
+}
+
+// applyAdminRepos - add, remove and pause repos of admin api after repos of config are listed
+func (sm *ScanManager) applyAdminRepos() {
+	sm.adminMutex.Lock()
+	defer sm.adminMutex.Unlock()
+	for _, repoURL := range sm.admin.Added {
+		r, err := sm.adminRepo(repoURL)
+		if err != nil {
+			sm.Log.Error().Str("error", err.Error()).Str("repo_url", repoURL).Str("service", "scan manager").Msg("can't add repo of admin api")
+			continue
+		}
+		sm.repoList.AddRepo(r)
+	}
+	for _, repoURL := range sm.admin.Removed {
+		sm.repoList.RemoveRepo(repoURL)
+	}
+	for _, repoURL := range sm.admin.Paused {
+		sm.repoList.SetPaused(repoURL, true)
+	}
+}
+
+// adminRepo - repo of absolute local path or of https url which is cloned into admin_work_dir
+func (sm *ScanManager) adminRepo(repoURL string) (hungryfox.Repo, error) {
+	if filepath.IsAbs(repoURL) {
+		path := filepath.Clean(repoURL)
+		if !isGitRepo(path) {
+			return hungryfox.Repo{}, fmt.Errorf("%s is not a git repo", path)
+		}
+		return hungryfox.Repo{
+			Location: hungryfox.RepoLocation{
+				URL:      filepath.ToSlash(path),
+				DataPath: filepath.Dir(path),
+				RepoPath: filepath.Base(path),
+			},
+		}, nil
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return hungryfox.Repo{}, fmt.Errorf("'%s' is not absolute path or https url of repo", repoURL)
+	}
+	if sm.getConfig().API.AdminWorkDir == "" {
+		return hungryfox.Repo{}, fmt.Errorf("api.admin_work_dir is required to add repos by url")
+	}
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if repoPath == "" || strings.Contains(repoPath, "..") {
+		return hungryfox.Repo{}, fmt.Errorf("bad path of repo url '%s'", repoURL)
+	}
+	location := hungryfox.RepoLocation{
+		URL:      fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, repoPath),
+		CloneURL: repoURL,
+		DataPath: filepath.Join(sm.getConfig().API.AdminWorkDir, u.Host),
+		RepoPath: repoPath,
+	}
+	return hungryfox.Repo{
+		Location: location,
+		Options: hungryfox.RepoOptions{
+			AllowUpdate:  true,
+			Auth:         sm.getRepoAuth(nil, location),
+			FetchTimeout: sm.getConfig().Common.FetchTimeout,
+		},
+	}, nil
+}
+
+// findAdminRepo - repo of scan list with url, error if scan list is not ready or there is no such repo
+func (sm *ScanManager) findAdminRepo(repoURL string) (*hungryfox.Repo, error) {
+	if sm.repoList == nil {
+		return nil, fmt.Errorf("scan manager is not started")
+	}
+	r := sm.repoList.GetRepoByIndex(sm.repoList.FindRepo(repoURL))
+	if r == nil {
+		return nil, fmt.Errorf("repo %s not found", repoURL)
+	}
+	return r, nil
+}
+
+// AddRepo - add repo to scan list, repo removed by admin api before is returned to scan list
+func (sm *ScanManager) AddRepo(repoURL string) error {
+	if sm.repoList == nil {
+		return fmt.Errorf("scan manager is not started")
+	}
+	sm.adminMutex.Lock()
+	defer sm.adminMutex.Unlock()
+	for i, removed := range sm.admin.Removed {
+		if removed == repoURL || removed == strings.TrimSuffix(repoURL, ".git") {
+			sm.admin.Removed = append(sm.admin.Removed[:i], sm.admin.Removed[i+1:]...)
+			// repo of config comes back with the next update of scan list
+			select {
+			case sm.refresh <- struct{}{}:
+			default:
+			}
+			return sm.saveAdminRepos()
+		}
+	}
+	if sm.repoList.FindRepo(repoURL) >= 0 {
+		return fmt.Errorf("repo %s is already in scan list", repoURL)
+	}
+	r, err := sm.adminRepo(repoURL)
+	if err != nil {
+		return err
+	}
+	sm.admin.Added = append(sm.admin.Added, repoURL)
+	if err := sm.saveAdminRepos(); err != nil {
+		sm.admin.Added = sm.admin.Added[:len(sm.admin.Added)-1]
+		return err
+	}
+	sm.repoList.AddRepo(r)
+	return nil
+}
+
+// RemoveRepo - stop scanning repo, its state is handled by removed_repos
+func (sm *ScanManager) RemoveRepo(repoURL string) error {
+	r, err := sm.findAdminRepo(repoURL)
+	if err != nil {
+		return err
+	}
+	sm.adminMutex.Lock()
+	defer sm.adminMutex.Unlock()
+	added := false
+	for i, a := range sm.admin.Added {
+		if ar, err := sm.adminRepo(a); err == nil && ar.Location.URL == r.Location.URL {
+			sm.admin.Added = append(sm.admin.Added[:i], sm.admin.Added[i+1:]...)
+			added = true
+			break
+		}
+	}
+	if !added {
+		sm.admin.Removed = append(sm.admin.Removed, r.Location.URL)
+	}
+	sm.admin.Paused = without(sm.admin.Paused, r.Location.URL)
+	if err := sm.saveAdminRepos(); err != nil {
+		return err
+	}
+	sm.repoList.SetPaused(r.Location.URL, false)
+	sm.repoList.RemoveRepo(r.Location.URL)
+	return nil
+}
+
+// PauseRepo - keep repo in scan list without scans or resume its scans
+func (sm *ScanManager) PauseRepo(repoURL string, paused bool) error {
+	r, err := sm.findAdminRepo(repoURL)
+	if err != nil {
+		return err
+	}
+	sm.adminMutex.Lock()
+	defer sm.adminMutex.Unlock()
+	sm.admin.Paused = without(sm.admin.Paused, r.Location.URL)
+	if paused {
+		sm.admin.Paused = append(sm.admin.Paused, r.Location.URL)
+	}
+	if err := sm.saveAdminRepos(); err != nil {
+		return err
+	}
+	sm.repoList.SetPaused(r.Location.URL, paused)
+	return nil
+}
+
+// RescanRepo - forget scanned refs of repo and scan it again from the newest commit to history limit
+func (sm *ScanManager) RescanRepo(repoURL string) error {
+	r, err := sm.findAdminRepo(repoURL)
+	if err != nil {
+		return err
+	}
+	select {
+	case sm.rescans <- r.Location.URL:
+		return nil
+	default:
+		return fmt.Errorf("scan queue is full")
+	}
+}
+
+// AdminRepos - changes of scan list made by admin api
+func (sm *ScanManager) AdminRepos() hungryfox.AdminRepos {
+	sm.adminMutex.Lock()
+	defer sm.adminMutex.Unlock()
+	return hungryfox.AdminRepos{
+		Added:   append([]string{}, sm.admin.Added...),
+		Removed: append([]string{}, sm.admin.Removed...),
+		Paused:  append([]string{}, sm.admin.Paused...),
+	}
+}
+
+func without(list []string, value string) []string {
+	result := []string{}
+	for _, item := range list {
+		if item != value {
+			result = append(result, item)
+		}
+	}
+	return result
+}
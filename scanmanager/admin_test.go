@@ -0,0 +1,174 @@
+package scanmanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/hercules"
+
+	"github.com/rs/zerolog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeStateManager struct{}
+
+func (fakeStateManager) Load(string) (hungryfox.RepoState, hungryfox.ScanStatus) {
+	return hungryfox.RepoState{}, hungryfox.ScanStatus{}
+}
+func (fakeStateManager) Save(hungryfox.Repo)    {}
+func (fakeStateManager) List() []hungryfox.Repo { return nil }
+func (fakeStateManager) Delete(string)          {}
+
+type fakeAudit struct {
+	events []hungryfox.AuditEvent
+}
+
+func (f *fakeAudit) Record(event hungryfox.AuditEvent) {
+	f.events = append(f.events, event)
+}
+
+func TestAdminRepos(t *testing.T) {
+	root, err := ioutil.TempDir("", "hungryfox-admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, name := range []string{"api.git", "web.git"} {
+		os.MkdirAll(filepath.Join(root, "repos", name, "objects"), 0755)
+		ioutil.WriteFile(filepath.Join(root, "repos", name, "HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+	}
+	conf, _ := config.ParseConfig(nil)
+	conf.Inspect = []config.Inspect{{Type: "path", URL: "https://git.example.com", TrimPrefix: filepath.Join(root, "repos"), Paths: []string{filepath.Join(root, "repos/api.git")}}}
+	conf.API.AdminFile = filepath.Join(root, "admin.json")
+	newManager := func() *ScanManager {
+		sm := &ScanManager{StateManager: fakeStateManager{}, Log: zerolog.Nop(), config: conf, refresh: make(chan struct{}, 1)}
+		So(sm.loadAdminRepos(), ShouldBeNil)
+		sm.updateScanList()
+		return sm
+	}
+	urls := func(sm *ScanManager) []string {
+		result := []string{}
+		for _, q := range sm.ScanQueue() {
+			if !q.Paused {
+				result = append(result, q.URL)
+			}
+		}
+		return result
+	}
+	localRepo := filepath.ToSlash(filepath.Join(root, "repos/web.git"))
+
+	Convey("changes are applied at once and kept after restart", t, func() {
+		sm := newManager()
+		So(sm.AddRepo(filepath.Join(root, "repos/web.git")), ShouldBeNil)
+		So(sm.AddRepo(filepath.Join(root, "repos/web.git")), ShouldNotBeNil)
+		So(sm.PauseRepo("https://git.example.com/api", true), ShouldBeNil)
+		So(urls(sm), ShouldResemble, []string{localRepo})
+
+		sm = newManager()
+		So(urls(sm), ShouldResemble, []string{localRepo})
+		So(sm.PauseRepo("https://git.example.com/api", false), ShouldBeNil)
+		So(sm.RemoveRepo(localRepo), ShouldBeNil)
+		So(sm.RemoveRepo("https://git.example.com/api"), ShouldBeNil)
+		So(urls(sm), ShouldBeEmpty)
+		So(sm.AdminRepos(), ShouldResemble, hungryfox.AdminRepos{Added: []string{}, Removed: []string{"https://git.example.com/api"}, Paused: []string{}})
+
+		Convey("removed repo of config comes back with the next update", func() {
+			So(sm.AddRepo("https://git.example.com/api"), ShouldBeNil)
+			So(sm.refresh, ShouldHaveLength, 1)
+			sm.updateScanList()
+			So(urls(sm), ShouldResemble, []string{"https://git.example.com/api"})
+		})
+	})
+
+	Convey("full rescan forgets scanned refs", t, func() {
+		os.Remove(conf.API.AdminFile)
+		sm := newManager()
+		sm.rescans = make(chan string, 1)
+		sm.rescanAfter = map[string]bool{}
+		sm.scans = map[string]*scan{}
+		r := sm.repoList.GetRepoByIndex(0)
+		r.State.Refs = []string{"1234"}
+		sm.repoList.UpdateRepo(*r)
+		So(sm.RescanRepo("https://git.example.com/missing"), ShouldNotBeNil)
+		So(sm.RescanRepo(r.Location.URL), ShouldBeNil)
+
+		sm.scans[r.Location.URL] = &scan{repo: *r, gitRepo: &repo.Repo{}, err: fmt.Errorf("fetch failed")}
+		sm.rescanRequested(<-sm.rescans)
+		So(sm.repoList.GetRepoByIndex(0).State.Refs, ShouldResemble, []string{"1234"})
+		So(sm.requested, ShouldBeEmpty)
+
+		sm.finishScan(sm.scans[r.Location.URL])
+		So(sm.repoList.GetRepoByIndex(0).State.Refs, ShouldBeEmpty)
+		So(sm.requested, ShouldResemble, []string{r.Location.URL})
+		So(sm.rescanAfter, ShouldBeEmpty)
+	})
+
+	Convey("blob cache is saved with version of rules and dropped when rules are changed", t, func() {
+		conf.Common.BlobCacheSize = 10
+		defer func() { conf.Common.BlobCacheSize = 0 }()
+		sm := newManager()
+		rules := "v1"
+		sm.RulesVersion = func() string { return rules }
+		r := sm.repoList.GetRepoByIndex(0)
+		r.State.Blobs, r.State.BlobsRules = []string{"a"}, "v1"
+		s := sm.prepareScan(*r)
+		So(s.gitRepo.BlobCache.Seen("a"), ShouldBeTrue)
+		So(s.gitRepo.BlobCache.Seen("b"), ShouldBeFalse)
+		sm.finishScan(s)
+		state := sm.repoList.GetRepoByIndex(0).State
+		So(state.Blobs, ShouldResemble, []string{"a", "b"})
+		So(state.BlobsRules, ShouldEqual, "v1")
+
+		rules = "v2"
+		s = sm.prepareScan(*sm.repoList.GetRepoByIndex(0))
+		So(s.gitRepo.BlobCache.Hashes(), ShouldBeEmpty)
+		s.err = fmt.Errorf("fetch failed")
+		sm.finishScan(s)
+		state = sm.repoList.GetRepoByIndex(0).State
+		So(state.Blobs, ShouldResemble, []string{"a", "b"})
+		So(state.BlobsRules, ShouldEqual, "v1")
+	})
+
+	Convey("start and finish of scan are audited with scanned refs", t, func() {
+		sm := newManager()
+		audit := &fakeAudit{}
+		sm.Audit = audit
+		sm.RulesVersion = func() string { return "v1" }
+		r := sm.repoList.GetRepoByIndex(0)
+		r.State.Refs = []string{"1234"}
+		s := sm.prepareScan(*r)
+		s.err = fmt.Errorf("fetch failed")
+		sm.finishScan(s)
+		So(audit.events, ShouldHaveLength, 2)
+		So(audit.events[0].Action, ShouldEqual, hungryfox.AuditScanStart)
+		So(audit.events[0].Repo, ShouldEqual, "https://git.example.com/api")
+		So(audit.events[0].FromRefs, ShouldResemble, []string{"1234"})
+		So(audit.events[0].Rules, ShouldEqual, "v1")
+		So(audit.events[1].Action, ShouldEqual, hungryfox.AuditScanFinish)
+		So(audit.events[1].Result, ShouldEqual, hungryfox.AuditFailed)
+		So(audit.events[1].Error, ShouldEqual, "fetch failed")
+		So(audit.events[1].FromRefs, ShouldResemble, []string{"1234"})
+		So(audit.events[1].ToRefs, ShouldBeEmpty)
+	})
+
+	Convey("repos are added by https url only with admin_work_dir", t, func() {
+		sm := newManager()
+		So(sm.AddRepo("https://github.com/backend/api.git"), ShouldNotBeNil)
+		So(sm.AddRepo("ftp://github.com/backend/api"), ShouldNotBeNil)
+		conf.API.AdminWorkDir = filepath.Join(root, "work")
+		defer func() { conf.API.AdminWorkDir = "" }()
+		r, err := sm.adminRepo("https://github.com/backend/api.git")
+		So(err, ShouldBeNil)
+		So(r.Location, ShouldResemble, hungryfox.RepoLocation{
+			URL:      "https://github.com/backend/api",
+			CloneURL: "https://github.com/backend/api.git",
+			DataPath: filepath.Join(root, "work", "github.com"),
+			RepoPath: "backend/api",
+		})
+	})
+}
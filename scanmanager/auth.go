@@ -0,0 +1,125 @@
+package scanmanager
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/helpers"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+// getAuth - ssh credentials of inspect, nil means https credentials or ambient git credentials
+func getAuth(inspect config.Inspect) (transport.AuthMethod, error) {
+	if inspect.SSH == nil {
+		return nil, nil
+	}
+	return getSSHAuth(inspect.SSH)
+}
+
+func getSSHAuth(conf *config.SSH) (transport.AuthMethod, error) {
+	if conf.KeyFile == "" {
+		return nil, fmt.Errorf("ssh key_file is required")
+	}
+	user := conf.User
+	if user == "" {
+		user = "git"
+	}
+	auth, err := gitssh.NewPublicKeysFromFile(user, conf.KeyFile, conf.Passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("can't load ssh key %s: %v", conf.KeyFile, err)
+	}
+	if conf.InsecureIgnoreHostKey {
+		auth.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		return auth, nil
+	}
+	var callback ssh.HostKeyCallback
+	if len(conf.KnownHosts) > 0 {
+		callback, err = knownhosts.New(conf.KnownHosts...)
+	} else {
+		// SSH_KNOWN_HOSTS, ~/.ssh/known_hosts or /etc/ssh/ssh_known_hosts
+		callback, err = gitssh.NewKnownHostsCallback()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't load known_hosts: %v", err)
+	}
+	auth.HostKeyCallback = callback
+	return auth, nil
+}
+
+// getRepoAuth - ssh auth of inspect or https token from credentials for clone url
+func (sm *ScanManager) getRepoAuth(sshAuth transport.AuthMethod, location hungryfox.RepoLocation) transport.AuthMethod {
+	if sshAuth != nil {
+		return sshAuth
+	}
+	u, err := url.Parse(location.CloneURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil
+	}
+	credential := sm.getCredential(u, location.RepoPath)
+	if credential == nil {
+		return nil
+	}
+	token, err := credential.GetToken()
+	if err != nil {
+		sm.Log.Error().Str("error", err.Error()).Str("host", credential.Host).Msg("can't get token")
+		return nil
+	}
+	username := credential.Username
+	if username == "" {
+		username = "git"
+	}
+	return &githttp.BasicAuth{Username: username, Password: token}
+}
+
+// getInspectToken - token for discovery api, credentials limited by repos are not used for discovery
+func (sm *ScanManager) getInspectToken(inspect config.Inspect) string {
+	if inspect.Token != "" {
+		return inspect.Token
+	}
+	apiURL := inspect.URL
+	if inspect.Type == "github" {
+		apiURL = "https://github.com"
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return ""
+	}
+	credential := sm.getCredential(u, "")
+	if credential == nil {
+		return ""
+	}
+	token, err := credential.GetToken()
+	if err != nil {
+		sm.Log.Error().Str("error", err.Error()).Str("host", credential.Host).Msg("can't get token")
+		return ""
+	}
+	return token
+}
+
+// getCredential - first credential matching host of u and repoPath
+func (sm *ScanManager) getCredential(u *url.URL, repoPath string) *config.Credential {
+	conf := sm.getConfig()
+	for i := range conf.Credentials {
+		credential := &conf.Credentials[i]
+		if !strings.EqualFold(credential.Host, u.Host) && !strings.EqualFold(credential.Host, u.Hostname()) {
+			continue
+		}
+		if len(credential.Repos) == 0 {
+			return credential
+		}
+		for _, pattern := range credential.Repos {
+			if repoPath != "" && helpers.MatchGlob(pattern, repoPath) {
+				return credential
+			}
+		}
+	}
+	return nil
+}
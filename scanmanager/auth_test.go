@@ -0,0 +1,100 @@
+package scanmanager
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+
+	. "github.com/smartystreets/goconvey/convey"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+func TestGetAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_rsa")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	knownHosts := filepath.Join(dir, "known_hosts")
+	if err := ioutil.WriteFile(knownHosts, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("without ssh section ambient credentials are used", t, func() {
+		auth, err := getAuth(config.Inspect{})
+		So(err, ShouldBeNil)
+		So(auth, ShouldBeNil)
+	})
+	Convey("key file is required", t, func() {
+		_, err := getAuth(config.Inspect{SSH: &config.SSH{}})
+		So(err, ShouldNotBeNil)
+	})
+	Convey("key with known_hosts", t, func() {
+		auth, err := getAuth(config.Inspect{SSH: &config.SSH{KeyFile: keyFile, KnownHosts: []string{knownHosts}}})
+		So(err, ShouldBeNil)
+		So(auth.(*gitssh.PublicKeys).User, ShouldEqual, "git")
+		So(auth.(*gitssh.PublicKeys).HostKeyCallback, ShouldNotBeNil)
+	})
+	Convey("missing known_hosts file", t, func() {
+		_, err := getAuth(config.Inspect{SSH: &config.SSH{KeyFile: keyFile, KnownHosts: []string{filepath.Join(dir, "nope")}}})
+		So(err, ShouldNotBeNil)
+	})
+	Convey("missing key file", t, func() {
+		_, err := getAuth(config.Inspect{SSH: &config.SSH{KeyFile: filepath.Join(dir, "nope"), InsecureIgnoreHostKey: true}})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCredentials(t *testing.T) {
+	os.Setenv("HUNGRYFOX_TEST_TOKEN", "env-token")
+	defer os.Unsetenv("HUNGRYFOX_TEST_TOKEN")
+	sm := &ScanManager{config: &config.Config{Credentials: []config.Credential{
+		{Host: "github.example.com", Repos: []string{"secret/**"}, Username: "bot", TokenSource: config.TokenSource{Token: "secret-token"}},
+		{Host: "github.example.com", TokenSource: config.TokenSource{TokenEnv: "HUNGRYFOX_TEST_TOKEN"}},
+		{Host: "gitlab.example.com:8443", TokenSource: config.TokenSource{Token: "gitlab-token"}},
+	}}}
+	location := func(cloneURL, repoPath string) hungryfox.RepoLocation {
+		return hungryfox.RepoLocation{CloneURL: cloneURL, RepoPath: repoPath}
+	}
+
+	Convey("repo pattern wins by order", t, func() {
+		auth := sm.getRepoAuth(nil, location("https://github.example.com/secret/app.git", "secret/app"))
+		So(auth, ShouldResemble, &githttp.BasicAuth{Username: "bot", Password: "secret-token"})
+	})
+	Convey("host credential with token from env", t, func() {
+		auth := sm.getRepoAuth(nil, location("https://github.example.com/public/app.git", "public/app"))
+		So(auth, ShouldResemble, &githttp.BasicAuth{Username: "git", Password: "env-token"})
+	})
+	Convey("host with port", t, func() {
+		auth := sm.getRepoAuth(nil, location("https://gitlab.example.com:8443/a/b.git", "a/b"))
+		So(auth, ShouldResemble, &githttp.BasicAuth{Username: "git", Password: "gitlab-token"})
+	})
+	Convey("unknown host and ssh urls have no credentials", t, func() {
+		So(sm.getRepoAuth(nil, location("https://other.example.com/a/b.git", "a/b")), ShouldBeNil)
+		So(sm.getRepoAuth(nil, location("git@github.example.com:a/b.git", "a/b")), ShouldBeNil)
+	})
+	Convey("discovery token", t, func() {
+		So(sm.getInspectToken(config.Inspect{Type: "gitlab", URL: "https://github.example.com"}), ShouldEqual, "env-token")
+		So(sm.getInspectToken(config.Inspect{Type: "gitlab", URL: "https://github.example.com", Token: "own"}), ShouldEqual, "own")
+		So(sm.getInspectToken(config.Inspect{Type: "github"}), ShouldEqual, "")
+	})
+}
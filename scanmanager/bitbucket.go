@@ -0,0 +1,62 @@
+package scanmanager
+
+import (
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/bitbucket"
+	"github.com/AlexAkulov/hungryfox/config"
+)
+
+func (sm *ScanManager) inspectBitbucket(inspect config.Inspect) error {
+	bitbucketClient := bitbucket.Client{
+		URL:     inspect.URL,
+		Token:   sm.getInspectToken(inspect),
+		WorkDir: inspect.WorkDir,
+		SSH:     inspect.SSH != nil,
+
+		HTTPClient: sm.getHTTPClient(),
+	}
+	sshAuth, err := getAuth(inspect)
+	if err != nil {
+		sm.Log.Error().Str("error", err.Error()).Str("type", inspect.Type).Msg("can't configure git auth")
+		return err
+	}
+	projects := inspect.Projects
+	if len(projects) == 0 {
+		if projects, err = bitbucketClient.FetchProjects(); err != nil {
+			sm.Log.Error().Str("error", err.Error()).Str("url", inspect.URL).Msg("can't fetch projects from bitbucket")
+			return err
+		}
+	}
+	repoLocations := map[hungryfox.RepoLocation]struct{}{}
+	var failed error // repos of failed requests are missing, so removed repos are not handled
+
+	for _, project := range projects {
+		sm.Log.Debug().Str("project", project).Str("url", inspect.URL).Msg("get repos from bitbucket")
+		repoList, err := bitbucketClient.FetchProjectRepos(project)
+		if err != nil {
+			sm.Log.Error().Str("error", err.Error()).Str("project", project).Msg("can't fetch repos from bitbucket")
+			failed = err
+			continue
+		}
+		for _, repoLocation := range repoList {
+			repoLocations[repoLocation] = struct{}{}
+		}
+	}
+
+	for repoLocation := range repoLocations {
+		if !isRepoIncluded(repoLocation.RepoPath, inspect) {
+			continue
+		}
+		sm.repoList.AddRepo(hungryfox.Repo{
+			Location: repoLocation,
+			Options: hungryfox.RepoOptions{
+				AllowUpdate:  true,
+				Auth:         sm.getRepoAuth(sshAuth, repoLocation),
+				ObjectFormat: inspect.ObjectFormat,
+				FetchTimeout: inspect.FetchTimeout,
+			},
+		})
+	}
+
+	return failed
+}
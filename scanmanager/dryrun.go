@@ -27,14 +27,19 @@ func (sm *ScanManager) DryRun() {
 }
 
 func (sm *ScanManager) getState(r *hungryfox.Repo) error {
+	pastLimit, maxCommits := history(sm.getConfig(), *r)
 	r.Repo = &repo.Repo{
 		DiffChannel:      sm.DiffChannel,
-		HistoryPastLimit: sm.config.Common.HistoryPastLimit,
+		HistoryPastLimit: pastLimit,
+		MaxCommits:       maxCommits,
 		DataPath:         r.Location.DataPath,
 		RepoPath:         r.Location.RepoPath,
 		URL:              r.Location.URL,
 		CloneURL:         r.Location.CloneURL,
 		AllowUpdate:      r.Options.AllowUpdate,
+		Auth:             r.Options.Auth,
+		ObjectFormat:     r.Options.ObjectFormat,
+		FullScanPaths:    sm.getConfig().Common.FullScanPaths,
 	}
 	if err := r.Repo.Open(); err != nil {
 		return err
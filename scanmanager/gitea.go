@@ -0,0 +1,74 @@
+package scanmanager
+
+import (
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/gitea"
+)
+
+func (sm *ScanManager) inspectGitea(inspect config.Inspect) error {
+	giteaClient := gitea.Client{
+		URL:     inspect.URL,
+		Token:   sm.getInspectToken(inspect),
+		WorkDir: inspect.WorkDir,
+		SSH:     inspect.SSH != nil,
+
+		HTTPClient: sm.getHTTPClient(),
+	}
+	sshAuth, err := getAuth(inspect)
+	if err != nil {
+		sm.Log.Error().Str("error", err.Error()).Str("type", inspect.Type).Msg("can't configure git auth")
+		return err
+	}
+	orgs := inspect.Orgs
+	if len(orgs) == 0 && len(inspect.Users) == 0 {
+		if orgs, err = giteaClient.FetchOrgs(); err != nil {
+			sm.Log.Error().Str("error", err.Error()).Str("url", inspect.URL).Msg("can't fetch organisations from gitea")
+			return err
+		}
+	}
+	repoLocations := map[hungryfox.RepoLocation]struct{}{}
+	var failed error // repos of failed requests are missing, so removed repos are not handled
+
+	for _, org := range orgs {
+		sm.Log.Debug().Str("organisation", org).Str("url", inspect.URL).Msg("get repos from gitea")
+		repoList, err := giteaClient.FetchOrgRepos(org)
+		if err != nil {
+			sm.Log.Error().Str("error", err.Error()).Str("organisation", org).Msg("can't fetch repos from gitea")
+			failed = err
+			continue
+		}
+		for _, repoLocation := range repoList {
+			repoLocations[repoLocation] = struct{}{}
+		}
+	}
+	for _, user := range inspect.Users {
+		sm.Log.Debug().Str("user", user).Str("url", inspect.URL).Msg("get repos from gitea")
+		repoList, err := giteaClient.FetchUserRepos(user)
+		if err != nil {
+			sm.Log.Error().Str("error", err.Error()).Str("user", user).Msg("can't fetch repos from gitea")
+			failed = err
+			continue
+		}
+		for _, repoLocation := range repoList {
+			repoLocations[repoLocation] = struct{}{}
+		}
+	}
+
+	for repoLocation := range repoLocations {
+		if !isRepoIncluded(repoLocation.RepoPath, inspect) {
+			continue
+		}
+		sm.repoList.AddRepo(hungryfox.Repo{
+			Location: repoLocation,
+			Options: hungryfox.RepoOptions{
+				AllowUpdate:  true,
+				Auth:         sm.getRepoAuth(sshAuth, repoLocation),
+				ObjectFormat: inspect.ObjectFormat,
+				FetchTimeout: inspect.FetchTimeout,
+			},
+		})
+	}
+
+	return failed
+}
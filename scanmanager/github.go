@@ -11,7 +11,10 @@ import (
 func getGitHubRepoURL(repoPath string) string {
 	return fmt.Sprintf("https://github.com/%s", repoPath)
 }
-func getGitHubCloneURL(repoPath string) string {
+func getGitHubCloneURL(repoPath string, ssh bool) string {
+	if ssh {
+		return fmt.Sprintf("git@github.com:%s.git", repoPath)
+	}
 	return fmt.Sprintf("https://github.com/%s.git", repoPath)
 }
 func getGitHubRepoPath(repoPath string) (string, error) {
@@ -20,16 +23,26 @@ func getGitHubRepoPath(repoPath string) (string, error) {
 
 func (sm *ScanManager) inspectGithub(inspect config.Inspect) error {
 	githubClient := github.Client{
-		Token:   inspect.Token,
+		Token:   sm.getInspectToken(inspect),
 		WorkDir: inspect.WorkDir,
+		SSH:     inspect.SSH != nil,
+
+		HTTPClient: sm.getHTTPClient(),
+	}
+	sshAuth, err := getAuth(inspect)
+	if err != nil {
+		sm.Log.Error().Str("error", err.Error()).Str("type", inspect.Type).Msg("can't configure git auth")
+		return err
 	}
 	repoLocations := map[hungryfox.RepoLocation]struct{}{}
+	var failed error // repos of failed requests are missing, so removed repos are not handled
 
 	for _, org := range inspect.Orgs {
 		sm.Log.Debug().Str("organisation", org).Msg("get repos from github.com")
 		repoList, err := githubClient.FetchOrgRepos(org)
 		if err != nil {
 			sm.Log.Error().Str("error", err.Error()).Str("organisation", org).Msg("can't fetch repos from github")
+			failed = err
 			continue
 		}
 		for _, repoLocation := range repoList {
@@ -41,6 +54,7 @@ func (sm *ScanManager) inspectGithub(inspect config.Inspect) error {
 		repoList, err := githubClient.FetchUserRepos(user)
 		if err != nil {
 			sm.Log.Error().Str("error", err.Error()).Str("user", user).Msg("can't fetch repos from github")
+			failed = err
 			continue
 		}
 		for _, repoLocation := range repoList {
@@ -50,7 +64,7 @@ func (sm *ScanManager) inspectGithub(inspect config.Inspect) error {
 	for _, repo := range inspect.Repos {
 		repoLocation := hungryfox.RepoLocation{
 			URL:      getGitHubRepoURL(repo),
-			CloneURL: getGitHubCloneURL(repo),
+			CloneURL: getGitHubCloneURL(repo, inspect.SSH != nil),
 			RepoPath: repo,
 			DataPath: inspect.WorkDir,
 		}
@@ -58,11 +72,19 @@ func (sm *ScanManager) inspectGithub(inspect config.Inspect) error {
 	}
 
 	for repoLocation := range repoLocations {
+		if !isRepoIncluded(repoLocation.RepoPath, inspect) {
+			continue
+		}
 		sm.repoList.AddRepo(hungryfox.Repo{
 			Location: repoLocation,
-			Options:  hungryfox.RepoOptions{AllowUpdate: true},
+			Options: hungryfox.RepoOptions{
+				AllowUpdate:  true,
+				Auth:         sm.getRepoAuth(sshAuth, repoLocation),
+				ObjectFormat: inspect.ObjectFormat,
+				FetchTimeout: inspect.FetchTimeout,
+			},
 		})
 	}
 
-	return nil
+	return failed
 }
@@ -0,0 +1,55 @@
+package scanmanager
+
+import (
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/gitlab"
+)
+
+func (sm *ScanManager) inspectGitlab(inspect config.Inspect) error {
+	gitlabClient := gitlab.Client{
+		URL:     inspect.URL,
+		Token:   sm.getInspectToken(inspect),
+		WorkDir: inspect.WorkDir,
+		SSH:     inspect.SSH != nil,
+
+		HTTPClient: sm.getHTTPClient(),
+	}
+	sshAuth, err := getAuth(inspect)
+	if err != nil {
+		sm.Log.Error().Str("error", err.Error()).Str("type", inspect.Type).Msg("can't configure git auth")
+		return err
+	}
+	repoLocations := map[hungryfox.RepoLocation]struct{}{}
+	var failed error // repos of failed requests are missing, so removed repos are not handled
+
+	for _, group := range inspect.Groups {
+		sm.Log.Debug().Str("group", group).Str("url", inspect.URL).Msg("get repos from gitlab")
+		repoList, err := gitlabClient.FetchGroupRepos(group)
+		if err != nil {
+			sm.Log.Error().Str("error", err.Error()).Str("group", group).Msg("can't fetch repos from gitlab")
+			failed = err
+			continue
+		}
+		for _, repoLocation := range repoList {
+			repoLocations[repoLocation] = struct{}{}
+		}
+	}
+
+	for repoLocation := range repoLocations {
+		if !isRepoIncluded(repoLocation.RepoPath, inspect) {
+			continue
+		}
+		sm.repoList.AddRepo(hungryfox.Repo{
+			Location: repoLocation,
+			Options: hungryfox.RepoOptions{
+				AllowUpdate:  true,
+				Auth:         sm.getRepoAuth(sshAuth, repoLocation),
+				ObjectFormat: inspect.ObjectFormat,
+				FetchTimeout: inspect.FetchTimeout,
+			},
+		})
+	}
+
+	return failed
+}
@@ -4,12 +4,73 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/AlexAkulov/hungryfox"
 	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/helpers"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 )
 
+// globPaths - paths matching pattern, "**" matches any number of directories, directories of found repos
+// are not walked into
+func globPaths(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	// walk from the deepest directory without wildcards
+	root := filepath.Dir(pattern[:strings.IndexAny(pattern, "*?[")+1])
+	slashPattern := filepath.ToSlash(pattern)
+	result := []string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// unreadable directories are skipped as by filepath.Glob
+			if info != nil && info.IsDir() && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if helpers.MatchGlob(slashPattern, filepath.ToSlash(path)) {
+			result = append(result, path)
+			if isGitRepo(path) {
+				return filepath.SkipDir
+			}
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// isGitRepo - directory is work tree with .git or bare repo with HEAD and objects
+func isGitRepo(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		return true
+	}
+	head, err := os.Stat(filepath.Join(path, "HEAD"))
+	if err != nil || head.IsDir() {
+		return false
+	}
+	objects, err := os.Stat(filepath.Join(path, "objects"))
+	return err == nil && objects.IsDir()
+}
+
+// expandGlob - git repos matching paths of inspect, globs are expanded on every update of scan list,
+// so new repos on git server host are picked up without config changes
 func expandGlob(inspect config.Inspect) (map[string]struct{}, error) {
 	excludePaths := make(map[string]struct{})
 	for _, pattern := range inspect.Paths {
@@ -17,7 +78,7 @@ func expandGlob(inspect config.Inspect) (map[string]struct{}, error) {
 			continue
 		}
 		pattern = strings.TrimPrefix(pattern, "!")
-		paths, err := filepath.Glob(pattern)
+		paths, err := globPaths(pattern)
 		if err != nil {
 			return nil, err
 		}
@@ -30,7 +91,7 @@ func expandGlob(inspect config.Inspect) (map[string]struct{}, error) {
 		if strings.HasPrefix(pattern, "!") {
 			continue
 		}
-		paths, err := filepath.Glob(pattern)
+		paths, err := globPaths(pattern)
 		if err != nil {
 			return nil, err
 		}
@@ -38,7 +99,7 @@ func expandGlob(inspect config.Inspect) (map[string]struct{}, error) {
 			if _, ok := excludePaths[path]; ok {
 				continue
 			}
-			if f, _ := os.Stat(path); f.IsDir() {
+			if isGitRepo(path) {
 				scanPaths[path] = struct{}{}
 			}
 		}
@@ -52,21 +113,53 @@ func (sm *ScanManager) inspectRepoPath(inspectObject config.Inspect) error {
 		sm.Log.Error().Str("error", err.Error()).Msg("can't expand glob")
 		return err
 	}
+	var sshAuth transport.AuthMethod
+	if inspectObject.Fetch {
+		if sshAuth, err = getAuth(inspectObject); err != nil {
+			sm.Log.Error().Str("error", err.Error()).Str("type", inspectObject.Type).Msg("can't configure git auth")
+			return err
+		}
+	}
 	for path := range scanPathList {
 		location := getRepoLocation(path, inspectObject)
+		options := hungryfox.RepoOptions{AllowUpdate: false, ObjectFormat: inspectObject.ObjectFormat}
+		if inspectObject.Fetch {
+			// credentials are chosen by url of the remote which is fetched
+			location.CloneURL = remoteURL(path, inspectObject.Remote)
+			options.Fetch = true
+			options.Remote = inspectObject.Remote
+			options.FetchTimeout = inspectObject.FetchTimeout
+			options.Auth = sm.getRepoAuth(sshAuth, location)
+		}
 		sm.repoList.AddRepo(hungryfox.Repo{
-			Options:  hungryfox.RepoOptions{AllowUpdate: false},
+			Options:  options,
 			Location: location,
 		})
 	}
 	return nil
 }
 
+// remoteURL - the first url of remote of repo at path, empty if repo has no such remote
+func remoteURL(path, remote string) string {
+	if remote == "" {
+		remote = git.DefaultRemoteName
+	}
+	repository, err := git.PlainOpen(path)
+	if err != nil {
+		return ""
+	}
+	r, err := repository.Remote(remote)
+	if err != nil || len(r.Config().URLs) == 0 {
+		return ""
+	}
+	return r.Config().URLs[0]
+}
+
 func getRepoLocation(path string, inspectObject config.Inspect) hungryfox.RepoLocation {
 	prefix := strings.Replace(inspectObject.TrimPrefix, "\\", "/", -1)
 	prefix = strings.TrimSuffix(prefix, "/")
 	path = strings.Replace(path, "\\", "/", -1)
-	path = strings.TrimPrefix(path, prefix)
+	path = trimPathPrefix(path, prefix)
 	path = strings.Trim(path, "/")
 	url := strings.TrimSuffix(inspectObject.URL, "/")
 	url = fmt.Sprintf("%s/%s", url, strings.TrimSuffix(path, ".git"))
@@ -77,3 +170,11 @@ func getRepoLocation(path string, inspectObject config.Inspect) hungryfox.RepoLo
 		URL:      url,
 	}
 }
+
+// trimPathPrefix - paths on windows are case-insensitive, so C:/Repos is trimmed by prefix c:/repos
+func trimPathPrefix(path, prefix string) string {
+	if runtime.GOOS == "windows" && len(path) >= len(prefix) && strings.EqualFold(path[:len(prefix)], prefix) {
+		return path[len(prefix):]
+	}
+	return strings.TrimPrefix(path, prefix)
+}
@@ -0,0 +1,88 @@
+package scanmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox/config"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExpandGlob(t *testing.T) {
+	root, err := ioutil.TempDir("", "hungryfox-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	bare := func(path string) {
+		os.MkdirAll(filepath.Join(root, path, "objects"), 0755)
+		ioutil.WriteFile(filepath.Join(root, path, "HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+	}
+	bare("backend/api.git")
+	bare("backend/team/web.git")
+	bare("excluded/old.git")
+	os.MkdirAll(filepath.Join(root, "frontend/app/.git"), 0755)
+	os.MkdirAll(filepath.Join(root, "backend/notrepo.git"), 0755)
+	bare("backend/api.git/modules/nested.git")
+
+	expand := func(paths ...string) []string {
+		found, err := expandGlob(config.Inspect{Paths: paths})
+		So(err, ShouldBeNil)
+		result := []string{}
+		for path := range found {
+			rel, _ := filepath.Rel(root, path)
+			result = append(result, filepath.ToSlash(rel))
+		}
+		return result
+	}
+
+	Convey("only git repos are found", t, func() {
+		So(expand(filepath.Join(root, "*/*.git"), "!"+filepath.Join(root, "excluded/*")), ShouldResemble, []string{"backend/api.git"})
+	})
+
+	Convey("** matches any depth and repos are not walked into", t, func() {
+		found := expand(filepath.Join(root, "**/*.git"), "!"+filepath.Join(root, "excluded/**"))
+		So(found, ShouldHaveLength, 2)
+		So(found, ShouldContain, "backend/api.git")
+		So(found, ShouldContain, "backend/team/web.git")
+		So(expand(filepath.Join(root, "**")), ShouldContain, "frontend/app")
+	})
+
+	Convey("missing root is empty", t, func() {
+		So(expand(filepath.Join(root, "missing/**/*.git")), ShouldBeEmpty)
+	})
+}
+
+func TestRemoteURL(t *testing.T) {
+	root, err := ioutil.TempDir("", "hungryfox-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	os.MkdirAll(filepath.Join(root, "objects"), 0755)
+	os.MkdirAll(filepath.Join(root, "refs"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "config"), []byte("[core]\n\tbare = true\n"+
+		"[remote \"origin\"]\n\turl = https://git.example.com/backend/api.git\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n"+
+		"[remote \"backup\"]\n\turl = git@backup.example.com:backend/api.git\n"), 0644)
+
+	Convey("url of remote is read from config of repo", t, func() {
+		So(remoteURL(root, ""), ShouldEqual, "https://git.example.com/backend/api.git")
+		So(remoteURL(root, "backup"), ShouldEqual, "git@backup.example.com:backend/api.git")
+		So(remoteURL(root, "missing"), ShouldBeEmpty)
+		So(remoteURL(filepath.Join(root, "missing"), ""), ShouldBeEmpty)
+	})
+}
+
+func TestGetRepoLocation(t *testing.T) {
+	Convey("windows path is trimmed by prefix and separated by slash", t, func() {
+		inspect := config.Inspect{TrimPrefix: `C:\repos\`, URL: "https://git.example.com/"}
+		location := getRepoLocation(`C:\repos\backend\api.git`, inspect)
+		So(location.DataPath, ShouldEqual, "C:/repos")
+		So(location.RepoPath, ShouldEqual, "backend/api.git")
+		So(location.URL, ShouldEqual, "https://git.example.com/backend/api")
+	})
+}
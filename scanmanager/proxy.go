@@ -0,0 +1,92 @@
+package scanmanager
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/proxy"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+)
+
+var (
+	// gitTransport - transport of git over http and https, go-git keeps protocols in a global map which
+	// can't be changed while workers fetch, so it is installed once and proxies of reloaded config are
+	// swapped inside
+	gitTransport     = &swappableTransport{}
+	installTransport sync.Once
+)
+
+type swappableTransport struct {
+	mutex     sync.RWMutex
+	transport http.RoundTripper
+}
+
+func (t *swappableTransport) set(transport http.RoundTripper) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.transport = transport
+}
+
+// RoundTrip - request by transport of the latest config
+func (t *swappableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mutex.RLock()
+	transport := t.transport
+	t.mutex.RUnlock()
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(req)
+}
+
+// setupProxy - build http client for discovery api and use it for git over http and https,
+// proxy of inspect is used for its api host
+func setupProxy(conf *config.Config) (*http.Client, error) {
+	hosts := map[string]string{}
+	for host, proxyURL := range conf.Proxy.Hosts {
+		hosts[host] = proxyURL
+	}
+	for _, inspect := range conf.Inspect {
+		if inspect.Proxy == "" {
+			continue
+		}
+		for _, host := range inspectHosts(inspect) {
+			hosts[host] = inspect.Proxy
+		}
+	}
+	p, err := proxy.New(conf.Proxy.URL, hosts, conf.Proxy.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := p.Client()
+	gitTransport.set(httpClient.Transport)
+	installTransport.Do(func() {
+		gitClient := &http.Client{Transport: gitTransport}
+		client.InstallProtocol("https", githttp.NewClient(gitClient))
+		client.InstallProtocol("http", githttp.NewClient(gitClient))
+	})
+	return httpClient, nil
+}
+
+func inspectHosts(inspect config.Inspect) []string {
+	if inspect.Type == "github" {
+		return []string{"github.com", "api.github.com"}
+	}
+	u, err := url.Parse(inspect.URL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	return []string{u.Host}
+}
+
+func (sm *ScanManager) getHTTPClient() *http.Client {
+	sm.configMutex.RLock()
+	defer sm.configMutex.RUnlock()
+	if sm.httpClient == nil {
+		return http.DefaultClient
+	}
+	return sm.httpClient
+}
@@ -0,0 +1,46 @@
+package scanmanager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox/config"
+
+	"github.com/rs/zerolog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReloadProxy(t *testing.T) {
+	Convey("config is reloaded while scan queue is read and git requests are sent", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+		newConfig := func() *config.Config {
+			return &config.Config{Common: &config.Common{}, Proxy: &config.Proxy{}}
+		}
+		conf := newConfig()
+		sm := &ScanManager{StateManager: fakeStateManager{}, Log: zerolog.Nop()}
+		sm.applyConfig(conf)
+		wg := sync.WaitGroup{}
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					sm.ScanQueue()
+					sm.getHTTPClient()
+					resp, err := (&http.Client{Transport: gitTransport}).Get(server.URL)
+					if err == nil {
+						resp.Body.Close()
+					}
+				}
+			}()
+		}
+		for i := 0; i < 20; i++ {
+			sm.applyConfig(newConfig())
+		}
+		wg.Wait()
+		So(sm.getConfig(), ShouldNotEqual, conf)
+	})
+}
@@ -0,0 +1,51 @@
+package scanmanager
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// handleRemovedRepos - archive or forget state of repos which are not in scan list anymore
+// and delete their mirrors after grace period
+func (sm *ScanManager) handleRemovedRepos() {
+	policy := sm.getConfig().Common.RemovedRepos
+	for _, r := range sm.StateManager.List() {
+		if sm.repoList.FindRepo(r.Location.URL) >= 0 {
+			continue
+		}
+		if r.State.RemovedAt.IsZero() {
+			sm.Log.Info().Str("repo_url", r.Location.URL).Bool("archive_state", policy.ArchiveState).Msg("repo removed from scan list")
+			if !policy.ArchiveState && !canDeleteMirror(r, policy.DeleteMirrorAfter) {
+				sm.StateManager.Delete(r.Location.URL)
+				continue
+			}
+			r.State.RemovedAt = sm.now().UTC()
+			sm.StateManager.Save(r)
+			continue
+		}
+		if !canDeleteMirror(r, policy.DeleteMirrorAfter) || sm.now().Sub(r.State.RemovedAt) < policy.DeleteMirrorAfter {
+			continue
+		}
+		mirrorPath := filepath.Join(r.Location.DataPath, r.Location.RepoPath)
+		if err := os.RemoveAll(mirrorPath); err != nil {
+			sm.Log.Error().Str("error", err.Error()).Str("path", mirrorPath).Msg("can't delete mirror of removed repo")
+			continue
+		}
+		sm.Log.Info().Str("repo_url", r.Location.URL).Str("path", mirrorPath).Msg("mirror of removed repo deleted")
+		if policy.ArchiveState {
+			// keep state but don't try to delete mirror again
+			r.Options.AllowUpdate = false
+			sm.StateManager.Save(r)
+			continue
+		}
+		sm.StateManager.Delete(r.Location.URL)
+	}
+}
+
+// canDeleteMirror - only repos cloned by hungryfox can be deleted, never local repos from path inspect
+func canDeleteMirror(r hungryfox.Repo, deleteAfter time.Duration) bool {
+	return r.Options.AllowUpdate && deleteAfter > 0 && r.Location.DataPath != "" && r.Location.RepoPath != ""
+}
@@ -1,9 +1,13 @@
 package scanmanager
 
 import (
+	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
 	"github.com/AlexAkulov/hungryfox/config"
 	"github.com/AlexAkulov/hungryfox/helpers"
 	"github.com/AlexAkulov/hungryfox/hercules"
@@ -13,72 +17,306 @@ import (
 	"gopkg.in/tomb.v2"
 )
 
+// maxWait - the longest sleep of scan loop before the scan queue is checked again
+const maxWait = 10 * time.Minute
+
 // ScanManager -
 type ScanManager struct {
 	DiffChannel  chan<- *hungryfox.Diff
 	Log          zerolog.Logger
 	StateManager hungryfox.IStateManager
+	Clock        clock.Clock // system clock if nil
+	// Leaks - found leaks raise scan priority of their repos, repos are ordered only by time of last scan if nil
+	Leaks hungryfox.ILeakStore
+	// RulesVersion - version of rules which blob cache is valid for, the cache is dropped when rules are changed
+	RulesVersion func() string
+	// Observer - receives finished scans, nothing is reported if nil
+	Observer hungryfox.IScanObserver
+	// Audit - start and finish of every scan with scanned refs, nothing is recorded if nil
+	Audit hungryfox.IAuditLog
 
-	config      *config.Config
-	tomb        tomb.Tomb
-	currentRepo int
-	repoList    *repolist.RepoList
+	configMutex  sync.RWMutex
+	config       *config.Config // replaced on reload, read it by getConfig
+	tomb         tomb.Tomb
+	repoList     *repolist.RepoList
+	scanRequests chan []string
+	requested    []string // urls of requested scans which wait for free worker
+	rescans      chan string
+	rescanAfter  map[string]bool // rescans requested while repo was being scanned
+	done         chan *scan
+	wake         chan struct{} // fetch slot of host is released
+	hosts        hostSlots
+	scansMutex   sync.Mutex
+	scans        map[string]*scan // running scans by repo url
+	queueMutex   sync.Mutex       // scan queue is read while scan is finished and state of its repo is saved
+	countsMutex  sync.Mutex
+	counts       map[string]int // leaks of repos, they are read from Leaks again after leakCountsTTL
+	countsAt     time.Time
+	configs      chan *config.Config
+	refresh      chan struct{} // update of scan list is requested by admin api
+	httpClient   *http.Client
+	adminMutex   sync.Mutex
+	admin        hungryfox.AdminRepos
 }
 
-// SetConfig - update configuration
+// SetConfig - update configuration, when ScanManager is started it is applied after current scan
 func (sm *ScanManager) SetConfig(config *config.Config) {
-	sm.config = config
+	if sm.configs == nil {
+		sm.applyConfig(config)
+		return
+	}
+	for {
+		select {
+		case sm.configs <- config:
+			return
+		default:
+		}
+		// drop config which wasn't applied yet, the newest one wins
+		select {
+		case <-sm.configs:
+		default:
+		}
+	}
+}
+
+func (sm *ScanManager) applyConfig(conf *config.Config) {
+	httpClient, err := setupProxy(conf)
+	if err != nil {
+		sm.Log.Error().Str("error", err.Error()).Str("service", "scan manager").Msg("can't setup proxy")
+	}
+	sm.configMutex.Lock()
+	sm.config = conf
+	if err == nil {
+		sm.httpClient = httpClient
+	}
+	sm.configMutex.Unlock()
 	sm.Log.Debug().Str("service", "scan manager").Msg("config reloaded")
 	sm.updateScanList()
 }
 
-// Status - get status for current repo
+// getConfig - config of scan loop, a scan keeps the config it was started with
+func (sm *ScanManager) getConfig() *config.Config {
+	sm.configMutex.RLock()
+	defer sm.configMutex.RUnlock()
+	return sm.config
+}
+
+// Status - get status for the longest running scan, nil if no repo is being scanned
 func (sm *ScanManager) Status() *hungryfox.Repo {
-	return sm.repoList.GetRepoByIndex(sm.currentRepo)
+	sm.scansMutex.Lock()
+	defer sm.scansMutex.Unlock()
+	var result *hungryfox.Repo
+	for _, s := range sm.scans {
+		if result == nil || s.repo.Scan.StartTime.Before(result.Scan.StartTime) {
+			r := s.repo
+			result = &r
+		}
+	}
+	return result
 }
 
 func (sm *ScanManager) updateScanList() {
 	sm.Log.Debug().Str("status", "start").Msg("update scan list")
 	if sm.repoList == nil {
 		sm.repoList = &repolist.RepoList{State: sm.StateManager}
+		sm.repoList.NextScan = func(r hungryfox.Repo) time.Time {
+			return nextScan(sm.getConfig(), r)
+		}
+	}
+	known := map[string]bool{}
+	for i := 0; i < sm.repoList.GetTotalRepos(); i++ {
+		known[sm.repoList.GetRepoByIndex(i).Location.URL] = true
 	}
 	sm.repoList.Clear()
-	for _, inspectObject := range sm.config.Inspect {
+	// repos of failed discovery (api error, auth failure, rate limit) are missing from the list
+	discovered := true
+	for _, inspectObject := range sm.getConfig().Inspect {
+		var err error
 		switch inspectObject.Type {
 		case "path":
-			sm.inspectRepoPath(inspectObject)
+			err = sm.inspectRepoPath(inspectObject)
 		case "github":
-			sm.inspectGithub(inspectObject)
+			err = sm.inspectGithub(inspectObject)
+		case "gitlab":
+			err = sm.inspectGitlab(inspectObject)
+		case "bitbucket":
+			err = sm.inspectBitbucket(inspectObject)
+		case "gitea", "forgejo":
+			err = sm.inspectGitea(inspectObject)
 		default:
+			err = fmt.Errorf("unsupported type %s", inspectObject.Type)
 			sm.Log.Error().Str("type", inspectObject.Type).Msg("unsupported type")
 		}
+		if err != nil {
+			discovered = false
+		}
+	}
+	sm.applyAdminRepos()
+	if len(known) > 0 {
+		for i := 0; i < sm.repoList.GetTotalRepos(); i++ {
+			if r := sm.repoList.GetRepoByIndex(i); !known[r.Location.URL] {
+				sm.Log.Info().Str("repo_url", r.Location.URL).Str("repo_path", r.Location.RepoPath).Msg("new repo discovered")
+			}
+		}
+	}
+	if discovered {
+		sm.handleRemovedRepos()
+	} else {
+		sm.Log.Warn().Str("service", "scan manager").Msg("discovery failed, removed repos are handled after successful one")
+	}
+	sm.Log.Debug().Str("status", "complete").Int("repos", sm.repoList.GetTotalRepos()).Msg("update scan list")
+}
+
+// isRepoIncluded - check repo path with include and exclude patterns of inspect
+func isRepoIncluded(repoPath string, inspect config.Inspect) bool {
+	for _, pattern := range inspect.Exclude {
+		if helpers.MatchGlob(pattern, repoPath) {
+			return false
+		}
+	}
+	if len(inspect.Include) == 0 {
+		return true
+	}
+	for _, pattern := range inspect.Include {
+		if helpers.MatchGlob(pattern, repoPath) {
+			return true
+		}
 	}
-	sm.Log.Debug().Str("status", "complete").Msg("update scan list")
+	return false
 }
 
 // Start - start ScanManager instance
-func (sm *ScanManager) Start(config *config.Config) error {
-	sm.config = config
-	sm.currentRepo = -1
+func (sm *ScanManager) Start(conf *config.Config) error {
+	httpClient, err := setupProxy(conf)
+	if err != nil {
+		return err
+	}
+	sm.configMutex.Lock()
+	sm.config, sm.httpClient = conf, httpClient
+	sm.configMutex.Unlock()
+	sm.scanRequests = make(chan []string, 100)
+	sm.rescans = make(chan string, 100)
+	sm.rescanAfter = map[string]bool{}
+	sm.done = make(chan *scan)
+	sm.wake = make(chan struct{}, 1)
+	sm.scans = map[string]*scan{}
+	sm.refresh = make(chan struct{}, 1)
+	if err := sm.loadAdminRepos(); err != nil {
+		return err
+	}
 	sm.updateScanList()
+	sm.configs = make(chan *config.Config, 1)
+	due := 0
+	queue := sm.ScanQueue()
+	for _, q := range queue {
+		if q.Due {
+			due++
+		}
+	}
+	if due > 0 {
+		sm.Log.Info().Int("due", due).Int("repos", len(queue)).Str("first", queue[0].URL).Msg("scan queue")
+	}
 
 	sm.tomb.Go(func() error {
-		updateTicker := time.NewTicker(time.Minute * 30)
+		discoveryInterval := conf.Common.DiscoveryInterval
+		if discoveryInterval <= 0 {
+			discoveryInterval = 30 * time.Minute
+		}
+		updateTicker := time.NewTicker(discoveryInterval)
+		defer updateTicker.Stop()
 		scanTimer := time.NewTimer(time.Second)
+		scanIn := func(wait time.Duration) {
+			if !scanTimer.Stop() {
+				select {
+				case <-scanTimer.C:
+				default:
+				}
+			}
+			scanTimer.Reset(wait)
+		}
 		for {
 			select {
 			case <-sm.tomb.Dying():
+				scanTimer.Stop()
+				// running scans are interrupted after the commit which is being sent to DiffChannel
+				for sm.running() > 0 {
+					sm.finishScan(<-sm.done)
+				}
 				return nil
 			case <-updateTicker.C:
 				sm.updateScanList()
+				scanIn(0)
+			case <-sm.refresh:
+				sm.updateScanList()
+				scanIn(0)
+			case conf := <-sm.configs:
+				sm.applyConfig(conf)
+				scanIn(0)
+			case urls := <-sm.scanRequests:
+				sm.scanRequested(urls)
+				scanIn(0)
+			case url := <-sm.rescans:
+				sm.rescanRequested(url)
+				scanIn(0)
+			case s := <-sm.done:
+				sm.finishScan(s)
+				scanIn(0)
+			case <-sm.wake:
+				scanIn(0)
 			case <-scanTimer.C:
-				scanTimer = sm.scanNext()
+				scanIn(sm.scanNext())
 			}
 		}
 	})
 	return nil
 }
 
+// TriggerScan - queue immediate scan of repo with one of urls
+func (sm *ScanManager) TriggerScan(urls ...string) error {
+	select {
+	case sm.scanRequests <- urls:
+		return nil
+	default:
+		return fmt.Errorf("scan queue is full")
+	}
+}
+
+// scanRequested - requested scans go before scan queue, they wait only for free worker and fetch slot
+func (sm *ScanManager) scanRequested(urls []string) {
+	r := sm.repoList.GetRepoByIndex(sm.repoList.FindRepo(urls...))
+	if r == nil {
+		sm.Log.Warn().Strs("urls", urls).Msg("repo for requested scan not found")
+		return
+	}
+	for _, url := range sm.requested {
+		if url == r.Location.URL {
+			return
+		}
+	}
+	sm.requested = append(sm.requested, r.Location.URL)
+}
+
+// rescanRequested - forget scanned refs of repo and scan all its history, refs of running scan are
+// forgotten when it is finished
+func (sm *ScanManager) rescanRequested(url string) {
+	if sm.isScanning(url) {
+		sm.rescanAfter[url] = true
+		sm.Log.Info().Str("repo_url", url).Msg("full rescan is queued after running scan")
+		return
+	}
+	r := sm.repoList.GetRepoByIndex(sm.repoList.FindRepo(url))
+	if r == nil {
+		sm.Log.Warn().Str("repo_url", url).Msg("repo for full rescan not found")
+		return
+	}
+	r.State.Refs = []string{}
+	r.State.Blobs = nil
+	sm.repoList.UpdateRepo(*r)
+	sm.Log.Info().Str("repo_url", url).Msg("scanned refs are forgotten for full rescan")
+	sm.scanRequested([]string{url})
+}
+
+// Stop - stop scan loop, running scans are interrupted after the commit which is being sent to DiffChannel
 func (sm *ScanManager) Stop() error {
 	sm.tomb.Kill(nil)
 	if err := sm.tomb.Wait(); err != nil {
@@ -87,76 +325,301 @@ func (sm *ScanManager) Stop() error {
 	return nil
 }
 
-func (sm *ScanManager) scanNext() *time.Timer {
-	rID := sm.repoList.GetRepoForScan()
-	if rID < 0 {
-		waitTime := time.Duration(time.Minute)
-		sm.Log.Debug().Str("wait", helpers.PrettyDuration(waitTime)).Msg("no repo for scan")
-		return time.NewTimer(waitTime)
+// scanNext - start requested and due repos while there are free workers and fetch slots of their hosts,
+// returns time until the next repo is due
+func (sm *ScanManager) scanNext() time.Duration {
+	workers := sm.getConfig().Common.ScanWorkers
+	if workers < 1 {
+		workers = 1
 	}
-	sm.currentRepo = rID
-	defer func() {
-		sm.currentRepo = -1
-	}()
-	r := sm.repoList.GetRepoByIndex(rID)
-	elapsedTime := time.Since(r.Scan.EndTime)
-	if elapsedTime > sm.config.Common.ScanInterval {
-		sm.Log.Info().Str("data_path", r.Location.DataPath).Str("repo_path", r.Location.RepoPath).Msg("start scan")
-		sm.ScanRepo(rID)
-		return time.NewTimer(0)
+	waiting := []string{}
+	for _, url := range sm.requested {
+		r := sm.repoList.GetRepoByIndex(sm.repoList.FindRepo(url))
+		if r == nil {
+			continue
+		}
+		host := repoHost(*r)
+		if sm.running() >= workers || sm.isScanning(url) || !sm.hosts.acquire(host, sm.fetchLimit(host)) {
+			waiting = append(waiting, url)
+			continue
+		}
+		sm.Log.Info().Str("repo_url", url).Msg("start requested scan")
+		sm.startScan(*r, host)
 	}
-	waitTime := sm.config.Common.ScanInterval - elapsedTime
-	sm.Log.Info().Str("wait", helpers.PrettyDuration(waitTime)).Msg("wait repo for scan")
-	return time.NewTimer(waitTime)
+	sm.requested = waiting
+
+	now := sm.now()
+	waitTime := maxWait
+	for _, q := range sm.ScanQueue() {
+		if q.Paused || q.Scanning {
+			continue
+		}
+		if !q.Due {
+			if q.NextScan != nil && q.NextScan.Sub(now) < waitTime {
+				waitTime = q.NextScan.Sub(now)
+			}
+			continue
+		}
+		if sm.running() >= workers {
+			// the next scan is started when one of running scans is finished
+			break
+		}
+		r := sm.repoList.GetRepoByIndex(sm.repoList.FindRepo(q.URL))
+		if r == nil {
+			continue
+		}
+		host := repoHost(*r)
+		if !sm.hosts.acquire(host, sm.fetchLimit(host)) {
+			// the repo is started when another clone or fetch from its host is finished
+			continue
+		}
+		sm.Log.Info().Str("data_path", r.Location.DataPath).Str("repo_path", r.Location.RepoPath).Int("running", sm.running()+1).Msg("start scan")
+		sm.startScan(*r, host)
+	}
+	if waitTime < 0 {
+		waitTime = 0
+	}
+	if sm.running() == 0 {
+		// repos of discovery and schedules of reloaded config are picked up while weekly repos wait
+		sm.Log.Info().Str("wait", helpers.PrettyDuration(waitTime)).Msg("wait repo for scan")
+	}
+	return waitTime
 }
 
-// ScanRepo - open exist git repository and fing leaks
+// ScanRepo - open exist git repository and fing leaks in current goroutine
 func (sm *ScanManager) ScanRepo(index int) {
 	r := sm.repoList.GetRepoByIndex(index)
 	if r == nil {
 		panic("bad index")
 	}
+	s := sm.prepareScan(*r)
+	s.err = sm.runScan(s)
+	sm.finishScan(s)
+}
+
+// prepareScan - mark repo as being scanned
+func (sm *ScanManager) prepareScan(r hungryfox.Repo) *scan {
 	sm.Log.Debug().Str("repo_url", r.Location.URL).Int("refs", len(r.State.Refs)).Msg("state loaded")
-	r.Repo = &repo.Repo{
+	conf := sm.getConfig()
+	pastLimit, maxCommits := history(conf, r)
+	gitRepo := &repo.Repo{
 		DiffChannel:      sm.DiffChannel,
-		HistoryPastLimit: sm.config.Common.HistoryPastLimit,
+		HistoryPastLimit: pastLimit,
+		MaxCommits:       maxCommits,
 		DataPath:         r.Location.DataPath,
 		RepoPath:         r.Location.RepoPath,
 		URL:              r.Location.URL,
 		CloneURL:         r.Location.CloneURL,
 		AllowUpdate:      r.Options.AllowUpdate,
+		Fetch:            r.Options.Fetch,
+		Remote:           r.Options.Remote,
+		FetchTimeout:     r.Options.FetchTimeout,
+		Auth:             r.Options.Auth,
+		ObjectFormat:     r.Options.ObjectFormat,
+		FullScanPaths:    conf.Common.FullScanPaths,
+		FileFilter:       conf.Files.Filter,
+		WindowSize:       conf.Common.DiffWindowKB * 1024,
+		MaxDiffSize:      int64(conf.Common.MaxDiffMB) * 1024 * 1024,
+		Stop:             sm.tomb.Dying(),
 	}
+	s := &scan{prev: r, gitRepo: gitRepo}
+	if conf.Common.BlobCacheSize > 0 {
+		s.rules = sm.rulesVersion() + filesRules(conf.Files)
+		blobs := r.State.Blobs
+		if r.State.BlobsRules != s.rules {
+			// blobs were inspected by other rules, leaks of new rules can be there
+			blobs = nil
+		}
+		gitRepo.BlobCache = repo.NewBlobCache(conf.Common.BlobCacheSize, blobs)
+	}
+	r.Repo = gitRepo
 	r.Repo.SetRefs(r.State.Refs)
-	startScan := time.Now().UTC()
-	r.Scan.StartTime = startScan
-	sm.repoList.UpdateRepo(*r)
+	r.Scan.StartTime = sm.now().UTC()
+	sm.repoList.UpdateRepo(r)
+	s.repo = r
+	sm.audit(hungryfox.AuditEvent{
+		Time:     r.Scan.StartTime,
+		Action:   hungryfox.AuditScanStart,
+		Result:   hungryfox.AuditOK,
+		Repo:     r.Location.URL,
+		FromRefs: r.State.Refs,
+		Rules:    sm.rulesVersion(),
+	})
+	return s
+}
 
-	err := openScanClose(*r)
-	r.State.Refs = r.Repo.GetRefs()
+// finishScan - save refs and status of scan, repo removed from scan list while it was scanned is not saved
+func (sm *ScanManager) finishScan(s *scan) {
+	r, err := s.repo, s.err
+	inList := sm.repoList.FindRepo(r.Location.URL) >= 0
+	if err == repo.ErrInterrupted {
+		// refs and status of the last scan are kept, the scan is repeated after restart
+		sm.saveScan(s, s.prev, inList)
+		sm.audit(hungryfox.AuditEvent{
+			Time:     sm.now().UTC(),
+			Action:   hungryfox.AuditScanFinish,
+			Result:   hungryfox.AuditInterrupted,
+			Repo:     r.Location.URL,
+			FromRefs: r.State.Refs,
+			Commits:  s.gitRepo.Commits(),
+		})
+		sm.Log.Warn().Str("data_path", r.Location.DataPath).Str("repo_path", r.Location.RepoPath).Msg("scan interrupted by shutdown")
+		return
+	}
+	// refs of failed scan are not saved, otherwise not scanned commits would be skipped next time,
+	// only commits which it inspected before the failure are added
+	refs := append(append([]string{}, r.State.Refs...), s.gitRepo.PartialRefs()...)
+	blobs, blobsRules := r.State.Blobs, r.State.BlobsRules
+	if err == nil {
+		refs = s.gitRepo.GetRefs()
+		if s.gitRepo.BlobCache != nil {
+			blobs, blobsRules = s.gitRepo.BlobCache.Hashes(), s.rules
+		}
+	}
 	newR := hungryfox.Repo{
 		Location: r.Location,
 		Options:  r.Options,
-		State:    hungryfox.RepoState{Refs: r.Repo.GetRefs()},
+		State:    hungryfox.RepoState{Refs: refs, Blobs: blobs, BlobsRules: blobsRules},
 		Scan: hungryfox.ScanStatus{
-			StartTime: startScan,
-			EndTime:   time.Now().UTC(),
+			StartTime: r.Scan.StartTime,
+			EndTime:   sm.now().UTC(),
 			Success:   err == nil,
 		},
 	}
-	sm.repoList.UpdateRepo(newR)
-
 	if err != nil {
+		newR.Scan.Error = err.Error()
+	}
+	if _, ok := err.(*repo.UnsupportedError); ok {
+		newR.Scan.Unhealthy = true
+	}
+	sm.saveScan(s, newR, inList)
+	if sm.Observer != nil {
+		sm.Observer.ScanFinished(newR, s.gitRepo.Commits())
+	}
+	finished := hungryfox.AuditEvent{
+		Time:     newR.Scan.EndTime,
+		Action:   hungryfox.AuditScanFinish,
+		Result:   hungryfox.AuditOK,
+		Error:    newR.Scan.Error,
+		Repo:     newR.Location.URL,
+		FromRefs: r.State.Refs,
+		Commits:  s.gitRepo.Commits(),
+	}
+	if err == nil {
+		finished.ToRefs = refs
+	} else {
+		finished.Result = hungryfox.AuditFailed
+	}
+	sm.audit(finished)
+	if sm.rescanAfter[newR.Location.URL] {
+		delete(sm.rescanAfter, newR.Location.URL)
+		sm.rescanRequested(newR.Location.URL)
+	}
+
+	if s.gitRepo.Truncated > 0 {
+		sm.Log.Warn().Str("repo_url", newR.Location.URL).Int("diffs", s.gitRepo.Truncated).Int("max_diff_mb", sm.getConfig().Common.MaxDiffMB).Msg("diffs bigger than max_diff_mb were inspected partially")
+	}
+	if s.gitRepo.SkippedBlobs > 0 {
+		sm.Log.Debug().Str("repo_url", newR.Location.URL).Int("blobs", s.gitRepo.SkippedBlobs).Msg("blobs inspected before were skipped")
+	}
+	if s.gitRepo.FallbackReason != "" {
+		sm.Log.Warn().Str("repo_url", newR.Location.URL).Str("reason", s.gitRepo.FallbackReason).Msg("go-git can't read repo, external git is used")
+	}
+	if s.gitRepo.FetchError != "" {
+		sm.Log.Warn().Str("repo_url", newR.Location.URL).Str("error", s.gitRepo.FetchError).Msg("can't fetch local repo, stale clone is scanned")
+	}
+	if newR.Scan.Unhealthy {
+		sm.Log.Error().Str("data_path", newR.Location.DataPath).Str("repo_path", newR.Location.RepoPath).Str("error", err.Error()).Msg("repo is unhealthy")
+	} else if err != nil {
 		sm.Log.Error().Str("data_path", newR.Location.DataPath).Str("repo_path", newR.Location.RepoPath).Str("error", err.Error()).Msg("scan failed")
 	} else {
-		sm.Log.Info().Str("data_path", newR.Location.DataPath).Str("repo_path", newR.Location.RepoPath).Str("duration", helpers.PrettyDuration(time.Since(newR.Scan.StartTime))).Msg("scan completed")
+		sm.Log.Info().Str("data_path", newR.Location.DataPath).Str("repo_path", newR.Location.RepoPath).Str("duration", helpers.PrettyDuration(newR.Scan.EndTime.Sub(newR.Scan.StartTime))).Msg("scan completed")
 	}
-	return
 }
 
-func openScanClose(r hungryfox.Repo) error {
-	if err := r.Repo.Open(); err != nil {
-		return err
+func (sm *ScanManager) audit(event hungryfox.AuditEvent) {
+	if sm.Audit != nil {
+		sm.Audit.Record(event)
 	}
-	defer r.Repo.Close()
-	return r.Repo.Scan()
+}
+
+// filesRules - rules of files filter for version of blob cache, files which were skipped by other rules weren't inspected
+func filesRules(files *config.Files) string {
+	if files == nil || (len(files.Include) == 0 && len(files.Exclude) == 0) {
+		return ""
+	}
+	return fmt.Sprintf(" files:%q:%q", files.Include, files.Exclude)
+}
+
+func (sm *ScanManager) rulesVersion() string {
+	if sm.RulesVersion == nil {
+		return ""
+	}
+	return sm.RulesVersion()
+}
+
+// saveScan - remove finished scan and save state of its repo at once, so scan queue never shows the repo
+// as not scanned with its state before the scan
+func (sm *ScanManager) saveScan(s *scan, r hungryfox.Repo, inList bool) {
+	sm.queueMutex.Lock()
+	defer sm.queueMutex.Unlock()
+	sm.scansMutex.Lock()
+	delete(sm.scans, s.repo.Location.URL)
+	sm.scansMutex.Unlock()
+	if inList {
+		sm.repoList.UpdateRepo(r)
+	}
+}
+
+// ScanQueue - repos in order of scan with repos which are being scanned, both are taken at once
+func (sm *ScanManager) ScanQueue() []hungryfox.QueuedRepo {
+	if sm.repoList == nil {
+		return []hungryfox.QueuedRepo{}
+	}
+	counts := sm.leakCounts()
+	sm.queueMutex.Lock()
+	defer sm.queueMutex.Unlock()
+	queue := sm.repoList.ScanQueue(sm.now(), sm.getConfig().Common.ScanInterval, counts)
+	sm.scansMutex.Lock()
+	defer sm.scansMutex.Unlock()
+	for i := range queue {
+		_, queue[i].Scanning = sm.scans[queue[i].URL]
+	}
+	return queue
+}
+
+// leakCountsTTL - found leaks are counted again after it, scan priority doesn't need the latest leaks
+const leakCountsTTL = time.Minute
+
+// leakCounts - number of found leaks of every repo url, the previous counts are used if leaks can't be read
+func (sm *ScanManager) leakCounts() map[string]int {
+	if sm.Leaks == nil {
+		return map[string]int{}
+	}
+	sm.countsMutex.Lock()
+	defer sm.countsMutex.Unlock()
+	now := sm.now()
+	if sm.counts != nil && now.Sub(sm.countsAt) < leakCountsTTL {
+		return sm.counts
+	}
+	sm.countsAt = now
+	leaks, err := sm.Leaks.GetLeaks()
+	if err != nil {
+		sm.Log.Warn().Str("error", err.Error()).Str("service", "scan manager").Msg("can't read leaks for scan priority")
+		if sm.counts == nil {
+			sm.counts = map[string]int{}
+		}
+		return sm.counts
+	}
+	counts := map[string]int{}
+	for _, leak := range leaks {
+		// purged leaks count too, they were found in repo
+		counts[leak.RepoURL]++
+	}
+	sm.counts = counts
+	return counts
+}
+
+func (sm *ScanManager) now() time.Time {
+	return clock.Or(sm.Clock).Now()
 }
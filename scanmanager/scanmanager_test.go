@@ -1 +1,194 @@
 package scanmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/repolist"
+
+	"github.com/rs/zerolog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNextScan(t *testing.T) {
+	conf, err := config.ParseConfig([]byte(`
+common:
+  scan_interval: 1h
+schedules:
+  - repos: [backend/hot-*]
+    interval: 10m
+  - repos: [archive/**]
+    cron: "0 3 * * 6"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastScan := time.Date(2018, 7, 1, 10, 30, 0, 0, time.Local)
+	repo := func(path string) hungryfox.Repo {
+		return hungryfox.Repo{
+			Location: hungryfox.RepoLocation{URL: "https://github.com/" + path, RepoPath: path},
+			Scan:     hungryfox.ScanStatus{EndTime: lastScan},
+		}
+	}
+
+	Convey("the first matching schedule is used", t, func() {
+		So(nextScan(conf, repo("backend/hot-api")), ShouldResemble, lastScan.Add(10*time.Minute))
+		So(nextScan(conf, repo("archive/old/tool")), ShouldResemble, time.Date(2018, 7, 7, 3, 0, 0, 0, time.Local))
+		So(nextScan(conf, repo("backend/api")), ShouldResemble, lastScan.Add(time.Hour))
+	})
+
+	Convey("jitter is stable and within scan_jitter", t, func() {
+		conf.Common.ScanJitter = 5 * time.Minute
+		defer func() { conf.Common.ScanJitter = 0 }()
+		a, b := nextScan(conf, repo("backend/api")), nextScan(conf, repo("backend/web"))
+		So(a, ShouldResemble, nextScan(conf, repo("backend/api")))
+		So(a, ShouldNotResemble, b)
+		for _, next := range []time.Time{a, b} {
+			So(next.Sub(lastScan.Add(time.Hour)), ShouldBeBetweenOrEqual, 0, 5*time.Minute)
+		}
+	})
+}
+
+func TestHistory(t *testing.T) {
+	conf, err := config.ParseConfig([]byte(`
+common:
+  history_limit: 1y
+  fake_clock: 2018-07-01T00:00:00Z
+  max_commits: 1000
+history:
+  - repos: [legacy/**]
+    history_limit: 10y
+  - repos: [tools/*]
+    max_commits: 50
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	repo := func(path string) hungryfox.Repo {
+		return hungryfox.Repo{Location: hungryfox.RepoLocation{URL: "https://github.com/" + path, RepoPath: path}}
+	}
+
+	Convey("the first matching history overrides common limits", t, func() {
+		pastLimit, maxCommits := history(conf, repo("legacy/billing/core"))
+		So(pastLimit, ShouldResemble, now.Add(-10*365*24*time.Hour))
+		So(maxCommits, ShouldEqual, 1000)
+
+		pastLimit, maxCommits = history(conf, repo("tools/deploy"))
+		So(pastLimit, ShouldResemble, now.Add(-365*24*time.Hour))
+		So(maxCommits, ShouldEqual, 50)
+
+		pastLimit, maxCommits = history(conf, repo("backend/api"))
+		So(pastLimit, ShouldResemble, conf.Common.HistoryPastLimit)
+		So(maxCommits, ShouldEqual, 1000)
+	})
+
+	Convey("history without repos is rejected", t, func() {
+		_, err := config.ParseConfig([]byte("history:\n  - max_commits: 10\n"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+type removedStateManager struct {
+	fakeStateManager
+	deleted []string
+}
+
+func (m *removedStateManager) List() []hungryfox.Repo {
+	return []hungryfox.Repo{{Location: hungryfox.RepoLocation{URL: "https://github.com/backend/api"}}}
+}
+
+func (m *removedStateManager) Delete(url string) {
+	m.deleted = append(m.deleted, url)
+}
+
+func TestRemovedRepos(t *testing.T) {
+	Convey("state of repos is kept after failed discovery", t, func() {
+		state := &removedStateManager{}
+		conf := &config.Config{
+			Common:  &config.Common{RemovedRepos: &config.RemovedRepos{}},
+			Inspect: []config.Inspect{{Type: "path", Paths: []string{"/nonexistent/["}}},
+		}
+		sm := &ScanManager{StateManager: state, Log: zerolog.Nop(), config: conf}
+		sm.updateScanList()
+		So(state.deleted, ShouldBeEmpty)
+
+		conf.Inspect[0].Paths = []string{"/nonexistent/*"}
+		sm.updateScanList()
+		So(state.deleted, ShouldResemble, []string{"https://github.com/backend/api"})
+	})
+}
+
+// memStateManager - state of repos in memory
+type memStateManager struct {
+	repos map[string]hungryfox.Repo
+}
+
+func (m *memStateManager) Load(url string) (hungryfox.RepoState, hungryfox.ScanStatus) {
+	return m.repos[url].State, m.repos[url].Scan
+}
+
+func (m *memStateManager) Save(r hungryfox.Repo) {
+	m.repos[r.Location.URL] = r
+}
+
+func (m *memStateManager) List() []hungryfox.Repo {
+	result := []hungryfox.Repo{}
+	for _, r := range m.repos {
+		result = append(result, r)
+	}
+	return result
+}
+
+func (m *memStateManager) Delete(url string) {
+	delete(m.repos, url)
+}
+
+func TestReaddedRepo(t *testing.T) {
+	Convey("repo removed again after it came back gets a new grace period", t, func() {
+		dir, err := ioutil.TempDir("", "hungryfox-removed")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		mirror := filepath.Join(dir, "backend", "api")
+		So(os.MkdirAll(mirror, 0755), ShouldBeNil)
+		repo := hungryfox.Repo{
+			Location: hungryfox.RepoLocation{URL: "https://github.com/backend/api", DataPath: dir, RepoPath: "backend/api"},
+			Options:  hungryfox.RepoOptions{AllowUpdate: true},
+		}
+		state := &memStateManager{repos: map[string]hungryfox.Repo{repo.Location.URL: repo}}
+		clk := clock.NewFake(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 0)
+		sm := &ScanManager{
+			StateManager: state,
+			Clock:        clk,
+			Log:          zerolog.Nop(),
+			config:       &config.Config{Common: &config.Common{RemovedRepos: &config.RemovedRepos{DeleteMirrorAfter: time.Hour}}},
+			repoList:     &repolist.RepoList{State: state},
+		}
+
+		sm.handleRemovedRepos()
+		So(state.repos[repo.Location.URL].State.RemovedAt, ShouldResemble, clk.Now())
+
+		clk.Advance(30 * time.Minute)
+		sm.repoList.AddRepo(repo)
+		So(state.repos[repo.Location.URL].State.RemovedAt.IsZero(), ShouldBeTrue)
+
+		clk.Advance(2 * time.Hour)
+		sm.repoList.RemoveRepo(repo.Location.URL)
+		sm.handleRemovedRepos()
+		So(state.repos[repo.Location.URL].State.RemovedAt, ShouldResemble, clk.Now())
+		_, err = os.Stat(mirror)
+		So(err, ShouldBeNil)
+
+		clk.Advance(2 * time.Hour)
+		sm.handleRemovedRepos()
+		_, err = os.Stat(mirror)
+		So(os.IsNotExist(err), ShouldBeTrue)
+		So(state.repos, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,60 @@
+package scanmanager
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/helpers"
+)
+
+// schedule - the first schedule of config matching repo, nil if scan_interval is used
+func schedule(conf *config.Config, r hungryfox.Repo) *config.Schedule {
+	for i := range conf.Schedules {
+		if helpers.MatchRepo(conf.Schedules[i].Repos, r.Location.RepoPath, r.Location.URL) {
+			return &conf.Schedules[i]
+		}
+	}
+	return nil
+}
+
+// jitter - stable offset of repo within scan_jitter, so scans of repos with the same schedule are spread
+// and don't fetch from the same server at once
+func jitter(url string, maxJitter time.Duration) time.Duration {
+	if maxJitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(url))
+	return time.Duration(h.Sum64() % uint64(maxJitter))
+}
+
+// nextScan - when repo is due again after its last scan by its schedule with jitter
+func nextScan(conf *config.Config, r hungryfox.Repo) time.Time {
+	lastScan := r.Scan.EndTime
+	next := lastScan.Add(conf.Common.ScanInterval)
+	if s := schedule(conf, r); s != nil {
+		if s.CronSchedule != nil {
+			next = s.CronSchedule.Next(lastScan.Local())
+		} else {
+			next = lastScan.Add(s.Interval)
+		}
+	}
+	return next.Add(jitter(r.Location.URL, conf.Common.ScanJitter))
+}
+
+// history - history limit and max commits of repo by the first matching history of config
+func history(conf *config.Config, r hungryfox.Repo) (time.Time, int) {
+	for _, h := range conf.Histories {
+		if !helpers.MatchRepo(h.Repos, r.Location.RepoPath, r.Location.URL) {
+			continue
+		}
+		maxCommits := h.MaxCommits
+		if maxCommits == 0 {
+			maxCommits = conf.Common.MaxCommits
+		}
+		return h.HistoryPastLimit, maxCommits
+	}
+	return conf.Common.HistoryPastLimit, conf.Common.MaxCommits
+}
@@ -0,0 +1,137 @@
+package scanmanager
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/hercules"
+)
+
+// scan - scan of repo which is running in worker
+type scan struct {
+	prev    hungryfox.Repo // repo before scan, it is restored if scan is interrupted
+	repo    hungryfox.Repo
+	gitRepo *repo.Repo
+	host    string // host holding fetch slot until repo is opened, empty if repo is not fetched
+	rules   string // version of rules which blob cache is saved for
+	err     error
+}
+
+// hostSlots - clones and fetches running on every host
+type hostSlots struct {
+	mutex sync.Mutex
+	busy  map[string]int
+}
+
+// acquire - take fetch slot of host if it has a free one, repos without host are not limited
+func (h *hostSlots) acquire(host string, limit int) bool {
+	if host == "" {
+		return true
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.busy == nil {
+		h.busy = map[string]int{}
+	}
+	if limit > 0 && h.busy[host] >= limit {
+		return false
+	}
+	h.busy[host]++
+	return true
+}
+
+func (h *hostSlots) release(host string) {
+	if host == "" {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.busy[host]--; h.busy[host] <= 0 {
+		delete(h.busy, host)
+	}
+}
+
+// repoHost - host which repo is cloned and fetched from, empty for local repos
+func repoHost(r hungryfox.Repo) string {
+	if !r.Options.AllowUpdate && !r.Options.Fetch || r.Location.CloneURL == "" {
+		return ""
+	}
+	cloneURL := r.Location.CloneURL
+	if u, err := url.Parse(cloneURL); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	// scp-like ssh url git@host:path
+	if i := strings.Index(cloneURL, ":"); i > 0 {
+		host := cloneURL[:i]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+		return strings.ToLower(host)
+	}
+	return ""
+}
+
+// fetchLimit - clones and fetches from host at once by fetch_limits or fetch_per_host, unlimited if zero
+func (sm *ScanManager) fetchLimit(host string) int {
+	conf := sm.getConfig()
+	if limit, ok := conf.Common.FetchLimits[host]; ok {
+		return limit
+	}
+	return conf.Common.FetchPerHost
+}
+
+// startScan - scan repo in worker, result is handled by finishScan in scan loop
+func (sm *ScanManager) startScan(r hungryfox.Repo, host string) {
+	s := sm.prepareScan(r)
+	s.host = host
+	sm.scansMutex.Lock()
+	sm.scans[r.Location.URL] = s
+	sm.scansMutex.Unlock()
+	sm.tomb.Go(func() error {
+		s.err = sm.runScan(s)
+		sm.done <- s
+		return nil
+	})
+}
+
+// runScan - open repo with fetch slot of its host, then scan it
+func (sm *ScanManager) runScan(s *scan) error {
+	err := s.gitRepo.Open()
+	sm.hosts.release(s.host)
+	select {
+	case sm.wake <- struct{}{}:
+	default:
+	}
+	if err != nil {
+		return err
+	}
+	defer s.gitRepo.Close()
+	return s.gitRepo.Scan()
+}
+
+// running - number of scans in workers
+func (sm *ScanManager) running() int {
+	sm.scansMutex.Lock()
+	defer sm.scansMutex.Unlock()
+	return len(sm.scans)
+}
+
+func (sm *ScanManager) isScanning(url string) bool {
+	sm.scansMutex.Lock()
+	defer sm.scansMutex.Unlock()
+	_, ok := sm.scans[url]
+	return ok
+}
+
+// Scans - repos which are being scanned
+func (sm *ScanManager) Scans() []hungryfox.Repo {
+	sm.scansMutex.Lock()
+	defer sm.scansMutex.Unlock()
+	result := make([]hungryfox.Repo, 0, len(sm.scans))
+	for _, s := range sm.scans {
+		result = append(result, s.repo)
+	}
+	return result
+}
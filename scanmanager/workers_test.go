@@ -0,0 +1,145 @@
+package scanmanager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+	"github.com/AlexAkulov/hungryfox/config"
+
+	"github.com/rs/zerolog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRepoHost(t *testing.T) {
+	remote := func(cloneURL string) hungryfox.Repo {
+		return hungryfox.Repo{
+			Location: hungryfox.RepoLocation{CloneURL: cloneURL},
+			Options:  hungryfox.RepoOptions{AllowUpdate: true},
+		}
+	}
+	Convey("host of clone url", t, func() {
+		So(repoHost(remote("https://GitHub.com/org/repo.git")), ShouldEqual, "github.com")
+		So(repoHost(remote("ssh://git@gitlab.example.com:2222/group/repo.git")), ShouldEqual, "gitlab.example.com")
+		So(repoHost(remote("git@bitbucket.org:team/repo.git")), ShouldEqual, "bitbucket.org")
+	})
+	Convey("local repos are not limited", t, func() {
+		So(repoHost(hungryfox.Repo{Location: hungryfox.RepoLocation{CloneURL: "https://github.com/org/repo.git"}}), ShouldEqual, "")
+		So(repoHost(remote("")), ShouldEqual, "")
+	})
+	Convey("fetched local repos are limited by host of remote", t, func() {
+		r := hungryfox.Repo{Location: hungryfox.RepoLocation{CloneURL: "https://github.com/org/repo.git"}, Options: hungryfox.RepoOptions{Fetch: true}}
+		So(repoHost(r), ShouldEqual, "github.com")
+	})
+}
+
+func TestHostSlots(t *testing.T) {
+	Convey("fetches from host are limited", t, func() {
+		h := hostSlots{}
+		So(h.acquire("github.com", 2), ShouldBeTrue)
+		So(h.acquire("github.com", 2), ShouldBeTrue)
+		So(h.acquire("github.com", 2), ShouldBeFalse)
+		So(h.acquire("gitlab.com", 2), ShouldBeTrue)
+		h.release("github.com")
+		So(h.acquire("github.com", 2), ShouldBeTrue)
+	})
+	Convey("zero limit and empty host are unlimited", t, func() {
+		h := hostSlots{}
+		for i := 0; i < 10; i++ {
+			So(h.acquire("github.com", 0), ShouldBeTrue)
+			So(h.acquire("", 1), ShouldBeTrue)
+		}
+	})
+	Convey("fetch_limits overrides fetch_per_host", t, func() {
+		conf, _ := config.ParseConfig(nil)
+		conf.Common.FetchPerHost = 2
+		conf.Common.FetchLimits = map[string]int{"github.com": 8}
+		sm := &ScanManager{config: conf}
+		So(sm.fetchLimit("github.com"), ShouldEqual, 8)
+		So(sm.fetchLimit("gitlab.com"), ShouldEqual, 2)
+	})
+}
+
+func TestScanWorkers(t *testing.T) {
+	root, err := ioutil.TempDir("", "hungryfox-workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	paths := []string{}
+	for _, name := range []string{"api.git", "web.git", "db.git"} {
+		os.MkdirAll(filepath.Join(root, name, "objects"), 0755)
+		ioutil.WriteFile(filepath.Join(root, name, "HEAD"), []byte("ref: refs/heads/master\n"), 0644)
+		paths = append(paths, filepath.Join(root, name))
+	}
+	conf, _ := config.ParseConfig(nil)
+	conf.Common.ScanWorkers = 2
+	conf.Inspect = []config.Inspect{{Type: "path", URL: "https://git.example.com", TrimPrefix: root, Paths: paths}}
+
+	Convey("no more than scan_workers repos are scanned at once", t, func() {
+		sm := &ScanManager{StateManager: fakeStateManager{}, Log: zerolog.Nop(), config: conf, done: make(chan *scan), scans: map[string]*scan{}}
+		sm.updateScanList()
+		sm.scanNext()
+		So(sm.running(), ShouldEqual, 2)
+		So(sm.Scans(), ShouldHaveLength, 2)
+		So(sm.Status(), ShouldNotBeNil)
+		scanning := 0
+		for _, q := range sm.ScanQueue() {
+			if q.Scanning {
+				scanning++
+			}
+		}
+		So(scanning, ShouldEqual, 2)
+
+		sm.finishScan(<-sm.done)
+		sm.scanNext()
+		So(sm.running(), ShouldEqual, 2)
+
+		sm.finishScan(<-sm.done)
+		sm.finishScan(<-sm.done)
+		So(sm.running(), ShouldEqual, 0)
+		So(sm.Status(), ShouldBeNil)
+		for _, q := range sm.ScanQueue() {
+			So(q.LastScan, ShouldNotBeNil)
+			So(q.Scanning, ShouldBeFalse)
+		}
+	})
+}
+
+type countingLeakStore struct {
+	leaks []hungryfox.Leak
+	reads int
+	err   error
+}
+
+func (s *countingLeakStore) GetLeaks() ([]hungryfox.Leak, error) {
+	s.reads++
+	return s.leaks, s.err
+}
+
+func TestLeakCounts(t *testing.T) {
+	Convey("leaks are counted again only after ttl", t, func() {
+		c := clock.NewFake(time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC), 0)
+		store := &countingLeakStore{leaks: []hungryfox.Leak{{RepoURL: "a"}, {RepoURL: "a"}, {RepoURL: "b"}}}
+		sm := &ScanManager{Log: zerolog.Nop(), Leaks: store, Clock: c}
+		So(sm.leakCounts(), ShouldResemble, map[string]int{"a": 2, "b": 1})
+		store.leaks = append(store.leaks, hungryfox.Leak{RepoURL: "b"})
+		So(sm.leakCounts()["b"], ShouldEqual, 1)
+		So(store.reads, ShouldEqual, 1)
+
+		c.Advance(leakCountsTTL)
+		So(sm.leakCounts()["b"], ShouldEqual, 2)
+		So(store.reads, ShouldEqual, 2)
+
+		Convey("previous counts are kept if leaks can't be read", func() {
+			store.err = fmt.Errorf("broken")
+			c.Advance(leakCountsTTL)
+			So(sm.leakCounts()["b"], ShouldEqual, 2)
+		})
+	})
+}
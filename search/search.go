@@ -0,0 +1,106 @@
+// Package search - full text search over metadata and context of leaks,
+// secrets extracted by patterns are masked before indexing and can't be found
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// Query - leak matches if it contains all terms, term is a word or a quoted phrase,
+// the last word of term matches by prefix
+type Query struct {
+	terms [][]string
+}
+
+// Parse - parse query like `service-foo "db password"`
+func Parse(s string) Query {
+	q := Query{}
+	parts := strings.Split(s, `"`)
+	for i, part := range parts {
+		if i%2 == 1 {
+			// quoted phrase
+			if tokens := Tokenize(part); len(tokens) > 0 {
+				q.terms = append(q.terms, tokens)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if tokens := Tokenize(word); len(tokens) > 0 {
+				q.terms = append(q.terms, tokens)
+			}
+		}
+	}
+	return q
+}
+
+// Empty - query has no terms and matches all leaks
+func (q Query) Empty() bool {
+	return len(q.terms) == 0
+}
+
+// Match - leak contains all terms of query
+func (q Query) Match(leak hungryfox.Leak) bool {
+	if q.Empty() {
+		return true
+	}
+	return q.MatchTokens(Tokenize(Text(leak)))
+}
+
+// MatchTokens - tokens of text contain all terms of query
+func (q Query) MatchTokens(tokens []string) bool {
+	for _, term := range q.terms {
+		if !containsTerm(tokens, term) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsTerm(tokens, term []string) bool {
+	for i := 0; i+len(term) <= len(tokens); i++ {
+		found := true
+		for j, word := range term {
+			if j == len(term)-1 {
+				found = strings.HasPrefix(tokens[i+j], word)
+			} else {
+				found = tokens[i+j] == word
+			}
+			if !found {
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// Text - searchable text of leak, secret is masked in context
+func Text(leak hungryfox.Leak) string {
+	return strings.Join([]string{
+		leak.PatternName,
+		leak.RepoURL,
+		leak.RepoPath,
+		leak.FilePath,
+		leak.CommitHash,
+		leak.CommitAuthor,
+		leak.CommitEmail,
+		leak.Severity,
+		leak.Language,
+		leak.Source,
+		leak.Kind,
+		leak.Redacted(),
+		leak.RedactedContext(),
+	}, "\n")
+}
+
+// Tokenize - lower case words of letters and digits
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
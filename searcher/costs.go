@@ -0,0 +1,98 @@
+package searcher
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// Families of rules without family in config
+const (
+	builtinFamily = "builtin"
+	entropyFamily = "entropy"
+)
+
+// usage - time spent and leaks found by family of rules
+type usage struct {
+	spent   time.Duration
+	matches int
+}
+
+type usages map[string]*usage
+
+func (u usages) add(family string, spent time.Duration, matches int) {
+	if u == nil {
+		return
+	}
+	if u[family] == nil {
+		u[family] = &usage{}
+	}
+	u[family].spent += spent
+	u[family].matches += matches
+}
+
+type costKey struct {
+	family    string
+	repoGroup string
+}
+
+// repoGroup - owner of repo (GitHub organization or user, GitLab group) from repo url or path
+func repoGroup(diff hungryfox.Diff) string {
+	path := ""
+	if u, err := url.Parse(diff.RepoURL); err == nil && u.Host != "" {
+		path = u.Path
+	} else if i := strings.Index(diff.RepoURL, ":"); i > 0 && !strings.Contains(diff.RepoURL, "://") {
+		// git@github.com:backend/api.git
+		path = diff.RepoURL[i+1:]
+	}
+	for _, p := range []string{path, diff.RepoPath} {
+		p = strings.Trim(p, "/")
+		if i := strings.Index(p, "/"); i > 0 {
+			return p[:i]
+		}
+	}
+	return "unknown"
+}
+
+// addCosts - usages of rules in diff of repo group
+func (s *Searcher) addCosts(group string, u usages) {
+	s.costsMutex.Lock()
+	defer s.costsMutex.Unlock()
+	if s.costs == nil {
+		s.costs = map[costKey]*hungryfox.RuleCost{}
+	}
+	for family, familyUsage := range u {
+		key := costKey{family: family, repoGroup: group}
+		cost := s.costs[key]
+		if cost == nil {
+			cost = &hungryfox.RuleCost{Family: family, RepoGroup: group}
+			s.costs[key] = cost
+		}
+		cost.Seconds += familyUsage.spent.Seconds()
+		cost.Diffs++
+		cost.Matches += familyUsage.matches
+	}
+}
+
+// RuleCosts - time spent by families of rules on repo groups since start, the most expensive first
+func (s *Searcher) RuleCosts() []hungryfox.RuleCost {
+	s.costsMutex.Lock()
+	defer s.costsMutex.Unlock()
+	result := make([]hungryfox.RuleCost, 0, len(s.costs))
+	for _, cost := range s.costs {
+		result = append(result, *cost)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Seconds != result[j].Seconds {
+			return result[i].Seconds > result[j].Seconds
+		}
+		if result[i].Family != result[j].Family {
+			return result[i].Family < result[j].Family
+		}
+		return result[i].RepoGroup < result[j].RepoGroup
+	})
+	return result
+}
@@ -0,0 +1,57 @@
+package searcher
+
+import (
+	"fmt"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+)
+
+// builtinPatterns - curated patterns enabled by default, names are used in default_patterns.disable.
+// Keywords must be literal parts of content regexp, otherwise leaks are missed. Severity is critical for keys
+// which give access to cloud, code or money, high for api keys and medium for short-lived or low-impact tokens
+var builtinPatterns = []config.Pattern{
+	{Name: "AWS Access Key ID", Severity: hungryfox.SeverityHigh, Content: `\b(AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA)[0-9A-Z]{16}\b`, Keywords: []string{"akia", "asia", "agpa", "aida", "aroa", "anpa", "anva"}},
+	{Name: "AWS Secret Access Key", Severity: hungryfox.SeverityCritical, Content: `(?i)aws.{0,20}(secret|private).{0,20}['"=:\s][0-9a-zA-Z/+]{40}\b`, Keywords: []string{"aws"}},
+	{Name: "GCP Service Account", Severity: hungryfox.SeverityCritical, Content: `"type"\s*:\s*"service_account"`, Keywords: []string{"service_account"}},
+	{Name: "Google API Key", Severity: hungryfox.SeverityHigh, Content: `\bAIza[0-9A-Za-z_\-]{35}\b`, Keywords: []string{"aiza"}},
+	{Name: "Google OAuth Access Token", Severity: hungryfox.SeverityMedium, Content: `\bya29\.[0-9A-Za-z_\-]{20,}`, Keywords: []string{"ya29."}},
+	{Name: "Azure Storage Account Key", Severity: hungryfox.SeverityCritical, Content: `AccountKey=[A-Za-z0-9+/]{86}==`, Keywords: []string{"accountkey="}},
+	{Name: "Slack Token", Severity: hungryfox.SeverityHigh, Content: `\bxox[abposr]-[0-9A-Za-z-]{10,}`, Keywords: []string{"xox"}},
+	{Name: "Slack Webhook", Severity: hungryfox.SeverityMedium, Content: `https://hooks\.slack\.com/services/T[A-Z0-9]+/B[A-Z0-9]+/[A-Za-z0-9]+`, Keywords: []string{"hooks.slack.com"}},
+	{Name: "GitHub Token", Severity: hungryfox.SeverityCritical, Content: `\b(gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`, Keywords: []string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_"}},
+	{Name: "GitLab Token", Severity: hungryfox.SeverityCritical, Content: `\bglpat-[0-9A-Za-z_\-]{20}`, Keywords: []string{"glpat-"}},
+	{Name: "Stripe Live Key", Severity: hungryfox.SeverityCritical, Content: `\b[rs]k_live_[0-9a-zA-Z]{24,}`, Keywords: []string{"_live_"}},
+	{Name: "SendGrid API Key", Severity: hungryfox.SeverityHigh, Content: `\bSG\.[0-9A-Za-z_\-]{22}\.[0-9A-Za-z_\-]{43}`, Keywords: []string{"sg."}},
+	{Name: "npm Token", Severity: hungryfox.SeverityHigh, Content: `\bnpm_[A-Za-z0-9]{36}\b`, Keywords: []string{"npm_"}},
+	{Name: "Private Key Block", Severity: hungryfox.SeverityCritical, Content: `-----BEGIN ((RSA|DSA|EC|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`, Keywords: []string{"private key"}},
+	{Name: "PuTTY Private Key", Severity: hungryfox.SeverityCritical, Content: `PuTTY-User-Key-File-\d`, Keywords: []string{"putty-user-key-file"}},
+	{Name: "JSON Web Token", Severity: hungryfox.SeverityMedium, Content: `\beyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`, Keywords: []string{"eyj"}},
+}
+
+// getBuiltinPatterns - enabled built-in patterns, unknown names in disable list are an error
+func getBuiltinPatterns(conf *config.DefaultPatterns) ([]config.Pattern, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	disabled := map[string]bool{}
+	for _, name := range conf.Disable {
+		disabled[name] = true
+	}
+	result := []config.Pattern{}
+	for _, p := range builtinPatterns {
+		if disabled[p.Name] {
+			delete(disabled, p.Name)
+			continue
+		}
+		p.Family = builtinFamily
+		result = append(result, p)
+	}
+	for name := range disabled {
+		return nil, fmt.Errorf("unknown default pattern '%s' in disable list", name)
+	}
+	if !conf.Enable {
+		return nil, nil
+	}
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package searcher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fake secrets are split so that this file is not a leak itself
+var builtinSamples = map[string]string{
+	"AWS Access Key ID":         `aws_access_key_id = AKIA` + `IOSFODNN7EXAMPLE`,
+	"AWS Secret Access Key":     `aws_secret_access_key = wJalrXUtnFEMI/K7MDENG/bPxRfiCY` + `EXAMPLEKEY`,
+	"GCP Service Account":       `  "type": "service_account",`,
+	"Google API Key":            `key: AIza` + `SyA1234567890abcdefghijklmnopqrstuv`,
+	"Google OAuth Access Token": `Bearer ya29` + `.a0AfH6SMBx1234567890abcdefg`,
+	"Azure Storage Account Key": `AccountKey=` + strings.Repeat("a", 86) + `==`,
+	"Slack Token":               `token: xox` + `b-123456789012-abcdefghij`,
+	"Slack Webhook":             `https://hooks.slack.com` + `/services/T00000000/B00000000/XXXXXXXXXXXXXXXXXXXXXXXX`,
+	"GitHub Token":              `GITHUB_TOKEN=gh` + `p_` + strings.Repeat("A", 36),
+	"GitLab Token":              `glpat` + `-abcdefghij0123456789`,
+	"Stripe Live Key":           `sk_live` + `_` + strings.Repeat("a", 24),
+	"SendGrid API Key":          `SG` + `.` + strings.Repeat("a", 22) + `.` + strings.Repeat("b", 43),
+	"npm Token":                 `//registry.npmjs.org/:_authToken=npm` + `_` + strings.Repeat("a", 36),
+	"Private Key Block":         `-----BEGIN RSA ` + `PRIVATE KEY-----`,
+	"PuTTY Private Key":         `PuTTY-User-Key` + `-File-2: ssh-rsa`,
+	"JSON Web Token":            `eyJhbGciOiJIUzI1NiJ9` + `.eyJzdWIiOiIxMjM0NTY3ODkwIn0.dozjgNryP4J3jVmNHl0w5N_XgL0n3I9PlFUP0THsR8U`,
+}
+
+func TestBuiltinPatterns(t *testing.T) {
+	Convey("every built-in pattern finds its sample", t, func() {
+		s := &Searcher{}
+		So(s.Configure(&config.Config{Common: &config.Common{}, DefaultPatterns: &config.DefaultPatterns{Enable: true}}), ShouldBeNil)
+		So(len(builtinSamples), ShouldEqual, len(builtinPatterns))
+		for _, p := range builtinPatterns {
+			sample, ok := builtinSamples[p.Name]
+			So(ok, ShouldBeTrue)
+			leaks := s.GetLeaks(hungryfox.Diff{FilePath: "config", Content: sample})
+			names := []string{}
+			for _, leak := range leaks {
+				names = append(names, leak.PatternName)
+			}
+			So(names, ShouldContain, p.Name)
+			So(hungryfox.SeverityLevel(p.Severity), ShouldBeGreaterThan, 0)
+			for _, leak := range leaks {
+				if leak.PatternName == p.Name {
+					So(leak.Severity, ShouldEqual, p.Severity)
+				}
+			}
+		}
+	})
+	Convey("plain code is not a leak", t, func() {
+		s := &Searcher{}
+		So(s.Configure(&config.Config{Common: &config.Common{}, DefaultPatterns: &config.DefaultPatterns{Enable: true}}), ShouldBeNil)
+		So(s.GetLeaks(hungryfox.Diff{FilePath: "main.go", Content: "func main() {\n\tpassword := os.Getenv(\"PASSWORD\")\n}"}), ShouldBeEmpty)
+	})
+	Convey("disable by name", t, func() {
+		patterns, err := getBuiltinPatterns(&config.DefaultPatterns{Enable: true, Disable: []string{"JSON Web Token"}})
+		So(err, ShouldBeNil)
+		So(len(patterns), ShouldEqual, len(builtinPatterns)-1)
+		_, err = getBuiltinPatterns(&config.DefaultPatterns{Enable: true, Disable: []string{"JWT"}})
+		So(err, ShouldNotBeNil)
+		patterns, err = getBuiltinPatterns(&config.DefaultPatterns{})
+		So(err, ShouldBeNil)
+		So(patterns, ShouldBeEmpty)
+	})
+}
+
+func TestKeywordPrefilter(t *testing.T) {
+	Convey("patterns are skipped without their keywords", t, func() {
+		patterns, err := compilePatterns([]config.Pattern{
+			{Name: "aws", Content: `AKIA[0-9A-Z]{16}`, Keywords: []string{"AKIA"}},
+			{Name: "always"},
+		})
+		So(err, ShouldBeNil)
+		active := activePatterns(patterns, "nothing here", "")
+		So(len(active), ShouldEqual, 1)
+		So(active[0].Name, ShouldEqual, "always")
+		So(len(activePatterns(patterns, "key: akia", "")), ShouldEqual, 2)
+	})
+}
+
+func BenchmarkGetLeaksBuiltin(b *testing.B) {
+	s := &Searcher{}
+	s.Configure(&config.Config{Common: &config.Common{}, DefaultPatterns: &config.DefaultPatterns{Enable: true}})
+	diff := hungryfox.Diff{FilePath: "main.go", Content: strings.Repeat("\tresult = append(result, compute(value, index))\n", 200)}
+	for i := 0; i < b.N; i++ {
+		s.GetLeaks(diff)
+	}
+}
@@ -0,0 +1,106 @@
+package searcher
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+)
+
+const (
+	charsetBase64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/=_-"
+	charsetHex    = "0123456789abcdefABCDEF"
+)
+
+// entropyDetector - finds strings of charset with Shannon entropy above threshold
+type entropyDetector struct {
+	Name      string
+	FileRe    *regexp.Regexp
+	MinLength int
+	Threshold float64
+	Severity  string
+	charset   [256]bool
+}
+
+func compileEntropy(configDetectors []config.Entropy) ([]entropyDetector, error) {
+	result := []entropyDetector{}
+	for _, c := range configDetectors {
+		charsetName, charset := c.Charset, c.Charset
+		switch charset {
+		case "", "base64":
+			charsetName, charset = "base64", charsetBase64
+		case "hex":
+			charset = charsetHex
+		}
+		d := entropyDetector{
+			Name:      c.Name,
+			FileRe:    matchAllRegex,
+			MinLength: c.MinLength,
+			Threshold: c.Threshold,
+			Severity:  hungryfox.SeverityMedium,
+		}
+		if c.Severity != "" {
+			if hungryfox.SeverityLevel(c.Severity) == 0 {
+				return nil, fmt.Errorf("unknown severity '%s' of entropy detector '%s'", c.Severity, c.Name)
+			}
+			d.Severity = c.Severity
+		}
+		if d.Name == "" {
+			d.Name = fmt.Sprintf("high entropy %s", charsetName)
+		}
+		if d.MinLength <= 0 {
+			d.MinLength = 20
+		}
+		if d.Threshold <= 0 {
+			d.Threshold = 4.5
+		}
+		for i := 0; i < len(charset); i++ {
+			d.charset[charset[i]] = true
+		}
+		if c.File != "" && c.File != "*" {
+			var err error
+			if d.FileRe, err = regexp.Compile(c.File); err != nil {
+				return nil, fmt.Errorf("can't compile entropy file regexp '%s' with: %v", c.File, err)
+			}
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+// find - first string in line with high entropy which ends after the first overlap bytes
+func (d *entropyDetector) find(line string, overlap int) (string, bool) {
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && d.charset[line[i]] {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i > overlap && i-start >= d.MinLength && shannonEntropy(line[start:i]) > d.Threshold {
+			return line[start:i], true
+		}
+		start = -1
+	}
+	return "", false
+}
+
+// shannonEntropy - bits per char
+func shannonEntropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,52 @@
+package searcher
+
+import (
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/config"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEntropy(t *testing.T) {
+	Convey("shannon entropy", t, func() {
+		So(shannonEntropy("aaaa"), ShouldEqual, 0)
+		So(shannonEntropy("abcd"), ShouldEqual, 2)
+	})
+	Convey("detectors", t, func() {
+		detectors, err := compileEntropy([]config.Entropy{
+			{Charset: "base64"},
+			{Name: "hex", Charset: "hex", MinLength: 32, Threshold: 3},
+		})
+		So(err, ShouldBeNil)
+		base64, hex := detectors[0], detectors[1]
+		found, ok := base64.find(`token = "kJ8sd7Fh2QxPz9LmN4vB6tRw"`, 0)
+		So(ok, ShouldBeTrue)
+		So(found, ShouldEqual, "kJ8sd7Fh2QxPz9LmN4vB6tRw")
+		_, ok = base64.find(`name = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"`, 0)
+		So(ok, ShouldBeFalse)
+		_, ok = base64.find(`short = "kJ8sd7Fh2Q"`, 0)
+		So(ok, ShouldBeFalse)
+		_, ok = hex.find(`sum: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08`, 0)
+		So(ok, ShouldBeTrue)
+		_, ok = hex.find(`func thisIsAVeryLongIdentifierWithoutRandomness()`, 0)
+		So(ok, ShouldBeFalse)
+	})
+	Convey("custom charset and bad file regexp", t, func() {
+		detectors, err := compileEntropy([]config.Entropy{{Charset: "01", MinLength: 8, Threshold: 0.9}})
+		So(err, ShouldBeNil)
+		_, ok := detectors[0].find("bits 0110100110010110", 0)
+		So(ok, ShouldBeTrue)
+		_, err = compileEntropy([]config.Entropy{{File: "("}})
+		So(err, ShouldNotBeNil)
+	})
+	Convey("entropy leaks are found with patterns", t, func() {
+		s := &Searcher{}
+		So(s.Configure(&config.Config{Common: &config.Common{}, Entropy: []config.Entropy{{Name: "entropy"}}}), ShouldBeNil)
+		leaks := s.GetLeaks(hungryfox.Diff{FilePath: "app.conf", Content: "x = 1\nkey = kJ8sd7Fh2QxPz9LmN4vB6tRw", LineBegin: 10})
+		So(len(leaks), ShouldEqual, 1)
+		So(leaks[0].PatternName, ShouldEqual, "entropy")
+		So(leaks[0].Line, ShouldEqual, 11)
+	})
+}
@@ -1,16 +1,28 @@
 package searcher
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	sync "github.com/sasha-s/go-deadlock"
 
 	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/baseline"
 	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/hashonly"
+	"github.com/AlexAkulov/hungryfox/helpers"
+	"github.com/AlexAkulov/hungryfox/language"
+	"github.com/AlexAkulov/hungryfox/ruleimport"
 
 	"github.com/rs/zerolog"
 	"gopkg.in/tomb.v2"
@@ -21,13 +33,21 @@ var matchAllRegex = regexp.MustCompile(".+")
 
 type patternType struct {
 	Name      string
+	Family    string // rules are grouped by family in costs, name of pattern by default
 	ContentRe *regexp.Regexp
 	FileRe    *regexp.Regexp
+	Keywords  []string
+	Severity  string
+	Languages map[string]bool
+	// SecretGroup - index of capture group of ContentRe with secret, 0 if secret is not extracted
+	SecretGroup int
 }
 
 type RepoStats struct {
 	LeaksFound   int `json:"leaks_found"`
 	LeaksFiltred int `json:"leaks_filtred"`
+	// LeaksByLanguage - found leaks per language of file, unknown languages are not counted
+	LeaksByLanguage map[string]int `json:"leaks_by_language,omitempty"`
 }
 
 type Searcher struct {
@@ -35,14 +55,34 @@ type Searcher struct {
 	DiffChannel <-chan *hungryfox.Diff
 	LeakChannel chan<- *hungryfox.Leak
 	Log         zerolog.Logger
+	// Audit - loads of rules with their version, nothing is recorded if nil
+	Audit hungryfox.IAuditLog
 
-	config           *config.Config
-	stats            map[string]RepoStats
-	statsMutex       sync.RWMutex
-	tomb             tomb.Tomb
-	patterns         []patternType
-	filters          []patternType
-	updateConfigChan chan *config.Config
+	config      *config.Config
+	configMutex sync.Mutex
+	stats       map[string]RepoStats
+	statsMutex  sync.RWMutex
+	costs       map[costKey]*hungryfox.RuleCost
+	costsMutex  sync.Mutex
+	tomb        tomb.Tomb
+	rules       atomic.Value         // *rules
+	failedFiles map[string]time.Time // files with modification time of the last failed reload
+}
+
+// rules - compiled patterns and filters, replaced as a whole on reload
+type rules struct {
+	patterns  []patternType
+	filters   []patternType
+	entropy   []entropyDetector
+	allowlist []config.Allowlist
+	escalate  []config.Escalation
+	baseline  map[string]bool      // fingerprints of known leaks
+	files     map[string]time.Time // patterns and filters files with modification time
+	hasher    *hashonly.Hasher     // content of found leaks is replaced by fingerprints in hash_only mode
+	version   string               // hash of patterns, filters and entropy detectors
+
+	contextLines  int  // lines around leak line which are kept in leak
+	redactContext bool // secret is masked in context
 }
 
 func compilePatterns(configPatterns []config.Pattern) ([]patternType, error) {
@@ -50,8 +90,19 @@ func compilePatterns(configPatterns []config.Pattern) ([]patternType, error) {
 	for _, configPattern := range configPatterns {
 		p := patternType{
 			Name:      configPattern.Name,
+			Family:    configPattern.Family,
 			FileRe:    matchAllRegex,
 			ContentRe: matchAllRegex,
+			Severity:  hungryfox.SeverityMedium,
+		}
+		if p.Family == "" {
+			p.Family = p.Name
+		}
+		if configPattern.Severity != "" {
+			if hungryfox.SeverityLevel(configPattern.Severity) == 0 {
+				return nil, fmt.Errorf("unknown severity '%s' of pattern '%s'", configPattern.Severity, configPattern.Name)
+			}
+			p.Severity = configPattern.Severity
 		}
 		if configPattern.File != "*" && configPattern.File != "" {
 			var err error
@@ -65,20 +116,83 @@ func compilePatterns(configPatterns []config.Pattern) ([]patternType, error) {
 				return nil, fmt.Errorf("can't compile pattern content regexp '%s' with: %v", configPattern.Content, err)
 			}
 		}
+		if configPattern.SecretGroup != "" {
+			var err error
+			if p.SecretGroup, err = secretGroup(p.ContentRe, configPattern.SecretGroup); err != nil {
+				return nil, fmt.Errorf("bad secret group of pattern '%s': %v", configPattern.Name, err)
+			}
+		}
+		for _, lang := range configPattern.Languages {
+			if p.Languages == nil {
+				p.Languages = map[string]bool{}
+			}
+			p.Languages[strings.ToLower(lang)] = true
+		}
+		for _, keyword := range configPattern.Keywords {
+			if keyword = strings.ToLower(keyword); keyword != "" {
+				p.Keywords = append(p.Keywords, keyword)
+			}
+		}
 		result = append(result, p)
 	}
 	return result, nil
 }
 
-func (s *Searcher) Update(conf *config.Config) {
-	s.updateConfigChan <- conf
+// secretGroup - index of capture group by its number or name
+func secretGroup(re *regexp.Regexp, group string) (int, error) {
+	if index, err := strconv.Atoi(group); err == nil {
+		if index < 1 || index > re.NumSubexp() {
+			return 0, fmt.Errorf("regexp '%s' has no group %d", re, index)
+		}
+		return index, nil
+	}
+	for i, name := range re.SubexpNames() {
+		if name == group {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("regexp '%s' has no group '%s'", re, group)
+}
+
+// activePatterns - patterns of language without keywords and patterns whose keyword is in content,
+// regexps of other patterns can't match so they are skipped
+func activePatterns(patterns []patternType, content, lang string) []patternType {
+	lowerContent := ""
+	result := make([]patternType, 0, len(patterns))
+	for _, p := range patterns {
+		if p.Languages != nil && !p.Languages[lang] {
+			continue
+		}
+		if len(p.Keywords) == 0 {
+			result = append(result, p)
+			continue
+		}
+		if lowerContent == "" {
+			lowerContent = strings.ToLower(content)
+		}
+		for _, keyword := range p.Keywords {
+			if strings.Contains(lowerContent, keyword) {
+				result = append(result, p)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Update - reload patterns and filters from new config, on error the current ones are kept
+func (s *Searcher) Update(conf *config.Config) error {
+	err := s.updateConfig(conf)
+	s.audit("config", err)
+	return err
 }
 
 func (s *Searcher) Start(conf *config.Config) error {
-	if err := s.updateConfig(conf); err != nil {
+	err := s.updateConfig(conf)
+	s.audit("start", err)
+	if err != nil {
 		return err
 	}
-	s.updateConfigChan = make(chan *config.Config, 1)
 
 	if s.Workers < 1 {
 		return fmt.Errorf("workers count can't be less 1")
@@ -88,81 +202,293 @@ func (s *Searcher) Start(conf *config.Config) error {
 	for i := 0; i < s.Workers; i++ {
 		s.tomb.Go(s.worker)
 	}
+	s.tomb.Go(s.watchFiles)
 	return nil
 }
 
-func (s *Searcher) worker() error {
+func (s *Searcher) getRules() *rules {
+	if r, ok := s.rules.Load().(*rules); ok {
+		return r
+	}
+	return &rules{}
+}
+
+func (s *Searcher) setRules(r *rules) {
+	s.rules.Store(r)
+}
+
+// watchFiles - reload patterns and filters when files of patterns_path, filters_path or baseline_file were changed
+func (s *Searcher) watchFiles() error {
 	for {
+		s.configMutex.Lock()
+		conf := s.config
+		s.configMutex.Unlock()
+		interval := conf.Common.PatternsReload
+		if interval <= 0 {
+			// reloading is disabled but can be enabled by Update
+			interval = time.Minute
+		}
 		select {
-		case newConf := <-s.updateConfigChan:
-			err := s.updateConfig(newConf)
+		case <-s.tomb.Dying():
+			return nil
+		case <-time.After(interval):
+		}
+		if conf.Common.PatternsReload > 0 && s.filesChanged(conf) {
+			s.reloadFiles(conf)
+		}
+	}
+}
+
+// reloadFiles - reload changed files, broken files are reported once and not loaded again until they are changed
+func (s *Searcher) reloadFiles(conf *config.Config) {
+	// files are stamped before loading, so a fix written during reload is not missed
+	files, _ := getFilesModTime(conf.Common.PatternsPath, conf.Common.FiltresPath, conf.Common.BaselineFile)
+	err := s.reload(conf)
+	s.audit("files", err)
+	if err != nil {
+		s.failedFiles = files
+		s.Log.Error().Str("error", err.Error()).Msg("can't reload patterns and filtres")
+		return
+	}
+	s.Log.Info().Msg("patterns reloaded")
+}
+
+// audit - record load of rules by reason, current rules are kept on error
+func (s *Searcher) audit(reason string, err error) {
+	if s.Audit == nil {
+		return
+	}
+	event := hungryfox.AuditEvent{
+		Action: hungryfox.AuditRulesReload,
+		Result: hungryfox.AuditOK,
+		Reason: reason,
+		Rules:  s.RulesVersion(),
+	}
+	if err != nil {
+		event.Result, event.Error = hungryfox.AuditFailed, err.Error()
+	}
+	s.Audit.Record(event)
+}
+
+// reload - load files again if config was not replaced by Update in the meantime
+func (s *Searcher) reload(conf *config.Config) error {
+	s.configMutex.Lock()
+	defer s.configMutex.Unlock()
+	if s.config != conf {
+		return nil
+	}
+	return s.loadRules(conf)
+}
+
+func (s *Searcher) filesChanged(conf *config.Config) bool {
+	files, err := getFilesModTime(conf.Common.PatternsPath, conf.Common.FiltresPath, conf.Common.BaselineFile)
+	if err != nil {
+		s.Log.Error().Str("error", err.Error()).Msg("can't check patterns and filtres files")
+		return false
+	}
+	return !sameFiles(files, s.getRules().files) && (s.failedFiles == nil || !sameFiles(files, s.failedFiles))
+}
+
+func sameFiles(files, other map[string]time.Time) bool {
+	if len(files) != len(other) {
+		return false
+	}
+	for file, modTime := range files {
+		if otherModTime, ok := other[file]; !ok || !otherModTime.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func getFilesModTime(paths ...string) (map[string]time.Time, error) {
+	result := map[string]time.Time{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		files, err := filepath.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			info, err := os.Stat(file)
 			if err != nil {
-				s.Log.Error().Str("error", err.Error()).Msg("can't update patterns and filtres")
+				return nil, err
 			}
+			result[file] = info.ModTime()
+		}
+	}
+	return result, nil
+}
+
+func (s *Searcher) worker() error {
+	for {
+		select {
 		case <-s.tomb.Dying():
+			s.drain()
 			return nil
 		case diff := <-s.DiffChannel:
-			leaks := s.GetLeaks(*diff)
-			filtredLeaks := 0
-			for i := range leaks {
-				if s.filterLeak(leaks[i]) {
-					filtredLeaks++
-					continue
-				}
-				s.LeakChannel <- &leaks[i]
-			}
-			leaksCount := len(leaks) - filtredLeaks
-			if leaksCount > 0 || filtredLeaks > 0 {
-				s.statsMutex.Lock()
-				repoStats, _ := s.stats[diff.RepoURL]
-				repoStats.LeaksFiltred += filtredLeaks
-				repoStats.LeaksFound += leaksCount
-				s.stats[diff.RepoURL] = repoStats
-				s.statsMutex.Unlock()
-			}
+			s.inspectDiff(diff)
+		}
+	}
+}
+
+// drain - inspect diffs left in DiffChannel on stop, so found leaks are not lost
+func (s *Searcher) drain() {
+	for {
+		select {
+		case diff := <-s.DiffChannel:
+			s.inspectDiff(diff)
+		default:
+			return
 		}
 	}
 }
 
+func (s *Searcher) inspectDiff(diff *hungryfox.Diff) {
+	leaks, filtredLeaks := s.Inspect(*diff)
+	for i := range leaks {
+		s.LeakChannel <- &leaks[i]
+	}
+	leaksCount := len(leaks)
+	if leaksCount == 0 && filtredLeaks == 0 {
+		return
+	}
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	repoStats, _ := s.stats[diff.RepoURL]
+	repoStats.LeaksFiltred += filtredLeaks
+	repoStats.LeaksFound += leaksCount
+	for _, leak := range leaks {
+		if leak.Language == "" {
+			continue
+		}
+		if repoStats.LeaksByLanguage == nil {
+			repoStats.LeaksByLanguage = map[string]int{}
+		}
+		repoStats.LeaksByLanguage[leak.Language]++
+	}
+	s.stats[diff.RepoURL] = repoStats
+}
+
+// Inspect - find leaks in diff, returns not filtered leaks and count of filtered
+func (s *Searcher) Inspect(diff hungryfox.Diff) ([]hungryfox.Leak, int) {
+	r := s.getRules()
+	spent := usages{}
+	leaks := r.findLeaks(diff, spent)
+	s.addCosts(repoGroup(diff), spent)
+	result := make([]hungryfox.Leak, 0, len(leaks))
+	for i := range leaks {
+		if r.filterLeak(leaks[i]) {
+			continue
+		}
+		r.escalateLeak(&leaks[i])
+		if r.hasher != nil {
+			leaks[i] = r.hasher.Hash(leaks[i])
+		}
+		result = append(result, leaks[i])
+	}
+	return result, len(leaks) - len(result)
+}
+
+// Configure - load patterns and filters without starting workers
+func (s *Searcher) Configure(conf *config.Config) error {
+	return s.updateConfig(conf)
+}
+
+// Stop - stop workers after diffs of DiffChannel are inspected and their leaks are sent to LeakChannel
 func (s *Searcher) Stop() error {
 	s.tomb.Kill(nil)
 	return s.tomb.Wait()
 }
 
-func loadPatternsFromFile(file string) ([]patternType, error) {
+func parsePatterns(rawData []byte) ([]patternType, error) {
 	rawPatterns := []config.Pattern{}
-	rawData, err := ioutil.ReadFile(file)
+	if err := yaml.Unmarshal(rawData, &rawPatterns); err != nil {
+		return nil, fmt.Errorf("can't parse with: %v", err)
+	}
+	return compilePatterns(rawPatterns)
+}
+
+// parsePatternsFile - patterns of hungryfox, gitleaks or trufflehog rules file, rules which can't be imported entirely
+// are imported partially and warnings are about what is lost
+func parsePatternsFile(file string, rawData []byte) ([]patternType, []string, error) {
+	format := ruleimport.Detect(file, rawData)
+	if format == "" {
+		patterns, err := parsePatterns(rawData)
+		return patterns, nil, err
+	}
+	rawPatterns, warnings, err := ruleimport.Convert(format, rawData)
 	if err != nil {
-		return nil, fmt.Errorf("can't read file '%s' with: %v", file, err)
+		return nil, nil, fmt.Errorf("can't parse %s rules with: %v", format, err)
 	}
-	if err := yaml.Unmarshal(rawData, &rawPatterns); err != nil {
-		return nil, fmt.Errorf("can't parse file '%s' with: %v", file, err)
+	patterns, err := compilePatterns(rawPatterns)
+	return patterns, warnings, err
+}
+
+func loadPatternsFromFile(file string) ([]patternType, []string, error) {
+	rawData, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't read file '%s' with: %v", file, err)
 	}
-	result, err := compilePatterns(rawPatterns)
+	result, warnings, err := parsePatternsFile(file, rawData)
 	if err != nil {
-		return nil, fmt.Errorf("can't compile file '%s' with: %v", file, err)
+		return nil, nil, fmt.Errorf("can't load file '%s': %v", file, err)
 	}
-	return result, nil
+	for i := range warnings {
+		warnings[i] = fmt.Sprintf("file '%s': %s", file, warnings[i])
+	}
+	return result, warnings, nil
 }
 
-func loadPatternsFromPath(path string) ([]patternType, error) {
+func loadPatternsFromPath(path string) ([]patternType, []string, error) {
 	result := []patternType{}
+	warnings := []string{}
 	files, err := filepath.Glob(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for _, file := range files {
-		patterns, err := loadPatternsFromFile(file)
+		patterns, fileWarnings, err := loadPatternsFromFile(file)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		result = append(result, patterns...)
+		warnings = append(warnings, fileWarnings...)
+	}
+	return result, warnings, nil
+}
+
+// warn - log warnings about rules which are imported partially, they are logged on every load of changed files
+func (s *Searcher) warn(warnings []string) {
+	for _, warning := range warnings {
+		s.Log.Warn().Str("warning", warning).Msg("rules are imported partially")
 	}
-	return result, nil
 }
 
 func (s *Searcher) updateConfig(conf *config.Config) error {
-	newCompiledPatterns, err := compilePatterns(conf.Patterns)
+	s.configMutex.Lock()
+	defer s.configMutex.Unlock()
+	if err := s.loadRules(conf); err != nil {
+		return err
+	}
+	s.config = conf
+	return nil
+}
+
+// loadRules - compile patterns and filters of config, current rules are replaced only on success
+func (s *Searcher) loadRules(conf *config.Config) error {
+	// files are listed before reading to catch changes made during loading on next check
+	files, err := getFilesModTime(conf.Common.PatternsPath, conf.Common.FiltresPath, conf.Common.BaselineFile)
+	if err != nil {
+		return err
+	}
+	builtin, err := getBuiltinPatterns(conf.DefaultPatterns)
+	if err != nil {
+		return err
+	}
+	newCompiledPatterns, err := compilePatterns(append(builtin, conf.Patterns...))
 	if err != nil {
 		return err
 	}
@@ -172,26 +498,80 @@ func (s *Searcher) updateConfig(conf *config.Config) error {
 	}
 
 	if conf.Common.PatternsPath != "" {
-		newFilePatterns, err := loadPatternsFromPath(conf.Common.PatternsPath)
+		newFilePatterns, warnings, err := loadPatternsFromPath(conf.Common.PatternsPath)
 		if err != nil {
 			return err
 		}
+		s.warn(warnings)
 		newCompiledPatterns = append(newCompiledPatterns, newFilePatterns...)
 	}
 
 	if conf.Common.FiltresPath != "" {
-		newFileFilters, err := loadPatternsFromPath(conf.Common.FiltresPath)
+		newFileFilters, warnings, err := loadPatternsFromPath(conf.Common.FiltresPath)
 		if err != nil {
 			return err
 		}
+		s.warn(warnings)
 		newCompiledFiltres = append(newCompiledFiltres, newFileFilters...)
 	}
-	s.patterns, s.filters = newCompiledPatterns, newCompiledFiltres
+	newEntropy, err := compileEntropy(conf.Entropy)
+	if err != nil {
+		return err
+	}
+	knownLeaks, err := baseline.Load(conf.Common.BaselineFile)
+	if err != nil {
+		return err
+	}
+	var hasher *hashonly.Hasher
+	if conf.Common.HashOnly {
+		if hasher, err = hashonly.Load(conf.Common.HashKeyFile); err != nil {
+			return err
+		}
+	}
+	s.setRules(&rules{
+		patterns:  newCompiledPatterns,
+		filters:   newCompiledFiltres,
+		entropy:   newEntropy,
+		allowlist: conf.Allowlist,
+		escalate:  conf.Escalate,
+		baseline:  knownLeaks,
+		files:     files,
+		hasher:    hasher,
+		version:   rulesVersion(newCompiledPatterns, newCompiledFiltres, conf.Entropy),
+
+		contextLines:  conf.Common.ContextLines,
+		redactContext: conf.Common.RedactContext,
+	})
 	s.Log.Info().Int("patterns", len(newCompiledPatterns)).Int("filters", len(newCompiledFiltres)).Msg("loaded")
-	s.config = conf
 	return nil
 }
 
+// RulesVersion - hash of loaded rules, instances with the same rules have the same version
+func (s *Searcher) RulesVersion() string {
+	return s.getRules().version
+}
+
+// rulesVersion - hash of everything in rules which changes what is found, order of rules doesn't matter
+func rulesVersion(patterns, filters []patternType, entropy []config.Entropy) string {
+	lines := []string{}
+	for kind, list := range map[string][]patternType{"pattern": patterns, "filter": filters} {
+		for _, p := range list {
+			languages := make([]string, 0, len(p.Languages))
+			for lang := range p.Languages {
+				languages = append(languages, lang)
+			}
+			sort.Strings(languages)
+			lines = append(lines, fmt.Sprintf("%s %q %q %q %q %d %q %q", kind, p.Name, p.ContentRe, p.FileRe, p.Severity, p.SecretGroup, p.Keywords, languages))
+		}
+	}
+	for _, e := range entropy {
+		lines = append(lines, fmt.Sprintf("entropy %+v", e))
+	}
+	sort.Strings(lines)
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(hash[:6])
+}
+
 func (s *Searcher) Status(repoURL string) RepoStats {
 	s.statsMutex.RLock()
 	defer s.statsMutex.RUnlock()
@@ -202,41 +582,277 @@ func (s *Searcher) Status(repoURL string) RepoStats {
 }
 
 func (s *Searcher) GetLeaks(diff hungryfox.Diff) []hungryfox.Leak {
+	return s.getRules().getLeaks(diff)
+}
+
+func (r *rules) getLeaks(diff hungryfox.Diff) []hungryfox.Leak {
+	return r.findLeaks(diff, nil)
+}
+
+// context - lines around line i of diff, every line is cut like leak string, empty if context is disabled.
+// Matches of other leaks on these lines are masked, so the context never carries secrets of neighbouring lines
+func (r *rules) context(lines []string, i int, secret string, masks map[int][]lineMask, self int) string {
+	if r.contextLines <= 0 {
+		return ""
+	}
+	from, to := i-r.contextLines, i+r.contextLines+1
+	if from < 0 {
+		from = 0
+	}
+	if to > len(lines) {
+		to = len(lines)
+	}
+	result := make([]string, 0, to-from)
+	for j := from; j < to; j++ {
+		line := lines[j]
+		for _, m := range masks[j] {
+			if m.leak != self {
+				line = m.apply(line)
+			}
+		}
+		if len(line) > 1024 {
+			line = line[:1024]
+		}
+		if r.redactContext {
+			switch {
+			case secret != "":
+				line = strings.Replace(line, secret, contextMask, -1)
+			case j == i:
+				line = contextMask
+			}
+		}
+		result = append(result, line)
+	}
+	// content of diff ends with new line
+	for len(result) > 1 && result[len(result)-1] == "" {
+		result = result[:len(result)-1]
+	}
+	return strings.Join(result, "\n")
+}
+
+// lineMask - leak found on line of diff, it is masked in context of other leaks
+type lineMask struct {
+	leak   int            // index of leak in findLeaks
+	secret string         // extracted secret
+	re     *regexp.Regexp // every match of pattern is masked if secret is unknown
+}
+
+func (m lineMask) apply(line string) string {
+	if m.secret != "" {
+		return strings.Replace(line, m.secret, contextMask, -1)
+	}
+	if m.re == nil {
+		return contextMask
+	}
+	result, last := "", 0
+	for _, loc := range m.re.FindAllStringIndex(line, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		result += line[last:loc[0]] + contextMask
+		last = loc[1]
+	}
+	return result + line[last:]
+}
+
+// contextMask - placeholder of secret in context of leak
+const contextMask = "*****"
+
+// findLeaks - leaks of diff in order of lines, time spent by every family of rules is added to spent if it is not nil
+func (r *rules) findLeaks(diff hungryfox.Diff, spent usages) []hungryfox.Leak {
 	leaks := make([]hungryfox.Leak, 0)
+	if diff.Language == "" {
+		// shebang is only in the first line of file, other chunks are detected by name
+		head := ""
+		if diff.FileStart || diff.LineBegin == 1 {
+			head = diff.Content
+		}
+		diff.Language = language.Detect(diff.FilePath, head)
+	}
+	if diff.CommitterEmail == diff.AuthorEmail {
+		diff.CommitterEmail = ""
+	}
+	patterns := activePatterns(r.patterns, diff.Content, diff.Language)
+	if len(patterns) == 0 && len(r.entropy) == 0 {
+		return leaks
+	}
 	lines := strings.Split(diff.Content, "\n")
-	for _, line := range lines {
-		for _, pattern := range s.patterns {
-			repoFilePath := fmt.Sprintf("%s/%s", diff.RepoURL, diff.FilePath)
-			if !pattern.FileRe.MatchString(repoFilePath) {
-				continue
+	repoFilePath := fmt.Sprintf("%s/%s", diff.RepoURL, diff.FilePath)
+	// every rule is checked against all lines at once to measure its time, leaks are sorted by line then
+	lineIndexes := []int{}
+	masks := map[int][]lineMask{}
+	add := func(i int, name string, re *regexp.Regexp, reString, severity, secret string) {
+		line := lines[i]
+		if len(line) > 1024 {
+			line = line[:1024]
+		}
+		lineNumber := 0
+		if diff.LineBegin > 0 {
+			lineNumber = diff.LineBegin + i
+		}
+		masks[i] = append(masks[i], lineMask{leak: len(leaks), secret: secret, re: re})
+		lineIndexes = append(lineIndexes, i)
+		leaks = append(leaks, hungryfox.Leak{
+			RepoPath:       diff.RepoPath,
+			FilePath:       diff.FilePath,
+			PatternName:    name,
+			Regexp:         reString,
+			LeakString:     line,
+			CommitHash:     diff.CommitHash,
+			TimeStamp:      diff.TimeStamp,
+			Line:           lineNumber,
+			CommitAuthor:   diff.Author,
+			CommitEmail:    diff.AuthorEmail,
+			RepoURL:        diff.RepoURL,
+			CommitterEmail: diff.CommitterEmail,
+			Severity:       severity,
+			Language:       diff.Language,
+			Secret:         secret,
+			Signed:         diff.Signed,
+			SigningKey:     diff.SigningKey,
+		})
+	}
+	for _, pattern := range patterns {
+		start, found := time.Now(), len(leaks)
+		if pattern.FileRe.MatchString(repoFilePath) {
+			for i, line := range lines {
+				if !pattern.ContentRe.MatchString(line) {
+					continue
+				}
+				match := findMatch(pattern.ContentRe, line, overlap(diff, i))
+				if match == nil {
+					continue
+				}
+				secret := ""
+				if g := pattern.SecretGroup; g > 0 && match[2*g] >= 0 {
+					secret = line[match[2*g]:match[2*g+1]]
+				}
+				add(i, pattern.Name, pattern.ContentRe, pattern.ContentRe.String(), pattern.Severity, secret)
 			}
-			if pattern.ContentRe.MatchString(line) {
-				if len(line) > 1024 {
-					line = line[:1024]
+		}
+		spent.add(pattern.Family, time.Since(start), len(leaks)-found)
+	}
+	for _, detector := range r.entropy {
+		start, found := time.Now(), len(leaks)
+		if detector.FileRe.MatchString(repoFilePath) {
+			for i, line := range lines {
+				if secret, ok := detector.find(line, overlap(diff, i)); ok {
+					add(i, detector.Name, nil, fmt.Sprintf("entropy > %.2f", detector.Threshold), detector.Severity, secret)
 				}
-				leaks = append(leaks, hungryfox.Leak{
-					RepoPath:     diff.RepoPath,
-					FilePath:     diff.FilePath,
-					PatternName:  pattern.Name,
-					Regexp:       pattern.ContentRe.String(),
-					LeakString:   line,
-					CommitHash:   diff.CommitHash,
-					TimeStamp:    diff.TimeStamp,
-					CommitAuthor: diff.Author,
-					CommitEmail:  diff.AuthorEmail,
-					RepoURL:      diff.RepoURL,
-				})
 			}
 		}
+		spent.add(entropyFamily, time.Since(start), len(leaks)-found)
 	}
-	return leaks
+	// context is built when all leaks of diff are known, so every of them can be masked
+	for k := range leaks {
+		leaks[k].Context = r.context(lines, lineIndexes[k], leaks[k].Secret, masks, k)
+	}
+	order := make([]int, len(leaks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return lineIndexes[order[i]] < lineIndexes[order[j]] })
+	result := make([]hungryfox.Leak, len(leaks))
+	for i, index := range order {
+		result[i] = leaks[index]
+	}
+	return result
+}
+
+// overlap - bytes at the beginning of line i which were inspected with the previous window of diff
+func overlap(diff hungryfox.Diff, i int) int {
+	if i == 0 {
+		return diff.Overlap
+	}
+	return 0
+}
+
+// findMatch - indexes of the first match in line and its groups, matches ending in the first overlap bytes are skipped
+// because they were found in the previous window
+func findMatch(re *regexp.Regexp, line string, overlap int) []int {
+	if overlap <= 0 {
+		return re.FindStringSubmatchIndex(line)
+	}
+	for _, match := range re.FindAllStringSubmatchIndex(line, -1) {
+		if match[1] > overlap {
+			return match
+		}
+	}
+	return nil
+}
+
+// IsFiltered - leak matches one of filters
+func (s *Searcher) IsFiltered(leak hungryfox.Leak) bool {
+	return s.getRules().filterLeak(leak)
 }
 
-func (s *Searcher) filterLeak(leak hungryfox.Leak) bool {
-	for _, filter := range s.filters {
+func (r *rules) filterLeak(leak hungryfox.Leak) bool {
+	if r.baseline[leak.Fingerprint()] {
+		return true
+	}
+	if r.hasher != nil && r.baseline[r.hasher.Hash(leak).Fingerprint()] {
+		// baseline of hash_only leaks file
+		return true
+	}
+	for _, a := range r.allowlist {
+		if allowed(a, leak) {
+			return true
+		}
+	}
+	for _, filter := range r.filters {
 		if filter.FileRe.MatchString(fmt.Sprintf("%s/%s", leak.RepoURL, leak.FilePath)) && filter.ContentRe.MatchString(leak.LeakString) {
 			return true
 		}
 	}
 	return false
 }
+
+// allowed - leak matches every non-empty list of allowlist rule
+func allowed(a config.Allowlist, leak hungryfox.Leak) bool {
+	if len(a.Files) > 0 && !matchAnyGlob(a.Files, leak.FilePath) {
+		return false
+	}
+	if len(a.Repos) > 0 && !helpers.MatchRepo(a.Repos, leak.RepoPath, leak.RepoURL) {
+		return false
+	}
+	if len(a.Patterns) > 0 && !matchAnyGlob(a.Patterns, leak.PatternName) {
+		return false
+	}
+	if len(a.Authors) > 0 && !matchAuthor(a.Authors, leak) {
+		return false
+	}
+	return true
+}
+
+// matchAuthor - email of author or committer matches one of glob patterns
+func matchAuthor(patterns []string, leak hungryfox.Leak) bool {
+	for _, email := range []string{leak.CommitEmail, leak.CommitterEmail} {
+		if email == "" {
+			continue
+		}
+		for _, pattern := range patterns {
+			if helpers.MatchGlob(strings.ToLower(pattern), strings.ToLower(email)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// escalateLeak - raise severity of leak to the highest severity of matching escalate rules
+func (r *rules) escalateLeak(leak *hungryfox.Leak) {
+	for _, e := range r.escalate {
+		if hungryfox.SeverityLevel(e.Severity) > hungryfox.SeverityLevel(leak.Severity) && matchAuthor(e.Authors, *leak) {
+			leak.Severity = e.Severity
+		}
+	}
+}
+
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if helpers.MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
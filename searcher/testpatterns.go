@@ -0,0 +1,85 @@
+package searcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// PatternMatch - line matched by pattern
+type PatternMatch struct {
+	Line   int               `json:"line"`
+	Text   string            `json:"text"`
+	Groups map[string]string `json:"groups,omitempty"` // capture groups by name or number
+	Secret string            `json:"secret,omitempty"`
+}
+
+// PatternResult - matches of pattern in sample and time spent in its regexps
+type PatternResult struct {
+	Name string `json:"name"`
+	// Skipped - why pattern wasn't checked: keywords, languages or file
+	Skipped  string         `json:"skipped,omitempty"`
+	Matches  []PatternMatch `json:"matches"`
+	Duration time.Duration  `json:"duration_ns"`
+}
+
+// CheckPatterns - check every pattern of file against sample content of filePath, for development of rules,
+// warnings are about rules of imported file which are imported partially
+func CheckPatterns(patternsFile, filePath, content, lang string) ([]PatternResult, []string, error) {
+	rawData, err := ioutil.ReadFile(patternsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	patterns, warnings, err := parsePatternsFile(patternsFile, rawData)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := strings.Split(content, "\n")
+	results := make([]PatternResult, 0, len(patterns))
+	for i, p := range patterns {
+		result := PatternResult{Name: p.Name, Matches: []PatternMatch{}}
+		if result.Name == "" {
+			result.Name = fmt.Sprintf("#%d", i+1)
+		}
+		switch {
+		case p.Languages != nil && !p.Languages[lang]:
+			result.Skipped = fmt.Sprintf("language '%s'", lang)
+		case len(activePatterns([]patternType{p}, content, lang)) == 0:
+			result.Skipped = "no keywords in content"
+		case !p.FileRe.MatchString(filePath):
+			result.Skipped = fmt.Sprintf("file regexp '%s'", p.FileRe)
+		}
+		if result.Skipped != "" {
+			results = append(results, result)
+			continue
+		}
+		for n, line := range lines {
+			started := time.Now()
+			match := p.ContentRe.FindStringSubmatch(line)
+			result.Duration += time.Since(started)
+			if match == nil {
+				continue
+			}
+			m := PatternMatch{Line: n + 1, Text: line}
+			for group, name := range p.ContentRe.SubexpNames() {
+				if group == 0 {
+					continue
+				}
+				if name == "" {
+					name = fmt.Sprint(group)
+				}
+				if m.Groups == nil {
+					m.Groups = map[string]string{}
+				}
+				m.Groups[name] = match[group]
+			}
+			if p.SecretGroup > 0 {
+				m.Secret = match[p.SecretGroup]
+			}
+			result.Matches = append(result.Matches, m)
+		}
+		results = append(results, result)
+	}
+	return results, warnings, nil
+}
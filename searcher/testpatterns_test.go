@@ -0,0 +1,41 @@
+package searcher
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheckPatterns(t *testing.T) {
+	file, _ := ioutil.TempFile("", "patterns")
+	defer os.Remove(file.Name())
+	file.WriteString(`
+- name: password
+  content: (?P<key>password)\s*=\s*"(.+)"
+  secret_group: 2
+- name: python only
+  content: .+
+  languages: [python]
+- name: aws
+  content: AKIA
+  keywords: [akia]
+- name: yaml only
+  file: \.ya?ml$
+`)
+	file.Close()
+	Convey("matches, groups and skipped patterns are reported", t, func() {
+		results, warnings, err := CheckPatterns(file.Name(), "config.go", "a = 1\npassword = \"qwerty\"", "go")
+		So(err, ShouldBeNil)
+		So(warnings, ShouldBeEmpty)
+		So(results, ShouldHaveLength, 4)
+		So(results[0].Matches, ShouldHaveLength, 1)
+		So(results[0].Matches[0].Line, ShouldEqual, 2)
+		So(results[0].Matches[0].Groups, ShouldResemble, map[string]string{"key": "password", "2": "qwerty"})
+		So(results[0].Matches[0].Secret, ShouldEqual, "qwerty")
+		So(results[1].Skipped, ShouldContainSubstring, "language")
+		So(results[2].Skipped, ShouldContainSubstring, "keywords")
+		So(results[3].Skipped, ShouldContainSubstring, "file")
+	})
+}
@@ -0,0 +1,238 @@
+// Package archive - upload batches of leaks as gzipped json lines to S3-compatible bucket for long-term archiving
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+	"github.com/AlexAkulov/hungryfox/senders/file"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/tomb.v2"
+)
+
+const (
+	batchExt   = ".jsonl.gz"
+	timeFormat = "20060102T150405.000Z"
+)
+
+// Archive - collect leaks as records of json_lines schema and upload them as one object every Interval,
+// batches are written to PendingDir before upload and removed from it after, so they survive failed uploads and restarts
+type Archive struct {
+	Bucket     *Bucket
+	Prefix     string
+	Instance   string        // part of object names, hostname if empty
+	Interval   time.Duration // how often batch is uploaded
+	Retention  time.Duration // older batches are removed after upload, kept forever if zero
+	PendingDir string        // batches which are not uploaded yet
+	MaxPending int64         // bytes of pending batches, the oldest are dropped over it, unlimited if zero
+	Clock      clock.Clock   // system clock if nil
+	Log        zerolog.Logger
+
+	mutex sync.Mutex // guards batch and count, it is not held during upload
+	batch bytes.Buffer
+	count int
+	flush sync.Mutex // one flush at once
+	tomb  tomb.Tomb
+}
+
+func (a *Archive) Start() error {
+	if a.Bucket == nil || a.Bucket.Name == "" {
+		return fmt.Errorf("archive bucket is required")
+	}
+	if a.PendingDir == "" {
+		return fmt.Errorf("archive pending dir is required")
+	}
+	if a.Instance == "" {
+		a.Instance, _ = os.Hostname()
+	}
+	if err := os.MkdirAll(a.PendingDir, 0700); err != nil {
+		return err
+	}
+	a.tomb.Go(func() error {
+		ticker := time.NewTicker(a.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.tomb.Dying():
+				return nil
+			case <-ticker.C:
+				a.Flush()
+			}
+		}
+	})
+	return nil
+}
+
+// Stop - upload the last batch
+func (a *Archive) Stop() error {
+	a.tomb.Kill(nil)
+	a.tomb.Wait()
+	return a.Flush()
+}
+
+func (a *Archive) Send(leak hungryfox.Leak) error {
+	data, err := json.Marshal(file.NewRecord(leak))
+	if err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.batch.Write(data)
+	a.batch.WriteByte('\n')
+	a.count++
+	return nil
+}
+
+// Flush - write collected leaks to pending batch, upload pending batches and remove batches older than Retention
+func (a *Archive) Flush() error {
+	a.flush.Lock()
+	defer a.flush.Unlock()
+	now := clock.Or(a.Clock).Now().UTC()
+	a.mutex.Lock()
+	batch, count := a.batch.Bytes(), a.count
+	a.batch, a.count = bytes.Buffer{}, 0
+	a.mutex.Unlock()
+	if count > 0 {
+		if err := a.writePending(now, batch); err != nil {
+			a.Log.Error().Str("service", "archive").Str("error", err.Error()).Int("leaks", count).Msg("can't write pending batch, leaks are lost")
+			return err
+		}
+	}
+	if err := a.uploadPending(); err != nil {
+		a.Log.Error().Str("service", "archive").Str("error", err.Error()).Msg("can't upload leaks, pending batches are kept for the next upload")
+		return err
+	}
+	if a.Retention > 0 {
+		if err := a.removeExpired(now); err != nil {
+			a.Log.Error().Str("service", "archive").Str("error", err.Error()).Msg("can't remove expired batches")
+			return err
+		}
+	}
+	return nil
+}
+
+// name - file name of batch in PendingDir and the last part of its key, names are sorted by time
+func (a *Archive) name(now time.Time) string {
+	return fmt.Sprintf("%s-%s%s", now.Format(timeFormat), a.Instance, batchExt)
+}
+
+// key - name of batch object, keys are sorted by time
+func (a *Archive) key(name string) (string, error) {
+	if len(name) < len(timeFormat) {
+		return "", fmt.Errorf("bad name of batch '%s'", name)
+	}
+	created, err := time.Parse(timeFormat, name[:len(timeFormat)])
+	if err != nil {
+		return "", fmt.Errorf("bad name of batch '%s': %v", name, err)
+	}
+	return a.Prefix + path.Join(created.Format("2006/01/02"), name), nil
+}
+
+// writePending - gzip batch into PendingDir and drop the oldest pending batches over MaxPending
+func (a *Archive) writePending(now time.Time, batch []byte) error {
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	if _, err := writer.Write(batch); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	name := filepath.Join(a.PendingDir, a.name(now))
+	if err := ioutil.WriteFile(name+".tmp", compressed.Bytes(), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+	if a.MaxPending <= 0 {
+		return nil
+	}
+	files, err := a.pending()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for i := len(files) - 1; i >= 0; i-- {
+		if size += files[i].Size(); size <= a.MaxPending || i == len(files)-1 {
+			continue
+		}
+		if err := os.Remove(filepath.Join(a.PendingDir, files[i].Name())); err != nil {
+			return err
+		}
+		a.Log.Warn().Str("service", "archive").Str("batch", files[i].Name()).Msg("pending batches are over limit, the oldest batch is dropped")
+	}
+	return nil
+}
+
+// pending - batches of PendingDir, the oldest first
+func (a *Archive) pending() ([]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(a.PendingDir)
+	if err != nil {
+		return nil, err
+	}
+	result := []os.FileInfo{}
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), batchExt) {
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+// uploadPending - upload pending batches from the oldest and remove them, the rest is kept after failed upload
+func (a *Archive) uploadPending() error {
+	files, err := a.pending()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		key, err := a.key(f.Name())
+		if err != nil {
+			return err
+		}
+		fileName := filepath.Join(a.PendingDir, f.Name())
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		if err := a.Bucket.Put(key, "application/gzip", data); err != nil {
+			return err
+		}
+		a.Log.Info().Str("service", "archive").Str("key", key).Msg("leaks uploaded")
+		if err := os.Remove(fileName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archive) removeExpired(now time.Time) error {
+	objects, err := a.Bucket.List(a.Prefix)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		// other objects of prefix are not touched
+		if !strings.HasSuffix(object.Key, batchExt) || now.Sub(object.LastModified) <= a.Retention {
+			continue
+		}
+		if err := a.Bucket.Delete(object.Key); err != nil {
+			return err
+		}
+		a.Log.Info().Str("service", "archive").Str("key", object.Key).Msg("expired batch removed")
+	}
+	return nil
+}
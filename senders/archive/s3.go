@@ -0,0 +1,201 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bucket - minimal client of S3 api with AWS signature version 4, path-style urls work with AWS, minio and ceph
+type Bucket struct {
+	Endpoint  string // scheme and host, e.g. https://s3.amazonaws.com
+	Region    string
+	Name      string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// Object - key and time of object in bucket
+type Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type listResult struct {
+	Contents              []Object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// Put - upload object
+func (b *Bucket) Put(key, contentType string, body []byte) error {
+	req, err := b.request(http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	_, err = b.do(req)
+	return err
+}
+
+// List - objects with key prefix
+func (b *Bucket) List(prefix string) ([]Object, error) {
+	result := []Object{}
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		req, err := b.request(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		data, err := b.do(req)
+		if err != nil {
+			return nil, err
+		}
+		page := listResult{}
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("can't parse list of objects: %v", err)
+		}
+		result = append(result, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return result, nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// Delete - remove object
+func (b *Bucket) Delete(key string) error {
+	req, err := b.request(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = b.do(req)
+	return err
+}
+
+func (b *Bucket) request(method, key string, query url.Values, body []byte) (*http.Request, error) {
+	path := "/" + b.Name
+	if key != "" {
+		path += "/" + key
+	}
+	rawURL := strings.TrimSuffix(b.Endpoint, "/") + escapePath(path)
+	if len(query) > 0 {
+		rawURL += "?" + canonicalQuery(query)
+	}
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	sign(req, body, b.Region, b.AccessKey, b.SecretKey, time.Now())
+	return req, nil
+}
+
+func (b *Bucket) do(req *http.Request) ([]byte, error) {
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: %s %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
+
+// sign - add authorization header of AWS signature version 4 signed with host, x-amz-content-sha256 and x-amz-date
+func sign(req *http.Request, body []byte, region, accessKey, secretKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.URL.Host
+	if req.Host != "" {
+		host = req.Host
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		"host:" + host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery - query sorted by name with names and values escaped by RFC 3986
+func canonicalQuery(query url.Values) string {
+	pairs := []string{}
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escape(name, true)+"="+escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// escapePath - path escaped by RFC 3986 except slashes
+func escapePath(path string) string {
+	return escape(path, false)
+}
+
+// escape - every byte except unreserved characters is percent-encoded, slash is kept if it is not query
+func escape(s string, query bool) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !query {
+			buf.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&buf, "%%%02X", c)
+	}
+	return buf.String()
+}
@@ -0,0 +1,99 @@
+// Package command - run external command for every leak with leak json on stdin, for integrations without go code
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	"github.com/rs/zerolog"
+)
+
+// FingerprintEnv - environment variable with fingerprint of leak, json of leak doesn't include it
+const FingerprintEnv = "HUNGRYFOX_FINGERPRINT"
+
+// maxOutput - bytes of stdout and stderr of failed command which are logged
+const maxOutput = 1024
+
+// Sender - run Command for every leak and wait for it, so failed commands are reported to router
+type Sender struct {
+	Name    string
+	Command []string      // executable and arguments, it is not run by shell
+	Timeout time.Duration // command is killed after it, 30s if zero
+	Log     zerolog.Logger
+}
+
+func (s *Sender) Start() error {
+	if len(s.Command) == 0 {
+		return fmt.Errorf("command is required")
+	}
+	if _, err := exec.LookPath(s.Command[0]); err != nil {
+		return err
+	}
+	if s.Timeout == 0 {
+		s.Timeout = 30 * time.Second
+	}
+	return nil
+}
+
+func (s *Sender) Stop() error {
+	return nil
+}
+
+// Send - run command for leak, exit code other than zero and timeout are errors
+func (s *Sender) Send(leak hungryfox.Leak) error {
+	data, err := json.Marshal(leak)
+	if err != nil {
+		return err
+	}
+	if err := s.run(data, leak.Fingerprint()); err != nil {
+		s.Log.Error().Str("service", "exec").Str("sender", s.Name).Str("error", err.Error()).Str("fingerprint", leak.Fingerprint()).Msg("can't send leak")
+		return err
+	}
+	return nil
+}
+
+func (s *Sender) Recipients(leak hungryfox.Leak) []string {
+	return []string{s.Command[0]}
+}
+
+func (s *Sender) run(data []byte, fingerprint string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Env = append(os.Environ(), FingerprintEnv+"="+fingerprint)
+	output := &limitedBuffer{limit: maxOutput}
+	cmd.Stdout, cmd.Stderr = output, output
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("killed after %s: %s", s.Timeout, output.String())
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, output.String())
+	}
+	return nil
+}
+
+// limitedBuffer - first bytes of output, the rest is discarded
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if free := b.limit - b.Len(); free > 0 {
+		if len(p) > free {
+			b.Buffer.Write(p[:free])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,199 @@
+package email
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// templateData - type of data passed to template, it is the catalog of available variables
+var templateData = reflect.TypeOf(mailTemplateStruct{})
+
+type templateValidator struct {
+	tree *parse.Tree
+	root reflect.Type
+}
+
+// validateTemplate - check that every variable used in template exists in data type
+func validateTemplate(tree *parse.Tree, data reflect.Type) error {
+	if tree == nil || tree.Root == nil {
+		return nil
+	}
+	v := templateValidator{tree: tree, root: data}
+	return v.walk(tree.Root, data)
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func availableNames(t reflect.Type) string {
+	names := fieldNames(t)
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// fieldNames - exported fields, fields of embedded structs are promoted
+func fieldNames(t reflect.Type) []string {
+	names := []string{}
+	t = indirect(t)
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch {
+		case f.Anonymous:
+			names = append(names, fieldNames(f.Type)...)
+		case f.PkgPath == "":
+			names = append(names, "."+f.Name)
+		}
+	}
+	return names
+}
+
+// resolveField - type of field or method result
+func resolveField(t reflect.Type, name string) (reflect.Type, bool) {
+	if m, ok := t.MethodByName(name); ok && m.Type.NumOut() > 0 {
+		return m.Type.Out(0), true
+	}
+	if t.Kind() != reflect.Ptr {
+		if m, ok := reflect.PtrTo(t).MethodByName(name); ok && m.Type.NumOut() > 0 {
+			return m.Type.Out(0), true
+		}
+	}
+	st := indirect(t)
+	if st.Kind() != reflect.Struct {
+		return nil, false
+	}
+	f, ok := st.FieldByName(name)
+	if !ok || f.PkgPath != "" {
+		return nil, false
+	}
+	return f.Type, true
+}
+
+func (v *templateValidator) resolve(node parse.Node, dot reflect.Type, idents []string) (reflect.Type, error) {
+	t := dot
+	for _, ident := range idents {
+		if t == nil {
+			return nil, nil
+		}
+		next, ok := resolveField(t, ident)
+		if !ok {
+			location, _ := v.tree.ErrorContext(node)
+			return nil, fmt.Errorf("%s: unknown variable .%s, available: %s", location, ident, availableNames(t))
+		}
+		t = next
+	}
+	return t, nil
+}
+
+// pipeType - type of pipeline result, nil if it can't be determined
+func (v *templateValidator) pipeType(pipe *parse.PipeNode, dot reflect.Type) (reflect.Type, error) {
+	if pipe == nil {
+		return nil, nil
+	}
+	var result reflect.Type
+	for i, cmd := range pipe.Cmds {
+		for j, arg := range cmd.Args {
+			t, err := v.argType(arg, dot)
+			if err != nil {
+				return nil, err
+			}
+			if i == len(pipe.Cmds)-1 && j == 0 && len(cmd.Args) == 1 {
+				result = t
+			}
+		}
+	}
+	return result, nil
+}
+
+func (v *templateValidator) argType(arg parse.Node, dot reflect.Type) (reflect.Type, error) {
+	switch n := arg.(type) {
+	case *parse.FieldNode:
+		return v.resolve(n, dot, n.Ident)
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" {
+			return v.resolve(n, v.root, n.Ident[1:])
+		}
+	case *parse.ChainNode:
+		if pipe, ok := n.Node.(*parse.PipeNode); ok {
+			t, err := v.pipeType(pipe, dot)
+			if err != nil || t == nil {
+				return nil, err
+			}
+			return v.resolve(n, t, n.Field)
+		}
+	case *parse.PipeNode:
+		return v.pipeType(n, dot)
+	case *parse.DotNode:
+		return dot, nil
+	}
+	return nil, nil
+}
+
+func elemType(t reflect.Type) reflect.Type {
+	t = indirect(t)
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return t.Elem()
+	}
+	return nil
+}
+
+func (v *templateValidator) walk(node parse.Node, dot reflect.Type) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := v.walk(child, dot); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		_, err := v.pipeType(n.Pipe, dot)
+		return err
+	case *parse.IfNode:
+		return v.walkBranch(&n.BranchNode, dot, dot)
+	case *parse.WithNode:
+		t, err := v.pipeType(n.Pipe, dot)
+		if err != nil {
+			return err
+		}
+		return v.walkBranch(&n.BranchNode, t, dot)
+	case *parse.RangeNode:
+		t, err := v.pipeType(n.Pipe, dot)
+		if err != nil {
+			return err
+		}
+		return v.walkBranch(&n.BranchNode, elemType(t), dot)
+	case *parse.TemplateNode:
+		_, err := v.pipeType(n.Pipe, dot)
+		return err
+	}
+	return nil
+}
+
+func (v *templateValidator) walkBranch(n *parse.BranchNode, listDot, elseDot reflect.Type) error {
+	if _, err := v.pipeType(n.Pipe, elseDot); err != nil {
+		return err
+	}
+	if err := v.walk(n.List, listDot); err != nil {
+		return err
+	}
+	if n.ElseList != nil {
+		return v.walk(n.ElseList, elseDot)
+	}
+	return nil
+}
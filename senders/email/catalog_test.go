@@ -0,0 +1,46 @@
+package email
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	Convey("default template is valid", t, func() {
+		tmpl, err := template.New("mail").Parse(defaultTemplate)
+		So(err, ShouldBeNil)
+		So(validateTemplate(tmpl.Tree, templateData), ShouldBeNil)
+	})
+	Convey("default template shows signature of commit", t, func() {
+		tmpl, err := loadTemplate("")
+		So(err, ShouldBeNil)
+		leak := hungryfox.Leak{RepoURL: "https://github.com/backend/api", Signed: hungryfox.SignatureSSH, SigningKey: "SHA256:key"}
+		data := &mailTemplateStruct{Repos: []*mailTemplateRepoStruct{{RepoURL: leak.RepoURL, Items: []mailTemplateLeak{{Leak: leak}}}}}
+		out := &bytes.Buffer{}
+		So(tmpl.Execute(out, data), ShouldBeNil)
+		So(out.String(), ShouldContainSubstring, "Подпись коммита: ssh <i>SHA256:key</i>")
+	})
+	Convey("unknown variable in range is found", t, func() {
+		tmpl, err := template.New("mail").Parse(`{{ range .Repos }}{{ range .Items }}{{ .CommitAuthor }} {{ .Autor }}{{ end }}{{ end }}`)
+		So(err, ShouldBeNil)
+		err = validateTemplate(tmpl.Tree, templateData)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "unknown variable .Autor")
+		So(err.Error(), ShouldContainSubstring, ".CommitAuthor, .CommitEmail, .CommitHash, .CommitterEmail, .Context, .DetailURL")
+	})
+	Convey("unknown root variable is found", t, func() {
+		tmpl, err := template.New("mail").Parse(`{{ if .LeaksCount }}{{ .RepoURL }}{{ end }}`)
+		So(err, ShouldBeNil)
+		So(validateTemplate(tmpl.Tree, templateData), ShouldNotBeNil)
+	})
+	Convey("methods and root variable are allowed", t, func() {
+		tmpl, err := template.New("mail").Parse(`{{ range .Repos }}{{ $.FilesCount }}{{ range .Items }}{{ .TimeStamp.Format "2006" }}{{ end }}{{ end }}`)
+		So(err, ShouldBeNil)
+		So(validateTemplate(tmpl.Tree, templateData), ShouldBeNil)
+	})
+}
@@ -0,0 +1,84 @@
+package email
+
+import (
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// maxDigestWait - the longest sleep before schedules of digests are checked again, so a changed clock is noticed
+const maxDigestWait = 10 * time.Minute
+
+// hasDigests - some route collects leaks for digest
+func (s *Sender) hasDigests() bool {
+	for i := range s.Routes {
+		if s.Routes[i].Digest != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// addDigest - collect leak for the next digest of its route
+func (s *Sender) addDigest(e envelope, leak hungryfox.Leak) {
+	s.digestMutex.Lock()
+	defer s.digestMutex.Unlock()
+	if s.digests == nil {
+		s.digests = map[string]*message{}
+	}
+	key := e.key()
+	if s.digests[key] == nil {
+		s.digests[key] = newMessage(e)
+	}
+	s.digests[key].add(s.Config, leak)
+}
+
+// sendDigests - send collected leaks of digest route, of all routes if route is zero
+func (s *Sender) sendDigests(route int) {
+	s.digestMutex.Lock()
+	ready := []*message{}
+	for key, m := range s.digests {
+		if route == 0 || m.digest == route {
+			ready = append(ready, m)
+			delete(s.digests, key)
+		}
+	}
+	s.digestMutex.Unlock()
+	for _, m := range ready {
+		s.deliver(m)
+	}
+}
+
+// runDigests - send digest of every route at times of its schedule, collected leaks are sent on stop,
+// so they are not lost on restart
+func (s *Sender) runDigests() error {
+	next := map[int]time.Time{}
+	now := time.Now()
+	for i := range s.Routes {
+		if s.Routes[i].Digest != nil {
+			next[i+1] = s.Routes[i].Digest.Next(now)
+		}
+	}
+	for {
+		wait := maxDigestWait
+		now := time.Now()
+		for _, t := range next {
+			if t.Sub(now) < wait {
+				wait = t.Sub(now)
+			}
+		}
+		select {
+		case <-s.tomb.Dying():
+			s.sendDigests(0)
+			return nil
+		case <-time.After(wait):
+		}
+		now = time.Now()
+		for route, t := range next {
+			if !now.Before(t) {
+				s.sendDigests(route)
+				next[route] = s.Routes[route-1].Digest.Next(now)
+			}
+		}
+	}
+}
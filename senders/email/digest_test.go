@@ -0,0 +1,48 @@
+package email
+
+import (
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/cron"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDigest(t *testing.T) {
+	Convey("leaks of digest route are collected till its schedule", t, func() {
+		weekly, err := cron.Parse("@weekly")
+		So(err, ShouldBeNil)
+		relay := &fakeRelay{}
+		config := &Config{From: "fox@example.com", SMTPHost: "relay.example.com", TLSMode: TLSNone}
+		s := &Sender{
+			AuditorEmail: "security@example.com",
+			Routes: []Route{
+				{Name: "critical", MinSeverity: hungryfox.SeverityCritical, To: []string{"secops@example.com"}},
+				{Name: "weekly", To: []string{"digest@example.com"}, Digest: weekly},
+			},
+			Config: config,
+			pool:   &pool{config: config, dial: relay.dial},
+		}
+		s.template, err = loadTemplate("")
+		So(err, ShouldBeNil)
+		So(s.hasDigests(), ShouldBeTrue)
+
+		b := s.batchMaker().(*batch)
+		b.Add(hungryfox.Leak{RepoURL: "https://github.com/backend/api", PatternName: "aws", Severity: hungryfox.SeverityCritical})
+		b.Add(hungryfox.Leak{RepoURL: "https://github.com/backend/api", FilePath: "a.yml", PatternName: "password"})
+		b.Add(hungryfox.Leak{RepoURL: "https://github.com/backend/web", FilePath: "b.yml", PatternName: "password"})
+		So(onlyMessage(b).To, ShouldResemble, []string{"secops@example.com"})
+		So(s.digests, ShouldHaveLength, 1)
+		So(s.digests["digest 2:digest@example.com;"].LeaksCount, ShouldEqual, 2)
+
+		s.sendDigests(1)
+		So(relay.messages, ShouldBeEmpty)
+		s.sendDigests(2)
+		s.pool.Close()
+		So(s.digests, ShouldBeEmpty)
+		So(relay.messages, ShouldHaveLength, 1)
+		So(relay.messages[0], ShouldContainSubstring, "Found 2 leaks in 2 repos")
+		So(relay.commands, ShouldContain, "RCPT TO:<digest@example.com>")
+	})
+}
@@ -0,0 +1,27 @@
+package email
+
+import (
+	"io"
+	"net"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Report - html message which is not a batch of leaks like scheduled summary, every message uses its own connection
+type Report struct {
+	Config *Config
+	To     []string
+	dial   func() (net.Conn, error) // tcp connection to relay, it's replaced in tests
+}
+
+// Send - send message with subject and html body written by body
+func (r *Report) Send(subject string, body func(io.Writer) error) error {
+	p := &pool{config: r.Config, dial: r.dial}
+	defer p.Close()
+	m := gomail.NewMessage()
+	m.SetHeader("From", r.Config.From)
+	m.SetHeader("To", r.To...)
+	m.SetHeader("Subject", subject)
+	m.AddAlternativeWriter("text/html", body)
+	return p.send(envelopeFrom(r.Config.From), r.To, m)
+}
@@ -0,0 +1,32 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReport(t *testing.T) {
+	Convey("report is sent as html message to its recipients", t, func() {
+		relay := &fakeRelay{}
+		r := &Report{
+			Config: &Config{From: "Hungry Fox <fox@example.com>", SMTPHost: "relay.example.com", TLSMode: TLSNone},
+			To:     []string{"leads@example.com"},
+			dial:   relay.dial,
+		}
+		So(r.Send("Weekly summary", func(w io.Writer) error {
+			_, err := fmt.Fprint(w, "<p>12 new leaks</p>")
+			return err
+		}), ShouldBeNil)
+		So(relay.commands, ShouldContain, "MAIL FROM:<fox@example.com>")
+		So(relay.commands, ShouldContain, "RCPT TO:<leads@example.com>")
+		So(relay.commands, ShouldContain, "QUIT")
+		So(relay.messages, ShouldHaveLength, 1)
+		So(relay.messages[0], ShouldContainSubstring, "Subject: Weekly summary")
+		So(relay.messages[0], ShouldContainSubstring, "text/html")
+		So(strings.Contains(relay.messages[0], "12 new leaks"), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,121 @@
+package email
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/cron"
+	"github.com/AlexAkulov/hungryfox/helpers"
+)
+
+// Route - leaks matching every non-empty condition are sent to To and CC instead of auditors
+type Route struct {
+	Name        string
+	MinSeverity string   // leaks with lower severity don't match, leaks without severity are medium
+	Repos       []string // glob patterns of repo path or host/path
+	Patterns    []string // glob patterns of pattern name
+	To          []string
+	CC          []string
+	Digest      *cron.Schedule // leaks are collected and sent at every time of schedule instead of after delay if set
+}
+
+func (r *Route) match(leak hungryfox.Leak) bool {
+	if r.MinSeverity != "" {
+		severity := leak.Severity
+		if severity == "" {
+			severity = hungryfox.SeverityMedium
+		}
+		if hungryfox.SeverityLevel(severity) < hungryfox.SeverityLevel(r.MinSeverity) {
+			return false
+		}
+	}
+	if len(r.Repos) > 0 && !helpers.MatchRepo(r.Repos, leak.RepoPath, leak.RepoURL) {
+		return false
+	}
+	if len(r.Patterns) > 0 {
+		for _, pattern := range r.Patterns {
+			if helpers.MatchGlob(pattern, leak.PatternName) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// envelope - recipients of one message
+type envelope struct {
+	To     []string
+	CC     []string
+	digest int // number of digest route, zero if message is sent after delay
+}
+
+func (e envelope) key() string {
+	key := strings.Join(e.To, ",") + ";" + strings.Join(e.CC, ",")
+	if e.digest > 0 {
+		key = fmt.Sprintf("digest %d:%s", e.digest, key)
+	}
+	return key
+}
+
+// splitAddresses - addresses of comma separated list
+func splitAddresses(list string) []string {
+	result := []string{}
+	for _, address := range strings.Split(list, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			result = append(result, address)
+		}
+	}
+	return result
+}
+
+// authorEmail - email of commit author if authors are notified and it is in allowed domains, empty otherwise,
+// nobody is notified without allowed domains
+func (s *Sender) authorEmail(leak hungryfox.Leak) string {
+	email := strings.ToLower(strings.TrimSpace(leak.CommitEmail))
+	if !s.SendToAuthor || !strings.Contains(email, "@") {
+		return ""
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for _, allowed := range s.AuthorDomains {
+		if strings.EqualFold(domain, allowed) {
+			return email
+		}
+	}
+	return ""
+}
+
+// envelopes - messages which include leak: the first matching route or auditors, and commit author
+func (s *Sender) envelopes(leak hungryfox.Leak) []envelope {
+	result := []envelope{}
+	e := envelope{To: splitAddresses(s.AuditorEmail), CC: s.CC}
+	for i := range s.Routes {
+		if s.Routes[i].match(leak) {
+			e = envelope{To: s.Routes[i].To, CC: s.Routes[i].CC}
+			if s.Routes[i].Digest != nil {
+				e.digest = i + 1
+			}
+			break
+		}
+	}
+	if len(e.To) > 0 {
+		result = append(result, e)
+	}
+	if author := s.authorEmail(leak); author != "" {
+		result = append(result, envelope{To: []string{author}})
+	}
+	return result
+}
+
+// Recipients - addresses which receive leak, copies are prefixed with cc:
+func (s *Sender) Recipients(leak hungryfox.Leak) []string {
+	result := []string{}
+	for _, e := range s.envelopes(leak) {
+		result = append(result, e.To...)
+		for _, address := range e.CC {
+			result = append(result, "cc:"+address)
+		}
+	}
+	return result
+}
@@ -1,10 +1,9 @@
 package email
 
 import (
-	"crypto/tls"
 	"fmt"
 	"io"
-	"net/smtp"
+	"net/mail"
 	"strings"
 
 	"github.com/AlexAkulov/hungryfox"
@@ -21,79 +20,148 @@ type mailTemplateStruct struct {
 
 type mailTemplateRepoStruct struct {
 	RepoURL string
-	Items   []hungryfox.Leak
+	Items   []mailTemplateLeak
+}
+
+type mailTemplateLeak struct {
+	hungryfox.Leak
+	// DetailURL - link to leak page in web UI, empty if UI is disabled
+	DetailURL string
 }
 
 func (s *Sender) batchMaker() muster.Batch {
 	return &batch{
-		Sender: s,
-		Repos:  map[string]*mailTemplateRepoStruct{},
-		Files:  map[string]struct{}{},
+		Sender:   s,
+		messages: map[string]*message{},
 	}
 }
 
+// batch - leaks collected during delay, one message per set of recipients
 type batch struct {
+	Sender   *Sender
+	messages map[string]*message
+}
+
+// message - leaks for the same recipients
+type message struct {
+	envelope
 	LeaksCount int
 	Repos      map[string]*mailTemplateRepoStruct
 	Files      map[string]struct{}
-	Sender     *Sender
+	leaks      []hungryfox.Leak // original leaks for audit of delivery
 }
 
 func (b *batch) Fire(notifier muster.Notifier) {
 	defer notifier.Done()
-	if b.LeaksCount < 1 {
+	for _, m := range b.messages {
+		b.Sender.deliver(m)
+	}
+}
+
+// deliver - send message and record its delivery
+func (s *Sender) deliver(m *message) {
+	if m.LeaksCount < 1 {
 		return
 	}
 	messageData := &mailTemplateStruct{
-		FilesCount: len(b.Files),
-		LeaksCount: b.LeaksCount,
+		FilesCount: len(m.Files),
+		LeaksCount: m.LeaksCount,
 	}
-	for _, repo := range b.Repos {
+	for _, repo := range m.Repos {
 		messageData.Repos = append(messageData.Repos, repo)
 	}
-	err := b.Sender.sendMessage(b.Sender.AuditorEmail, messageData)
+	err := s.sendMessage(m.envelope, messageData)
 	if err != nil {
-		b.Sender.Log.Error().Str("error", err.Error()).Msg("can't send email")
+		s.Log.Error().Str("error", err.Error()).Strs("to", m.To).Msg("can't send email")
+	}
+	s.audit(m, err)
+}
+
+// audit - record delivery of message for every leak of it, copies are prefixed with cc: like in Recipients
+func (s *Sender) audit(m *message, err error) {
+	if s.Audit == nil {
+		return
+	}
+	recipients := append([]string{}, m.To...)
+	for _, address := range m.CC {
+		recipients = append(recipients, "cc:"+address)
+	}
+	for _, leak := range m.leaks {
+		event := hungryfox.AuditEvent{
+			Action:      hungryfox.AuditNotification,
+			Result:      hungryfox.AuditOK,
+			Repo:        leak.RepoURL,
+			Sender:      "email",
+			Recipients:  recipients,
+			Fingerprint: leak.Fingerprint(),
+		}
+		if err != nil {
+			event.Result, event.Error = hungryfox.AuditFailed, err.Error()
+		}
+		s.Audit.Record(event)
 	}
 }
 
 func (b *batch) Add(item interface{}) {
 	leak := item.(hungryfox.Leak)
+	for _, e := range b.Sender.envelopes(leak) {
+		if e.digest > 0 {
+			b.Sender.addDigest(e, leak)
+			continue
+		}
+		key := e.key()
+		if b.messages[key] == nil {
+			b.messages[key] = newMessage(e)
+		}
+		b.messages[key].add(b.Sender.Config, leak)
+	}
+}
+
+func newMessage(e envelope) *message {
+	return &message{
+		envelope: e,
+		Repos:    map[string]*mailTemplateRepoStruct{},
+		Files:    map[string]struct{}{},
+	}
+}
+
+func (m *message) add(config *Config, leak hungryfox.Leak) {
+	m.leaks = append(m.leaks, leak)
+	detailURL := ""
+	if config.UIURL != "" {
+		// fingerprint is taken before leak string is trimmed
+		detailURL = strings.TrimRight(config.UIURL, "/") + hungryfox.UILeakPath + leak.Fingerprint()
+	}
+	if config.Redact {
+		leak.LeakString = leak.Redacted()
+		leak.Context = leak.RedactedContext()
+		leak.Secret = ""
+	}
 	leak.LeakString = strings.TrimSpace(leak.LeakString)
 	if len(leak.LeakString) > 512 {
 		leak.LeakString = "too long"
 	}
-	if b.Repos[leak.RepoURL] == nil {
-		b.Repos[leak.RepoURL] = &mailTemplateRepoStruct{
+	if len(leak.Context) > 4096 {
+		leak.Context = ""
+	}
+	if m.Repos[leak.RepoURL] == nil {
+		m.Repos[leak.RepoURL] = &mailTemplateRepoStruct{
 			RepoURL: leak.RepoURL,
-			Items:   []hungryfox.Leak{},
+			Items:   []mailTemplateLeak{},
 		}
 	}
-	b.Repos[leak.RepoURL].Items = append(b.Repos[leak.RepoURL].Items, leak)
-	b.Files[fmt.Sprintf("%s/%s", leak.RepoURL, leak.FilePath)] = struct{}{}
-	b.LeaksCount++
+	m.Repos[leak.RepoURL].Items = append(m.Repos[leak.RepoURL].Items, mailTemplateLeak{Leak: leak, DetailURL: detailURL})
+	m.Files[fmt.Sprintf("%s/%s", leak.RepoURL, leak.FilePath)] = struct{}{}
+	m.LeaksCount++
 }
 
-func (s *Sender) sendMessage(recipient string, messageData *mailTemplateStruct) error {
-	d := gomail.Dialer{
-		Host: s.Config.SMTPHost,
-		Port: s.Config.SMTPPort,
-		TLSConfig: &tls.Config{
-			InsecureSkipVerify: s.Config.InsecureTLS,
-			ServerName:         s.Config.SMTPHost,
-		},
-	}
-	if s.Config.Password != "" {
-		d.Auth = smtp.PlainAuth(
-			"",
-			s.Config.Username,
-			s.Config.Password,
-			s.Config.SMTPHost)
-	}
-
+func (s *Sender) sendMessage(e envelope, messageData *mailTemplateStruct) error {
 	m := gomail.NewMessage()
 	m.SetHeader("From", s.Config.From)
-	m.SetHeader("To", strings.Split(recipient, ",")...)
+	m.SetHeader("To", e.To...)
+	if len(e.CC) > 0 {
+		m.SetHeader("Cc", e.CC...)
+	}
 
 	var subject string
 	if len(messageData.Repos) == 1 {
@@ -105,5 +173,13 @@ func (s *Sender) sendMessage(recipient string, messageData *mailTemplateStruct)
 	m.AddAlternativeWriter("text/html", func(w io.Writer) error {
 		return s.template.Execute(w, messageData)
 	})
-	return d.DialAndSend(m)
+	return s.pool.send(envelopeFrom(s.Config.From), append(append([]string{}, e.To...), e.CC...), m)
+}
+
+// envelopeFrom - envelope sender is bare address of mail_from like "Hungry Fox <fox@example.com>"
+func envelopeFrom(from string) string {
+	if address, err := mail.ParseAddress(from); err == nil {
+		return address.Address
+	}
+	return from
 }
@@ -0,0 +1,121 @@
+package email
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// onlyMessage - the single message of batch
+func onlyMessage(b *batch) *message {
+	So(b.messages, ShouldHaveLength, 1)
+	for _, m := range b.messages {
+		return m
+	}
+	return nil
+}
+
+func TestBatchDetailURL(t *testing.T) {
+	leak := hungryfox.Leak{RepoURL: "https://github.com/backend/api", FilePath: ".env", LeakString: "  password=secret  "}
+	Convey("leaks are linked to web ui by fingerprint", t, func() {
+		b := (&Sender{AuditorEmail: "security@example.com", Config: &Config{UIURL: "https://hungryfox.example.com/"}}).batchMaker().(*batch)
+		b.Add(leak)
+		item := onlyMessage(b).Repos[leak.RepoURL].Items[0]
+		So(item.DetailURL, ShouldEqual, "https://hungryfox.example.com/ui/leaks/"+leak.Fingerprint())
+		So(item.LeakString, ShouldEqual, "password=secret")
+	})
+	Convey("no links without web ui", t, func() {
+		b := (&Sender{AuditorEmail: "security@example.com", Config: &Config{}}).batchMaker().(*batch)
+		b.Add(leak)
+		So(onlyMessage(b).Repos[leak.RepoURL].Items[0].DetailURL, ShouldBeEmpty)
+	})
+	Convey("secrets are masked if redact is enabled", t, func() {
+		secretLeak := leak
+		secretLeak.LeakString = "password=ghp_0123456789abcdef"
+		secretLeak.Secret = "ghp_0123456789abcdef"
+		secretLeak.Context = "# github\npassword=ghp_0123456789abcdef"
+		b := (&Sender{AuditorEmail: "security@example.com", Config: &Config{Redact: true}}).batchMaker().(*batch)
+		b.Add(secretLeak)
+		item := onlyMessage(b).Repos[leak.RepoURL].Items[0]
+		So(item.LeakString, ShouldEqual, "password=ghp*****")
+		So(item.Context, ShouldEqual, "# github\npassword=ghp*****")
+		So(item.Secret, ShouldBeEmpty)
+	})
+}
+
+func TestRoutes(t *testing.T) {
+	s := &Sender{
+		AuditorEmail: "digest@example.com, audit@example.com",
+		CC:           []string{"lead@example.com"},
+		Routes: []Route{
+			{Name: "critical", MinSeverity: hungryfox.SeverityCritical, To: []string{"secops@example.com"}},
+			{Name: "payments", Repos: []string{"payments/**"}, Patterns: []string{"stripe*"}, To: []string{"payments@example.com"}, CC: []string{"secops@example.com"}},
+		},
+		SendToAuthor:  true,
+		AuthorDomains: []string{"example.com"},
+		Config:        &Config{},
+	}
+	critical := hungryfox.Leak{RepoURL: "https://github.com/backend/api", PatternName: "aws", Severity: hungryfox.SeverityCritical, CommitEmail: "Alice@Example.com"}
+	stripe := hungryfox.Leak{RepoURL: "https://github.com/payments/gateway", PatternName: "stripe key", CommitEmail: "bob@contractor.io"}
+	other := hungryfox.Leak{RepoURL: "https://github.com/payments/gateway", PatternName: "password"}
+
+	Convey("the first matching route chooses recipients", t, func() {
+		So(s.Recipients(critical), ShouldResemble, []string{"secops@example.com", "alice@example.com"})
+		So(s.Recipients(stripe), ShouldResemble, []string{"payments@example.com", "cc:secops@example.com"})
+		So(s.Recipients(other), ShouldResemble, []string{"digest@example.com", "audit@example.com", "cc:lead@example.com"})
+	})
+
+	Convey("authors are not notified without allowed domains", t, func() {
+		noDomains := &Sender{AuditorEmail: s.AuditorEmail, Routes: s.Routes, SendToAuthor: true, Config: s.Config}
+		So(noDomains.Recipients(critical), ShouldResemble, []string{"secops@example.com"})
+	})
+
+	Convey("leaks are grouped by recipients", t, func() {
+		b := s.batchMaker().(*batch)
+		for _, leak := range []hungryfox.Leak{critical, stripe, other, other} {
+			b.Add(leak)
+		}
+		So(b.messages, ShouldHaveLength, 4)
+		So(b.messages["secops@example.com;"].LeaksCount, ShouldEqual, 1)
+		So(b.messages["alice@example.com;"].LeaksCount, ShouldEqual, 1)
+		So(b.messages["payments@example.com;secops@example.com"].LeaksCount, ShouldEqual, 1)
+		So(b.messages["digest@example.com,audit@example.com;lead@example.com"].LeaksCount, ShouldEqual, 2)
+	})
+}
+
+type fakeAudit struct {
+	events []hungryfox.AuditEvent
+}
+
+func (f *fakeAudit) Record(event hungryfox.AuditEvent) {
+	f.events = append(f.events, event)
+}
+
+func TestAudit(t *testing.T) {
+	Convey("delivery of message is recorded for every leak", t, func() {
+		audit := &fakeAudit{}
+		s := &Sender{AuditorEmail: "security@example.com", CC: []string{"lead@example.com"}, Config: &Config{Redact: true}, Audit: audit}
+		leaks := []hungryfox.Leak{
+			{RepoURL: "https://github.com/backend/api", FilePath: ".env", LeakString: "password=secret", Secret: "secret"},
+			{RepoURL: "https://github.com/backend/api", FilePath: "config.yml", LeakString: "token: 123"},
+		}
+		b := s.batchMaker().(*batch)
+		for _, leak := range leaks {
+			b.Add(leak)
+		}
+		s.audit(onlyMessage(b), nil)
+		So(audit.events, ShouldHaveLength, 2)
+		So(audit.events[0].Result, ShouldEqual, hungryfox.AuditOK)
+		So(audit.events[0].Sender, ShouldEqual, "email")
+		So(audit.events[0].Recipients, ShouldResemble, []string{"security@example.com", "cc:lead@example.com"})
+		So(audit.events[0].Fingerprint, ShouldEqual, leaks[0].Fingerprint())
+		So(audit.events[1].Fingerprint, ShouldEqual, leaks[1].Fingerprint())
+
+		s.audit(onlyMessage(b), fmt.Errorf("relay is down"))
+		So(audit.events[2].Result, ShouldEqual, hungryfox.AuditFailed)
+		So(audit.events[2].Error, ShouldEqual, "relay is down")
+	})
+}
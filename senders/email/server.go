@@ -1,16 +1,17 @@
 package email
 
 import (
-	"crypto/tls"
 	"fmt"
 	"html/template"
-	"net/smtp"
+	"io/ioutil"
+	"sync"
 	"time"
 
 	"github.com/AlexAkulov/hungryfox"
 
 	"github.com/facebookgo/muster"
 	"github.com/rs/zerolog"
+	"gopkg.in/tomb.v2"
 )
 
 // Config - SMTP settings
@@ -21,58 +22,102 @@ type Config struct {
 	InsecureTLS bool
 	Username    string
 	Password    string
-	Delay       time.Duration
+	// PasswordSource - password is read on every connection if set, so a rotated password of vault is used
+	PasswordSource func() (string, error)
+	Delay          time.Duration
+	// TemplateFile - html template of message, default template is used if empty
+	TemplateFile string
+	// UIURL - address of web UI, leaks are linked to their pages if set
+	UIURL string
+	// Redact - mask secrets extracted by patterns, messages may be stored in mailboxes for years
+	Redact bool
+	// TLSMode - starttls, implicit or none, starttls if empty
+	TLSMode string
+	// AuthMethod - plain, login or xoauth2, plain if empty
+	AuthMethod string
+	// OAuth2Token - access token for xoauth2
+	OAuth2Token func() (string, error)
+	// MaxPerMinute - limit of messages per minute, unlimited if zero
+	MaxPerMinute int
 }
 
 // Sender - send email
 type Sender struct {
-	AuditorEmail string
-	Config       *Config
-	Log          zerolog.Logger
-	template     *template.Template
-	muster       *muster.Client
+	AuditorEmail string   // comma separated addresses which receive leaks not matching routes
+	CC           []string // copies of messages to AuditorEmail
+	Routes       []Route  // the first matching route chooses recipients instead of AuditorEmail
+	SendToAuthor bool     // commit authors receive their own leaks
+	// AuthorDomains - only authors with email in these domains are notified, nobody if empty
+	AuthorDomains []string
+	Config        *Config
+	// Audit - deliveries of batched messages, nothing is recorded if nil
+	Audit    hungryfox.IAuditLog
+	Log      zerolog.Logger
+	template *template.Template
+	muster   *muster.Client
+	pool     *pool
+
+	digestMutex sync.Mutex
+	digests     map[string]*message // leaks of digest routes till the next time of their schedule
+	tomb        tomb.Tomb
 }
 
 // Start - start sender
 func (s *Sender) Start() error {
-	t, err := smtp.Dial(fmt.Sprintf("%s:%d", s.Config.SMTPHost, s.Config.SMTPPort))
-	if err != nil {
+	var err error
+	if s.template, err = loadTemplate(s.Config.TemplateFile); err != nil {
 		return err
 	}
-	defer t.Close()
-	// Test TLS handshake
-	if err := t.StartTLS(&tls.Config{
-		InsecureSkipVerify: s.Config.InsecureTLS,
-		ServerName:         s.Config.SMTPHost,
-	}); err != nil {
-		return err
-	}
-	// Test authentication
-	if s.Config.Password != "" {
-		if err := t.Auth(smtp.PlainAuth(
-			"",
-			s.Config.Username,
-			s.Config.Password,
-			s.Config.SMTPHost,
-		)); err != nil {
-			return err
-		}
-	}
-	if s.template, err = template.New("mail").Parse(defaultTemplate); err != nil {
+	// Test TLS handshake and authentication, the connection is reused by the first message
+	s.pool = &pool{config: s.Config}
+	if s.pool.client, err = s.pool.connect(); err != nil {
 		return err
 	}
+	s.pool.lastUsed = time.Now()
 	s.muster = &muster.Client{
 		MaxBatchSize:         100,
 		MaxConcurrentBatches: 1,
 		BatchTimeout:         s.Config.Delay,
 		BatchMaker:           s.batchMaker,
 	}
-	return s.muster.Start()
+	if err := s.muster.Start(); err != nil {
+		return err
+	}
+	if s.hasDigests() {
+		s.tomb.Go(s.runDigests)
+	}
+	return nil
 }
 
-// Stop - stop sender
+// loadTemplate - parse and validate template against catalog of variables
+func loadTemplate(templateFile string) (*template.Template, error) {
+	text := defaultTemplate
+	if templateFile != "" {
+		data, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read template with: %v", err)
+		}
+		text = string(data)
+	}
+	t, err := template.New("mail").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTemplate(t.Tree, templateData); err != nil {
+		return nil, fmt.Errorf("bad template: %v", err)
+	}
+	return t, nil
+}
+
+// Stop - stop sender, collected digests are sent
 func (s *Sender) Stop() error {
-	return s.muster.Stop()
+	err := s.muster.Stop()
+	if s.hasDigests() {
+		s.tomb.Kill(nil)
+		s.tomb.Wait()
+	}
+	s.pool.Close()
+	return err
 }
 
 // Send - send leaks
@@ -0,0 +1,315 @@
+package email
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLS modes of smtp connection
+const (
+	TLSStartTLS = "starttls" // STARTTLS is required, it's the default
+	TLSImplicit = "implicit" // TLS from the first byte, usually port 465
+	TLSNone     = "none"     // plain connection for local relays
+)
+
+// Authentication methods of smtp connection
+const (
+	AuthPlain   = "plain" // it's the default
+	AuthLogin   = "login"
+	AuthXOAuth2 = "xoauth2"
+)
+
+// idleTimeout - pooled connection is closed when it was not used for this time, relays drop idle connections
+const idleTimeout = time.Minute
+
+// pool - one reused smtp connection with limit of messages per minute
+type pool struct {
+	config *Config
+	dial   func() (net.Conn, error) // tcp connection to relay, it's replaced in tests
+
+	mutex    sync.Mutex
+	client   *smtp.Client
+	lastUsed time.Time
+	sent     []time.Time // send times of the last minute
+}
+
+func (p *pool) tlsConfig() *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify: p.config.InsecureTLS,
+		ServerName:         p.config.SMTPHost,
+	}
+}
+
+func (p *pool) auth() (smtp.Auth, error) {
+	switch p.config.AuthMethod {
+	case AuthXOAuth2:
+		if p.config.OAuth2Token == nil {
+			return nil, fmt.Errorf("oauth2 token source is required for xoauth2")
+		}
+		return &xoauth2Auth{username: p.config.Username, token: p.config.OAuth2Token}, nil
+	case AuthLogin:
+		password, err := p.password()
+		if password == "" || err != nil {
+			return nil, err
+		}
+		return &loginAuth{username: p.config.Username, password: password}, nil
+	case AuthPlain, "":
+		password, err := p.password()
+		if password == "" || err != nil {
+			return nil, err
+		}
+		return smtp.PlainAuth("", p.config.Username, password, p.config.SMTPHost), nil
+	}
+	return nil, fmt.Errorf("unknown smtp auth '%s'", p.config.AuthMethod)
+}
+
+// password - current password of source or password of config
+func (p *pool) password() (string, error) {
+	if p.config.PasswordSource == nil {
+		return p.config.Password, nil
+	}
+	password, err := p.config.PasswordSource()
+	if err != nil {
+		return "", fmt.Errorf("can't get smtp password: %v", err)
+	}
+	return password, nil
+}
+
+// connect - new connection with TLS of mode and authentication
+func (p *pool) connect() (*smtp.Client, error) {
+	dial := p.dial
+	if dial == nil {
+		dial = func() (net.Conn, error) {
+			return net.DialTimeout("tcp", net.JoinHostPort(p.config.SMTPHost, strconv.Itoa(p.config.SMTPPort)), 30*time.Second)
+		}
+	}
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	if p.config.TLSMode == TLSImplicit {
+		conn = tls.Client(conn, p.tlsConfig())
+	}
+	c, err := smtp.NewClient(conn, p.config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if p.config.TLSMode == TLSStartTLS || p.config.TLSMode == "" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			c.Close()
+			return nil, fmt.Errorf("smtp server doesn't support STARTTLS, set tls_mode")
+		}
+		if err := c.StartTLS(p.tlsConfig()); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	auth, err := p.auth()
+	if err == nil && auth != nil {
+		err = c.Auth(auth)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// wait - block until one more message fits into limit of messages per minute
+func (p *pool) wait() {
+	if p.config.MaxPerMinute <= 0 {
+		return
+	}
+	for {
+		now := time.Now()
+		for len(p.sent) > 0 && now.Sub(p.sent[0]) >= time.Minute {
+			p.sent = p.sent[1:]
+		}
+		if len(p.sent) < p.config.MaxPerMinute {
+			p.sent = append(p.sent, now)
+			return
+		}
+		time.Sleep(p.sent[0].Add(time.Minute).Sub(now))
+	}
+}
+
+// send - deliver message over pooled connection, it is reconnected once if the relay has dropped it
+func (p *pool) send(from string, to []string, message io.WriterTo) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.wait()
+	if p.client != nil && time.Since(p.lastUsed) > idleTimeout {
+		p.closeClient()
+	}
+	if p.client != nil && p.client.Reset() != nil {
+		p.closeClient()
+	}
+	if p.client == nil {
+		c, err := p.connect()
+		if err != nil {
+			return err
+		}
+		p.client = c
+	}
+	p.lastUsed = time.Now()
+	if err := p.deliver(from, to, message); err != nil {
+		p.closeClient()
+		return err
+	}
+	return nil
+}
+
+func (p *pool) deliver(from string, to []string, message io.WriterTo) error {
+	if err := p.client.Mail(from); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err := p.client.Rcpt(address); err != nil {
+			return err
+		}
+	}
+	w, err := p.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := message.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (p *pool) closeClient() {
+	if p.client == nil {
+		return
+	}
+	if err := p.client.Quit(); err != nil {
+		p.client.Close()
+	}
+	p.client = nil
+}
+
+// Close - close pooled connection
+func (p *pool) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closeClient()
+}
+
+// xoauth2Auth - SASL XOAUTH2 of Office 365 and Gmail
+type xoauth2Auth struct {
+	username string
+	token    func() (string, error)
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	token, err := a.token()
+	if err != nil {
+		return "", nil, fmt.Errorf("can't get oauth2 token: %v", err)
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + token + "\x01\x01"), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// server sends json with error, empty response gets the final error code
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// loginAuth - SASL LOGIN of relays without PLAIN
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected server challenge '%s'", fromServer)
+}
+
+// OAuth2Token - access token of refresh token grant (Gmail) or client credentials grant (Office 365),
+// it is cached until a minute before expiration
+type OAuth2Token struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string // client credentials grant is used if empty
+	Scopes       []string
+	Client       *http.Client
+
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Token - valid access token
+func (o *OAuth2Token) Token() (string, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.token != "" && time.Now().Before(o.expires) {
+		return o.token, nil
+	}
+	form := url.Values{"client_id": {o.ClientID}, "client_secret": {o.ClientSecret}}
+	if o.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", o.RefreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.PostForm(o.TokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	result := struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("can't parse token response: %s", resp.Status)
+	}
+	if result.AccessToken == "" {
+		if result.Error != "" {
+			return "", fmt.Errorf("%s: %s", result.Error, result.ErrorDescription)
+		}
+		return "", errors.New("no access_token in token response")
+	}
+	o.token = result.AccessToken
+	o.expires = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return o.token, nil
+}
@@ -0,0 +1,164 @@
+package email
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeRelay - smtp server without tls which records commands and accepted messages
+type fakeRelay struct {
+	connections int
+	commands    []string
+	messages    []string
+}
+
+func (f *fakeRelay) dial() (net.Conn, error) {
+	client, server := net.Pipe()
+	f.connections++
+	go f.serve(textproto.NewConn(server))
+	return client, nil
+}
+
+func (f *fakeRelay) serve(c *textproto.Conn) {
+	defer c.Close()
+	c.PrintfLine("220 relay.example.com ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		f.commands = append(f.commands, line)
+		switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+		case "EHLO":
+			c.PrintfLine("250-relay.example.com")
+			c.PrintfLine("250 AUTH LOGIN XOAUTH2")
+		case "AUTH":
+			if strings.Fields(line)[1] == "LOGIN" {
+				c.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				user, _ := c.ReadLine()
+				c.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				password, _ := c.ReadLine()
+				f.commands = append(f.commands, user, password)
+			}
+			c.PrintfLine("235 ok")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			lines, _ := c.ReadDotLines()
+			f.messages = append(f.messages, strings.Join(lines, "\n"))
+			c.PrintfLine("250 queued")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		case "DROP":
+			return
+		default:
+			c.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	Convey("messages are sent over one connection", t, func() {
+		relay := &fakeRelay{}
+		p := &pool{config: &Config{SMTPHost: "relay.example.com", TLSMode: TLSNone, AuthMethod: AuthLogin, Username: "fox", Password: "qwerty"}, dial: relay.dial}
+		So(p.send("fox@example.com", []string{"a@example.com", "b@example.com"}, strings.NewReader("Subject: 1\r\n\r\nfirst")), ShouldBeNil)
+		So(p.send("fox@example.com", []string{"a@example.com"}, strings.NewReader("Subject: 2\r\n\r\nsecond")), ShouldBeNil)
+		p.Close()
+		So(relay.connections, ShouldEqual, 1)
+		So(relay.messages, ShouldResemble, []string{"Subject: 1\n\nfirst", "Subject: 2\n\nsecond"})
+		So(relay.commands, ShouldContain, "AUTH LOGIN")
+		So(relay.commands, ShouldContain, base64.StdEncoding.EncodeToString([]byte("qwerty")))
+		So(relay.commands, ShouldContain, "RCPT TO:<b@example.com>")
+		So(relay.commands, ShouldContain, "RSET")
+	})
+
+	Convey("dropped connection is reopened", t, func() {
+		relay := &fakeRelay{}
+		p := &pool{config: &Config{SMTPHost: "relay.example.com", TLSMode: TLSNone}, dial: relay.dial}
+		So(p.send("fox@example.com", []string{"a@example.com"}, strings.NewReader("first")), ShouldBeNil)
+		p.client.Text.PrintfLine("DROP")
+		So(p.send("fox@example.com", []string{"a@example.com"}, strings.NewReader("second")), ShouldBeNil)
+		p.Close()
+		So(relay.connections, ShouldEqual, 2)
+		So(relay.messages, ShouldHaveLength, 2)
+	})
+
+	Convey("starttls is required by default", t, func() {
+		relay := &fakeRelay{}
+		p := &pool{config: &Config{SMTPHost: "relay.example.com"}, dial: relay.dial}
+		_, err := p.connect()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "STARTTLS")
+	})
+
+	Convey("xoauth2 sends bearer token", t, func() {
+		relay := &fakeRelay{}
+		token := func() (string, error) { return "ya29.token", nil }
+		p := &pool{config: &Config{SMTPHost: "relay.example.com", TLSMode: TLSNone, AuthMethod: AuthXOAuth2, Username: "fox@example.com", OAuth2Token: token}, dial: relay.dial}
+		c, err := p.connect()
+		So(err, ShouldBeNil)
+		c.Quit()
+		So(relay.commands, ShouldContain, "AUTH XOAUTH2 "+base64.StdEncoding.EncodeToString([]byte("user=fox@example.com\x01auth=Bearer ya29.token\x01\x01")))
+	})
+
+	Convey("password of source is read on connect", t, func() {
+		relay := &fakeRelay{}
+		password := func() (string, error) { return "rotated", nil }
+		p := &pool{config: &Config{SMTPHost: "relay.example.com", TLSMode: TLSNone, AuthMethod: AuthLogin, Username: "fox", Password: "old", PasswordSource: password}, dial: relay.dial}
+		c, err := p.connect()
+		So(err, ShouldBeNil)
+		c.Quit()
+		So(relay.commands, ShouldContain, base64.StdEncoding.EncodeToString([]byte("rotated")))
+	})
+
+	Convey("messages of the last minute are limited", t, func() {
+		p := &pool{config: &Config{MaxPerMinute: 2}}
+		p.sent = []time.Time{time.Now().Add(-2 * time.Minute), time.Now().Add(-time.Second)}
+		p.wait()
+		So(p.sent, ShouldHaveLength, 2)
+	})
+}
+
+func TestOAuth2Token(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		r.ParseForm()
+		if r.Form.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client", "error_description": "bad secret"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": r.Form.Get("grant_type") + "-token", "expires_in": 3600})
+	}))
+	defer server.Close()
+
+	Convey("access token is cached", t, func() {
+		o := &OAuth2Token{TokenURL: server.URL, ClientID: "fox", ClientSecret: "secret", RefreshToken: "1//refresh"}
+		token, err := o.Token()
+		So(err, ShouldBeNil)
+		So(token, ShouldEqual, "refresh_token-token")
+		o.Token()
+		So(requests, ShouldEqual, 1)
+	})
+
+	Convey("client credentials grant is used without refresh token", t, func() {
+		token, err := (&OAuth2Token{TokenURL: server.URL, ClientID: "fox", ClientSecret: "secret"}).Token()
+		So(err, ShouldBeNil)
+		So(token, ShouldEqual, "client_credentials-token")
+	})
+
+	Convey("errors of token endpoint are returned", t, func() {
+		_, err := (&OAuth2Token{TokenURL: server.URL, ClientID: "fox"}).Token()
+		So(err.Error(), ShouldEqual, "invalid_client: bad secret")
+	})
+}
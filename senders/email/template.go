@@ -140,9 +140,12 @@ const defaultTemplate = `
           <p><a style="color: rgb(216, 119, 0); font-size: 14px;" href="{{ .RepoURL }}/blob/{{ .CommitHash }}/{{ .FilePath }}">{{ .FilePath }}</a>
           </p>
           <p style="background-color:#f9f9f9; font-size: 14px; font-family: 'Courier New'; color: #111111; font-weight:bold;">{{ .LeakString }}</p>
+          {{ if .Context }}<pre style="background-color:#f9f9f9; font-size: 12px; font-family: 'Courier New'; color: #555555; white-space: pre-wrap;">{{ .Context }}</pre>{{ end }}
           <p style="font-size: 12px; text-align: right;">Commit
             <i>{{ .CommitHash }}</i> by
             <a style="color:rgb(216, 119, 0);" href="mailto:{{ .CommitEmail }}">{{ .CommitAuthor }}</a> ({{ .TimeStamp.Format "15:04:05 02.01.2006" }})</p>
+          {{ if .Signed }}<p style="font-size: 12px; text-align: right;">Подпись коммита: {{ .Signed }}{{ if .SigningKey }} <i>{{ .SigningKey }}</i>{{ end }}</p>{{ end }}
+          {{ if .DetailURL }}<p style="font-size: 12px; text-align: right;"><a style="color:rgb(216, 119, 0);" href="{{ .DetailURL }}">Подробнее</a></p>{{ end }}
 
         </td>
       </tr>
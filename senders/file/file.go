@@ -1,14 +1,21 @@
 package file
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/AlexAkulov/hungryfox"
 )
 
 type File struct {
 	LeaksFile string
+
+	mutex sync.Mutex
 }
 
 func (self *File) Start() error {
@@ -20,6 +27,8 @@ func (self *File) Stop() error {
 }
 
 func (self *File) Send(leak hungryfox.Leak) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
 	f, err := os.OpenFile(self.LeaksFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return err
@@ -30,3 +39,58 @@ func (self *File) Send(leak hungryfox.Leak) error {
 	f.WriteString("\n")
 	return nil
 }
+
+func (self *File) Recipients(leak hungryfox.Leak) []string {
+	return []string{self.LeaksFile}
+}
+
+// Rewrite - replace leaks of file with result of change, file is kept if result is nil.
+// Leaks are not sent meanwhile
+func (self *File) Rewrite(change func([]hungryfox.Leak) []hungryfox.Leak) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	leaks := []hungryfox.Leak{}
+	data, err := ioutil.ReadFile(self.LeaksFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		leak := hungryfox.Leak{}
+		if err := decoder.Decode(&leak); err != nil {
+			return err
+		}
+		leaks = append(leaks, leak)
+	}
+	leaks = change(leaks)
+	if leaks == nil {
+		return nil
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(self.LeaksFile), "."+filepath.Base(self.LeaksFile))
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, leak := range leaks {
+		line, _ := json.Marshal(leak)
+		writer.Write(line)
+		writer.WriteString("\n")
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), self.LeaksFile)
+}
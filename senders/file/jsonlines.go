@@ -0,0 +1,247 @@
+package file
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+)
+
+// SchemaVersion - version of Record, fields are only added in new versions
+const SchemaVersion = 3
+
+// Record - canonical json of leak for log shippers, every field is always present
+type Record struct {
+	Schema            int                `json:"schema"`
+	Fingerprint       string             `json:"fingerprint"`
+	SecretFingerprint string             `json:"secret_fingerprint"`
+	FoundAt           time.Time          `json:"found_at"`
+	Kind              string             `json:"kind"`
+	Rule              string             `json:"rule"`
+	Pattern           string             `json:"pattern"`
+	Severity          string             `json:"severity"`
+	Repo              string             `json:"repo"`
+	RepoPath          string             `json:"repo_path"`
+	Commit            string             `json:"commit"`
+	CommitTime        time.Time          `json:"commit_time"`
+	Author            string             `json:"author"`
+	AuthorEmail       string             `json:"author_email"`
+	CommitterEmail    string             `json:"committer_email"`
+	File              string             `json:"file"`
+	Line              int                `json:"line"`
+	Language          string             `json:"language"`
+	Leak              string             `json:"leak"`
+	Secret            string             `json:"secret"`
+	Source            string             `json:"source"`
+	Verified          string             `json:"verified"`
+	Hashed            bool               `json:"hashed"`
+	Receipt           *hungryfox.Receipt `json:"receipt"`
+	Signed            string             `json:"signed"`
+	SigningKey        string             `json:"signing_key"`
+	Context           string             `json:"context"`
+}
+
+// NewRecord - record of leak, defaults are filled: medium severity and committer is author if it is not set
+func NewRecord(leak hungryfox.Leak) Record {
+	record := Record{
+		Schema:            SchemaVersion,
+		Fingerprint:       leak.Fingerprint(),
+		SecretFingerprint: leak.SecretFingerprint(),
+		FoundAt:           leak.FoundAt,
+		Kind:              leak.Kind,
+		Rule:              leak.PatternName,
+		Pattern:           leak.Regexp,
+		Severity:          leak.Severity,
+		Repo:              leak.RepoURL,
+		RepoPath:          leak.RepoPath,
+		Commit:            leak.CommitHash,
+		CommitTime:        leak.TimeStamp,
+		Author:            leak.CommitAuthor,
+		AuthorEmail:       leak.CommitEmail,
+		CommitterEmail:    leak.CommitterEmail,
+		File:              leak.FilePath,
+		Line:              leak.Line,
+		Language:          leak.Language,
+		Leak:              leak.LeakString,
+		Secret:            leak.Secret,
+		Source:            leak.Source,
+		Verified:          leak.Verified,
+		Hashed:            leak.Hashed,
+		Receipt:           leak.Receipt,
+		Signed:            leak.Signed,
+		SigningKey:        leak.SigningKey,
+		Context:           leak.Context,
+	}
+	if record.Severity == "" {
+		record.Severity = hungryfox.SeverityMedium
+	}
+	if record.CommitterEmail == "" {
+		record.CommitterEmail = record.AuthorEmail
+	}
+	return record
+}
+
+// JSONLines - write every leak as Record line to Path, the file is rotated by size and age
+type JSONLines struct {
+	Path     string
+	MaxSize  int64         // bytes, file is not rotated by size if zero
+	MaxAge   time.Duration // since file was opened, file is not rotated by age if zero
+	MaxFiles int           // rotated files which are kept, all if zero
+	Gzip     bool          // compress rotated files
+	Clock    clock.Clock   // system clock if nil
+
+	mutex  sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func (j *JSONLines) Start() error {
+	if j.Path == "" {
+		return fmt.Errorf("json lines path is required")
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.open()
+}
+
+func (j *JSONLines) Stop() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+func (j *JSONLines) Send(leak hungryfox.Leak) error {
+	line, err := json.Marshal(NewRecord(leak))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.file == nil {
+		return fmt.Errorf("json lines file %s is not open", j.Path)
+	}
+	if j.needRotate(int64(len(line))) {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	return err
+}
+
+func (j *JSONLines) Recipients(leak hungryfox.Leak) []string {
+	return []string{j.Path}
+}
+
+func (j *JSONLines) open() error {
+	if err := os.MkdirAll(filepath.Dir(j.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.Path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.file, j.size, j.opened = f, info.Size(), clock.Or(j.Clock).Now()
+	return nil
+}
+
+// needRotate - line doesn't fit into MaxSize or file is older than MaxAge, empty file is never rotated
+func (j *JSONLines) needRotate(lineSize int64) bool {
+	if j.size == 0 {
+		return false
+	}
+	if j.MaxSize > 0 && j.size+lineSize > j.MaxSize {
+		return true
+	}
+	return j.MaxAge > 0 && clock.Or(j.Clock).Now().Sub(j.opened) >= j.MaxAge
+}
+
+// rotate - rename current file to Path.<time>, compress it and remove old rotated files
+func (j *JSONLines) rotate() error {
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	j.file = nil
+	rotated := j.Path + "." + clock.Or(j.Clock).Now().UTC().Format("20060102T150405.000Z")
+	if err := os.Rename(j.Path, rotated); err != nil {
+		return err
+	}
+	if err := j.open(); err != nil {
+		return err
+	}
+	if j.Gzip {
+		if err := compress(rotated); err != nil {
+			return fmt.Errorf("can't compress %s: %v", rotated, err)
+		}
+	}
+	return j.removeOld()
+}
+
+func (j *JSONLines) removeOld() error {
+	if j.MaxFiles <= 0 {
+		return nil
+	}
+	files, err := filepath.Glob(j.Path + ".*")
+	if err != nil {
+		return err
+	}
+	// names of rotated files are sorted by time of rotation
+	sort.Strings(files)
+	for len(files) > j.MaxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// compress - replace file by file.gz
+func compress(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(dst)
+	if _, err := io.Copy(writer, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,116 @@
+package file
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func readRecords(name string) []Record {
+	f, err := os.Open(name)
+	So(err, ShouldBeNil)
+	defer f.Close()
+	var data []byte
+	if strings.HasSuffix(name, ".gz") {
+		reader, err := gzip.NewReader(f)
+		So(err, ShouldBeNil)
+		data, err = ioutil.ReadAll(reader)
+		So(err, ShouldBeNil)
+	} else {
+		data, err = ioutil.ReadAll(f)
+		So(err, ShouldBeNil)
+	}
+	records := []Record{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		record := Record{}
+		So(json.Unmarshal([]byte(line), &record), ShouldBeNil)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRecord(t *testing.T) {
+	Convey("every field is present with defaults", t, func() {
+		leak := hungryfox.Leak{RepoURL: "https://github.com/backend/api", PatternName: "aws", CommitEmail: "alice@example.com"}
+		data, err := json.Marshal(NewRecord(leak))
+		So(err, ShouldBeNil)
+		fields := map[string]interface{}{}
+		So(json.Unmarshal(data, &fields), ShouldBeNil)
+		So(fields, ShouldHaveLength, 27)
+		So(fields["schema"], ShouldEqual, SchemaVersion)
+		So(fields["fingerprint"], ShouldEqual, leak.Fingerprint())
+		So(fields["severity"], ShouldEqual, hungryfox.SeverityMedium)
+		So(fields["committer_email"], ShouldEqual, "alice@example.com")
+		So(fields["secret"], ShouldEqual, "")
+		So(fields["receipt"], ShouldBeNil)
+	})
+}
+
+func TestJSONLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-jsonlines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "leaks.jsonl")
+	leak := hungryfox.Leak{RepoURL: "https://github.com/backend/api", PatternName: "aws", LeakString: strings.Repeat("x", 100)}
+	lineSize := func() int64 {
+		data, _ := json.Marshal(NewRecord(leak))
+		return int64(len(data) + 1)
+	}()
+	fakeClock := clock.NewFake(time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC), time.Millisecond)
+	j := &JSONLines{Path: path, MaxSize: 2 * lineSize, MaxAge: time.Hour, MaxFiles: 2, Gzip: true, Clock: fakeClock}
+
+	Convey("lines are appended until max size", t, func() {
+		So(j.Start(), ShouldBeNil)
+		So(j.Send(leak), ShouldBeNil)
+		So(j.Send(leak), ShouldBeNil)
+		So(readRecords(path), ShouldHaveLength, 2)
+		rotated, _ := filepath.Glob(path + ".*")
+		So(rotated, ShouldBeEmpty)
+	})
+
+	Convey("full file is rotated and compressed", t, func() {
+		So(j.Send(leak), ShouldBeNil)
+		So(readRecords(path), ShouldHaveLength, 1)
+		rotated, _ := filepath.Glob(path + ".*")
+		So(rotated, ShouldHaveLength, 1)
+		So(rotated[0], ShouldEndWith, ".gz")
+		So(readRecords(rotated[0]), ShouldHaveLength, 2)
+	})
+
+	Convey("old file is rotated", t, func() {
+		fakeClock.Set(fakeClock.Now().Add(time.Hour))
+		So(j.Send(leak), ShouldBeNil)
+		So(readRecords(path), ShouldHaveLength, 1)
+		rotated, _ := filepath.Glob(path + ".*")
+		So(rotated, ShouldHaveLength, 2)
+	})
+
+	Convey("only max files are kept", t, func() {
+		So(j.Send(leak), ShouldBeNil)
+		So(j.Send(leak), ShouldBeNil)
+		rotated, _ := filepath.Glob(path + ".*")
+		So(rotated, ShouldHaveLength, 2)
+	})
+
+	Convey("size of existing file is counted after restart", t, func() {
+		So(j.Stop(), ShouldBeNil)
+		So(j.Start(), ShouldBeNil)
+		So(j.Send(leak), ShouldBeNil)
+		So(readRecords(path), ShouldHaveLength, 2)
+		So(j.Send(leak), ShouldBeNil)
+		So(readRecords(path), ShouldHaveLength, 1)
+		So(j.Stop(), ShouldBeNil)
+	})
+}
@@ -0,0 +1,101 @@
+package spool
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// IngestPath - path of central instance which receives envelopes
+const IngestPath = "/api/ingest"
+
+const rejectedExt = ".rejected"
+
+// Forwarder - ship spooled envelopes to central instance in order they were written
+type Forwarder struct {
+	Dir   string
+	URL   string
+	Token string
+	// TokenSource - token is read before every post if set, so a rotated token of vault is used
+	TokenSource func() (string, error)
+	Client      *http.Client
+	Log         zerolog.Logger
+}
+
+// Forward - send all spooled envelopes, it stops on the first delivery error to keep the order,
+// envelopes rejected by receiver are renamed so they don't block the rest
+func (f *Forwarder) Forward() (int, error) {
+	files, err := List(f.Dir)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return sent, err
+		}
+		if _, err := ParseEnvelope(data); err != nil {
+			f.reject(file, err)
+			continue
+		}
+		status, err := f.post(data)
+		if err != nil {
+			return sent, err
+		}
+		switch {
+		case status/100 == 2, status == http.StatusConflict:
+			// conflict means envelope was delivered before but not removed
+			if err := os.Remove(file); err != nil {
+				return sent, err
+			}
+			sent++
+		case status == http.StatusBadRequest:
+			f.reject(file, fmt.Errorf("rejected by receiver"))
+		default:
+			return sent, fmt.Errorf("receiver returned %d", status)
+		}
+	}
+	return sent, nil
+}
+
+func (f *Forwarder) post(data []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(f.URL, "/")+IngestPath, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token := f.Token
+	if f.TokenSource != nil {
+		if token, err = f.TokenSource(); err != nil {
+			return 0, fmt.Errorf("can't get forward token: %v", err)
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func (f *Forwarder) reject(file string, reason error) {
+	f.Log.Error().Str("file", file).Str("error", reason.Error()).Msg("envelope rejected")
+	if err := os.Rename(file, strings.TrimSuffix(file, envelopeExt)+rejectedExt); err != nil {
+		f.Log.Error().Str("file", file).Str("error", err.Error()).Msg("can't move rejected envelope")
+	}
+}
@@ -0,0 +1,130 @@
+package spool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+)
+
+// EnvelopeVersion - version of envelope format, receiver rejects unknown versions
+const EnvelopeVersion = 1
+
+const envelopeExt = ".json"
+
+// Envelope - spooled leaks of edge instance, ID is unique so repeated delivery can be detected
+type Envelope struct {
+	Version   int              `json:"version"`
+	ID        string           `json:"id"`
+	Source    string           `json:"source"`
+	CreatedAt time.Time        `json:"created_at"`
+	Leaks     []hungryfox.Leak `json:"leaks"`
+}
+
+// Spool - write every leak as envelope file into Dir, files are removed by forward after delivery
+type Spool struct {
+	Dir    string
+	Source string
+	Clock  clock.Clock // system clock if nil, ids of envelopes are derived from it
+}
+
+func (s *Spool) Start() error {
+	if s.Dir == "" {
+		return fmt.Errorf("spool dir is required")
+	}
+	if s.Source == "" {
+		s.Source, _ = os.Hostname()
+	}
+	return os.MkdirAll(s.Dir, 0700)
+}
+
+func (s *Spool) Stop() error {
+	return nil
+}
+
+func (s *Spool) Send(leak hungryfox.Leak) error {
+	now := clock.Or(s.Clock).Now().UTC()
+	envelope := Envelope{
+		Version:   EnvelopeVersion,
+		ID:        fmt.Sprintf("%s-%d-%s", s.Source, now.UnixNano(), leak.Fingerprint()),
+		Source:    s.Source,
+		CreatedAt: now,
+		Leaks:     []hungryfox.Leak{leak},
+	}
+	return s.write(envelope)
+}
+
+func (s *Spool) Recipients(leak hungryfox.Leak) []string {
+	return []string{s.Dir}
+}
+
+// write - envelope is written to temp file and renamed, so forward never reads partial files
+func (s *Spool) write(envelope Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%020d-%s", envelope.CreatedAt.UnixNano(), envelope.Leaks[0].Fingerprint())
+	tmp, err := ioutil.TempFile(s.Dir, "."+name)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.Dir, name+envelopeExt))
+}
+
+// List - spooled envelope files, oldest first
+func List(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || filepath.Ext(f.Name()) != envelopeExt {
+			continue
+		}
+		result = append(result, filepath.Join(dir, f.Name()))
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// ReadEnvelope - read and check envelope file
+func ReadEnvelope(file string) (*Envelope, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEnvelope(data)
+}
+
+// ParseEnvelope - decode envelope and check its version
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	envelope := &Envelope{}
+	if err := json.Unmarshal(data, envelope); err != nil {
+		return nil, fmt.Errorf("can't parse envelope: %v", err)
+	}
+	if envelope.Version != EnvelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", envelope.Version)
+	}
+	if envelope.ID == "" {
+		return nil, fmt.Errorf("envelope id is required")
+	}
+	return envelope, nil
+}
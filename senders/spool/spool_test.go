@@ -0,0 +1,86 @@
+package spool
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/rs/zerolog"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSpool(t *testing.T) {
+	Convey("leaks are spooled and forwarded", t, func() {
+		dir, err := ioutil.TempDir("", "hungryfox-spool")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		s := &Spool{Dir: filepath.Join(dir, "spool"), Source: "edge-1"}
+		So(s.Start(), ShouldBeNil)
+		So(s.Send(hungryfox.Leak{RepoURL: "https://gitlab.local/backend/api", PatternName: "aws"}), ShouldBeNil)
+		So(s.Send(hungryfox.Leak{RepoURL: "https://gitlab.local/backend/api", PatternName: "gcp"}), ShouldBeNil)
+
+		files, err := List(s.Dir)
+		So(err, ShouldBeNil)
+		So(files, ShouldHaveLength, 2)
+		envelope, err := ReadEnvelope(files[0])
+		So(err, ShouldBeNil)
+		So(envelope.Version, ShouldEqual, EnvelopeVersion)
+		So(envelope.Source, ShouldEqual, "edge-1")
+		So(envelope.Leaks[0].PatternName, ShouldEqual, "aws")
+
+		status := http.StatusOK
+		received := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != IngestPath || r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			data, _ := ioutil.ReadAll(r.Body)
+			envelope, err := ParseEnvelope(data)
+			if err == nil {
+				received = append(received, envelope.Leaks[0].PatternName)
+			}
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+		forwarder := &Forwarder{Dir: s.Dir, URL: server.URL + "/", Token: "secret", Log: zerolog.Nop()}
+
+		Convey("delivered envelopes are removed", func() {
+			sent, err := forwarder.Forward()
+			So(err, ShouldBeNil)
+			So(sent, ShouldEqual, 2)
+			So(received, ShouldResemble, []string{"aws", "gcp"})
+			files, _ := List(s.Dir)
+			So(files, ShouldBeEmpty)
+		})
+
+		Convey("envelopes are kept while receiver is unavailable", func() {
+			status = http.StatusServiceUnavailable
+			sent, err := forwarder.Forward()
+			So(err, ShouldNotBeNil)
+			So(sent, ShouldEqual, 0)
+			So(received, ShouldHaveLength, 1)
+			files, _ := List(s.Dir)
+			So(files, ShouldHaveLength, 2)
+
+			status = http.StatusConflict
+			sent, err = forwarder.Forward()
+			So(err, ShouldBeNil)
+			So(sent, ShouldEqual, 2)
+		})
+
+		Convey("bad envelopes don't block the rest", func() {
+			So(ioutil.WriteFile(filepath.Join(s.Dir, "0-broken.json"), []byte("{"), 0600), ShouldBeNil)
+			sent, err := forwarder.Forward()
+			So(err, ShouldBeNil)
+			So(sent, ShouldEqual, 2)
+			_, err = os.Stat(filepath.Join(s.Dir, "0-broken.rejected"))
+			So(err, ShouldBeNil)
+		})
+	})
+}
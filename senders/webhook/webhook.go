@@ -0,0 +1,173 @@
+// Package webhook - send found leaks to http endpoints, with client certificates for mutual TLS
+package webhook
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	"github.com/rs/zerolog"
+)
+
+// Headers of deployment identity
+const (
+	InstanceHeader  = "X-Hungryfox-Instance"
+	EgressIPsHeader = "X-Hungryfox-Egress-IPs"
+)
+
+// Identity - deployment which sends leaks
+type Identity struct {
+	Instance  string   // hostname if empty
+	EgressIPs []string // static egress addresses of deployment
+}
+
+// Sender - POST every leak as json to URL
+type Sender struct {
+	URL      string
+	Headers  map[string]string
+	Identity Identity
+	Client   *http.Client // with TLS config of TLSConfig for mutual TLS
+	UIURL    string       // public url of web UI, payloads have no link if empty
+	// Retries - attempts after failed delivery, errors of network, 429 and 5xx are retried
+	Retries    int
+	RetryDelay time.Duration // delay before the first retry, it is doubled for every next one, 1s if empty
+	Log        zerolog.Logger
+
+	sleep func(time.Duration) // time.Sleep if nil
+}
+
+// retryError - delivery which may succeed later
+type retryError struct {
+	err error
+}
+
+func (e retryError) Error() string {
+	return e.err.Error()
+}
+
+// payload - leak with its fingerprint and link to its page in web UI, so receivers can dedup and link tickets
+type payload struct {
+	hungryfox.Leak
+	Fingerprint string `json:"fingerprint"`
+	URL         string `json:"url,omitempty"`
+}
+
+// TLSConfig - client certificate and CA of server, nil if both are not set
+func TLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	if certFile == "" && caFile == "" {
+		return nil, nil
+	}
+	result := &tls.Config{}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load client certificate: %v", err)
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		result.RootCAs = x509.NewCertPool()
+		if !result.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+	}
+	return result, nil
+}
+
+func (s *Sender) Start() error {
+	if s.URL == "" {
+		return fmt.Errorf("webhook url is required")
+	}
+	if s.Client == nil {
+		s.Client = http.DefaultClient
+	}
+	if s.Identity.Instance == "" {
+		s.Identity.Instance, _ = os.Hostname()
+	}
+	return nil
+}
+
+func (s *Sender) Stop() error {
+	return nil
+}
+
+// Send - post leak and retry failed deliveries, router waits for it so the result is reported to audit
+func (s *Sender) Send(leak hungryfox.Leak) error {
+	delay := s.RetryDelay
+	if delay == 0 {
+		delay = time.Second
+	}
+	sleep := s.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	err := s.post(leak)
+	for attempt := 0; attempt < s.Retries; attempt++ {
+		if _, ok := err.(retryError); !ok {
+			break
+		}
+		s.Log.Warn().Str("service", "webhook").Str("url", s.URL).Str("error", err.Error()).Str("fingerprint", leak.Fingerprint()).Str("retry_in", delay.String()).Msg("can't send leak, retry")
+		sleep(delay)
+		delay *= 2
+		err = s.post(leak)
+	}
+	if err != nil {
+		s.Log.Error().Str("service", "webhook").Str("url", s.URL).Str("error", err.Error()).Str("fingerprint", leak.Fingerprint()).Msg("can't send leak")
+		return err
+	}
+	return nil
+}
+
+func (s *Sender) Recipients(leak hungryfox.Leak) []string {
+	return []string{s.URL}
+}
+
+func (s *Sender) post(leak hungryfox.Leak) error {
+	p := payload{Leak: leak, Fingerprint: leak.Fingerprint()}
+	if s.UIURL != "" {
+		p.URL = strings.TrimRight(s.UIURL, "/") + hungryfox.UILeakPath + p.Fingerprint
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for name, value := range s.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(InstanceHeader, s.Identity.Instance)
+	if len(s.Identity.EgressIPs) > 0 {
+		req.Header.Set(EgressIPsHeader, strings.Join(s.Identity.EgressIPs, ","))
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return retryError{err}
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5 {
+		return retryError{fmt.Errorf("webhook answered %s", resp.Status)}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
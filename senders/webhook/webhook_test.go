@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	"github.com/rs/zerolog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// writeClientCert - self-signed client certificate and its key in dir
+func writeClientCert(dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	So(err, ShouldBeNil)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "edge-1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	So(err, ShouldBeNil)
+	cert, err := x509.ParseCertificate(der)
+	So(err, ShouldBeNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	So(err, ShouldBeNil)
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	So(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600), ShouldBeNil)
+	So(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600), ShouldBeNil)
+	return cert, certFile, keyFile
+}
+
+func TestSender(t *testing.T) {
+	Convey("leak is sent with client certificate and identity headers", t, func() {
+		dir, err := ioutil.TempDir("", "hungryfox-webhook")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		clientCert, certFile, keyFile := writeClientCert(dir)
+
+		var received *http.Request
+		var body []byte
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			body, _ = ioutil.ReadAll(r.Body)
+		}))
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(clientCert)
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+		server.StartTLS()
+		defer server.Close()
+		caFile := filepath.Join(dir, "ca.pem")
+		So(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600), ShouldBeNil)
+
+		newSender := func(certFile, keyFile string) *Sender {
+			tlsConfig, err := TLSConfig(certFile, keyFile, caFile)
+			So(err, ShouldBeNil)
+			s := &Sender{
+				URL:      server.URL,
+				Headers:  map[string]string{"X-Team": "security"},
+				Identity: Identity{Instance: "edge-1", EgressIPs: []string{"203.0.113.10", "203.0.113.11"}},
+				Client:   &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+				UIURL:    "https://hungryfox.example.com/",
+				Log:      zerolog.Nop(),
+			}
+			So(s.Start(), ShouldBeNil)
+			return s
+		}
+		leak := hungryfox.Leak{RepoURL: "https://github.com/backend/api", PatternName: "aws", LeakString: "AKIA"}
+
+		So(newSender(certFile, keyFile).Send(leak), ShouldBeNil)
+		So(received.TLS.PeerCertificates[0].Subject.CommonName, ShouldEqual, "edge-1")
+		So(received.Header.Get(InstanceHeader), ShouldEqual, "edge-1")
+		So(received.Header.Get(EgressIPsHeader), ShouldEqual, "203.0.113.10,203.0.113.11")
+		So(received.Header.Get("X-Team"), ShouldEqual, "security")
+		sent := hungryfox.Leak{}
+		So(json.Unmarshal(body, &sent), ShouldBeNil)
+		So(sent.Fingerprint(), ShouldEqual, leak.Fingerprint())
+		links := struct {
+			Fingerprint string `json:"fingerprint"`
+			URL         string `json:"url"`
+		}{}
+		So(json.Unmarshal(body, &links), ShouldBeNil)
+		So(links.Fingerprint, ShouldEqual, leak.Fingerprint())
+		So(links.URL, ShouldEqual, "https://hungryfox.example.com/ui/leaks/"+leak.Fingerprint())
+
+		received = nil
+		So(newSender("", "").Send(leak), ShouldNotBeNil)
+		So(received, ShouldBeNil)
+	})
+
+	Convey("bad certificates", t, func() {
+		_, err := TLSConfig("missing.pem", "missing.key", "")
+		So(err, ShouldNotBeNil)
+		_, err = TLSConfig("", "", "webhook_test.go")
+		So(err, ShouldNotBeNil)
+		tlsConfig, err := TLSConfig("", "", "")
+		So(err, ShouldBeNil)
+		So(tlsConfig, ShouldBeNil)
+	})
+}
+
+func TestRetry(t *testing.T) {
+	Convey("failed deliveries are retried with doubling delay", t, func() {
+		statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statuses[requests])
+			requests++
+		}))
+		defer server.Close()
+		delays := []time.Duration{}
+		s := &Sender{
+			URL:     server.URL,
+			Retries: 3,
+			Log:     zerolog.Nop(),
+			sleep:   func(d time.Duration) { delays = append(delays, d) },
+		}
+		So(s.Start(), ShouldBeNil)
+		So(s.Send(hungryfox.Leak{RepoURL: "a/b"}), ShouldBeNil)
+		So(requests, ShouldEqual, 3)
+		So(delays, ShouldResemble, []time.Duration{time.Second, 2 * time.Second})
+
+		Convey("rejected leak is not retried", func() {
+			statuses, requests = []int{http.StatusBadRequest}, 0
+			So(s.Send(hungryfox.Leak{RepoURL: "a/b"}), ShouldNotBeNil)
+			So(requests, ShouldEqual, 1)
+		})
+		Convey("error of the last attempt is returned", func() {
+			statuses, requests = []int{500, 500, 500, 500}, 0
+			So(s.Send(hungryfox.Leak{RepoURL: "a/b"}), ShouldNotBeNil)
+			So(requests, ShouldEqual, 4)
+		})
+	})
+}
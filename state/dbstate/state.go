@@ -1,48 +1,326 @@
+// Package dbstate - state of repos and fingerprints of sent leaks in embedded ql database,
+// every change is a transaction, so state is not lost on crash like with periodically saved state file
 package dbstate
 
 import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/state/filestate"
 
+	"github.com/rs/zerolog"
+	db "upper.io/db.v3"
 	"upper.io/db.v3/lib/sqlbuilder"
 	"upper.io/db.v3/ql"
 )
 
+const schema = `
+CREATE TABLE IF NOT EXISTS repos (
+	url string,
+	clone_url string,
+	data_path string,
+	repo_path string,
+	refs string,
+	mirror bool,
+	removed_at time,
+	start_time time,
+	end_time time,
+	success bool,
+	error string,
+	unhealthy bool,
+);
+CREATE UNIQUE INDEX IF NOT EXISTS repos_url ON repos (url);
+CREATE TABLE IF NOT EXISTS fingerprints (
+	fingerprint string,
+	secret_fingerprint string,
+	found_at time,
+);
+CREATE UNIQUE INDEX IF NOT EXISTS fingerprints_fingerprint ON fingerprints (fingerprint);
+CREATE TABLE IF NOT EXISTS blobs (
+	url string,
+	rules string,
+	hashes string,
+);
+CREATE UNIQUE INDEX IF NOT EXISTS blobs_url ON blobs (url);
+`
+
+// StateManager - database is locked by the process, so api role needs state_file
 type StateManager struct {
 	Location string
-	db       sqlbuilder.Database
+	// Import - state file which is imported into empty database
+	Import string
+	Log    zerolog.Logger
+
+	db    sqlbuilder.Database
+	mutex sync.Mutex
+}
+
+type repoRow struct {
+	URL       string    `db:"url"`
+	CloneURL  string    `db:"clone_url"`
+	DataPath  string    `db:"data_path"`
+	RepoPath  string    `db:"repo_path"`
+	Refs      string    `db:"refs"` // json list
+	Mirror    bool      `db:"mirror"`
+	RemovedAt time.Time `db:"removed_at"`
+	StartTime time.Time `db:"start_time"`
+	EndTime   time.Time `db:"end_time"`
+	Success   bool      `db:"success"`
+	Error     string    `db:"error"`
+	Unhealthy bool      `db:"unhealthy"`
+}
+
+// blobsRow - blob cache of repo, it is kept apart from repos as it is much bigger
+type blobsRow struct {
+	URL    string `db:"url"`
+	Rules  string `db:"rules"`
+	Hashes string `db:"hashes"` // json list
 }
 
-type State struct {
-	RepoID string `db:"repoid"`
-	Refs   string `db:"refs"`
-	Status string `db:"status"`
+type fingerprintRow struct {
+	Fingerprint       string    `db:"fingerprint"`
+	SecretFingerprint string    `db:"secret_fingerprint"`
+	FoundAt           time.Time `db:"found_at"`
 }
 
 func (s *StateManager) Start() error {
-	settings := ql.ConnectionURL{Database: s.Location}
 	var err error
-	if s.db, err = ql.Open(settings); err != nil {
-		return err
+	if s.db, err = ql.Open(ql.ConnectionURL{Database: s.Location}); err != nil {
+		return fmt.Errorf("can't open %s: %v", s.Location, err)
+	}
+	if _, err := s.db.Exec(schema); err != nil {
+		s.db.Close()
+		return fmt.Errorf("can't create schema: %v", err)
+	}
+	if s.Import != "" {
+		if err := s.importStateFile(); err != nil {
+			s.db.Close()
+			return fmt.Errorf("can't import %s: %v", s.Import, err)
+		}
 	}
 	return nil
 }
 
 func (s *StateManager) Stop() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	return s.db.Close()
 }
 
-func (s StateManager) Save(r hungryfox.Repo) {
+// importStateFile - move repos of state file into database on first start
+func (s *StateManager) importStateFile() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	count, err := s.db.Collection("repos").Find().Count()
+	if err != nil || count > 0 {
+		return err
+	}
+	repos, err := (&filestate.Reader{Location: s.Import}).GetRepos()
+	if err != nil {
+		return err
+	}
+	err = s.db.Tx(nil, func(tx sqlbuilder.Tx) error {
+		for _, r := range repos {
+			if err := saveRepo(tx, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil && len(repos) > 0 {
+		s.Log.Info().Str("service", "state manager").Int("repos", len(repos)).Str("state_file", s.Import).Msg("state imported")
+	}
+	return err
 }
 
-func (s StateManager) Load(id string) (hungryfox.RepoState, hungryfox.ScanStatus) {
-	return hungryfox.RepoState{}, hungryfox.ScanStatus{}
+func saveRepo(tx sqlbuilder.Tx, r hungryfox.Repo) error {
+	refs, err := json.Marshal(r.State.Refs)
+	if err != nil {
+		return err
+	}
+	repos := tx.Collection("repos")
+	if err := repos.Find(db.Cond{"url": r.Location.URL}).Delete(); err != nil {
+		return err
+	}
+	if err := saveBlobs(tx, r); err != nil {
+		return err
+	}
+	_, err = repos.Insert(repoRow{
+		URL:       r.Location.URL,
+		CloneURL:  r.Location.CloneURL,
+		DataPath:  r.Location.DataPath,
+		RepoPath:  r.Location.RepoPath,
+		Refs:      string(refs),
+		Mirror:    r.Options.AllowUpdate,
+		RemovedAt: r.State.RemovedAt,
+		StartTime: r.Scan.StartTime,
+		EndTime:   r.Scan.EndTime,
+		Success:   r.Scan.Success,
+		Error:     r.Scan.Error,
+		Unhealthy: r.Scan.Unhealthy,
+	})
+	return err
 }
 
-func (s *StateManager) setup() error {
-	// table := `CREATE TABLE state (
-	// repoid string,
-	// refs string,
-	// status string,
-	// )`
-	return nil
+func saveBlobs(tx sqlbuilder.Tx, r hungryfox.Repo) error {
+	blobs := tx.Collection("blobs")
+	if err := blobs.Find(db.Cond{"url": r.Location.URL}).Delete(); err != nil {
+		return err
+	}
+	if len(r.State.Blobs) == 0 {
+		return nil
+	}
+	hashes, err := json.Marshal(r.State.Blobs)
+	if err != nil {
+		return err
+	}
+	_, err = blobs.Insert(blobsRow{URL: r.Location.URL, Rules: r.State.BlobsRules, Hashes: string(hashes)})
+	return err
+}
+
+// loadBlobs - add blob cache to state of repo
+func (s *StateManager) loadBlobs(r *hungryfox.Repo) error {
+	row := blobsRow{}
+	if err := s.db.Collection("blobs").Find(db.Cond{"url": r.Location.URL}).One(&row); err != nil {
+		if err == db.ErrNoMoreRows {
+			return nil
+		}
+		return err
+	}
+	r.State.BlobsRules = row.Rules
+	return json.Unmarshal([]byte(row.Hashes), &r.State.Blobs)
+}
+
+func (row repoRow) repo() hungryfox.Repo {
+	refs := []string{}
+	json.Unmarshal([]byte(row.Refs), &refs)
+	return hungryfox.Repo{
+		Location: hungryfox.RepoLocation{
+			URL:      row.URL,
+			CloneURL: row.CloneURL,
+			DataPath: row.DataPath,
+			RepoPath: row.RepoPath,
+		},
+		Options: hungryfox.RepoOptions{
+			AllowUpdate: row.Mirror,
+		},
+		State: hungryfox.RepoState{
+			Refs:      refs,
+			RemovedAt: row.RemovedAt,
+		},
+		Scan: hungryfox.ScanStatus{
+			StartTime: row.StartTime,
+			EndTime:   row.EndTime,
+			Success:   row.Success,
+			Error:     row.Error,
+			Unhealthy: row.Unhealthy,
+		},
+	}
+}
+
+// Save - replace state of repo
+func (s *StateManager) Save(r hungryfox.Repo) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err := s.db.Tx(nil, func(tx sqlbuilder.Tx) error {
+		return saveRepo(tx, r)
+	})
+	if err != nil {
+		s.Log.Error().Str("service", "state manager").Str("repo_url", r.Location.URL).Str("error", err.Error()).Msg("can't save state")
+	}
+}
+
+// Load - state of repo, empty if repo is unknown
+func (s *StateManager) Load(url string) (hungryfox.RepoState, hungryfox.ScanStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	row := repoRow{}
+	if err := s.db.Collection("repos").Find(db.Cond{"url": url}).One(&row); err != nil {
+		if err != db.ErrNoMoreRows {
+			s.Log.Error().Str("service", "state manager").Str("repo_url", url).Str("error", err.Error()).Msg("can't load state")
+		}
+		return hungryfox.RepoState{}, hungryfox.ScanStatus{}
+	}
+	r := row.repo()
+	if err := s.loadBlobs(&r); err != nil {
+		s.Log.Error().Str("service", "state manager").Str("repo_url", url).Str("error", err.Error()).Msg("can't load blob cache")
+	}
+	return r.State, r.Scan
+}
+
+// List - get state of all known repos
+func (s *StateManager) List() []hungryfox.Repo {
+	repos, err := s.GetRepos()
+	if err != nil {
+		s.Log.Error().Str("service", "state manager").Str("error", err.Error()).Msg("can't list state")
+	}
+	return repos
+}
+
+// GetRepos - state of all repos for api
+func (s *StateManager) GetRepos() ([]hungryfox.Repo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	rows := []repoRow{}
+	if err := s.db.Collection("repos").Find().All(&rows); err != nil {
+		return nil, err
+	}
+	result := make([]hungryfox.Repo, 0, len(rows))
+	for _, row := range rows {
+		r := row.repo()
+		if err := s.loadBlobs(&r); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// Delete - forget state of repo
+func (s *StateManager) Delete(url string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err := s.db.Tx(nil, func(tx sqlbuilder.Tx) error {
+		if err := tx.Collection("blobs").Find(db.Cond{"url": url}).Delete(); err != nil {
+			return err
+		}
+		return tx.Collection("repos").Find(db.Cond{"url": url}).Delete()
+	})
+	if err != nil {
+		s.Log.Error().Str("service", "state manager").Str("repo_url", url).Str("error", err.Error()).Msg("can't delete state")
+	}
+}
+
+// GetFingerprints - fingerprints and secret fingerprints of sent leaks
+func (s *StateManager) GetFingerprints() (map[string]bool, map[string]bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	rows := []fingerprintRow{}
+	if err := s.db.Collection("fingerprints").Find().All(&rows); err != nil {
+		return nil, nil, err
+	}
+	fingerprints, secretFingerprints := map[string]bool{}, map[string]bool{}
+	for _, row := range rows {
+		fingerprints[row.Fingerprint] = true
+		secretFingerprints[row.SecretFingerprint] = true
+	}
+	return fingerprints, secretFingerprints, nil
+}
+
+// AddFingerprint - remember sent leak
+func (s *StateManager) AddFingerprint(fingerprint, secretFingerprint string, foundAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.db.Tx(nil, func(tx sqlbuilder.Tx) error {
+		fingerprints := tx.Collection("fingerprints")
+		exists, err := fingerprints.Find(db.Cond{"fingerprint": fingerprint}).Exists()
+		if err != nil || exists {
+			return err
+		}
+		_, err = fingerprints.Insert(fingerprintRow{Fingerprint: fingerprint, SecretFingerprint: secretFingerprint, FoundAt: foundAt})
+		return err
+	})
 }
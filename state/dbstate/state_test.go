@@ -0,0 +1,66 @@
+package dbstate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStateManager(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dbstate")
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.yml")
+	ioutil.WriteFile(stateFile, []byte("- url: https://github.com/a/b\n  refs: [refs/heads/master]\n"), 0644)
+	location := filepath.Join(dir, "state.ql")
+	now := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	Convey("state file is imported into new database", t, func() {
+		s := &StateManager{Location: location, Import: stateFile}
+		So(s.Start(), ShouldBeNil)
+		state, _ := s.Load("https://github.com/a/b")
+		So(state.Refs, ShouldResemble, []string{"refs/heads/master"})
+		state, _ = s.Load("")
+		So(state.Refs, ShouldBeNil)
+		So(s.Stop(), ShouldBeNil)
+	})
+	Convey("state and fingerprints survive restart", t, func() {
+		s := &StateManager{Location: location, Import: stateFile}
+		So(s.Start(), ShouldBeNil)
+		s.Save(hungryfox.Repo{
+			Location: hungryfox.RepoLocation{URL: "https://github.com/c/d"},
+			State:    hungryfox.RepoState{Refs: []string{"refs/heads/dev"}, Blobs: []string{"b1", "b2"}, BlobsRules: "r1"},
+			Scan:     hungryfox.ScanStatus{EndTime: now, Success: true},
+		})
+		s.Delete("https://github.com/a/b")
+		So(s.AddFingerprint("f1", "s1", now), ShouldBeNil)
+		So(s.AddFingerprint("f1", "s1", now), ShouldBeNil)
+		So(s.Stop(), ShouldBeNil)
+
+		s = &StateManager{Location: location, Import: stateFile}
+		So(s.Start(), ShouldBeNil)
+		defer s.Stop()
+		repos := s.List()
+		So(repos, ShouldHaveLength, 1)
+		state, scan := s.Load("https://github.com/c/d")
+		So(state.Refs, ShouldResemble, []string{"refs/heads/dev"})
+		So(state.Blobs, ShouldResemble, []string{"b1", "b2"})
+		So(state.BlobsRules, ShouldEqual, "r1")
+		So(scan.Success, ShouldBeTrue)
+		state, _ = s.Load("https://github.com/a/b")
+		So(state.Refs, ShouldBeNil)
+		So(repos[0].State.Refs, ShouldResemble, []string{"refs/heads/dev"})
+		So(repos[0].Scan.EndTime.Equal(now), ShouldBeTrue)
+		So(repos[0].Scan.Success, ShouldBeTrue)
+		So(repos[0].State.Blobs, ShouldResemble, []string{"b1", "b2"})
+		fingerprints, secrets, err := s.GetFingerprints()
+		So(err, ShouldBeNil)
+		So(fingerprints, ShouldResemble, map[string]bool{"f1": true})
+		So(secrets, ShouldResemble, map[string]bool{"s1": true})
+	})
+}
@@ -19,6 +19,8 @@ type StateManager struct {
 	saveRepoChan        chan hungryfox.Repo
 	loadRepoChan        chan hungryfox.Repo
 	loadRepoChanRequest chan string
+	listRepoChanRequest chan chan []hungryfox.Repo
+	deleteRepoChan      chan string
 }
 
 func (s *StateManager) Start() error {
@@ -28,6 +30,8 @@ func (s *StateManager) Start() error {
 	s.saveRepoChan = make(chan hungryfox.Repo)
 	s.loadRepoChan = make(chan hungryfox.Repo)
 	s.loadRepoChanRequest = make(chan string)
+	s.listRepoChanRequest = make(chan chan []hungryfox.Repo)
+	s.deleteRepoChan = make(chan string)
 
 	s.tomb.Go(func() error {
 		saveTicker := time.NewTicker(time.Minute)
@@ -47,6 +51,14 @@ func (s *StateManager) Start() error {
 					continue
 				}
 				s.loadRepoChan <- hungryfox.Repo{}
+			case result := <-s.listRepoChanRequest:
+				list := make([]hungryfox.Repo, 0, len(s.state))
+				for _, r := range s.state {
+					list = append(list, r)
+				}
+				result <- list
+			case url := <-s.deleteRepoChan:
+				delete(s.state, url)
 			}
 		}
 	})
@@ -79,16 +91,22 @@ func convertToRawData(stateStruct map[string]hungryfox.Repo) ([]byte, error) {
 	fileStruct := []RepoJSON{}
 	for _, r := range stateStruct {
 		fileStruct = append(fileStruct, RepoJSON{
-			RepoURL:  r.Location.URL,
-			CloneURL: r.Location.CloneURL,
-			RepoPath: r.Location.RepoPath,
-			DataPath: r.Location.DataPath,
-			Refs:     r.State.Refs,
+			RepoURL:   r.Location.URL,
+			CloneURL:  r.Location.CloneURL,
+			RepoPath:  r.Location.RepoPath,
+			DataPath:  r.Location.DataPath,
+			Refs:      r.State.Refs,
+			Mirror:    r.Options.AllowUpdate,
+			RemovedAt: r.State.RemovedAt,
 			ScanStatus: ScanJSON{
 				StartTime: r.Scan.StartTime,
 				EndTime:   r.Scan.EndTime,
 				Success:   r.Scan.Success,
+				Error:     r.Scan.Error,
+				Unhealthy: r.Scan.Unhealthy,
 			},
+			Blobs:      r.State.Blobs,
+			BlobsRules: r.State.BlobsRules,
 		})
 	}
 	return yaml.Marshal(&fileStruct)
@@ -108,13 +126,21 @@ func converFromRawData(rawData []byte) (map[string]hungryfox.Repo, error) {
 				DataPath: r.DataPath,
 				RepoPath: r.RepoPath,
 			},
+			Options: hungryfox.RepoOptions{
+				AllowUpdate: r.Mirror,
+			},
 			State: hungryfox.RepoState{
-				Refs: r.Refs,
+				Refs:       r.Refs,
+				RemovedAt:  r.RemovedAt,
+				Blobs:      r.Blobs,
+				BlobsRules: r.BlobsRules,
 			},
 			Scan: hungryfox.ScanStatus{
 				StartTime: r.ScanStatus.StartTime,
 				EndTime:   r.ScanStatus.EndTime,
 				Success:   r.ScanStatus.Success,
+				Error:     r.ScanStatus.Error,
+				Unhealthy: r.ScanStatus.Unhealthy,
 			},
 		}
 	}
@@ -137,12 +163,24 @@ func (s *StateManager) saveToFile() error {
 	return nil
 }
 
-func (s StateManager) Save(r hungryfox.Repo) {
+func (s *StateManager) Save(r hungryfox.Repo) {
 	s.saveRepoChan <- r
 }
 
-func (s StateManager) Load(url string) (hungryfox.RepoState, hungryfox.ScanStatus) {
+func (s *StateManager) Load(url string) (hungryfox.RepoState, hungryfox.ScanStatus) {
 	s.loadRepoChanRequest <- url
 	r := <-s.loadRepoChan
 	return r.State, r.Scan
 }
+
+// List - get state of all known repos
+func (s *StateManager) List() []hungryfox.Repo {
+	result := make(chan []hungryfox.Repo)
+	s.listRepoChanRequest <- result
+	return <-result
+}
+
+// Delete - forget state of repo
+func (s *StateManager) Delete(url string) {
+	s.deleteRepoChan <- url
+}
@@ -3,16 +3,23 @@ package filestate
 import "time"
 
 type RepoJSON struct {
-	RepoURL    string   `yaml:"url"`
-	CloneURL   string   `yaml:"clone_url"`
-	RepoPath   string   `yaml:"repo_path"`
-	DataPath   string   `yaml:"data_path"`
-	Refs       []string `yaml:"refs"`
-	ScanStatus ScanJSON `yaml:"scan_status"`
+	RepoURL    string    `yaml:"url"`
+	CloneURL   string    `yaml:"clone_url"`
+	RepoPath   string    `yaml:"repo_path"`
+	DataPath   string    `yaml:"data_path"`
+	Refs       []string  `yaml:"refs"`
+	Mirror     bool      `yaml:"mirror"`
+	RemovedAt  time.Time `yaml:"removed_at,omitempty"`
+	ScanStatus ScanJSON  `yaml:"scan_status"`
+	// Blobs - inspected blobs of blob cache with version of rules which inspected them
+	Blobs      []string `yaml:"blobs,omitempty"`
+	BlobsRules string   `yaml:"blobs_rules,omitempty"`
 }
 
 type ScanJSON struct {
 	StartTime time.Time `yaml:"start_time"`
 	EndTime   time.Time `yaml:"end_time"`
 	Success   bool      `yaml:"success"`
+	Error     string    `yaml:"error,omitempty"`
+	Unhealthy bool      `yaml:"unhealthy,omitempty"`
 }
@@ -0,0 +1,33 @@
+package filestate
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+// Reader - read-only access to state file written by scanning instance
+type Reader struct {
+	Location string
+}
+
+// GetRepos - get state of all repos from file
+func (r *Reader) GetRepos() ([]hungryfox.Repo, error) {
+	rawData, err := ioutil.ReadFile(r.Location)
+	if os.IsNotExist(err) {
+		return []hungryfox.Repo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state, err := converFromRawData(rawData)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]hungryfox.Repo, 0, len(state))
+	for _, repo := range state {
+		result = append(result, repo)
+	}
+	return result, nil
+}
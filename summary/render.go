@@ -0,0 +1,89 @@
+package summary
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Subject - subject of email and title of Slack message
+func (s *Summary) Subject() string {
+	return "HungryFox summary " + s.From.Format("2006-01-02") + " - " + s.To.Format("2006-01-02")
+}
+
+var htmlTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <style>
+    body { font-family: sans-serif; color: #111111; }
+    table { border-collapse: collapse; margin-bottom: 1.5em; }
+    td, th { padding: 0.2em 1em; border-bottom: 1px solid #dddddd; text-align: left; }
+    td.n { text-align: right; }
+    .trend { color: #777777; }
+  </style>
+</head>
+<body>
+  <h2>{{ .Subject }}</h2>
+  <table>
+    <tr><td>Repos scanned</td><td class="n">{{ .Totals.Repos }}</td><td class="trend">{{ .Trend "repos" }}</td></tr>
+    <tr><td>Scans</td><td class="n">{{ .Activity.Scans }}</td><td class="trend">{{ if .Activity.Failed }}{{ .Activity.Failed }} failed{{ end }}</td></tr>
+    <tr><td>Commits processed</td><td class="n">{{ .Totals.Commits }}</td><td class="trend">{{ .Trend "commits" }}</td></tr>
+    <tr><td>New leaks</td><td class="n">{{ .Totals.NewLeaks }}</td><td class="trend">{{ .Trend "new_leaks" }}</td></tr>
+    <tr><td>Open leaks</td><td class="n">{{ .Totals.Open }}</td><td class="trend">{{ .Trend "open" }}</td></tr>
+  </table>
+{{ define "counts" }}
+  <table>
+  {{ range . }}
+    <tr><td>{{ .Name }}</td><td class="n">{{ .Leaks }}</td></tr>
+  {{ else }}
+    <tr><td>none</td></tr>
+  {{ end }}
+  </table>
+{{ end }}
+  <h3>New leaks by severity</h3>
+  {{ template "counts" .NewBySeverity }}
+  <h3>Top patterns</h3>
+  {{ template "counts" .TopPatterns }}
+  <h3>Top repos</h3>
+  {{ template "counts" .TopRepos }}
+  <h3>Top authors</h3>
+  {{ template "counts" .TopAuthors }}
+  <h3>Open leaks by severity</h3>
+  {{ template "counts" .OpenBySeverity }}
+  <p>Open leaks are all leaks which are not resolved, rotated, ignored or false positive at {{ .To.Format "2006-01-02 15:04 MST" }}. Trends are against the previous summary.</p>
+</body>
+</html>
+`))
+
+// WriteHTML - html message of email
+func (s *Summary) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, s)
+}
+
+// slackEscape - control characters of Slack message text
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var textTemplate = texttemplate.Must(texttemplate.New("summary").Funcs(texttemplate.FuncMap{
+	"escape": slackEscape.Replace,
+}).Parse(`*{{ .Subject }}*
+Repos scanned: {{ .Totals.Repos }}{{ with .Trend "repos" }} ({{ . }}){{ end }}
+Scans: {{ .Activity.Scans }}{{ if .Activity.Failed }}, {{ .Activity.Failed }} failed{{ end }}
+Commits processed: {{ .Totals.Commits }}{{ with .Trend "commits" }} ({{ . }}){{ end }}
+New leaks: {{ .Totals.NewLeaks }}{{ with .Trend "new_leaks" }} ({{ . }}){{ end }}: {{ template "counts" .NewBySeverity }}
+Open leaks: {{ .Totals.Open }}{{ with .Trend "open" }} ({{ . }}){{ end }}: {{ template "counts" .OpenBySeverity }}
+{{ define "counts" }}{{ range $i, $c := . }}{{ if $i }}, {{ end }}{{ escape $c.Name }} {{ $c.Leaks }}{{ else }}none{{ end }}{{ end }}
+*Top patterns:* {{ template "counts" .TopPatterns }}
+*Top repos:* {{ template "counts" .TopRepos }}
+*Top authors:* {{ template "counts" .TopAuthors }}`))
+
+// Text - message of Slack in mrkdwn
+func (s *Summary) Text() (string, error) {
+	var buf bytes.Buffer
+	if err := textTemplate.Execute(&buf, s); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,229 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+	"github.com/AlexAkulov/hungryfox/cron"
+	"github.com/AlexAkulov/hungryfox/findings"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/tomb.v2"
+)
+
+// maxWait - the longest sleep before the schedule is checked again, so a changed clock is noticed
+const maxWait = 10 * time.Minute
+
+// Notifier - destination of summary
+type Notifier interface {
+	Notify(*Summary) error
+}
+
+// Mailer - html message with subject, it is email.Report
+type Mailer interface {
+	Send(subject string, body func(io.Writer) error) error
+}
+
+// Email - summary as html message
+type Email struct {
+	Mailer Mailer
+}
+
+// Notify - send html summary
+func (e *Email) Notify(s *Summary) error {
+	return e.Mailer.Send(s.Subject(), s.WriteHTML)
+}
+
+// Slack - summary as message of incoming webhook
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify - post text summary
+func (sl *Slack) Notify(s *Summary) error {
+	text, err := s.Text()
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(map[string]string{"text": text})
+	resp, err := sl.Client.Post(sl.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// state - activity of current period and totals of the last sent summary
+type state struct {
+	Since    time.Time `json:"since"`
+	Activity Activity  `json:"activity"`
+	Previous *Totals   `json:"previous,omitempty"`
+}
+
+// Reporter - collect activity of scans and send summary by schedule
+type Reporter struct {
+	Schedule  *cron.Schedule
+	Top       int
+	StateFile string // activity of current period is kept in memory if empty
+	Leaks     hungryfox.ILeakStore
+	Statuses  hungryfox.IStatusStore // every leak is open if nil
+	Notifiers []Notifier
+	Clock     clock.Clock // system clock if nil
+	Log       zerolog.Logger
+
+	mutex sync.Mutex
+	state state
+	tomb  tomb.Tomb
+}
+
+// ScanFinished - count scan in activity of current period
+func (r *Reporter) ScanFinished(repo hungryfox.Repo, commits int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.state.Activity.add(repo, commits)
+	if err := r.save(); err != nil {
+		r.Log.Error().Str("service", "summary").Str("error", err.Error()).Msg("can't save activity")
+	}
+}
+
+// load - activity of period from state file, the period starts now if there is no state
+func (r *Reporter) load() error {
+	r.state = state{Since: clock.Or(r.Clock).Now()}
+	if r.StateFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(r.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &r.state)
+}
+
+// save - write state file through temporary file, so it is never half written
+func (r *Reporter) save() error {
+	if r.StateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(r.state)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(r.StateFile), filepath.Base(r.StateFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.StateFile)
+}
+
+// Run - send summary of period till now, the next period starts if it was sent to all notifiers,
+// otherwise the next summary covers this period too
+func (r *Reporter) Run() error {
+	now := clock.Or(r.Clock).Now()
+	leaks, err := r.Leaks.GetLeaks()
+	if err != nil {
+		return err
+	}
+	history := map[string][]hungryfox.StatusEvent{}
+	if r.Statuses != nil {
+		events, err := r.Statuses.GetEvents()
+		if err != nil {
+			return err
+		}
+		history = findings.StatusHistory(events)
+	}
+	r.mutex.Lock()
+	s := Build(r.state.Since, now, r.state.Activity, r.state.Previous, leaks, history, r.Top)
+	r.mutex.Unlock()
+
+	failed := 0
+	for _, notifier := range r.Notifiers {
+		if err := notifier.Notify(s); err != nil {
+			r.Log.Error().Str("service", "summary").Str("error", err.Error()).Msg("can't send summary")
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("summary is not sent to %d of %d destinations", failed, len(r.Notifiers))
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// scans finished while summary was sent go to the next period
+	r.state.Activity.Scans -= s.Activity.Scans
+	r.state.Activity.Failed -= s.Activity.Failed
+	r.state.Activity.Commits -= s.Activity.Commits
+	for url, scans := range s.Activity.Repos {
+		if r.state.Activity.Repos[url] -= scans; r.state.Activity.Repos[url] <= 0 {
+			delete(r.state.Activity.Repos, url)
+		}
+	}
+	r.state.Since = now
+	r.state.Previous = &s.Totals
+	r.Log.Info().Str("service", "summary").Int("repos", s.Totals.Repos).Int("new_leaks", s.Totals.NewLeaks).Int("open", s.Totals.Open).Msg("summary sent")
+	return r.save()
+}
+
+// Start - summary is sent at every time of schedule, a summary which was missed while the process was down
+// is sent at once
+func (r *Reporter) Start() error {
+	if err := r.load(); err != nil {
+		return fmt.Errorf("can't read %s: %v", r.StateFile, err)
+	}
+	r.tomb.Go(func() error {
+		next := r.Schedule.Next(r.state.Since)
+		for {
+			wait := next.Sub(clock.Or(r.Clock).Now())
+			if wait > maxWait {
+				wait = maxWait
+			}
+			if wait > 0 {
+				select {
+				case <-r.tomb.Dying():
+					return nil
+				case <-time.After(wait):
+				}
+			}
+			now := clock.Or(r.Clock).Now()
+			if now.Before(next) {
+				continue
+			}
+			if err := r.Run(); err != nil {
+				r.Log.Error().Str("service", "summary").Str("error", err.Error()).Msg("fail")
+			}
+			next = r.Schedule.Next(now)
+		}
+	})
+	return nil
+}
+
+// Stop - stop schedule, activity is already saved
+func (r *Reporter) Stop() error {
+	r.tomb.Kill(nil)
+	return r.tomb.Wait()
+}
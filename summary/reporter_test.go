@@ -0,0 +1,138 @@
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+	"github.com/AlexAkulov/hungryfox/cron"
+	"github.com/rs/zerolog"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeLeaks []hungryfox.Leak
+
+func (f fakeLeaks) GetLeaks() ([]hungryfox.Leak, error) { return f, nil }
+
+type fakeNotifier struct {
+	err  error
+	sent []*Summary
+}
+
+func (f *fakeNotifier) Notify(s *Summary) error {
+	f.sent = append(f.sent, s)
+	return f.err
+}
+
+func TestReporter(t *testing.T) {
+	start := time.Date(2018, 7, 2, 9, 0, 0, 0, time.UTC)
+	schedule, _ := cron.Parse("0 9 * * 1")
+	api := hungryfox.Repo{Location: hungryfox.RepoLocation{URL: "https://github.com/backend/api"}, Scan: hungryfox.ScanStatus{Success: true}}
+	leak := hungryfox.Leak{RepoURL: api.Location.URL, PatternName: "aws", Severity: hungryfox.SeverityHigh, FoundAt: start.Add(time.Hour)}
+
+	newReporter := func(stateFile string, c clock.Clock, notifier Notifier) *Reporter {
+		return &Reporter{
+			Schedule:  schedule,
+			Top:       10,
+			StateFile: stateFile,
+			Leaks:     fakeLeaks{leak},
+			Notifiers: []Notifier{notifier},
+			Clock:     c,
+			Log:       zerolog.Nop(),
+		}
+	}
+
+	Convey("activity survives restart and starts again after summary is sent", t, func() {
+		dir, _ := ioutil.TempDir("", "summary")
+		defer os.RemoveAll(dir)
+		stateFile := filepath.Join(dir, "summary.json")
+		c := clock.NewFake(start, 0)
+		notifier := &fakeNotifier{}
+
+		r := newReporter(stateFile, c, notifier)
+		So(r.load(), ShouldBeNil)
+		r.ScanFinished(api, 10)
+		r.ScanFinished(api, 2)
+
+		r = newReporter(stateFile, c, notifier)
+		So(r.load(), ShouldBeNil)
+		So(r.state.Since, ShouldEqual, start)
+		So(r.state.Activity.Commits, ShouldEqual, 12)
+
+		c.Advance(7 * 24 * time.Hour)
+		So(r.Run(), ShouldBeNil)
+		So(notifier.sent, ShouldHaveLength, 1)
+		So(notifier.sent[0].From, ShouldEqual, start)
+		So(notifier.sent[0].Totals, ShouldResemble, Totals{Repos: 1, Commits: 12, NewLeaks: 1, Open: 1})
+
+		r = newReporter(stateFile, c, notifier)
+		So(r.load(), ShouldBeNil)
+		So(r.state.Since, ShouldEqual, start.Add(7*24*time.Hour))
+		So(r.state.Activity.Scans, ShouldEqual, 0)
+		So(r.state.Activity.Repos, ShouldBeEmpty)
+		So(r.state.Previous, ShouldResemble, &Totals{Repos: 1, Commits: 12, NewLeaks: 1, Open: 1})
+	})
+
+	Convey("period is kept if summary is not sent", t, func() {
+		c := clock.NewFake(start, 0)
+		notifier := &fakeNotifier{err: fmt.Errorf("relay is down")}
+		r := newReporter("", c, notifier)
+		So(r.load(), ShouldBeNil)
+		r.ScanFinished(api, 3)
+		c.Advance(time.Hour)
+		So(r.Run(), ShouldNotBeNil)
+		So(r.state.Since, ShouldEqual, start)
+		So(r.state.Activity.Commits, ShouldEqual, 3)
+		So(r.state.Previous, ShouldBeNil)
+	})
+
+	Convey("missed summary is sent at start", t, func() {
+		dir, _ := ioutil.TempDir("", "summary")
+		defer os.RemoveAll(dir)
+		stateFile := filepath.Join(dir, "summary.json")
+		c := clock.NewFake(start, 0)
+		notifier := &fakeNotifier{}
+		r := newReporter(stateFile, c, notifier)
+		So(r.load(), ShouldBeNil)
+		r.ScanFinished(api, 1)
+
+		c.Advance(8 * 24 * time.Hour)
+		r = newReporter(stateFile, c, notifier)
+		So(r.Start(), ShouldBeNil)
+		for i := 0; i < 100 && len(notifier.sent) == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(r.Stop(), ShouldBeNil)
+		So(notifier.sent, ShouldHaveLength, 1)
+		So(notifier.sent[0].Totals.Commits, ShouldEqual, 1)
+	})
+
+	Convey("slack receives text of summary", t, func() {
+		var text string
+		status := http.StatusOK
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			message := map[string]string{}
+			json.NewDecoder(req.Body).Decode(&message)
+			text = message["text"]
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+		slack := &Slack{URL: server.URL, Client: server.Client()}
+		s := Build(start, start.Add(time.Hour), Activity{}, nil, []hungryfox.Leak{leak}, nil, 10)
+
+		So(slack.Notify(s), ShouldBeNil)
+		So(text, ShouldStartWith, "*HungryFox summary 2018-07-02 - 2018-07-02*\n")
+
+		status = http.StatusNotFound
+		So(slack.Notify(s), ShouldNotBeNil)
+	})
+}
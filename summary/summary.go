@@ -0,0 +1,154 @@
+// Package summary - numbers of scans, new leaks and open leaks of period for management, they are sent by schedule
+// by email and to Slack. Trends are shown against the previous summary, secrets are never included
+package summary
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/report"
+)
+
+// Activity - scans finished since the start of period
+type Activity struct {
+	Scans   int            `json:"scans"`
+	Failed  int            `json:"failed"`
+	Commits int            `json:"commits"`
+	Repos   map[string]int `json:"repos"` // scans by repo url
+}
+
+func (a *Activity) add(r hungryfox.Repo, commits int) {
+	if a.Repos == nil {
+		a.Repos = map[string]int{}
+	}
+	a.Scans++
+	if !r.Scan.Success {
+		a.Failed++
+	}
+	a.Commits += commits
+	a.Repos[r.Location.URL]++
+}
+
+// Totals - main numbers of summary which the next summary is compared with
+type Totals struct {
+	Repos    int `json:"repos"`
+	Commits  int `json:"commits"`
+	NewLeaks int `json:"new_leaks"`
+	Open     int `json:"open"`
+}
+
+// Count - number of leaks of severity, pattern, repo or author
+type Count struct {
+	Name  string
+	Leaks int
+}
+
+// Summary - activity and leaks of period
+type Summary struct {
+	From     time.Time
+	To       time.Time
+	Activity Activity
+	Totals   Totals
+	// Previous - totals of the previous summary, nil for the first one
+	Previous *Totals
+	// NewBySeverity - new leaks by critical, high, medium and low
+	NewBySeverity []Count
+	TopPatterns   []Count
+	TopRepos      []Count
+	TopAuthors    []Count
+	// OpenBySeverity - all leaks which are not resolved or ignored at the end of period
+	OpenBySeverity []Count
+}
+
+// Build - summary of leaks found from the start of period to its end, leaks which were found before the start
+// are counted only as open ones
+func Build(from, to time.Time, activity Activity, previous *Totals, leaks []hungryfox.Leak, history map[string][]hungryfox.StatusEvent, top int) *Summary {
+	newLeaks := []hungryfox.Leak{}
+	for _, leak := range leaks {
+		if leak.Purged || leak.Kind != "" {
+			continue
+		}
+		foundAt := leak.FoundAt
+		if foundAt.IsZero() {
+			// leaks found before found_at was added
+			foundAt = leak.TimeStamp
+		}
+		if !foundAt.Before(from) && foundAt.Before(to) {
+			newLeaks = append(newLeaks, leak)
+		}
+	}
+	open := report.Build(leaks, history, to).Total
+	created := report.Build(newLeaks, nil, to)
+	return &Summary{
+		From:     from,
+		To:       to,
+		Activity: activity,
+		Previous: previous,
+		Totals: Totals{
+			Repos:    len(activity.Repos),
+			Commits:  activity.Commits,
+			NewLeaks: len(newLeaks),
+			Open:     open.Open,
+		},
+		// every new leak is open in report without statuses
+		NewBySeverity:  severities(created.Total),
+		TopPatterns:    topRows(created.Rules, top),
+		TopRepos:       topRows(created.Repos, top),
+		TopAuthors:     topRows(created.Authors, top),
+		OpenBySeverity: severities(open),
+	}
+}
+
+func severities(row report.Row) []Count {
+	return []Count{
+		{hungryfox.SeverityCritical, row.Critical},
+		{hungryfox.SeverityHigh, row.High},
+		{hungryfox.SeverityMedium, row.Medium},
+		{hungryfox.SeverityLow, row.Low},
+	}
+}
+
+// topRows - the first rows with the most leaks
+func topRows(rows []*report.Row, top int) []Count {
+	result := make([]Count, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, Count{Name: row.Name, Leaks: row.Leaks})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Leaks > result[j].Leaks
+	})
+	if len(result) > top {
+		result = result[:top]
+	}
+	return result
+}
+
+// Trend - change of total (repos, commits, new_leaks or open) against the previous summary like "+3", "-2" or "=",
+// empty for the first summary
+func (s *Summary) Trend(total string) string {
+	if s.Previous == nil {
+		return ""
+	}
+	current, previous := s.Totals.get(total), s.Previous.get(total)
+	switch {
+	case current > previous:
+		return "+" + strconv.Itoa(current-previous)
+	case current < previous:
+		return "-" + strconv.Itoa(previous-current)
+	}
+	return "="
+}
+
+func (t Totals) get(total string) int {
+	switch total {
+	case "repos":
+		return t.Repos
+	case "commits":
+		return t.Commits
+	case "new_leaks":
+		return t.NewLeaks
+	}
+	return t.Open
+}
@@ -0,0 +1,142 @@
+package tokens
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AlexAkulov/hungryfox/config"
+	"github.com/AlexAkulov/hungryfox/helpers"
+)
+
+const (
+	// ScopeScan - trigger scan of repo
+	ScopeScan = "scan"
+	// ScopeLeaks - query leaks of repo
+	ScopeLeaks = "leaks"
+	// ScopeIngest - send envelopes of edge instance to central one
+	ScopeIngest = "ingest"
+	// ScopeTriage - change status of leaks of repo
+	ScopeTriage = "triage"
+	// ScopeAdmin - add, remove, pause and scan repos at runtime
+	ScopeAdmin = "admin"
+	// ScopeBadge - only badges of repos, token can be embedded into README without access to leaks
+	ScopeBadge = "badge"
+)
+
+// Token - scoped api token
+type Token struct {
+	Name   string
+	Secret string
+	Scopes []string
+	// Repos - glob patterns of repo path or host/path of repo url, every repo if empty
+	Repos []string
+	// Source - secret is read on every use if set, so a rotated token of vault is accepted
+	Source func() (string, error)
+}
+
+// Tokens - checker of api tokens, access is open if there are no tokens
+type Tokens []Token
+
+// New - resolve secrets of configured tokens
+func New(conf []config.APIToken) (Tokens, error) {
+	result := Tokens{}
+	for _, t := range conf {
+		secret, err := t.GetToken()
+		if err != nil {
+			return nil, fmt.Errorf("api token '%s': %v", t.Name, err)
+		}
+		for _, scope := range t.Scopes {
+			if scope != ScopeScan && scope != ScopeLeaks && scope != ScopeIngest && scope != ScopeTriage && scope != ScopeAdmin && scope != ScopeBadge {
+				return nil, fmt.Errorf("api token '%s': unknown scope '%s'", t.Name, scope)
+			}
+		}
+		token := Token{Name: t.Name, Secret: secret, Scopes: t.Scopes, Repos: t.Repos}
+		if t.TokenVault != "" {
+			token.Source = t.GetToken
+		}
+		result = append(result, token)
+	}
+	return result, nil
+}
+
+// Authorize - find token of request with scope, nil token without error means open access
+func (t Tokens) Authorize(r *http.Request, scope string) (*Token, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	// query parameter is for badges and links where headers can't be set
+	secret := r.URL.Query().Get("token")
+	if header := r.Header.Get("X-Hungryfox-Token"); header != "" {
+		secret = header
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		secret = strings.TrimPrefix(auth, "Bearer ")
+	}
+	// basic auth is for browsers, user name is ignored and password is the token
+	if _, password, ok := r.BasicAuth(); ok {
+		secret = password
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("token is required")
+	}
+	for i := range t {
+		if subtle.ConstantTimeCompare([]byte(t[i].Current()), []byte(secret)) != 1 {
+			continue
+		}
+		if !t[i].HasScope(scope) {
+			return nil, fmt.Errorf("token '%s' has no scope '%s'", t[i].Name, scope)
+		}
+		return &t[i], nil
+	}
+	return nil, fmt.Errorf("bad token")
+}
+
+// Require - token of request with scope, access is never open: without tokens every request is refused
+func (t Tokens) Require(r *http.Request, scope string) (*Token, error) {
+	if !t.Configured(scope) {
+		return nil, fmt.Errorf("no token with scope '%s' is configured", scope)
+	}
+	return t.Authorize(r, scope)
+}
+
+// Configured - at least one token has scope
+func (t Tokens) Configured(scope string) bool {
+	for i := range t {
+		if t[i].HasScope(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Current - secret of source, the secret read on start if source is unavailable
+func (t *Token) Current() string {
+	if t.Source == nil {
+		return t.Secret
+	}
+	if secret, err := t.Source(); err == nil && secret != "" {
+		return secret
+	}
+	return t.Secret
+}
+
+// HasScope - token allows scope
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		// badge shows number of leaks, so leaks scope includes it
+		if s == scope || (scope == ScopeBadge && s == ScopeLeaks) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowRepo - token allows repo with any of given repo paths or urls, nil token allows everything
+func (t *Token) AllowRepo(repos ...string) bool {
+	if t == nil || len(t.Repos) == 0 {
+		return true
+	}
+	return helpers.MatchRepo(t.Repos, repos...)
+}
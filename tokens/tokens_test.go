@@ -0,0 +1,85 @@
+package tokens
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTokens(t *testing.T) {
+	tokens := Tokens{
+		{Name: "backend", Secret: "b", Scopes: []string{ScopeScan}, Repos: []string{"backend/**"}},
+		{Name: "audit", Secret: "a", Scopes: []string{ScopeLeaks}},
+	}
+	request := func(header, value string) *http.Request {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		return r
+	}
+
+	Convey("no tokens means open access", t, func() {
+		token, err := Tokens{}.Authorize(request("", ""), ScopeLeaks)
+		So(err, ShouldBeNil)
+		So(token.AllowRepo("anything"), ShouldBeTrue)
+	})
+	Convey("required token is never open", t, func() {
+		_, err := Tokens{}.Require(request("", ""), ScopeAdmin)
+		So(err, ShouldNotBeNil)
+		So(tokens.Configured(ScopeAdmin), ShouldBeFalse)
+		So(tokens.Configured(ScopeLeaks), ShouldBeTrue)
+		token, err := tokens.Require(request("X-Hungryfox-Token", "a"), ScopeLeaks)
+		So(err, ShouldBeNil)
+		So(token.Name, ShouldEqual, "audit")
+	})
+	Convey("token is required", t, func() {
+		_, err := tokens.Authorize(request("", ""), ScopeLeaks)
+		So(err, ShouldNotBeNil)
+		_, err = tokens.Authorize(request("Authorization", "Bearer nope"), ScopeLeaks)
+		So(err, ShouldNotBeNil)
+	})
+	Convey("scope is checked", t, func() {
+		_, err := tokens.Authorize(request("Authorization", "Bearer b"), ScopeLeaks)
+		So(err, ShouldNotBeNil)
+		token, err := tokens.Authorize(request("X-Hungryfox-Token", "b"), ScopeScan)
+		So(err, ShouldBeNil)
+		So(token.Name, ShouldEqual, "backend")
+	})
+	Convey("badge scope doesn't give access to leaks", t, func() {
+		tokens := Tokens{{Name: "readme", Secret: "r", Scopes: []string{ScopeBadge}}, {Name: "audit", Secret: "a", Scopes: []string{ScopeLeaks}}}
+		_, err := tokens.Authorize(request("X-Hungryfox-Token", "r"), ScopeBadge)
+		So(err, ShouldBeNil)
+		_, err = tokens.Authorize(request("X-Hungryfox-Token", "r"), ScopeLeaks)
+		So(err, ShouldNotBeNil)
+		_, err = tokens.Authorize(request("X-Hungryfox-Token", "a"), ScopeBadge)
+		So(err, ShouldBeNil)
+	})
+	Convey("token of source is read on every use", t, func() {
+		current := "v1"
+		rotated := Tokens{{Name: "vault", Secret: "v1", Scopes: []string{ScopeLeaks}, Source: func() (string, error) { return current, nil }}}
+		_, err := rotated.Authorize(request("X-Hungryfox-Token", "v1"), ScopeLeaks)
+		So(err, ShouldBeNil)
+		current = "v2"
+		_, err = rotated.Authorize(request("X-Hungryfox-Token", "v1"), ScopeLeaks)
+		So(err, ShouldNotBeNil)
+		_, err = rotated.Authorize(request("X-Hungryfox-Token", "v2"), ScopeLeaks)
+		So(err, ShouldBeNil)
+	})
+	Convey("token is password of basic auth", t, func() {
+		r := request("", "")
+		r.SetBasicAuth("anyone", "b")
+		token, err := tokens.Authorize(r, ScopeScan)
+		So(err, ShouldBeNil)
+		So(token.Name, ShouldEqual, "backend")
+	})
+	Convey("repos are checked by path and url", t, func() {
+		token := &tokens[0]
+		So(token.AllowRepo("backend/api"), ShouldBeTrue)
+		So(token.AllowRepo("https://gitlab.example.com/backend/team/api.git"), ShouldBeTrue)
+		So(token.AllowRepo("git@gitlab.example.com:backend/api.git"), ShouldBeTrue)
+		So(token.AllowRepo("frontend/app", "https://gitlab.example.com/frontend/app"), ShouldBeFalse)
+		So(tokens[1].AllowRepo("frontend/app"), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,238 @@
+// Package vault - credentials of senders and discovery from HashiCorp Vault referenced as path#field,
+// secrets are cached until their lease expires or refresh passes and token of client is renewed in background
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox/clock"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/tomb.v2"
+)
+
+// retryInterval - delay of token renewal after failure
+const retryInterval = time.Minute
+
+type secret struct {
+	data    map[string]string
+	expires time.Time // never if zero
+}
+
+// Client - reader of secrets of kv v1, kv v2 and dynamic secret engines
+type Client struct {
+	Address   string
+	Token     string
+	Namespace string // enterprise namespace, root if empty
+	// Refresh - secrets without lease (kv) are read again after it, they are cached forever if zero
+	Refresh time.Duration
+	HTTP    *http.Client
+	Clock   clock.Clock // system clock if nil
+	Log     zerolog.Logger
+
+	mutex   sync.Mutex
+	secrets map[string]*secret // by path
+	tomb    tomb.Tomb
+}
+
+// response - common part of vault api responses
+type response struct {
+	LeaseDuration int                    `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		LeaseDuration int  `json:"lease_duration"`
+		Renewable     bool `json:"renewable"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// ParseRef - path and field of reference like secret/data/hungryfox/smtp#password
+func ParseRef(ref string) (string, string, error) {
+	i := strings.LastIndex(ref, "#")
+	if i <= 0 || i == len(ref)-1 {
+		return "", "", fmt.Errorf("vault reference '%s' is not path#field", ref)
+	}
+	return strings.Trim(ref[:i], "/"), ref[i+1:], nil
+}
+
+func (c *Client) request(method, path string) (*response, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(c.Address, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", c.Token)
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	result := &response{}
+	if err := json.Unmarshal(body, result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("can't parse response of %s: %v", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(result.Errors) > 0 {
+			return nil, fmt.Errorf("vault %s %s: %s: %s", method, path, resp.Status, strings.Join(result.Errors, ", "))
+		}
+		return nil, fmt.Errorf("vault %s %s: %s", method, path, resp.Status)
+	}
+	return result, nil
+}
+
+// Read - fields of secret at path and its lease duration, data of kv v2 is unwrapped
+func (c *Client) Read(path string) (map[string]string, time.Duration, error) {
+	resp, err := c.request(http.MethodGet, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	data := resp.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	result := map[string]string{}
+	for key, value := range data {
+		if s, ok := value.(string); ok {
+			result[key] = s
+		} else {
+			result[key] = fmt.Sprint(value)
+		}
+	}
+	return result, time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+// Get - field of reference, secret is read again when its lease expires or after refresh if it has no lease,
+// the previous value is returned if vault is unavailable at that moment. Requests to vault are made without lock,
+// so a slow vault doesn't block readers of cached secrets
+func (c *Client) Get(ref string) (string, error) {
+	path, field, err := ParseRef(ref)
+	if err != nil {
+		return "", err
+	}
+	now := clock.Or(c.Clock).Now()
+	c.mutex.Lock()
+	cached := c.secrets[path]
+	c.mutex.Unlock()
+	if cached == nil || (!cached.expires.IsZero() && !now.Before(cached.expires)) {
+		data, lease, err := c.Read(path)
+		switch {
+		case err != nil && cached == nil:
+			return "", err
+		case err != nil:
+			c.Log.Warn().Str("service", "vault").Str("path", path).Str("error", err.Error()).Msg("can't renew secret, previous value is used")
+		default:
+			cached = &secret{data: data}
+			if lease <= 0 {
+				lease = c.Refresh
+			}
+			if lease > 0 {
+				cached.expires = now.Add(lease)
+			}
+			c.mutex.Lock()
+			if c.secrets == nil {
+				c.secrets = map[string]*secret{}
+			}
+			c.secrets[path] = cached
+			c.mutex.Unlock()
+		}
+	}
+	value, ok := cached.data[field]
+	if !ok {
+		return "", fmt.Errorf("no field '%s' in vault secret %s", field, path)
+	}
+	return value, nil
+}
+
+// Prefetch - read all references on start, so missing secrets and permissions fail start and not the first send
+func (c *Client) Prefetch(refs []string) error {
+	for _, ref := range refs {
+		if _, err := c.Get(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renewToken - renew token of client, zero ttl if the token is not renewable
+func (c *Client) renewToken() (time.Duration, error) {
+	resp, err := c.request(http.MethodPost, "auth/token/renew-self")
+	if err != nil {
+		return 0, err
+	}
+	if resp.Auth == nil || !resp.Auth.Renewable {
+		return 0, nil
+	}
+	return time.Duration(resp.Auth.LeaseDuration) * time.Second, nil
+}
+
+// tokenTTL - remaining ttl of token of client, zero if it is not renewable or never expires
+func (c *Client) tokenTTL() (time.Duration, error) {
+	resp, err := c.request(http.MethodGet, "auth/token/lookup-self")
+	if err != nil {
+		return 0, err
+	}
+	renewable, _ := resp.Data["renewable"].(bool)
+	ttl, _ := resp.Data["ttl"].(float64)
+	if !renewable {
+		return 0, nil
+	}
+	return time.Duration(ttl) * time.Second, nil
+}
+
+// Start - renew token at half of its ttl
+func (c *Client) Start() error {
+	ttl, err := c.tokenTTL()
+	if err != nil {
+		return err
+	}
+	c.tomb.Go(func() error {
+		if ttl == 0 {
+			c.Log.Debug().Str("service", "vault").Msg("token is not renewable")
+			return nil
+		}
+		wait := ttl / 2
+		for {
+			select {
+			case <-c.tomb.Dying():
+				return nil
+			case <-time.After(wait):
+			}
+			ttl, err := c.renewToken()
+			switch {
+			case err != nil:
+				c.Log.Error().Str("service", "vault").Str("error", err.Error()).Msg("can't renew token")
+				wait = retryInterval
+			case ttl == 0:
+				c.Log.Warn().Str("service", "vault").Msg("token is not renewable anymore")
+				return nil
+			default:
+				c.Log.Debug().Str("service", "vault").Str("ttl", ttl.String()).Msg("token is renewed")
+				wait = ttl / 2
+			}
+		}
+	})
+	return nil
+}
+
+func (c *Client) Stop() error {
+	c.tomb.Kill(nil)
+	return c.tomb.Wait()
+}
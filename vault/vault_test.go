@@ -0,0 +1,123 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox/clock"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClient(t *testing.T) {
+	reads, kvVersion, down := 0, 0, false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.root" || r.Header.Get("X-Vault-Namespace") != "security" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/hungryfox/smtp":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"data": map[string]interface{}{"password": "qwerty"}, "metadata": map[string]interface{}{"version": 3}},
+			})
+		case "/v1/secret/data/hungryfox/rotated":
+			kvVersion++
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"data": map[string]interface{}{"token": "t" + string(rune('0'+kvVersion))}, "metadata": map[string]interface{}{"version": kvVersion}},
+			})
+		case "/v1/database/creds/readonly":
+			reads++
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"lease_duration": 3600,
+				"data":           map[string]interface{}{"password": "p" + string(rune('0'+reads)), "ttl": 3600},
+			})
+		case "/v1/auth/token/lookup-self":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"ttl": 7200, "renewable": true}})
+		case "/v1/auth/token/renew-self":
+			json.NewEncoder(w).Encode(map[string]interface{}{"auth": map[string]interface{}{"lease_duration": 7200, "renewable": true}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{}})
+		}
+	}))
+	defer server.Close()
+	fakeClock := clock.NewFake(time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC), 0)
+	c := &Client{Address: server.URL, Token: "s.root", Namespace: "security", Clock: fakeClock}
+
+	Convey("references are path#field", t, func() {
+		path, field, err := ParseRef("/secret/data/hungryfox/smtp#password")
+		So(err, ShouldBeNil)
+		So(path, ShouldEqual, "secret/data/hungryfox/smtp")
+		So(field, ShouldEqual, "password")
+		for _, ref := range []string{"secret/smtp", "#password", "secret/smtp#"} {
+			_, _, err := ParseRef(ref)
+			So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("data of kv v2 is unwrapped", t, func() {
+		password, err := c.Get("secret/data/hungryfox/smtp#password")
+		So(err, ShouldBeNil)
+		So(password, ShouldEqual, "qwerty")
+		_, err = c.Get("secret/data/hungryfox/smtp#token")
+		So(err, ShouldNotBeNil)
+		So(c.Prefetch([]string{"secret/data/hungryfox/smtp#password", "secret/data/missing#token"}), ShouldNotBeNil)
+	})
+
+	Convey("secret is read again when its lease expires", t, func() {
+		password, _ := c.Get("database/creds/readonly#password")
+		So(password, ShouldEqual, "p1")
+		fakeClock.Advance(59 * time.Minute)
+		password, _ = c.Get("database/creds/readonly#password")
+		So(password, ShouldEqual, "p1")
+		fakeClock.Advance(time.Minute)
+		password, _ = c.Get("database/creds/readonly#password")
+		So(password, ShouldEqual, "p2")
+	})
+
+	Convey("secret without lease is read again after refresh", t, func() {
+		refreshed := &Client{Address: server.URL, Token: "s.root", Namespace: "security", Clock: fakeClock, Refresh: 5 * time.Minute}
+		token, _ := refreshed.Get("secret/data/hungryfox/rotated#token")
+		So(token, ShouldEqual, "t1")
+		fakeClock.Advance(4 * time.Minute)
+		token, _ = refreshed.Get("secret/data/hungryfox/rotated#token")
+		So(token, ShouldEqual, "t1")
+		fakeClock.Advance(time.Minute)
+		token, _ = refreshed.Get("secret/data/hungryfox/rotated#token")
+		So(token, ShouldEqual, "t2")
+	})
+
+	Convey("previous value is used while vault is down", t, func() {
+		down = true
+		defer func() { down = false }()
+		fakeClock.Advance(time.Hour)
+		password, err := c.Get("database/creds/readonly#password")
+		So(err, ShouldBeNil)
+		So(password, ShouldEqual, "p2")
+	})
+
+	Convey("token is renewed", t, func() {
+		ttl, err := c.tokenTTL()
+		So(err, ShouldBeNil)
+		So(ttl, ShouldEqual, 2*time.Hour)
+		ttl, err = c.renewToken()
+		So(err, ShouldBeNil)
+		So(ttl, ShouldEqual, 2*time.Hour)
+		So(c.Start(), ShouldBeNil)
+		So(c.Stop(), ShouldBeNil)
+	})
+
+	Convey("errors of vault are returned", t, func() {
+		_, err := (&Client{Address: server.URL, Token: "bad"}).Get("secret/data/hungryfox/smtp#password")
+		So(err.Error(), ShouldEndWith, "403 Forbidden: permission denied")
+	})
+}
@@ -0,0 +1,135 @@
+package verify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+)
+
+var (
+	awsKeyIDRe  = regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`)
+	awsSecretRe = regexp.MustCompile(`(?i)(?:secret|private).{0,20}['"=:\s]([0-9a-zA-Z/+]{40})\b`)
+)
+
+// awsRegion - region of global sts endpoint
+const awsRegion = "us-east-1"
+
+// awsCredential - key id and secret key of leak string and context joined by colon, empty if one of them is missing,
+// key id and secret key are usually on neighbour lines
+func awsCredential(leak hungryfox.Leak) string {
+	text := leak.LeakString + "\n" + leak.Context
+	keyID := awsKeyIDRe.FindStringSubmatch(text)
+	secretKey := awsSecretRe.FindStringSubmatch(text)
+	if keyID == nil || secretKey == nil {
+		return ""
+	}
+	return keyID[1] + ":" + secretKey[1]
+}
+
+// checkAWS - sts GetCallerIdentity answers for any valid key without permissions, InvalidClientTokenId
+// and SignatureDoesNotMatch mean the key is not live
+func checkAWS(client *http.Client, baseURL, credential string) (bool, error) {
+	i := strings.Index(credential, ":")
+	body := "Action=GetCallerIdentity&Version=2011-06-15"
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/", strings.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signSTS(req, body, credential[:i], credential[i+1:], time.Now())
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return true, nil
+	}
+	result := struct {
+		Error struct {
+			Code string `xml:"Code"`
+		} `xml:"Error"`
+	}{}
+	xml.Unmarshal(data, &result)
+	switch result.Error.Code {
+	case "InvalidClientTokenId", "SignatureDoesNotMatch", "ExpiredToken":
+		return false, nil
+	case "AccessDenied":
+		// key is valid but has no access
+		return true, nil
+	}
+	return false, fmt.Errorf("unexpected status %d %s", resp.StatusCode, result.Error.Code)
+}
+
+// signSTS - authorization header of AWS signature version 4 for sts form request
+func signSTS(req *http.Request, body, accessKey, secretKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	payloadHash := sha256Hex(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-date:" + amzDate,
+		"",
+		"content-type;host;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + awsRegion + "/sts/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, part := range []string{awsRegion, "sts", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=content-type;host;x-amz-date, Signature=%s", accessKey, scope, signature))
+}
+
+func sha256Hex(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// repoBaseURL - scheme and host of http or ssh url of repo, empty for local paths
+func repoBaseURL(repoURL string) string {
+	if strings.HasPrefix(repoURL, "git@") {
+		if i := strings.Index(repoURL, ":"); i > 0 {
+			return "https://" + repoURL[len("git@"):i]
+		}
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return u.Scheme + "://" + u.Host
+	case "ssh":
+		return "https://" + u.Hostname()
+	}
+	return ""
+}
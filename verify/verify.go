@@ -0,0 +1,199 @@
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/clock"
+)
+
+// Results of verification, empty if leak was not checked
+const (
+	Verified   = "verified"
+	Unverified = "unverified"
+)
+
+// Verifier - checks whether credential of leak is live
+type Verifier struct {
+	Name   string
+	Secret *regexp.Regexp // extracts credential from leak string
+	URL    string         // base url of api
+	// RepoHost - credential is checked on host of repo first, self-hosted instances issue tokens too
+	RepoHost bool
+	check    func(client *http.Client, baseURL, secret string) (bool, error)
+	// extract - credential of leak instead of Secret, for credentials of several parts
+	extract func(leak hungryfox.Leak) string
+}
+
+// Verifiers - known verifiers by name
+var Verifiers = map[string]Verifier{
+	"github": {
+		Name:   "github",
+		Secret: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`),
+		URL:    "https://api.github.com",
+		check: func(client *http.Client, baseURL, secret string) (bool, error) {
+			return checkStatus(client, http.MethodGet, baseURL+"/user", "token "+secret)
+		},
+	},
+	"aws": {
+		Name:    "aws",
+		Secret:  awsKeyIDRe,
+		URL:     "https://sts.amazonaws.com",
+		check:   checkAWS,
+		extract: awsCredential,
+	},
+	"gitlab": {
+		Name:     "gitlab",
+		Secret:   regexp.MustCompile(`\bglpat-[0-9A-Za-z_\-]{20}`),
+		URL:      "https://gitlab.com",
+		RepoHost: true,
+		check: func(client *http.Client, baseURL, secret string) (bool, error) {
+			return checkStatus(client, http.MethodGet, baseURL+"/api/v4/personal_access_tokens/self", "Bearer "+secret)
+		},
+	},
+	"slack": {
+		Name:   "slack",
+		Secret: regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}`),
+		URL:    "https://slack.com",
+		check:  checkSlack,
+	},
+	"stripe": {
+		Name:   "stripe",
+		Secret: regexp.MustCompile(`\b[rs]k_live_[0-9a-zA-Z]{24,}`),
+		URL:    "https://api.stripe.com",
+		check: func(client *http.Client, baseURL, secret string) (bool, error) {
+			return checkStatus(client, http.MethodGet, baseURL+"/v1/balance", "Bearer "+secret)
+		},
+	},
+}
+
+// Checker - verifies leaks with rate limit, leaks over limit are not checked
+type Checker struct {
+	Verifiers []Verifier
+	Client    *http.Client
+	Interval  time.Duration // minimal interval between requests
+	Clock     clock.Clock   // system clock if nil
+
+	mutex sync.Mutex
+	next  time.Time
+}
+
+// New - checker with verifiers of names, all if names are empty
+func New(names []string, client *http.Client, ratePerMinute int) (*Checker, error) {
+	c := &Checker{Client: client, Interval: time.Minute / time.Duration(ratePerMinute)}
+	if len(names) == 0 {
+		for name := range Verifiers {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		v, ok := Verifiers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown verifier '%s'", name)
+		}
+		c.Verifiers = append(c.Verifiers, v)
+	}
+	return c, nil
+}
+
+// Check - result of the first verifier which finds credential in leak, empty if there is no such
+// verifier, rate limit is exceeded or api can't tell
+func (c *Checker) Check(leak hungryfox.Leak) (string, error) {
+	for _, v := range c.Verifiers {
+		secret := v.Secret.FindString(leak.LeakString)
+		if v.extract != nil {
+			secret = v.extract(leak)
+		}
+		if secret == "" {
+			continue
+		}
+		if !c.allow() {
+			return "", nil
+		}
+		live, err := c.check(v, leak, secret)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", v.Name, err)
+		}
+		if live {
+			return Verified, nil
+		}
+		return Unverified, nil
+	}
+	return "", nil
+}
+
+// check - on host of repo first if verifier supports it, api of verifier answers if host of repo can't tell
+func (c *Checker) check(v Verifier, leak hungryfox.Leak, secret string) (bool, error) {
+	if base := repoBaseURL(leak.RepoURL); v.RepoHost && base != "" && base != v.URL {
+		if live, err := v.check(c.Client, base, secret); err == nil && live {
+			return true, nil
+		}
+	}
+	return v.check(c.Client, v.URL, secret)
+}
+
+func (c *Checker) allow() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := clock.Or(c.Clock).Now()
+	if now.Before(c.next) {
+		return false
+	}
+	c.next = now.Add(c.Interval)
+	return true
+}
+
+// checkStatus - credential is live if api answers 2xx or 403 which means missing permissions of
+// authenticated credential, it is not if 401
+func checkStatus(client *http.Client, method, url, authorization string) (bool, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", authorization)
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode/100 == 2, resp.StatusCode == http.StatusForbidden:
+		return true, nil
+	case resp.StatusCode == http.StatusUnauthorized:
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+// checkSlack - auth.test answers 200 for invalid tokens too, result is in body
+func checkSlack(client *http.Client, baseURL, secret string) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/api/auth.test", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+secret)
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	result := struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	switch {
+	case result.OK:
+		return true, nil
+	case result.Error == "invalid_auth", result.Error == "account_inactive", result.Error == "token_revoked":
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected error '%s'", result.Error)
+}
@@ -0,0 +1,129 @@
+// Package watch - polling watcher of working tree for developer mode, changed files are reported after they settle
+package watch
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher - files of Root changed since previous Poll, hidden directories (.git, .idea) are skipped
+type Watcher struct {
+	Root    string
+	Exclude []string // glob patterns of relative paths or base names of skipped files and directories
+	MaxSize int64    // bigger files are skipped, no limit if zero
+
+	files map[string]fileState
+}
+
+// excluded - rel is separated by slash on every OS, so are patterns
+func (w *Watcher) excluded(rel string) bool {
+	for _, pattern := range w.Exclude {
+		pattern = filepath.ToSlash(pattern)
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Watcher) snapshot() map[string]fileState {
+	files := map[string]fileState{}
+	filepath.Walk(w.Root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// file was removed during walk or is not readable
+			return nil
+		}
+		rel, err := filepath.Rel(w.Root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), ".") || w.excluded(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || w.excluded(rel) || (w.MaxSize > 0 && info.Size() > w.MaxSize) {
+			return nil
+		}
+		files[rel] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return files
+}
+
+// Poll - relative paths of created or modified and of removed files since previous Poll,
+// every file is created on the first Poll
+func (w *Watcher) Poll() (changed, removed []string) {
+	files := w.snapshot()
+	for rel, state := range files {
+		if prev, ok := w.files[rel]; !ok || prev != state {
+			changed = append(changed, rel)
+		}
+	}
+	for rel := range w.files {
+		if _, ok := files[rel]; !ok {
+			removed = append(removed, rel)
+		}
+	}
+	w.files = files
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// Run - poll every interval and call onChange when nothing has changed for debounce since the last change,
+// so a file is inspected once after editor has saved it and not on every keystroke of autosave
+func (w *Watcher) Run(interval, debounce time.Duration, stop <-chan struct{}, onChange func(changed, removed []string)) {
+	changed, removed := map[string]bool{}, map[string]bool{}
+	var lastChange time.Time
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		c, r := w.Poll()
+		for _, rel := range c {
+			changed[rel] = true
+			delete(removed, rel)
+		}
+		for _, rel := range r {
+			removed[rel] = true
+			delete(changed, rel)
+		}
+		if len(c)+len(r) > 0 {
+			lastChange = time.Now()
+			continue
+		}
+		if len(changed)+len(removed) == 0 || time.Since(lastChange) < debounce {
+			continue
+		}
+		onChange(sortedKeys(changed), sortedKeys(removed))
+		changed, removed = map[string]bool{}, map[string]bool{}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,66 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hungryfox-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(rel, content string) {
+		path := filepath.Join(dir, rel)
+		So(os.MkdirAll(filepath.Dir(path), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+	}
+	w := &Watcher{Root: dir, Exclude: []string{"*.log", "node_modules"}, MaxSize: 100}
+
+	Convey("every file is created on the first poll", t, func() {
+		write("main.go", "package main")
+		write("config/app.yml", "password: 123")
+		write(".git/config", "[core]")
+		write("node_modules/lib/index.js", "token")
+		write("debug.log", "token")
+		write("big.bin", string(make([]byte, 200)))
+		changed, removed := w.Poll()
+		So(changed, ShouldResemble, []string{"config/app.yml", "main.go"})
+		So(removed, ShouldBeEmpty)
+	})
+
+	Convey("only changed files are returned", t, func() {
+		changed, removed := w.Poll()
+		So(changed, ShouldBeEmpty)
+		So(removed, ShouldBeEmpty)
+		write("config/app.yml", "password: 123456")
+		So(os.Remove(filepath.Join(dir, "main.go")), ShouldBeNil)
+		changed, removed = w.Poll()
+		So(changed, ShouldResemble, []string{"config/app.yml"})
+		So(removed, ShouldResemble, []string{"main.go"})
+	})
+
+	Convey("changes are reported after they settle", t, func() {
+		stop := make(chan struct{})
+		calls := make(chan []string, 10)
+		go w.Run(5*time.Millisecond, 50*time.Millisecond, stop, func(changed, removed []string) {
+			calls <- changed
+		})
+		write("a.go", "a")
+		time.Sleep(20 * time.Millisecond)
+		write("b.go", "b")
+		select {
+		case changed := <-calls:
+			So(changed, ShouldResemble, []string{"a.go", "b.go"})
+		case <-time.After(5 * time.Second):
+			So("timeout", ShouldBeEmpty)
+		}
+		close(stop)
+	})
+}
@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/tokens"
+
+	"github.com/rs/zerolog"
+)
+
+const maxPayloadSize = 25 * 1024 * 1024
+
+// IScanTrigger - something that can scan repo immediately
+type IScanTrigger interface {
+	TriggerScan(repoURLs ...string) error
+}
+
+// Server - receive push webhooks from GitHub and GitLab
+type Server struct {
+	Listen  string
+	Secret  string
+	Scanner IScanTrigger
+	// Tokens - tokens with scan scope for /webhook/scan, open if empty
+	Tokens tokens.Tokens
+	// Repos - scanned repos, a token limited by repos scans all its repos if request has no repos
+	Repos hungryfox.IRepoStore
+	Log   zerolog.Logger
+
+	server *http.Server
+}
+
+type githubPush struct {
+	Repository struct {
+		HTMLURL  string `json:"html_url"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+	} `json:"repository"`
+}
+
+type gitlabPush struct {
+	Project struct {
+		WebURL     string `json:"web_url"`
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+	} `json:"project"`
+}
+
+// Start - start listen
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.Listen)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook/github", s.handleGithub)
+	mux.HandleFunc("/webhook/gitlab", s.handleGitlab)
+	mux.HandleFunc("/webhook/scan", s.handleScan)
+	s.server = &http.Server{Handler: mux}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.Log.Error().Str("error", err.Error()).Str("service", "webhook").Msg("serve failed")
+		}
+	}()
+	return nil
+}
+
+// Stop - stop listen
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Method != http.MethodPost {
+		return nil, fmt.Errorf("method %s not allowed", r.Method)
+	}
+	return ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxPayloadSize))
+}
+
+func checkSignature(secret string, body []byte, signature string) bool {
+	var mac hash.Hash
+	switch {
+	case strings.HasPrefix(signature, "sha256="):
+		mac = hmac.New(sha256.New, []byte(secret))
+	case strings.HasPrefix(signature, "sha1="):
+		mac = hmac.New(sha1.New, []byte(secret))
+	default:
+		return false
+	}
+	expected, err := hex.DecodeString(signature[strings.Index(signature, "=")+1:])
+	if err != nil {
+		return false
+	}
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func (s *Server) handleGithub(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.Secret != "" {
+		signature := r.Header.Get("X-Hub-Signature-256")
+		if signature == "" {
+			signature = r.Header.Get("X-Hub-Signature")
+		}
+		if !checkSignature(s.Secret, body, signature) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+	}
+	switch r.Header.Get("X-GitHub-Event") {
+	case "ping":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "push":
+	default:
+		http.Error(w, "unsupported event", http.StatusBadRequest)
+		return
+	}
+	payload := githubPush{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.trigger(w, payload.Repository.HTMLURL, payload.Repository.CloneURL, payload.Repository.SSHURL)
+}
+
+func (s *Server) handleGitlab(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.Secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), []byte(s.Secret)) != 1 {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+		return
+	}
+	if event := r.Header.Get("X-Gitlab-Event"); event != "Push Hook" && event != "Tag Push Hook" {
+		http.Error(w, "unsupported event", http.StatusBadRequest)
+		return
+	}
+	payload := gitlabPush{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.trigger(w, payload.Project.WebURL, payload.Project.GitHTTPURL, payload.Project.GitSSHURL)
+}
+
+type scanRequest struct {
+	Repos []string `json:"repos"`
+}
+
+type scanResponse struct {
+	Repos []string `json:"repos"` // repos which are queued for scan
+}
+
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token, err := s.Tokens.Authorize(r, tokens.ScopeScan)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	request := scanRequest{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(request.Repos) == 0 {
+		if token == nil || len(token.Repos) == 0 || s.Repos == nil {
+			http.Error(w, "repos are required", http.StatusBadRequest)
+			return
+		}
+		if request.Repos, err = s.allowedRepos(token); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(request.Repos) == 0 {
+			http.Error(w, "token allows no scanned repos", http.StatusNotFound)
+			return
+		}
+	}
+	for _, repo := range request.Repos {
+		if !token.AllowRepo(repo) {
+			http.Error(w, fmt.Sprintf("repo %s is not allowed", repo), http.StatusForbidden)
+			return
+		}
+	}
+	// every repo is a separate scan, urls of one trigger are alternative urls of the same repo
+	for _, repo := range request.Repos {
+		if err := s.Scanner.TriggerScan(repo); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	s.Log.Debug().Strs("urls", request.Repos).Str("service", "webhook").Msg("scan requested")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(scanResponse{Repos: request.Repos})
+}
+
+// allowedRepos - urls of scanned repos which token allows
+func (s *Server) allowedRepos(token *tokens.Token) ([]string, error) {
+	repos, err := s.Repos.GetRepos()
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, repo := range repos {
+		if repo.State.RemovedAt.IsZero() && token.AllowRepo(repo.Location.RepoPath, repo.Location.URL) {
+			result = append(result, repo.Location.URL)
+		}
+	}
+	return result, nil
+}
+
+func (s *Server) trigger(w http.ResponseWriter, urls ...string) {
+	s.Log.Debug().Strs("urls", urls).Str("service", "webhook").Msg("push received")
+	if err := s.Scanner.TriggerScan(urls...); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexAkulov/hungryfox"
+	"github.com/AlexAkulov/hungryfox/state/dbstate"
+	"github.com/AlexAkulov/hungryfox/tokens"
+
+	"github.com/rs/zerolog"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeScanner struct {
+	urls  []string
+	scans int
+}
+
+func (f *fakeScanner) TriggerScan(urls ...string) error {
+	f.urls = append(f.urls, urls...)
+	f.scans++
+	return nil
+}
+
+type fakeRepos []hungryfox.Repo
+
+func (f fakeRepos) GetRepos() ([]hungryfox.Repo, error) {
+	return f, nil
+}
+
+func TestGithubWebhook(t *testing.T) {
+	body := []byte(`{"repository":{"html_url":"https://github.com/a/b","clone_url":"https://github.com/a/b.git"}}`)
+	Convey("valid signature triggers scan", t, func() {
+		scanner := &fakeScanner{}
+		s := &Server{Secret: "secret", Scanner: scanner, Log: zerolog.Nop()}
+		req := httptest.NewRequest(http.MethodPost, "/webhook/github", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", "sha256=b8534445d4bc8513419deeb1883ffe0cdedfd771e0fc6838c931d9a76e6de60e")
+		w := httptest.NewRecorder()
+		s.handleGithub(w, req)
+		So(w.Code, ShouldEqual, http.StatusAccepted)
+		So(scanner.urls, ShouldContain, "https://github.com/a/b")
+	})
+	Convey("bad signature is rejected", t, func() {
+		scanner := &fakeScanner{}
+		s := &Server{Secret: "secret", Scanner: scanner, Log: zerolog.Nop()}
+		req := httptest.NewRequest(http.MethodPost, "/webhook/github", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", "sha256=00")
+		w := httptest.NewRecorder()
+		s.handleGithub(w, req)
+		So(w.Code, ShouldEqual, http.StatusUnauthorized)
+		So(scanner.urls, ShouldBeNil)
+	})
+}
+
+func TestGitlabWebhook(t *testing.T) {
+	Convey("token checked and project urls passed", t, func() {
+		scanner := &fakeScanner{}
+		s := &Server{Secret: "secret", Scanner: scanner, Log: zerolog.Nop()}
+		req := httptest.NewRequest(http.MethodPost, "/webhook/gitlab", bytes.NewReader([]byte(`{"project":{"web_url":"https://gitlab.example.com/a/b"}}`)))
+		req.Header.Set("X-Gitlab-Event", "Push Hook")
+		req.Header.Set("X-Gitlab-Token", "secret")
+		w := httptest.NewRecorder()
+		s.handleGitlab(w, req)
+		So(w.Code, ShouldEqual, http.StatusAccepted)
+		So(scanner.urls, ShouldContain, "https://gitlab.example.com/a/b")
+	})
+}
+
+func TestScanWebhook(t *testing.T) {
+	apiTokens := tokens.Tokens{{Name: "backend", Secret: "b", Scopes: []string{tokens.ScopeScan}, Repos: []string{"backend/**"}}}
+	scan := func(token, body string) (*fakeScanner, int) {
+		scanner := &fakeScanner{}
+		repos := fakeRepos{
+			{Location: hungryfox.RepoLocation{URL: "https://gitlab.example.com/backend/api", RepoPath: "backend/api"}},
+			{Location: hungryfox.RepoLocation{URL: "https://gitlab.example.com/backend/web", RepoPath: "backend/web"}},
+			{Location: hungryfox.RepoLocation{URL: "https://gitlab.example.com/backend/old", RepoPath: "backend/old"}, State: hungryfox.RepoState{RemovedAt: time.Now()}},
+			{Location: hungryfox.RepoLocation{URL: "https://gitlab.example.com/frontend/app", RepoPath: "frontend/app"}},
+		}
+		s := &Server{Scanner: scanner, Tokens: apiTokens, Repos: repos, Log: zerolog.Nop()}
+		req := httptest.NewRequest(http.MethodPost, "/webhook/scan", bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.handleScan(w, req)
+		return scanner, w.Code
+	}
+	Convey("allowed repo is scanned", t, func() {
+		scanner, code := scan("b", `{"repos":["https://gitlab.example.com/backend/api.git"]}`)
+		So(code, ShouldEqual, http.StatusAccepted)
+		So(scanner.urls, ShouldContain, "https://gitlab.example.com/backend/api.git")
+	})
+	Convey("every repo is a separate scan", t, func() {
+		scanner, code := scan("b", `{"repos":["https://gitlab.example.com/backend/api.git","https://gitlab.example.com/backend/web.git"]}`)
+		So(code, ShouldEqual, http.StatusAccepted)
+		So(scanner.scans, ShouldEqual, 2)
+	})
+	Convey("token limited by repos scans all its repos without repos in request", t, func() {
+		scanner, code := scan("b", `{}`)
+		So(code, ShouldEqual, http.StatusAccepted)
+		So(scanner.scans, ShouldEqual, 2)
+		So(scanner.urls, ShouldResemble, []string{"https://gitlab.example.com/backend/api", "https://gitlab.example.com/backend/web"})
+	})
+	Convey("repo out of token scope is forbidden", t, func() {
+		scanner, code := scan("b", `{"repos":["https://gitlab.example.com/frontend/app.git"]}`)
+		So(code, ShouldEqual, http.StatusForbidden)
+		So(scanner.urls, ShouldBeNil)
+	})
+	Convey("bad token", t, func() {
+		_, code := scan("x", `{"repos":["https://gitlab.example.com/backend/api.git"]}`)
+		So(code, ShouldEqual, http.StatusUnauthorized)
+	})
+	Convey("token limited by repos scans all its repos from state_db", t, func() {
+		dir, err := ioutil.TempDir("", "webhook")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		stateDB := &dbstate.StateManager{Location: filepath.Join(dir, "state.ql"), Import: filepath.Join(dir, "state.yml")}
+		So(stateDB.Start(), ShouldBeNil)
+		defer stateDB.Stop()
+		stateDB.Save(hungryfox.Repo{Location: hungryfox.RepoLocation{URL: "https://gitlab.example.com/backend/api", RepoPath: "backend/api"}})
+		stateDB.Save(hungryfox.Repo{Location: hungryfox.RepoLocation{URL: "https://gitlab.example.com/frontend/app", RepoPath: "frontend/app"}})
+		scanner := &fakeScanner{}
+		s := &Server{Scanner: scanner, Tokens: apiTokens, Repos: stateDB, Log: zerolog.Nop()}
+		req := httptest.NewRequest(http.MethodPost, "/webhook/scan", bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("Authorization", "Bearer b")
+		w := httptest.NewRecorder()
+		s.handleScan(w, req)
+		So(w.Code, ShouldEqual, http.StatusAccepted)
+		So(scanner.urls, ShouldResemble, []string{"https://gitlab.example.com/backend/api"})
+	})
+}